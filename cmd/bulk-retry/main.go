@@ -0,0 +1,158 @@
+// Command bulk-retry starts a bulk retry through the query service and reports
+// its progress (POST /admin/retries, GET /admin/retries/{id}):
+//
+//	go run ./cmd/bulk-retry -reason validation_failed -from 2026-10-01T00:00:00Z -to 2026-10-02T00:00:00Z -wait
+//	go run ./cmd/bulk-retry -job 6f1c…
+//
+// QUERY_URL selects the query service (default http://localhost:8083). Under
+// QUERY_AUTHZ=enforce the caller needs an admin X-API-Key (QUERY_API_KEY) or
+// bearer token (QUERY_TOKEN). -wait polls the job until it completes or fails,
+// and a failed job exits 1.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+const defaultQueryURL = "http://localhost:8083"
+
+func main() {
+	var (
+		reason = flag.String("reason", "", "reason code of the failures to retry, e.g. validation_failed")
+		from   = flag.String("from", "", "start of the window, RFC 3339 (inclusive)")
+		to     = flag.String("to", "", "end of the window, RFC 3339 (exclusive; default now)")
+		actor  = flag.String("actor", os.Getenv("USER"), "who is retrying, recorded on the job (X-Actor)")
+		jobID  = flag.String("job", "", "report this job instead of starting one")
+		wait   = flag.Bool("wait", false, "poll the job until it completes or fails")
+		every  = flag.Duration("interval", 2*time.Second, "poll interval with -wait")
+	)
+	flag.Parse()
+
+	baseURL := os.Getenv("QUERY_URL")
+	if baseURL == "" {
+		baseURL = defaultQueryURL
+	}
+	c := &client{baseURL: baseURL, apiKey: os.Getenv("QUERY_API_KEY"), token: os.Getenv("QUERY_TOKEN"), actor: *actor}
+
+	var job *domain.RetryJob
+	var err error
+	if *jobID != "" {
+		job, err = c.get(*jobID)
+	} else {
+		job, err = c.start(*reason, *from, *to)
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+	report(job)
+	for *wait && !done(job) {
+		time.Sleep(*every)
+		if job, err = c.get(job.JobID); err != nil {
+			fatalf("%v", err)
+		}
+		report(job)
+	}
+	if job.Status == domain.RetryJobStatusFailed {
+		os.Exit(1)
+	}
+}
+
+// client calls the query service's retry endpoints.
+type client struct {
+	baseURL string
+	apiKey  string
+	token   string
+	actor   string
+}
+
+// start starts a retry of the failures with reason in [from, to).
+func (c *client) start(reason, from, to string) (*domain.RetryJob, error) {
+	if reason == "" || from == "" {
+		return nil, fmt.Errorf("-reason and -from are required, or -job")
+	}
+	if c.actor == "" {
+		return nil, fmt.Errorf("-actor is required")
+	}
+	fromTime, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		return nil, fmt.Errorf("-from: %v", err)
+	}
+	toTime := time.Now().UTC()
+	if to != "" {
+		if toTime, err = time.Parse(time.RFC3339, to); err != nil {
+			return nil, fmt.Errorf("-to: %v", err)
+		}
+	}
+	body, _ := json.Marshal(map[string]interface{}{"reason": reason, "from": fromTime, "to": toTime})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/admin/retries", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Actor", c.actor)
+	return c.do(req, http.StatusAccepted)
+}
+
+// get reads the job jobID.
+func (c *client) get(jobID string) (*domain.RetryJob, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/admin/retries/"+url.PathEscape(jobID), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req, http.StatusOK)
+}
+
+// do sends req with the caller's credentials and decodes the job it answers
+// with want.
+func (c *client) do(req *http.Request, want int) (*domain.RetryJob, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != want {
+		return nil, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(raw))
+	}
+	var job domain.RetryJob
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return nil, fmt.Errorf("decode job: %v", err)
+	}
+	return &job, nil
+}
+
+// done reports whether job has finished.
+func done(job *domain.RetryJob) bool {
+	return job.Status == domain.RetryJobStatusComplete || job.Status == domain.RetryJobStatusFailed
+}
+
+func report(job *domain.RetryJob) {
+	line := fmt.Sprintf("%s %s: %d enqueued, %d skipped of %d", job.JobID, job.Status, job.Enqueued, job.Skipped, job.TotalKeys)
+	if job.ErrorReason != "" {
+		line += " (" + job.ErrorReason + ")"
+	}
+	fmt.Println(line)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
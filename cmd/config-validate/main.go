@@ -0,0 +1,92 @@
+// Command config-validate checks a service environment before it is deployed:
+// the config loads and validates as the services load it (formats, ranges), and,
+// unless -offline, what it points at is there:
+//
+//	go run ./cmd/config-validate            # env as the services see it
+//	go run ./cmd/config-validate -offline   # formats only, no connections
+//
+// The checks connect as the services do: Postgres is pinged, RabbitMQ is dialed
+// and the topology declared (a no-op when it matches, an error when an exchange
+// or queue exists with other settings), and every MinIO bucket must already exist
+// in MINIO_REGION. Buckets are not created, unlike at service start, so a
+// misspelt name is reported instead of silently becoming a new bucket. Exits 1 on
+// any failure.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/clients"
+	"github.com/fluxa/fluxa/internal/config"
+	"github.com/fluxa/fluxa/internal/db"
+)
+
+func main() {
+	offline := flag.Bool("offline", false, "only validate formats; don't connect to anything")
+	timeout := flag.Duration("timeout", 15*time.Second, "bound on all connection checks")
+	flag.Parse()
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		fatalf("config: %v", err)
+	}
+	fmt.Printf("config: ok (checksum %s)\n", cfg.Checksum())
+	if *offline {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	factory := clients.New(cfg, "config-validate")
+
+	failed := false
+	report := func(name string, problems ...error) {
+		if len(problems) == 0 {
+			fmt.Printf("%s: ok\n", name)
+			return
+		}
+		failed = true
+		for _, p := range problems {
+			fmt.Printf("%s: %v\n", name, p)
+		}
+	}
+
+	dbClient, err := db.Connect(cfg.DSN(), db.PoolFor(db.PoolSingle, time.Second), false)
+	if err == nil {
+		err = dbClient.Ping(ctx)
+		dbClient.Close()
+	}
+	report("postgres", nonNil(err)...)
+
+	mqClient, err := factory.Queue()
+	if err == nil {
+		mqClient.Close()
+	}
+	report("rabbitmq", nonNil(err)...)
+
+	problems, err := factory.CheckStorage(ctx)
+	if err != nil {
+		problems = []error{err}
+	}
+	report("minio", problems...)
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func nonNil(err error) []error {
+	if err == nil {
+		return nil
+	}
+	return []error{err}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -44,6 +44,8 @@ func main() {
 		`SELECT user_id, amount, currency, merchant, ts, metadata_json
 		   FROM events
 		  WHERE metadata_json ->> 'is_fraud_ground_truth' IS NOT NULL
+		    AND NOT is_canary
+		    AND deleted_at IS NULL
 		  ORDER BY ts`)
 	if err != nil {
 		fatalf("query events: %v", err)
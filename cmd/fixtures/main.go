@@ -0,0 +1,67 @@
+// Command fixtures writes generated events as JSON lines, one ingest request body
+// per line, for seeding the local stack or checking in as test data:
+//
+//	go run ./cmd/fixtures -n 1000 -seed 7 -start 2026-01-01T00:00:00Z > events.jsonl
+//	while read -r ev; do curl -s -XPOST localhost:8080/events -d "$ev"; done < events.jsonl
+//
+// The same flags always produce the same file.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/fixtures"
+)
+
+func main() {
+	var (
+		n       = flag.Int("n", 100, "number of events")
+		seed    = flag.Int64("seed", 1, "generator seed")
+		users   = flag.Int("users", 1000, "distinct user IDs")
+		sizes   = flag.String("sizes", "1:400", "payload size distribution as weight:bytes,...")
+		start   = flag.String("start", "", "RFC 3339 time of the first event; empty stamps events with the current time")
+		gap     = flag.Duration("gap", time.Second, "mean gap between event timestamps (with -start)")
+		canary  = flag.Float64("canary-ratio", 0, "fraction of events marked canary (0-1)")
+		median  = flag.Float64("median-amount", 25, "median USD amount")
+		startAt = time.Time{}
+	)
+	flag.Parse()
+
+	dist, err := fixtures.ParseSizeDist(*sizes)
+	if err != nil {
+		fatalf("sizes: %v", err)
+	}
+	if *start != "" {
+		if startAt, err = time.Parse(time.RFC3339, *start); err != nil {
+			fatalf("start: %v", err)
+		}
+	}
+
+	gen := fixtures.New(fixtures.Options{
+		Seed:         *seed,
+		Users:        *users,
+		Sizes:        dist,
+		MedianAmount: *median,
+		Start:        startAt,
+		MeanGap:      *gap,
+		CanaryRatio:  *canary,
+	})
+	w := bufio.NewWriter(os.Stdout)
+	for i := 0; i < *n; i++ {
+		_, payload := gen.Next()
+		w.Write(payload)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		fatalf("write: %v", err)
+	}
+}
+
+func fatalf(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+	os.Exit(1)
+}
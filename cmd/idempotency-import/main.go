@@ -0,0 +1,115 @@
+// Command idempotency-import records event IDs migrated from another system as
+// already processed (idempotency.Client.ImportSucceeded), so replaying those events
+// through ingest during a cutover dedupes instead of inserting them twice:
+//
+//	go run ./cmd/idempotency-import legacy-ids.txt
+//	go run ./cmd/idempotency-import -batch 5000 < events.jsonl
+//
+// Each input line is an event ID or a JSON object with an event_id (an ingest
+// request body). Keys that already exist are left alone. Run it before the replay
+// starts; importing again is harmless.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/idempotency"
+)
+
+const defaultDSN = "host=localhost port=5432 user=fluxa_user password=fluxa_password dbname=fluxa sslmode=disable"
+
+func main() {
+	batch := flag.Int("batch", 1000, fmt.Sprintf("keys written per statement (at most %d)", idempotency.MaxImportBatch))
+	flag.Parse()
+	if *batch <= 0 || *batch > idempotency.MaxImportBatch {
+		fatalf("batch must be between 1 and %d", idempotency.MaxImportBatch)
+	}
+
+	var in io.Reader = os.Stdin
+	if path := flag.Arg(0); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			fatalf("open: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	dsn := os.Getenv("IMPORT_DSN")
+	if dsn == "" {
+		dsn = defaultDSN
+	}
+	client, err := db.NewClient(dsn, 2)
+	if err != nil {
+		fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	idem := idempotency.NewClient(client.GetDB())
+
+	var read, imported int
+	ids := make([]string, 0, *batch)
+	flush := func() {
+		if len(ids) == 0 {
+			return
+		}
+		n, err := idem.ImportSucceeded(ids)
+		if err != nil {
+			fatalf("import after %d keys: %v", read-len(ids), err)
+		}
+		imported += n
+		ids = ids[:0]
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		id, err := eventID(scanner.Text())
+		if err != nil {
+			fatalf("line %d: %v", line, err)
+		}
+		if id == "" {
+			continue
+		}
+		ids = append(ids, id)
+		read++
+		if len(ids) == *batch {
+			flush()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fatalf("read: %v", err)
+	}
+	flush()
+	fmt.Printf("read %d event ids: %d imported, %d already known\n", read, imported, read-imported)
+}
+
+// eventID returns the event ID on line: the line itself, or the event_id of a
+// JSON object. Blank lines give "".
+func eventID(line string) (string, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return line, nil
+	}
+	var ev struct {
+		EventID string `json:"event_id"`
+	}
+	if err := json.Unmarshal([]byte(line), &ev); err != nil {
+		return "", err
+	}
+	if ev.EventID == "" {
+		return "", fmt.Errorf("no event_id")
+	}
+	return ev.EventID, nil
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/fluxa/fluxa/internal/fixtures"
+)
+
+// generator draws events from internal/fixtures and mixes in a share of exact
+// re-sends of earlier events to exercise dedupe. Safe for concurrent use.
+type generator struct {
+	mu       sync.Mutex
+	events   *fixtures.Generator
+	rng      *rand.Rand // duplicate decisions, seeded apart from events
+	dupRatio float64
+	recent   []generated // ring of recently generated events, for duplicates
+	next     int
+}
+
+func newGenerator(seed int64, sizes []fixtures.SizeBucket, dupRatio float64, users int) *generator {
+	return &generator{
+		events:   fixtures.New(fixtures.Options{Seed: seed, Sizes: sizes, Users: users, Source: "loadgen"}),
+		rng:      rand.New(rand.NewSource(seed + 1)),
+		dupRatio: dupRatio,
+		recent:   make([]generated, 0, 1024),
+	}
+}
+
+// generated is one serialized event.
+type generated struct {
+	eventID   string
+	payload   []byte
+	duplicate bool // an exact re-send of an earlier event
+}
+
+// Next returns the next event to send.
+func (g *generator) Next() generated {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.recent) > 0 && g.rng.Float64() < g.dupRatio {
+		dup := g.recent[g.rng.Intn(len(g.recent))]
+		dup.duplicate = true
+		return dup
+	}
+
+	ev, payload := g.events.Next()
+	out := generated{eventID: ev.EventID, payload: payload}
+	if len(g.recent) < cap(g.recent) {
+		g.recent = append(g.recent, out)
+	} else {
+		g.recent[g.next] = out
+		g.next = (g.next + 1) % len(g.recent)
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/fixtures"
+)
+
+func TestGenerator_SizesAndDuplicates(t *testing.T) {
+	gen := newGenerator(1, []fixtures.SizeBucket{{Weight: 1, Bytes: 4096}}, 0.5, 10)
+	var dups int
+	for i := 0; i < 200; i++ {
+		g := gen.Next()
+		if len(g.payload) < 4000 {
+			t.Fatalf("payload %d bytes, want ~4096", len(g.payload))
+		}
+		if g.duplicate {
+			dups++
+		}
+	}
+	if dups < 60 || dups > 140 {
+		t.Errorf("got %d duplicates of 200 at dup-ratio 0.5", dups)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var d []time.Duration
+	for i := 1; i <= 100; i++ {
+		d = append(d, time.Duration(i)*time.Millisecond)
+	}
+	if p := percentile(d, 0.99); p != 99*time.Millisecond {
+		t.Errorf("p99 = %s, want 99ms", p)
+	}
+	if p := percentile(d, 0.5); p != 50*time.Millisecond {
+		t.Errorf("p50 = %s, want 50ms", p)
+	}
+}
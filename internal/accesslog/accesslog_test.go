@@ -0,0 +1,54 @@
+package accesslog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	noop := func(http.ResponseWriter, *http.Request) {}
+	mux.HandleFunc("/events", noop)
+	mux.HandleFunc("/events/", noop)
+	mux.HandleFunc("/events/status-batch", noop)
+	mux.HandleFunc("/admin/events/", noop)
+
+	for _, tc := range []struct{ path, want string }{
+		{"/events", "/events"},
+		{"/events/evt-1", "/events/:id"},
+		{"/events/status-batch", "/events/status-batch"},
+		{"/admin/events/evt-1/restore", "/admin/events/:id/restore"},
+		{"/admin/events/", "/admin/events/"},
+		{"/nope", "unmatched"},
+	} {
+		if got := Route(mux, httptest.NewRequest(http.MethodGet, tc.path, nil)); got != tc.want {
+			t.Errorf("Route(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestWrap_RecordsResponseAndTenant(t *testing.T) {
+	mux := http.NewServeMux()
+	var rec *recorder
+	var tenant string
+	mux.HandleFunc("/events/", func(w http.ResponseWriter, r *http.Request) {
+		SetTenant(r.Context(), "tenant-a")
+		tenant = r.Context().Value(entryKey{}).(*entry).tenant
+		rec = w.(*recorder)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+		w.(http.Flusher).Flush()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/events/evt-1", nil)
+	req.Header.Set("X-API-Key", "secret")
+	Wrap("test", mux, mux).ServeHTTP(httptest.NewRecorder(), req)
+
+	if rec == nil || rec.status != http.StatusAccepted || rec.bytes != 11 {
+		t.Fatalf("recorded %+v, want 202 with 11 bytes", rec)
+	}
+	if tenant != "tenant-a" {
+		t.Errorf("tenant = %q, want the one set by the handler", tenant)
+	}
+}
@@ -5,66 +5,270 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/fluxa/fluxa/internal/domain"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
 )
 
-// Client wraps MinIO operations and implements ports.Storage.
+// Client wraps MinIO operations and implements ports.Storage and
+// ports.LimitedGetter. Object operation errors are domain.RetryableError,
+// domain.NonRetryableError, or domain.NotFoundError (see classifyError), and
+// domain.TooLargeError from GetLimited.
 type Client struct {
-	mc         *minio.Client
-	bucketName string
+	mc            *minio.Client
+	presign       *minio.Client // signs download URLs; mc unless PublicEndpoint is set
+	bucketName    string
+	prefixBuckets map[string]string // see Options.PrefixBuckets
+}
+
+// Options configures NewClientWithOptions. Zero-valued optional fields fall back
+// to minio-go defaults.
+type Options struct {
+	Endpoint   string
+	AccessKey  string
+	SecretKey  string
+	Bucket     string
+	UseSSL     bool
+	Region     string            // optional; skips the bucket-location lookup when set
+	Transport  http.RoundTripper // optional; share one tuned transport across clients
+	AppName    string            // optional; appended to the User-Agent
+	AppVersion string
+	// PublicEndpoint, when set, is the host presigned URLs are issued for, for
+	// clients that reach the object store by another address than Endpoint (the
+	// container network vs. the host). Region should be set with it.
+	PublicEndpoint string
+	// PrefixBuckets sends keys under "<prefix>/" to another bucket, keyed by prefix:
+	// per-producer placements (domain.PayloadPlacement). Prefixes must not nest.
+	// Every bucket is created if missing, like Bucket.
+	PrefixBuckets map[string]string
 }
 
 // NewClient creates a MinIO client and ensures the bucket exists.
 func NewClient(endpoint, accessKey, secretKey, bucketName string, useSSL bool) (*Client, error) {
-	mc, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: useSSL,
+	return NewClientWithOptions(Options{
+		Endpoint:  endpoint,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Bucket:    bucketName,
+		UseSSL:    useSSL,
+	})
+}
+
+// NewClientWithOptions is NewClient with region, transport, and User-Agent overrides.
+func NewClientWithOptions(opts Options) (*Client, error) {
+	bucketName := opts.Bucket
+	mc, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure:    opts.UseSSL,
+		Region:    opts.Region,
+		Transport: opts.Transport,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("minio: failed to create client: %w", err)
 	}
+	if opts.AppName != "" {
+		mc.SetAppInfo(opts.AppName, opts.AppVersion)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	buckets := []string{bucketName}
+	for _, b := range opts.PrefixBuckets {
+		buckets = append(buckets, b)
+	}
+	for _, b := range buckets {
+		if err := ensureBucket(ctx, mc, b); err != nil {
+			return nil, err
+		}
+	}
+
+	presign := mc
+	if opts.PublicEndpoint != "" {
+		presign, err = minio.New(opts.PublicEndpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+			Secure: opts.UseSSL,
+			Region: opts.Region,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("minio: failed to create presign client: %w", err)
+		}
+	}
+
+	return &Client{mc: mc, presign: presign, bucketName: bucketName, prefixBuckets: opts.PrefixBuckets}, nil
+}
+
+func ensureBucket(ctx context.Context, mc *minio.Client, bucketName string) error {
 	exists, err := mc.BucketExists(ctx, bucketName)
 	if err != nil {
-		return nil, fmt.Errorf("minio: failed to check bucket %q: %w", bucketName, err)
+		return fmt.Errorf("minio: failed to check bucket %q: %w", bucketName, err)
 	}
 	if !exists {
 		if err := mc.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{}); err != nil {
-			return nil, fmt.Errorf("minio: failed to create bucket %q: %w", bucketName, err)
+			return fmt.Errorf("minio: failed to create bucket %q: %w", bucketName, err)
+		}
+	}
+	return nil
+}
+
+// CheckBuckets reports, without creating anything, whether opts' buckets exist
+// and, when opts.Region is set, are in that region: one error per problem. The
+// services create a missing bucket on start, so a misspelt name otherwise shows
+// up only as payloads landing in a new, empty bucket.
+func CheckBuckets(ctx context.Context, opts Options) ([]error, error) {
+	mc, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure:    opts.UseSSL,
+		Transport: opts.Transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("minio: failed to create client: %w", err)
+	}
+	buckets := []string{opts.Bucket}
+	for _, b := range opts.PrefixBuckets {
+		buckets = append(buckets, b)
+	}
+	var problems []error
+	for _, b := range buckets {
+		exists, err := mc.BucketExists(ctx, b)
+		if err != nil {
+			return nil, fmt.Errorf("minio: failed to check bucket %q: %w", b, err)
+		}
+		if !exists {
+			problems = append(problems, fmt.Errorf("bucket %q does not exist", b))
+			continue
+		}
+		if opts.Region == "" {
+			continue
+		}
+		location, err := mc.GetBucketLocation(ctx, b)
+		if err != nil {
+			return nil, fmt.Errorf("minio: failed to get location of bucket %q: %w", b, err)
+		}
+		if location != "" && location != opts.Region {
+			problems = append(problems, fmt.Errorf("bucket %q is in region %s, not %s", b, location, opts.Region))
 		}
 	}
+	return problems, nil
+}
 
-	return &Client{mc: mc, bucketName: bucketName}, nil
+// bucket is the bucket holding key: its prefix's, else the default one.
+func (c *Client) bucket(key string) string {
+	for prefix, b := range c.prefixBuckets {
+		if strings.HasPrefix(key, prefix+"/") {
+			return b
+		}
+	}
+	return c.bucketName
 }
 
 // Put stores data at the given key (path within the bucket).
 func (c *Client) Put(ctx context.Context, key string, data []byte) error {
-	_, err := c.mc.PutObject(ctx, c.bucketName, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+	_, err := c.mc.PutObject(ctx, c.bucket(key), key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
 		ContentType: "application/json",
 	})
 	if err != nil {
-		return fmt.Errorf("minio: put %q: %w", key, err)
+		return classifyError(err, fmt.Errorf("minio: put %q: %w", key, err))
 	}
 	return nil
 }
 
 // Get retrieves the object stored at key.
 func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
-	obj, err := c.mc.GetObject(ctx, c.bucketName, key, minio.GetObjectOptions{})
+	obj, err := c.mc.GetObject(ctx, c.bucket(key), key, minio.GetObjectOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("minio: get %q: %w", key, err)
+		return nil, classifyError(err, fmt.Errorf("minio: get %q: %w", key, err))
 	}
 	defer obj.Close()
 
+	// GetObject is lazy: a missing key or denied access surfaces on first read.
 	data, err := io.ReadAll(obj)
 	if err != nil {
-		return nil, fmt.Errorf("minio: read %q: %w", key, err)
+		return nil, classifyError(err, fmt.Errorf("minio: read %q: %w", key, err))
 	}
 	return data, nil
 }
+
+// GetLimited is Get for an object of at most limit bytes: the object is stat'd
+// first, and the read stops past limit in case it was replaced meanwhile.
+func (c *Client) GetLimited(ctx context.Context, key string, limit int64) ([]byte, error) {
+	info, err := c.mc.StatObject(ctx, c.bucket(key), key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, classifyError(err, fmt.Errorf("minio: stat %q: %w", key, err))
+	}
+	if info.Size > limit {
+		return nil, &domain.TooLargeError{Resource: "object", Size: info.Size, Limit: limit}
+	}
+	obj, err := c.mc.GetObject(ctx, c.bucket(key), key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, classifyError(err, fmt.Errorf("minio: get %q: %w", key, err))
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(io.LimitReader(obj, limit+1))
+	if err != nil {
+		return nil, classifyError(err, fmt.Errorf("minio: read %q: %w", key, err))
+	}
+	if int64(len(data)) > limit {
+		return nil, &domain.TooLargeError{Resource: "object", Size: limit + 1, Limit: limit}
+	}
+	return data, nil
+}
+
+// Exists stats the object at key. A missing object is (false, nil), not an error.
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := c.mc.StatObject(ctx, c.bucket(key), key, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return false, nil
+	}
+	return false, classifyError(err, fmt.Errorf("minio: stat %q: %w", key, err))
+}
+
+// PresignGet returns a URL that downloads the object at key without credentials
+// until expiry passes.
+func (c *Client) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := c.presign.PresignedGetObject(ctx, c.bucket(key), key, expiry, nil)
+	if err != nil {
+		return "", classifyError(err, fmt.Errorf("minio: presign %q: %w", key, err))
+	}
+	return u.String(), nil
+}
+
+// ExpirePrefix has the bucket holding prefix delete the objects under it days
+// after they were written, through a lifecycle rule named "expire-<prefix>". An
+// earlier rule of that name is replaced; the bucket's other rules are kept.
+func (c *Client) ExpirePrefix(ctx context.Context, prefix string, days int) error {
+	bucket := c.bucket(prefix + "/")
+	config, err := c.mc.GetBucketLifecycle(ctx, bucket)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchLifecycleConfiguration" {
+			return classifyError(err, fmt.Errorf("minio: get lifecycle of %q: %w", bucket, err))
+		}
+		config = lifecycle.NewConfiguration()
+	}
+	id := "expire-" + prefix
+	rules := config.Rules[:0]
+	for _, r := range config.Rules {
+		if r.ID != id {
+			rules = append(rules, r)
+		}
+	}
+	config.Rules = append(rules, lifecycle.Rule{
+		ID:         id,
+		Status:     "Enabled",
+		RuleFilter: lifecycle.Filter{Prefix: prefix + "/"},
+		Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(days)},
+	})
+	if err := c.mc.SetBucketLifecycle(ctx, bucket, config); err != nil {
+		return classifyError(err, fmt.Errorf("minio: set lifecycle of %q: %w", bucket, err))
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package minioadapter
+
+import (
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+func TestClient_BucketByPrefix(t *testing.T) {
+	c := &Client{bucketName: "fluxa-payloads", prefixBuckets: map[string]string{"tenants/acme": "acme-eu"}}
+	acme := domain.PayloadPlacement{Bucket: "acme-eu", Prefix: "tenants/acme"}
+	tests := []struct {
+		key, want string
+	}{
+		{domain.PayloadKey("abc"), "fluxa-payloads"},
+		{acme.Key("abc"), "acme-eu"},
+		{"tenants/acme-other/" + domain.PayloadKey("abc"), "fluxa-payloads"},
+	}
+	for _, tt := range tests {
+		if got := c.bucket(tt.key); got != tt.want {
+			t.Errorf("bucket(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
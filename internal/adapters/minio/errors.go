@@ -0,0 +1,33 @@
+package minioadapter
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/minio/minio-go/v7"
+)
+
+// classifyError maps a MinIO/S3 error onto the shared domain error types so callers
+// can act on the failure class instead of string-matching. cause is the raw SDK
+// error; wrapped is the annotated error carried inside the typed error. Anything
+// unrecognised is treated as retryable.
+func classifyError(cause, wrapped error) error {
+	switch minio.ToErrorResponse(cause).Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return domain.NewNotFoundError("object", wrapped)
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch", "AllAccessDisabled":
+		return domain.NewNonRetryableError("storage_access_denied", wrapped)
+	case "SlowDown", "RequestLimitExceeded", "ServiceUnavailable", "XMinioServerNotInitialized":
+		return domain.NewRetryableError("storage_throttled", wrapped)
+	case "RequestTimeout", "RequestTimeTooSkewed":
+		return domain.NewRetryableError("storage_timeout", wrapped)
+	}
+
+	var netErr net.Error
+	if errors.Is(cause, context.DeadlineExceeded) || (errors.As(cause, &netErr) && netErr.Timeout()) {
+		return domain.NewRetryableError("storage_timeout", wrapped)
+	}
+	return domain.NewRetryableError("storage_error", wrapped)
+}
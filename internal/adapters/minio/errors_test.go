@@ -0,0 +1,42 @@
+package minioadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/minio/minio-go/v7"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name  string
+		cause error
+		check func(error) bool
+	}{
+		{"no such key", minio.ErrorResponse{Code: "NoSuchKey"}, isType[*domain.NotFoundError]},
+		{"access denied", minio.ErrorResponse{Code: "AccessDenied"}, isType[*domain.NonRetryableError]},
+		{"slow down", minio.ErrorResponse{Code: "SlowDown"}, isType[*domain.RetryableError]},
+		{"deadline", context.DeadlineExceeded, isType[*domain.RetryableError]},
+		{"unknown", errors.New("boom"), isType[*domain.RetryableError]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := fmt.Errorf("minio: get %q: %w", "k", tt.cause)
+			got := classifyError(tt.cause, wrapped)
+			if !tt.check(got) {
+				t.Errorf("classifyError(%v) = %T", tt.cause, got)
+			}
+			if !errors.Is(got, tt.cause) {
+				t.Error("classified error does not unwrap to the cause")
+			}
+		})
+	}
+}
+
+func isType[T error](err error) bool {
+	var target T
+	return errors.As(err, &target)
+}
@@ -1,72 +1,48 @@
 package prommetrics
 
 import (
+	"github.com/fluxa/fluxa/internal/metricdef"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5}
-
-// Metrics implements ports.Metrics using Prometheus counters and histograms.
+// Metrics implements ports.Metrics using Prometheus counters, histograms, and gauges.
 type Metrics struct {
 	counters   map[string]*prometheus.CounterVec
 	histograms map[string]*prometheus.HistogramVec
+	gauges     map[string]*prometheus.GaugeVec
 }
 
-// NewMetrics creates and registers all Fluxa metrics for the given service.
+// NewMetrics creates and registers every metric in the metricdef catalog for the
+// given service. It panics on a catalog entry that fails metricdef validation.
 // Not idempotent: calls prometheus.MustRegister on the global default registry,
 // so a second call in the same process panics. Production services call it once
 // per binary; tests must share an instance per package.
 func NewMetrics(service string) *Metrics {
-	counters := map[string]*prometheus.CounterVec{
-		"events_ingested_total": prometheus.NewCounterVec(
-			prometheus.CounterOpts{Name: "events_ingested_total", Help: "Total events accepted by ingest"},
-			[]string{"service"},
-		),
-		"events_processed_total": prometheus.NewCounterVec(
-			prometheus.CounterOpts{Name: "events_processed_total", Help: "Total events completing the processor pipeline"},
-			[]string{"service", "status"},
-		),
-		"fraud_flags_total": prometheus.NewCounterVec(
-			prometheus.CounterOpts{Name: "fraud_flags_total", Help: "Total fraud rule fires"},
-			[]string{"rule"},
-		),
-		"query_total": prometheus.NewCounterVec(
-			prometheus.CounterOpts{Name: "query_total", Help: "Total query endpoint outcomes"},
-			[]string{"status"},
-		),
-		"alerts_consumed_total": prometheus.NewCounterVec(
-			prometheus.CounterOpts{Name: "alerts_consumed_total", Help: "Total alerts received by alert-consumer"},
-			[]string{},
-		),
-		"fraud_flags_grpc_total": prometheus.NewCounterVec(
-			prometheus.CounterOpts{Name: "fraud_flags_grpc_total", Help: "Total fraud rule fires via the synchronous gRPC surface"},
-			[]string{"rule"},
-		),
-	}
-
-	histograms := map[string]*prometheus.HistogramVec{
-		"ingest_latency_seconds": prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{Name: "ingest_latency_seconds", Help: "Ingest handler latency", Buckets: latencyBuckets},
-			[]string{"service"},
-		),
-		"process_latency_seconds": prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{Name: "process_latency_seconds", Help: "Per-message processor latency", Buckets: latencyBuckets},
-			[]string{"service"},
-		),
-		"fraud_eval_latency_seconds": prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{Name: "fraud_eval_latency_seconds", Help: "End-to-end gRPC fraud evaluation latency", Buckets: latencyBuckets},
-			[]string{"service"},
-		),
+	m := &Metrics{
+		counters:   map[string]*prometheus.CounterVec{},
+		histograms: map[string]*prometheus.HistogramVec{},
+		gauges:     map[string]*prometheus.GaugeVec{},
 	}
-
-	for _, c := range counters {
-		prometheus.MustRegister(c)
-	}
-	for _, h := range histograms {
-		prometheus.MustRegister(h)
+	for _, d := range metricdef.All() {
+		if err := d.Validate(); err != nil {
+			panic(err)
+		}
+		switch d.Kind {
+		case metricdef.Counter:
+			cv := prometheus.NewCounterVec(prometheus.CounterOpts{Name: d.Name, Help: d.Help}, d.Labels)
+			prometheus.MustRegister(cv)
+			m.counters[d.Name] = cv
+		case metricdef.Histogram:
+			hv := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: d.Name, Help: d.Help, Buckets: d.Buckets}, d.Labels)
+			prometheus.MustRegister(hv)
+			m.histograms[d.Name] = hv
+		case metricdef.Gauge:
+			gv := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: d.Name, Help: d.Help}, d.Labels)
+			prometheus.MustRegister(gv)
+			m.gauges[d.Name] = gv
+		}
 	}
-
-	return &Metrics{counters: counters, histograms: histograms}
+	return m
 }
 
 // IncCounter increments the named counter. Labels are flat key-value pairs.
@@ -87,6 +63,15 @@ func (m *Metrics) ObserveHistogram(name string, value float64, labels ...string)
 	hv.With(toPromLabels(labels)).Observe(value)
 }
 
+// SetGauge sets the named gauge to value.
+func (m *Metrics) SetGauge(name string, value float64, labels ...string) {
+	gv, ok := m.gauges[name]
+	if !ok {
+		return
+	}
+	gv.With(toPromLabels(labels)).Set(value)
+}
+
 // toPromLabels converts a flat []string of key,value pairs to prometheus.Labels.
 func toPromLabels(pairs []string) prometheus.Labels {
 	labels := prometheus.Labels{}
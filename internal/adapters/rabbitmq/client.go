@@ -3,11 +3,14 @@ package rabbitmq
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/ports"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-// Client wraps an AMQP connection and implements both ports.Publisher and ports.Consumer.
+// Client wraps an AMQP connection and implements ports.BatchPublisher and ports.Consumer.
 // It declares all required exchanges and queues on construction.
 type Client struct {
 	conn    *amqp.Connection
@@ -17,10 +20,23 @@ type Client struct {
 // NewClient dials RabbitMQ, opens a channel, and declares the topology:
 //   - exchange "events" (direct, durable) — ingest publishes here
 //   - exchange "alerts" (fanout, durable)  — processor publishes fraud alerts here
+//   - exchange "groups" (fanout, durable)  — processor publishes group completions
+//     here; their consumers bind their own queues
 //   - queue "events" bound to exchange "events" with routing key "events"
+//   - queue "events.priority" bound to exchange "events" with routing key
+//     "events.priority" — high-priority events, consumed by dedicated workers
 //   - queue "alerts" bound to exchange "alerts"
 func NewClient(amqpURL string) (*Client, error) {
-	conn, err := amqp.Dial(amqpURL)
+	return NewClientWithConfig(amqpURL, amqp.Config{
+		Heartbeat: 10 * time.Second,
+		Locale:    "en_US",
+	})
+}
+
+// NewClientWithConfig is NewClient with explicit connection tuning (heartbeat,
+// dial timeout, client properties such as connection_name).
+func NewClientWithConfig(amqpURL string, amqpCfg amqp.Config) (*Client, error) {
+	conn, err := amqp.DialConfig(amqpURL, amqpCfg)
 	if err != nil {
 		return nil, fmt.Errorf("rabbitmq: failed to dial %s: %w", amqpURL, err)
 	}
@@ -40,12 +56,31 @@ func NewClient(amqpURL string) (*Client, error) {
 	return &Client{conn: conn, channel: ch}, nil
 }
 
+// queues are the durable queues declareTopology creates, with their bindings.
+var queues = []struct {
+	name, exchange, key string
+}{
+	{"events", "events", "events"},
+	{"events.priority", "events", "events.priority"},
+	{"alerts", "alerts", ""},
+}
+
+// QueueNames returns the names of the queues in Fluxa's topology.
+func QueueNames() []string {
+	names := make([]string, len(queues))
+	for i, q := range queues {
+		names[i] = q.name
+	}
+	return names
+}
+
 func declareTopology(ch *amqp.Channel) error {
 	exchanges := []struct {
 		name, kind string
 	}{
 		{"events", "direct"},
 		{"alerts", "fanout"},
+		{"groups", "fanout"},
 	}
 	for _, e := range exchanges {
 		if err := ch.ExchangeDeclare(e.name, e.kind, true, false, false, false, nil); err != nil {
@@ -53,12 +88,6 @@ func declareTopology(ch *amqp.Channel) error {
 		}
 	}
 
-	queues := []struct {
-		name, exchange, key string
-	}{
-		{"events", "events", "events"},
-		{"alerts", "alerts", ""},
-	}
 	for _, q := range queues {
 		if _, err := ch.QueueDeclare(q.name, true, false, false, false, nil); err != nil {
 			return fmt.Errorf("rabbitmq: declare queue %q: %w", q.name, err)
@@ -71,6 +100,7 @@ func declareTopology(ch *amqp.Channel) error {
 }
 
 // Publish sends body to the given exchange with the given routing key.
+// Errors are classified into domain error types (see classifyError).
 func (c *Client) Publish(ctx context.Context, exchange, routingKey string, body []byte) error {
 	err := c.channel.PublishWithContext(ctx, exchange, routingKey, false, false, amqp.Publishing{
 		ContentType:  "application/json",
@@ -78,7 +108,32 @@ func (c *Client) Publish(ctx context.Context, exchange, routingKey string, body
 		Body:         body,
 	})
 	if err != nil {
-		return fmt.Errorf("rabbitmq: publish to %q: %w", exchange, err)
+		return classifyError(err, fmt.Errorf("rabbitmq: publish to %q: %w", exchange, err))
+	}
+	return nil
+}
+
+// PublishBatch sends up to ports.MaxPublishBatch bodies to exchange back to back,
+// stopping at the first error. AMQP publishes are asynchronous, so this saves no
+// round trips over Publish; it exists so batching callers need no special case.
+func (c *Client) PublishBatch(ctx context.Context, exchange, routingKey string, bodies [][]byte) error {
+	if len(bodies) > ports.MaxPublishBatch {
+		return domain.NewNonRetryableError("publish_batch_too_large",
+			fmt.Errorf("rabbitmq: %d messages in one batch, max %d", len(bodies), ports.MaxPublishBatch))
+	}
+	for _, body := range bodies {
+		if err := c.Publish(ctx, exchange, routingKey, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPrefetch caps the unacked deliveries the broker pushes to each consumer
+// started on c afterwards; 0 (the broker default) leaves them unbounded.
+func (c *Client) SetPrefetch(n int) error {
+	if err := c.channel.Qos(n, 0, false); err != nil {
+		return classifyError(err, fmt.Errorf("rabbitmq: set prefetch %d: %w", n, err))
 	}
 	return nil
 }
@@ -87,7 +142,7 @@ func (c *Client) Publish(ctx context.Context, exchange, routingKey string, body
 func (c *Client) Consume(ctx context.Context, queue string) (<-chan Delivery, error) {
 	msgs, err := c.channel.Consume(queue, "", false, false, false, false, nil)
 	if err != nil {
-		return nil, fmt.Errorf("rabbitmq: consume %q: %w", queue, err)
+		return nil, classifyError(err, fmt.Errorf("rabbitmq: consume %q: %w", queue, err))
 	}
 
 	out := make(chan Delivery)
@@ -108,6 +163,31 @@ func (c *Client) Consume(ctx context.Context, queue string) (<-chan Delivery, er
 	return out, nil
 }
 
+// Ping reports whether the connection and channel are still open. The heartbeat
+// closes them when the broker becomes unreachable, so this needs no round trip.
+func (c *Client) Ping(ctx context.Context) error {
+	if c.conn.IsClosed() || c.channel.IsClosed() {
+		return fmt.Errorf("rabbitmq: connection closed")
+	}
+	return nil
+}
+
+// QueueStats reports a queue's ready-message and consumer counts. It uses a
+// short-lived channel of its own, since a failed passive declare (e.g. an unknown
+// queue) closes the channel it runs on.
+func (c *Client) QueueStats(ctx context.Context, queue string) (messages, consumers int, err error) {
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return 0, 0, classifyError(err, fmt.Errorf("rabbitmq: open channel: %w", err))
+	}
+	defer ch.Close()
+	q, err := ch.QueueDeclarePassive(queue, true, false, false, false, nil)
+	if err != nil {
+		return 0, 0, classifyError(err, fmt.Errorf("rabbitmq: inspect queue %q: %w", queue, err))
+	}
+	return q.Messages, q.Consumers, nil
+}
+
 // Close shuts down the channel and connection.
 func (c *Client) Close() error {
 	if err := c.channel.Close(); err != nil {
@@ -124,6 +204,7 @@ type delivery struct {
 func (d *delivery) Body() []byte            { return d.d.Body }
 func (d *delivery) Ack() error              { return d.d.Ack(false) }
 func (d *delivery) Nack(requeue bool) error { return d.d.Nack(false, requeue) }
+func (d *delivery) Redelivered() bool       { return d.d.Redelivered }
 
 // Delivery is the interface that wraps a single received AMQP message.
 // Exported here so callers can use it without importing ports directly.
@@ -131,4 +212,5 @@ type Delivery interface {
 	Body() []byte
 	Ack() error
 	Nack(requeue bool) error
+	Redelivered() bool
 }
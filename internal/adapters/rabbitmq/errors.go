@@ -0,0 +1,34 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// classifyError maps an AMQP error onto the shared domain error types. cause is
+// the raw library error; wrapped is the annotated error carried inside the typed
+// error. Closed connections and broker-side hiccups are retryable (the caller
+// reconnects or the broker redelivers); topology and permission errors are not.
+func classifyError(cause, wrapped error) error {
+	var amqpErr *amqp.Error
+	if errors.As(cause, &amqpErr) {
+		switch amqpErr.Code {
+		case amqp.NotFound:
+			return domain.NewNotFoundError("exchange_or_queue", wrapped)
+		case amqp.AccessRefused, amqp.NotAllowed:
+			return domain.NewNonRetryableError("queue_access_denied", wrapped)
+		case amqp.ContentTooLarge, amqp.PreconditionFailed, amqp.NotImplemented, amqp.SyntaxError, amqp.CommandInvalid:
+			return domain.NewNonRetryableError("queue_rejected", wrapped)
+		case amqp.ResourceError, amqp.ResourceLocked, amqp.NoConsumers:
+			return domain.NewRetryableError("queue_throttled", wrapped)
+		}
+		return domain.NewRetryableError("queue_unavailable", wrapped)
+	}
+	if errors.Is(cause, context.DeadlineExceeded) {
+		return domain.NewRetryableError("queue_timeout", wrapped)
+	}
+	return domain.NewRetryableError("queue_error", wrapped)
+}
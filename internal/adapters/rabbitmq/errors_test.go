@@ -0,0 +1,36 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name  string
+		cause error
+		check func(error) bool
+	}{
+		{"missing exchange", &amqp.Error{Code: amqp.NotFound}, isType[*domain.NotFoundError]},
+		{"access refused", &amqp.Error{Code: amqp.AccessRefused}, isType[*domain.NonRetryableError]},
+		{"closed", amqp.ErrClosed, isType[*domain.RetryableError]},
+		{"unknown", errors.New("boom"), isType[*domain.RetryableError]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError(tt.cause, fmt.Errorf("rabbitmq: publish: %w", tt.cause))
+			if !tt.check(got) {
+				t.Errorf("classifyError(%v) = %T", tt.cause, got)
+			}
+		})
+	}
+}
+
+func isType[T error](err error) bool {
+	var target T
+	return errors.As(err, &target)
+}
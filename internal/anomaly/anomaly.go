@@ -0,0 +1,93 @@
+// Package anomaly scores how far an event's amount deviates from the rolling
+// per-user and per-merchant amount distributions (z-score). It is advisory:
+// scores are recorded on the event and in metrics, never used to reject it.
+package anomaly
+
+import (
+	"math"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// Dimensions an event is scored against.
+const (
+	DimensionUser     = "user"
+	DimensionMerchant = "merchant"
+)
+
+// StatsQuerier is the narrow DB surface the detector needs. *db.Client satisfies it.
+type StatsQuerier interface {
+	UserAmountDistributionAsOf(userID string, asOf time.Time, windowSeconds int) (count int, mean, stddev float64, err error)
+	MerchantAmountDistributionAsOf(merchant string, asOf time.Time, windowSeconds int) (count int, mean, stddev float64, err error)
+}
+
+// Stats is the rolling distribution for one dimension plus the event's z-score against it.
+// Scored is false when there were fewer than MinSamples prior events or no spread.
+type Stats struct {
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	ZScore float64 `json:"z"`
+	Scored bool    `json:"-"`
+}
+
+// Result holds per-dimension stats. Score is the largest |z| across scored dimensions.
+type Result struct {
+	User      Stats
+	Merchant  Stats
+	Score     float64
+	Anomalous []string // dimensions whose |z| reached the threshold
+}
+
+// Detector computes z-scores over a trailing window ending at the event timestamp.
+type Detector struct {
+	Querier       StatsQuerier
+	Threshold     float64 // |z| at or above which a dimension is anomalous
+	MinSamples    int     // prior events required before a dimension is scored
+	WindowSeconds int
+}
+
+// Evaluate scores event against both dimensions. A query error aborts scoring.
+func (d *Detector) Evaluate(event *domain.Event) (Result, error) {
+	var res Result
+
+	n, mean, sd, err := d.Querier.UserAmountDistributionAsOf(event.UserID, event.Timestamp, d.WindowSeconds)
+	if err != nil {
+		return res, err
+	}
+	res.User = d.score(event.Amount, n, mean, sd)
+
+	n, mean, sd, err = d.Querier.MerchantAmountDistributionAsOf(event.Merchant, event.Timestamp, d.WindowSeconds)
+	if err != nil {
+		return res, err
+	}
+	res.Merchant = d.score(event.Amount, n, mean, sd)
+
+	for _, dim := range []struct {
+		name  string
+		stats Stats
+	}{{DimensionUser, res.User}, {DimensionMerchant, res.Merchant}} {
+		if !dim.stats.Scored {
+			continue
+		}
+		z := math.Abs(dim.stats.ZScore)
+		if z > res.Score {
+			res.Score = z
+		}
+		if d.Threshold > 0 && z >= d.Threshold {
+			res.Anomalous = append(res.Anomalous, dim.name)
+		}
+	}
+	return res, nil
+}
+
+func (d *Detector) score(amount float64, count int, mean, stddev float64) Stats {
+	s := Stats{Count: count, Mean: mean, StdDev: stddev}
+	if count < d.MinSamples || count < 2 || stddev == 0 {
+		return s
+	}
+	s.ZScore = (amount - mean) / stddev
+	s.Scored = true
+	return s
+}
@@ -0,0 +1,85 @@
+package anomaly
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+type dist struct {
+	count        int
+	mean, stddev float64
+}
+
+type fakeQuerier struct {
+	user, merchant dist
+	err            error
+}
+
+func (f fakeQuerier) UserAmountDistributionAsOf(_ string, _ time.Time, _ int) (int, float64, float64, error) {
+	return f.user.count, f.user.mean, f.user.stddev, f.err
+}
+
+func (f fakeQuerier) MerchantAmountDistributionAsOf(_ string, _ time.Time, _ int) (int, float64, float64, error) {
+	return f.merchant.count, f.merchant.mean, f.merchant.stddev, f.err
+}
+
+func event(amount float64) *domain.Event {
+	return &domain.Event{EventID: "e1", UserID: "u1", Merchant: "m1", Amount: amount, Timestamp: time.Now()}
+}
+
+func TestEvaluate_FlagsDeviation(t *testing.T) {
+	d := &Detector{
+		Querier:    fakeQuerier{user: dist{50, 100, 10}, merchant: dist{500, 120, 40}},
+		Threshold:  3,
+		MinSamples: 10,
+	}
+	res, err := d.Evaluate(event(140))
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if res.User.ZScore != 4 || res.Merchant.ZScore != 0.5 {
+		t.Errorf("z-scores = %v / %v, want 4 / 0.5", res.User.ZScore, res.Merchant.ZScore)
+	}
+	if res.Score != 4 {
+		t.Errorf("Score = %v, want 4", res.Score)
+	}
+	if len(res.Anomalous) != 1 || res.Anomalous[0] != DimensionUser {
+		t.Errorf("Anomalous = %v, want [user]", res.Anomalous)
+	}
+}
+
+func TestEvaluate_NegativeDeviationCounts(t *testing.T) {
+	d := &Detector{Querier: fakeQuerier{user: dist{50, 100, 10}, merchant: dist{50, 100, 10}}, Threshold: 3, MinSamples: 10}
+	res, _ := d.Evaluate(event(60))
+	if res.Score != 4 || len(res.Anomalous) != 2 {
+		t.Errorf("Score = %v Anomalous = %v, want 4 and both dimensions", res.Score, res.Anomalous)
+	}
+}
+
+func TestEvaluate_InsufficientHistoryNotScored(t *testing.T) {
+	d := &Detector{
+		Querier:    fakeQuerier{user: dist{3, 100, 10}, merchant: dist{50, 100, 0}},
+		Threshold:  3,
+		MinSamples: 10,
+	}
+	res, err := d.Evaluate(event(10000))
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if res.User.Scored || res.Merchant.Scored {
+		t.Errorf("expected no scored dimension (few samples / zero spread), got %+v", res)
+	}
+	if res.Score != 0 || len(res.Anomalous) != 0 {
+		t.Errorf("unscored dimensions must not be anomalous: %+v", res)
+	}
+}
+
+func TestEvaluate_QueryError(t *testing.T) {
+	d := &Detector{Querier: fakeQuerier{err: errors.New("db down")}, Threshold: 3}
+	if _, err := d.Evaluate(event(1)); err == nil {
+		t.Error("expected error")
+	}
+}
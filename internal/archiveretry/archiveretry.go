@@ -0,0 +1,147 @@
+// Package archiveretry drains archive_outbox: the sampled payloads ingest failed
+// to archive (PAYLOAD_ARCHIVE_SAMPLE_PERCENT). The processor queues one in the
+// statement that persists its event, so the archival is eventually done rather
+// than dropped: a Drainer stores each due payload under its producer's
+// content-addressed key and points the event at it, backing off a failed one and
+// retrying it until it succeeds.
+package archiveretry
+
+import (
+	"context"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// Defaults for a zero Drainer.Interval and Drainer.BatchSize.
+const (
+	DefaultInterval  = time.Minute
+	DefaultBatchSize = 100
+)
+
+// MaxBackoff caps how long a failing archival waits between attempts.
+const MaxBackoff = time.Hour
+
+// lease is how long a claimed archival is hidden from other processors; one left
+// unsettled (the processor stopped) is due again after it.
+const lease = 5 * time.Minute
+
+// Store holds the queue; *db.Client implements it.
+type Store interface {
+	ClaimArchives(limit int, lease time.Duration) ([]domain.ArchiveTask, error)
+	CompleteArchive(eventID, s3Key string) error
+	RetryArchive(eventID string, next time.Time, reason string) error
+}
+
+// Drainer archives the due payloads every Interval, BatchSize at a time.
+// Placements are the per-producer storage placements
+// (PAYLOAD_STORAGE_OVERRIDES), so a payload lands where ingest would have put it.
+type Drainer struct {
+	Store      Store
+	Storage    ports.Storage
+	Placements map[string]domain.PayloadPlacement
+	Interval   time.Duration
+	BatchSize  int
+	Metrics    ports.Metrics
+	Logger     *logging.Logger
+}
+
+// Run drains immediately and then every Interval until ctx is done.
+func (d *Drainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval())
+	defer ticker.Stop()
+	for {
+		d.Drain(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain archives due payloads until none are left or ctx is done, returning how
+// many it stored. Failures are logged and rescheduled.
+func (d *Drainer) Drain(ctx context.Context) int {
+	size := d.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	archived := 0
+	for ctx.Err() == nil {
+		tasks, err := d.Store.ClaimArchives(size, lease)
+		if err != nil {
+			d.Logger.Warn("Failed to claim queued archivals", map[string]interface{}{"error": err.Error()})
+			return archived
+		}
+		for _, t := range tasks {
+			if d.archive(ctx, t) {
+				archived++
+			}
+		}
+		if len(tasks) < size {
+			break
+		}
+	}
+	return archived
+}
+
+// archive stores t's payload and settles it, or reschedules it with backoff.
+func (d *Drainer) archive(ctx context.Context, t domain.ArchiveTask) bool {
+	key := d.Placements[t.ProducerKey].Key(t.PayloadSHA256)
+	err := d.put(ctx, key, t.Payload)
+	if err == nil {
+		err = d.Store.CompleteArchive(t.EventID, key)
+	}
+	if err == nil {
+		d.Metrics.IncCounter(metricdef.PayloadsArchivedTotal, "result", "retried")
+		return true
+	}
+
+	d.Metrics.IncCounter(metricdef.PayloadsArchivedTotal, "result", "retry_failed")
+	next := time.Now().Add(d.Backoff(t.Attempts + 1))
+	d.Logger.Warn("Queued archival failed; rescheduled", map[string]interface{}{
+		"event_id": t.EventID,
+		"attempts": t.Attempts + 1,
+		"next_at":  next.UTC().Format(time.RFC3339),
+		"error":    err.Error(),
+	})
+	if rerr := d.Store.RetryArchive(t.EventID, next, err.Error()); rerr != nil {
+		// The claim's lease makes it due again anyway.
+		d.Logger.Warn("Failed to reschedule queued archival", map[string]interface{}{
+			"event_id": t.EventID,
+			"error":    rerr.Error(),
+		})
+	}
+	return false
+}
+
+// put stores payload at key unless an identical object (the key is its hash) is
+// already there, as ingest does.
+func (d *Drainer) put(ctx context.Context, key string, payload []byte) error {
+	exists, err := d.Storage.Exists(ctx, key)
+	if err != nil || exists {
+		return err
+	}
+	return d.Storage.Put(ctx, key, payload)
+}
+
+// Backoff is the wait before retrying an archival that has failed attempts
+// times: Interval doubled per failure, capped at MaxBackoff.
+func (d *Drainer) Backoff(attempts int) time.Duration {
+	wait := d.interval()
+	for i := 1; i < attempts && wait < MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, MaxBackoff)
+}
+
+func (d *Drainer) interval() time.Duration {
+	if d.Interval <= 0 {
+		return DefaultInterval
+	}
+	return d.Interval
+}
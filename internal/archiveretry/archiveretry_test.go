@@ -0,0 +1,117 @@
+package archiveretry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+// fakeStore is an archive_outbox whose claims ignore leases and due times.
+type fakeStore struct {
+	queued    []domain.ArchiveTask
+	completed map[string]string
+	retried   map[string]string
+}
+
+func newFakeStore(tasks ...domain.ArchiveTask) *fakeStore {
+	return &fakeStore{queued: tasks, completed: map[string]string{}, retried: map[string]string{}}
+}
+
+func (s *fakeStore) ClaimArchives(limit int, lease time.Duration) ([]domain.ArchiveTask, error) {
+	var out []domain.ArchiveTask
+	for _, t := range s.queued {
+		if _, done := s.completed[t.EventID]; done {
+			continue
+		}
+		if _, failed := s.retried[t.EventID]; failed {
+			continue
+		}
+		if len(out) < limit {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) CompleteArchive(eventID, s3Key string) error {
+	s.completed[eventID] = s3Key
+	return nil
+}
+
+func (s *fakeStore) RetryArchive(eventID string, next time.Time, reason string) error {
+	s.retried[eventID] = reason
+	return nil
+}
+
+func task(eventID, producerKey, sha string) domain.ArchiveTask {
+	return domain.ArchiveTask{EventID: eventID, ProducerKey: producerKey, PayloadSHA256: sha, Payload: []byte(`{"event_id":"` + eventID + `"}`)}
+}
+
+func TestDrainer_ArchivesIntoPlacements(t *testing.T) {
+	store := newFakeStore(task("evt-1", "", "aa"), task("evt-2", "eu", "bb"), task("evt-3", "", "cc"))
+	storage, m := fluxatest.NewStorage(), fluxatest.NewMetrics()
+	d := &Drainer{
+		Store:      store,
+		Storage:    storage,
+		Placements: map[string]domain.PayloadPlacement{"eu": {Bucket: "eu-bucket", Prefix: "eu"}},
+		BatchSize:  2,
+		Metrics:    m,
+		Logger:     logging.NewLogger("test", "test"),
+	}
+
+	if n := d.Drain(context.Background()); n != 3 {
+		t.Fatalf("Drain = %d, want 3", n)
+	}
+	if got := store.completed["evt-2"]; got != "eu/"+domain.PayloadKey("bb") {
+		t.Errorf("evt-2 archived at %q, want its producer's placement", got)
+	}
+	if ok, _ := storage.Exists(context.Background(), domain.PayloadKey("aa")); !ok {
+		t.Error("evt-1's payload was not stored")
+	}
+	if got := m.Counter(metricdef.PayloadsArchivedTotal, "retried"); got != 3 {
+		t.Errorf("payloads_archived_total{retried} = %d, want 3", got)
+	}
+}
+
+func TestDrainer_ReschedulesFailures(t *testing.T) {
+	store := newFakeStore(task("evt-1", "", "aa"))
+	storage, m := fluxatest.NewStorage(), fluxatest.NewMetrics()
+	storage.PutErr = errors.New("minio down")
+	d := &Drainer{Store: store, Storage: storage, Metrics: m, Logger: logging.NewLogger("test", "test")}
+
+	if n := d.Drain(context.Background()); n != 0 {
+		t.Fatalf("Drain = %d, want 0", n)
+	}
+	if got := store.retried["evt-1"]; got != "minio down" {
+		t.Errorf("evt-1 rescheduled with %q, want the put error", got)
+	}
+	if _, ok := store.completed["evt-1"]; ok {
+		t.Error("a failed archival was completed")
+	}
+	if got := m.Counter(metricdef.PayloadsArchivedTotal, "retry_failed"); got != 1 {
+		t.Errorf("payloads_archived_total{retry_failed} = %d, want 1", got)
+	}
+}
+
+func TestDrainer_Backoff(t *testing.T) {
+	d := &Drainer{Interval: time.Minute}
+	for _, tc := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{20, MaxBackoff},
+	} {
+		if got := d.Backoff(tc.attempts); got != tc.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tc.attempts, got, tc.want)
+		}
+	}
+}
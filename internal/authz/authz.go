@@ -0,0 +1,150 @@
+// Package authz resolves callers of the query and admin APIs to a role, from the
+// hash of their X-API-Key or from the claims of a signed bearer token, for the
+// query service to enforce per endpoint.
+//
+// Roles are ordered: an admin may do anything a tenant-admin may, and a
+// tenant-admin anything a reader may. A tenant-admin administers only the events
+// of its tenant, the producer (hashed API key) that submitted them.
+//
+// The pii scope is granted on top of any role, and lets the caller read user IDs
+// and metadata unmasked under QUERY_MASK_PII.
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// Role is what a caller may do.
+type Role string
+
+const (
+	RoleReader      Role = "reader"       // read events, groups, merchants, the fraud feed
+	RoleTenantAdmin Role = "tenant-admin" // admin actions on its own tenant's events
+	RoleAdmin       Role = "admin"        // every admin endpoint
+)
+
+// ScopePII is the scope that reads PII unmasked: the "+pii" suffix of a
+// QUERY_API_ROLES value, or an entry of a bearer token's space-separated scope
+// claim.
+const ScopePII = "pii"
+
+// rank orders roles; unknown roles rank below reader.
+var rank = map[Role]int{RoleReader: 1, RoleTenantAdmin: 2, RoleAdmin: 3}
+
+// Resolution failures. Callers answer all of them with 401; the distinction is
+// for metrics and logs.
+var (
+	ErrNoCredentials = errors.New("authz: no API key or bearer token")
+	ErrUnknownKey    = errors.New("authz: API key has no role")
+	ErrInvalidToken  = errors.New("authz: invalid bearer token")
+)
+
+// Principal is a resolved caller.
+type Principal struct {
+	Subject string // hashed API key, or the token's sub claim
+	Role    Role
+	Tenant  string // producer key a tenant-admin administers
+	PII     bool   // holds ScopePII
+}
+
+// Has reports whether p's role is at least role.
+func (p Principal) Has(role Role) bool {
+	return rank[p.Role] > 0 && rank[p.Role] >= rank[role]
+}
+
+// CanAdminTenant reports whether p may administer events submitted by
+// producerKey: admins always, tenant-admins for their own tenant.
+func (p Principal) CanAdminTenant(producerKey string) bool {
+	switch p.Role {
+	case RoleAdmin:
+		return true
+	case RoleTenantAdmin:
+		return p.Tenant != "" && p.Tenant == producerKey
+	}
+	return false
+}
+
+// Grant is the role configured for one API key.
+type Grant struct {
+	Role   Role
+	Tenant string
+	PII    bool
+}
+
+// ParseGrant parses the QUERY_API_ROLES value for keyHash: "reader", "admin",
+// "tenant-admin" (of the key's own tenant), or "tenant-admin:<producer key>",
+// each optionally followed by "+pii" for ScopePII.
+func ParseGrant(keyHash, value string) (Grant, error) {
+	value, pii := strings.CutSuffix(strings.TrimSpace(value), "+"+ScopePII)
+	role, tenant, scoped := strings.Cut(value, ":")
+	g := Grant{Role: Role(role), PII: pii}
+	switch g.Role {
+	case RoleReader, RoleAdmin:
+		if scoped {
+			return Grant{}, fmt.Errorf("role %s takes no tenant", role)
+		}
+	case RoleTenantAdmin:
+		g.Tenant = keyHash
+		if scoped {
+			g.Tenant = tenant
+		}
+		if g.Tenant == "" {
+			return Grant{}, fmt.Errorf("tenant-admin needs a tenant")
+		}
+	default:
+		return Grant{}, fmt.Errorf("unknown role %q (want reader, tenant-admin or admin)", role)
+	}
+	return g, nil
+}
+
+// Resolver resolves requests to principals.
+type Resolver struct {
+	grants    map[string]Grant
+	jwtSecret []byte
+	now       func() time.Time
+}
+
+// NewResolver returns a Resolver for roles (hashed API key => grant, as
+// QUERY_API_ROLES) and, when jwtSecret is set, HS256 bearer tokens signed with it.
+func NewResolver(roles map[string]string, jwtSecret string) (*Resolver, error) {
+	r := &Resolver{grants: make(map[string]Grant, len(roles)), now: time.Now}
+	for keyHash, value := range roles {
+		g, err := ParseGrant(keyHash, value)
+		if err != nil {
+			return nil, fmt.Errorf("role of %s: %w", keyHash, err)
+		}
+		r.grants[keyHash] = g
+	}
+	if jwtSecret != "" {
+		r.jwtSecret = []byte(jwtSecret)
+	}
+	return r, nil
+}
+
+// Resolve identifies req's caller. A bearer token is preferred over X-API-Key,
+// and is only accepted when the Resolver has a secret.
+func (r *Resolver) Resolve(req *http.Request) (Principal, error) {
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || r.jwtSecret == nil {
+			return Principal{}, ErrInvalidToken
+		}
+		return r.verifyToken(strings.TrimSpace(token))
+	}
+	key := strings.TrimSpace(req.Header.Get("X-API-Key"))
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	keyHash := domain.HashAPIKey(key)
+	g, ok := r.grants[keyHash]
+	if !ok {
+		return Principal{}, ErrUnknownKey
+	}
+	return Principal{Subject: keyHash, Role: g.Role, Tenant: g.Tenant, PII: g.PII}, nil
+}
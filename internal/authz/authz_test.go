@@ -0,0 +1,138 @@
+package authz
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// signToken returns an HS256 token for c.
+func signToken(secret string, c claims) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	body, _ := json.Marshal(c)
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseGrant(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Grant
+		wantErr bool
+	}{
+		{"reader", Grant{Role: RoleReader}, false},
+		{" admin ", Grant{Role: RoleAdmin}, false},
+		{"tenant-admin", Grant{Role: RoleTenantAdmin, Tenant: "k"}, false},
+		{"tenant-admin:other", Grant{Role: RoleTenantAdmin, Tenant: "other"}, false},
+		{"reader+pii", Grant{Role: RoleReader, PII: true}, false},
+		{"tenant-admin:other+pii", Grant{Role: RoleTenantAdmin, Tenant: "other", PII: true}, false},
+		{"+pii", Grant{}, true},
+		{"tenant-admin:", Grant{}, true},
+		{"admin:other", Grant{}, true},
+		{"owner", Grant{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGrant("k", tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseGrant(%q) = %+v, %v; want %+v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPrincipal_Roles(t *testing.T) {
+	reader := Principal{Role: RoleReader}
+	tenant := Principal{Role: RoleTenantAdmin, Tenant: "producer-a"}
+	admin := Principal{Role: RoleAdmin}
+
+	if !reader.Has(RoleReader) || reader.Has(RoleTenantAdmin) || !tenant.Has(RoleReader) || tenant.Has(RoleAdmin) || !admin.Has(RoleTenantAdmin) {
+		t.Error("Has does not order reader < tenant-admin < admin")
+	}
+	if (Principal{}).Has(RoleReader) {
+		t.Error("the zero Principal has a role")
+	}
+	if reader.CanAdminTenant("producer-a") || !tenant.CanAdminTenant("producer-a") || tenant.CanAdminTenant("producer-b") || !admin.CanAdminTenant("producer-b") {
+		t.Error("CanAdminTenant: want admins everywhere, tenant-admins on their own tenant only")
+	}
+}
+
+func TestResolver_APIKey(t *testing.T) {
+	r, err := NewResolver(map[string]string{domain.HashAPIKey("ops-key"): "admin"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/failures", nil)
+	if _, err := r.Resolve(req); err != ErrNoCredentials {
+		t.Errorf("Resolve(no key) = %v, want ErrNoCredentials", err)
+	}
+	req.Header.Set("X-API-Key", "someone-else")
+	if _, err := r.Resolve(req); err != ErrUnknownKey {
+		t.Errorf("Resolve(unknown key) = %v, want ErrUnknownKey", err)
+	}
+	req.Header.Set("X-API-Key", "ops-key")
+	if p, err := r.Resolve(req); err != nil || p.Role != RoleAdmin || p.Subject != domain.HashAPIKey("ops-key") {
+		t.Errorf("Resolve(ops key) = %+v, %v; want admin", p, err)
+	}
+	req.Header.Set("Authorization", "Bearer x.y.z")
+	if _, err := r.Resolve(req); err != ErrInvalidToken {
+		t.Errorf("Resolve(token without a secret) = %v, want ErrInvalidToken", err)
+	}
+
+	if _, err := NewResolver(map[string]string{"k": "owner"}, ""); err == nil {
+		t.Error("NewResolver accepted an unknown role")
+	}
+}
+
+func TestResolver_Token(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	r, err := NewResolver(nil, "jwt-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time { return now }
+	exp := now.Add(time.Hour).Unix()
+
+	tests := []struct {
+		name  string
+		token string
+		want  Principal
+		ok    bool
+	}{
+		{"tenant admin", signToken("jwt-secret", claims{Subject: "alice", Role: RoleTenantAdmin, Tenant: "producer-a", ExpiresAt: exp}),
+			Principal{Subject: "alice", Role: RoleTenantAdmin, Tenant: "producer-a"}, true},
+		{"pii scope", signToken("jwt-secret", claims{Subject: "carol", Role: RoleReader, Scope: "events:read pii", ExpiresAt: exp}),
+			Principal{Subject: "carol", Role: RoleReader, PII: true}, true},
+		{"reader ignores tenant", signToken("jwt-secret", claims{Subject: "bob", Role: RoleReader, Tenant: "producer-a", ExpiresAt: exp}),
+			Principal{Subject: "bob", Role: RoleReader}, true},
+		{"wrong secret", signToken("other", claims{Role: RoleAdmin, ExpiresAt: exp}), Principal{}, false},
+		{"expired", signToken("jwt-secret", claims{Role: RoleAdmin, ExpiresAt: now.Unix()}), Principal{}, false},
+		{"no expiry", signToken("jwt-secret", claims{Role: RoleAdmin}), Principal{}, false},
+		{"not yet valid", signToken("jwt-secret", claims{Role: RoleAdmin, ExpiresAt: exp, NotBefore: exp}), Principal{}, false},
+		{"unknown role", signToken("jwt-secret", claims{Role: "owner", ExpiresAt: exp}), Principal{}, false},
+		{"tenant admin without tenant", signToken("jwt-secret", claims{Role: RoleTenantAdmin, ExpiresAt: exp}), Principal{}, false},
+		{"unsigned", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(`{"role":"admin","exp":9999999999}`)) + ".", Principal{}, false},
+		{"malformed", "not-a-token", Principal{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/events/evt-1", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			p, err := r.Resolve(req)
+			if tt.ok && (err != nil || p != tt.want) {
+				t.Errorf("Resolve = %+v, %v; want %+v", p, err, tt.want)
+			}
+			if !tt.ok && err != ErrInvalidToken {
+				t.Errorf("Resolve = %+v, %v; want ErrInvalidToken", p, err)
+			}
+		})
+	}
+}
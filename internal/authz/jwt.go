@@ -0,0 +1,74 @@
+package authz
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// claims are the bearer token claims Resolve reads. exp is required; role must be
+// one of the Roles, and a tenant-admin token must name its tenant. scope is
+// space-separated, as in OAuth 2.0; only ScopePII means anything here.
+type claims struct {
+	Subject   string `json:"sub"`
+	Role      Role   `json:"role"`
+	Tenant    string `json:"tenant"`
+	Scope     string `json:"scope"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+}
+
+// verifyToken checks a compact JWS signed with HS256 and returns its principal.
+// Other algorithms, "none" included, are rejected.
+func (r *Resolver) verifyToken(token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return Principal{}, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, r.jwtSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return Principal{}, ErrInvalidToken
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	now := r.now().Unix()
+	if c.ExpiresAt == 0 || now >= c.ExpiresAt || (c.NotBefore != 0 && now < c.NotBefore) {
+		return Principal{}, ErrInvalidToken
+	}
+	if rank[c.Role] == 0 || (c.Role == RoleTenantAdmin && c.Tenant == "") {
+		return Principal{}, ErrInvalidToken
+	}
+	p := Principal{Subject: c.Subject, Role: c.Role}
+	for _, scope := range strings.Fields(c.Scope) {
+		p.PII = p.PII || scope == ScopePII
+	}
+	if c.Role == RoleTenantAdmin {
+		p.Tenant = c.Tenant
+	}
+	return p, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token into v.
+func decodeSegment(seg string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,186 @@
+// Package bulkretry runs admin bulk retries (query service POST /admin/retries).
+// A Runner pages through the idempotency keys that failed permanently with one
+// reason in a time window and publishes their kept queue messages to the events
+// exchange again, BatchSize at a time and at most Rate a second, recording
+// progress on the retry job row as it goes, which GET /admin/retries/{id} reports.
+//
+// Re-enqueued events go through the processor like any redelivery: CheckAndMark
+// reclaims failed keys. Keys that failed before the processor kept messages
+// (migration 022) have nothing to re-enqueue and are counted as skipped.
+package bulkretry
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/jobs"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// eventsExchange is where ingest publishes events, and so where retries go.
+const eventsExchange = "events"
+
+// Store saves the progress of a retry job; *db.Client implements it.
+type Store interface {
+	UpdateRetryJob(job *domain.RetryJob) error
+}
+
+// Failures finds permanently failed keys and their kept messages;
+// *idempotency.Client implements it.
+type Failures interface {
+	CountFailed(reason string, from, to time.Time) (int, error)
+	ListFailed(reason string, from, to time.Time, afterID string, limit int) ([]domain.FailedMessage, error)
+}
+
+// Options tunes a Runner. Zero values select the defaults.
+type Options struct {
+	Workers   int           // concurrent jobs; default 1
+	BatchSize int           // keys read and re-enqueued per batch; default 100
+	Rate      int           // messages re-enqueued per second; default 100
+	Timeout   time.Duration // per job; default 2h
+}
+
+// Runner executes retry jobs.
+type Runner struct {
+	store     Store
+	failures  Failures
+	publisher ports.Publisher
+	metrics   ports.Metrics
+	logger    *logging.Logger
+	opts      Options
+	pool      *jobs.Pool
+}
+
+// NewRunner returns a Runner re-enqueueing through publisher.
+func NewRunner(store Store, failures Failures, publisher ports.Publisher, metrics ports.Metrics, logger *logging.Logger, opts Options) *Runner {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Rate <= 0 {
+		opts.Rate = 100
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Hour
+	}
+	return &Runner{
+		store:     store,
+		failures:  failures,
+		publisher: publisher,
+		metrics:   metrics,
+		logger:    logger,
+		opts:      opts,
+		pool:      jobs.NewPool(opts.Workers, opts.Timeout),
+	}
+}
+
+// Start runs job in the background. It stays pending until one of the Workers
+// slots is free.
+func (r *Runner) Start(job *domain.RetryJob) {
+	r.pool.Start(func(ctx context.Context) { _ = r.Run(ctx, job) })
+}
+
+// Wait blocks until every started job has finished.
+func (r *Runner) Wait() {
+	r.pool.Wait()
+}
+
+// Run executes job and records its outcome on it, returning the failure if any.
+func (r *Runner) Run(ctx context.Context, job *domain.RetryJob) error {
+	start := time.Now()
+	err := r.run(ctx, job)
+	if err != nil {
+		now := time.Now().UTC()
+		job.Status, job.CompletedAt = domain.RetryJobStatusFailed, &now
+		if job.ErrorReason == "" {
+			job.ErrorReason = "internal_error"
+		}
+		r.logger.Error("Bulk retry failed", err, map[string]interface{}{"job_id": job.JobID, "reason": job.ErrorReason})
+	} else {
+		r.logger.Info("Bulk retry complete", map[string]interface{}{
+			"job_id":      job.JobID,
+			"enqueued":    job.Enqueued,
+			"skipped":     job.Skipped,
+			"duration_ms": time.Since(start).Milliseconds(),
+		})
+	}
+	r.save(job)
+	r.metrics.IncCounter(metricdef.RetryJobsTotal, "status", string(job.Status))
+	return err
+}
+
+func (r *Runner) run(ctx context.Context, job *domain.RetryJob) error {
+	job.Status = domain.RetryJobStatusRunning
+	r.save(job)
+
+	total, err := r.failures.CountFailed(job.Reason, job.From, job.To)
+	if err != nil {
+		job.ErrorReason = "query_failed"
+		return err
+	}
+	job.TotalKeys = total
+	r.save(job)
+
+	// Each batch takes at least interval, so messages go out at no more than Rate.
+	interval := time.Duration(r.opts.BatchSize) * time.Second / time.Duration(r.opts.Rate)
+	after := ""
+	for {
+		started := time.Now()
+		page, err := r.failures.ListFailed(job.Reason, job.From, job.To, after, r.opts.BatchSize)
+		if err != nil {
+			job.ErrorReason = "query_failed"
+			return err
+		}
+		for _, f := range page {
+			after = f.EventID
+			if err := r.enqueue(ctx, job, f); err != nil {
+				job.ErrorReason = "publish_failed"
+				return err
+			}
+		}
+		r.save(job)
+		if len(page) < r.opts.BatchSize {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			job.ErrorReason = "timeout"
+			return ctx.Err()
+		case <-time.After(interval - time.Since(started)):
+		}
+	}
+	now := time.Now().UTC()
+	job.Status, job.CompletedAt = domain.RetryJobStatusComplete, &now
+	return nil
+}
+
+// enqueue publishes f's kept message on its original routing key, or counts f as
+// skipped when there is no usable message.
+func (r *Runner) enqueue(ctx context.Context, job *domain.RetryJob, f domain.FailedMessage) error {
+	var msg domain.QueueMessage
+	if f.Message == nil || json.Unmarshal(f.Message, &msg) != nil {
+		job.Skipped++
+		r.metrics.IncCounter(metricdef.RetriedEventsTotal, "result", "skipped")
+		return nil
+	}
+	if err := r.publisher.Publish(ctx, eventsExchange, msg.RoutingKey(), f.Message); err != nil {
+		return err
+	}
+	job.Enqueued++
+	r.metrics.IncCounter(metricdef.RetriedEventsTotal, "result", "enqueued")
+	return nil
+}
+
+// save records job's progress. Failures are logged, not fatal: the job carries
+// on and the next save catches the row up.
+func (r *Runner) save(job *domain.RetryJob) {
+	if err := r.store.UpdateRetryJob(job); err != nil {
+		r.logger.Error("Failed to save bulk retry progress", err, map[string]interface{}{"job_id": job.JobID})
+	}
+}
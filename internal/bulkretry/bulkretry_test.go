@@ -0,0 +1,135 @@
+package bulkretry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+type fakeStore struct {
+	updates []domain.RetryJob
+}
+
+func (s *fakeStore) UpdateRetryJob(j *domain.RetryJob) error {
+	s.updates = append(s.updates, *j)
+	return nil
+}
+
+// fakeFailures holds failed keys in event_id order.
+type fakeFailures struct {
+	failed  []domain.FailedMessage
+	listErr error
+	pages   int
+}
+
+func (f *fakeFailures) CountFailed(reason string, from, to time.Time) (int, error) {
+	return len(f.failed), nil
+}
+
+func (f *fakeFailures) ListFailed(reason string, from, to time.Time, afterID string, limit int) ([]domain.FailedMessage, error) {
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	f.pages++
+	var out []domain.FailedMessage
+	for _, m := range f.failed {
+		if m.EventID > afterID && len(out) < limit {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func failedMessages(t *testing.T, n int) []domain.FailedMessage {
+	t.Helper()
+	out := make([]domain.FailedMessage, n)
+	for i := range out {
+		id := fmt.Sprintf("evt-%02d", i)
+		msg := fluxatest.InlineEnvelope(id, fluxatest.NewEvent(id).Payload())
+		if i == 0 {
+			msg.Priority = domain.PriorityHigh
+		}
+		body, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = domain.FailedMessage{EventID: id, Message: body}
+	}
+	return out
+}
+
+func newJob() *domain.RetryJob {
+	return &domain.RetryJob{JobID: "job-1", Reason: "validation_failed", Status: domain.RetryJobStatusPending}
+}
+
+func TestRunner_EnqueuesInBatches(t *testing.T) {
+	failures := &fakeFailures{failed: failedMessages(t, 5)}
+	failures.failed[3].Message = nil // failed before messages were kept
+	store, queue, m := &fakeStore{}, fluxatest.NewQueue(), fluxatest.NewMetrics()
+	r := NewRunner(store, failures, queue, m, logging.NewLogger("test", "test"), Options{BatchSize: 2, Rate: 1000})
+
+	job := newJob()
+	if err := r.Run(context.Background(), job); err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if job.Status != domain.RetryJobStatusComplete || job.TotalKeys != 5 || job.Enqueued != 4 || job.Skipped != 1 {
+		t.Errorf("job = %+v, want complete with 4 enqueued, 1 skipped of 5", job)
+	}
+	if failures.pages != 3 {
+		t.Errorf("read %d pages, want 3 of 2", failures.pages)
+	}
+	published := queue.Published("events")
+	if len(published) != 4 {
+		t.Fatalf("published %d messages, want 4", len(published))
+	}
+	if published[0].RoutingKey != domain.PriorityEventsRoutingKey || published[1].RoutingKey != domain.EventsRoutingKey {
+		t.Errorf("routing keys = %q, %q; want the messages' own", published[0].RoutingKey, published[1].RoutingKey)
+	}
+	if got := m.Counter(metricdef.RetriedEventsTotal, "skipped"); got != 1 {
+		t.Errorf("retried_events_total{skipped} = %d, want 1", got)
+	}
+	if got := m.Counter(metricdef.RetryJobsTotal, "complete"); got != 1 {
+		t.Errorf("retry_jobs_total{complete} = %d, want 1", got)
+	}
+	if len(store.updates) < 4 {
+		t.Errorf("saved progress %d times, want running, total, each batch, outcome", len(store.updates))
+	}
+}
+
+func TestRunner_Throttles(t *testing.T) {
+	failures := &fakeFailures{failed: failedMessages(t, 4)}
+	r := NewRunner(&fakeStore{}, failures, fluxatest.NewQueue(), fluxatest.NewMetrics(), logging.NewLogger("test", "test"),
+		Options{BatchSize: 2, Rate: 20})
+
+	start := time.Now()
+	if err := r.Run(context.Background(), newJob()); err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	// Two full batches of 2 at 20/s: at least 100ms between them.
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("Run took %v, want >= 100ms at 20 messages/s", d)
+	}
+}
+
+func TestRunner_RecordsFailure(t *testing.T) {
+	queue := fluxatest.NewQueue()
+	queue.PublishErr = errors.New("broker down")
+	r := NewRunner(&fakeStore{}, &fakeFailures{failed: failedMessages(t, 2)}, queue, fluxatest.NewMetrics(),
+		logging.NewLogger("test", "test"), Options{})
+
+	job := newJob()
+	if err := r.Run(context.Background(), job); err == nil {
+		t.Fatal("Run = nil, want the publish error")
+	}
+	if job.Status != domain.RetryJobStatusFailed || job.ErrorReason != "publish_failed" || job.CompletedAt == nil {
+		t.Errorf("job = %+v, want failed with publish_failed", job)
+	}
+}
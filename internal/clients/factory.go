@@ -0,0 +1,212 @@
+// Package clients builds the MinIO and RabbitMQ clients every service needs from
+// one place, so connection tuning, region/endpoint overrides, and client
+// identification stay consistent instead of being re-derived in each main.go.
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	minioadapter "github.com/fluxa/fluxa/internal/adapters/minio"
+	"github.com/fluxa/fluxa/internal/adapters/rabbitmq"
+	"github.com/fluxa/fluxa/internal/config"
+	"github.com/fluxa/fluxa/internal/config/secrets"
+	"github.com/fluxa/fluxa/internal/dynconfig"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/notify"
+	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/fluxa/fluxa/internal/schema"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// appName prefixes client identification (MinIO User-Agent, AMQP connection_name),
+// so server-side logs show which fluxa service made a request.
+const appName = "fluxa"
+
+// Tuning for the shared HTTP transport and AMQP dialer. Object-store calls sit on
+// the ingest hot path, so dials and header waits fail fast rather than holding a
+// request for the Go defaults (30s+ / unbounded).
+const (
+	dialTimeout           = 5 * time.Second
+	keepAlive             = 30 * time.Second
+	tlsHandshakeTimeout   = 5 * time.Second
+	responseHeaderTimeout = 10 * time.Second
+	idleConnTimeout       = 90 * time.Second
+	maxIdleConnsPerHost   = 32
+	amqpHeartbeat         = 10 * time.Second
+)
+
+// Factory constructs backend clients from a Config. A Factory is safe for
+// concurrent use; the HTTP transport is built once and shared by every client
+// it creates, so connections are pooled across them.
+type Factory struct {
+	cfg     *config.Config
+	service string
+
+	transportOnce sync.Once
+	transport     *http.Transport
+}
+
+// New returns a Factory for the named service (e.g. "ingest").
+func New(cfg *config.Config, service string) *Factory {
+	return &Factory{cfg: cfg, service: service}
+}
+
+// HTTPTransport returns the shared, tuned transport.
+func (f *Factory) HTTPTransport() *http.Transport {
+	f.transportOnce.Do(func() {
+		f.transport = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   dialTimeout,
+				KeepAlive: keepAlive,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+			IdleConnTimeout:       idleConnTimeout,
+			TLSHandshakeTimeout:   tlsHandshakeTimeout,
+			ResponseHeaderTimeout: responseHeaderTimeout,
+			ExpectContinueTimeout: time.Second,
+		}
+	})
+	return f.transport
+}
+
+// Storage connects to MinIO using the shared transport and ensures the bucket exists.
+func (f *Factory) Storage() (*minioadapter.Client, error) {
+	return minioadapter.NewClientWithOptions(f.storageOptions())
+}
+
+// CheckStorage reports missing or misplaced buckets without creating them; see
+// minioadapter.CheckBuckets.
+func (f *Factory) CheckStorage(ctx context.Context) ([]error, error) {
+	return minioadapter.CheckBuckets(ctx, f.storageOptions())
+}
+
+// Schemas returns the event schema registry: the embedded base schema plus the
+// EVENT_SCHEMA_TYPES schemas, read from MinIO under EVENT_SCHEMA_PREFIX. MinIO is
+// only connected when there are types to read.
+func (f *Factory) Schemas(ctx context.Context) (*schema.Registry, error) {
+	if len(f.cfg.EventSchemaTypes) == 0 {
+		return schema.New(nil)
+	}
+	store, err := f.Storage()
+	if err != nil {
+		return nil, err
+	}
+	return schema.Load(ctx, store, f.cfg.EventSchemaPrefix, f.cfg.EventSchemaTypes)
+}
+
+func (f *Factory) storageOptions() minioadapter.Options {
+	return minioadapter.Options{
+		Endpoint:   f.cfg.MinioEndpoint,
+		AccessKey:  f.cfg.MinioAccessKey,
+		SecretKey:  f.cfg.MinioSecretKey,
+		Bucket:     f.cfg.MinioBucket,
+		UseSSL:     f.cfg.MinioUseSSL,
+		Region:     f.cfg.MinioRegion,
+		Transport:  f.HTTPTransport(),
+		AppName:    appName + "-" + f.service,
+		AppVersion: buildVersion(),
+
+		PublicEndpoint: f.cfg.MinioPublicEndpoint,
+		PrefixBuckets:  f.cfg.PayloadPrefixBuckets(),
+	}
+}
+
+// Queue dials RabbitMQ with a bounded connect timeout and a connection_name that
+// identifies the service in the management UI.
+func (f *Factory) Queue() (*rabbitmq.Client, error) {
+	props := amqp.NewConnectionProperties()
+	props.SetClientConnectionName(appName + "-" + f.service)
+	return rabbitmq.NewClientWithConfig(f.cfg.RabbitMQURL, amqp.Config{
+		Heartbeat:  amqpHeartbeat,
+		Locale:     "en_US",
+		Dial:       amqp.DefaultDial(dialTimeout),
+		Properties: props,
+	})
+}
+
+// Notifier returns the fraud alert notifier selected by NOTIFIER_MODE. Queue mode
+// publishes through pub, which the other modes ignore (it may be nil). Webhook
+// requests use the shared transport.
+func (f *Factory) Notifier(pub ports.Publisher) (notify.Notifier, error) {
+	switch f.cfg.NotifierMode {
+	case notify.ModeQueue:
+		if pub == nil {
+			return nil, fmt.Errorf("notifier: queue mode needs a publisher")
+		}
+		return notify.NewQueueNotifier(pub), nil
+	case notify.ModeWebhook:
+		if f.cfg.NotifierWebhookURL == "" {
+			return nil, fmt.Errorf("notifier: webhook mode needs NOTIFIER_WEBHOOK_URL")
+		}
+		return notify.NewWebhookNotifier(f.cfg.NotifierWebhookURL,
+			&http.Client{Transport: f.HTTPTransport(), Timeout: responseHeaderTimeout}), nil
+	case notify.ModeNoop:
+		return notify.Noop{}, nil
+	}
+	return nil, fmt.Errorf("notifier: unknown NOTIFIER_MODE %q (want %s, %s or %s)",
+		f.cfg.NotifierMode, notify.ModeQueue, notify.ModeWebhook, notify.ModeNoop)
+}
+
+// Flags returns the service's runtime feature flags: FLAG_<NAME> env vars, then
+// the AppConfig agent profile when FEATURE_FLAGS_APPCONFIG_URL is set. AppConfig
+// requests use the shared transport.
+func (f *Factory) Flags(logger *logging.Logger) *featureflags.Flags {
+	sources := []featureflags.Source{featureflags.EnvSource{}}
+	if f.cfg.FeatureFlagsAppConfigURL != "" {
+		sources = append(sources, featureflags.AppConfigSource{
+			URL:    f.cfg.FeatureFlagsAppConfigURL,
+			Client: &http.Client{Transport: f.HTTPTransport(), Timeout: dialTimeout},
+		})
+	}
+	return featureflags.New(time.Duration(f.cfg.FeatureFlagsTTLSeconds)*time.Second, logger, sources...)
+}
+
+// Tunables returns the AppConfig-backed runtime tunables, or nil (no overrides)
+// when DYNAMIC_CONFIG_URL is unset. The first poll runs before it returns, so a
+// service starts on the deployed values; polling then continues until ctx is done.
+// A failed first poll is logged and the env configuration stays in force.
+func (f *Factory) Tunables(ctx context.Context, metrics ports.Metrics, logger *logging.Logger) *dynconfig.Store {
+	if f.cfg.DynamicConfigURL == "" {
+		return nil
+	}
+	store := dynconfig.NewStore(f.cfg.DynamicConfigURL,
+		&http.Client{Transport: f.HTTPTransport(), Timeout: dialTimeout},
+		time.Duration(f.cfg.DynamicConfigPollSeconds)*time.Second, metrics, logger)
+	if err := store.Poll(ctx); err != nil && logger != nil {
+		logger.Warn("Initial dynamic config poll failed; using env configuration", map[string]interface{}{"error": err.Error()})
+	}
+	go store.Run(ctx)
+	return store
+}
+
+// Secrets opens the shared secret bundle at SECRETS_FILE, or returns nil when
+// there is none; the Store's accessors treat nil as empty.
+func (f *Factory) Secrets(logger *logging.Logger) (*secrets.Store, error) {
+	if f.cfg.SecretsFile == "" {
+		return nil, nil
+	}
+	return secrets.Open(f.cfg.SecretsFile, secrets.Options{
+		Refresh: time.Duration(f.cfg.SecretsRefreshSeconds) * time.Second,
+		Grace:   time.Duration(f.cfg.SecretsRotationGraceSeconds) * time.Second,
+		Logger:  logger,
+	})
+}
+
+// buildVersion reports the main module version stamped by the Go toolchain,
+// or "dev" for local builds.
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
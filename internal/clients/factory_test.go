@@ -0,0 +1,29 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/config"
+)
+
+func TestFactory_SharesTransport(t *testing.T) {
+	f := New(&config.Config{}, "test")
+	a, b := f.HTTPTransport(), f.HTTPTransport()
+	if a != b {
+		t.Error("HTTPTransport returned distinct transports; want one shared instance")
+	}
+	if a.ResponseHeaderTimeout != responseHeaderTimeout || a.MaxIdleConnsPerHost != maxIdleConnsPerHost {
+		t.Errorf("transport not tuned: %+v", a)
+	}
+}
+
+func TestFactory_Notifier(t *testing.T) {
+	for mode, ok := range map[string]bool{"queue": false, "noop": true, "webhook": false, "sns": false} {
+		if _, err := New(&config.Config{NotifierMode: mode}, "test").Notifier(nil); (err == nil) != ok {
+			t.Errorf("Notifier(%q) error = %v, want ok=%v", mode, err, ok)
+		}
+	}
+	if _, err := New(&config.Config{NotifierMode: "webhook", NotifierWebhookURL: "http://alerts.local/hook"}, "test").Notifier(nil); err != nil {
+		t.Errorf("webhook mode with a URL: %v", err)
+	}
+}
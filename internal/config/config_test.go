@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"testing"
+
+	"github.com/fluxa/fluxa/internal/domain"
 )
 
 func TestConfig_Validate(t *testing.T) {
@@ -44,6 +46,157 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "recording sample over 100",
+			cfg: &Config{
+				DBHost:                 "localhost",
+				DBUser:                 "user",
+				DBPassword:             "password",
+				RecordingSamplePercent: 150,
+				RecordingRetentionDays: 3,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative idempotency key TTL",
+			cfg: &Config{
+				DBHost:                 "localhost",
+				DBUser:                 "user",
+				DBPassword:             "password",
+				IdempotencyKeyTTLHours: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "recording without retention",
+			cfg: &Config{
+				DBHost:                 "localhost",
+				DBUser:                 "user",
+				DBPassword:             "password",
+				RecordingSamplePercent: 1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "overlapping payload prefixes",
+			cfg: &Config{
+				DBHost:     "localhost",
+				DBUser:     "user",
+				DBPassword: "password",
+				PayloadPlacements: map[string]domain.PayloadPlacement{
+					"a": {Bucket: "eu", Prefix: "acme"},
+					"b": {Bucket: "us", Prefix: "acme/us"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "storage budget share out of range",
+			cfg: &Config{
+				DBHost:                     "localhost",
+				DBUser:                     "user",
+				DBPassword:                 "password",
+				IngestRequestBudgetMs:      10000,
+				IngestStorageBudgetPercent: 100,
+			},
+			wantErr: true,
+		},
+		{
+			name: "advisory locks on a single connection",
+			cfg: &Config{
+				DBHost:              "localhost",
+				DBUser:              "user",
+				DBPassword:          "password",
+				DBPoolStrategy:      "single",
+				IdempotencyLockMode: "advisory",
+			},
+			wantErr: true,
+		},
+		{
+			name: "authz enforced without roles",
+			cfg: &Config{
+				DBHost:     "localhost",
+				DBUser:     "user",
+				DBPassword: "password",
+				QueryAuthz: "enforce",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown query role",
+			cfg: &Config{
+				DBHost:        "localhost",
+				DBUser:        "user",
+				DBPassword:    "password",
+				QueryAuthz:    "enforce",
+				QueryAPIRoles: map[string]string{"abc": "owner"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max attempts",
+			cfg: &Config{
+				DBHost:               "localhost",
+				DBUser:               "user",
+				DBPassword:           "password",
+				ProcessorMaxAttempts: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "usage metrics tenants without a secret bundle",
+			cfg: &Config{
+				DBHost:         "localhost",
+				DBUser:         "user",
+				DBPassword:     "password",
+				MetricsTenants: []string{"acme"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "RabbitMQ URL without amqp scheme",
+			cfg: &Config{
+				DBHost:      "localhost",
+				DBUser:      "user",
+				DBPassword:  "password",
+				RabbitMQURL: "rabbitmq:5672",
+			},
+			wantErr: true,
+		},
+		{
+			name: "MinIO endpoint in another region",
+			cfg: &Config{
+				DBHost:        "localhost",
+				DBUser:        "user",
+				DBPassword:    "password",
+				MinioEndpoint: "s3.eu-west-1.amazonaws.com",
+				MinioRegion:   "us-east-1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid bucket name",
+			cfg: &Config{
+				DBHost:      "localhost",
+				DBUser:      "user",
+				DBPassword:  "password",
+				MinioBucket: "Fluxa_Events",
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid endpoints",
+			cfg: &Config{
+				DBHost:        "localhost",
+				DBUser:        "user",
+				DBPassword:    "password",
+				RabbitMQURL:   "amqps://u:p@mq.internal:5671/fluxa",
+				MinioEndpoint: "s3.eu-west-1.amazonaws.com",
+				MinioRegion:   "eu-west-1",
+				MinioBucket:   "fluxa-events",
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -72,6 +225,29 @@ func TestConfig_DSN(t *testing.T) {
 	if dsn != expected {
 		t.Errorf("Config.DSN() = %v, want %v", dsn, expected)
 	}
+
+	cfg.DBStatementTimeoutMs = 15000
+	if dsn := cfg.DSN(); dsn != expected+" statement_timeout=15000" {
+		t.Errorf("Config.DSN() with statement timeout = %v", dsn)
+	}
+	// RDS Proxy pins sessions that set startup parameters.
+	cfg.DBPoolStrategy = "single"
+	if dsn := cfg.DSN(); dsn != expected {
+		t.Errorf("Config.DSN() with single pool = %v, want no statement_timeout", dsn)
+	}
+}
+
+func TestConfig_Checksum(t *testing.T) {
+	a := &Config{DBHost: "db", DBPassword: "one", RabbitMQURL: "amqp://u:one@mq:5672/", AdminCursorSecret: "one"}
+	b := *a
+	b.DBPassword, b.RabbitMQURL, b.AdminCursorSecret = "two", "amqp://u:two@mq:5672/", "two"
+	if a.Checksum() != b.Checksum() {
+		t.Error("Checksum changed with a credential")
+	}
+	b.DBHost = "other"
+	if a.Checksum() == b.Checksum() {
+		t.Error("Checksum ignored a setting")
+	}
 }
 
 func TestLoadFromEnv(t *testing.T) {
@@ -101,3 +277,43 @@ func TestLoadFromEnv(t *testing.T) {
 		t.Errorf("LoadFromEnv() RabbitMQURL = %v, want default amqp URL", cfg.RabbitMQURL)
 	}
 }
+
+func TestParseListEnv(t *testing.T) {
+	t.Setenv("FLUXA_TEST_LIST", " country, ,segment ,")
+	got := parseListEnv("FLUXA_TEST_LIST", nil)
+	if len(got) != 2 || got[0] != "country" || got[1] != "segment" {
+		t.Errorf("parseListEnv = %q, want [country segment]", got)
+	}
+
+	def := []string{"a"}
+	if got := parseListEnv("FLUXA_TEST_LIST_UNSET", def); len(got) != 1 || got[0] != "a" {
+		t.Errorf("parseListEnv unset = %q, want default", got)
+	}
+}
+
+func TestParseIntMapEnv(t *testing.T) {
+	t.Setenv("FLUXA_TEST_MAP", " a=720, b = 0 ,bad,c=x,=5")
+	got := parseIntMapEnv("FLUXA_TEST_MAP")
+	if len(got) != 2 || got["a"] != 720 || got["b"] != 0 {
+		t.Errorf("parseIntMapEnv = %v, want map[a:720 b:0]", got)
+	}
+	if got := parseIntMapEnv("FLUXA_TEST_MAP_UNSET"); len(got) != 0 {
+		t.Errorf("parseIntMapEnv unset = %v, want empty", got)
+	}
+}
+
+func TestParsePlacementsEnv(t *testing.T) {
+	t.Setenv("FLUXA_TEST_PLACEMENTS", " a=eu-payloads/acme/raw/ , b = us-payloads ,bad,=x")
+	got := parsePlacementsEnv("FLUXA_TEST_PLACEMENTS")
+	want := map[string]domain.PayloadPlacement{
+		"a": {Bucket: "eu-payloads", Prefix: "acme/raw"},
+		"b": {Bucket: "us-payloads", Prefix: "tenants/b"},
+	}
+	if len(got) != len(want) || got["a"] != want["a"] || got["b"] != want["b"] {
+		t.Errorf("parsePlacementsEnv = %v, want %v", got, want)
+	}
+	cfg := &Config{PayloadPlacements: got}
+	if b := cfg.PayloadPrefixBuckets(); b["acme/raw"] != "eu-payloads" || b["tenants/b"] != "us-payloads" {
+		t.Errorf("PayloadPrefixBuckets = %v", b)
+	}
+}
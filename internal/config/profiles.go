@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Config profiles, selected by ENVIRONMENT. A profile supplies defaults for
+// environment variables on top of the built-in ones, so the local harness, tests
+// and deployed services differ only in the profile they name; a variable set in
+// the environment always wins.
+const (
+	ProfileDev     = "dev"
+	ProfileStaging = "staging"
+	ProfileProd    = "prod"
+)
+
+// profileAliases are the other ENVIRONMENT values accepted for a profile.
+// "local" is the ENVIRONMENT default and the name docker-compose uses.
+var profileAliases = map[string]string{
+	"local":       ProfileDev,
+	"development": ProfileDev,
+	"stage":       ProfileStaging,
+	"production":  ProfileProd,
+}
+
+// profile is one profile's defaults, and the variables it requires to be set:
+// those whose built-in defaults only suit a laptop (localhost, stock
+// credentials).
+type profile struct {
+	defaults map[string]string
+	required []string
+}
+
+// deployedRequired are required by every deployed profile.
+var deployedRequired = []string{
+	"DB_HOST", "DB_USER", "DB_PASSWORD",
+	"RABBITMQ_URL",
+	"MINIO_ENDPOINT", "MINIO_ACCESS_KEY", "MINIO_SECRET_KEY",
+}
+
+var profiles = map[string]profile{
+	// dev matches docker-compose's Postgres with its port published on localhost,
+	// so the services and tests run against `make up` with no variables set.
+	ProfileDev: {defaults: map[string]string{
+		"DB_HOST":     "localhost",
+		"DB_USER":     "fluxa_user",
+		"DB_PASSWORD": "fluxa_password",
+		"RULES_FILE":  "rules.yaml",
+	}},
+	ProfileStaging: {
+		defaults: map[string]string{
+			"DB_SSL_MODE":   "require",
+			"MINIO_USE_SSL": "true",
+		},
+		required: deployedRequired,
+	},
+	ProfileProd: {
+		defaults: map[string]string{
+			"DB_SSL_MODE":            "require",
+			"MINIO_USE_SSL":          "true",
+			"QUERY_MASK_PII":         "true",
+			"DIAGNOSTICS_SELF_CHECK": "true",
+		},
+		required: deployedRequired,
+	},
+}
+
+// activeProfile holds the defaults LoadFromEnv selected; the env helpers fall
+// back to it. Nil outside LoadFromEnv's profile, e.g. in tests building a Config.
+var activeProfile map[string]string
+
+// ResolveProfile returns the profile named by environment (ENVIRONMENT), through
+// its aliases.
+func ResolveProfile(environment string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(environment))
+	if alias, ok := profileAliases[name]; ok {
+		name = alias
+	}
+	if _, ok := profiles[name]; !ok {
+		return "", fmt.Errorf("ENVIRONMENT must name a profile (dev, staging, prod), got %q", environment)
+	}
+	return name, nil
+}
+
+// useProfile makes name's defaults the fallback of the env helpers, and reports
+// the variables the profile requires that are unset.
+func useProfile(name string) error {
+	p := profiles[name]
+	activeProfile = p.defaults
+	var missing []string
+	for _, key := range p.required {
+		if os.Getenv(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("profile %s requires %s", name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// envValue returns key from the environment, else the active profile's default,
+// else "".
+func envValue(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return activeProfile[key]
+}
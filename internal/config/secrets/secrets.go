@@ -0,0 +1,255 @@
+// Package secrets reads structured secret bundles: the JSON documents
+// secrets-manager entries are mounted as. A bundle maps producer API keys to their
+// tenants, to the HMAC secrets they sign ingest requests with (internal/signing),
+// and to the secrets their ack webhooks are signed with (internal/webhook), so
+// features that need any of them read them through a Store's typed accessors
+// instead of parsing their own files.
+//
+// A Store caches its bundle and re-reads the file when it changes, checking at
+// most every Refresh, so a rotated entry takes effect without a restart. A signing
+// secret that a reload replaces or removes stays valid for Grace, giving producers
+// time to move to the new one.
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/logging"
+)
+
+// Defaults for a zero Options.Refresh and Options.Grace.
+const (
+	DefaultRefresh = 30 * time.Second
+	DefaultGrace   = 15 * time.Minute
+)
+
+// Bundle is a parsed secret bundle:
+//
+//	{"api_keys": {"<API key>": "<tenant>"},
+//	 "signing_secrets": {"<API key>": "<secret>"},
+//	 "webhook_secrets": {"<API key>": "<secret>"}}
+//
+// A flat object of API key → secret, the format INGEST_SIGNING_SECRETS_FILE has
+// always had, reads as signing_secrets.
+type Bundle struct {
+	APIKeys        map[string]string `json:"api_keys"`
+	SigningSecrets map[string]string `json:"signing_secrets"`
+	WebhookSecrets map[string]string `json:"webhook_secrets"`
+}
+
+// Parse decodes and checks a bundle. Empty keys, tenants and secrets are errors.
+func Parse(raw []byte) (Bundle, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return Bundle{}, err
+	}
+	var b Bundle
+	_, hasKeys := doc["api_keys"]
+	_, hasSecrets := doc["signing_secrets"]
+	_, hasWebhooks := doc["webhook_secrets"]
+	if hasKeys || hasSecrets || hasWebhooks {
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return Bundle{}, err
+		}
+	} else if err := json.Unmarshal(raw, &b.SigningSecrets); err != nil {
+		return Bundle{}, err
+	}
+	for key, tenant := range b.APIKeys {
+		if key == "" || tenant == "" {
+			return Bundle{}, fmt.Errorf("api_keys: empty API key or tenant")
+		}
+	}
+	for key, secret := range b.SigningSecrets {
+		if key == "" || secret == "" {
+			return Bundle{}, fmt.Errorf("signing_secrets: empty API key or secret")
+		}
+	}
+	for key, secret := range b.WebhookSecrets {
+		if key == "" || secret == "" {
+			return Bundle{}, fmt.Errorf("webhook_secrets: empty API key or secret")
+		}
+	}
+	return b, nil
+}
+
+// Options tunes a Store. Zero values select the defaults.
+type Options struct {
+	Refresh time.Duration // how often the file is checked for changes
+	Grace   time.Duration // how long a replaced signing secret stays valid
+	Logger  *logging.Logger
+}
+
+// retired is a signing secret a reload replaced, valid until until.
+type retired struct {
+	secret string
+	until  time.Time
+}
+
+// Store serves a bundle file, reloading it when it changes. A reload that fails
+// keeps the last good bundle in force and is logged.
+type Store struct {
+	path string
+	opts Options
+	now  func() time.Time
+
+	mu      sync.Mutex
+	bundle  Bundle
+	hooks   map[string]string // WebhookSecrets by domain.HashAPIKey
+	modTime time.Time
+	size    int64
+	checked time.Time
+	retired map[string][]retired
+}
+
+// Open loads the bundle at path. Unlike later reloads, a failure here is returned:
+// a service should not start without its secrets.
+func Open(path string, opts Options) (*Store, error) {
+	if opts.Refresh <= 0 {
+		opts.Refresh = DefaultRefresh
+	}
+	if opts.Grace <= 0 {
+		opts.Grace = DefaultGrace
+	}
+	s := &Store{path: path, opts: opts, now: time.Now, retired: map[string][]retired{}}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: read %s: %w", path, err)
+	}
+	if err := s.load(info); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Tenant returns the tenant apiKey belongs to. A nil Store has none.
+func (s *Store) Tenant(apiKey string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	tenant, ok := s.bundle.APIKeys[apiKey]
+	return tenant, ok
+}
+
+// WebhookSecret returns the secret the ack webhook of apiKeyHash, a
+// domain.HashAPIKey, is signed with. Webhooks are looked up by hashed key, the
+// only form the processor sees. A rotated secret signs from the next delivery;
+// there is no grace, since the receiver is the one verifying. A nil Store has
+// none.
+func (s *Store) WebhookSecret(apiKeyHash string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	secret, ok := s.hooks[apiKeyHash]
+	return secret, ok
+}
+
+// SigningSecrets returns the secrets apiKey may sign with: its current one first,
+// then any replaced within Grace. Nil when the key has none.
+func (s *Store) SigningSecrets(apiKey string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	var out []string
+	if secret, ok := s.bundle.SigningSecrets[apiKey]; ok {
+		out = append(out, secret)
+	}
+	now := s.now()
+	for _, r := range s.retired[apiKey] {
+		if now.Before(r.until) {
+			out = append(out, r.secret)
+		}
+	}
+	return out
+}
+
+// SigningKeys returns how many API keys have a signing secret.
+func (s *Store) SigningKeys() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	return len(s.bundle.SigningSecrets)
+}
+
+// refresh reloads the file if Refresh has passed and it changed. Callers hold mu.
+func (s *Store) refresh() {
+	now := s.now()
+	if now.Sub(s.checked) < s.opts.Refresh {
+		return
+	}
+	s.checked = now
+	info, err := os.Stat(s.path)
+	if err != nil {
+		s.warn(err)
+		return
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return
+	}
+	if err := s.load(info); err != nil {
+		s.warn(err)
+		return
+	}
+	if s.opts.Logger != nil {
+		s.opts.Logger.Info("Secret bundle reloaded", map[string]interface{}{
+			"path":         s.path,
+			"signing_keys": len(s.bundle.SigningSecrets),
+			"webhook_keys": len(s.bundle.WebhookSecrets),
+			"rotating":     len(s.retired),
+		})
+	}
+}
+
+// load reads the file described by info and swaps it in, retiring the signing
+// secrets it replaces. Callers hold mu, or own s.
+func (s *Store) load(info os.FileInfo) error {
+	raw, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("secrets: read %s: %w", s.path, err)
+	}
+	b, err := Parse(raw)
+	if err != nil {
+		return fmt.Errorf("secrets: parse %s: %w", s.path, err)
+	}
+	now := s.now()
+	for key, old := range s.bundle.SigningSecrets {
+		if b.SigningSecrets[key] != old {
+			s.retired[key] = append(s.retired[key], retired{secret: old, until: now.Add(s.opts.Grace)})
+		}
+	}
+	for key, rs := range s.retired {
+		kept := rs[:0]
+		for _, r := range rs {
+			if now.Before(r.until) && r.secret != b.SigningSecrets[key] {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.retired, key)
+		} else {
+			s.retired[key] = kept
+		}
+	}
+	hooks := make(map[string]string, len(b.WebhookSecrets))
+	for key, secret := range b.WebhookSecrets {
+		hooks[domain.HashAPIKey(key)] = secret
+	}
+	s.bundle, s.hooks, s.modTime, s.size, s.checked = b, hooks, info.ModTime(), info.Size(), now
+	return nil
+}
+
+func (s *Store) warn(err error) {
+	if s.opts.Logger != nil {
+		s.opts.Logger.Warn("Failed to reload secret bundle; keeping the last good one", map[string]interface{}{"error": err.Error()})
+	}
+}
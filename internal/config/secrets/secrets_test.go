@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+func writeBundle(t *testing.T, path, body string, mod time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    Bundle
+		wantErr bool
+	}{
+		{"bundle", `{"api_keys":{"key-a":"acme"},"signing_secrets":{"key-a":"secret-a"}}`,
+			Bundle{APIKeys: map[string]string{"key-a": "acme"}, SigningSecrets: map[string]string{"key-a": "secret-a"}}, false},
+		{"webhook secrets only", `{"webhook_secrets":{"key-a":"hook-a"}}`,
+			Bundle{WebhookSecrets: map[string]string{"key-a": "hook-a"}}, false},
+		{"flat signing secrets", `{"key-a":"secret-a"}`,
+			Bundle{SigningSecrets: map[string]string{"key-a": "secret-a"}}, false},
+		{"empty secret", `{"key-a":""}`, Bundle{}, true},
+		{"empty tenant", `{"api_keys":{"key-a":""}}`, Bundle{}, true},
+		{"empty webhook secret", `{"webhook_secrets":{"key-a":""}}`, Bundle{}, true},
+		{"not an object", `["key-a"]`, Bundle{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen_MissingFile(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.json"), Options{}); err == nil {
+		t.Error("Open accepted a missing file")
+	}
+}
+
+func TestStore_ReloadsAndRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	start := time.Now().Truncate(time.Second)
+	writeBundle(t, path, `{"api_keys":{"key-a":"acme"},"signing_secrets":{"key-a":"old","key-b":"b"},"webhook_secrets":{"key-a":"hook-old"}}`, start)
+	s, err := Open(path, Options{Refresh: time.Minute, Grace: 10 * time.Minute})
+	if err != nil {
+		t.Fatalf("Open = %v", err)
+	}
+	now := start
+	s.now = func() time.Time { return now }
+
+	if tenant, ok := s.Tenant("key-a"); !ok || tenant != "acme" {
+		t.Errorf("Tenant = %q, %v; want acme", tenant, ok)
+	}
+	if secret, ok := s.WebhookSecret(domain.HashAPIKey("key-a")); !ok || secret != "hook-old" {
+		t.Errorf("WebhookSecret = %q, %v; want hook-old", secret, ok)
+	}
+
+	// key-a rotates and key-b is dropped.
+	writeBundle(t, path, `{"api_keys":{"key-a":"acme"},"signing_secrets":{"key-a":"new"},"webhook_secrets":{"key-a":"hook-new"}}`, start.Add(time.Second))
+	now = start.Add(30 * time.Second)
+	if got := s.SigningSecrets("key-a"); !reflect.DeepEqual(got, []string{"old"}) {
+		t.Errorf("SigningSecrets before Refresh = %v, want the cached [old]", got)
+	}
+	now = start.Add(2 * time.Minute)
+	if got := s.SigningSecrets("key-a"); !reflect.DeepEqual(got, []string{"new", "old"}) {
+		t.Errorf("SigningSecrets after reload = %v, want [new old]", got)
+	}
+	if got := s.SigningSecrets("key-b"); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("SigningSecrets of a removed key = %v, want [b] within Grace", got)
+	}
+	if secret, _ := s.WebhookSecret(domain.HashAPIKey("key-a")); secret != "hook-new" {
+		t.Errorf("WebhookSecret after reload = %q, want hook-new at once", secret)
+	}
+
+	// A broken file keeps the last good bundle.
+	writeBundle(t, path, `{"key-a":`, start.Add(2*time.Second))
+	now = start.Add(4 * time.Minute)
+	if got := s.SigningKeys(); got != 1 {
+		t.Errorf("SigningKeys after a bad reload = %d, want 1", got)
+	}
+
+	now = start.Add(13 * time.Minute)
+	if got := s.SigningSecrets("key-a"); !reflect.DeepEqual(got, []string{"new"}) {
+		t.Errorf("SigningSecrets after Grace = %v, want [new]", got)
+	}
+	if got := s.SigningSecrets("key-b"); got != nil {
+		t.Errorf("SigningSecrets of a removed key after Grace = %v, want nil", got)
+	}
+}
@@ -0,0 +1,75 @@
+// Package cursor seals pagination state into opaque page tokens. A token is the
+// JSON state encrypted and authenticated with AES-256-GCM and base64url-encoded,
+// so clients can't read the keys it carries (event IDs, timestamps) or forge one
+// that starts a page somewhere else.
+package cursor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalid is returned for a token that is malformed, was tampered with, or was
+// sealed with another secret.
+var ErrInvalid = errors.New("cursor: invalid token")
+
+// Codec seals and opens page tokens.
+type Codec struct {
+	aead cipher.AEAD
+}
+
+// NewCodec returns a Codec keyed by SHA-256 of secret. An empty secret selects a
+// random key, so tokens only open in the process that sealed them.
+func NewCodec(secret string) (*Codec, error) {
+	key := sha256.Sum256([]byte(secret))
+	if secret == "" {
+		if _, err := rand.Read(key[:]); err != nil {
+			return nil, fmt.Errorf("cursor: generate key: %w", err)
+		}
+	}
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("cursor: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cursor: %w", err)
+	}
+	return &Codec{aead: aead}, nil
+}
+
+// Seal returns the token for state v.
+func (c *Codec) Seal(v any) (string, error) {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("cursor: marshal: %w", err)
+	}
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plain)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("cursor: nonce: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(c.aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// Open decodes token into v, or returns ErrInvalid.
+func (c *Codec) Open(token string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) < c.aead.NonceSize() {
+		return ErrInvalid
+	}
+	nonce, sealed := raw[:c.aead.NonceSize()], raw[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return ErrInvalid
+	}
+	if err := json.Unmarshal(plain, v); err != nil {
+		return ErrInvalid
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package cursor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type state struct {
+	After string `json:"after"`
+	N     int    `json:"n"`
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	c, err := NewCodec("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := c.Seal(state{After: "evt-42", N: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(token, "evt") {
+		t.Errorf("token %q leaks its state", token)
+	}
+	var got state
+	if err := c.Open(token, &got); err != nil {
+		t.Fatalf("Open = %v", err)
+	}
+	if got != (state{After: "evt-42", N: 7}) {
+		t.Errorf("Open = %+v", got)
+	}
+}
+
+func TestCodec_RejectsForeignAndTampered(t *testing.T) {
+	c, _ := NewCodec("secret")
+	other, _ := NewCodec("other")
+	random, _ := NewCodec("")
+	token, _ := c.Seal(state{After: "evt-1"})
+
+	tampered := []byte(token)
+	mid := len(tampered) / 2
+	if tampered[mid] == 'A' {
+		tampered[mid] = 'B'
+	} else {
+		tampered[mid] = 'A'
+	}
+	cases := map[string]struct {
+		codec *Codec
+		token string
+	}{
+		"other secret":  {other, token},
+		"random secret": {random, token},
+		"tampered":      {c, string(tampered)},
+		"not base64":    {c, "!!"},
+		"too short":     {c, "AAAA"},
+	}
+	for name, tc := range cases {
+		var got state
+		if err := tc.codec.Open(tc.token, &got); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: Open = %v, want ErrInvalid", name, err)
+		}
+	}
+}
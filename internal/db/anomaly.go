@@ -0,0 +1,41 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// UserAmountDistributionAsOf returns count, mean, and sample stddev of the user's
+// amounts with ts in [asOf-window, asOf). The event at asOf itself and canary
+// events are excluded.
+func (c *Client) UserAmountDistributionAsOf(userID string, asOf time.Time, windowSeconds int) (count int, mean, stddev float64, err error) {
+	return c.amountDistributionAsOf("user_id", userID, asOf, windowSeconds)
+}
+
+// MerchantAmountDistributionAsOf is UserAmountDistributionAsOf keyed on the raw merchant descriptor.
+func (c *Client) MerchantAmountDistributionAsOf(merchant string, asOf time.Time, windowSeconds int) (count int, mean, stddev float64, err error) {
+	return c.amountDistributionAsOf("merchant", merchant, asOf, windowSeconds)
+}
+
+// amountDistributionAsOf aggregates over a fixed, code-controlled column — never user input.
+func (c *Client) amountDistributionAsOf(column, value string, asOf time.Time, windowSeconds int) (_ int, _ float64, _ float64, err error) {
+	ctx, done := c.read("amount_distribution_as_of", value, asOf, windowSeconds)
+	defer done(&err)
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(*), COALESCE(AVG(amount), 0), STDDEV_SAMP(amount)
+		FROM events
+		WHERE %s = $1 AND NOT is_canary AND deleted_at IS NULL AND ts < $2 AND ts >= $2 - ($3 * INTERVAL '1 second')
+	`, column)
+
+	var (
+		count  int
+		mean   float64
+		stddev sql.NullFloat64
+	)
+	if err := c.db.QueryRowContext(ctx, query, value, asOf, windowSeconds).Scan(&count, &mean, &stddev); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to compute %s amount distribution: %w", column, err)
+	}
+	return count, mean, stddev.Float64, nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// ClaimArchives returns up to limit queued archivals that are due, oldest first,
+// and pushes each one's next_attempt_at out by lease, so other processors skip
+// them while this one works. A claim not settled by CompleteArchive or
+// RetryArchive is simply due again once the lease runs out.
+func (c *Client) ClaimArchives(limit int, lease time.Duration) (_ []domain.ArchiveTask, err error) {
+	ctx, done := c.write("claim_archives", limit)
+	defer done(&err)
+
+	rows, err := c.db.QueryContext(ctx, `
+		UPDATE archive_outbox o SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		FROM (
+			SELECT event_id FROM archive_outbox
+			WHERE next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE o.event_id = due.event_id
+		RETURNING o.event_id, o.producer_key, o.payload_sha256, o.payload, o.attempts
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim archives: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []domain.ArchiveTask
+	for rows.Next() {
+		var t domain.ArchiveTask
+		if err := rows.Scan(&t.EventID, &t.ProducerKey, &t.PayloadSHA256, &t.Payload, &t.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan archive: %w", err)
+		}
+		tasks = append(tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate archives: %w", err)
+	}
+	return tasks, nil
+}
+
+// CompleteArchive settles eventID's queued archival once its payload is stored
+// at s3Key: the row is deleted and the event points at the object and counts in
+// payload_refs, as if ingest had archived it. An event replaced or erased since
+// the claim has no row left and is not touched.
+func (c *Client) CompleteArchive(eventID, s3Key string) (err error) {
+	ctx, done := c.write("complete_archive", eventID)
+	defer done(&err)
+
+	_, err = c.db.ExecContext(ctx, `
+		WITH done AS (
+			DELETE FROM archive_outbox WHERE event_id = $1 RETURNING event_id
+		), ev AS (
+			UPDATE events SET s3_key = $2
+			WHERE event_id IN (SELECT event_id FROM done) AND s3_key IS NULL
+			RETURNING s3_key
+		)
+		INSERT INTO payload_refs (s3_key, ref_count, first_seen_at, last_seen_at)
+		SELECT s3_key, 1, NOW(), NOW() FROM ev
+		ON CONFLICT (s3_key) DO UPDATE SET
+			ref_count    = payload_refs.ref_count + 1,
+			last_seen_at = EXCLUDED.last_seen_at
+	`, eventID, s3Key)
+	if err != nil {
+		return fmt.Errorf("failed to complete archive: %w", err)
+	}
+	return nil
+}
+
+// RetryArchive records a failed attempt at eventID's queued archival and makes
+// it due again at next.
+func (c *Client) RetryArchive(eventID string, next time.Time, reason string) (err error) {
+	ctx, done := c.write("retry_archive", eventID)
+	defer done(&err)
+
+	_, err = c.db.ExecContext(ctx, `
+		UPDATE archive_outbox SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3
+		WHERE event_id = $1
+	`, eventID, next, reason)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule archive: %w", err)
+	}
+	return nil
+}
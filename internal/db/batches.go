@@ -0,0 +1,333 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+	"github.com/lib/pq"
+)
+
+// InsertEventBatch persists every event of an atomic batch and records the batch as
+// succeeded, all in one transaction: either all members are stored or none is.
+// Events use the same statement as InsertEvent, so a member already stored is
+// skipped and the roll-ups count each new row once. Sets batch.CreatedAt/UpdatedAt.
+func (c *Client) InsertEventBatch(batch *domain.Batch, events []*domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) (err error) {
+	ctx, done := c.begin("insert_event_batch", batchTimeout, batch.BatchID, events)
+	defer done(&err)
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, event := range events {
+		if err := insertEvent(ctx, tx, event, correlationID, payloadMode, s3Key); err != nil {
+			return fmt.Errorf("batch %s event %s: %w", batch.BatchID, event.EventID, err)
+		}
+	}
+	if err := saveBatch(ctx, tx, batch); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return nil
+}
+
+// InsertPartialBatch persists a partial batch in one transaction, each event
+// behind its own savepoint: an event Postgres rejects (a data or constraint error)
+// is rolled back alone and added to batch.Failures as "db_rejected" (or
+// "client_reference_conflict"), and the rest
+// commit. batch.Failures comes in holding the members already rejected by the
+// processor; all failures are recorded in batch_members with their reason. Sets
+// batch.Processed, Failed, Status (success when nothing failed, failed when
+// everything did, else complete) and CreatedAt/UpdatedAt. Any other error aborts the whole batch, as for
+// InsertEventBatch.
+func (c *Client) InsertPartialBatch(batch *domain.Batch, events []*domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) (err error) {
+	ctx, done := c.begin("insert_partial_batch", batchTimeout, batch.BatchID, events)
+	defer done(&err)
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	failures := append([]domain.BatchFailure(nil), batch.Failures...)
+	for _, event := range events {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT batch_member`); err != nil {
+			return fmt.Errorf("failed to set savepoint: %w", err)
+		}
+		err := insertEvent(ctx, tx, event, correlationID, payloadMode, s3Key)
+		if err == nil {
+			if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT batch_member`); err != nil {
+				return fmt.Errorf("failed to release savepoint: %w", err)
+			}
+			continue
+		}
+		if !isRowError(err) {
+			return fmt.Errorf("batch %s event %s: %w", batch.BatchID, event.EventID, err)
+		}
+		if _, rerr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT batch_member`); rerr != nil {
+			return fmt.Errorf("failed to roll back to savepoint: %w", rerr)
+		}
+		reason := "db_rejected"
+		var conflict *domain.ClientReferenceConflictError
+		if errors.As(err, &conflict) {
+			reason = "client_reference_conflict"
+		}
+		failures = append(failures, domain.BatchFailure{EventID: event.EventID, Reason: reason})
+	}
+
+	now := time.Now().UTC()
+	for _, f := range failures {
+		// The ID may be the very thing Postgres rejected (too long for the column),
+		// so it is recorded truncated to fit.
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO batch_members (batch_id, event_id, status, error_reason, updated_at)
+			VALUES ($1, LEFT($2, 255), $3, $4, $5)
+			ON CONFLICT (batch_id, event_id) DO NOTHING
+		`, batch.BatchID, f.EventID, string(domain.IdempotencyStatusFailed), f.Reason, now)
+		if err != nil {
+			return fmt.Errorf("failed to record batch member failure: %w", err)
+		}
+	}
+	batch.Failures = failures
+	batch.Failed = len(failures)
+	batch.Processed = batch.Submitted - batch.Failed
+	switch {
+	case batch.Failed == 0:
+		batch.Status = domain.BatchStatusSuccess
+	case batch.Failed >= batch.Submitted:
+		batch.Status = domain.BatchStatusFailed
+	default:
+		batch.Status = domain.BatchStatusComplete
+	}
+	if err := saveBatch(ctx, tx, batch); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return nil
+}
+
+// isRowError reports whether err is Postgres rejecting the row itself (class 22
+// data exception or 23 integrity violation), which leaves the transaction usable
+// once rolled back to a savepoint.
+func isRowError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	class := pqErr.Code.Class()
+	return class == "22" || class == "23"
+}
+
+// RecordBatchMember records the terminal status (success or failed) of one member
+// of a non-atomic batch and updates the batch's counts, creating the batch with
+// submitted members on first use. Re-recording a member's current status is a
+// no-op and a changed status (a failed member retried successfully) moves it
+// between the counts, so redeliveries never double-count. The batch turns complete
+// once processed+failed reaches submitted.
+func (c *Client) RecordBatchMember(batchID, eventID string, submitted int, status domain.IdempotencyStatus) (err error) {
+	ctx, done := c.write("record_batch_member", batchID, eventID, submitted, status)
+	defer done(&err)
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO batches (batch_id, atomic, status, submitted, created_at, updated_at)
+		VALUES ($1, FALSE, $2, $3, $4, $4)
+		ON CONFLICT (batch_id) DO NOTHING
+	`, batchID, string(domain.BatchStatusProcessing), submitted, now)
+	if err != nil {
+		return fmt.Errorf("failed to create batch: %w", err)
+	}
+	// Locking the batch row serializes members of the same batch, so the read of
+	// the member's previous status below can't race another delivery.
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM batches WHERE batch_id = $1 FOR UPDATE`, batchID); err != nil {
+		return fmt.Errorf("failed to lock batch: %w", err)
+	}
+
+	var prev sql.NullString
+	err = tx.QueryRowContext(ctx,
+		`SELECT status FROM batch_members WHERE batch_id = $1 AND event_id = $2`,
+		batchID, eventID).Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to query batch member: %w", err)
+	}
+	if prev.String == string(status) {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO batch_members (batch_id, event_id, status, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (batch_id, event_id) DO UPDATE SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at
+	`, batchID, eventID, string(status), now)
+	if err != nil {
+		return fmt.Errorf("failed to upsert batch member: %w", err)
+	}
+
+	delta := func(s domain.IdempotencyStatus) int {
+		n := 0
+		if status == s {
+			n++
+		}
+		if prev.String == string(s) {
+			n--
+		}
+		return n
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE batches SET
+			processed  = processed + $2,
+			failed     = failed + $3,
+			status     = CASE WHEN processed + $2 + failed + $3 >= submitted THEN $4 ELSE $5 END,
+			updated_at = $6
+		WHERE batch_id = $1
+	`, batchID, delta(domain.IdempotencyStatusSuccess), delta(domain.IdempotencyStatusFailed),
+		string(domain.BatchStatusComplete), string(domain.BatchStatusProcessing), now)
+	if err != nil {
+		return fmt.Errorf("failed to update batch counts: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch member: %w", err)
+	}
+	return nil
+}
+
+// SaveBatch records batch's outcome, replacing any earlier record for its ID. Used
+// for failed batches, which persist no events. Sets batch.CreatedAt/UpdatedAt.
+func (c *Client) SaveBatch(batch *domain.Batch) (err error) {
+	ctx, done := c.write("save_batch", batch.BatchID)
+	defer done(&err)
+
+	return saveBatch(ctx, c.db, batch)
+}
+
+// queryRower is satisfied by *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func saveBatch(ctx context.Context, q queryRower, batch *domain.Batch) error {
+	query := `
+		INSERT INTO batches (batch_id, atomic, partial, status, submitted, processed, failed, failed_event_id, error_reason, created_at, updated_at)
+		VALUES ($1, $2, $10, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9, $9)
+		ON CONFLICT (batch_id) DO UPDATE SET
+			atomic          = EXCLUDED.atomic,
+			partial         = EXCLUDED.partial,
+			status          = EXCLUDED.status,
+			submitted       = EXCLUDED.submitted,
+			processed       = EXCLUDED.processed,
+			failed          = EXCLUDED.failed,
+			failed_event_id = EXCLUDED.failed_event_id,
+			error_reason    = EXCLUDED.error_reason,
+			updated_at      = EXCLUDED.updated_at
+		RETURNING created_at, updated_at
+	`
+	err := q.QueryRowContext(ctx, query,
+		batch.BatchID,
+		batch.Atomic,
+		string(batch.Status),
+		batch.Submitted,
+		batch.Processed,
+		batch.Failed,
+		batch.FailedEventID,
+		batch.ErrorReason,
+		time.Now().UTC(),
+		batch.Partial,
+	).Scan(&batch.CreatedAt, &batch.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save batch: %w", err)
+	}
+	return nil
+}
+
+// GetBatch returns the recorded batch with batchID, or ErrNotFound.
+func (c *Client) GetBatch(batchID string) (_ *domain.Batch, err error) {
+	ctx, done := c.read("get_batch", batchID)
+	defer done(&err)
+
+	var row batchRow
+	err = c.db.QueryRowContext(ctx, `SELECT `+batchColumns.List()+` FROM batches WHERE batch_id = $1`, batchID).
+		Scan(batchColumns.Dest(&row)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batch: %w", err)
+	}
+	row.FailedEventID = row.failedEventID.String
+	row.ErrorReason = row.errorReason.String
+	if row.Partial {
+		if row.Failures, err = c.listBatchFailures(ctx, batchID); err != nil {
+			return nil, err
+		}
+	}
+	return &row.Batch, nil
+}
+
+// listBatchFailures returns the failed members of a partial batch, by event ID.
+func (c *Client) listBatchFailures(ctx context.Context, batchID string) ([]domain.BatchFailure, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+batchFailureColumns.List()+`
+		FROM batch_members
+		WHERE batch_id = $1 AND error_reason IS NOT NULL
+		ORDER BY event_id`, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batch failures: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []domain.BatchFailure
+	for rows.Next() {
+		var f domain.BatchFailure
+		if err := rows.Scan(batchFailureColumns.Dest(&f)...); err != nil {
+			return nil, fmt.Errorf("failed to scan batch failure: %w", err)
+		}
+		failures = append(failures, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate batch failures: %w", err)
+	}
+	return failures, nil
+}
+
+var batchFailureColumns = sqlrow.New("batch_members",
+	sqlrow.Col("event_id", func(f *domain.BatchFailure) any { return &f.EventID }),
+	sqlrow.Col("error_reason", func(f *domain.BatchFailure) any { return &f.Reason }),
+)
+
+// batchRow is a batches row; the nullable columns read into NullStrings.
+type batchRow struct {
+	domain.Batch
+	failedEventID, errorReason sql.NullString
+}
+
+var batchColumns = sqlrow.New("batches",
+	sqlrow.Col("batch_id", func(r *batchRow) any { return &r.BatchID }),
+	sqlrow.Col("atomic", func(r *batchRow) any { return &r.Atomic }),
+	sqlrow.Col("partial", func(r *batchRow) any { return &r.Partial }),
+	sqlrow.Col("status", func(r *batchRow) any { return &r.Status }),
+	sqlrow.Col("submitted", func(r *batchRow) any { return &r.Submitted }),
+	sqlrow.Col("processed", func(r *batchRow) any { return &r.Processed }),
+	sqlrow.Col("failed", func(r *batchRow) any { return &r.Failed }),
+	sqlrow.Col("failed_event_id", func(r *batchRow) any { return &r.failedEventID }),
+	sqlrow.Col("error_reason", func(r *batchRow) any { return &r.errorReason }),
+	sqlrow.Col("created_at", func(r *batchRow) any { return &r.CreatedAt }),
+	sqlrow.Col("updated_at", func(r *batchRow) any { return &r.UpdatedAt }),
+)
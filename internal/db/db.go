@@ -2,22 +2,50 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/fluxa/fluxa/internal/domain"
-	_ "github.com/lib/pq"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+	"github.com/lib/pq"
 )
 
 // Client wraps database operations
 type Client struct {
-	db *sql.DB
+	db           *sql.DB
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	metrics      ports.Metrics
+	slowQuery    time.Duration
+	logger       *logging.Logger
 }
 
-// NewClient creates a new database client
+// NewClient creates a new database client and verifies connectivity with a ping
 func NewClient(dsn string, maxConnections int) (*Client, error) {
+	client, err := Open(dsn, maxConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	// Test connection
+	if err := client.db.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return client, nil
+}
+
+// Open creates a database client without dialing. The pool connects on first use,
+// so services that can serve traffic before the DB is reachable skip the startup ping.
+func Open(dsn string, maxConnections int) (*Client, error) {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -27,12 +55,7 @@ func NewClient(dsn string, maxConnections int) (*Client, error) {
 	db.SetMaxIdleConns(5)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
-
-	return &Client{db: db}, nil
+	return &Client{db: db, readTimeout: defaultReadTimeout, writeTimeout: defaultWriteTimeout}, nil
 }
 
 // Close closes the database connection
@@ -40,17 +63,76 @@ func (c *Client) Close() error {
 	return c.db.Close()
 }
 
+// Ping checks that the pool can reach Postgres (readiness probes).
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
+// CheckSchema checks projections (sqlrow.Registered) against the live database's
+// columns, as the package tests check them against the migrations: one error per
+// projection whose table or columns are missing, e.g. after a skipped migration.
+func (c *Client) CheckSchema(ctx context.Context, projections []sqlrow.Checked) ([]error, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema()`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	defer rows.Close()
+
+	schema := sqlrow.Schema{}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, fmt.Errorf("failed to scan schema: %w", err)
+		}
+		if schema[table] == nil {
+			schema[table] = map[string]bool{}
+		}
+		schema[table][column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	return schema.CheckAll(projections), nil
+}
+
 // GetDB returns the underlying database connection (for idempotency client)
 func (c *Client) GetDB() *sql.DB {
 	return c.db
 }
 
 // InsertEvent inserts an event into the events table
-// Uses ON CONFLICT DO NOTHING to handle duplicate event_id gracefully (idempotency)
-func (c *Client) InsertEvent(event *domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Uses ON CONFLICT DO NOTHING to handle duplicate event_id gracefully (idempotency).
+// An event reusing its producer's client_reference is a
+// *domain.ClientReferenceConflictError naming the event that holds it.
+// The same statement folds newly inserted rows into merchant_stats_hourly, so a
+// redelivered duplicate never double-counts the roll-up. Canary events are
+// stored but never rolled up. Likewise, each new row referencing an offloaded
+// payload bumps that object's payload_refs count exactly once.
+func (c *Client) InsertEvent(event *domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) (err error) {
+	ctx, done := c.write("insert_event", event.EventID, correlationID, payloadMode, s3Key)
+	defer done(&err)
+
+	err = insertEvent(ctx, c.db, event, correlationID, payloadMode, s3Key)
+	var conflict *domain.ClientReferenceConflictError
+	if errors.As(err, &conflict) {
+		// Best-effort: without the holder the error still names the reference.
+		_ = c.db.QueryRowContext(ctx, `SELECT event_id FROM events WHERE producer_key = $1 AND client_reference = $2`,
+			event.ProducerKey, event.ClientReference).Scan(&conflict.ExistingEventID)
+	}
+	return err
+}
 
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertEvent(ctx context.Context, ex execer, event *domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) error {
 	metadataJSON := "{}"
 	if event.Metadata != nil {
 		bytes, err := json.Marshal(event.Metadata)
@@ -60,15 +142,69 @@ func (c *Client) InsertEvent(event *domain.Event, correlationID string, payloadM
 		metadataJSON = string(bytes)
 	}
 
+	var canonicalMerchant *string
+	if event.CanonicalMerchant != "" {
+		canonicalMerchant = &event.CanonicalMerchant
+	}
+
+	var enrichmentJSON *string
+	if len(event.Enrichment) > 0 {
+		bytes, err := json.Marshal(event.Enrichment)
+		if err != nil {
+			return fmt.Errorf("failed to marshal enrichment: %w", err)
+		}
+		s := string(bytes)
+		enrichmentJSON = &s
+	}
+
 	query := `
-		INSERT INTO events (
-			event_id, correlation_id, user_id, amount, currency, merchant, 
-			ts, metadata_json, payload_mode, s3_key, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		ON CONFLICT (event_id) DO NOTHING
+		WITH ins AS (
+			INSERT INTO events (
+				event_id, correlation_id, user_id, amount, currency, merchant,
+				ts, metadata_json, payload_mode, s3_key, created_at, canonical_merchant,
+				enrichment_json, is_canary, producer_key, client_reference, group_id, schema_version
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $20)
+			ON CONFLICT (event_id) DO NOTHING
+			RETURNING COALESCE(canonical_merchant, merchant) AS merchant, ts, amount, is_canary, s3_key
+		), refs AS (
+			INSERT INTO payload_refs (s3_key, ref_count, first_seen_at, last_seen_at)
+			SELECT s3_key, 1, NOW(), NOW() FROM ins WHERE s3_key IS NOT NULL
+			ON CONFLICT (s3_key) DO UPDATE SET
+				ref_count    = payload_refs.ref_count + 1,
+				last_seen_at = EXCLUDED.last_seen_at
+		), outbox AS (
+			INSERT INTO archive_outbox (event_id, producer_key, payload_sha256, payload)
+			SELECT $1, $15, $18, $19 FROM ins WHERE $19::bytea IS NOT NULL
+		)
+		INSERT INTO merchant_stats_hourly (merchant, bucket, event_count, total_amount, max_amount)
+		SELECT merchant, date_trunc('hour', ts), 1, amount, amount FROM ins WHERE NOT is_canary
+		ON CONFLICT (merchant, bucket) DO UPDATE SET
+			event_count  = merchant_stats_hourly.event_count + 1,
+			total_amount = merchant_stats_hourly.total_amount + EXCLUDED.total_amount,
+			max_amount   = GREATEST(merchant_stats_hourly.max_amount, EXCLUDED.max_amount)
 	`
 
-	_, err := c.db.ExecContext(
+	// A payload ingest failed to archive is queued with the row, so it is
+	// archived exactly when the event exists (internal/archiveretry).
+	var archiveSHA256 *string
+	if event.PendingArchive != nil {
+		sum := sha256.Sum256(event.PendingArchive)
+		h := hex.EncodeToString(sum[:])
+		archiveSHA256 = &h
+	}
+
+	var clientReference, groupID, schemaVersion *string
+	if event.ClientReference != "" {
+		clientReference = &event.ClientReference
+	}
+	if event.GroupID != "" {
+		groupID = &event.GroupID
+	}
+	if event.SchemaVersion != "" {
+		schemaVersion = &event.SchemaVersion
+	}
+
+	_, err := ex.ExecContext(
 		ctx,
 		query,
 		event.EventID,
@@ -82,7 +218,20 @@ func (c *Client) InsertEvent(event *domain.Event, correlationID string, payloadM
 		string(payloadMode),
 		s3Key,
 		time.Now().UTC(),
+		canonicalMerchant,
+		enrichmentJSON,
+		event.Canary,
+		event.ProducerKey,
+		clientReference,
+		groupID,
+		archiveSHA256,
+		event.PendingArchive,
+		schemaVersion,
 	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == clientReferenceIndex {
+		return &domain.ClientReferenceConflictError{EventID: event.EventID, ClientReference: event.ClientReference, Err: err}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to insert event: %w", err)
 	}
@@ -90,51 +239,177 @@ func (c *Client) InsertEvent(event *domain.Event, correlationID string, payloadM
 	return nil
 }
 
-// GetEventByID retrieves an event by event_id
-func (c *Client) GetEventByID(eventID string) (*domain.EventRecord, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// EventFields are the fields GetEventByID can project, named as in the JSON of
+// domain.EventRecord.
+var EventFields = []string{
+	"event_id", "correlation_id", "user_id", "amount", "currency", "merchant",
+	"canonical_merchant", "timestamp", "metadata", "enrichment", "canary",
+	"payload_mode", "s3_key", "created_at", "version", "client_reference",
+	"group_id", "schema_version",
+}
 
-	query := `
-		SELECT
-			event_id, correlation_id, user_id, amount, currency, merchant,
-			ts, metadata_json, payload_mode, s3_key, created_at
-		FROM events
-		WHERE event_id = $1
-	`
+// eventColumns maps EventFields to their events column.
+var eventColumns = map[string]string{
+	"event_id":           "event_id",
+	"correlation_id":     "correlation_id",
+	"user_id":            "user_id",
+	"amount":             "amount",
+	"currency":           "currency",
+	"merchant":           "merchant",
+	"canonical_merchant": "canonical_merchant",
+	"timestamp":          "ts",
+	"metadata":           "metadata_json",
+	"enrichment":         "enrichment_json",
+	"canary":             "is_canary",
+	"payload_mode":       "payload_mode",
+	"s3_key":             "s3_key",
+	"created_at":         "created_at",
+	"version":            "version",
+	"client_reference":   "client_reference",
+	"group_id":           "group_id",
+	"schema_version":     "schema_version",
+}
 
-	var record domain.EventRecord
-	var metadataJSON sql.NullString
-	var s3Key sql.NullString
-
-	err := c.db.QueryRowContext(ctx, query, eventID).Scan(
-		&record.EventID,
-		&record.CorrelationID,
-		&record.UserID,
-		&record.Amount,
-		&record.Currency,
-		&record.Merchant,
-		&record.Timestamp,
-		&metadataJSON,
-		&record.PayloadMode,
-		&s3Key,
-		&record.CreatedAt,
-	)
+// GetEventByID retrieves an event by event_id. With fields (see EventFields) only
+// those columns are read, leaving the rest of the record zero; unselected JSON
+// columns are not decoded. No fields reads them all. A soft-deleted event is
+// ErrNotFound.
+func (c *Client) GetEventByID(eventID string, fields ...string) (_ *domain.EventRecord, err error) {
+	ctx, done := c.read("get_event_by_id", eventID, fields)
+	defer done(&err)
+
+	scan, columns, err := newEventScan(fields)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + strings.Join(columns, ", ") + ` FROM events WHERE event_id = $1 AND deleted_at IS NULL`
+	err = c.db.QueryRowContext(ctx, query, eventID).Scan(scan.dest...)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to query event: %w", err)
 	}
+	return scan.record()
+}
+
+// EventPersisted reports whether eventID has an events row, soft-deleted or not.
+func (c *Client) EventPersisted(eventID string) (_ bool, err error) {
+	ctx, done := c.read("event_persisted", eventID)
+	defer done(&err)
+
+	var exists bool
+	if err = c.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM events WHERE event_id = $1)`, eventID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check event: %w", err)
+	}
+	return exists, nil
+}
+
+// GetEventProducerKey returns the producer_key of eventID, soft-deleted or not,
+// or ErrNotFound.
+func (c *Client) GetEventProducerKey(eventID string) (_ string, err error) {
+	ctx, done := c.read("get_event_producer_key", eventID)
+	defer done(&err)
+
+	var key string
+	err = c.db.QueryRowContext(ctx, `SELECT producer_key FROM events WHERE event_id = $1`, eventID).Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query event producer: %w", err)
+	}
+	return key, nil
+}
+
+// eventScan reads selected EventFields of events rows. Its destinations are reused
+// across rows; record decodes the current one.
+type eventScan struct {
+	rec  domain.EventRecord
+	dest []interface{}
+
+	// Nullable columns, decoded into the record by record().
+	metadataJSON, s3Key, canonicalMerchant, enrichmentJSON, clientReference, groupID, schemaVersion sql.NullString
+}
 
-	if metadataJSON.Valid {
-		if err := json.Unmarshal([]byte(metadataJSON.String), &record.Metadata); err != nil {
+// newEventScan prepares a scan of fields (all EventFields when empty) and returns
+// the matching columns, in order.
+func newEventScan(fields []string) (*eventScan, []string, error) {
+	if len(fields) == 0 {
+		fields = EventFields
+	}
+	s := &eventScan{dest: make([]interface{}, len(fields))}
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		col, ok := eventColumns[field]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown event field %q", field)
+		}
+		columns[i] = col
+		switch field {
+		case "event_id":
+			s.dest[i] = &s.rec.EventID
+		case "correlation_id":
+			s.dest[i] = &s.rec.CorrelationID
+		case "user_id":
+			s.dest[i] = &s.rec.UserID
+		case "amount":
+			s.dest[i] = &s.rec.Amount
+		case "currency":
+			s.dest[i] = &s.rec.Currency
+		case "merchant":
+			s.dest[i] = &s.rec.Merchant
+		case "canonical_merchant":
+			s.dest[i] = &s.canonicalMerchant
+		case "timestamp":
+			s.dest[i] = &s.rec.Timestamp
+		case "metadata":
+			s.dest[i] = &s.metadataJSON
+		case "enrichment":
+			s.dest[i] = &s.enrichmentJSON
+		case "canary":
+			s.dest[i] = &s.rec.Canary
+		case "payload_mode":
+			s.dest[i] = &s.rec.PayloadMode
+		case "s3_key":
+			s.dest[i] = &s.s3Key
+		case "created_at":
+			s.dest[i] = &s.rec.CreatedAt
+		case "version":
+			s.dest[i] = &s.rec.Version
+		case "client_reference":
+			s.dest[i] = &s.clientReference
+		case "group_id":
+			s.dest[i] = &s.groupID
+		case "schema_version":
+			s.dest[i] = &s.schemaVersion
+		}
+	}
+	return s, columns, nil
+}
+
+// record returns a copy of the scanned row with its JSON columns decoded.
+func (s *eventScan) record() (*domain.EventRecord, error) {
+	record := s.rec
+	if s.metadataJSON.Valid {
+		if err := json.Unmarshal([]byte(s.metadataJSON.String), &record.Metadata); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 		}
 	}
 
-	if s3Key.Valid {
-		record.S3Key = &s3Key.String
+	if s.s3Key.Valid {
+		key := s.s3Key.String
+		record.S3Key = &key
+	}
+	record.CanonicalMerchant = s.canonicalMerchant.String
+	record.ClientReference = s.clientReference.String
+	record.GroupID = s.groupID.String
+	record.SchemaVersion = s.schemaVersion.String
+
+	if s.enrichmentJSON.Valid {
+		if err := json.Unmarshal([]byte(s.enrichmentJSON.String), &record.Enrichment); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal enrichment: %w", err)
+		}
 	}
 
 	return &record, nil
@@ -143,11 +418,18 @@ func (c *Client) GetEventByID(eventID string) (*domain.EventRecord, error) {
 // ErrNotFound is returned when an event is not found
 var ErrNotFound = fmt.Errorf("event not found")
 
+// clientReferenceIndex enforces client_reference uniqueness (migration 024).
+const clientReferenceIndex = "idx_events_client_reference"
+
+// ErrVersionConflict is returned by event amendments whose expected version (the
+// caller's If-Match) is no longer the stored one: someone else amended it first.
+var ErrVersionConflict = fmt.Errorf("event version conflict")
+
 // InsertFraudFlag inserts a fraud flag into the fraud_flags table.
 // Uses ON CONFLICT DO NOTHING so repeated calls with the same flag_id are safe.
-func (c *Client) InsertFraudFlag(flag *domain.FraudFlag) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+func (c *Client) InsertFraudFlag(flag *domain.FraudFlag) (err error) {
+	ctx, done := c.write("insert_fraud_flag", flag.EventID, flag.RuleName)
+	defer done(&err)
 
 	query := `
 		INSERT INTO fraud_flags (flag_id, event_id, user_id, rule_name, rule_value, ml_score, flagged_at)
@@ -155,7 +437,7 @@ func (c *Client) InsertFraudFlag(flag *domain.FraudFlag) error {
 		ON CONFLICT (flag_id) DO NOTHING
 	`
 
-	_, err := c.db.ExecContext(ctx, query,
+	_, err = c.db.ExecContext(ctx, query,
 		flag.FlagID,
 		flag.EventID,
 		flag.UserID,
@@ -171,16 +453,17 @@ func (c *Client) InsertFraudFlag(flag *domain.FraudFlag) error {
 }
 
 // GetRecentFraudEvents returns the most recent fraud flags joined with event data, newest first.
-// Used to replay history on SSE connect.
-func (c *Client) GetRecentFraudEvents(limit int) ([]*domain.FraudEvent, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Used to replay history on SSE connect. Flags on canary and soft-deleted events
+// are excluded.
+func (c *Client) GetRecentFraudEvents(limit int) (_ []*domain.FraudEvent, err error) {
+	ctx, done := c.read("get_recent_fraud_events", limit)
+	defer done(&err)
 
 	query := `
-		SELECT ff.flag_id, ff.event_id, e.correlation_id, ff.user_id, e.amount, e.currency, e.merchant,
-		       ff.rule_name, ff.rule_value, ff.flagged_at, ff.ml_score
+		SELECT ` + fraudEventSelect + `
 		FROM fraud_flags ff
 		JOIN events e ON ff.event_id = e.event_id
+		WHERE NOT e.is_canary AND e.deleted_at IS NULL
 		ORDER BY ff.flagged_at DESC
 		LIMIT $1
 	`
@@ -191,32 +474,21 @@ func (c *Client) GetRecentFraudEvents(limit int) ([]*domain.FraudEvent, error) {
 	}
 	defer rows.Close()
 
-	var events []*domain.FraudEvent
-	for rows.Next() {
-		fe := &domain.FraudEvent{}
-		if err := rows.Scan(
-			&fe.FlagID, &fe.EventID, &fe.CorrelationID, &fe.UserID, &fe.Amount, &fe.Currency, &fe.Merchant,
-			&fe.RuleName, &fe.RuleValue, &fe.FlaggedAt, &fe.MlScore,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan fraud event: %w", err)
-		}
-		events = append(events, fe)
-	}
-	return events, rows.Err()
+	return scanFraudEvents(rows)
 }
 
 // GetFraudEventsSince returns fraud flags with flagged_at strictly after since, oldest first.
-// Used to poll for new events in the SSE loop.
-func (c *Client) GetFraudEventsSince(since time.Time) ([]*domain.FraudEvent, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Used to poll for new events in the SSE loop. Flags on canary and soft-deleted
+// events are excluded.
+func (c *Client) GetFraudEventsSince(since time.Time) (_ []*domain.FraudEvent, err error) {
+	ctx, done := c.read("get_fraud_events_since", since)
+	defer done(&err)
 
 	query := `
-		SELECT ff.flag_id, ff.event_id, e.correlation_id, ff.user_id, e.amount, e.currency, e.merchant,
-		       ff.rule_name, ff.rule_value, ff.flagged_at, ff.ml_score
+		SELECT ` + fraudEventSelect + `
 		FROM fraud_flags ff
 		JOIN events e ON ff.event_id = e.event_id
-		WHERE ff.flagged_at > $1
+		WHERE ff.flagged_at > $1 AND NOT e.is_canary AND e.deleted_at IS NULL
 		ORDER BY ff.flagged_at ASC
 	`
 
@@ -226,13 +498,34 @@ func (c *Client) GetFraudEventsSince(since time.Time) ([]*domain.FraudEvent, err
 	}
 	defer rows.Close()
 
+	return scanFraudEvents(rows)
+}
+
+// A FraudEvent is a fraud_flags row (ff) joined with its event (e).
+var (
+	fraudEventFlagColumns = sqlrow.New("fraud_flags",
+		sqlrow.Col("flag_id", func(fe *domain.FraudEvent) any { return &fe.FlagID }),
+		sqlrow.Col("event_id", func(fe *domain.FraudEvent) any { return &fe.EventID }),
+		sqlrow.Col("user_id", func(fe *domain.FraudEvent) any { return &fe.UserID }),
+		sqlrow.Col("rule_name", func(fe *domain.FraudEvent) any { return &fe.RuleName }),
+		sqlrow.Col("rule_value", func(fe *domain.FraudEvent) any { return &fe.RuleValue }),
+		sqlrow.Col("flagged_at", func(fe *domain.FraudEvent) any { return &fe.FlaggedAt }),
+		sqlrow.Col("ml_score", func(fe *domain.FraudEvent) any { return &fe.MlScore }),
+	)
+	fraudEventEventColumns = sqlrow.New("events",
+		sqlrow.Col("correlation_id", func(fe *domain.FraudEvent) any { return &fe.CorrelationID }),
+		sqlrow.Col("amount", func(fe *domain.FraudEvent) any { return &fe.Amount }),
+		sqlrow.Col("currency", func(fe *domain.FraudEvent) any { return &fe.Currency }),
+		sqlrow.Col("merchant", func(fe *domain.FraudEvent) any { return &fe.Merchant }),
+	)
+	fraudEventSelect = fraudEventFlagColumns.Qualified("ff") + ", " + fraudEventEventColumns.Qualified("e")
+)
+
+func scanFraudEvents(rows *sql.Rows) ([]*domain.FraudEvent, error) {
 	var events []*domain.FraudEvent
 	for rows.Next() {
 		fe := &domain.FraudEvent{}
-		if err := rows.Scan(
-			&fe.FlagID, &fe.EventID, &fe.CorrelationID, &fe.UserID, &fe.Amount, &fe.Currency, &fe.Merchant,
-			&fe.RuleName, &fe.RuleValue, &fe.FlaggedAt, &fe.MlScore,
-		); err != nil {
+		if err := rows.Scan(append(fraudEventFlagColumns.Dest(fe), fraudEventEventColumns.Dest(fe)...)...); err != nil {
 			return nil, fmt.Errorf("failed to scan fraud event: %w", err)
 		}
 		events = append(events, fe)
@@ -241,20 +534,23 @@ func (c *Client) GetFraudEventsSince(since time.Time) ([]*domain.FraudEvent, err
 }
 
 // CountRecentEvents returns the number of events for a user within the last windowSeconds seconds.
-// Used by the fraud engine for velocity checks.
-func (c *Client) CountRecentEvents(userID string, windowSeconds int) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Used by the fraud engine for velocity checks. Canary and soft-deleted events are
+// not counted.
+func (c *Client) CountRecentEvents(userID string, windowSeconds int) (_ int, err error) {
+	ctx, done := c.read("count_recent_events", userID, windowSeconds)
+	defer done(&err)
 
 	query := `
 		SELECT COUNT(*)
 		FROM events
 		WHERE user_id = $1
+		  AND NOT is_canary
+		  AND deleted_at IS NULL
 		  AND created_at >= NOW() - ($2 * INTERVAL '1 second')
 	`
 
 	var count int
-	err := c.db.QueryRowContext(ctx, query, userID, windowSeconds).Scan(&count)
+	err = c.db.QueryRowContext(ctx, query, userID, windowSeconds).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count recent events: %w", err)
 	}
@@ -264,13 +560,15 @@ func (c *Client) CountRecentEvents(userID string, windowSeconds int) (int, error
 // CountUserEventsAsOf counts the user's events with ts in (asOf-window, asOf].
 // Transaction-time, point-in-time aggregate for the ML feature builder — reproducible
 // offline and online (unlike CountRecentEvents which keys on created_at/NOW()).
-func (c *Client) CountUserEventsAsOf(userID string, asOf time.Time, windowSeconds int) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Canary and soft-deleted events are excluded from this and the other *AsOf
+// aggregates.
+func (c *Client) CountUserEventsAsOf(userID string, asOf time.Time, windowSeconds int) (_ int, err error) {
+	ctx, done := c.read("count_user_events_as_of", userID, asOf, windowSeconds)
+	defer done(&err)
 	var n int
-	err := c.db.QueryRowContext(ctx,
+	err = c.db.QueryRowContext(ctx,
 		`SELECT count(*) FROM events
-		 WHERE user_id = $1 AND ts <= $2 AND ts > $2 - ($3 * INTERVAL '1 second')`,
+		 WHERE user_id = $1 AND NOT is_canary AND deleted_at IS NULL AND ts <= $2 AND ts > $2 - ($3 * INTERVAL '1 second')`,
 		userID, asOf, windowSeconds).Scan(&n)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count user events as-of: %w", err)
@@ -281,19 +579,19 @@ func (c *Client) CountUserEventsAsOf(userID string, asOf time.Time, windowSecond
 // UserAmountStatsAsOf returns the sum and max amount over the window ending at asOf,
 // plus the ts of the user's most recent event strictly before asOf (zero time if none).
 func (c *Client) UserAmountStatsAsOf(userID string, asOf time.Time, windowSeconds int) (sum, max float64, prevTs time.Time, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	ctx, done := c.read("user_amount_stats_as_of", userID, asOf, windowSeconds)
+	defer done(&err)
 	err = c.db.QueryRowContext(ctx,
 		`SELECT COALESCE(SUM(amount),0), COALESCE(MAX(amount),0)
 		 FROM events
-		 WHERE user_id = $1 AND ts <= $2 AND ts > $2 - ($3 * INTERVAL '1 second')`,
+		 WHERE user_id = $1 AND NOT is_canary AND deleted_at IS NULL AND ts <= $2 AND ts > $2 - ($3 * INTERVAL '1 second')`,
 		userID, asOf, windowSeconds).Scan(&sum, &max)
 	if err != nil {
 		return 0, 0, time.Time{}, fmt.Errorf("failed to compute user amount stats: %w", err)
 	}
 	var pt sql.NullTime
 	if err = c.db.QueryRowContext(ctx,
-		`SELECT MAX(ts) FROM events WHERE user_id = $1 AND ts < $2`,
+		`SELECT MAX(ts) FROM events WHERE user_id = $1 AND NOT is_canary AND deleted_at IS NULL AND ts < $2`,
 		userID, asOf).Scan(&pt); err != nil {
 		return 0, 0, time.Time{}, fmt.Errorf("failed to fetch prev event ts: %w", err)
 	}
@@ -1,14 +1,100 @@
 package db
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/fluxa/fluxa/internal/domain"
-	_ "github.com/lib/pq"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+	"github.com/lib/pq"
 )
 
+// TestProjectionsMatchMigrations checks every column the package selects against
+// the schema the migrations build. It needs no database.
+func TestProjectionsMatchMigrations(t *testing.T) {
+	schema, err := sqlrow.LoadSchema("../../migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range schema.CheckAll(sqlrow.Registered()) {
+		t.Error(err)
+	}
+	columns := make([]string, 0, len(eventColumns))
+	for _, col := range eventColumns {
+		columns = append(columns, col)
+	}
+	if err := schema.Check("events", columns...); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestTimeouts_AreRetryable needs no database: it checks how the read/write
+// helpers and classify report an expired deadline or a server-side cancel.
+func TestTimeouts_AreRetryable(t *testing.T) {
+	metrics := fluxatest.NewMetrics()
+	c := (&Client{}).WithTimeouts(time.Nanosecond, time.Second).WithMetrics(metrics)
+
+	ctx, done := c.read("get_batch")
+	<-ctx.Done()
+	err := fmt.Errorf("failed to query batch: %w", errors.New("driver: bad connection"))
+	done(&err)
+	var retryable *domain.RetryableError
+	if !errors.As(err, &retryable) || retryable.Reason != "db_timeout" {
+		t.Errorf("expired read returned %v, want a db_timeout RetryableError", err)
+	}
+
+	err = c.classify("stream_export_events", fmt.Errorf("failed to query export events: %w", &pq.Error{Code: "57014"}))
+	if !errors.As(err, &retryable) {
+		t.Errorf("statement_timeout cancel returned %v, want a RetryableError", err)
+	}
+	if err := c.classify("stream_export_events", &pq.Error{Code: "23505"}); errors.As(err, &retryable) {
+		t.Errorf("unique violation classified as a timeout: %v", err)
+	}
+
+	_, done = c.write("save_batch")
+	err = errors.New("boom")
+	done(&err)
+	if errors.As(err, &retryable) {
+		t.Errorf("write within its deadline classified as a timeout: %v", err)
+	}
+
+	if n := metrics.Counter(metricdef.DBTimeoutsTotal, "get_batch"); n != 1 {
+		t.Errorf("db_timeouts_total{get_batch} = %d, want 1", n)
+	}
+	if n := metrics.Counter(metricdef.DBTimeoutsTotal, "stream_export_events"); n != 1 {
+		t.Errorf("db_timeouts_total{stream_export_events} = %d, want 1", n)
+	}
+}
+
+func TestSlowQueryLog_CountsAndRedacts(t *testing.T) {
+	metrics := fluxatest.NewMetrics()
+	c := (&Client{}).WithMetrics(metrics).WithSlowQueryLog(20*time.Millisecond, logging.NewLogger("test", ""))
+
+	c.logIfSlow("get_top_merchants", nil, 5*time.Millisecond)
+	c.logIfSlow("get_top_merchants", nil, 20*time.Millisecond)
+	if n := metrics.Counter(metricdef.SlowQueriesTotal, "get_top_merchants"); n != 1 {
+		t.Errorf("slow_queries_total{get_top_merchants} = %d, want 1", n)
+	}
+
+	var s3Key *string
+	since := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	got := summarizeParams([]interface{}{"user-secret", since, 50, []string{"a", "b"}, s3Key, domain.PayloadModeInline})
+	want := "$1=string(11) $2=2026-10-16T12:00:00Z $3=50 $4=[]string(2) $5=null $6=domain.PayloadMode(6)"
+	if got != want {
+		t.Errorf("summarizeParams = %q, want %q", got, want)
+	}
+}
+
 func getTestDB(t *testing.T) *Client {
 	t.Helper()
 	dsn := "host=localhost port=5432 user=fluxa_user password=fluxa_password dbname=fluxa sslmode=disable"
@@ -312,3 +398,693 @@ func TestUserAmountStatsAsOf(t *testing.T) {
 		t.Errorf("expected prevTs ~base, got %v (base %v)", prevTs, base)
 	}
 }
+
+func TestInsertEvent_MaintainsMerchantStats(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	ts := time.Now().UTC().Truncate(time.Second)
+	merchant := fmt.Sprintf("test-merchant-stats-%d", ts.UnixNano())
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM merchant_stats_hourly WHERE merchant = $1", merchant)
+		_, _ = c.GetDB().Exec("DELETE FROM events WHERE merchant = $1", merchant)
+	}()
+
+	for i, amount := range []float64{10, 30} {
+		ev := &domain.Event{
+			EventID:   fmt.Sprintf("%s-%d", merchant, i),
+			UserID:    "test-user-stats",
+			Amount:    amount,
+			Currency:  "USD",
+			Merchant:  merchant,
+			Timestamp: ts,
+		}
+		if err := c.InsertEvent(ev, "corr-stats", domain.PayloadModeInline, nil); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+		// A redelivered duplicate must not double-count the roll-up.
+		if err := c.InsertEvent(ev, "corr-stats", domain.PayloadModeInline, nil); err != nil {
+			t.Fatalf("InsertEvent (duplicate): %v", err)
+		}
+	}
+
+	buckets, err := c.GetMerchantStatsBuckets(merchant, ts.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetMerchantStatsBuckets: %v", err)
+	}
+	if len(buckets) != 1 {
+		t.Fatalf("expected 1 hourly bucket, got %d", len(buckets))
+	}
+	if b := buckets[0]; b.EventCount != 2 || b.TotalAmount != 40 || b.MaxAmount != 30 {
+		t.Errorf("bucket = %+v, want count=2 total=40 max=30", b)
+	}
+}
+
+func TestInsertEvent_CanaryExcludedFromAggregates(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	ts := time.Now().UTC().Truncate(time.Second)
+	merchant := fmt.Sprintf("test-merchant-canary-%d", ts.UnixNano())
+	userID := merchant + "-user"
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM merchant_stats_hourly WHERE merchant = $1", merchant)
+		_, _ = c.GetDB().Exec("DELETE FROM events WHERE merchant = $1", merchant)
+	}()
+
+	ev := &domain.Event{
+		EventID:   merchant + "-0",
+		UserID:    userID,
+		Amount:    42,
+		Currency:  "USD",
+		Merchant:  merchant,
+		Timestamp: ts,
+		Canary:    true,
+	}
+	if err := c.InsertEvent(ev, "corr-canary", domain.PayloadModeInline, nil); err != nil {
+		t.Fatalf("InsertEvent: %v", err)
+	}
+
+	rec, err := c.GetEventByID(ev.EventID)
+	if err != nil {
+		t.Fatalf("GetEventByID: %v", err)
+	}
+	if !rec.Canary {
+		t.Error("stored record lost the canary flag")
+	}
+
+	if n, err := c.CountUserEventsAsOf(userID, ts, 3600); err != nil || n != 0 {
+		t.Errorf("CountUserEventsAsOf = %d, %v; want 0 (canary excluded)", n, err)
+	}
+	buckets, err := c.GetMerchantStatsBuckets(merchant, ts.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetMerchantStatsBuckets: %v", err)
+	}
+	if len(buckets) != 0 {
+		t.Errorf("canary event was rolled up: %+v", buckets)
+	}
+}
+
+func TestGetEventByID_ProjectsFields(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	ev := domain.NewEvent(fmt.Sprintf("test-db-proj-%d", time.Now().UnixNano()), "test-user-proj", 12.5, "EUR", "ProjMerchant",
+		time.Now().UTC().Truncate(time.Second), map[string]interface{}{"k": "v"})
+	if err := c.InsertEvent(ev, "corr-proj", domain.PayloadModeInline, nil); err != nil {
+		t.Fatalf("InsertEvent: %v", err)
+	}
+
+	rec, err := c.GetEventByID(ev.EventID, "event_id", "amount")
+	if err != nil {
+		t.Fatalf("GetEventByID: %v", err)
+	}
+	if rec.EventID != ev.EventID || rec.Amount != 12.5 {
+		t.Errorf("projected record = %+v, want event_id and amount", rec)
+	}
+	if rec.Currency != "" || rec.Metadata != nil || !rec.Timestamp.IsZero() {
+		t.Errorf("unselected fields were read: %+v", rec)
+	}
+
+	if _, err := c.GetEventByID(ev.EventID, "nope"); err == nil {
+		t.Error("GetEventByID accepted an unknown field")
+	}
+}
+
+func TestInsertEvent_CountsPayloadRefs(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	ts := time.Now().UTC().Truncate(time.Second)
+	prefix := fmt.Sprintf("test-payload-refs-%d", ts.UnixNano())
+	key := domain.PayloadKey(prefix)
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM payload_refs WHERE s3_key = $1", key)
+		_, _ = c.GetDB().Exec("DELETE FROM merchant_stats_hourly WHERE merchant = $1", prefix)
+		_, _ = c.GetDB().Exec("DELETE FROM events WHERE merchant = $1", prefix)
+	}()
+
+	for i := 0; i < 2; i++ {
+		ev := &domain.Event{
+			EventID:   fmt.Sprintf("%s-%d", prefix, i),
+			UserID:    "test-user-refs",
+			Amount:    5,
+			Currency:  "USD",
+			Merchant:  prefix,
+			Timestamp: ts,
+		}
+		if err := c.InsertEvent(ev, "corr-refs", domain.PayloadModeS3, &key); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+		// Redelivery must not bump the count.
+		if err := c.InsertEvent(ev, "corr-refs", domain.PayloadModeS3, &key); err != nil {
+			t.Fatalf("InsertEvent (duplicate): %v", err)
+		}
+	}
+
+	n, err := c.PayloadRefCount(key)
+	if err != nil {
+		t.Fatalf("PayloadRefCount: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("ref_count = %d, want 2", n)
+	}
+}
+
+func TestListFraudFlags_AndNotificationAudit(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	suffix := fmt.Sprintf("notify-%d", time.Now().UnixNano())
+	seeded := seedEventAndFlag(t, c, suffix, 900, time.Now().UTC().Add(-time.Minute))
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM notification_audit WHERE event_id = $1", seeded.EventID)
+		_, _ = c.GetDB().Exec("DELETE FROM fraud_flags WHERE event_id = $1", seeded.EventID)
+		_, _ = c.GetDB().Exec("DELETE FROM events WHERE event_id = $1", seeded.EventID)
+	}()
+
+	flags, err := c.ListFraudFlags(seeded.EventID)
+	if err != nil {
+		t.Fatalf("ListFraudFlags: %v", err)
+	}
+	if len(flags) != 1 || flags[0].FlagID != seeded.FlagID || flags[0].RuleName != "amount_threshold" {
+		t.Fatalf("ListFraudFlags = %+v, want the seeded flag", flags)
+	}
+	if none, err := c.ListFraudFlags("test-no-such-event"); err != nil || len(none) != 0 {
+		t.Errorf("ListFraudFlags(unknown) = %v, %v; want empty", none, err)
+	}
+
+	failed := "publish alert: channel closed"
+	for _, a := range []*domain.NotificationAudit{
+		{EventID: seeded.EventID, Actor: "alice", Channel: "alerts", Error: &failed},
+		{EventID: seeded.EventID, Actor: "bob", Reason: "consumer lost messages", Channel: "alerts", Notifications: 1},
+	} {
+		if err := c.InsertNotificationAudit(a); err != nil {
+			t.Fatalf("InsertNotificationAudit: %v", err)
+		}
+		if a.AuditID == 0 || a.TriggeredAt.IsZero() {
+			t.Errorf("InsertNotificationAudit did not populate id/time: %+v", a)
+		}
+	}
+	audits, err := c.ListNotificationAudits(seeded.EventID)
+	if err != nil {
+		t.Fatalf("ListNotificationAudits: %v", err)
+	}
+	if len(audits) != 2 || audits[0].Actor != "bob" || audits[0].Notifications != 1 ||
+		audits[1].Error == nil || *audits[1].Error != failed {
+		t.Errorf("ListNotificationAudits = %+v, want bob then alice (with error)", audits)
+	}
+}
+
+func TestInsertEventBatch_AllOrNothing(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	suffix := fmt.Sprintf("batch-%d", time.Now().UnixNano())
+	newEvent := func(id string) *domain.Event {
+		return &domain.Event{EventID: id, UserID: "test-user-batch", Amount: 10, Currency: "USD", Merchant: "TestMerchant", Timestamp: time.Now().UTC()}
+	}
+	ok := []*domain.Event{newEvent("test-batch-a-" + suffix), newEvent("test-batch-b-" + suffix)}
+	// An over-long event_id fails the insert part-way through the batch.
+	bad := []*domain.Event{newEvent("test-batch-c-" + suffix), newEvent(strings.Repeat("x", 300))}
+	defer func() {
+		for _, e := range append(ok, bad[0]) {
+			_, _ = c.GetDB().Exec("DELETE FROM events WHERE event_id = $1", e.EventID)
+		}
+		_, _ = c.GetDB().Exec("DELETE FROM batches WHERE batch_id LIKE $1", "%"+suffix)
+	}()
+
+	batch := &domain.Batch{BatchID: "ok-" + suffix, Atomic: true, Status: domain.BatchStatusSuccess, Submitted: len(ok), Processed: len(ok)}
+	if err := c.InsertEventBatch(batch, ok, "corr-"+suffix, domain.PayloadModeInline, nil); err != nil {
+		t.Fatalf("InsertEventBatch: %v", err)
+	}
+	for _, e := range ok {
+		if _, err := c.GetEventByID(e.EventID); err != nil {
+			t.Errorf("member %s not stored: %v", e.EventID, err)
+		}
+	}
+	got, err := c.GetBatch(batch.BatchID)
+	if err != nil || got.Status != domain.BatchStatusSuccess || got.Processed != 2 || !got.Atomic {
+		t.Fatalf("GetBatch = %+v, %v; want atomic success with 2 events", got, err)
+	}
+
+	failing := &domain.Batch{BatchID: "bad-" + suffix, Atomic: true, Status: domain.BatchStatusSuccess, Submitted: len(bad), Processed: len(bad)}
+	if err := c.InsertEventBatch(failing, bad, "corr-"+suffix, domain.PayloadModeInline, nil); err == nil {
+		t.Fatal("InsertEventBatch with an invalid member succeeded")
+	}
+	if _, err := c.GetEventByID(bad[0].EventID); err != ErrNotFound {
+		t.Errorf("valid member of a failed batch was persisted (err=%v)", err)
+	}
+	if _, err := c.GetBatch(failing.BatchID); err != ErrNotFound {
+		t.Errorf("failed batch recorded as success (err=%v)", err)
+	}
+
+	failing.Status, failing.ErrorReason, failing.FailedEventID = domain.BatchStatusFailed, "db_insert_failed", bad[1].EventID
+	if err := c.SaveBatch(failing); err != nil {
+		t.Fatalf("SaveBatch: %v", err)
+	}
+	if got, err := c.GetBatch(failing.BatchID); err != nil || got.Status != domain.BatchStatusFailed || got.ErrorReason != "db_insert_failed" {
+		t.Errorf("GetBatch(failed) = %+v, %v", got, err)
+	}
+}
+
+func TestInsertPartialBatch_RollsBackBadRowsOnly(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	suffix := fmt.Sprintf("partial-%d", time.Now().UnixNano())
+	newEvent := func(id string) *domain.Event {
+		return &domain.Event{EventID: id, UserID: "test-user-batch", Amount: 10, Currency: "USD", Merchant: "TestMerchant", Timestamp: time.Now().UTC()}
+	}
+	good := newEvent("test-partial-a-" + suffix)
+	// An over-long event_id is a data error: its savepoint is rolled back.
+	bad := newEvent(strings.Repeat("x", 300))
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM events WHERE event_id = $1", good.EventID)
+		_, _ = c.GetDB().Exec("DELETE FROM batches WHERE batch_id = $1", "partial-"+suffix)
+	}()
+
+	batch := &domain.Batch{BatchID: "partial-" + suffix, Atomic: true, Partial: true, Submitted: 3,
+		Failures: []domain.BatchFailure{{EventID: "test-partial-invalid-" + suffix, Reason: "validation_error"}}}
+	if err := c.InsertPartialBatch(batch, []*domain.Event{good, bad}, "corr-"+suffix, domain.PayloadModeInline, nil); err != nil {
+		t.Fatalf("InsertPartialBatch: %v", err)
+	}
+	if _, err := c.GetEventByID(good.EventID); err != nil {
+		t.Errorf("good member not stored: %v", err)
+	}
+	got, err := c.GetBatch(batch.BatchID)
+	if err != nil || got.Status != domain.BatchStatusComplete || got.Processed != 1 || got.Failed != 2 || !got.Partial || len(got.Failures) != 2 {
+		t.Fatalf("GetBatch = %+v, %v; want complete with 1 processed and 2 failures", got, err)
+	}
+
+	// With every member failing, the batch is failed rather than complete.
+	allBad := &domain.Batch{BatchID: "partial-all-" + suffix, Atomic: true, Partial: true, Submitted: 2,
+		Failures: []domain.BatchFailure{{EventID: "test-partial-stale-" + suffix, Reason: "validation_error"}}}
+	defer func() { _, _ = c.GetDB().Exec("DELETE FROM batches WHERE batch_id = $1", allBad.BatchID) }()
+	if err := c.InsertPartialBatch(allBad, []*domain.Event{bad}, "corr-"+suffix, domain.PayloadModeInline, nil); err != nil {
+		t.Fatalf("InsertPartialBatch: %v", err)
+	}
+	if got, err := c.GetBatch(allBad.BatchID); err != nil || got.Status != domain.BatchStatusFailed || got.Processed != 0 || got.Failed != 2 {
+		t.Errorf("GetBatch = %+v, %v; want failed with nothing processed", got, err)
+	}
+}
+
+func TestRecordBatchMember_CountsOncePerStatus(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	batchID := fmt.Sprintf("test-batch-progress-%d", time.Now().UnixNano())
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM batches WHERE batch_id = $1", batchID)
+	}()
+
+	steps := []struct {
+		eventID string
+		status  domain.IdempotencyStatus
+	}{
+		{"e1", domain.IdempotencyStatusSuccess},
+		{"e1", domain.IdempotencyStatusSuccess}, // redelivery
+		{"e2", domain.IdempotencyStatusFailed},
+		{"e2", domain.IdempotencyStatusSuccess}, // retried successfully
+	}
+	for _, s := range steps {
+		if err := c.RecordBatchMember(batchID, s.eventID, 3, s.status); err != nil {
+			t.Fatalf("RecordBatchMember(%s, %s): %v", s.eventID, s.status, err)
+		}
+	}
+	b, err := c.GetBatch(batchID)
+	if err != nil || b.Submitted != 3 || b.Processed != 2 || b.Failed != 0 || b.Status != domain.BatchStatusProcessing {
+		t.Fatalf("GetBatch = %+v, %v; want processing 2/0 of 3", b, err)
+	}
+
+	if err := c.RecordBatchMember(batchID, "e3", 3, domain.IdempotencyStatusFailed); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := c.GetBatch(batchID); err != nil || b.Status != domain.BatchStatusComplete || b.Failed != 1 {
+		t.Errorf("GetBatch after last member = %+v, %v; want complete", b, err)
+	}
+}
+
+func TestSoftDeleteEvent_HidesRestoresAndErases(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	suffix := fmt.Sprintf("delete-%d", time.Now().UnixNano())
+	seeded := seedEventAndFlag(t, c, suffix, 900, time.Now().UTC().Add(-time.Minute))
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM event_deletions WHERE event_id = $1", seeded.EventID)
+		_, _ = c.GetDB().Exec("DELETE FROM fraud_flags WHERE event_id = $1", seeded.EventID)
+		_, _ = c.GetDB().Exec("DELETE FROM events WHERE event_id = $1", seeded.EventID)
+	}()
+
+	del := &domain.EventDeletion{EventID: seeded.EventID, Actor: "alice"}
+	var conflict *VersionConflictError
+	if err := c.SoftDeleteEvent(del, 7); !errors.As(err, &conflict) || conflict.Current != 1 {
+		t.Fatalf("SoftDeleteEvent(stale) = %v, want conflict at version 1", err)
+	}
+	if err := c.SoftDeleteEvent(del, 1); err != nil || del.Version != 2 {
+		t.Fatalf("SoftDeleteEvent = %v (version %d), want version 2", err, del.Version)
+	}
+	if _, err := c.GetEventByID(seeded.EventID); err != ErrNotFound {
+		t.Errorf("GetEventByID(soft-deleted) = %v, want ErrNotFound", err)
+	}
+	if err := c.SoftDeleteEvent(&domain.EventDeletion{EventID: seeded.EventID, Actor: "alice"}, 2); err != ErrNotFound {
+		t.Errorf("SoftDeleteEvent(again) = %v, want ErrNotFound", err)
+	}
+
+	restore := &domain.EventDeletion{EventID: seeded.EventID, Actor: "bob"}
+	if err := c.RestoreEvent(restore, 2); err != nil || restore.Version != 3 {
+		t.Fatalf("RestoreEvent = %v (version %d), want version 3", err, restore.Version)
+	}
+	if rec, err := c.GetEventByID(seeded.EventID); err != nil || rec.Version != 3 {
+		t.Errorf("GetEventByID(restored) = %+v, %v; want version 3", rec, err)
+	}
+	if err := c.RestoreEvent(&domain.EventDeletion{EventID: seeded.EventID, Actor: "bob"}, 3); err != ErrNotDeleted {
+		t.Errorf("RestoreEvent(live) = %v, want ErrNotDeleted", err)
+	}
+
+	erase := &domain.EventDeletion{EventID: seeded.EventID, Actor: "dpo", Reason: "GDPR request"}
+	if err := c.HardDeleteEvent(erase, 3); err != nil {
+		t.Fatalf("HardDeleteEvent: %v", err)
+	}
+	if flags, err := c.ListFraudFlags(seeded.EventID); err != nil || len(flags) != 0 {
+		t.Errorf("flags after erasure = %v, %v; want none", flags, err)
+	}
+	history, err := c.ListEventDeletions(seeded.EventID)
+	if err != nil {
+		t.Fatalf("ListEventDeletions: %v", err)
+	}
+	if len(history) != 3 || history[0].Action != domain.EventHardDelete || history[2].Action != domain.EventSoftDelete {
+		t.Errorf("ListEventDeletions = %+v, want hard_delete, restore, soft_delete", history)
+	}
+}
+
+func TestReplaceEvent_IdempotentRevisions(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	suffix := fmt.Sprintf("replace-%d", time.Now().UnixNano())
+	seeded := seedEventAndFlag(t, c, suffix, 900, time.Now().UTC().Add(-time.Minute).Truncate(time.Second))
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM event_revisions WHERE event_id = $1", seeded.EventID)
+		_, _ = c.GetDB().Exec("DELETE FROM fraud_flags WHERE event_id = $1", seeded.EventID)
+		_, _ = c.GetDB().Exec("DELETE FROM events WHERE event_id = $1", seeded.EventID)
+	}()
+
+	same := &domain.Event{EventID: seeded.EventID, UserID: seeded.UserID, Amount: 900, Currency: "USD",
+		Merchant: seeded.Merchant, Timestamp: seeded.FlaggedAt, Metadata: map[string]interface{}{}}
+	if v, changed, err := c.ReplaceEvent(same, 0, "svc"); err != nil || changed || v != 1 {
+		t.Fatalf("ReplaceEvent(same) = %d, %v, %v; want unchanged at version 1", v, changed, err)
+	}
+
+	revised := *same
+	revised.Amount = 450.005
+	revised.Metadata = map[string]interface{}{"note": "corrected"}
+	var conflict *VersionConflictError
+	if _, _, err := c.ReplaceEvent(&revised, 0, "svc"); !errors.As(err, &conflict) || conflict.Current != 1 {
+		t.Fatalf("ReplaceEvent(changed, no If-Match) = %v, want conflict at version 1", err)
+	}
+	if v, changed, err := c.ReplaceEvent(&revised, 1, "svc"); err != nil || !changed || v != 2 {
+		t.Fatalf("ReplaceEvent(changed) = %d, %v, %v; want revised to version 2", v, changed, err)
+	}
+	// A retry of the applied PUT, still carrying the old If-Match, is a no-op.
+	retry := *same
+	retry.Amount, retry.Metadata = 450.005, map[string]interface{}{"note": "corrected"}
+	if v, changed, err := c.ReplaceEvent(&retry, 1, "svc"); err != nil || changed || v != 2 {
+		t.Errorf("ReplaceEvent(retry) = %d, %v, %v; want unchanged at version 2", v, changed, err)
+	}
+
+	rec, err := c.GetEventByID(seeded.EventID)
+	if err != nil || rec.Amount != 450.01 || rec.Metadata["note"] != "corrected" || rec.Version != 2 {
+		t.Errorf("GetEventByID = %+v, %v; want the revised content at version 2", rec, err)
+	}
+	revisions, err := c.ListEventRevisions(seeded.EventID)
+	if err != nil || len(revisions) != 1 || revisions[0].Version != 1 || revisions[0].Content.Amount != 900 {
+		t.Errorf("ListEventRevisions = %+v, %v; want the original content at version 1", revisions, err)
+	}
+	if len(revisions) == 1 {
+		before := revisions[0].CreatedAt.Add(-time.Microsecond)
+		if rev, err := c.EventRevisionAsOf(seeded.EventID, before); err != nil || rev.Version != 1 || rev.Content.Amount != 900 {
+			t.Errorf("EventRevisionAsOf(before the PUT) = %+v, %v; want version 1", rev, err)
+		}
+		if _, err := c.EventRevisionAsOf(seeded.EventID, revisions[0].CreatedAt); err != ErrNotFound {
+			t.Errorf("EventRevisionAsOf(at the PUT) = %v, want ErrNotFound for the current content", err)
+		}
+	}
+}
+
+func TestHardDeleteEvent_ErasesRevisions(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	suffix := fmt.Sprintf("erase-revisions-%d", time.Now().UnixNano())
+	seeded := seedEventAndFlag(t, c, suffix, 900, time.Now().UTC().Add(-time.Minute).Truncate(time.Second))
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM event_deletions WHERE event_id = $1", seeded.EventID)
+		_, _ = c.GetDB().Exec("DELETE FROM event_revisions WHERE event_id = $1", seeded.EventID)
+		_, _ = c.GetDB().Exec("DELETE FROM fraud_flags WHERE event_id = $1", seeded.EventID)
+		_, _ = c.GetDB().Exec("DELETE FROM events WHERE event_id = $1", seeded.EventID)
+	}()
+
+	revised := &domain.Event{EventID: seeded.EventID, UserID: seeded.UserID, Amount: 450, Currency: "USD",
+		Merchant: seeded.Merchant, Timestamp: seeded.FlaggedAt, Metadata: map[string]interface{}{"note": "corrected"}}
+	if v, changed, err := c.ReplaceEvent(revised, 1, "svc"); err != nil || !changed || v != 2 {
+		t.Fatalf("ReplaceEvent = %d, %v, %v; want revised to version 2", v, changed, err)
+	}
+	if revisions, err := c.ListEventRevisions(seeded.EventID); err != nil || len(revisions) != 1 {
+		t.Fatalf("ListEventRevisions before erasure = %+v, %v; want one", revisions, err)
+	}
+
+	erase := &domain.EventDeletion{EventID: seeded.EventID, Actor: "dpo", Reason: "GDPR request"}
+	if err := c.HardDeleteEvent(erase, 2); err != nil {
+		t.Fatalf("HardDeleteEvent: %v", err)
+	}
+	if revisions, err := c.ListEventRevisions(seeded.EventID); err != nil || len(revisions) != 0 {
+		t.Errorf("ListEventRevisions after erasure = %+v, %v; want none", revisions, err)
+	}
+}
+
+func TestEventTimelines_RecordOnceAndNotify(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	id := fmt.Sprintf("test-timeline-%d", time.Now().UnixNano())
+	defer func() { _, _ = c.GetDB().Exec("DELETE FROM event_timelines WHERE event_id = $1", id) }()
+
+	received := time.Now().UTC().Add(-time.Second).Truncate(time.Millisecond)
+	first := domain.EventTimeline{EventID: id, ReceivedAt: received, DequeuedAt: received.Add(200 * time.Millisecond), PersistedAt: received.Add(250 * time.Millisecond)}
+	if err := c.RecordEventTimelines([]domain.EventTimeline{first}); err != nil {
+		t.Fatalf("RecordEventTimelines: %v", err)
+	}
+	later := first
+	later.DequeuedAt = received.Add(time.Minute)
+	if err := c.RecordEventTimelines([]domain.EventTimeline{later}); err != nil {
+		t.Fatalf("RecordEventTimelines(redelivery): %v", err)
+	}
+	notified := received.Add(time.Second)
+	for _, at := range []time.Time{notified, notified.Add(time.Minute)} {
+		if err := c.MarkEventNotified(id, at); err != nil {
+			t.Fatalf("MarkEventNotified: %v", err)
+		}
+	}
+
+	got, err := c.GetEventTimelines([]string{id, id + "-missing"})
+	if err != nil {
+		t.Fatalf("GetEventTimelines: %v", err)
+	}
+	tl, ok := got[id]
+	if !ok || len(got) != 1 {
+		t.Fatalf("GetEventTimelines = %+v, want only %s", got, id)
+	}
+	if !tl.DequeuedAt.Equal(first.DequeuedAt) || tl.NotifiedAt == nil || !tl.NotifiedAt.Equal(notified) {
+		t.Errorf("timeline = %+v, want the first dequeue and first delivery kept", tl)
+	}
+}
+
+func TestStatementTimeout_CancelsRunawayQuery(t *testing.T) {
+	client := getTestDB(t)
+	client.Close()
+	client, err := NewClient("host=localhost port=5432 user=fluxa_user password=fluxa_password dbname=fluxa sslmode=disable statement_timeout=50", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	_, err = client.db.ExecContext(context.Background(), `SELECT pg_sleep(1)`)
+	if err = client.classify("sleep", err); err == nil {
+		t.Fatal("pg_sleep(1) outlived a 50ms statement_timeout")
+	}
+	var retryable *domain.RetryableError
+	if !errors.As(err, &retryable) {
+		t.Errorf("statement_timeout returned %v, want a RetryableError", err)
+	}
+}
+
+func TestPoolFor(t *testing.T) {
+	if p := PoolFor(PoolPooled, time.Second); p.MaxOpen != 10 || p.MaxIdle != 5 || p.MaxIdleTime != 0 {
+		t.Errorf("pooled = %+v, want 10 open, 5 idle, no idle timeout", p)
+	}
+	if p := PoolFor(PoolSingle, 30*time.Second); p.MaxOpen != 1 || p.MaxIdle != 1 || p.MaxIdleTime != 30*time.Second {
+		t.Errorf("single = %+v, want 1 connection closed after 30s idle", p)
+	}
+}
+
+func TestConnect_Lazy(t *testing.T) {
+	// Nothing listens on port 1: a lazy client opens anyway, an eager one fails.
+	dsn := "host=127.0.0.1 port=1 user=u password=p dbname=d sslmode=disable connect_timeout=1"
+	c, err := Connect(dsn, PoolFor(PoolSingle, time.Second), true)
+	if err != nil {
+		t.Fatalf("lazy Connect = %v", err)
+	}
+	c.Close()
+	if _, err := Connect(dsn, PoolFor(PoolSingle, time.Second), false); err == nil {
+		t.Error("eager Connect to a closed port succeeded")
+	}
+}
+
+func TestRecordSchemaFields_Drift(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	producer := fmt.Sprintf("test-schema-%d", time.Now().UnixNano())
+	defer func() { _, _ = c.GetDB().Exec("DELETE FROM payload_schema_fields WHERE producer_key = $1", producer) }()
+
+	baseline := []domain.PayloadField{{Path: "amount", Type: "number"}, {Path: "metadata.order_id", Type: "string"}}
+	drift, err := c.RecordSchemaFields(producer, "v1", baseline)
+	if err != nil || len(drift) != 1 || drift[0].Kind != domain.SchemaDriftNewSchema {
+		t.Fatalf("RecordSchemaFields(first) = %+v, %v; want new_schema", drift, err)
+	}
+	if drift, err := c.RecordSchemaFields(producer, "v1", baseline); err != nil || len(drift) != 0 {
+		t.Errorf("RecordSchemaFields(same) = %+v, %v; want no drift", drift, err)
+	}
+	changed := []domain.PayloadField{{Path: "amount", Type: "number"}, {Path: "channel", Type: "string"}, {Path: "metadata.order_id", Type: "number"}}
+	drift, err = c.RecordSchemaFields(producer, "v1", changed)
+	if err != nil || len(drift) != 2 ||
+		drift[0].Kind != domain.SchemaDriftNewField || drift[0].Field.Path != "channel" ||
+		drift[1].Kind != domain.SchemaDriftTypeChange || len(drift[1].PreviousTypes) != 1 || drift[1].PreviousTypes[0] != "string" {
+		t.Errorf("RecordSchemaFields(changed) = %+v, %v; want channel new, metadata.order_id string -> number", drift, err)
+	}
+	if drift, err := c.RecordSchemaFields(producer, "v2", changed); err != nil || len(drift) != 1 || drift[0].Kind != domain.SchemaDriftNewSchema {
+		t.Errorf("RecordSchemaFields(v2) = %+v, %v; want a new schema per version", drift, err)
+	}
+}
+
+func TestArchiveOutbox_QueuedWithEventAndCompleted(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	ts := time.Now().UTC().Truncate(time.Second)
+	prefix := fmt.Sprintf("test-archive-outbox-%d", ts.UnixNano())
+	payload := []byte(`{"event_id":"` + prefix + `"}`)
+	sum := sha256.Sum256(payload)
+	key := domain.PayloadKey(hex.EncodeToString(sum[:]))
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM archive_outbox WHERE event_id = $1", prefix)
+		_, _ = c.GetDB().Exec("DELETE FROM payload_refs WHERE s3_key = $1", key)
+		_, _ = c.GetDB().Exec("DELETE FROM merchant_stats_hourly WHERE merchant = $1", prefix)
+		_, _ = c.GetDB().Exec("DELETE FROM events WHERE event_id = $1", prefix)
+	}()
+
+	ev := &domain.Event{
+		EventID: prefix, UserID: "test-user-outbox", Amount: 5, Currency: "USD", Merchant: prefix,
+		Timestamp: ts, PendingArchive: payload,
+	}
+	for i := 0; i < 2; i++ { // a redelivery queues nothing more
+		if err := c.InsertEvent(ev, "corr-outbox", domain.PayloadModeInline, nil); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+	}
+
+	claim := func() *domain.ArchiveTask {
+		tasks, err := c.ClaimArchives(1000, time.Minute)
+		if err != nil {
+			t.Fatalf("ClaimArchives: %v", err)
+		}
+		for _, task := range tasks {
+			if task.EventID == prefix {
+				return &task
+			}
+		}
+		return nil
+	}
+	task := claim()
+	if task == nil || !bytes.Equal(task.Payload, payload) || task.PayloadSHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("claimed %+v, want the queued payload", task)
+	}
+	if again := claim(); again != nil {
+		t.Errorf("claimed %+v again within its lease", again)
+	}
+	if err := c.RetryArchive(prefix, time.Now().Add(-time.Second), "minio down"); err != nil {
+		t.Fatalf("RetryArchive: %v", err)
+	}
+	if task = claim(); task == nil || task.Attempts != 1 {
+		t.Fatalf("claimed %+v after a failure, want it due with 1 attempt", task)
+	}
+
+	if err := c.CompleteArchive(prefix, key); err != nil {
+		t.Fatalf("CompleteArchive: %v", err)
+	}
+	rec, err := c.GetEventByID(prefix)
+	if err != nil || rec.S3Key == nil || *rec.S3Key != key {
+		t.Fatalf("GetEventByID = %+v, %v; want s3_key %q", rec, err, key)
+	}
+	if n, err := c.PayloadRefCount(key); err != nil || n != 1 {
+		t.Errorf("PayloadRefCount = %d, %v; want 1", n, err)
+	}
+	if err := c.RetryArchive(prefix, time.Now().Add(-time.Second), "late"); err != nil {
+		t.Fatalf("RetryArchive (settled): %v", err)
+	}
+	if task := claim(); task != nil {
+		t.Errorf("claimed %+v after completion", task)
+	}
+}
+
+func TestReconcile_FindsBothDirections(t *testing.T) {
+	c := getTestDB(t)
+	defer c.Close()
+
+	suffix := time.Now().UnixNano()
+	lost := fmt.Sprintf("test-reconcile-lost-%d", suffix)
+	orphan := fmt.Sprintf("test-reconcile-orphan-%d", suffix)
+	imported := fmt.Sprintf("test-reconcile-imported-%d", suffix)
+	defer func() {
+		_, _ = c.GetDB().Exec("DELETE FROM idempotency_keys WHERE event_id = ANY($1)", pq.Array([]string{lost, imported}))
+		_, _ = c.GetDB().Exec("DELETE FROM events WHERE event_id = $1", orphan)
+	}()
+
+	now := time.Now().UTC()
+	for id, attempts := range map[string]int{lost: 1, imported: 0} {
+		_, err := c.GetDB().Exec(`
+			INSERT INTO idempotency_keys (event_id, status, first_seen_at, last_seen_at, attempts)
+			VALUES ($1, 'success', $2, $2, $3)`, id, now, attempts)
+		if err != nil {
+			t.Fatalf("seed key %s: %v", id, err)
+		}
+	}
+	event := &domain.Event{EventID: orphan, UserID: "u-reconcile", Amount: 10, Currency: "USD", Merchant: "M", Timestamp: now}
+	if err := c.InsertEvent(event, "corr-reconcile", domain.PayloadModeInline, nil); err != nil {
+		t.Fatalf("InsertEvent: %v", err)
+	}
+
+	rec, err := c.Reconcile(now.Add(-time.Minute), now.Add(time.Minute), 100)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	has := func(d domain.Discrepancy, id string) bool {
+		for _, e := range d.Sample {
+			if e.EventID == id {
+				return true
+			}
+		}
+		return false
+	}
+	if !has(rec.SucceededWithoutEvent, lost) || has(rec.SucceededWithoutEvent, imported) {
+		t.Errorf("succeeded_without_event = %+v, want %s and not the imported key", rec.SucceededWithoutEvent, lost)
+	}
+	if !has(rec.EventsWithoutSuccess, orphan) {
+		t.Errorf("events_without_success = %+v, want %s", rec.EventsWithoutSuccess, orphan)
+	}
+	if rec.ImportedWithoutEvent < 1 {
+		t.Errorf("imported_without_event = %d, want the imported key counted", rec.ImportedWithoutEvent)
+	}
+}
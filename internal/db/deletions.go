@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+)
+
+// ErrNotDeleted is returned by RestoreEvent for an event that isn't soft-deleted.
+var ErrNotDeleted = fmt.Errorf("event is not deleted")
+
+// VersionConflictError is ErrVersionConflict with the event's current version, for
+// the 412 response.
+type VersionConflictError struct {
+	Current int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%v: current version is %d", ErrVersionConflict, e.Current)
+}
+
+// Is makes errors.Is(err, ErrVersionConflict) hold.
+func (e *VersionConflictError) Is(target error) bool { return target == ErrVersionConflict }
+
+// rollUpDelta adds sign ($2, ±1) × the event to its merchant_stats_hourly bucket,
+// the way insertEvent counted it (canaries were never counted). max_amount only
+// grows, so a removed event's amount may remain the bucket maximum.
+const rollUpDelta = `
+	INSERT INTO merchant_stats_hourly (merchant, bucket, event_count, total_amount, max_amount)
+	SELECT COALESCE(canonical_merchant, merchant), date_trunc('hour', ts), $2, $2 * amount, amount
+	FROM events
+	WHERE event_id = $1 AND NOT is_canary
+	ON CONFLICT (merchant, bucket) DO UPDATE SET
+		event_count  = merchant_stats_hourly.event_count + EXCLUDED.event_count,
+		total_amount = merchant_stats_hourly.total_amount + EXCLUDED.total_amount,
+		max_amount   = GREATEST(merchant_stats_hourly.max_amount, EXCLUDED.max_amount)`
+
+// SoftDeleteEvent hides d.EventID from reads and aggregates and bumps its version,
+// if expectedVersion is still current. It returns ErrNotFound for a missing or
+// already deleted event and a *VersionConflictError for a stale version. Sets
+// d.Version and the audit fields.
+func (c *Client) SoftDeleteEvent(d *domain.EventDeletion, expectedVersion int) error {
+	d.Action = domain.EventSoftDelete
+	return c.changeDeletion(d, expectedVersion, false, `
+		UPDATE events SET deleted_at = NOW(), version = version + 1
+		WHERE event_id = $1 RETURNING version`, -1)
+}
+
+// RestoreEvent undoes a soft delete of d.EventID and bumps its version, if
+// expectedVersion is still current. It returns ErrNotFound for a missing event,
+// ErrNotDeleted for a live one, and a *VersionConflictError for a stale version.
+func (c *Client) RestoreEvent(d *domain.EventDeletion, expectedVersion int) error {
+	d.Action = domain.EventRestore
+	return c.changeDeletion(d, expectedVersion, true, `
+		UPDATE events SET deleted_at = NULL, version = version + 1
+		WHERE event_id = $1 RETURNING version`, 1)
+}
+
+// changeDeletion locks the event, checks its deletion state and version, applies
+// update (returning the new version), moves the roll-up by rollUp, and records d.
+func (c *Client) changeDeletion(d *domain.EventDeletion, expectedVersion int, wantDeleted bool, update string, rollUp int) (err error) {
+	ctx, done := c.write(string(d.Action)+"_event", d.EventID, expectedVersion)
+	defer done(&err)
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	version, deleted, err := lockEvent(ctx, tx, d.EventID)
+	if err != nil {
+		return err
+	}
+	if deleted != wantDeleted {
+		if wantDeleted {
+			return ErrNotDeleted
+		}
+		return ErrNotFound
+	}
+	if version != expectedVersion {
+		return &VersionConflictError{Current: version}
+	}
+	if err := tx.QueryRowContext(ctx, update, d.EventID).Scan(&d.Version); err != nil {
+		return fmt.Errorf("failed to update event: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, rollUpDelta, d.EventID, rollUp); err != nil {
+		return fmt.Errorf("failed to update merchant roll-up: %w", err)
+	}
+	if err := insertEventDeletion(ctx, tx, d); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit event %s: %w", d.Action, err)
+	}
+	return nil
+}
+
+// HardDeleteEvent erases d.EventID — live or soft-deleted — with its fraud flags,
+// notification audits, timeline, revisions and queued archival, releases its payload
+// reference, and takes a live event out of the roll-up, if expectedVersion is
+// still current. Only the event_deletions row remains. Errors as for SoftDeleteEvent.
+func (c *Client) HardDeleteEvent(d *domain.EventDeletion, expectedVersion int) (err error) {
+	ctx, done := c.write("hard_delete_event", d.EventID, expectedVersion)
+	defer done(&err)
+	d.Action, d.Version = domain.EventHardDelete, 0
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	version, deleted, err := lockEvent(ctx, tx, d.EventID)
+	if err != nil {
+		return err
+	}
+	if version != expectedVersion {
+		return &VersionConflictError{Current: version}
+	}
+	if !deleted {
+		if _, err := tx.ExecContext(ctx, rollUpDelta, d.EventID, -1); err != nil {
+			return fmt.Errorf("failed to update merchant roll-up: %w", err)
+		}
+	}
+	for _, stmt := range []string{
+		`DELETE FROM notification_audit WHERE event_id = $1`,
+		`DELETE FROM fraud_flags WHERE event_id = $1`,
+		`DELETE FROM event_timelines WHERE event_id = $1`,
+		`DELETE FROM event_revisions WHERE event_id = $1`,
+		`DELETE FROM archive_outbox WHERE event_id = $1`,
+		`UPDATE payload_refs SET ref_count = ref_count - 1
+		 WHERE s3_key = (SELECT s3_key FROM events WHERE event_id = $1) AND ref_count > 0`,
+		`DELETE FROM events WHERE event_id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, d.EventID); err != nil {
+			return fmt.Errorf("failed to erase event: %w", err)
+		}
+	}
+	if err := insertEventDeletion(ctx, tx, d); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit event erasure: %w", err)
+	}
+	return nil
+}
+
+// lockEvent locks eventID's row for the rest of tx and returns its version and
+// whether it is soft-deleted.
+func lockEvent(ctx context.Context, tx *sql.Tx, eventID string) (version int, deleted bool, err error) {
+	err = tx.QueryRowContext(ctx,
+		`SELECT version, deleted_at IS NOT NULL FROM events WHERE event_id = $1 FOR UPDATE`,
+		eventID).Scan(&version, &deleted)
+	if err == sql.ErrNoRows {
+		return 0, false, ErrNotFound
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to lock event: %w", err)
+	}
+	return version, deleted, nil
+}
+
+func insertEventDeletion(ctx context.Context, tx *sql.Tx, d *domain.EventDeletion) error {
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO event_deletions (event_id, action, actor, reason, version)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING deletion_id, created_at`,
+		d.EventID, string(d.Action), d.Actor, d.Reason, d.Version,
+	).Scan(&d.DeletionID, &d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record event deletion: %w", err)
+	}
+	return nil
+}
+
+// ListEventDeletions returns eventID's delete/restore/erasure history, newest first.
+func (c *Client) ListEventDeletions(eventID string) (_ []domain.EventDeletion, err error) {
+	ctx, done := c.read("list_event_deletions", eventID)
+	defer done(&err)
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+deletionColumns.List()+`
+		FROM event_deletions
+		WHERE event_id = $1
+		ORDER BY created_at DESC, deletion_id DESC`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event deletions: %w", err)
+	}
+	defer rows.Close()
+
+	deletions := []domain.EventDeletion{}
+	for rows.Next() {
+		var d domain.EventDeletion
+		if err := rows.Scan(deletionColumns.Dest(&d)...); err != nil {
+			return nil, fmt.Errorf("failed to scan event deletion: %w", err)
+		}
+		deletions = append(deletions, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate event deletions: %w", err)
+	}
+	return deletions, nil
+}
+
+var deletionColumns = sqlrow.New("event_deletions",
+	sqlrow.Col("deletion_id", func(d *domain.EventDeletion) any { return &d.DeletionID }),
+	sqlrow.Col("event_id", func(d *domain.EventDeletion) any { return &d.EventID }),
+	sqlrow.Col("action", func(d *domain.EventDeletion) any { return &d.Action }),
+	sqlrow.Col("actor", func(d *domain.EventDeletion) any { return &d.Actor }),
+	sqlrow.Col("reason", func(d *domain.EventDeletion) any { return &d.Reason }),
+	sqlrow.Col("version", func(d *domain.EventDeletion) any { return &d.Version }),
+	sqlrow.Col("created_at", func(d *domain.EventDeletion) any { return &d.CreatedAt }),
+)
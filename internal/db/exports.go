@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+)
+
+// CreateExport inserts a new export job. Sets export.CreatedAt/UpdatedAt.
+func (c *Client) CreateExport(export *domain.Export) (err error) {
+	ctx, done := c.write("create_export", export.ExportID)
+	defer done(&err)
+
+	filters, err := json.Marshal(export.Filter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal export filters: %w", err)
+	}
+	err = c.db.QueryRowContext(ctx, `
+		INSERT INTO exports (export_id, format, filters, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at
+	`, export.ExportID, string(export.Format), filters, string(export.Status)).Scan(&export.CreatedAt, &export.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert export: %w", err)
+	}
+	return nil
+}
+
+// GetExport returns the export job exportID, or ErrNotFound.
+func (c *Client) GetExport(exportID string) (_ *domain.Export, err error) {
+	ctx, done := c.read("get_export", exportID)
+	defer done(&err)
+
+	var row exportRow
+	err = c.db.QueryRowContext(ctx, `SELECT `+exportColumns.List()+` FROM exports WHERE export_id = $1`, exportID).
+		Scan(exportColumns.Dest(&row)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query export: %w", err)
+	}
+	if err := json.Unmarshal(row.filters, &row.Filter); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal export filters: %w", err)
+	}
+	row.ObjectKey = row.objectKey.String
+	row.ErrorReason = row.errorReason.String
+	return &row.Export, nil
+}
+
+// exportRow is an exports row; filters is the raw JSONB and the nullable text
+// columns read into NullStrings.
+type exportRow struct {
+	domain.Export
+	filters                []byte
+	objectKey, errorReason sql.NullString
+}
+
+var exportColumns = sqlrow.New("exports",
+	sqlrow.Col("export_id", func(r *exportRow) any { return &r.ExportID }),
+	sqlrow.Col("format", func(r *exportRow) any { return &r.Format }),
+	sqlrow.Col("filters", func(r *exportRow) any { return &r.filters }),
+	sqlrow.Col("status", func(r *exportRow) any { return &r.Status }),
+	sqlrow.Col("total_rows", func(r *exportRow) any { return &r.TotalRows }),
+	sqlrow.Col("rows_exported", func(r *exportRow) any { return &r.RowsExported }),
+	sqlrow.Col("object_key", func(r *exportRow) any { return &r.objectKey }),
+	sqlrow.Col("error_reason", func(r *exportRow) any { return &r.errorReason }),
+	sqlrow.Col("created_at", func(r *exportRow) any { return &r.CreatedAt }),
+	sqlrow.Col("updated_at", func(r *exportRow) any { return &r.UpdatedAt }),
+	sqlrow.Col("completed_at", func(r *exportRow) any { return &r.CompletedAt }),
+)
+
+// UpdateExport saves the progress and outcome of export (status, row counts,
+// object key, error, completion time). Sets export.UpdatedAt.
+func (c *Client) UpdateExport(export *domain.Export) (err error) {
+	ctx, done := c.write("update_export", export.ExportID)
+	defer done(&err)
+
+	var objectKey, errorReason *string
+	if export.ObjectKey != "" {
+		objectKey = &export.ObjectKey
+	}
+	if export.ErrorReason != "" {
+		errorReason = &export.ErrorReason
+	}
+	err = c.db.QueryRowContext(ctx, `
+		UPDATE exports
+		SET status = $2, total_rows = $3, rows_exported = $4, object_key = $5,
+		    error_reason = $6, completed_at = $7, updated_at = NOW()
+		WHERE export_id = $1
+		RETURNING updated_at
+	`, export.ExportID, string(export.Status), export.TotalRows, export.RowsExported,
+		objectKey, errorReason, export.CompletedAt).Scan(&export.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update export: %w", err)
+	}
+	return nil
+}
+
+// FailInterruptedExports marks pending and running exports as failed with reason.
+// Jobs run inside the query service, so any left unfinished at startup were cut
+// off by a restart and will never complete.
+func (c *Client) FailInterruptedExports(reason string) (_ int64, err error) {
+	ctx, done := c.write("fail_interrupted_exports", reason)
+	defer done(&err)
+
+	res, err := c.db.ExecContext(ctx, `
+		UPDATE exports
+		SET status = $1, error_reason = $2, completed_at = NOW(), updated_at = NOW()
+		WHERE status IN ($3, $4)
+	`, string(domain.ExportStatusFailed), reason, string(domain.ExportStatusPending), string(domain.ExportStatusRunning))
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted exports: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// CountExportEvents returns how many events match filter.
+func (c *Client) CountExportEvents(ctx context.Context, filter domain.ExportFilter) (int, error) {
+	where, args := exportWhere(filter)
+	var n int
+	if err := c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM events e`+where, args...).Scan(&n); err != nil {
+		return 0, c.classify("count_export_events", fmt.Errorf("failed to count export events: %w", err))
+	}
+	return n, nil
+}
+
+// StreamExportEvents calls fn with every event matching filter, in timestamp
+// order, reading all EventFields. It stops at the first error from fn. The caller's
+// ctx bounds the whole scan: the scan is one long statement, so it runs in a
+// read-only transaction with statement_timeout lifted.
+func (c *Client) StreamExportEvents(ctx context.Context, filter domain.ExportFilter, fn func(*domain.EventRecord) error) (err error) {
+	defer func() { err = c.classify("stream_export_events", err) }()
+
+	scan, columns, err := newEventScan(nil)
+	if err != nil {
+		return err
+	}
+	tx, err := c.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin export transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return fmt.Errorf("failed to lift statement timeout: %w", err)
+	}
+
+	where, args := exportWhere(filter)
+	rows, err := tx.QueryContext(ctx, `SELECT `+strings.Join(columns, ", ")+` FROM events e`+where+` ORDER BY e.ts, e.event_id`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query export events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		scan.rec = domain.EventRecord{}
+		if err := rows.Scan(scan.dest...); err != nil {
+			return fmt.Errorf("failed to scan export event: %w", err)
+		}
+		record, err := scan.record()
+		if err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate export events: %w", err)
+	}
+	return nil
+}
+
+// exportWhere builds the WHERE clause (over events aliased e) and its arguments.
+func exportWhere(filter domain.ExportFilter) (string, []interface{}) {
+	conds := []string{"NOT e.is_canary", "e.deleted_at IS NULL"}
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if filter.From != nil {
+		add("e.ts >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		add("e.ts < ?", *filter.To)
+	}
+	if filter.UserID != "" {
+		add("e.user_id = ?", filter.UserID)
+	}
+	if filter.Merchant != "" {
+		add("e.canonical_merchant = ?", filter.Merchant)
+	}
+	if filter.Currency != "" {
+		add("e.currency = ?", filter.Currency)
+	}
+	if filter.FlaggedOnly {
+		conds = append(conds, "EXISTS (SELECT 1 FROM fraud_flags f WHERE f.event_id = e.event_id)")
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
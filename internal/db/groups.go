@@ -0,0 +1,114 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+)
+
+// RecordGroupMember brings groupID's progress up to date after one of its members
+// was persisted, creating the group with expected members on first use (a later
+// member declaring another size doesn't change it). Persisted is recounted from
+// events, so a redelivered member never counts twice. completed reports whether
+// this call completed the group: completed_at is only ever set once, so exactly
+// one caller sees true.
+func (c *Client) RecordGroupMember(groupID string, expected int) (_ *domain.EventGroup, completed bool, err error) {
+	ctx, done := c.write("record_group_member", groupID, expected)
+	defer done(&err)
+
+	now := time.Now().UTC()
+	_, err = c.db.ExecContext(ctx, `
+		INSERT INTO event_groups (group_id, expected, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (group_id) DO NOTHING
+	`, groupID, expected, now)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	var group domain.EventGroup
+	dest := append(groupColumns.Dest(&group), &completed)
+	err = c.db.QueryRowContext(ctx, `
+		UPDATE event_groups g SET
+			persisted    = n.persisted,
+			updated_at   = $2,
+			completed_at = CASE WHEN g.completed_at IS NULL AND n.persisted >= g.expected THEN $2 ELSE g.completed_at END
+		FROM (SELECT COUNT(*) AS persisted FROM events WHERE group_id = $1) n
+		WHERE g.group_id = $1
+		RETURNING `+groupColumns.Qualified("g")+`, g.completed_at IS NOT DISTINCT FROM $2
+	`, groupID, now).Scan(dest...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update group: %w", err)
+	}
+	// The row lock serializes updates: only the one that set completed_at finds
+	// its own timestamp there.
+	group.Complete = group.CompletedAt != nil
+	return &group, completed, nil
+}
+
+// GetGroup returns the progress of groupID, or ErrNotFound before its first
+// member is persisted.
+func (c *Client) GetGroup(groupID string) (_ *domain.EventGroup, err error) {
+	ctx, done := c.read("get_group", groupID)
+	defer done(&err)
+
+	var group domain.EventGroup
+	err = c.db.QueryRowContext(ctx, `SELECT `+groupColumns.List()+` FROM event_groups WHERE group_id = $1`, groupID).
+		Scan(groupColumns.Dest(&group)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group: %w", err)
+	}
+	group.Complete = group.CompletedAt != nil
+	return &group, nil
+}
+
+// ListGroupEvents returns the events of groupID in timestamp order, soft-deleted
+// ones left out.
+func (c *Client) ListGroupEvents(groupID string) (_ []domain.EventRecord, err error) {
+	ctx, done := c.read("list_group_events", groupID)
+	defer done(&err)
+
+	scan, columns, err := newEventScan(nil)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+strings.Join(columns, ", ")+`
+		FROM events
+		WHERE group_id = $1 AND deleted_at IS NULL
+		ORDER BY ts, event_id`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []domain.EventRecord{}
+	for rows.Next() {
+		if err := rows.Scan(scan.dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan group event: %w", err)
+		}
+		rec, err := scan.record()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *rec)
+	}
+	return events, rows.Err()
+}
+
+// groupColumns reads an event_groups row; Complete is derived from completed_at.
+var groupColumns = sqlrow.New("event_groups",
+	sqlrow.Col("group_id", func(r *domain.EventGroup) any { return &r.GroupID }),
+	sqlrow.Col("expected", func(r *domain.EventGroup) any { return &r.Expected }),
+	sqlrow.Col("persisted", func(r *domain.EventGroup) any { return &r.Persisted }),
+	sqlrow.Col("created_at", func(r *domain.EventGroup) any { return &r.CreatedAt }),
+	sqlrow.Col("updated_at", func(r *domain.EventGroup) any { return &r.UpdatedAt }),
+	sqlrow.Col("completed_at", func(r *domain.EventGroup) any { return &r.CompletedAt }),
+)
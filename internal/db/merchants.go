@@ -0,0 +1,131 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+)
+
+// ListMerchantAliases returns every merchant alias mapping, ordered by alias key.
+func (c *Client) ListMerchantAliases() (_ []domain.MerchantAlias, err error) {
+	ctx, done := c.read("list_merchant_aliases")
+	defer done(&err)
+
+	rows, err := c.db.QueryContext(ctx,
+		`SELECT `+aliasColumns.List()+` FROM merchant_aliases ORDER BY alias_key`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query merchant aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []domain.MerchantAlias
+	for rows.Next() {
+		var a domain.MerchantAlias
+		if err := rows.Scan(aliasColumns.Dest(&a)...); err != nil {
+			return nil, fmt.Errorf("failed to scan merchant alias: %w", err)
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+var aliasColumns = sqlrow.New("merchant_aliases",
+	sqlrow.Col("alias_key", func(a *domain.MerchantAlias) any { return &a.AliasKey }),
+	sqlrow.Col("canonical", func(a *domain.MerchantAlias) any { return &a.Canonical }),
+	sqlrow.Col("updated_at", func(a *domain.MerchantAlias) any { return &a.UpdatedAt }),
+)
+
+// UpsertMerchantAlias creates or replaces the mapping for aliasKey.
+func (c *Client) UpsertMerchantAlias(aliasKey, canonical string) (err error) {
+	ctx, done := c.write("upsert_merchant_alias", aliasKey, canonical)
+	defer done(&err)
+
+	query := `
+		INSERT INTO merchant_aliases (alias_key, canonical, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (alias_key) DO UPDATE SET canonical = EXCLUDED.canonical, updated_at = EXCLUDED.updated_at
+	`
+	if _, err := c.db.ExecContext(ctx, query, aliasKey, canonical, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to upsert merchant alias: %w", err)
+	}
+	return nil
+}
+
+// DeleteMerchantAlias removes the mapping for aliasKey. Returns ErrNotFound if none existed.
+func (c *Client) DeleteMerchantAlias(aliasKey string) (err error) {
+	ctx, done := c.write("delete_merchant_alias", aliasKey)
+	defer done(&err)
+
+	res, err := c.db.ExecContext(ctx, `DELETE FROM merchant_aliases WHERE alias_key = $1`, aliasKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete merchant alias: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetTopMerchants returns the merchants with the most events in buckets at or after since,
+// highest event count first.
+func (c *Client) GetTopMerchants(since time.Time, limit int) (_ []domain.MerchantStats, err error) {
+	ctx, done := c.read("get_top_merchants", since, limit)
+	defer done(&err)
+
+	query := `
+		SELECT merchant, SUM(event_count), SUM(total_amount), MAX(max_amount)
+		FROM merchant_stats_hourly
+		WHERE bucket >= date_trunc('hour', $1::timestamptz)
+		GROUP BY merchant
+		ORDER BY SUM(event_count) DESC, merchant
+		LIMIT $2
+	`
+	rows, err := c.db.QueryContext(ctx, query, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top merchants: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []domain.MerchantStats
+	for rows.Next() {
+		var s domain.MerchantStats
+		if err := rows.Scan(&s.Merchant, &s.EventCount, &s.TotalAmount, &s.MaxAmount); err != nil {
+			return nil, fmt.Errorf("failed to scan merchant stats: %w", err)
+		}
+		if s.EventCount > 0 {
+			s.AvgAmount = s.TotalAmount / float64(s.EventCount)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// GetMerchantStatsBuckets returns the merchant's hourly buckets at or after since, oldest first.
+func (c *Client) GetMerchantStatsBuckets(merchant string, since time.Time) (_ []domain.MerchantStatsBucket, err error) {
+	ctx, done := c.read("get_merchant_stats_buckets", merchant, since)
+	defer done(&err)
+
+	query := `
+		SELECT bucket, event_count, total_amount, max_amount
+		FROM merchant_stats_hourly
+		WHERE merchant = $1 AND bucket >= date_trunc('hour', $2::timestamptz)
+		ORDER BY bucket
+	`
+	rows, err := c.db.QueryContext(ctx, query, merchant, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query merchant stats: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []domain.MerchantStatsBucket
+	for rows.Next() {
+		var b domain.MerchantStatsBucket
+		if err := rows.Scan(&b.Bucket, &b.EventCount, &b.TotalAmount, &b.MaxAmount); err != nil {
+			return nil, fmt.Errorf("failed to scan merchant stats bucket: %w", err)
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+)
+
+// ListFraudFlags returns the fraud flags raised for eventID, oldest first, with
+// Canary copied from the event. An event without flags yields an empty slice.
+func (c *Client) ListFraudFlags(eventID string) (_ []domain.FraudFlag, err error) {
+	ctx, done := c.read("list_fraud_flags", eventID)
+	defer done(&err)
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+flagColumns.Qualified("f")+`, e.is_canary
+		FROM fraud_flags f
+		JOIN events e ON e.event_id = f.event_id
+		WHERE f.event_id = $1
+		ORDER BY f.flagged_at, f.flag_id`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fraud flags: %w", err)
+	}
+	defer rows.Close()
+
+	flags := []domain.FraudFlag{}
+	for rows.Next() {
+		var f domain.FraudFlag
+		if err := rows.Scan(append(flagColumns.Dest(&f), &f.Canary)...); err != nil {
+			return nil, fmt.Errorf("failed to scan fraud flag: %w", err)
+		}
+		flags = append(flags, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate fraud flags: %w", err)
+	}
+	return flags, nil
+}
+
+// InsertNotificationAudit records a notification re-send and sets a.AuditID and
+// a.TriggeredAt from the stored row.
+func (c *Client) InsertNotificationAudit(a *domain.NotificationAudit) (err error) {
+	ctx, done := c.write("insert_notification_audit", a.EventID)
+	defer done(&err)
+
+	err = c.db.QueryRowContext(ctx, `
+		INSERT INTO notification_audit (event_id, actor, reason, channel, notifications, error)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING audit_id, triggered_at`,
+		a.EventID, a.Actor, a.Reason, a.Channel, a.Notifications, a.Error,
+	).Scan(&a.AuditID, &a.TriggeredAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert notification audit: %w", err)
+	}
+	return nil
+}
+
+// ListNotificationAudits returns eventID's re-send history, newest first.
+func (c *Client) ListNotificationAudits(eventID string) (_ []domain.NotificationAudit, err error) {
+	ctx, done := c.read("list_notification_audits", eventID)
+	defer done(&err)
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+auditColumns.List()+`
+		FROM notification_audit
+		WHERE event_id = $1
+		ORDER BY triggered_at DESC, audit_id DESC`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification audits: %w", err)
+	}
+	defer rows.Close()
+
+	audits := []domain.NotificationAudit{}
+	for rows.Next() {
+		var a domain.NotificationAudit
+		if err := rows.Scan(auditColumns.Dest(&a)...); err != nil {
+			return nil, fmt.Errorf("failed to scan notification audit: %w", err)
+		}
+		audits = append(audits, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate notification audits: %w", err)
+	}
+	return audits, nil
+}
+
+var flagColumns = sqlrow.New("fraud_flags",
+	sqlrow.Col("flag_id", func(f *domain.FraudFlag) any { return &f.FlagID }),
+	sqlrow.Col("event_id", func(f *domain.FraudFlag) any { return &f.EventID }),
+	sqlrow.Col("user_id", func(f *domain.FraudFlag) any { return &f.UserID }),
+	sqlrow.Col("rule_name", func(f *domain.FraudFlag) any { return &f.RuleName }),
+	sqlrow.Col("rule_value", func(f *domain.FraudFlag) any { return &f.RuleValue }),
+	sqlrow.Col("ml_score", func(f *domain.FraudFlag) any { return &f.MlScore }),
+	sqlrow.Col("flagged_at", func(f *domain.FraudFlag) any { return &f.FlaggedAt }),
+)
+
+var auditColumns = sqlrow.New("notification_audit",
+	sqlrow.Col("audit_id", func(a *domain.NotificationAudit) any { return &a.AuditID }),
+	sqlrow.Col("event_id", func(a *domain.NotificationAudit) any { return &a.EventID }),
+	sqlrow.Col("actor", func(a *domain.NotificationAudit) any { return &a.Actor }),
+	sqlrow.Col("reason", func(a *domain.NotificationAudit) any { return &a.Reason }),
+	sqlrow.Col("channel", func(a *domain.NotificationAudit) any { return &a.Channel }),
+	sqlrow.Col("notifications", func(a *domain.NotificationAudit) any { return &a.Notifications }),
+	sqlrow.Col("error", func(a *domain.NotificationAudit) any { return &a.Error }),
+	sqlrow.Col("triggered_at", func(a *domain.NotificationAudit) any { return &a.TriggeredAt }),
+)
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// PayloadRefCount returns how many events reference the offloaded payload at s3Key.
+// An unknown key has zero references.
+func (c *Client) PayloadRefCount(s3Key string) (_ int, err error) {
+	ctx, done := c.read("payload_ref_count", s3Key)
+	defer done(&err)
+
+	var n int
+	err = c.db.QueryRowContext(ctx,
+		`SELECT ref_count FROM payload_refs WHERE s3_key = $1`, s3Key).Scan(&n)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query payload ref count: %w", err)
+	}
+	return n, nil
+}
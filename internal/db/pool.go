@@ -0,0 +1,61 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Connection pool strategies (DB_POOL_STRATEGY).
+const (
+	// PoolPooled keeps up to 10 connections open and 5 idle, Open's defaults.
+	PoolPooled = "pooled"
+	// PoolSingle keeps one connection, closed once idle for the idle timeout, for
+	// deployments that run many small replicas against one Postgres (or RDS Proxy):
+	// connections scale with replicas, not replicas × 10.
+	PoolSingle = "single"
+)
+
+// PoolOptions sizes a Client's connection pool.
+type PoolOptions struct {
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration
+	// MaxIdleTime closes a connection idle for that long; 0 keeps it until
+	// MaxLifetime.
+	MaxIdleTime time.Duration
+}
+
+// PoolFor returns the pool of strategy (config validates it; anything but
+// PoolSingle is PoolPooled). idleTimeout applies to PoolSingle only.
+func PoolFor(strategy string, idleTimeout time.Duration) PoolOptions {
+	if strategy == PoolSingle {
+		return PoolOptions{MaxOpen: 1, MaxIdle: 1, MaxLifetime: 5 * time.Minute, MaxIdleTime: idleTimeout}
+	}
+	return PoolOptions{MaxOpen: 10, MaxIdle: 5, MaxLifetime: 5 * time.Minute}
+}
+
+// Connect opens a Client with pool and, unless lazy, pings it like NewClient.
+func Connect(dsn string, pool PoolOptions, lazy bool) (*Client, error) {
+	client, err := Open(dsn, pool.MaxOpen)
+	if err != nil {
+		return nil, err
+	}
+	client.WithPool(pool)
+	if lazy {
+		return client, nil
+	}
+	if err := client.db.Ping(); err != nil {
+		client.db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return client, nil
+}
+
+// WithPool resizes the connection pool. Returns c for chaining.
+func (c *Client) WithPool(p PoolOptions) *Client {
+	c.db.SetMaxOpenConns(p.MaxOpen)
+	c.db.SetMaxIdleConns(p.MaxIdle)
+	c.db.SetConnMaxLifetime(p.MaxLifetime)
+	c.db.SetConnMaxIdleTime(p.MaxIdleTime)
+	return c
+}
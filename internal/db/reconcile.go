@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// Reconcile compares idempotency_keys with events over [from, to): keys by when
+// they were last seen, events by when they were stored. Each direction returns its
+// count and up to sample of the oldest event IDs.
+//
+// Keys of atomic batches (domain.BatchIdempotencyKey) are left out, as are keys
+// whose event was erased (event_deletions). The batch's members, which have no
+// keys of their own, do show up in EventsWithoutSuccess.
+func (c *Client) Reconcile(from, to time.Time, sample int) (_ *domain.Reconciliation, err error) {
+	ctx, done := c.read("reconcile", from, to, sample)
+	defer done(&err)
+
+	rec := &domain.Reconciliation{From: from, To: to}
+	rec.SucceededWithoutEvent, err = discrepancy(ctx, c.db, `
+		SELECT k.event_id, k.status, k.last_seen_at, COUNT(*) OVER ()
+		FROM idempotency_keys k
+		WHERE k.status = 'success' AND k.attempts > 0 AND k.event_id NOT LIKE 'batch:%'
+		  AND k.last_seen_at >= $1 AND k.last_seen_at < $2
+		  AND NOT EXISTS (SELECT 1 FROM events e WHERE e.event_id = k.event_id)
+		  AND NOT EXISTS (SELECT 1 FROM event_deletions d WHERE d.event_id = k.event_id)
+		ORDER BY k.last_seen_at, k.event_id
+		LIMIT $3`, from, to, sample)
+	if err != nil {
+		return nil, err
+	}
+	rec.EventsWithoutSuccess, err = discrepancy(ctx, c.db, `
+		SELECT e.event_id, COALESCE(k.status, ''), e.created_at, COUNT(*) OVER ()
+		FROM events e
+		LEFT JOIN idempotency_keys k ON k.event_id = e.event_id
+		WHERE e.created_at >= $1 AND e.created_at < $2
+		  AND k.status IS DISTINCT FROM 'success'
+		ORDER BY e.created_at, e.event_id
+		LIMIT $3`, from, to, sample)
+	if err != nil {
+		return nil, err
+	}
+	err = c.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM idempotency_keys k
+		WHERE k.status = 'success' AND k.attempts = 0
+		  AND k.last_seen_at >= $1 AND k.last_seen_at < $2
+		  AND NOT EXISTS (SELECT 1 FROM events e WHERE e.event_id = k.event_id)`,
+		from, to).Scan(&rec.ImportedWithoutEvent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count imported keys: %w", err)
+	}
+	return rec, nil
+}
+
+// discrepancy runs one direction of Reconcile: rows of event ID, key status, time
+// and the total count before the limit.
+func discrepancy(ctx context.Context, q *sql.DB, query string, args ...interface{}) (domain.Discrepancy, error) {
+	out := domain.Discrepancy{Sample: []domain.DiscrepantEvent{}}
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return out, fmt.Errorf("failed to reconcile: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d domain.DiscrepantEvent
+		if err := rows.Scan(&d.EventID, &d.KeyStatus, &d.At, &out.Count); err != nil {
+			return out, fmt.Errorf("failed to scan discrepancy: %w", err)
+		}
+		out.Sample = append(out.Sample, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+)
+
+// CreateRetryJob inserts a new bulk retry job. Sets job.CreatedAt/UpdatedAt.
+func (c *Client) CreateRetryJob(job *domain.RetryJob) (err error) {
+	ctx, done := c.write("create_retry_job", job.JobID, job.Reason)
+	defer done(&err)
+
+	err = c.db.QueryRowContext(ctx, `
+		INSERT INTO retry_jobs (job_id, reason, failed_from, failed_to, actor, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at
+	`, job.JobID, job.Reason, job.From, job.To, job.Actor, string(job.Status)).Scan(&job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert retry job: %w", err)
+	}
+	return nil
+}
+
+// GetRetryJob returns the bulk retry job jobID, or ErrNotFound.
+func (c *Client) GetRetryJob(jobID string) (_ *domain.RetryJob, err error) {
+	ctx, done := c.read("get_retry_job", jobID)
+	defer done(&err)
+
+	var row retryJobRow
+	err = c.db.QueryRowContext(ctx, `SELECT `+retryJobColumns.List()+` FROM retry_jobs WHERE job_id = $1`, jobID).
+		Scan(retryJobColumns.Dest(&row)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retry job: %w", err)
+	}
+	row.ErrorReason = row.errorReason.String
+	return &row.RetryJob, nil
+}
+
+// retryJobRow is a retry_jobs row; the nullable error_reason reads into a NullString.
+type retryJobRow struct {
+	domain.RetryJob
+	errorReason sql.NullString
+}
+
+var retryJobColumns = sqlrow.New("retry_jobs",
+	sqlrow.Col("job_id", func(r *retryJobRow) any { return &r.JobID }),
+	sqlrow.Col("reason", func(r *retryJobRow) any { return &r.Reason }),
+	sqlrow.Col("failed_from", func(r *retryJobRow) any { return &r.From }),
+	sqlrow.Col("failed_to", func(r *retryJobRow) any { return &r.To }),
+	sqlrow.Col("actor", func(r *retryJobRow) any { return &r.Actor }),
+	sqlrow.Col("status", func(r *retryJobRow) any { return &r.Status }),
+	sqlrow.Col("total_keys", func(r *retryJobRow) any { return &r.TotalKeys }),
+	sqlrow.Col("enqueued", func(r *retryJobRow) any { return &r.Enqueued }),
+	sqlrow.Col("skipped", func(r *retryJobRow) any { return &r.Skipped }),
+	sqlrow.Col("error_reason", func(r *retryJobRow) any { return &r.errorReason }),
+	sqlrow.Col("created_at", func(r *retryJobRow) any { return &r.CreatedAt }),
+	sqlrow.Col("updated_at", func(r *retryJobRow) any { return &r.UpdatedAt }),
+	sqlrow.Col("completed_at", func(r *retryJobRow) any { return &r.CompletedAt }),
+)
+
+// UpdateRetryJob saves the progress and outcome of job (status, counts, error,
+// completion time). Sets job.UpdatedAt.
+func (c *Client) UpdateRetryJob(job *domain.RetryJob) (err error) {
+	ctx, done := c.write("update_retry_job", job.JobID)
+	defer done(&err)
+
+	var errorReason *string
+	if job.ErrorReason != "" {
+		errorReason = &job.ErrorReason
+	}
+	err = c.db.QueryRowContext(ctx, `
+		UPDATE retry_jobs
+		SET status = $2, total_keys = $3, enqueued = $4, skipped = $5,
+		    error_reason = $6, completed_at = $7, updated_at = NOW()
+		WHERE job_id = $1
+		RETURNING updated_at
+	`, job.JobID, string(job.Status), job.TotalKeys, job.Enqueued, job.Skipped,
+		errorReason, job.CompletedAt).Scan(&job.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update retry job: %w", err)
+	}
+	return nil
+}
+
+// FailInterruptedRetryJobs marks pending and running retry jobs as failed with
+// reason, like FailInterruptedExports. Re-running the job is safe: keys already
+// re-enqueued are no longer 'failed', or fail again and are retried once more.
+func (c *Client) FailInterruptedRetryJobs(reason string) (_ int64, err error) {
+	ctx, done := c.write("fail_interrupted_retry_jobs", reason)
+	defer done(&err)
+
+	res, err := c.db.ExecContext(ctx, `
+		UPDATE retry_jobs
+		SET status = $1, error_reason = $2, completed_at = NOW(), updated_at = NOW()
+		WHERE status IN ($3, $4)
+	`, string(domain.RetryJobStatusFailed), reason, string(domain.RetryJobStatusPending), string(domain.RetryJobStatusRunning))
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted retry jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
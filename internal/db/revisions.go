@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+)
+
+// ReplaceEvent replaces the producer content of event.EventID (user, amount,
+// currency, merchant, timestamp, metadata, canary) with event's, idempotently: if
+// the stored content is already equal it changes nothing and returns the current
+// version with changed false. Otherwise ifMatch must be the stored version (a
+// *VersionConflictError says which it is; 0 means none was given); the old content
+// is saved to event_revisions under actor, the row is rewritten inline with
+// event.CanonicalMerchant, its version bumped, and the merchant roll-up and
+// payload reference moved with it. The client reference is the producer's key for
+// the event and, like its group, is never replaced. A missing or soft-deleted event is ErrNotFound.
+func (c *Client) ReplaceEvent(event *domain.Event, ifMatch int, actor string) (version int, changed bool, err error) {
+	ctx, done := c.write("replace_event", event.EventID, ifMatch)
+	defer done(&err)
+
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	// Match the precision events stores, so a retried PUT compares equal.
+	event.Amount = math.Round(event.Amount*100) / 100
+	event.Timestamp = event.Timestamp.Truncate(time.Microsecond)
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stored, version, deleted, err := lockEventContent(ctx, tx, event.EventID)
+	if err != nil {
+		return 0, false, err
+	}
+	if deleted {
+		return 0, false, ErrNotFound
+	}
+	storedMetadata, err := json.Marshal(stored.Metadata)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to marshal stored metadata: %w", err)
+	}
+	if sameContent(stored, event) && string(storedMetadata) == string(metadata) {
+		return version, false, nil
+	}
+	if ifMatch != version {
+		return 0, false, &VersionConflictError{Current: version}
+	}
+
+	content, err := json.Marshal(stored)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to marshal revision: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO event_revisions (event_id, version, content, actor) VALUES ($1, $2, $3, $4)`,
+		event.EventID, version, content, actor); err != nil {
+		return 0, false, fmt.Errorf("failed to record event revision: %w", err)
+	}
+
+	var canonicalMerchant *string
+	if event.CanonicalMerchant != "" {
+		canonicalMerchant = &event.CanonicalMerchant
+	}
+	for _, stmt := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{rollUpDelta, []interface{}{event.EventID, -1}},
+		// The replaced payload is no longer the event's; don't archive it for it.
+		{`DELETE FROM archive_outbox WHERE event_id = $1`, []interface{}{event.EventID}},
+		{`UPDATE payload_refs SET ref_count = ref_count - 1
+		  WHERE s3_key = (SELECT s3_key FROM events WHERE event_id = $1) AND ref_count > 0`, []interface{}{event.EventID}},
+		// Enrichment describes the user; drop it when the event moves to another one.
+		{`UPDATE events SET
+			user_id = $2, amount = $3, currency = $4, merchant = $5, canonical_merchant = $6,
+			ts = $7, metadata_json = $8, is_canary = $9, payload_mode = $10, s3_key = NULL,
+			enrichment_json = CASE WHEN user_id = $2 THEN enrichment_json END,
+			schema_version = NULLIF($11, ''),
+			version = version + 1
+		  WHERE event_id = $1`, []interface{}{
+			event.EventID, event.UserID, event.Amount, event.Currency, event.Merchant, canonicalMerchant,
+			event.Timestamp, string(metadata), event.Canary, string(domain.PayloadModeInline), event.SchemaVersion,
+		}},
+		{rollUpDelta, []interface{}{event.EventID, 1}},
+	} {
+		if _, err := tx.ExecContext(ctx, stmt.query, stmt.args...); err != nil {
+			return 0, false, fmt.Errorf("failed to replace event: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, false, fmt.Errorf("failed to commit event replacement: %w", err)
+	}
+	return version + 1, true, nil
+}
+
+// lockEventContent locks eventID's row for the rest of tx and returns its producer
+// content, version, and whether it is soft-deleted.
+func lockEventContent(ctx context.Context, tx *sql.Tx, eventID string) (*domain.Event, int, bool, error) {
+	e := &domain.Event{EventID: eventID}
+	var metadataJSON sql.NullString
+	var version int
+	var deleted bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT user_id, amount, currency, merchant, ts, metadata_json, is_canary,
+		       version, deleted_at IS NOT NULL
+		FROM events WHERE event_id = $1 FOR UPDATE`, eventID).Scan(
+		&e.UserID, &e.Amount, &e.Currency, &e.Merchant, &e.Timestamp, &metadataJSON, &e.Canary,
+		&version, &deleted)
+	if err == sql.ErrNoRows {
+		return nil, 0, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to lock event: %w", err)
+	}
+	e.Metadata = map[string]interface{}{}
+	if metadataJSON.Valid {
+		if err := json.Unmarshal([]byte(metadataJSON.String), &e.Metadata); err != nil {
+			return nil, 0, false, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+	e.Normalize()
+	return e, version, deleted, nil
+}
+
+// sameContent compares the scalar producer fields of two events; metadata is
+// compared separately, as canonical JSON.
+func sameContent(a, b *domain.Event) bool {
+	return a.UserID == b.UserID && a.Amount == b.Amount && a.Currency == b.Currency &&
+		a.Merchant == b.Merchant && a.Timestamp.Equal(b.Timestamp) && a.Canary == b.Canary
+}
+
+// ListEventRevisions returns the superseded contents of eventID, newest first.
+func (c *Client) ListEventRevisions(eventID string) (_ []domain.EventRevision, err error) {
+	ctx, done := c.read("list_event_revisions", eventID)
+	defer done(&err)
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+revisionColumns.List()+`
+		FROM event_revisions
+		WHERE event_id = $1
+		ORDER BY version DESC`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []domain.EventRevision{}
+	for rows.Next() {
+		var row revisionRow
+		if err := rows.Scan(revisionColumns.Dest(&row)...); err != nil {
+			return nil, fmt.Errorf("failed to scan event revision: %w", err)
+		}
+		if err := json.Unmarshal(row.content, &row.Content); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event revision: %w", err)
+		}
+		revisions = append(revisions, row.EventRevision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate event revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// EventRevisionAsOf returns the revision of eventID that was current at asOf:
+// the earliest one superseded after it. ErrNotFound means no PUT has replaced the
+// event since asOf, so its current content applies.
+func (c *Client) EventRevisionAsOf(eventID string, asOf time.Time) (_ *domain.EventRevision, err error) {
+	ctx, done := c.read("event_revision_as_of", eventID, asOf)
+	defer done(&err)
+
+	var row revisionRow
+	err = c.db.QueryRowContext(ctx, `
+		SELECT `+revisionColumns.List()+`
+		FROM event_revisions
+		WHERE event_id = $1 AND created_at > $2
+		ORDER BY version
+		LIMIT 1`, eventID, asOf).Scan(revisionColumns.Dest(&row)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event revision: %w", err)
+	}
+	if err := json.Unmarshal(row.content, &row.Content); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event revision: %w", err)
+	}
+	return &row.EventRevision, nil
+}
+
+// revisionRow is an event_revisions row with content still raw JSONB.
+type revisionRow struct {
+	domain.EventRevision
+	content []byte
+}
+
+var revisionColumns = sqlrow.New("event_revisions",
+	sqlrow.Col("revision_id", func(r *revisionRow) any { return &r.RevisionID }),
+	sqlrow.Col("event_id", func(r *revisionRow) any { return &r.EventID }),
+	sqlrow.Col("version", func(r *revisionRow) any { return &r.Version }),
+	sqlrow.Col("content", func(r *revisionRow) any { return &r.content }),
+	sqlrow.Col("actor", func(r *revisionRow) any { return &r.Actor }),
+	sqlrow.Col("created_at", func(r *revisionRow) any { return &r.CreatedAt }),
+)
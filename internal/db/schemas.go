@@ -0,0 +1,73 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/lib/pq"
+)
+
+// RecordSchemaFields records fields as seen in a payload of producerKey's schema
+// schemaVersion and returns the drift they show against what was seen before:
+// one SchemaDriftNewSchema when the schema had no fields yet, otherwise a
+// new_field or type_change for each path/type pair first seen now. Concurrent
+// samples with the same new pair report it once, from whichever inserts it.
+func (c *Client) RecordSchemaFields(producerKey, schemaVersion string, fields []domain.PayloadField) (_ []domain.SchemaDrift, err error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	ctx, done := c.write("record_schema_fields", producerKey, schemaVersion, len(fields))
+	defer done(&err)
+
+	paths := make([]string, len(fields))
+	types := make([]string, len(fields))
+	for i, f := range fields {
+		paths[i], types[i] = f.Path, f.Type
+	}
+	// known is read from the statement's snapshot, before the insert; xmax is 0
+	// only on rows the insert created rather than updated.
+	rows, err := c.db.QueryContext(ctx, `
+		WITH known AS (
+			SELECT field_path, json_type FROM payload_schema_fields
+			WHERE producer_key = $1 AND schema_version = $2
+		), seen AS (
+			INSERT INTO payload_schema_fields (producer_key, schema_version, field_path, json_type)
+			SELECT $1, $2, f.path, f.type FROM unnest($3::text[], $4::text[]) AS f(path, type)
+			ON CONFLICT (producer_key, schema_version, field_path, json_type) DO UPDATE
+			SET last_seen_at = NOW(), seen_count = payload_schema_fields.seen_count + 1
+			RETURNING field_path, json_type, xmax = 0 AS inserted
+		)
+		SELECT s.field_path, s.json_type, NOT EXISTS (SELECT 1 FROM known),
+		       COALESCE(array_agg(k.json_type ORDER BY k.json_type) FILTER (WHERE k.json_type IS NOT NULL), '{}')
+		FROM seen s LEFT JOIN known k ON k.field_path = s.field_path
+		WHERE s.inserted
+		GROUP BY s.field_path, s.json_type
+		ORDER BY s.field_path, s.json_type
+	`, producerKey, schemaVersion, pq.Array(paths), pq.Array(types))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record schema fields: %w", err)
+	}
+	defer rows.Close()
+
+	var drift []domain.SchemaDrift
+	for rows.Next() {
+		var field domain.PayloadField
+		var newSchema bool
+		var previous pq.StringArray
+		if err := rows.Scan(&field.Path, &field.Type, &newSchema, &previous); err != nil {
+			return nil, fmt.Errorf("failed to scan schema drift: %w", err)
+		}
+		if newSchema {
+			return []domain.SchemaDrift{{Kind: domain.SchemaDriftNewSchema, ProducerKey: producerKey, SchemaVersion: schemaVersion}}, nil
+		}
+		d := domain.SchemaDrift{Kind: domain.SchemaDriftNewField, ProducerKey: producerKey, SchemaVersion: schemaVersion, Field: field}
+		if len(previous) > 0 {
+			d.Kind, d.PreviousTypes = domain.SchemaDriftTypeChange, previous
+		}
+		drift = append(drift, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate schema drift: %w", err)
+	}
+	return drift, nil
+}
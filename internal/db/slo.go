@@ -0,0 +1,96 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+)
+
+// RollUpSLO rebuilds the slo_rollups rows of the hours from from's hour up to to,
+// from event_timelines and failed idempotency keys, counting latencies up to
+// target as within target. It recomputes whole hours, so running it again (or from
+// several processors) is harmless; hours with no events get no row.
+func (c *Client) RollUpSLO(from, to time.Time, target time.Duration) (_ int64, err error) {
+	ctx, done := c.write("roll_up_slo", from, to)
+	defer done(&err)
+
+	res, err := c.db.ExecContext(ctx, `
+		WITH latency AS (
+			SELECT date_trunc('hour', received_at) AS bucket,
+			       COUNT(*) AS processed,
+			       COUNT(*) FILTER (WHERE persisted_at - received_at <= $3::int * interval '1 millisecond') AS within_target,
+			       percentile_cont(0.50) WITHIN GROUP (ORDER BY ms) AS p50,
+			       percentile_cont(0.95) WITHIN GROUP (ORDER BY ms) AS p95,
+			       percentile_cont(0.99) WITHIN GROUP (ORDER BY ms) AS p99,
+			       MAX(ms) AS max
+			FROM (SELECT received_at, persisted_at, EXTRACT(EPOCH FROM persisted_at - received_at) * 1000 AS ms
+			      FROM event_timelines
+			      WHERE received_at >= date_trunc('hour', $1::timestamptz) AND received_at < $2) t
+			GROUP BY 1
+		), failures AS (
+			SELECT date_trunc('hour', last_seen_at) AS bucket, COUNT(*) AS failed
+			FROM idempotency_keys
+			WHERE status = 'failed' AND last_seen_at >= date_trunc('hour', $1::timestamptz) AND last_seen_at < $2
+			GROUP BY 1
+		)
+		INSERT INTO slo_rollups (bucket, processed, failed, within_target, target_ms, p50_ms, p95_ms, p99_ms, max_ms, updated_at)
+		SELECT bucket, COALESCE(l.processed, 0), COALESCE(f.failed, 0), COALESCE(l.within_target, 0), $3::int,
+		       l.p50, l.p95, l.p99, l.max, NOW()
+		FROM latency l FULL OUTER JOIN failures f USING (bucket)
+		ON CONFLICT (bucket) DO UPDATE SET
+			processed     = EXCLUDED.processed,
+			failed        = EXCLUDED.failed,
+			within_target = EXCLUDED.within_target,
+			target_ms     = EXCLUDED.target_ms,
+			p50_ms        = EXCLUDED.p50_ms,
+			p95_ms        = EXCLUDED.p95_ms,
+			p99_ms        = EXCLUDED.p99_ms,
+			max_ms        = EXCLUDED.max_ms,
+			updated_at    = NOW()
+	`, from, to, target.Milliseconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to roll up SLO: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ListSLORollups returns the slo_rollups rows from since's hour on, oldest first.
+func (c *Client) ListSLORollups(since time.Time) (_ []domain.SLORollup, err error) {
+	ctx, done := c.read("list_slo_rollups", since)
+	defer done(&err)
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+sloColumns.List()+`
+		FROM slo_rollups
+		WHERE bucket >= date_trunc('hour', $1::timestamptz)
+		ORDER BY bucket
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query SLO roll-ups: %w", err)
+	}
+	defer rows.Close()
+
+	var out []domain.SLORollup
+	for rows.Next() {
+		var r domain.SLORollup
+		if err := rows.Scan(sloColumns.Dest(&r)...); err != nil {
+			return nil, fmt.Errorf("failed to scan SLO roll-up: %w", err)
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+var sloColumns = sqlrow.New("slo_rollups",
+	sqlrow.Col("bucket", func(r *domain.SLORollup) any { return &r.Bucket }),
+	sqlrow.Col("processed", func(r *domain.SLORollup) any { return &r.Processed }),
+	sqlrow.Col("failed", func(r *domain.SLORollup) any { return &r.Failed }),
+	sqlrow.Col("within_target", func(r *domain.SLORollup) any { return &r.WithinTarget }),
+	sqlrow.Col("target_ms", func(r *domain.SLORollup) any { return &r.TargetMs }),
+	sqlrow.Col("p50_ms", func(r *domain.SLORollup) any { return &r.P50Ms }),
+	sqlrow.Col("p95_ms", func(r *domain.SLORollup) any { return &r.P95Ms }),
+	sqlrow.Col("p99_ms", func(r *domain.SLORollup) any { return &r.P99Ms }),
+	sqlrow.Col("max_ms", func(r *domain.SLORollup) any { return &r.MaxMs }),
+)
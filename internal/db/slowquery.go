@@ -0,0 +1,70 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+// WithSlowQueryLog logs, at WARN, every call that takes threshold or longer, and
+// counts it in slow_queries_total{operation}. A non-positive threshold disables
+// it. Returns c for chaining.
+func (c *Client) WithSlowQueryLog(threshold time.Duration, logger *logging.Logger) *Client {
+	c.slowQuery, c.logger = threshold, logger
+	return c
+}
+
+func (c *Client) logIfSlow(op string, params []interface{}, elapsed time.Duration) {
+	if c.slowQuery <= 0 || elapsed < c.slowQuery {
+		return
+	}
+	if c.metrics != nil {
+		c.metrics.IncCounter(metricdef.SlowQueriesTotal, "operation", op)
+	}
+	if c.logger != nil {
+		c.logger.Warn("Slow query", map[string]interface{}{
+			"operation":   op,
+			"params":      summarizeParams(params),
+			"duration_ms": elapsed.Milliseconds(),
+		})
+	}
+}
+
+// summarizeParams describes bound parameters without their values, which may be
+// user IDs, merchants or API key hashes: strings and slices show only their type
+// and length. Integers, booleans and times are kept, since limits, windows and
+// time ranges are what usually explain a slow plan.
+func summarizeParams(params []interface{}) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = fmt.Sprintf("$%d=%s", i+1, summarizeParam(p))
+	}
+	return strings.Join(parts, " ")
+}
+
+func summarizeParam(p interface{}) string {
+	if t, ok := p.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	v := reflect.ValueOf(p)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "null"
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return "null"
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(v.Interface())
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("%s(%d)", v.Type(), v.Len())
+	}
+	return v.Type().String()
+}
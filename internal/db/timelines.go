@@ -0,0 +1,95 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+	"github.com/lib/pq"
+)
+
+// RecordEventTimelines stores the received/dequeued/persisted timestamps of newly
+// persisted events. A row that already exists is kept, so a redelivery can't move
+// an event's timeline after the fact.
+func (c *Client) RecordEventTimelines(timelines []domain.EventTimeline) (err error) {
+	if len(timelines) == 0 {
+		return nil
+	}
+	ctx, done := c.write("record_event_timelines", timelines)
+	defer done(&err)
+
+	ids := make([]string, len(timelines))
+	received := make([]time.Time, len(timelines))
+	dequeued := make([]time.Time, len(timelines))
+	persisted := make([]time.Time, len(timelines))
+	for i, t := range timelines {
+		ids[i], received[i], dequeued[i], persisted[i] = t.EventID, t.ReceivedAt, t.DequeuedAt, t.PersistedAt
+	}
+	_, err = c.db.ExecContext(ctx, `
+		INSERT INTO event_timelines (event_id, received_at, dequeued_at, persisted_at)
+		SELECT * FROM unnest($1::text[], $2::timestamptz[], $3::timestamptz[], $4::timestamptz[])
+		ON CONFLICT (event_id) DO NOTHING
+	`, pq.Array(ids), pq.Array(received), pq.Array(dequeued), pq.Array(persisted))
+	if err != nil {
+		return fmt.Errorf("failed to record event timelines: %w", err)
+	}
+	return nil
+}
+
+// MarkEventNotified sets the event's notified_at to the first successful ack
+// delivery. An event without a timeline row is left alone.
+func (c *Client) MarkEventNotified(eventID string, at time.Time) (err error) {
+	ctx, done := c.write("mark_event_notified", eventID, at)
+	defer done(&err)
+
+	_, err = c.db.ExecContext(ctx,
+		`UPDATE event_timelines SET notified_at = $2 WHERE event_id = $1 AND notified_at IS NULL`,
+		eventID, at)
+	if err != nil {
+		return fmt.Errorf("failed to mark event notified: %w", err)
+	}
+	return nil
+}
+
+// GetEventTimelines returns the timelines of eventIDs in one query. IDs without a
+// timeline (not yet persisted, or persisted before timelines were recorded) are
+// absent from the map.
+func (c *Client) GetEventTimelines(eventIDs []string) (_ map[string]*domain.EventTimeline, err error) {
+	out := make(map[string]*domain.EventTimeline, len(eventIDs))
+	if len(eventIDs) == 0 {
+		return out, nil
+	}
+	ctx, done := c.read("get_event_timelines", eventIDs)
+	defer done(&err)
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT `+timelineColumns.List()+`
+		FROM event_timelines
+		WHERE event_id = ANY($1)
+	`, pq.Array(eventIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event timelines: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t domain.EventTimeline
+		if err := rows.Scan(timelineColumns.Dest(&t)...); err != nil {
+			return nil, fmt.Errorf("failed to scan event timeline: %w", err)
+		}
+		out[t.EventID] = &t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate event timelines: %w", err)
+	}
+	return out, nil
+}
+
+var timelineColumns = sqlrow.New("event_timelines",
+	sqlrow.Col("event_id", func(t *domain.EventTimeline) any { return &t.EventID }),
+	sqlrow.Col("received_at", func(t *domain.EventTimeline) any { return &t.ReceivedAt }),
+	sqlrow.Col("dequeued_at", func(t *domain.EventTimeline) any { return &t.DequeuedAt }),
+	sqlrow.Col("persisted_at", func(t *domain.EventTimeline) any { return &t.PersistedAt }),
+	sqlrow.Col("notified_at", func(t *domain.EventTimeline) any { return &t.NotifiedAt }),
+)
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/lib/pq"
+)
+
+// Default per-call deadlines; WithTimeouts overrides them. Batch inserts write many
+// rows in one transaction and get batchTimeout instead.
+const (
+	defaultReadTimeout  = 5 * time.Second
+	defaultWriteTimeout = 5 * time.Second
+	batchTimeout        = 30 * time.Second
+)
+
+// queryCanceled is SQLSTATE 57014, raised by statement_timeout and by the cancel
+// request lib/pq sends when a context expires mid-query.
+const queryCanceled = "57014"
+
+// WithTimeouts sets the deadline of each read (SELECT-only) and write call. A
+// non-positive value keeps the default. Returns c for chaining.
+func (c *Client) WithTimeouts(read, write time.Duration) *Client {
+	if read > 0 {
+		c.readTimeout = read
+	}
+	if write > 0 {
+		c.writeTimeout = write
+	}
+	return c
+}
+
+// WithMetrics enables db_timeouts_total{operation} and slow_queries_total{operation}.
+// Returns c for chaining.
+func (c *Client) WithMetrics(m ports.Metrics) *Client {
+	c.metrics = m
+	return c
+}
+
+// read starts a read call named op with the given parameters, which are only
+// summarised in the slow query log. The returned done must be deferred with the
+// call's error: it cancels the context, turns a timeout into a RetryableError, and
+// logs the call if it was slow.
+func (c *Client) read(op string, params ...interface{}) (context.Context, func(*error)) {
+	return c.begin(op, c.readTimeout, params...)
+}
+
+// write is read for calls that modify rows.
+func (c *Client) write(op string, params ...interface{}) (context.Context, func(*error)) {
+	return c.begin(op, c.writeTimeout, params...)
+}
+
+func (c *Client) begin(op string, timeout time.Duration, params ...interface{}) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	return ctx, func(err *error) {
+		expired := ctx.Err() == context.DeadlineExceeded
+		cancel()
+		c.logIfSlow(op, params, time.Since(start))
+		if *err != nil && (expired || isTimeout(*err)) {
+			*err = c.timedOut(op, *err)
+		}
+	}
+}
+
+// classify is done for calls that run under the caller's context.
+func (c *Client) classify(op string, err error) error {
+	if err != nil && isTimeout(err) {
+		return c.timedOut(op, err)
+	}
+	return err
+}
+
+func (c *Client) timedOut(op string, err error) error {
+	if c.metrics != nil {
+		c.metrics.IncCounter(metricdef.DBTimeoutsTotal, "operation", op)
+	}
+	return domain.NewRetryableError("db_timeout", err)
+}
+
+// isTimeout reports whether err is a client deadline or a server-side cancel.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == queryCanceled
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+)
+
+// UpsertProducerWebhook creates or replaces the ack webhook for w.APIKeyHash and
+// sets w.CreatedAt/UpdatedAt from the stored row.
+func (c *Client) UpsertProducerWebhook(w *domain.ProducerWebhook) (err error) {
+	ctx, done := c.write("upsert_producer_webhook", w.APIKeyHash)
+	defer done(&err)
+
+	query := `
+		INSERT INTO producer_webhooks (api_key_hash, url, secret, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (api_key_hash) DO UPDATE
+			SET url = EXCLUDED.url, secret = EXCLUDED.secret, updated_at = EXCLUDED.updated_at
+		RETURNING created_at, updated_at
+	`
+	err = c.db.QueryRowContext(ctx, query, w.APIKeyHash, w.URL, w.Secret, time.Now().UTC()).
+		Scan(&w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert producer webhook: %w", err)
+	}
+	return nil
+}
+
+// GetProducerWebhook returns the webhook registered for apiKeyHash, or ErrNotFound.
+func (c *Client) GetProducerWebhook(apiKeyHash string) (_ *domain.ProducerWebhook, err error) {
+	ctx, done := c.read("get_producer_webhook", apiKeyHash)
+	defer done(&err)
+
+	w := &domain.ProducerWebhook{}
+	err = c.db.QueryRowContext(ctx,
+		`SELECT `+webhookColumns.List()+` FROM producer_webhooks WHERE api_key_hash = $1`,
+		apiKeyHash).Scan(webhookColumns.Dest(w)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get producer webhook: %w", err)
+	}
+	return w, nil
+}
+
+var webhookColumns = sqlrow.New("producer_webhooks",
+	sqlrow.Col("api_key_hash", func(w *domain.ProducerWebhook) any { return &w.APIKeyHash }),
+	sqlrow.Col("url", func(w *domain.ProducerWebhook) any { return &w.URL }),
+	sqlrow.Col("secret", func(w *domain.ProducerWebhook) any { return &w.Secret }),
+	sqlrow.Col("created_at", func(w *domain.ProducerWebhook) any { return &w.CreatedAt }),
+	sqlrow.Col("updated_at", func(w *domain.ProducerWebhook) any { return &w.UpdatedAt }),
+)
+
+// DeleteProducerWebhook removes the webhook for apiKeyHash. Returns ErrNotFound if none existed.
+func (c *Client) DeleteProducerWebhook(apiKeyHash string) (err error) {
+	ctx, done := c.write("delete_producer_webhook", apiKeyHash)
+	defer done(&err)
+
+	res, err := c.db.ExecContext(ctx, `DELETE FROM producer_webhooks WHERE api_key_hash = $1`, apiKeyHash)
+	if err != nil {
+		return fmt.Errorf("failed to delete producer webhook: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Package diagnostics logs what a service is running as one structured entry at
+// startup: build (Go version, VCS revision, key dependency versions), a checksum
+// of the effective configuration, feature flag values, the schema it was built
+// against, and its database pool. With DIAGNOSTICS_SELF_CHECK the entry also
+// carries the result of a quick self-check: every readiness check run once, and
+// the live schema checked against the columns the binary reads.
+package diagnostics
+
+import (
+	"context"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/config"
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/health"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+	"github.com/fluxa/fluxa/migrations"
+)
+
+// dependencies are the modules whose versions are worth knowing in an incident:
+// the drivers and SDKs every service talks to its dependencies through.
+var dependencies = []string{
+	"github.com/lib/pq",
+	"github.com/rabbitmq/amqp091-go",
+	"github.com/minio/minio-go/v7",
+	"google.golang.org/grpc",
+	"github.com/prometheus/client_golang",
+}
+
+// selfCheckTimeout bounds the whole self-check, so it never holds up startup.
+const selfCheckTimeout = 5 * time.Second
+
+// Startup describes a starting service. DB and Flags are nil for services without
+// them.
+type Startup struct {
+	Service string
+	Config  *config.Config
+	Flags   *featureflags.Flags
+	Probes  *health.Probes
+	DB      *db.Client
+	Pool    *db.PoolOptions
+}
+
+// Report is the startup diagnostics entry.
+type Report struct {
+	Service        string            `json:"service"`
+	GoVersion      string            `json:"go_version"`
+	Revision       string            `json:"revision,omitempty"`
+	Modified       bool              `json:"modified,omitempty"`
+	Dependencies   map[string]string `json:"dependencies,omitempty"`
+	ConfigChecksum string            `json:"config_checksum"`
+	Features       map[string]bool   `json:"features,omitempty"`
+	SchemaVersion  string            `json:"schema_version"`
+	DBPool         *Pool             `json:"db_pool,omitempty"`
+	SelfCheck      *SelfCheck        `json:"self_check,omitempty"`
+}
+
+// Pool is the database pool settings.
+type Pool struct {
+	Strategy      string  `json:"strategy"`
+	MaxOpen       int     `json:"max_open"`
+	MaxIdle       int     `json:"max_idle"`
+	IdleTimeoutS  float64 `json:"idle_timeout_s,omitempty"`
+	LifetimeS     float64 `json:"lifetime_s"`
+	LazyConnect   bool    `json:"lazy_connect,omitempty"`
+	StatementTOMs int     `json:"statement_timeout_ms,omitempty"`
+}
+
+// SelfCheck is the outcome of the startup self-check.
+type SelfCheck struct {
+	OK         bool              `json:"ok"`
+	Checks     map[string]string `json:"checks,omitempty"`
+	Schema     []string          `json:"schema_errors,omitempty"`
+	DurationMs int64             `json:"duration_ms"`
+}
+
+// Collect builds the report for s, without the self-check.
+func Collect(s Startup) Report {
+	r := Report{
+		Service:        s.Service,
+		ConfigChecksum: s.Config.Checksum(),
+		SchemaVersion:  migrations.Latest(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		r.GoVersion = info.GoVersion
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				r.Revision = setting.Value
+			case "vcs.modified":
+				r.Modified = setting.Value == "true"
+			}
+		}
+		versions := map[string]string{}
+		for _, dep := range info.Deps {
+			versions[dep.Path] = dep.Version
+		}
+		for _, path := range dependencies {
+			if v, ok := versions[path]; ok {
+				if r.Dependencies == nil {
+					r.Dependencies = map[string]string{}
+				}
+				r.Dependencies[path] = v
+			}
+		}
+	}
+	if s.Flags != nil {
+		r.Features = map[string]bool{}
+		for flag := range featureflags.Known() {
+			r.Features[string(flag)] = s.Flags.Enabled(flag)
+		}
+	}
+	if s.Pool != nil {
+		r.DBPool = &Pool{
+			Strategy:     s.Config.DBPoolStrategy,
+			MaxOpen:      s.Pool.MaxOpen,
+			MaxIdle:      s.Pool.MaxIdle,
+			IdleTimeoutS: s.Pool.MaxIdleTime.Seconds(),
+			LifetimeS:    s.Pool.MaxLifetime.Seconds(),
+			LazyConnect:  s.Config.DBLazyConnect,
+		}
+		if s.Config.DBPoolStrategy != db.PoolSingle {
+			r.DBPool.StatementTOMs = s.Config.DBStatementTimeoutMs
+		}
+	}
+	return r
+}
+
+// Check runs the self-check: s.Probes' readiness checks once and, with a
+// database, the live schema against every projection the binary reads.
+func Check(ctx context.Context, s Startup) *SelfCheck {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, selfCheckTimeout)
+	defer cancel()
+
+	sc := &SelfCheck{OK: true}
+	if s.Probes != nil {
+		ready := s.Probes.Ready(ctx)
+		sc.Checks = ready.Checks
+		sc.OK = ready.Status == "ready"
+	}
+	if s.DB != nil {
+		mismatches, err := s.DB.CheckSchema(ctx, sqlrow.Registered())
+		if err != nil {
+			mismatches = []error{err}
+		}
+		for _, m := range mismatches {
+			sc.Schema = append(sc.Schema, m.Error())
+		}
+		sort.Strings(sc.Schema)
+		if len(sc.Schema) > 0 {
+			sc.OK = false
+		}
+	}
+	sc.DurationMs = time.Since(start).Milliseconds()
+	return sc
+}
+
+// Log writes the startup diagnostics entry for s, running the self-check first
+// when the config enables it. A failed self-check is logged at WARN; the service
+// starts anyway, and /readyz reports dependency failures from then on.
+func Log(ctx context.Context, logger *logging.Logger, s Startup) Report {
+	r := Collect(s)
+	if s.Config.DiagnosticsSelfCheck {
+		r.SelfCheck = Check(ctx, s)
+	}
+	fields := map[string]interface{}{"diagnostics": r}
+	if r.SelfCheck != nil && !r.SelfCheck.OK {
+		logger.Warn("Startup diagnostics: self-check failed", fields)
+	} else {
+		logger.Info("Startup diagnostics", fields)
+	}
+	return r
+}
@@ -0,0 +1,46 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/config"
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/health"
+	"github.com/fluxa/fluxa/migrations"
+)
+
+func TestCollect(t *testing.T) {
+	cfg := &config.Config{DBPoolStrategy: db.PoolSingle, DBStatementTimeoutMs: 5000}
+	pool := db.PoolFor(db.PoolSingle, 30*time.Second)
+	r := Collect(Startup{Service: "query", Config: cfg, Flags: featureflags.New(time.Minute, nil), Pool: &pool})
+
+	if r.ConfigChecksum != cfg.Checksum() || r.SchemaVersion != migrations.Latest() || r.SchemaVersion == "" {
+		t.Errorf("checksum, schema = %q, %q; want the config's and the latest migration", r.ConfigChecksum, r.SchemaVersion)
+	}
+	if len(r.Features) != len(featureflags.Known()) {
+		t.Errorf("features = %v, want every known flag", r.Features)
+	}
+	if r.DBPool == nil || r.DBPool.MaxOpen != 1 || r.DBPool.IdleTimeoutS != 30 || r.DBPool.StatementTOMs != 0 {
+		t.Errorf("db_pool = %+v, want the single-connection pool without a statement timeout", r.DBPool)
+	}
+	if r.SelfCheck != nil {
+		t.Error("Collect ran the self-check")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	probes := health.New()
+	probes.Add("queue", func(context.Context) error { return nil })
+	if sc := Check(context.Background(), Startup{Probes: probes}); !sc.OK || sc.Checks["queue"] != "ok" {
+		t.Errorf("self-check = %+v, want ok", sc)
+	}
+
+	probes.Add("db", func(context.Context) error { return errors.New("connection refused") })
+	if sc := Check(context.Background(), Startup{Probes: probes}); sc.OK || sc.Checks["db"] != "connection refused" {
+		t.Errorf("self-check = %+v, want failed on db", sc)
+	}
+}
@@ -0,0 +1,12 @@
+package domain
+
+// ArchiveTask is a queued archival (archive_outbox): the payload of an event
+// whose sampled upload failed at ingest, to be stored under its producer's
+// content-addressed key. Attempts counts the failed retries so far.
+type ArchiveTask struct {
+	EventID       string
+	ProducerKey   string
+	PayloadSHA256 string
+	Payload       []byte
+	Attempts      int
+}
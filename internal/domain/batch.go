@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"time"
+)
+
+// MaxBatchEvents is the most events one POST /events/batch may carry.
+const MaxBatchEvents = 1000
+
+// BatchStatus is the lifecycle of a submitted batch. Atomic batches end in success
+// or failed; non-atomic ones go from processing to complete once every submitted
+// member has been processed or failed.
+type BatchStatus string
+
+const (
+	BatchStatusProcessing BatchStatus = "processing"
+	BatchStatusSuccess    BatchStatus = "success"
+	BatchStatusFailed     BatchStatus = "failed"
+	BatchStatusComplete   BatchStatus = "complete"
+)
+
+// BatchPayload is the queue payload of an atomic batch. All members travel in one
+// QueueMessage (Atomic set) so the processor can validate them together and persist
+// them in one transaction; the envelope's hash and S3 offload cover the whole batch.
+type BatchPayload struct {
+	Events []Event `json:"events"`
+	// Rejected lists the members of a partial batch ingest already turned away
+	// (stale events), so the batch record counts them among its failures.
+	Rejected []BatchFailure `json:"rejected,omitempty"`
+}
+
+// BatchIdempotencyKey is the QueueMessage.EventID (and idempotency key) of an atomic
+// batch's message. The prefix keeps it from colliding with member event IDs.
+func BatchIdempotencyKey(batchID string) string {
+	return "batch:" + batchID
+}
+
+// Batch is the progress of a batch submission (GET /batches/{id}). Submitted is the
+// number of members enqueued, plus for a partial batch those ingest rejected;
+// Processed and Failed count those that reached a terminal status. A failed
+// atomic batch persisted none of its events; FailedEventID and ErrorReason name
+// the first member that failed, or only ErrorReason when the batch as a whole was
+// unreadable. A partial batch ends success when every member was persisted,
+// failed when none was, and complete otherwise.
+type Batch struct {
+	BatchID       string      `json:"batch_id"`
+	Atomic        bool        `json:"atomic"`
+	Status        BatchStatus `json:"status"`
+	Submitted     int         `json:"submitted"`
+	Processed     int         `json:"processed"`
+	Failed        int         `json:"failed"`
+	FailedEventID string      `json:"failed_event_id,omitempty"`
+	ErrorReason   string      `json:"error_reason,omitempty"`
+	// Partial marks an atomic batch whose members may fail one by one; Failures
+	// lists them.
+	Partial   bool           `json:"partial,omitempty"`
+	Failures  []BatchFailure `json:"failures,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// BatchFailure is a member of a partial batch that was not persisted, with its
+// reason code (e.g. "validation_error", "db_insert_failed").
+type BatchFailure struct {
+	EventID string `json:"event_id"`
+	Reason  string `json:"reason"`
+}
+
+// Drained reports whether every submitted member has reached a terminal status.
+func (b *Batch) Drained() bool {
+	return b.Processed+b.Failed >= b.Submitted
+}
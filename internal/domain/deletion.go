@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// EventDeletionAction is what an admin did to an event's deletion state.
+type EventDeletionAction string
+
+const (
+	// EventSoftDelete hides the event from reads and aggregates; it can be restored.
+	EventSoftDelete EventDeletionAction = "soft_delete"
+	// EventRestore undoes a soft delete.
+	EventRestore EventDeletionAction = "restore"
+	// EventHardDelete erases the event and its fraud flags for good (GDPR).
+	EventHardDelete EventDeletionAction = "hard_delete"
+)
+
+// EventDeletion is one audited delete, restore, or erasure of an event. Version is
+// the event's version after the action (0 once erased).
+type EventDeletion struct {
+	DeletionID int64               `json:"deletion_id"`
+	EventID    string              `json:"event_id"`
+	Action     EventDeletionAction `json:"action"`
+	Actor      string              `json:"actor"`
+	Reason     string              `json:"reason,omitempty"`
+	Version    int                 `json:"version"`
+	CreatedAt  time.Time           `json:"created_at"`
+}
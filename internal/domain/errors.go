@@ -49,3 +49,61 @@ func (e *RetryableError) Unwrap() error {
 func NewRetryableError(reason string, err error) error {
 	return &RetryableError{Reason: reason, Err: err}
 }
+
+// ClientReferenceConflictError is an event reusing a client reference another event
+// of the same producer holds. ExistingEventID is that event, when it could be
+// looked up (not inside a batch transaction, which the conflict aborts); Err is
+// the database's unique violation.
+type ClientReferenceConflictError struct {
+	EventID         string
+	ClientReference string
+	ExistingEventID string
+	Err             error
+}
+
+func (e *ClientReferenceConflictError) Error() string {
+	if e.ExistingEventID != "" {
+		return fmt.Sprintf("client reference %q already used by event %s", e.ClientReference, e.ExistingEventID)
+	}
+	return fmt.Sprintf("client reference %q already used", e.ClientReference)
+}
+
+func (e *ClientReferenceConflictError) Unwrap() error {
+	return e.Err
+}
+
+// NotFoundError indicates the referenced resource (object, queue, exchange) does
+// not exist. Retrying cannot help; callers decide whether that is fatal.
+type NotFoundError struct {
+	Resource string
+	Err      error
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("not found: %s: %v", e.Resource, e.Err)
+	}
+	return fmt.Sprintf("not found: %s", e.Resource)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// NewNotFoundError creates a new NotFoundError.
+func NewNotFoundError(resource string, err error) error {
+	return &NotFoundError{Resource: resource, Err: err}
+}
+
+// TooLargeError is an object or payload over the size a reader accepts. Size is
+// what is known of the actual size: the stored size, or Limit+1 when reading
+// stopped past the limit. Retrying cannot help.
+type TooLargeError struct {
+	Resource string
+	Size     int64
+	Limit    int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("too large: %s: %d bytes, limit %d", e.Resource, e.Size, e.Limit)
+}
@@ -2,7 +2,13 @@ package domain
 
 import (
 	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // Event represents a transaction event in the system.
@@ -14,16 +20,225 @@ type Event struct {
 	Merchant  string                 `json:"merchant" binding:"required"`
 	Timestamp time.Time              `json:"timestamp" binding:"required"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	// Canary marks a synthetic end-to-end check. Canaries run the full pipeline but are
+	// excluded from aggregates, roll-ups, and feature exports.
+	Canary bool `json:"canary,omitempty"`
+	// Priority is PriorityHigh for latency-sensitive events (e.g. auth decisions),
+	// which skip the queue bulk traffic waits in. Empty means normal.
+	Priority string `json:"priority,omitempty"`
+	// ClientReference is the producer's own ID for the event (an order or
+	// transaction ID). It is unique per producer: a second event with the same
+	// reference fails with client_reference_conflict.
+	ClientReference string `json:"client_reference,omitempty"`
+	// GroupID correlates events that belong together, such as the legs of a
+	// transfer, and GroupSize is how many events the group has. A group completes
+	// once GroupSize members are persisted. Both are set or neither is.
+	GroupID   string `json:"group_id,omitempty"`
+	GroupSize int    `json:"group_size,omitempty"`
+	// SchemaVersion is the producer's label for its payload layout. The processor
+	// tracks schema drift per producer and version (internal/schemadrift) and
+	// upgrades old versions to the latest (internal/schemamigrate) before storing
+	// the event with the version it reached.
+	SchemaVersion string `json:"schema_version,omitempty"`
+	// Sensitive asks ingest to encrypt the payload while it is queued (see
+	// QueueMessage.Encryption). It does not change how the event is stored.
+	Sensitive bool `json:"sensitive,omitempty"`
+	// EventType names the JSON Schema the event is validated against on top of
+	// the base event schema (internal/schema). Empty is the base schema alone. It
+	// is not stored.
+	EventType string `json:"event_type,omitempty"`
+
+	// ProducerKey is the hashed API key of the submitting producer, set by the
+	// processor from the queue message; never read from producers.
+	ProducerKey string `json:"-"`
+	// CanonicalMerchant is resolved by the processor (internal/merchant); never read from producers.
+	CanonicalMerchant string `json:"-"`
+	// Enrichment holds user attributes attached by the processor (internal/enrichment).
+	Enrichment map[string]string `json:"-"`
+	// PendingArchive is the raw payload of an event whose archival failed at ingest
+	// (QueueMessage.ArchivePending), set by the processor; InsertEvent queues it in
+	// archive_outbox together with the row.
+	PendingArchive []byte `json:"-"`
+}
+
+// NewEvent builds a normalized Event from its fields. eventID may be empty;
+// ingest assigns one when the producer didn't.
+func NewEvent(eventID, userID string, amount float64, currency, merchant string, ts time.Time, metadata map[string]interface{}) *Event {
+	e := &Event{
+		EventID:   eventID,
+		UserID:    userID,
+		Amount:    amount,
+		Currency:  currency,
+		Merchant:  merchant,
+		Timestamp: ts,
+		Metadata:  metadata,
+	}
+	e.Normalize()
+	return e
+}
+
+// Normalize sanitizes producer input in place: trims string fields, uppercases
+// the currency code, converts the timestamp to UTC, and defaults Metadata to an
+// empty map. It is idempotent, so ingest and processor can both apply it.
+func (e *Event) Normalize() {
+	e.EventID = strings.TrimSpace(e.EventID)
+	e.UserID = strings.TrimSpace(e.UserID)
+	e.Currency = strings.ToUpper(strings.TrimSpace(e.Currency))
+	e.Merchant = strings.TrimSpace(e.Merchant)
+	e.ClientReference = strings.TrimSpace(e.ClientReference)
+	e.GroupID = strings.TrimSpace(e.GroupID)
+	e.SchemaVersion = strings.TrimSpace(e.SchemaVersion)
+	e.EventType = strings.ToLower(strings.TrimSpace(e.EventType))
+	e.Timestamp = e.Timestamp.UTC()
+	e.Priority = strings.ToLower(strings.TrimSpace(e.Priority))
+	if e.Priority == PriorityNormal {
+		e.Priority = ""
+	}
+	if e.Metadata == nil {
+		e.Metadata = map[string]interface{}{}
+	}
+}
+
+// MaxClientReferenceLen bounds ClientReference, the width of its events column.
+const MaxClientReferenceLen = 255
+
+// MaxSchemaVersionLen bounds SchemaVersion, the width of its payload_schema_fields
+// column.
+const MaxSchemaVersionLen = 64
+
+// MaxEventTypeLen bounds EventType.
+const MaxEventTypeLen = 64
+
+// eventTypePattern is the shape of an EventType: lowercase letters, digits, '_',
+// '.' and '-', starting with a letter or digit.
+var eventTypePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// ValidEventType reports whether name is a well-formed event type name.
+func ValidEventType(name string) bool {
+	return len(name) <= MaxEventTypeLen && eventTypePattern.MatchString(name)
+}
+
+// eventIDNamespace is the UUID namespace of derived event IDs.
+var eventIDNamespace = uuid.MustParse("84c9fd80-8999-4d0a-bf6b-976b3fea620b")
+
+// DerivedID returns a name-based (version 5) UUID of the fields that identify a
+// submission: user, timestamp, amount, currency, merchant and client reference. Resubmitting the same event yields the same ID,
+// so ingest can give ID-less events one that dedupes (INGEST_DERIVE_EVENT_IDS).
+// Call it on a normalized event.
+func (e *Event) DerivedID() string {
+	name := strings.Join([]string{
+		e.UserID,
+		e.Timestamp.UTC().Format(time.RFC3339Nano),
+		strconv.FormatFloat(e.Amount, 'f', -1, 64),
+		e.Currency,
+		e.Merchant,
+		e.ClientReference,
+	}, "\x1f")
+	return uuid.NewSHA1(eventIDNamespace, []byte(name)).String()
+}
+
+// MaxIdempotencyKeyLen bounds the Idempotency-Key header ingest accepts.
+const MaxIdempotencyKeyLen = 255
+
+// IdempotencyKeyEventID returns the event ID for an ID-less event posted with
+// Idempotency-Key key by the producer with hashed API key producerKey: a
+// name-based UUID of both, so a retry gets the original's ID and dedupes like a
+// repeated event_id, and two producers' keys never meet.
+func IdempotencyKeyEventID(producerKey, key string) string {
+	return uuid.NewSHA1(eventIDNamespace, []byte("idempotency-key\x1f"+producerKey+"\x1f"+key)).String()
 }
 
+// Event priorities. Ingest routes PriorityHigh events to the priority queue.
+const (
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
 // Validation error codes
 const (
 	ErrCodeMissingField = "MISSING_FIELD"
 	ErrCodeInvalidValue = "INVALID_VALUE"
+	ErrCodeEventTooOld  = "EVENT_TOO_OLD"
 )
 
-// Validate performs basic validation on the event.
+// DefaultMaxFutureDrift is the clock skew allowed for timestamps ahead of the
+// server clock when ValidationConfig doesn't set one.
+const DefaultMaxFutureDrift = 5 * time.Minute
+
+// ValidationConfig holds the tolerances applied by ValidateWith: clock skew for
+// the timestamp and size limits for metadata. The zero value allows
+// DefaultMaxFutureDrift ahead, any age behind, and the default metadata limits.
+type ValidationConfig struct {
+	// MaxFutureDrift is how far ahead of the server clock a timestamp may be;
+	// zero means DefaultMaxFutureDrift.
+	MaxFutureDrift time.Duration
+	// MaxAge is how far behind it may be (ErrCodeEventTooOld); zero disables the
+	// check. Ingest sets it per producer; the processor leaves it unset because
+	// events keep aging while queued.
+	MaxAge time.Duration
+
+	// Metadata limits (see validateMetadata); zero selects the Default* value.
+	MetadataMaxKeys  int // top-level keys
+	MetadataMaxDepth int // nesting levels, counting metadata itself
+	MetadataMaxBytes int // serialized JSON size
+	// MetadataKeysOnly skips the depth, type, and size checks and enforces only
+	// MetadataMaxKeys; it is the rollback for the strict_metadata_validation flag.
+	MetadataKeysOnly bool
+
+	// Schemas checks the event's fields against its JSON Schemas in place of the
+	// built-in checks (validateFields). Ingest and the processor set it to the
+	// schema registry; nil keeps the built-in checks.
+	Schemas EventSchemas
+}
+
+// EventSchemas validates events against the JSON Schema of their event type
+// (internal/schema.Registry).
+type EventSchemas interface {
+	// ValidateEvent returns an ErrInvalidEvent for an event its schema rejects.
+	ValidateEvent(e *Event) error
+}
+
+// Validate performs basic validation on the event with the default tolerances.
 func (e *Event) Validate() error {
+	return e.ValidateWith(ValidationConfig{}, time.Now())
+}
+
+// ValidateWith performs basic validation on the event, checking its timestamp
+// against now and its metadata with vc's tolerances. Timestamp errors name the observed skew and the
+// allowed one, so producers with drifting clocks can tell how far off they are.
+func (e *Event) ValidateWith(vc ValidationConfig, now time.Time) error {
+	validate := e.validateFields
+	if vc.Schemas != nil {
+		validate = func() error { return vc.Schemas.ValidateEvent(e) }
+	}
+	if err := validate(); err != nil {
+		return err
+	}
+	if e.Timestamp.IsZero() {
+		return ErrInvalidEvent{Field: "timestamp", Reason: "must be set", Code: ErrCodeMissingField}
+	}
+	drift := vc.MaxFutureDrift
+	if drift <= 0 {
+		drift = DefaultMaxFutureDrift
+	}
+	if skew := e.Timestamp.Sub(now); skew > drift {
+		return ErrInvalidEvent{
+			Field:  "timestamp",
+			Reason: fmt.Sprintf("cannot be in the future: %s ahead of server time, max drift %s", skew.Round(time.Second), drift),
+			Code:   ErrCodeInvalidValue,
+		}
+	}
+	if err := e.CheckAge(now, vc.MaxAge); err != nil {
+		return err
+	}
+
+	return validateMetadata(e.Metadata, vc)
+}
+
+// validateFields checks the fields the base event schema (internal/schema)
+// covers, for callers without a schema registry. The two are kept in agreement
+// by internal/schema's tests.
+func (e *Event) validateFields() error {
 	if e.UserID == "" {
 		return ErrInvalidEvent{Field: "user_id", Reason: "cannot be empty", Code: ErrCodeMissingField}
 	}
@@ -36,19 +251,53 @@ func (e *Event) Validate() error {
 	if e.Merchant == "" {
 		return ErrInvalidEvent{Field: "merchant", Reason: "cannot be empty", Code: ErrCodeMissingField}
 	}
-	if e.Timestamp.IsZero() {
-		return ErrInvalidEvent{Field: "timestamp", Reason: "must be set", Code: ErrCodeMissingField}
+	if e.Priority != "" && e.Priority != PriorityNormal && e.Priority != PriorityHigh {
+		return ErrInvalidEvent{Field: "priority", Reason: `must be "normal" or "high"`, Code: ErrCodeInvalidValue}
+	}
+	if len(e.ClientReference) > MaxClientReferenceLen {
+		return ErrInvalidEvent{Field: "client_reference", Reason: fmt.Sprintf("must be at most %d bytes", MaxClientReferenceLen), Code: ErrCodeInvalidValue}
+	}
+	if err := e.validateGroup(); err != nil {
+		return err
+	}
+	if len(e.SchemaVersion) > MaxSchemaVersionLen {
+		return ErrInvalidEvent{Field: "schema_version", Reason: fmt.Sprintf("must be at most %d bytes", MaxSchemaVersionLen), Code: ErrCodeInvalidValue}
 	}
-	// Check for future timestamp (basic sanity check, allow 5 min drift)
-	if e.Timestamp.After(time.Now().Add(5 * time.Minute)) {
-		return ErrInvalidEvent{Field: "timestamp", Reason: "cannot be in the future", Code: ErrCodeInvalidValue}
+	if len(e.EventType) > MaxEventTypeLen {
+		return ErrInvalidEvent{Field: "event_type", Reason: fmt.Sprintf("must be at most %d bytes", MaxEventTypeLen), Code: ErrCodeInvalidValue}
 	}
+	if e.EventType != "" && !eventTypePattern.MatchString(e.EventType) {
+		return ErrInvalidEvent{Field: "event_type", Reason: "must match " + eventTypePattern.String(), Code: ErrCodeInvalidValue}
+	}
+	return nil
+}
 
-	// Metadata size check (simulated constraint)
-	if len(e.Metadata) > 10 {
-		return ErrInvalidEvent{Field: "metadata", Reason: "too many keys (max 10)", Code: ErrCodeInvalidValue}
+// validateGroup checks GroupID and GroupSize come together and within bounds.
+func (e *Event) validateGroup() error {
+	switch {
+	case e.GroupID == "" && e.GroupSize != 0:
+		return ErrInvalidEvent{Field: "group_id", Reason: "is required with group_size", Code: ErrCodeMissingField}
+	case e.GroupID == "":
+		return nil
+	case len(e.GroupID) > MaxGroupIDLen:
+		return ErrInvalidEvent{Field: "group_id", Reason: fmt.Sprintf("must be at most %d bytes", MaxGroupIDLen), Code: ErrCodeInvalidValue}
+	case e.GroupSize < 1 || e.GroupSize > MaxGroupSize:
+		return ErrInvalidEvent{Field: "group_size", Reason: fmt.Sprintf("must be between 1 and %d with group_id", MaxGroupSize), Code: ErrCodeInvalidValue}
 	}
+	return nil
+}
 
+// CheckAge rejects an event whose timestamp is more than maxAge before now
+// (ErrCodeEventTooOld), so stale replays never reach the aggregates. maxAge <= 0
+// disables the check. ValidateWith applies it with ValidationConfig.MaxAge.
+func (e *Event) CheckAge(now time.Time, maxAge time.Duration) error {
+	if age := now.Sub(e.Timestamp); maxAge > 0 && age > maxAge {
+		return ErrInvalidEvent{
+			Field:  "timestamp",
+			Reason: fmt.Sprintf("too old: %s behind server time, max age %s", age.Round(time.Second), maxAge),
+			Code:   ErrCodeEventTooOld,
+		}
+	}
 	return nil
 }
 
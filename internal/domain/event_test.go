@@ -0,0 +1,256 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNormalize(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	e := &Event{
+		EventID:   "  e1 ",
+		UserID:    " u1\t",
+		Amount:    10,
+		Currency:  " usd ",
+		Merchant:  "  Amazon  ",
+		Timestamp: time.Date(2026, 1, 1, 14, 0, 0, 0, loc),
+	}
+	e.Normalize()
+
+	if e.EventID != "e1" || e.UserID != "u1" || e.Merchant != "Amazon" {
+		t.Errorf("strings not trimmed: %+v", e)
+	}
+	if e.Currency != "USD" {
+		t.Errorf("Currency = %q, want USD", e.Currency)
+	}
+	if e.Timestamp.Location() != time.UTC || e.Timestamp.Hour() != 12 {
+		t.Errorf("Timestamp = %v, want 12:00 UTC", e.Timestamp)
+	}
+	if e.Metadata == nil {
+		t.Error("Metadata should default to an empty map")
+	}
+}
+
+func TestNormalize_Idempotent(t *testing.T) {
+	e := NewEvent("e1", "u1", 10, "eur", "m1", time.Now(), map[string]interface{}{"k": "v"})
+	before := *e
+	e.Normalize()
+	if e.Currency != before.Currency || !e.Timestamp.Equal(before.Timestamp) || len(e.Metadata) != 1 {
+		t.Errorf("second Normalize changed the event: before %+v, after %+v", before, *e)
+	}
+}
+
+func TestNewEvent_Validates(t *testing.T) {
+	e := NewEvent("", " u1 ", 25, "usd", " m1 ", time.Now().Add(-time.Minute), nil)
+	if err := e.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	if e.UserID != "u1" || e.Currency != "USD" || e.Merchant != "m1" {
+		t.Errorf("NewEvent did not normalize: %+v", e)
+	}
+}
+
+func TestEvent_CanaryJSON(t *testing.T) {
+	e := NewEvent("e1", "u1", 10, "USD", "m1", time.Unix(0, 0), nil)
+	b, err := e.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Omitted when false so payload hashes of ordinary events are unchanged.
+	if strings.Contains(string(b), "canary") {
+		t.Errorf("non-canary payload contains canary field: %s", b)
+	}
+
+	var got Event
+	if err := json.Unmarshal([]byte(`{"user_id":"u1","canary":true}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Canary {
+		t.Error("canary:true was not decoded")
+	}
+}
+
+func TestEvent_Priority(t *testing.T) {
+	cases := []struct {
+		in, want string
+		valid    bool
+		key      string
+	}{
+		{"", "", true, EventsRoutingKey},
+		{" Normal ", "", true, EventsRoutingKey},
+		{"HIGH", PriorityHigh, true, PriorityEventsRoutingKey},
+		{"urgent", "urgent", false, ""},
+	}
+	for _, c := range cases {
+		e := NewEvent("e1", "u1", 10, "USD", "m1", time.Now(), nil)
+		e.Priority = c.in
+		e.Normalize()
+		if e.Priority != c.want {
+			t.Errorf("Normalize(%q) priority = %q, want %q", c.in, e.Priority, c.want)
+		}
+		err := e.Validate()
+		if (err == nil) != c.valid {
+			t.Errorf("priority %q: Validate() = %v, want valid=%v", c.in, err, c.valid)
+			continue
+		}
+		if err == nil {
+			if got := (&QueueMessage{Priority: e.Priority}).RoutingKey(); got != c.key {
+				t.Errorf("priority %q: RoutingKey() = %q, want %q", c.in, got, c.key)
+			}
+		}
+	}
+}
+
+func TestEvent_ClientReference(t *testing.T) {
+	e := NewEvent("e1", "u1", 10, "USD", "m1", time.Now(), nil)
+	e.ClientReference = "  order-7 "
+	e.Normalize()
+	if e.ClientReference != "order-7" {
+		t.Errorf("Normalize client_reference = %q, want trimmed", e.ClientReference)
+	}
+	e.ProducerKey = "secret-hash"
+	b, _ := e.ToJSON()
+	if !strings.Contains(string(b), `"client_reference":"order-7"`) || strings.Contains(string(b), "secret-hash") {
+		t.Errorf("payload = %s, want client_reference and no producer key", b)
+	}
+	if err := e.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	e.ClientReference = strings.Repeat("x", MaxClientReferenceLen+1)
+	if err := e.Validate(); err == nil {
+		t.Error("Validate() accepted an over-long client_reference")
+	}
+}
+
+func TestEvent_Group(t *testing.T) {
+	tests := []struct {
+		name    string
+		groupID string
+		size    int
+		wantErr bool
+	}{
+		{"none", "", 0, false},
+		{"group", " transfer-9 ", 2, false},
+		{"size without group", "", 2, true},
+		{"group without size", "transfer-9", 0, true},
+		{"size over the limit", "transfer-9", MaxGroupSize + 1, true},
+		{"id too long", strings.Repeat("x", MaxGroupIDLen+1), 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEvent("e1", "u1", 10, "USD", "m1", time.Now(), nil)
+			e.GroupID, e.GroupSize = tt.groupID, tt.size
+			e.Normalize()
+			if err := e.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if e.GroupID != strings.TrimSpace(tt.groupID) {
+				t.Errorf("Normalize group_id = %q, want trimmed", e.GroupID)
+			}
+		})
+	}
+}
+
+func TestEvent_DerivedID(t *testing.T) {
+	ts := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	base := func() *Event {
+		e := NewEvent("", "u1", 12.5, "usd", " m1 ", ts.In(time.FixedZone("x", 3600)), nil)
+		e.ClientReference = "order-7"
+		return e
+	}
+	id := base().DerivedID()
+	if id != base().DerivedID() {
+		t.Fatal("DerivedID is not deterministic")
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		t.Errorf("DerivedID = %q, not a UUID: %v", id, err)
+	}
+	changes := map[string]func(e *Event){
+		"user":      func(e *Event) { e.UserID = "u2" },
+		"timestamp": func(e *Event) { e.Timestamp = e.Timestamp.Add(time.Millisecond) },
+		"amount":    func(e *Event) { e.Amount = 12.51 },
+		"currency":  func(e *Event) { e.Currency = "EUR" },
+		"merchant":  func(e *Event) { e.Merchant = "m2" },
+		"reference": func(e *Event) { e.ClientReference = "order-8" },
+		"no ref":    func(e *Event) { e.ClientReference = "" },
+	}
+	for name, change := range changes {
+		e := base()
+		change(e)
+		if e.DerivedID() == id {
+			t.Errorf("%s change kept the derived ID", name)
+		}
+	}
+	same := base()
+	same.Metadata["note"] = "other metadata"
+	same.Priority = PriorityHigh
+	if same.DerivedID() != id {
+		t.Error("fields outside the identity changed the derived ID")
+	}
+}
+
+func TestIdempotencyKeyEventID(t *testing.T) {
+	id := IdempotencyKeyEventID("producer-a", "order-7-attempt")
+	if id != IdempotencyKeyEventID("producer-a", "order-7-attempt") {
+		t.Fatal("IdempotencyKeyEventID is not deterministic")
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		t.Errorf("IdempotencyKeyEventID = %q, not a UUID: %v", id, err)
+	}
+	if id == IdempotencyKeyEventID("producer-b", "order-7-attempt") {
+		t.Error("two producers' keys mapped to one event ID")
+	}
+	if id == IdempotencyKeyEventID("producer-a", "order-8-attempt") {
+		t.Error("two keys mapped to one event ID")
+	}
+}
+
+func TestEvent_CheckAge(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		age     time.Duration
+		maxAge  time.Duration
+		wantErr bool
+	}{
+		{"within window", 29 * 24 * time.Hour, 30 * 24 * time.Hour, false},
+		{"too old", 31 * 24 * time.Hour, 30 * 24 * time.Hour, true},
+		{"disabled", 365 * 24 * time.Hour, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEvent("e1", "u1", 10, "USD", "m1", now.Add(-tt.age), nil)
+			err := e.CheckAge(now, tt.maxAge)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckAge() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ie, ok := err.(ErrInvalidEvent); tt.wantErr && (!ok || ie.Code != ErrCodeEventTooOld) {
+				t.Errorf("CheckAge() error = %#v, want code %s", err, ErrCodeEventTooOld)
+			}
+		})
+	}
+}
+
+func TestEvent_ValidateWithFutureDrift(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	e := NewEvent("e1", "u1", 10, "USD", "m1", now.Add(8*time.Minute), nil)
+
+	err := e.ValidateWith(ValidationConfig{}, now)
+	if err == nil {
+		t.Fatal("ValidateWith(default) accepted an event 8m ahead")
+	}
+	// Producers with drifting clocks need the observed and allowed skew.
+	if !strings.Contains(err.Error(), "8m0s ahead") || !strings.Contains(err.Error(), "max drift 5m0s") {
+		t.Errorf("error = %q, want observed and allowed skew", err)
+	}
+	if err := e.ValidateWith(ValidationConfig{MaxFutureDrift: 10 * time.Minute}, now); err != nil {
+		t.Errorf("ValidateWith(10m drift) = %v, want nil", err)
+	}
+	if err := e.ValidateWith(ValidationConfig{MaxFutureDrift: 10 * time.Minute, MaxAge: time.Hour}, now.Add(2*time.Hour)); err == nil {
+		t.Error("ValidateWith(MaxAge 1h) accepted an event 2h old")
+	}
+}
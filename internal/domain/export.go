@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"time"
+)
+
+// ExportFormat is the file format of an export.
+type ExportFormat string
+
+const (
+	ExportFormatCSV    ExportFormat = "csv"
+	ExportFormatNDJSON ExportFormat = "ndjson"
+)
+
+// Valid reports whether f is a format exports can be written in.
+func (f ExportFormat) Valid() bool {
+	return f == ExportFormatCSV || f == ExportFormatNDJSON
+}
+
+// ExportStatus is the lifecycle of an export job: pending until a worker picks it
+// up, running while rows are written, then complete or failed.
+type ExportStatus string
+
+const (
+	ExportStatusPending  ExportStatus = "pending"
+	ExportStatusRunning  ExportStatus = "running"
+	ExportStatusComplete ExportStatus = "complete"
+	ExportStatusFailed   ExportStatus = "failed"
+)
+
+// ExportFilter selects the events of an export. Empty fields don't filter. From is
+// inclusive and To exclusive, both on the event timestamp; Merchant matches the
+// canonical merchant. Canary and soft-deleted events are never exported.
+type ExportFilter struct {
+	From        *time.Time `json:"from,omitempty"`
+	To          *time.Time `json:"to,omitempty"`
+	UserID      string     `json:"user_id,omitempty"`
+	Merchant    string     `json:"merchant,omitempty"`
+	Currency    string     `json:"currency,omitempty"`
+	FlaggedOnly bool       `json:"flagged_only,omitempty"`
+}
+
+// Export is an export job (GET /exports/{id}). TotalRows is the number of matching
+// events when the job started and RowsExported how many have been written so far;
+// ObjectKey is set once the file is stored.
+type Export struct {
+	ExportID     string       `json:"export_id"`
+	Format       ExportFormat `json:"format"`
+	Filter       ExportFilter `json:"filters"`
+	Status       ExportStatus `json:"status"`
+	TotalRows    int          `json:"total_rows"`
+	RowsExported int          `json:"rows_exported"`
+	ObjectKey    string       `json:"-"`
+	ErrorReason  string       `json:"error_reason,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	CompletedAt  *time.Time   `json:"completed_at,omitempty"`
+}
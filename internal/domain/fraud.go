@@ -11,6 +11,7 @@ type FraudFlag struct {
 	RuleValue string  // human-readable: e.g. "amount=15000.00 > threshold=10000.00"
 	MlScore   float64 // blended ML fraud probability for the event (0 when scorer unavailable)
 	FlaggedAt time.Time
+	Canary    bool // copied from the event; not persisted (join events.is_canary instead)
 }
 
 // AlertMessage is published to the RabbitMQ alerts exchange when a fraud flag is created.
@@ -22,6 +23,25 @@ type AlertMessage struct {
 	RuleValue string    `json:"rule_value"`
 	MlScore   float64   `json:"ml_score"`
 	FlaggedAt time.Time `json:"flagged_at"`
+	Canary    bool      `json:"canary,omitempty"`
+	// DedupToken is identical for every publish of the same notification (processor
+	// redelivery, admin re-send), unlike FlagID; consumers dedupe on it.
+	DedupToken string `json:"dedup_token,omitempty"`
+}
+
+// NewAlertMessage builds the alerts-exchange message for flag, with its dedup token.
+func NewAlertMessage(flag FraudFlag) AlertMessage {
+	return AlertMessage{
+		FlagID:     flag.FlagID,
+		EventID:    flag.EventID,
+		UserID:     flag.UserID,
+		RuleName:   flag.RuleName,
+		RuleValue:  flag.RuleValue,
+		MlScore:    flag.MlScore,
+		FlaggedAt:  flag.FlaggedAt,
+		Canary:     flag.Canary,
+		DedupToken: DedupToken(flag.EventID, FraudAlertType(flag.RuleName)),
+	}
 }
 
 // FraudEvent is a joined view of fraud_flags + events, used by the SSE stream.
@@ -32,6 +52,7 @@ type FraudEvent struct {
 	UserID        string    `json:"user_id"`
 	Amount        float64   `json:"amount"`
 	Currency      string    `json:"currency"`
+	AmountDisplay string    `json:"amount_display,omitempty"` // FormatAmount; set by the query service
 	Merchant      string    `json:"merchant"`
 	RuleName      string    `json:"rule_name"`
 	RuleValue     string    `json:"rule_value"`
@@ -0,0 +1,52 @@
+package domain
+
+import "time"
+
+// Correlation group limits: GroupID fits its events column, and GroupSize is
+// bounded like a batch.
+const (
+	MaxGroupIDLen = 255
+	MaxGroupSize  = MaxBatchEvents
+)
+
+// NotificationTypeGroupComplete is the notification type of a group completion.
+const NotificationTypeGroupComplete = "group_complete"
+
+// EventGroup is the progress of a correlation group (GET /groups/{id}): events
+// sharing a group_id, such as the legs of a transfer. Expected is the group_size
+// of the first member persisted; Persisted counts the members stored so far. A
+// group is complete, once, when Persisted reaches Expected.
+type EventGroup struct {
+	GroupID     string     `json:"group_id"`
+	Expected    int        `json:"expected"`
+	Persisted   int        `json:"persisted"`
+	Complete    bool       `json:"complete"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// GroupCompleteMessage is published on the groups exchange when a group completes.
+// Like alerts, delivery is at-least-once; consumers dedupe on DedupToken.
+type GroupCompleteMessage struct {
+	Type        string    `json:"type"`
+	GroupID     string    `json:"group_id"`
+	Members     int       `json:"members"`
+	CompletedAt time.Time `json:"completed_at"`
+	DedupToken  string    `json:"dedup_token"`
+}
+
+// NewGroupCompleteMessage builds the completion message of g, which must be
+// complete.
+func NewGroupCompleteMessage(g EventGroup) GroupCompleteMessage {
+	msg := GroupCompleteMessage{
+		Type:       NotificationTypeGroupComplete,
+		GroupID:    g.GroupID,
+		Members:    g.Persisted,
+		DedupToken: DedupToken(g.GroupID, NotificationTypeGroupComplete),
+	}
+	if g.CompletedAt != nil {
+		msg.CompletedAt = *g.CompletedAt
+	}
+	return msg
+}
@@ -0,0 +1,10 @@
+package domain
+
+import "time"
+
+// MerchantAlias maps a normalized merchant descriptor to its canonical merchant name.
+type MerchantAlias struct {
+	AliasKey  string    `json:"alias_key"`
+	Canonical string    `json:"canonical"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// MerchantStats aggregates merchant_stats_hourly rows over a window.
+type MerchantStats struct {
+	Merchant    string  `json:"merchant"`
+	EventCount  int64   `json:"event_count"`
+	TotalAmount float64 `json:"total_amount"`
+	AvgAmount   float64 `json:"avg_amount"`
+	MaxAmount   float64 `json:"max_amount"`
+}
+
+// MerchantStatsBucket is one hourly merchant_stats_hourly row.
+type MerchantStatsBucket struct {
+	Bucket      time.Time `json:"bucket"`
+	EventCount  int64     `json:"event_count"`
+	TotalAmount float64   `json:"total_amount"`
+	MaxAmount   float64   `json:"max_amount"`
+}
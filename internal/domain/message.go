@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"encoding/base64"
+	"strconv"
 	"time"
 )
 
@@ -12,6 +14,73 @@ const (
 	PayloadModeS3     PayloadMode = "S3"
 )
 
+// Payload content types (QueueMessage.ContentType). The payload of an atomic
+// batch is always ContentTypeJSON.
+const (
+	// ContentTypeJSON is an Event as JSON; also what an envelope without a
+	// content_type carries.
+	ContentTypeJSON = "application/json"
+	// ContentTypeProtobuf is a fluxa.fraud.v1.EvaluateRequest in protobuf binary.
+	// Inline, it is carried base64-encoded (see QueueMessage.SetInline).
+	ContentTypeProtobuf = "application/x-protobuf"
+	// ContentTypeCloudEvents is a CloudEvents 1.0 structured-mode JSON event whose
+	// data is an Event.
+	ContentTypeCloudEvents = "application/cloudevents+json"
+)
+
+// MaxInlinePayloadBytes is the largest payload carried inline in a QueueMessage;
+// anything bigger is offloaded to object storage (PayloadModeS3).
+const MaxInlinePayloadBytes = 256 * 1024
+
+// MaxQueueMessageBytes bounds a marshaled QueueMessage. An inline payload near
+// MaxInlinePayloadBytes can cross it once JSON escaping and the envelope fields
+// are added; ingest offloads such payloads instead.
+const MaxQueueMessageBytes = 256 * 1024
+
+// PayloadKey is the content-addressed object key for an offloaded payload with the
+// given hex SHA256, so identical payloads share one stored object.
+func PayloadKey(sha256Hex string) string {
+	return "raw/sha256/" + sha256Hex + ".json"
+}
+
+// PayloadPlacement is where one producer's offloaded payloads are stored instead
+// of the default bucket, e.g. for data residency: Bucket, with keys under Prefix.
+// The storage adapter picks the bucket back from the prefix when reading.
+type PayloadPlacement struct {
+	Bucket string
+	Prefix string
+}
+
+// Key is PayloadKey under p's prefix; the zero placement leaves it unprefixed.
+func (p PayloadPlacement) Key(sha256Hex string) string {
+	if p.Prefix == "" {
+		return PayloadKey(sha256Hex)
+	}
+	return p.Prefix + "/" + PayloadKey(sha256Hex)
+}
+
+// SampledForArchive reports whether an inline payload with the given hex SHA256
+// falls in an archival sample of percent (0-100). The choice is a function of the
+// hash, so a retried submission of the same payload is sampled the same way.
+func SampledForArchive(sha256Hex string, percent float64) bool {
+	if percent <= 0 || len(sha256Hex) < 8 {
+		return false
+	}
+	bucket, err := strconv.ParseUint(sha256Hex[:8], 16, 32)
+	if err != nil {
+		return false
+	}
+	return float64(bucket) < percent/100*(1<<32)
+}
+
+// Routing keys on the events exchange, each bound to the queue of the same name.
+// The processor consumes the priority queue with workers of its own, so
+// high-priority events never wait behind a backlog of normal ones.
+const (
+	EventsRoutingKey         = "events"
+	PriorityEventsRoutingKey = "events.priority"
+)
+
 // QueueMessage represents the message envelope published to and consumed from the queue.
 // S3Bucket is not included — the bucket is a service configuration detail, not message data.
 type QueueMessage struct {
@@ -19,30 +88,122 @@ type QueueMessage struct {
 	CorrelationID string      `json:"correlation_id"`
 	PayloadMode   PayloadMode `json:"payload_mode"`
 
+	// ContentType is the payload's format, one of the ContentType* constants; the
+	// processor decodes the payload by it. Empty (envelopes before v2) is JSON.
+	ContentType string `json:"content_type,omitempty"`
+
 	// For INLINE mode
 	PayloadInline *string `json:"payload_inline,omitempty"`
 	PayloadSHA256 string  `json:"payload_sha256"`
+	// Encryption is set when PayloadInline is sealed (a sensitive event, see
+	// internal/payloadcrypt); PayloadSHA256 is still the plaintext's.
+	Encryption *PayloadEncryption `json:"encryption,omitempty"`
 
-	// For S3 mode — only the key is needed; bucket comes from service config
+	// For S3 mode — only the key is needed; bucket comes from service config. An
+	// INLINE message carries one too when its payload was also archived
+	// (SampledForArchive); the processor reads the inline copy either way.
 	S3Key *string `json:"s3_key,omitempty"`
+	// ArchivePending marks an INLINE message whose payload was sampled for
+	// archival but failed to upload at ingest. The processor queues the payload in
+	// archive_outbox with the event row, to be stored later (internal/archiveretry).
+	ArchivePending bool `json:"archive_pending,omitempty"`
 
 	ReceivedAt time.Time `json:"received_at"`
+
+	// ProducerKey is HashAPIKey of the submitting producer's X-API-Key, empty when
+	// none was sent. The processor uses it to find the producer's ack webhook.
+	ProducerKey string `json:"producer_key,omitempty"`
+
+	// Tenant is the producer's tenant, from the api_keys of the secret bundle
+	// (internal/config/secrets), empty when it has none. Ingest resolves it, as
+	// the only service that sees the raw API key.
+	Tenant string `json:"tenant,omitempty"`
+
+	// BatchID names the POST /events/batch submission the message belongs to. With
+	// Atomic set the payload is a BatchPayload holding every member and EventID is
+	// BatchIdempotencyKey(BatchID). Otherwise the message is one member and
+	// BatchSize is the number of members enqueued for the batch. Partial (with
+	// Atomic) lets single members fail without failing the batch.
+	BatchID   string `json:"batch_id,omitempty"`
+	Atomic    bool   `json:"atomic,omitempty"`
+	Partial   bool   `json:"partial,omitempty"`
+	BatchSize int    `json:"batch_size,omitempty"`
+
+	// Priority is the event's (Event.Priority); an atomic batch is high only when
+	// every member is. It picks the routing key (RoutingKey).
+	Priority string `json:"priority,omitempty"`
+}
+
+// SetInline carries payload inline in m. A binary (protobuf) payload is
+// base64-encoded, since payload_inline is a JSON string; m.ContentType must be set.
+func (m *QueueMessage) SetInline(payload []byte) {
+	s := string(payload)
+	if m.ContentType == ContentTypeProtobuf {
+		s = base64.StdEncoding.EncodeToString(payload)
+	}
+	m.PayloadMode = PayloadModeInline
+	m.PayloadInline = &s
+}
+
+// SetSealed carries a sealed payload inline in m, base64-encoded, with the
+// encryption that opens it.
+func (m *QueueMessage) SetSealed(enc *PayloadEncryption, sealed []byte) {
+	s := base64.StdEncoding.EncodeToString(sealed)
+	m.PayloadMode = PayloadModeInline
+	m.PayloadInline = &s
+	m.Encryption = enc
+}
+
+// PayloadEncryption is how an inline payload was sealed: KeyID names the key that
+// wraps DataKey, the base64 per-payload key the payload is encrypted with.
+type PayloadEncryption struct {
+	KeyID   string `json:"key_id"`
+	DataKey string `json:"data_key"`
+}
+
+// InlinePayload returns the payload SetInline carried in m, or nil without one.
+// A sealed payload (SetSealed) is returned still sealed.
+func (m *QueueMessage) InlinePayload() ([]byte, error) {
+	if m.PayloadInline == nil {
+		return nil, nil
+	}
+	if m.ContentType == ContentTypeProtobuf || m.Encryption != nil {
+		return base64.StdEncoding.DecodeString(*m.PayloadInline)
+	}
+	return []byte(*m.PayloadInline), nil
+}
+
+// RoutingKey is the events-exchange routing key m is published with.
+func (m *QueueMessage) RoutingKey() string {
+	if m.Priority == PriorityHigh {
+		return PriorityEventsRoutingKey
+	}
+	return EventsRoutingKey
 }
 
 // EventRecord represents a persisted event in the database.
+// CanonicalMerchant and Enrichment are empty for events persisted before those features existed.
+// Canary is true for synthetic end-to-end check events (see Event.Canary).
 type EventRecord struct {
-	EventID       string                 `json:"event_id" db:"event_id"`
-	CorrelationID string                 `json:"correlation_id" db:"correlation_id"`
-	UserID        string                 `json:"user_id" db:"user_id"`
-	Amount        float64                `json:"amount" db:"amount"`
-	Currency      string                 `json:"currency" db:"currency"`
-	Merchant      string                 `json:"merchant" db:"merchant"`
-	Timestamp     time.Time              `json:"timestamp" db:"ts"`
-	MetadataJSON  string                 `json:"-" db:"metadata_json"`
-	Metadata      map[string]interface{} `json:"metadata,omitempty"`
-	PayloadMode   PayloadMode            `json:"payload_mode" db:"payload_mode"`
-	S3Key         *string                `json:"s3_key,omitempty" db:"s3_key"`
-	CreatedAt     time.Time              `json:"created_at" db:"created_at"`
+	EventID           string                 `json:"event_id" db:"event_id"`
+	CorrelationID     string                 `json:"correlation_id" db:"correlation_id"`
+	UserID            string                 `json:"user_id" db:"user_id"`
+	Amount            float64                `json:"amount" db:"amount"`
+	Currency          string                 `json:"currency" db:"currency"`
+	Merchant          string                 `json:"merchant" db:"merchant"`
+	CanonicalMerchant string                 `json:"canonical_merchant,omitempty" db:"canonical_merchant"`
+	Timestamp         time.Time              `json:"timestamp" db:"ts"`
+	MetadataJSON      string                 `json:"-" db:"metadata_json"`
+	Metadata          map[string]interface{} `json:"metadata,omitempty"`
+	Enrichment        map[string]string      `json:"enrichment,omitempty" db:"enrichment_json"`
+	Canary            bool                   `json:"canary,omitempty" db:"is_canary"`
+	PayloadMode       PayloadMode            `json:"payload_mode" db:"payload_mode"`
+	S3Key             *string                `json:"s3_key,omitempty" db:"s3_key"`
+	CreatedAt         time.Time              `json:"created_at" db:"created_at"`
+	Version           int                    `json:"version" db:"version"` // bumped on every amendment
+	ClientReference   string                 `json:"client_reference,omitempty" db:"client_reference"`
+	GroupID           string                 `json:"group_id,omitempty" db:"group_id"`
+	SchemaVersion     string                 `json:"schema_version,omitempty" db:"schema_version"`
 }
 
 // IdempotencyKeyRecord represents an idempotency key in the database.
@@ -0,0 +1,215 @@
+package domain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	fraudv1 "github.com/fluxa/fluxa/internal/grpc/fraud/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Contract tests for the QueueMessage envelope exchanged by ingest (producer) and
+// processor (consumer). Golden fixtures live in testdata/envelopes as
+// v<N>_<case>.json; S3-mode cases carry the offloaded object in
+// v<N>_<case>.object.json.
+//
+// When the envelope changes, add v<N+1>_* fixtures with -update and keep the old
+// ones: messages already queued under an older version must still decode, and the
+// processor runs its decoder over every fixture (internal/processor/contract_test.go).
+//
+//	go test ./internal/domain -run TestQueueMessageContract -update
+
+var update = flag.Bool("update", false, "rewrite golden envelope fixtures for the current version")
+
+const (
+	envelopeDir     = "testdata/envelopes"
+	envelopeVersion = "v2"
+)
+
+// contractEnvelopes builds the messages the current producer emits, keyed by case name.
+// The second value is the offloaded object body for S3 mode, nil for inline.
+func contractEnvelopes(t *testing.T) map[string]struct {
+	msg    *QueueMessage
+	object []byte
+} {
+	t.Helper()
+	ev := NewEvent("evt-contract-1", "u-contract", 42.5, "usd", "ACME Corp", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), nil)
+	payload, err := ev.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(payload)
+	hash := hex.EncodeToString(sum[:])
+	inline := string(payload)
+	key := PayloadKey(hash)
+	receivedAt := time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC)
+
+	second := NewEvent("evt-contract-2", "u-contract", 7, "eur", "ACME Corp", ev.Timestamp, nil)
+	batchPayload, err := json.Marshal(BatchPayload{Events: []Event{*ev, *second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batchSum := sha256.Sum256(batchPayload)
+	batchInline := string(batchPayload)
+
+	cloudEvent, err := json.Marshal(map[string]interface{}{
+		"specversion": "1.0", "id": ev.EventID, "source": "urn:contract", "type": "com.fluxa.transaction",
+		"datacontenttype": ContentTypeJSON, "data": ev,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ceSum := sha256.Sum256(cloudEvent)
+	ceMsg := &QueueMessage{
+		EventID: ev.EventID, CorrelationID: "corr-contract-1", ContentType: ContentTypeCloudEvents,
+		PayloadSHA256: hex.EncodeToString(ceSum[:]), ReceivedAt: receivedAt,
+	}
+	ceMsg.SetInline(cloudEvent)
+
+	protoPayload, err := proto.Marshal(&fraudv1.EvaluateRequest{
+		EventId: ev.EventID, UserId: ev.UserID, Amount: ev.Amount, Currency: ev.Currency,
+		Merchant: ev.Merchant, TransactionTime: timestamppb.New(ev.Timestamp),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	protoSum := sha256.Sum256(protoPayload)
+	protoMsg := &QueueMessage{
+		EventID: ev.EventID, CorrelationID: "corr-contract-1", ContentType: ContentTypeProtobuf,
+		PayloadSHA256: hex.EncodeToString(protoSum[:]), ReceivedAt: receivedAt,
+	}
+	protoMsg.SetInline(protoPayload)
+
+	return map[string]struct {
+		msg    *QueueMessage
+		object []byte
+	}{
+		"inline": {msg: &QueueMessage{
+			EventID: ev.EventID, CorrelationID: "corr-contract-1", ContentType: ContentTypeJSON, PayloadMode: PayloadModeInline,
+			PayloadInline: &inline, PayloadSHA256: hash, ReceivedAt: receivedAt,
+		}},
+		"inline_producer": {msg: &QueueMessage{
+			EventID: ev.EventID, CorrelationID: "corr-contract-1", ContentType: ContentTypeJSON, PayloadMode: PayloadModeInline,
+			PayloadInline: &inline, PayloadSHA256: hash, ReceivedAt: receivedAt,
+			ProducerKey: HashAPIKey("contract-api-key"),
+		}},
+		"atomic_batch": {msg: &QueueMessage{
+			EventID: BatchIdempotencyKey("batch-contract-1"), CorrelationID: "corr-contract-1", ContentType: ContentTypeJSON, PayloadMode: PayloadModeInline,
+			PayloadInline: &batchInline, PayloadSHA256: hex.EncodeToString(batchSum[:]), ReceivedAt: receivedAt,
+			BatchID: "batch-contract-1", Atomic: true,
+		}},
+		"partial_batch": {msg: &QueueMessage{
+			EventID: BatchIdempotencyKey("batch-contract-3"), CorrelationID: "corr-contract-1", ContentType: ContentTypeJSON, PayloadMode: PayloadModeInline,
+			PayloadInline: &batchInline, PayloadSHA256: hex.EncodeToString(batchSum[:]), ReceivedAt: receivedAt,
+			BatchID: "batch-contract-3", Atomic: true, Partial: true,
+		}},
+		"batch_member": {msg: &QueueMessage{
+			EventID: ev.EventID, CorrelationID: "corr-contract-1", ContentType: ContentTypeJSON, PayloadMode: PayloadModeInline,
+			PayloadInline: &inline, PayloadSHA256: hash, ReceivedAt: receivedAt,
+			BatchID: "batch-contract-2", BatchSize: 2,
+		}},
+		"s3": {msg: &QueueMessage{
+			EventID: ev.EventID, CorrelationID: "corr-contract-1", ContentType: ContentTypeJSON, PayloadMode: PayloadModeS3,
+			S3Key: &key, PayloadSHA256: hash, ReceivedAt: receivedAt,
+		}, object: payload},
+		"cloudevents": {msg: ceMsg},
+		"protobuf":    {msg: protoMsg},
+	}
+}
+
+// TestQueueMessageContract snapshots the current producer's wire format.
+func TestQueueMessageContract(t *testing.T) {
+	for name, c := range contractEnvelopes(t) {
+		t.Run(name, func(t *testing.T) {
+			got, err := json.MarshalIndent(c.msg, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			path := filepath.Join(envelopeDir, envelopeVersion+"_"+name+".json")
+
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if c.object != nil {
+					if err := os.WriteFile(strings.TrimSuffix(path, ".json")+".object.json", c.object, 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("missing golden fixture (run with -update): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("QueueMessage wire format changed for %s — in-flight messages may break.\ngot:\n%s\nwant:\n%s", path, got, want)
+			}
+		})
+	}
+}
+
+// TestQueueMessageContract_AllVersionsDecode strictly decodes every fixture, old
+// versions included, so removing or renaming a field still in flight fails here.
+func TestQueueMessageContract_AllVersionsDecode(t *testing.T) {
+	paths := envelopeFixtures(t, envelopeDir)
+	if len(paths) == 0 {
+		t.Fatal("no envelope fixtures found")
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.DisallowUnknownFields()
+			var msg QueueMessage
+			if err := dec.Decode(&msg); err != nil {
+				t.Fatalf("fixture no longer decodes: %v", err)
+			}
+			if msg.EventID == "" || msg.PayloadSHA256 == "" || msg.ReceivedAt.IsZero() {
+				t.Errorf("required envelope fields missing after decode: %+v", msg)
+			}
+			switch msg.PayloadMode {
+			case PayloadModeInline:
+				if msg.PayloadInline == nil {
+					t.Error("INLINE fixture lost payload_inline")
+				}
+			case PayloadModeS3:
+				if msg.S3Key == nil {
+					t.Error("S3 fixture lost s3_key")
+				}
+			default:
+				t.Errorf("unknown payload_mode %q", msg.PayloadMode)
+			}
+		})
+	}
+}
+
+// envelopeFixtures lists envelope fixtures in dir, skipping S3 object bodies.
+func envelopeFixtures(t *testing.T, dir string) []string {
+	t.Helper()
+	all, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, p := range all {
+		if !strings.HasSuffix(p, ".object.json") {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPayloadKey(t *testing.T) {
+	if got := PayloadKey("abc123"); got != "raw/sha256/abc123.json" {
+		t.Errorf("PayloadKey = %q", got)
+	}
+}
+
+func TestSampledForArchive(t *testing.T) {
+	low, high := "00000000"+"ab", "ffffffff"+"ab"
+	if SampledForArchive(low, 0) || SampledForArchive("", 100) || SampledForArchive("zzzzzzzz", 100) {
+		t.Error("sampled with 0%, an empty hash, or a non-hex hash")
+	}
+	if !SampledForArchive(low, 0.001) || SampledForArchive(high, 99.9) || !SampledForArchive(high, 100) {
+		t.Error("sample boundaries wrong")
+	}
+	n := 0
+	for i := 0; i < 1000; i++ {
+		if SampledForArchive(fmt.Sprintf("%08x", uint32(i)*4294967), 10) {
+			n++
+		}
+	}
+	if n < 90 || n > 110 {
+		t.Errorf("sampled %d of 1000 evenly spread hashes at 10%%", n)
+	}
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Default metadata limits, used when ValidationConfig leaves them zero. The size
+// limit leaves room for the large payloads that are offloaded to object storage
+// (see MaxInlinePayloadBytes); it stops runaway documents, not big ones.
+const (
+	DefaultMetadataMaxKeys  = 10
+	DefaultMetadataMaxDepth = 4
+	DefaultMetadataMaxBytes = 1 << 20
+)
+
+// validateMetadata enforces vc's metadata limits: the number of top-level keys,
+// how deeply objects and arrays nest, the JSON value types, and the serialized
+// size. Errors name the offending value's path, e.g. metadata.items[3].price.
+// vc.MetadataKeysOnly stops after the key count.
+func validateMetadata(md map[string]interface{}, vc ValidationConfig) error {
+	maxKeys := orDefault(vc.MetadataMaxKeys, DefaultMetadataMaxKeys)
+	if len(md) > maxKeys {
+		return ErrInvalidEvent{Field: "metadata", Reason: fmt.Sprintf("too many keys (max %d)", maxKeys), Code: ErrCodeInvalidValue}
+	}
+	if vc.MetadataKeysOnly {
+		return nil
+	}
+	if err := checkMetadataValue("metadata", md, 1, orDefault(vc.MetadataMaxDepth, DefaultMetadataMaxDepth)); err != nil {
+		return err
+	}
+	b, err := json.Marshal(md)
+	if err != nil {
+		return ErrInvalidEvent{Field: "metadata", Reason: "not serializable: " + err.Error(), Code: ErrCodeInvalidValue}
+	}
+	if maxBytes := orDefault(vc.MetadataMaxBytes, DefaultMetadataMaxBytes); len(b) > maxBytes {
+		return ErrInvalidEvent{Field: "metadata", Reason: fmt.Sprintf("serialized size %d bytes exceeds %d", len(b), maxBytes), Code: ErrCodeInvalidValue}
+	}
+	return nil
+}
+
+// checkMetadataValue checks v, found at path, and everything under it. level is
+// the nesting level of v if it is an object or array (metadata itself is 1).
+// Map keys are visited in order so the same input always reports the same path.
+func checkMetadataValue(path string, v interface{}, level, maxDepth int) error {
+	switch v := v.(type) {
+	case nil, string, bool, float64, float32, int, int32, int64, json.Number:
+		return nil
+	case map[string]interface{}:
+		if level > maxDepth {
+			return ErrInvalidEvent{Field: path, Reason: fmt.Sprintf("nested deeper than %d levels", maxDepth), Code: ErrCodeInvalidValue}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := checkMetadataValue(path+"."+k, v[k], level+1, maxDepth); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if level > maxDepth {
+			return ErrInvalidEvent{Field: path, Reason: fmt.Sprintf("nested deeper than %d levels", maxDepth), Code: ErrCodeInvalidValue}
+		}
+		for i, item := range v {
+			if err := checkMetadataValue(fmt.Sprintf("%s[%d]", path, i), item, level+1, maxDepth); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return ErrInvalidEvent{Field: path, Reason: fmt.Sprintf("unsupported value type %T", v), Code: ErrCodeInvalidValue}
+	}
+}
+
+func orDefault(n, def int) int {
+	if n <= 0 {
+		return def
+	}
+	return n
+}
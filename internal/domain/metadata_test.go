@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	decode := func(s string) map[string]interface{} {
+		var md map[string]interface{}
+		if err := json.Unmarshal([]byte(s), &md); err != nil {
+			t.Fatal(err)
+		}
+		return md
+	}
+	vc := ValidationConfig{MetadataMaxDepth: 3, MetadataMaxBytes: 100}
+	tests := []struct {
+		name      string
+		md        map[string]interface{}
+		wantField string // empty: valid
+	}{
+		{"flat", decode(`{"a":"x","b":1,"c":true,"d":null}`), ""},
+		{"nested within depth", decode(`{"items":[{"price":1}]}`), ""},
+		{"too deep", decode(`{"items":[{"price":[1]}]}`), "metadata.items[0].price"},
+		{"unsupported type", map[string]interface{}{"items": []interface{}{1.0, 2.0, 3.0, map[string]interface{}{"price": func() {}}}}, "metadata.items[3].price"},
+		{"too large", decode(`{"blob":"` + strings.Repeat("x", 100) + `"}`), "metadata"},
+		{"too many keys", decode(`{"a":1,"b":2,"c":3,"d":4,"e":5,"f":6,"g":7,"h":8,"i":9,"j":10,"k":11}`), "metadata"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMetadata(tt.md, vc)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("validateMetadata() = %v, want nil", err)
+				}
+				return
+			}
+			ie, ok := err.(ErrInvalidEvent)
+			if !ok || ie.Field != tt.wantField {
+				t.Errorf("validateMetadata() = %v, want error at %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidateWith_MetadataDefaults(t *testing.T) {
+	e := NewEvent("e1", "u1", 10, "USD", "m1", time.Now(), map[string]interface{}{
+		"a": map[string]interface{}{"b": map[string]interface{}{"c": map[string]interface{}{"d": map[string]interface{}{}}}},
+	})
+	err := e.ValidateWith(ValidationConfig{}, time.Now())
+	if ie, ok := err.(ErrInvalidEvent); !ok || ie.Field != "metadata.a.b.c.d" {
+		t.Errorf("ValidateWith() = %v, want depth error at metadata.a.b.c.d (default depth %d)", err, DefaultMetadataMaxDepth)
+	}
+}
+
+func TestValidateMetadata_KeysOnly(t *testing.T) {
+	md := map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{[]interface{}{1}}}}
+	if err := validateMetadata(md, ValidationConfig{MetadataMaxDepth: 2, MetadataKeysOnly: true}); err != nil {
+		t.Errorf("validateMetadata() = %v, want nil with MetadataKeysOnly", err)
+	}
+	many := map[string]interface{}{}
+	for i := 0; i <= DefaultMetadataMaxKeys; i++ {
+		many[string(rune('a'+i))] = i
+	}
+	if err := validateMetadata(many, ValidationConfig{MetadataKeysOnly: true}); err == nil {
+		t.Error("validateMetadata() = nil, want key-count error with MetadataKeysOnly")
+	}
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// currencyMinorUnits lists the ISO 4217 currencies whose minor unit isn't 2 digits
+// (JPY has no minor unit, KWD has fils in thousandths). Everything else uses 2.
+var currencyMinorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0,
+	"XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// MinorUnits returns the number of decimal digits currency is quoted in.
+func MinorUnits(currency string) int {
+	if d, ok := currencyMinorUnits[strings.ToUpper(currency)]; ok {
+		return d
+	}
+	return 2
+}
+
+// FormatAmount renders amount for display in currency's minor units, with
+// thousands separators and the currency code: "1,234.50 USD", "1,235 JPY",
+// "-0.125 KWD". It is the display value query responses return beside the raw
+// amount; consumers should show it rather than format amounts themselves.
+func FormatAmount(amount float64, currency string) string {
+	digits := MinorUnits(currency)
+	scale := math.Pow10(digits)
+	minor := int64(math.Round(math.Abs(amount) * scale))
+	whole := strconv.FormatInt(minor/int64(scale), 10)
+
+	var b strings.Builder
+	if amount < 0 && minor != 0 {
+		b.WriteByte('-')
+	}
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if digits > 0 {
+		frac := strconv.FormatInt(minor%int64(scale), 10)
+		b.WriteByte('.')
+		b.WriteString(strings.Repeat("0", digits-len(frac)))
+		b.WriteString(frac)
+	}
+	b.WriteByte(' ')
+	b.WriteString(strings.ToUpper(currency))
+	return b.String()
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "testing"
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     string
+	}{
+		{1234.5, "USD", "1,234.50 USD"},
+		{0.1 + 0.2, "EUR", "0.30 EUR"},
+		{1234.5, "JPY", "1,235 JPY"},
+		{1234567, "jpy", "1,234,567 JPY"},
+		{12.3456, "KWD", "12.346 KWD"},
+		{-0.125, "BHD", "-0.125 BHD"},
+		{-0.001, "USD", "0.00 USD"},
+		{999.999, "USD", "1,000.00 USD"},
+		{5, "XYZ", "5.00 XYZ"},
+	}
+	for _, tt := range tests {
+		if got := FormatAmount(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("FormatAmount(%v, %q) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
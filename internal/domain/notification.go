@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// NotificationTypeFraudAlert prefixes the notification type of fraud alerts; see
+// FraudAlertType.
+const NotificationTypeFraudAlert = "fraud_alert"
+
+// FraudAlertType is the notification type of the alert for one rule. An event can
+// trip several rules, and each alert must dedupe independently.
+func FraudAlertType(ruleName string) string {
+	return NotificationTypeFraudAlert + ":" + ruleName
+}
+
+// DedupToken derives the deduplication token of a downstream notification from the
+// event and notification type alone, so every re-send of the same notification
+// carries the same token. It is the hex SHA-256 of "v1", eventID, and
+// notificationType joined by NUL bytes; bump the version prefix if the inputs change.
+func DedupToken(eventID, notificationType string) string {
+	sum := sha256.Sum256([]byte("v1\x00" + eventID + "\x00" + notificationType))
+	return hex.EncodeToString(sum[:])
+}
+
+// NotificationAudit records one operator-triggered notification re-send.
+type NotificationAudit struct {
+	AuditID       int64     `json:"audit_id"`
+	EventID       string    `json:"event_id"`
+	Actor         string    `json:"actor"`
+	Reason        string    `json:"reason,omitempty"`
+	Channel       string    `json:"channel"`
+	Notifications int       `json:"notifications"`
+	Error         *string   `json:"error,omitempty"`
+	TriggeredAt   time.Time `json:"triggered_at"`
+}
+
+// NotificationTypeIngestAck is the notification type of producer acknowledgment
+// webhooks; one per event, sent when it reaches a terminal status.
+const NotificationTypeIngestAck = "ingest_ack"
+
+// Terminal outcomes reported in an EventAck.
+const (
+	AckOutcomeProcessed = "processed"
+	AckOutcomeFailed    = "failed"
+)
+
+// HashAPIKey returns the hex SHA-256 of a producer API key. Only the hash is carried
+// on queue messages and stored, so a leaked queue or table never exposes keys.
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// ProducerWebhook is the acknowledgment callback registered for one API key.
+// Secret signs deliveries (X-Fluxa-Signature) and is only shown at registration.
+type ProducerWebhook struct {
+	APIKeyHash string    `json:"-"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// EventAck is POSTed to a producer's webhook when one of its events reaches a
+// terminal status. Deliveries are at-least-once; dedupe on DedupToken.
+type EventAck struct {
+	EventID    string    `json:"event_id"`
+	Outcome    string    `json:"outcome"`
+	Reason     string    `json:"reason,omitempty"`
+	Canary     bool      `json:"canary,omitempty"`
+	DedupToken string    `json:"dedup_token"`
+	OccurredAt time.Time `json:"occurred_at"`
+
+	// Event is the decoded event, when it decoded. It is not part of the standard
+	// body; webhook templates can draw on it.
+	Event *Event `json:"-"`
+}
+
+// NewEventAck builds the acknowledgment for eventID with its dedup token.
+func NewEventAck(eventID, outcome, reason string, at time.Time) EventAck {
+	return EventAck{
+		EventID:    eventID,
+		Outcome:    outcome,
+		Reason:     reason,
+		DedupToken: DedupToken(eventID, NotificationTypeIngestAck),
+		OccurredAt: at.UTC(),
+	}
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "testing"
+
+func TestDedupToken(t *testing.T) {
+	// Pinned: changing the derivation breaks dedupe for consumers holding old tokens.
+	const want = "d929bcb1ba1430c352186c5e2143761365eac91e7fac68d1ba8e5991cfbabcf7"
+	if got := DedupToken("evt-1", FraudAlertType("velocity")); got != want {
+		t.Errorf("DedupToken = %s, want %s", got, want)
+	}
+	if DedupToken("evt-1", FraudAlertType("velocity")) == DedupToken("evt-1", FraudAlertType("amount_threshold")) {
+		t.Error("different notification types share a token")
+	}
+	if DedupToken("a", "b:c") == DedupToken("a:b", "c") {
+		t.Error("token inputs are ambiguous")
+	}
+}
+
+func TestNewAlertMessage_TokenIgnoresFlagID(t *testing.T) {
+	a := NewAlertMessage(FraudFlag{FlagID: "f1", EventID: "evt-1", RuleName: "velocity"})
+	b := NewAlertMessage(FraudFlag{FlagID: "f2", EventID: "evt-1", RuleName: "velocity"})
+	if a.DedupToken == "" || a.DedupToken != b.DedupToken {
+		t.Errorf("re-raised flag tokens differ: %q vs %q", a.DedupToken, b.DedupToken)
+	}
+	if a.FlagID != "f1" || a.RuleName != "velocity" {
+		t.Errorf("NewAlertMessage dropped fields: %+v", a)
+	}
+}
@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// Reconciliation compares the processor's idempotency keys with the events table
+// over a window (GET /admin/reconciliation). Each side should account for the
+// other: a key marked success means its event was persisted, and a persisted
+// event was claimed and settled under its key. Discrepancies point at bugs that
+// otherwise surface only as missing or doubled events.
+type Reconciliation struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// SucceededWithoutEvent are success keys with no events row and no recorded
+	// deletion. Batch keys and imported keys (ImportedWithoutEvent) are left out.
+	SucceededWithoutEvent Discrepancy `json:"succeeded_without_event"`
+	// EventsWithoutSuccess are events whose key is missing or not success.
+	EventsWithoutSuccess Discrepancy `json:"events_without_success"`
+	// ImportedWithoutEvent counts keys imported as processed (attempts 0) that
+	// have no events row, which is expected of migrated events.
+	ImportedWithoutEvent int64 `json:"imported_without_event"`
+}
+
+// Discrepancy is one direction of a Reconciliation: how many event IDs are off,
+// and the oldest of them.
+type Discrepancy struct {
+	Count  int64             `json:"count"`
+	Sample []DiscrepantEvent `json:"sample"`
+}
+
+// DiscrepantEvent is a sampled event ID. KeyStatus is its idempotency key's
+// status, or "" when it has none; At is when the key was last seen or the event
+// stored.
+type DiscrepantEvent struct {
+	EventID   string    `json:"event_id"`
+	KeyStatus string    `json:"key_status,omitempty"`
+	At        time.Time `json:"at"`
+}
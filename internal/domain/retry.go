@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RetryJobStatus is the lifecycle of a bulk retry job, like ExportStatus: pending
+// until a worker picks it up, running while messages are re-enqueued, then
+// complete or failed.
+type RetryJobStatus string
+
+const (
+	RetryJobStatusPending  RetryJobStatus = "pending"
+	RetryJobStatusRunning  RetryJobStatus = "running"
+	RetryJobStatusComplete RetryJobStatus = "complete"
+	RetryJobStatusFailed   RetryJobStatus = "failed"
+)
+
+// RetryJob re-enqueues the events that failed permanently with Reason (the
+// NonRetryableError reason, e.g. "validation_failed") between From (inclusive) and
+// To (exclusive) (GET /admin/retries/{id}). TotalKeys is the number of matching
+// failed keys when the job started; Enqueued have been published again so far and
+// Skipped had no stored message to re-enqueue.
+type RetryJob struct {
+	JobID       string         `json:"job_id"`
+	Reason      string         `json:"reason"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Actor       string         `json:"actor"`
+	Status      RetryJobStatus `json:"status"`
+	TotalKeys   int            `json:"total_keys"`
+	Enqueued    int            `json:"enqueued"`
+	Skipped     int            `json:"skipped"`
+	ErrorReason string         `json:"error_reason,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+}
+
+// FailureRecord is a permanently failed event as support sees it (GET
+// /admin/failures): its idempotency key, the reason code and detail split out of
+// the stored error, and the queue message kept with it (nil for failures recorded
+// before migration 022).
+type FailureRecord struct {
+	EventID     string          `json:"event_id"`
+	Reason      string          `json:"reason"`
+	Detail      string          `json:"detail,omitempty"`
+	Attempts    int             `json:"attempts"`
+	FirstSeenAt time.Time       `json:"first_seen_at"`
+	LastSeenAt  time.Time       `json:"last_seen_at"`
+	Message     json.RawMessage `json:"message,omitempty"`
+}
+
+// FailedMessage is the stored queue message of a permanently failed event; Message
+// is nil for failures recorded before messages were kept.
+type FailedMessage struct {
+	EventID string
+	Message []byte
+}
@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// EventRevision is the content an event had before a PUT /events/{id} replaced
+// it. Version is the version that content had.
+type EventRevision struct {
+	RevisionID int64     `json:"revision_id"`
+	EventID    string    `json:"event_id"`
+	Version    int       `json:"version"`
+	Content    Event     `json:"content"`
+	Actor      string    `json:"actor"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package domain
+
+// Schema drift kinds (SchemaDrift.Kind).
+const (
+	// SchemaDriftNewSchema is the first sample of a producer and schema version;
+	// its fields become the baseline.
+	SchemaDriftNewSchema = "new_schema"
+	// SchemaDriftNewField is a field path the schema has not carried before.
+	SchemaDriftNewField = "new_field"
+	// SchemaDriftTypeChange is a known field path seen with another JSON type.
+	SchemaDriftTypeChange = "type_change"
+)
+
+// PayloadField is a field path of a JSON payload and the JSON type found there.
+// Nested objects are joined with ".", array elements are "[]":
+// "metadata.items[].price".
+type PayloadField struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+// SchemaDrift is a change in the fields a producer's payloads carry.
+type SchemaDrift struct {
+	Kind          string       `json:"kind"`
+	ProducerKey   string       `json:"producer_key"`
+	SchemaVersion string       `json:"schema_version"`
+	Field         PayloadField `json:"field"`          // empty for SchemaDriftNewSchema
+	PreviousTypes []string     `json:"previous_types"` // for SchemaDriftTypeChange
+}
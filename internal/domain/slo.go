@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// SLORollup is one hour of processing (slo_rollups): events persisted (Processed)
+// and failed permanently (Failed), and the ingest→persist latency of the persisted
+// ones. WithinTarget were persisted within TargetMs. The percentiles are nil for an
+// hour with failures only.
+type SLORollup struct {
+	Bucket       time.Time `json:"bucket"`
+	Processed    int64     `json:"processed"`
+	Failed       int64     `json:"failed"`
+	WithinTarget int64     `json:"within_target"`
+	TargetMs     int       `json:"target_ms"`
+	P50Ms        *float64  `json:"p50_ms,omitempty"`
+	P95Ms        *float64  `json:"p95_ms,omitempty"`
+	P99Ms        *float64  `json:"p99_ms,omitempty"`
+	MaxMs        *float64  `json:"max_ms,omitempty"`
+}
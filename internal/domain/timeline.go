@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// EventTimeline is when an event passed each pipeline stage: accepted by ingest,
+// picked up by the processor, committed, and acknowledged to its producer's webhook.
+// NotifiedAt is nil until a webhook delivery succeeds (and always for events
+// submitted without a producer key).
+type EventTimeline struct {
+	EventID     string     `json:"event_id"`
+	ReceivedAt  time.Time  `json:"received_at"`
+	DequeuedAt  time.Time  `json:"dequeued_at"`
+	PersistedAt time.Time  `json:"persisted_at"`
+	NotifiedAt  *time.Time `json:"notified_at,omitempty"`
+}
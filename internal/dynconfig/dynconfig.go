@@ -0,0 +1,239 @@
+// Package dynconfig polls an AWS AppConfig hosted configuration for tunables
+// that may change while a service runs: the inline payload threshold and the
+// event validation limits. Each poll goes through the AppConfig agent (or Lambda
+// extension); a document that fails to parse or validate is rejected and the last
+// good version stays in force, so a bad deployment never takes effect and rolling
+// back in AppConfig restores the previous values on the next poll.
+package dynconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// DefaultPollInterval matches the AppConfig agent's own default poll interval.
+const DefaultPollInterval = 45 * time.Second
+
+// maxDocumentBytes bounds one configuration document.
+const maxDocumentBytes = 64 * 1024
+
+// Tunables are the settings the hosted configuration may override. A zero (or
+// nil) field leaves the service's env configuration in force.
+type Tunables struct {
+	// InlinePayloadMaxBytes lowers the size above which ingest offloads payloads
+	// to object storage; at most domain.MaxInlinePayloadBytes.
+	InlinePayloadMaxBytes int `json:"inline_payload_max_bytes,omitempty"`
+
+	EventMaxFutureDriftSeconds int `json:"event_max_future_drift_seconds,omitempty"`
+	// IngestMaxEventAgeHours replaces INGEST_MAX_EVENT_AGE_HOURS; 0 disables the
+	// check. Per-producer overrides still take precedence.
+	IngestMaxEventAgeHours *int `json:"ingest_max_event_age_hours,omitempty"`
+
+	MetadataMaxKeys  int `json:"metadata_max_keys,omitempty"`
+	MetadataMaxDepth int `json:"metadata_max_depth,omitempty"`
+	MetadataMaxBytes int `json:"metadata_max_bytes,omitempty"`
+}
+
+// Validate rejects values no service should run with.
+func (t Tunables) Validate() error {
+	for name, v := range map[string]int{
+		"inline_payload_max_bytes":       t.InlinePayloadMaxBytes,
+		"event_max_future_drift_seconds": t.EventMaxFutureDriftSeconds,
+		"metadata_max_keys":              t.MetadataMaxKeys,
+		"metadata_max_depth":             t.MetadataMaxDepth,
+		"metadata_max_bytes":             t.MetadataMaxBytes,
+	} {
+		if v < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if t.IngestMaxEventAgeHours != nil && *t.IngestMaxEventAgeHours < 0 {
+		return fmt.Errorf("ingest_max_event_age_hours must not be negative")
+	}
+	if t.InlinePayloadMaxBytes > domain.MaxInlinePayloadBytes {
+		return fmt.Errorf("inline_payload_max_bytes must be at most %d", domain.MaxInlinePayloadBytes)
+	}
+	if t.EventMaxFutureDriftSeconds > 24*3600 {
+		return fmt.Errorf("event_max_future_drift_seconds must be at most one day")
+	}
+	return nil
+}
+
+// Store holds the current tunables and refreshes them from AppConfig. A nil
+// *Store has no overrides. Safe for concurrent use.
+type Store struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+	metrics  ports.Metrics
+	logger   *logging.Logger
+
+	mu       sync.RWMutex
+	current  Tunables
+	version  string
+	document []byte
+}
+
+// NewStore returns a Store polling url, an AppConfig agent configuration path
+// such as http://localhost:2772/applications/fluxa/environments/prod/configurations/tunables.
+// interval <= 0 means DefaultPollInterval; a nil client means http.DefaultClient.
+func NewStore(url string, client *http.Client, interval time.Duration, metrics ports.Metrics, logger *logging.Logger) *Store {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Store{url: url, client: client, interval: interval, metrics: metrics, logger: logger}
+}
+
+// Current returns the tunables in force and the AppConfig version they came
+// from ("" before the first successful poll).
+func (s *Store) Current() (Tunables, string) {
+	if s == nil {
+		return Tunables{}, ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current, s.version
+}
+
+// Poll fetches the configuration once and applies it if it is new and valid.
+func (s *Store) Poll(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.count("error")
+		return fmt.Errorf("dynconfig: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.count("error")
+		return fmt.Errorf("dynconfig: status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentBytes+1))
+	if err != nil {
+		s.count("error")
+		return fmt.Errorf("dynconfig: read: %w", err)
+	}
+	version := resp.Header.Get("Configuration-Version")
+
+	s.mu.RLock()
+	unchanged := bytes.Equal(body, s.document)
+	s.mu.RUnlock()
+	if unchanged {
+		s.count("unchanged")
+		return nil
+	}
+
+	t, err := parse(body)
+	if err != nil {
+		s.count("rejected")
+		if s.logger != nil {
+			s.logger.Warn("Rejected dynamic config; keeping the previous version", map[string]interface{}{
+				"version": version,
+				"error":   err.Error(),
+			})
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	previous := s.version
+	s.current, s.version, s.document = t, version, body
+	s.mu.Unlock()
+	s.count("applied")
+	if s.logger != nil {
+		s.logger.Info("Applied dynamic config", map[string]interface{}{"version": version, "previous_version": previous})
+	}
+	return nil
+}
+
+// Run polls every interval until ctx is done. Poll errors are logged and the
+// previous tunables kept.
+func (s *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Poll(ctx); err != nil && ctx.Err() == nil && s.logger != nil {
+				s.logger.Warn("Dynamic config poll failed", map[string]interface{}{"error": err.Error()})
+			}
+		}
+	}
+}
+
+// Validation returns vc with the tunables' validation overrides applied.
+func (s *Store) Validation(vc domain.ValidationConfig) domain.ValidationConfig {
+	t, _ := s.Current()
+	if t.EventMaxFutureDriftSeconds > 0 {
+		vc.MaxFutureDrift = time.Duration(t.EventMaxFutureDriftSeconds) * time.Second
+	}
+	if t.MetadataMaxKeys > 0 {
+		vc.MetadataMaxKeys = t.MetadataMaxKeys
+	}
+	if t.MetadataMaxDepth > 0 {
+		vc.MetadataMaxDepth = t.MetadataMaxDepth
+	}
+	if t.MetadataMaxBytes > 0 {
+		vc.MetadataMaxBytes = t.MetadataMaxBytes
+	}
+	return vc
+}
+
+// InlinePayloadMaxBytes returns the inline payload threshold in force.
+func (s *Store) InlinePayloadMaxBytes() int {
+	if t, _ := s.Current(); t.InlinePayloadMaxBytes > 0 {
+		return t.InlinePayloadMaxBytes
+	}
+	return domain.MaxInlinePayloadBytes
+}
+
+// MaxEventAgeHours returns the ingest replay window, in hours, given the env value.
+func (s *Store) MaxEventAgeHours(static int) int {
+	if t, _ := s.Current(); t.IngestMaxEventAgeHours != nil {
+		return *t.IngestMaxEventAgeHours
+	}
+	return static
+}
+
+func (s *Store) count(result string) {
+	if s.metrics != nil {
+		s.metrics.IncCounter(metricdef.DynamicConfigReloadsTotal, "result", result)
+	}
+}
+
+// parse decodes a configuration document strictly, so a misspelled key is
+// rejected rather than silently ignored.
+func parse(body []byte) (Tunables, error) {
+	var t Tunables
+	if len(body) > maxDocumentBytes {
+		return t, fmt.Errorf("dynconfig: document larger than %d bytes", maxDocumentBytes)
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return Tunables{}, fmt.Errorf("dynconfig: decode: %w", err)
+	}
+	if err := t.Validate(); err != nil {
+		return Tunables{}, fmt.Errorf("dynconfig: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,103 @@
+package dynconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+func TestNilStore(t *testing.T) {
+	var s *Store
+	base := domain.ValidationConfig{MetadataMaxKeys: 7}
+	if got := s.Validation(base); got != base {
+		t.Errorf("Validation() = %+v, want %+v unchanged", got, base)
+	}
+	if got := s.InlinePayloadMaxBytes(); got != domain.MaxInlinePayloadBytes {
+		t.Errorf("InlinePayloadMaxBytes() = %d, want %d", got, domain.MaxInlinePayloadBytes)
+	}
+	if got := s.MaxEventAgeHours(12); got != 12 {
+		t.Errorf("MaxEventAgeHours(12) = %d, want 12", got)
+	}
+}
+
+func TestPoll_AppliesAndKeepsLastGood(t *testing.T) {
+	version, body := "1", `{"inline_payload_max_bytes": 4096, "metadata_max_depth": 2, "ingest_max_event_age_hours": 0}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Configuration-Version", version)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	metrics := fluxatest.NewMetrics()
+	s := NewStore(ts.URL, nil, time.Minute, metrics, nil)
+
+	if err := s.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, v := s.Current(); v != "1" {
+		t.Errorf("version = %q, want 1", v)
+	}
+	if got := s.InlinePayloadMaxBytes(); got != 4096 {
+		t.Errorf("InlinePayloadMaxBytes() = %d, want 4096", got)
+	}
+	if got := s.MaxEventAgeHours(12); got != 0 {
+		t.Errorf("MaxEventAgeHours(12) = %d, want the dynamic 0", got)
+	}
+	vc := s.Validation(domain.ValidationConfig{MetadataMaxDepth: 4, MetadataMaxKeys: 10})
+	if vc.MetadataMaxDepth != 2 || vc.MetadataMaxKeys != 10 {
+		t.Errorf("Validation() = %+v, want depth 2 and the base key limit", vc)
+	}
+
+	if err := s.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := metrics.Counter(metricdef.DynamicConfigReloadsTotal, "unchanged"); n != 1 {
+		t.Errorf("unchanged polls = %v, want 1", n)
+	}
+
+	for _, bad := range []string{
+		`{"inline_payload_max_bytes": 999999999}`,
+		`{"metadata_max_depht": 3}`,
+		`{"metadata_max_keys": -1}`,
+		`not json`,
+	} {
+		version, body = "2", bad
+		if err := s.Poll(context.Background()); err == nil {
+			t.Errorf("Poll() accepted %s", bad)
+		}
+		if _, v := s.Current(); v != "1" {
+			t.Errorf("after rejecting %s: version = %q, want 1 kept", bad, v)
+		}
+	}
+	if n := metrics.Counter(metricdef.DynamicConfigReloadsTotal, "rejected"); n != 4 {
+		t.Errorf("rejected polls = %v, want 4", n)
+	}
+
+	// Rolling back in AppConfig serves a new version of the old content.
+	version, body = "3", `{}`
+	if err := s.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.InlinePayloadMaxBytes(); got != domain.MaxInlinePayloadBytes {
+		t.Errorf("InlinePayloadMaxBytes() after rollback = %d, want the default", got)
+	}
+}
+
+func TestPoll_ErrorKeepsCurrent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no deployment", http.StatusNotFound)
+	}))
+	defer ts.Close()
+	s := NewStore(ts.URL, nil, time.Minute, nil, nil)
+	if err := s.Poll(context.Background()); err == nil {
+		t.Fatal("Poll() = nil on 404")
+	}
+	if tun, v := s.Current(); v != "" || tun != (Tunables{}) {
+		t.Errorf("Current() = %+v, %q, want zero", tun, v)
+	}
+}
@@ -0,0 +1,141 @@
+// Package enrichment fetches user attributes (country, segment, ...) from an
+// external profile service and attaches them to events. Enrichment is
+// best-effort: callers skip it on any error rather than failing the pipeline.
+package enrichment
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider returns enrichment attributes for a user. An empty map with a nil
+// error means the provider knows nothing about the user.
+type Provider interface {
+	Lookup(ctx context.Context, userID string) (map[string]string, error)
+}
+
+// HTTPProvider calls GET {BaseURL}/users/{user_id} and keeps only Fields from the
+// JSON object it returns. Non-string values are formatted with fmt.Sprint.
+type HTTPProvider struct {
+	BaseURL string
+	Fields  []string
+	Client  *http.Client
+}
+
+// NewHTTPProvider returns an HTTPProvider whose client enforces timeout per lookup.
+func NewHTTPProvider(baseURL string, fields []string, timeout time.Duration) *HTTPProvider {
+	return &HTTPProvider{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Fields:  fields,
+		Client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Lookup fetches the user profile. A 404 is not an error: it yields no attributes.
+func (p *HTTPProvider) Lookup(ctx context.Context, userID string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/users/"+url.PathEscape(userID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("enrichment: build request: %w", err)
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("enrichment: lookup %q: %w", userID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return map[string]string{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("enrichment: lookup %q: unexpected status %d", userID, resp.StatusCode)
+	}
+
+	var profile map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&profile); err != nil {
+		return nil, fmt.Errorf("enrichment: decode profile for %q: %w", userID, err)
+	}
+
+	attrs := make(map[string]string, len(p.Fields))
+	for _, f := range p.Fields {
+		if v, ok := profile[f]; ok && v != nil {
+			attrs[f] = fmt.Sprint(v)
+		}
+	}
+	return attrs, nil
+}
+
+// DefaultCacheMaxEntries bounds a CachingProvider given no capacity.
+const DefaultCacheMaxEntries = 10000
+
+type cacheEntry struct {
+	userID    string
+	attrs     map[string]string
+	expiresAt time.Time
+}
+
+// CachingProvider memoizes successful lookups of the wrapped Provider for TTL,
+// keeping at most max users: the least recently used is evicted to make room.
+// Errors are not cached, so a recovered upstream is used on the next event.
+type CachingProvider struct {
+	next Provider
+	ttl  time.Duration
+	max  int
+
+	mu      sync.Mutex
+	order   *list.List // front = most recently used
+	entries map[string]*list.Element
+}
+
+// NewCachingProvider wraps next with an in-memory TTL cache of up to max users
+// (ENRICHMENT_CACHE_MAX_ENTRIES); max <= 0 selects DefaultCacheMaxEntries.
+func NewCachingProvider(next Provider, ttl time.Duration, max int) *CachingProvider {
+	if max <= 0 {
+		max = DefaultCacheMaxEntries
+	}
+	return &CachingProvider{next: next, ttl: ttl, max: max, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Lookup serves from cache when fresh, otherwise delegates and caches the result.
+func (c *CachingProvider) Lookup(ctx context.Context, userID string) (map[string]string, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if el, ok := c.entries[userID]; ok && now.Before(el.Value.(*cacheEntry).expiresAt) {
+		c.order.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*cacheEntry).attrs, nil
+	}
+	c.mu.Unlock()
+
+	attrs, err := c.next.Lookup(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[userID]; ok {
+		c.order.Remove(el)
+	}
+	c.entries[userID] = c.order.PushFront(&cacheEntry{userID: userID, attrs: attrs, expiresAt: now.Add(c.ttl)})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).userID)
+	}
+	return attrs, nil
+}
+
+// Len returns the number of users cached, expired ones included until evicted.
+func (c *CachingProvider) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
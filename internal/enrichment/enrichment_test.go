@@ -0,0 +1,128 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPProvider_SelectsFields(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/u1":
+			_, _ = w.Write([]byte(`{"country":"DE","segment":"premium","ssn":"secret","tier":3}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewHTTPProvider(srv.URL+"/", []string{"country", "segment", "tier"}, time.Second)
+	attrs, err := p.Lookup(context.Background(), "u1")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if attrs["country"] != "DE" || attrs["segment"] != "premium" || attrs["tier"] != "3" {
+		t.Errorf("attrs = %v", attrs)
+	}
+	if _, ok := attrs["ssn"]; ok {
+		t.Error("unselected field leaked into attrs")
+	}
+
+	attrs, err = p.Lookup(context.Background(), "unknown")
+	if err != nil || len(attrs) != 0 {
+		t.Errorf("404 should yield empty attrs and no error, got %v / %v", attrs, err)
+	}
+}
+
+func TestHTTPProvider_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	p := NewHTTPProvider(srv.URL, []string{"country"}, 20*time.Millisecond)
+	if _, err := p.Lookup(context.Background(), "u1"); err == nil {
+		t.Error("expected timeout error")
+	}
+}
+
+type countingProvider struct {
+	calls int
+	err   error
+}
+
+func (c *countingProvider) Lookup(_ context.Context, _ string) (map[string]string, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return map[string]string{"country": "US"}, nil
+}
+
+func TestCachingProvider(t *testing.T) {
+	next := &countingProvider{}
+	c := NewCachingProvider(next, time.Minute, 0)
+
+	for i := 0; i < 3; i++ {
+		if attrs, err := c.Lookup(context.Background(), "u1"); err != nil || attrs["country"] != "US" {
+			t.Fatalf("Lookup = %v, %v", attrs, err)
+		}
+	}
+	if next.calls != 1 {
+		t.Errorf("upstream called %d times, want 1", next.calls)
+	}
+
+	c.Lookup(context.Background(), "u2")
+	if next.calls != 2 {
+		t.Errorf("distinct user should miss the cache, calls = %d", next.calls)
+	}
+}
+
+func TestCachingProvider_DoesNotCacheErrors(t *testing.T) {
+	next := &countingProvider{err: errors.New("down")}
+	c := NewCachingProvider(next, time.Minute, 0)
+
+	if _, err := c.Lookup(context.Background(), "u1"); err == nil {
+		t.Fatal("expected error")
+	}
+	next.err = nil
+	if attrs, err := c.Lookup(context.Background(), "u1"); err != nil || attrs["country"] != "US" {
+		t.Errorf("recovered upstream not used: %v, %v", attrs, err)
+	}
+}
+
+func TestCachingProvider_Expiry(t *testing.T) {
+	next := &countingProvider{}
+	c := NewCachingProvider(next, time.Millisecond, 0)
+	c.Lookup(context.Background(), "u1")
+	time.Sleep(5 * time.Millisecond)
+	c.Lookup(context.Background(), "u1")
+	if next.calls != 2 {
+		t.Errorf("expired entry should be refetched, calls = %d", next.calls)
+	}
+}
+
+func TestCachingProvider_Capacity(t *testing.T) {
+	next := &countingProvider{}
+	c := NewCachingProvider(next, time.Minute, 2)
+	for _, user := range []string{"u1", "u2", "u1", "u3", "u4", "u5"} {
+		c.Lookup(context.Background(), user)
+		if c.Len() > 2 {
+			t.Fatalf("cache holds %d users, want at most 2", c.Len())
+		}
+	}
+	// u5 and u4 are the most recently used; u1 was evicted.
+	calls := next.calls
+	c.Lookup(context.Background(), "u5")
+	if next.calls != calls {
+		t.Error("most recently used user was evicted")
+	}
+	c.Lookup(context.Background(), "u1")
+	if next.calls != calls+1 {
+		t.Error("least recently used user was not evicted")
+	}
+}
@@ -0,0 +1,118 @@
+// Package eventcodec decodes event payloads by content type (domain.ContentType*),
+// so the payload format can evolve apart from the queue envelope: ingest accepts
+// each format as posted and forwards it as is, and the processor decodes it by
+// the envelope's content_type.
+package eventcodec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	fraudv1 "github.com/fluxa/fluxa/internal/grpc/fraud/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrUnsupported is returned by Decode for a content type it has no decoder for.
+var ErrUnsupported = errors.New("eventcodec: unsupported content type")
+
+// cloudEventsSpecVersion is the only CloudEvents version accepted.
+const cloudEventsSpecVersion = "1.0"
+
+// Parse maps a Content-Type header to the content type an event posted with it
+// is decoded as: the protobuf and CloudEvents types by name, anything else
+// (including no header, or curl's form default) as JSON, as before they existed.
+func Parse(header string) string {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return domain.ContentTypeJSON
+	}
+	switch mediaType {
+	case domain.ContentTypeProtobuf, domain.ContentTypeCloudEvents:
+		return mediaType
+	}
+	return domain.ContentTypeJSON
+}
+
+// Decode decodes payload, of contentType ("" is JSON), into an event. The event
+// is not normalized or validated.
+func Decode(contentType string, payload []byte) (domain.Event, error) {
+	switch contentType {
+	case "", domain.ContentTypeJSON:
+		var event domain.Event
+		err := json.Unmarshal(payload, &event)
+		return event, err
+	case domain.ContentTypeProtobuf:
+		var req fraudv1.EvaluateRequest
+		if err := proto.Unmarshal(payload, &req); err != nil {
+			return domain.Event{}, err
+		}
+		return FromProto(&req), nil
+	case domain.ContentTypeCloudEvents:
+		return decodeCloudEvent(payload)
+	}
+	return domain.Event{}, fmt.Errorf("%w %q", ErrUnsupported, contentType)
+}
+
+// FromProto converts the protobuf form of an event. Metadata values stay strings.
+func FromProto(req *fraudv1.EvaluateRequest) domain.Event {
+	md := req.GetMetadata()
+	var metadata map[string]interface{}
+	if len(md) > 0 {
+		metadata = make(map[string]interface{}, len(md))
+		for k, v := range md {
+			metadata[k] = v
+		}
+	}
+	return *domain.NewEvent(req.GetEventId(), req.GetUserId(), req.GetAmount(), req.GetCurrency(),
+		req.GetMerchant(), req.GetTransactionTime().AsTime(), metadata)
+}
+
+// cloudEvent is a CloudEvents structured-mode JSON event. Extension attributes
+// are ignored.
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            *time.Time      `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	DataBase64      string          `json:"data_base64"`
+}
+
+// decodeCloudEvent decodes the event in a CloudEvent's data. The CloudEvent's id
+// and time stand in for an event_id and timestamp the data leaves out.
+func decodeCloudEvent(payload []byte) (domain.Event, error) {
+	var ce cloudEvent
+	if err := json.Unmarshal(payload, &ce); err != nil {
+		return domain.Event{}, err
+	}
+	switch {
+	case ce.SpecVersion != cloudEventsSpecVersion:
+		return domain.Event{}, fmt.Errorf("cloudevents: specversion %q, want %s", ce.SpecVersion, cloudEventsSpecVersion)
+	case ce.ID == "" || ce.Source == "" || ce.Type == "":
+		return domain.Event{}, errors.New("cloudevents: id, source and type are required")
+	case ce.DataBase64 != "" || len(ce.Data) == 0:
+		return domain.Event{}, errors.New("cloudevents: data must be a JSON event")
+	}
+	if ce.DataContentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(ce.DataContentType); err != nil || mediaType != domain.ContentTypeJSON {
+			return domain.Event{}, fmt.Errorf("cloudevents: datacontenttype %q, want %s", ce.DataContentType, domain.ContentTypeJSON)
+		}
+	}
+	var event domain.Event
+	if err := json.Unmarshal(ce.Data, &event); err != nil {
+		return domain.Event{}, fmt.Errorf("cloudevents: data: %w", err)
+	}
+	if event.EventID == "" {
+		event.EventID = ce.ID
+	}
+	if event.Timestamp.IsZero() && ce.Time != nil {
+		event.Timestamp = *ce.Time
+	}
+	return event, nil
+}
@@ -0,0 +1,84 @@
+package eventcodec
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	fraudv1 "github.com/fluxa/fluxa/internal/grpc/fraud/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestParse(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                                  domain.ContentTypeJSON,
+		"application/json; charset=utf-8":   domain.ContentTypeJSON,
+		"application/x-www-form-urlencoded": domain.ContentTypeJSON,
+		"application/x-protobuf":            domain.ContentTypeProtobuf,
+		"application/cloudevents+json; a=b": domain.ContentTypeCloudEvents,
+		"not a media type;;":                domain.ContentTypeJSON,
+	} {
+		if got := Parse(header); got != want {
+			t.Errorf("Parse(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestDecode_CloudEvents(t *testing.T) {
+	ce := `{"specversion":"1.0","id":"ce-1","source":"urn:shop","type":"com.shop.order","time":"2026-01-02T03:04:05Z",
+		"datacontenttype":"application/json","data":{"user_id":"u1","amount":10,"currency":"USD","merchant":"acme"}}`
+	event, err := Decode(domain.ContentTypeCloudEvents, []byte(ce))
+	if err != nil {
+		t.Fatalf("Decode = %v", err)
+	}
+	if event.EventID != "ce-1" || event.UserID != "u1" || !event.Timestamp.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("event = %+v, want the CloudEvent's id and time for the missing event_id and timestamp", event)
+	}
+
+	for name, bad := range map[string]string{
+		"old spec":    `{"specversion":"0.3","id":"x","source":"s","type":"t","data":{}}`,
+		"no source":   `{"specversion":"1.0","id":"x","type":"t","data":{}}`,
+		"binary data": `{"specversion":"1.0","id":"x","source":"s","type":"t","data_base64":"AAEC"}`,
+		"xml data":    `{"specversion":"1.0","id":"x","source":"s","type":"t","datacontenttype":"application/xml","data":"<e/>"}`,
+	} {
+		if _, err := Decode(domain.ContentTypeCloudEvents, []byte(bad)); err == nil {
+			t.Errorf("%s: Decode = nil error", name)
+		}
+	}
+}
+
+func TestDecode_Unsupported(t *testing.T) {
+	if _, err := Decode("text/csv", []byte("a,b")); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Decode(text/csv) = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestFromProto(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	req := &fraudv1.EvaluateRequest{
+		EventId:         "e1",
+		UserId:          "u1",
+		Amount:          12.5,
+		Currency:        "USD",
+		Merchant:        "acme",
+		TransactionTime: timestamppb.New(now),
+		Metadata:        map[string]string{"channel": "mobile"},
+	}
+	got := FromProto(req)
+	if got.EventID != "e1" || got.UserID != "u1" || got.Amount != 12.5 || got.Currency != "USD" || got.Merchant != "acme" {
+		t.Errorf("scalar fields wrong: %+v", got)
+	}
+	if !got.Timestamp.Equal(now) {
+		t.Errorf("timestamp = %v, want %v", got.Timestamp, now)
+	}
+	if v, ok := got.Metadata["channel"]; !ok || v != "mobile" {
+		t.Errorf("metadata channel = %v ok=%v, want mobile/true", v, ok)
+	}
+
+	req2 := &fraudv1.EvaluateRequest{EventId: "e2", TransactionTime: timestamppb.Now()}
+	got2 := FromProto(req2)
+	if got2.Currency != "" || len(got2.Metadata) != 0 {
+		t.Errorf("empty req → got %+v", got2)
+	}
+}
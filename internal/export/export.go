@@ -0,0 +1,244 @@
+// Package export runs asynchronous event exports (query service POST /exports).
+// A Runner counts the events matching an export's filters, writes them as CSV or
+// NDJSON, stores the file in object storage, and records progress and the outcome
+// on the export row as it goes, which GET /exports/{id} reports.
+//
+// Jobs run in the process that starts them, a few at a time. The file is built in
+// memory before upload, so Options.MaxRows bounds an export; a narrower filter is
+// the answer to a job rejected as too large.
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/jobs"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// Store reads the events of an export and saves its progress; *db.Client
+// implements it.
+type Store interface {
+	UpdateExport(export *domain.Export) error
+	CountExportEvents(ctx context.Context, filter domain.ExportFilter) (int, error)
+	StreamExportEvents(ctx context.Context, filter domain.ExportFilter, fn func(*domain.EventRecord) error) error
+}
+
+// Options tunes a Runner. Zero values select the defaults.
+type Options struct {
+	Workers       int           // concurrent jobs; default 2
+	MaxRows       int           // largest export; default 1,000,000
+	ProgressEvery int           // rows between progress saves; default 10,000
+	Timeout       time.Duration // per job; default 30m
+}
+
+// Runner executes export jobs.
+type Runner struct {
+	store   Store
+	storage ports.Storage
+	metrics ports.Metrics
+	logger  *logging.Logger
+	opts    Options
+	pool    *jobs.Pool
+}
+
+// NewRunner returns a Runner writing export files to storage.
+func NewRunner(store Store, storage ports.Storage, metrics ports.Metrics, logger *logging.Logger, opts Options) *Runner {
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = 1000000
+	}
+	if opts.ProgressEvery <= 0 {
+		opts.ProgressEvery = 10000
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Minute
+	}
+	return &Runner{
+		store:   store,
+		storage: storage,
+		metrics: metrics,
+		logger:  logger,
+		opts:    opts,
+		pool:    jobs.NewPool(opts.Workers, opts.Timeout),
+	}
+}
+
+// ObjectKey is where the file of export is stored.
+func ObjectKey(export *domain.Export) string {
+	return "exports/" + export.ExportID + "." + string(export.Format)
+}
+
+// Start runs export in the background. It stays pending until one of the
+// Workers slots is free.
+func (r *Runner) Start(export *domain.Export) {
+	r.pool.Start(func(ctx context.Context) { _ = r.Run(ctx, export) })
+}
+
+// Wait blocks until every started job has finished.
+func (r *Runner) Wait() {
+	r.pool.Wait()
+}
+
+// Run executes export and records its outcome on it, returning the failure if any.
+func (r *Runner) Run(ctx context.Context, export *domain.Export) error {
+	start := time.Now()
+	err := r.run(ctx, export)
+	if err != nil {
+		now := time.Now().UTC()
+		export.Status, export.CompletedAt = domain.ExportStatusFailed, &now
+		if export.ErrorReason == "" {
+			export.ErrorReason = "internal_error"
+		}
+		r.logger.Error("Export failed", err, map[string]interface{}{"export_id": export.ExportID, "reason": export.ErrorReason})
+	} else {
+		r.logger.Info("Export complete", map[string]interface{}{
+			"export_id":   export.ExportID,
+			"rows":        export.RowsExported,
+			"duration_ms": time.Since(start).Milliseconds(),
+		})
+	}
+	r.save(export)
+	r.metrics.IncCounter(metricdef.ExportsTotal, "status", string(export.Status))
+	return err
+}
+
+func (r *Runner) run(ctx context.Context, export *domain.Export) error {
+	export.Status = domain.ExportStatusRunning
+	r.save(export)
+
+	total, err := r.store.CountExportEvents(ctx, export.Filter)
+	if err != nil {
+		export.ErrorReason = "query_failed"
+		return err
+	}
+	if total > r.opts.MaxRows {
+		export.ErrorReason = fmt.Sprintf("too_many_rows: %d events match, limit %d; narrow the filters", total, r.opts.MaxRows)
+		return fmt.Errorf("export: %d rows exceed limit %d", total, r.opts.MaxRows)
+	}
+	export.TotalRows = total
+	r.save(export)
+
+	var buf bytes.Buffer
+	w, err := newRowWriter(export.Format, &buf)
+	if err != nil {
+		export.ErrorReason = "unsupported_format"
+		return err
+	}
+	err = r.store.StreamExportEvents(ctx, export.Filter, func(rec *domain.EventRecord) error {
+		if export.RowsExported >= r.opts.MaxRows {
+			return fmt.Errorf("export: more than %d rows matched while streaming", r.opts.MaxRows)
+		}
+		if err := w.write(rec); err != nil {
+			return err
+		}
+		export.RowsExported++
+		if export.RowsExported%r.opts.ProgressEvery == 0 {
+			r.save(export)
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.flush()
+	}
+	if err != nil {
+		export.ErrorReason = "query_failed"
+		return err
+	}
+
+	key := ObjectKey(export)
+	if err := r.storage.Put(ctx, key, buf.Bytes()); err != nil {
+		export.ErrorReason = "storage_failed"
+		return err
+	}
+	now := time.Now().UTC()
+	export.Status, export.ObjectKey, export.CompletedAt = domain.ExportStatusComplete, key, &now
+	return nil
+}
+
+// save records export's progress. Failures are logged, not fatal: the job carries
+// on and the next save catches the row up.
+func (r *Runner) save(export *domain.Export) {
+	if err := r.store.UpdateExport(export); err != nil {
+		r.logger.Error("Failed to save export progress", err, map[string]interface{}{"export_id": export.ExportID})
+	}
+}
+
+// rowWriter encodes events in one export format.
+type rowWriter interface {
+	write(rec *domain.EventRecord) error
+	flush() error
+}
+
+func newRowWriter(format domain.ExportFormat, buf *bytes.Buffer) (rowWriter, error) {
+	switch format {
+	case domain.ExportFormatCSV:
+		w := &csvWriter{w: csv.NewWriter(buf)}
+		return w, w.w.Write(csvHeader)
+	case domain.ExportFormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(buf)}, nil
+	default:
+		return nil, fmt.Errorf("export: unsupported format %q", format)
+	}
+}
+
+var csvHeader = []string{
+	"event_id", "correlation_id", "user_id", "amount", "currency", "merchant", "canonical_merchant",
+	"timestamp", "payload_mode", "s3_key", "metadata", "enrichment", "created_at",
+}
+
+// csvWriter writes one row per event; metadata and enrichment are JSON cells.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) write(rec *domain.EventRecord) error {
+	metadata, err := jsonCell(rec.Metadata)
+	if err != nil {
+		return err
+	}
+	enrichment, err := jsonCell(rec.Enrichment)
+	if err != nil {
+		return err
+	}
+	s3Key := ""
+	if rec.S3Key != nil {
+		s3Key = *rec.S3Key
+	}
+	return c.w.Write([]string{
+		rec.EventID, rec.CorrelationID, rec.UserID, strconv.FormatFloat(rec.Amount, 'f', -1, 64),
+		rec.Currency, rec.Merchant, rec.CanonicalMerchant, rec.Timestamp.UTC().Format(time.RFC3339Nano),
+		string(rec.PayloadMode), s3Key, metadata, enrichment, rec.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func jsonCell(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ndjsonWriter writes one domain.EventRecord JSON object per line.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) write(rec *domain.EventRecord) error { return n.enc.Encode(rec) }
+func (n *ndjsonWriter) flush() error                        { return nil }
@@ -0,0 +1,142 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+type fakeStore struct {
+	events  []*domain.EventRecord
+	updates []domain.Export
+}
+
+func (s *fakeStore) UpdateExport(e *domain.Export) error {
+	s.updates = append(s.updates, *e)
+	return nil
+}
+
+func (s *fakeStore) CountExportEvents(ctx context.Context, filter domain.ExportFilter) (int, error) {
+	return len(s.events), nil
+}
+
+func (s *fakeStore) StreamExportEvents(ctx context.Context, filter domain.ExportFilter, fn func(*domain.EventRecord) error) error {
+	for _, e := range s.events {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func records(n int) []*domain.EventRecord {
+	ts := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	out := make([]*domain.EventRecord, n)
+	for i := range out {
+		out[i] = &domain.EventRecord{
+			EventID:     "evt-" + string(rune('a'+i)),
+			UserID:      "u1",
+			Amount:      12.5,
+			Currency:    "JPY",
+			Merchant:    "Shop, Inc.",
+			Timestamp:   ts,
+			Metadata:    map[string]interface{}{"k": "v"},
+			PayloadMode: domain.PayloadModeInline,
+			CreatedAt:   ts,
+		}
+	}
+	return out
+}
+
+func newTestRunner(store Store, storage *fluxatest.Storage, m *fluxatest.Metrics, opts Options) *Runner {
+	return NewRunner(store, storage, m, logging.NewLogger("test", "test"), opts)
+}
+
+func TestRunner_WritesCSV(t *testing.T) {
+	store := &fakeStore{events: records(3)}
+	storage := fluxatest.NewStorage()
+	m := fluxatest.NewMetrics()
+	e := &domain.Export{ExportID: "x1", Format: domain.ExportFormatCSV, Status: domain.ExportStatusPending}
+
+	if err := newTestRunner(store, storage, m, Options{ProgressEvery: 2}).Run(context.Background(), e); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if e.Status != domain.ExportStatusComplete || e.TotalRows != 3 || e.RowsExported != 3 || e.ObjectKey != "exports/x1.csv" || e.CompletedAt == nil {
+		t.Errorf("export = %+v, want complete with 3 rows at exports/x1.csv", e)
+	}
+	data, err := storage.Get(context.Background(), e.ObjectKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatalf("stored file is not CSV: %v", err)
+	}
+	if len(rows) != 4 || rows[0][0] != "event_id" || rows[1][5] != "Shop, Inc." || rows[1][10] != `{"k":"v"}` {
+		t.Errorf("csv rows = %q", rows)
+	}
+	// running, counted, one mid-stream progress save, final
+	if len(store.updates) != 4 || store.updates[2].RowsExported != 2 {
+		t.Errorf("progress updates = %+v", store.updates)
+	}
+	if m.Counter(metricdef.ExportsTotal, "complete") != 1 {
+		t.Error("completed export not counted")
+	}
+}
+
+func TestRunner_WritesNDJSON(t *testing.T) {
+	storage := fluxatest.NewStorage()
+	e := &domain.Export{ExportID: "x2", Format: domain.ExportFormatNDJSON}
+	if err := newTestRunner(&fakeStore{events: records(2)}, storage, fluxatest.NewMetrics(), Options{}).Run(context.Background(), e); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	data, _ := storage.Get(context.Background(), "exports/x2.ndjson")
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var rec domain.EventRecord
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &rec) != nil || rec.EventID != "evt-b" {
+		t.Errorf("ndjson = %s", data)
+	}
+}
+
+func TestRunner_RejectsTooManyRows(t *testing.T) {
+	storage := fluxatest.NewStorage()
+	m := fluxatest.NewMetrics()
+	e := &domain.Export{ExportID: "x3", Format: domain.ExportFormatCSV}
+	if err := newTestRunner(&fakeStore{events: records(3)}, storage, m, Options{MaxRows: 2}).Run(context.Background(), e); err == nil {
+		t.Fatal("Run() = nil, want too-many-rows failure")
+	}
+	if e.Status != domain.ExportStatusFailed || !strings.HasPrefix(e.ErrorReason, "too_many_rows") {
+		t.Errorf("export = %+v, want failed too_many_rows", e)
+	}
+	if ok, _ := storage.Exists(context.Background(), "exports/x3.csv"); ok {
+		t.Error("a rejected export stored a file")
+	}
+	if m.Counter(metricdef.ExportsTotal, "failed") != 1 {
+		t.Error("failed export not counted")
+	}
+}
+
+func TestRunner_StartLimitsConcurrency(t *testing.T) {
+	r := newTestRunner(&fakeStore{events: records(1)}, fluxatest.NewStorage(), fluxatest.NewMetrics(), Options{Workers: 1})
+	exports := []*domain.Export{
+		{ExportID: "c1", Format: domain.ExportFormatCSV},
+		{ExportID: "c2", Format: domain.ExportFormatCSV},
+	}
+	for _, e := range exports {
+		r.Start(e)
+	}
+	r.Wait()
+	for _, e := range exports {
+		if e.Status != domain.ExportStatusComplete {
+			t.Errorf("%s status = %s, want complete", e.ExportID, e.Status)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package featureflags gates risky behaviors behind runtime flags, so a change
+// can be rolled forward or back by flipping a flag instead of redeploying every
+// service. Flags are read from an ordered list of Sources (environment, AWS
+// AppConfig agent) and cached for a TTL; every flag has a built-in default that
+// preserves the shipped behavior when no source sets it.
+package featureflags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/logging"
+)
+
+// Flag names a runtime flag. Names are lower snake case in every source.
+type Flag string
+
+const (
+	// StrictJSON rejects ingest bodies with fields the event schema doesn't know.
+	StrictJSON Flag = "strict_json"
+	// AtomicBatches allows "atomic": true on POST /events/batch; when off, such
+	// batches are rejected and producers must fall back to per-member batches.
+	AtomicBatches Flag = "atomic_batches"
+	// StrictMetadataValidation applies the metadata depth, type, and size checks;
+	// when off, only the top-level key count is enforced.
+	StrictMetadataValidation Flag = "strict_metadata_validation"
+	// IdempotencyUpsert claims idempotency keys with one INSERT ... ON CONFLICT
+	// statement; when off, the processor takes the SELECT FOR UPDATE path.
+	IdempotencyUpsert Flag = "idempotency_upsert"
+	// RecordRequests has ingest keep redacted copies of a sample of its requests
+	// and responses (internal/recording).
+	RecordRequests Flag = "record_requests"
+)
+
+// defaults are the values used when no source sets a flag.
+var defaults = map[Flag]bool{
+	StrictJSON:               false,
+	AtomicBatches:            true,
+	StrictMetadataValidation: true,
+	IdempotencyUpsert:        true,
+	RecordRequests:           false,
+}
+
+// Known returns every flag with its default, for docs and debugging.
+func Known() map[Flag]bool {
+	out := make(map[Flag]bool, len(defaults))
+	for f, v := range defaults {
+		out[f] = v
+	}
+	return out
+}
+
+// Source loads flag values. A flag missing from the result is left to later
+// sources or its default.
+type Source interface {
+	Load(ctx context.Context) (map[Flag]bool, error)
+}
+
+// DefaultTTL is how long loaded values are served before a reload.
+const DefaultTTL = 30 * time.Second
+
+// loadTimeout bounds one reload of all sources.
+const loadTimeout = 2 * time.Second
+
+// Flags serves flag values, reloading them from its sources at most once per
+// TTL. A nil *Flags serves the defaults. Safe for concurrent use.
+type Flags struct {
+	sources []Source
+	ttl     time.Duration
+	logger  *logging.Logger
+
+	mu       sync.RWMutex
+	values   map[Flag]bool
+	loadedAt time.Time
+}
+
+// New returns Flags reading sources in order; later sources override earlier
+// ones. ttl <= 0 means DefaultTTL.
+func New(ttl time.Duration, logger *logging.Logger, sources ...Source) *Flags {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Flags{sources: sources, ttl: ttl, logger: logger}
+}
+
+// Enabled reports whether flag is on. It never fails: a source error keeps the
+// previously loaded value for that source's flags (or the default).
+func (f *Flags) Enabled(flag Flag) bool {
+	if f == nil {
+		return defaults[flag]
+	}
+	f.refreshIfStale()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if v, ok := f.values[flag]; ok {
+		return v
+	}
+	return defaults[flag]
+}
+
+// Validation returns vc adjusted for the validator flags.
+func (f *Flags) Validation(vc domain.ValidationConfig) domain.ValidationConfig {
+	if !f.Enabled(StrictMetadataValidation) {
+		vc.MetadataKeysOnly = true
+	}
+	return vc
+}
+
+// Invalidate forces the next Enabled call to reload.
+func (f *Flags) Invalidate() {
+	f.mu.Lock()
+	f.loadedAt = time.Time{}
+	f.mu.Unlock()
+}
+
+func (f *Flags) refreshIfStale() {
+	f.mu.RLock()
+	fresh := time.Since(f.loadedAt) < f.ttl
+	f.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if time.Since(f.loadedAt) < f.ttl {
+		return
+	}
+	// Stamp before loading so a failing source is retried once per TTL, not per call.
+	f.loadedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	values := make(map[Flag]bool, len(f.values))
+	for flag, v := range f.values {
+		values[flag] = v
+	}
+	for _, src := range f.sources {
+		loaded, err := src.Load(ctx)
+		if err != nil {
+			if f.logger != nil {
+				f.logger.Warn("Feature flag refresh failed; using cached values", map[string]interface{}{"error": err.Error()})
+			}
+			continue
+		}
+		for flag, v := range loaded {
+			values[flag] = v
+		}
+	}
+	f.values = values
+}
+
+// EnvSource reads FLAG_<NAME> variables, e.g. FLAG_STRICT_JSON=true, for every
+// known flag. Values are parsed with strconv.ParseBool.
+type EnvSource struct{}
+
+// Load implements Source.
+func (EnvSource) Load(context.Context) (map[Flag]bool, error) {
+	out := map[Flag]bool{}
+	for flag := range defaults {
+		key := "FLAG_" + strings.ToUpper(string(flag))
+		raw, ok := os.LookupEnv(key)
+		if !ok || raw == "" {
+			continue
+		}
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		out[flag] = v
+	}
+	return out, nil
+}
+
+// AppConfigSource reads a feature-flag configuration profile through the AWS
+// AppConfig agent (or the AppConfig Lambda extension), which serves it at
+// http://localhost:2772/applications/<app>/environments/<env>/configurations/<profile>.
+// Both the AppConfig feature-flag shape ({"strict_json": {"enabled": true}}) and
+// a plain {"strict_json": true} document are accepted; unknown flags are ignored.
+type AppConfigSource struct {
+	URL    string
+	Client *http.Client // nil means http.DefaultClient
+}
+
+// Load implements Source.
+func (s AppConfigSource) Load(ctx context.Context) (map[Flag]bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("appconfig: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("appconfig: status %d", resp.StatusCode)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("appconfig: decode: %w", err)
+	}
+	out := map[Flag]bool{}
+	for name, raw := range doc {
+		flag := Flag(name)
+		if _, known := defaults[flag]; !known {
+			continue
+		}
+		var plain bool
+		if err := json.Unmarshal(raw, &plain); err == nil {
+			out[flag] = plain
+			continue
+		}
+		var wrapped struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil || wrapped.Enabled == nil {
+			return nil, fmt.Errorf("appconfig: flag %s: want a bool or {\"enabled\": bool}", name)
+		}
+		out[flag] = *wrapped.Enabled
+	}
+	return out, nil
+}
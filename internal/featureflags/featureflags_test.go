@@ -0,0 +1,115 @@
+package featureflags
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+type fakeSource struct {
+	values map[Flag]bool
+	err    error
+	calls  int
+}
+
+func (f *fakeSource) Load(context.Context) (map[Flag]bool, error) {
+	f.calls++
+	return f.values, f.err
+}
+
+func TestEnabled_Defaults(t *testing.T) {
+	var nilFlags *Flags
+	for _, f := range []*Flags{nilFlags, New(time.Minute, nil)} {
+		if f.Enabled(StrictJSON) || !f.Enabled(AtomicBatches) || !f.Enabled(StrictMetadataValidation) {
+			t.Errorf("Enabled() did not return the defaults %v", Known())
+		}
+	}
+	if vc := nilFlags.Validation(domain.ValidationConfig{}); vc.MetadataKeysOnly {
+		t.Error("Validation() set MetadataKeysOnly with strict_metadata_validation on by default")
+	}
+}
+
+func TestEnabled_LaterSourceWinsAndCaches(t *testing.T) {
+	first := &fakeSource{values: map[Flag]bool{StrictJSON: true, AtomicBatches: false}}
+	second := &fakeSource{values: map[Flag]bool{StrictJSON: false}}
+	f := New(time.Hour, nil, first, second)
+
+	if f.Enabled(StrictJSON) {
+		t.Error("strict_json = true, want the later source's false")
+	}
+	if f.Enabled(AtomicBatches) {
+		t.Error("atomic_batches = true, want the first source's false")
+	}
+	if first.calls != 1 || second.calls != 1 {
+		t.Errorf("sources loaded %d/%d times, want once each within the TTL", first.calls, second.calls)
+	}
+
+	f.Invalidate()
+	f.Enabled(StrictJSON)
+	if first.calls != 2 {
+		t.Errorf("first source loaded %d times after Invalidate, want 2", first.calls)
+	}
+}
+
+func TestEnabled_SourceErrorKeepsCachedValues(t *testing.T) {
+	src := &fakeSource{values: map[Flag]bool{StrictMetadataValidation: false}}
+	f := New(time.Hour, nil, src)
+	if f.Enabled(StrictMetadataValidation) {
+		t.Fatal("strict_metadata_validation = true, want false from the source")
+	}
+
+	src.values, src.err = nil, errors.New("agent down")
+	f.Invalidate()
+	if f.Enabled(StrictMetadataValidation) {
+		t.Error("strict_metadata_validation = true after a failed refresh, want the cached false")
+	}
+	if vc := f.Validation(domain.ValidationConfig{}); !vc.MetadataKeysOnly {
+		t.Error("Validation() left MetadataKeysOnly unset with strict_metadata_validation off")
+	}
+}
+
+func TestEnvSource(t *testing.T) {
+	t.Setenv("FLAG_STRICT_JSON", "true")
+	t.Setenv("FLAG_ATOMIC_BATCHES", "0")
+	got, err := EnvSource{}.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got[StrictJSON] || got[AtomicBatches] {
+		t.Errorf("Load() = %v", got)
+	}
+	if _, ok := got[StrictMetadataValidation]; ok {
+		t.Error("Load() set strict_metadata_validation, which has no env var")
+	}
+
+	t.Setenv("FLAG_STRICT_JSON", "sometimes")
+	if _, err := (EnvSource{}).Load(context.Background()); err == nil {
+		t.Error("Load() accepted FLAG_STRICT_JSON=sometimes")
+	}
+}
+
+func TestAppConfigSource(t *testing.T) {
+	body := `{"strict_json": {"enabled": true}, "atomic_batches": false, "someone_elses_flag": {"enabled": true}}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	got, err := AppConfigSource{URL: ts.URL}.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[StrictJSON] || got[AtomicBatches] {
+		t.Errorf("Load() = %v, want strict_json on and atomic_batches off only", got)
+	}
+
+	body = `{"strict_json": "yes"}`
+	if _, err := (AppConfigSource{URL: ts.URL}).Load(context.Background()); err == nil {
+		t.Error("Load() accepted a string flag value")
+	}
+}
@@ -0,0 +1,235 @@
+// Package fixtures generates realistic, reproducible transaction events: skewed
+// user and merchant popularity, log-normal amounts with a heavy tail, a weighted
+// currency mix, varied metadata, and payload sizes drawn from a configurable
+// distribution. The same seed always yields the same events, so a failing test or
+// load run can be replayed exactly.
+//
+// It is shared by cmd/loadgen, cmd/fixtures (JSONL for the local harness), and tests.
+package fixtures
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/google/uuid"
+)
+
+// SizeBucket is one weighted entry of a payload-size distribution.
+type SizeBucket struct {
+	Weight float64
+	Bytes  int
+}
+
+// ParseSizeDist parses "weight:bytes,..." (e.g. "0.9:512,0.09:65536,0.01:300000").
+// Weights are relative and need not sum to 1; bytes is the target serialized size.
+func ParseSizeDist(s string) ([]SizeBucket, error) {
+	var out []SizeBucket
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		w, b, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("size bucket %q: want weight:bytes", part)
+		}
+		weight, err := strconv.ParseFloat(w, 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("size bucket %q: invalid weight", part)
+		}
+		n, err := strconv.Atoi(b)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("size bucket %q: invalid byte size", part)
+		}
+		out = append(out, SizeBucket{Weight: weight, Bytes: n})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("empty size distribution")
+	}
+	return out, nil
+}
+
+// Currency is one weighted entry of the currency mix. Exponent is the number of
+// minor-unit digits (2 for USD, 0 for JPY); Scale converts a USD amount into it.
+type Currency struct {
+	Code     string
+	Weight   float64
+	Scale    float64
+	Exponent int
+}
+
+// DefaultCurrencies is a card-present mix dominated by USD, with a rare share of
+// high-risk codes (see rules.yaml) so fraud rules see realistic positives.
+var DefaultCurrencies = []Currency{
+	{Code: "USD", Weight: 0.72, Scale: 1, Exponent: 2},
+	{Code: "EUR", Weight: 0.12, Scale: 0.92, Exponent: 2},
+	{Code: "GBP", Weight: 0.07, Scale: 0.79, Exponent: 2},
+	{Code: "CAD", Weight: 0.04, Scale: 1.36, Exponent: 2},
+	{Code: "JPY", Weight: 0.04, Scale: 150, Exponent: 0},
+	{Code: "XMR", Weight: 0.01, Scale: 0.006, Exponent: 2},
+}
+
+// DefaultMerchants are raw card descriptors, including variants the merchant
+// canonicalizer folds together.
+var DefaultMerchants = []string{
+	"Amazon Marketplace", "AMZN Mktp US", "Walmart Online", "Target", "Best Buy",
+	"Steam Games", "PlayStation Store", "Starbucks", "UBER *TRIP", "Uber Eats",
+	"Shell", "Apple Store", "Netflix", "Spotify", "Costco Wholesale", "Home Depot",
+}
+
+// DefaultSizes is the production payload mix: mostly small, a tail above the
+// inline limit.
+var DefaultSizes = []SizeBucket{{Weight: 0.95, Bytes: 400}, {Weight: 0.04, Bytes: 16 * 1024}, {Weight: 0.01, Bytes: 300 * 1024}}
+
+var (
+	channels     = []string{"web", "ios", "android", "pos", "api"}
+	emailDomains = []string{"gmail.com", "yahoo.com", "outlook.com", "icloud.com", "protonmail.com", "anonymous.com"}
+	countries    = []string{"US", "US", "US", "CA", "GB", "DE", "FR", "JP", "BR", "NG"}
+)
+
+// Options configures a Generator. Zero values select the defaults.
+type Options struct {
+	Seed       int64
+	Users      int        // distinct user IDs; default 1000
+	Merchants  []string   // default DefaultMerchants
+	Currencies []Currency // default DefaultCurrencies
+	Sizes      []SizeBucket
+	// MedianAmount is the median USD amount; the tail follows a log-normal with
+	// sigma 1.2, so roughly 1 in 1000 events exceeds 40x the median. Default 25.
+	MedianAmount float64
+	// Start, when set, timestamps events from Start with exponential gaps averaging
+	// MeanGap (default 1s); otherwise events are stamped time.Now().
+	Start   time.Time
+	MeanGap time.Duration
+	// Source is written to metadata.source; default "fixtures".
+	Source string
+	// CanaryRatio is the fraction of events marked canary.
+	CanaryRatio float64
+}
+
+// Generator produces events per Options. Safe for concurrent use.
+type Generator struct {
+	mu     sync.Mutex
+	rng    *rand.Rand
+	opts   Options
+	totalW float64
+	currW  float64
+	clock  time.Time
+	n      int
+}
+
+// New returns a Generator seeded with opts.Seed.
+func New(opts Options) *Generator {
+	if opts.Users <= 0 {
+		opts.Users = 1000
+	}
+	if len(opts.Merchants) == 0 {
+		opts.Merchants = DefaultMerchants
+	}
+	if len(opts.Currencies) == 0 {
+		opts.Currencies = DefaultCurrencies
+	}
+	if len(opts.Sizes) == 0 {
+		opts.Sizes = DefaultSizes
+	}
+	if opts.MedianAmount <= 0 {
+		opts.MedianAmount = 25
+	}
+	if opts.MeanGap <= 0 {
+		opts.MeanGap = time.Second
+	}
+	if opts.Source == "" {
+		opts.Source = "fixtures"
+	}
+	g := &Generator{rng: rand.New(rand.NewSource(opts.Seed)), opts: opts, clock: opts.Start}
+	for _, b := range opts.Sizes {
+		g.totalW += b.Weight
+	}
+	for _, c := range opts.Currencies {
+		g.currW += c.Weight
+	}
+	return g
+}
+
+// Next returns the next event and its JSON payload, padded toward a size drawn
+// from the size distribution.
+func (g *Generator) Next() (*domain.Event, []byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+
+	// Zipf-ish skew: a few users and merchants dominate, like real traffic.
+	user := fmt.Sprintf("user-%d", int(float64(g.opts.Users)*g.rng.Float64()*g.rng.Float64()))
+	merchant := g.opts.Merchants[int(float64(len(g.opts.Merchants))*g.rng.Float64()*g.rng.Float64())]
+	cur := g.pickCurrency()
+	amount := g.opts.MedianAmount * math.Exp(1.2*g.rng.NormFloat64()) * cur.Scale
+	unit := math.Pow(10, float64(cur.Exponent))
+	amount = math.Max(math.Round(amount*unit)/unit, 1/unit)
+
+	ts := time.Now().UTC()
+	if !g.opts.Start.IsZero() {
+		g.clock = g.clock.Add(time.Duration(g.rng.ExpFloat64() * float64(g.opts.MeanGap)))
+		ts = g.clock.UTC()
+	}
+
+	meta := map[string]interface{}{
+		"source":       g.opts.Source,
+		"seq":          g.n,
+		"channel":      channels[g.rng.Intn(len(channels))],
+		"email_domain": emailDomains[g.rng.Intn(len(emailDomains))],
+		"ip_country":   countries[g.rng.Intn(len(countries))],
+	}
+	if g.rng.Float64() < 0.3 {
+		meta["device"] = map[string]interface{}{
+			"os":       []string{"ios", "android", "macos", "windows"}[g.rng.Intn(4)],
+			"trusted":  g.rng.Float64() < 0.8,
+			"age_days": g.rng.Intn(2000),
+		}
+	}
+
+	ev := domain.NewEvent(g.uuid(), user, amount, cur.Code, merchant, ts, meta)
+	ev.Canary = g.opts.CanaryRatio > 0 && g.rng.Float64() < g.opts.CanaryRatio
+	payload, _ := ev.ToJSON()
+	if pad := g.pickSize() - len(payload); pad > 0 {
+		ev.Metadata["padding"] = strings.Repeat("x", pad)
+		payload, _ = ev.ToJSON()
+	}
+	return ev, payload
+}
+
+// uuid draws a v4 UUID from the seeded source so event IDs are reproducible.
+func (g *Generator) uuid() string {
+	var b [16]byte
+	g.rng.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return uuid.UUID(b).String()
+}
+
+func (g *Generator) pickCurrency() Currency {
+	r := g.rng.Float64() * g.currW
+	for _, c := range g.opts.Currencies {
+		if r < c.Weight {
+			return c
+		}
+		r -= c.Weight
+	}
+	return g.opts.Currencies[len(g.opts.Currencies)-1]
+}
+
+func (g *Generator) pickSize() int {
+	r := g.rng.Float64() * g.totalW
+	for _, b := range g.opts.Sizes {
+		if r < b.Weight {
+			return b.Bytes
+		}
+		r -= b.Weight
+	}
+	return g.opts.Sizes[len(g.opts.Sizes)-1].Bytes
+}
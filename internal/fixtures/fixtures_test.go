@@ -0,0 +1,106 @@
+package fixtures
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestParseSizeDist(t *testing.T) {
+	got, err := ParseSizeDist("0.9:512, 0.1:300000")
+	if err != nil {
+		t.Fatalf("ParseSizeDist: %v", err)
+	}
+	if len(got) != 2 || got[0].Bytes != 512 || got[1].Weight != 0.1 {
+		t.Errorf("ParseSizeDist = %+v", got)
+	}
+	for _, bad := range []string{"", "512", "x:512", "0.5:-1", "0:10"} {
+		if _, err := ParseSizeDist(bad); err == nil {
+			t.Errorf("ParseSizeDist(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestGenerator_Reproducible(t *testing.T) {
+	opts := Options{Seed: 42, Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a, b := New(opts), New(opts)
+	for i := 0; i < 50; i++ {
+		_, pa := a.Next()
+		_, pb := b.Next()
+		if !bytes.Equal(pa, pb) {
+			t.Fatalf("event %d differs for the same seed:\n%s\n%s", i, pa, pb)
+		}
+	}
+	_, other := New(Options{Seed: 43, Start: opts.Start}).Next()
+	_, first := New(opts).Next()
+	if bytes.Equal(first, other) {
+		t.Error("different seeds produced the same first event")
+	}
+}
+
+func TestGenerator_Distributions(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	gen := New(Options{Seed: 7, Start: start, Sizes: []SizeBucket{{Weight: 1, Bytes: 2048}}})
+
+	const n = 5000
+	var usd []float64
+	currencies := map[string]int{}
+	users := map[string]int{}
+	last := start
+	for i := 0; i < n; i++ {
+		ev, payload := gen.Next()
+		if err := ev.Validate(); err != nil {
+			t.Fatalf("event %d invalid: %v", i, err)
+		}
+		if len(payload) < 2048 {
+			t.Fatalf("event %d payload %d bytes, want >= 2048", i, len(payload))
+		}
+		if ev.Timestamp.Before(last) {
+			t.Fatalf("event %d timestamp went backwards", i)
+		}
+		last = ev.Timestamp
+		currencies[ev.Currency]++
+		users[ev.UserID]++
+		if ev.Currency == "USD" {
+			usd = append(usd, ev.Amount)
+		}
+	}
+
+	sort.Float64s(usd)
+	if median := usd[len(usd)/2]; median < 20 || median > 31 {
+		t.Errorf("USD median amount = %.2f, want ~25", median)
+	}
+	if p999 := usd[len(usd)*999/1000]; p999 < 500 {
+		t.Errorf("USD p99.9 amount = %.2f, want a heavy tail", p999)
+	}
+	if share := float64(currencies["USD"]) / n; share < 0.68 || share > 0.76 {
+		t.Errorf("USD share = %.3f, want ~0.72", share)
+	}
+	if currencies["JPY"] == 0 || currencies["XMR"] == 0 {
+		t.Errorf("currency mix missing minor entries: %v", currencies)
+	}
+	// Skew: the busiest user should far exceed the uniform expectation (n/1000 = 5).
+	var busiest int
+	for _, c := range users {
+		if c > busiest {
+			busiest = c
+		}
+	}
+	if busiest < 25 {
+		t.Errorf("busiest user has %d events, want a skewed distribution", busiest)
+	}
+}
+
+func TestGenerator_CanaryRatio(t *testing.T) {
+	gen := New(Options{Seed: 3, CanaryRatio: 0.25})
+	var canaries int
+	for i := 0; i < 2000; i++ {
+		if ev, _ := gen.Next(); ev.Canary {
+			canaries++
+		}
+	}
+	if canaries < 400 || canaries > 600 {
+		t.Errorf("%d canaries of 2000 at ratio 0.25", canaries)
+	}
+}
@@ -0,0 +1,272 @@
+package fluxatest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+var (
+	_ ports.Storage        = (*Storage)(nil)
+	_ ports.LimitedGetter  = (*Storage)(nil)
+	_ ports.BatchPublisher = (*Queue)(nil)
+	_ ports.Consumer       = (*Queue)(nil)
+	_ ports.Metrics        = (*Metrics)(nil)
+)
+
+// Storage is an in-memory ports.Storage. A missing key fails Get with a
+// *domain.NotFoundError, as the MinIO adapter does; GetErr overrides every Get
+// and PutErr every Put.
+type Storage struct {
+	GetErr error
+	PutErr error
+
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+// NewStorage returns an empty Storage.
+func NewStorage() *Storage {
+	return &Storage{objects: map[string][]byte{}}
+}
+
+func (s *Storage) Put(ctx context.Context, key string, data []byte) error {
+	if s.PutErr != nil {
+		return s.PutErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *Storage) Get(ctx context.Context, key string) ([]byte, error) {
+	if s.GetErr != nil {
+		return nil, s.GetErr
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, domain.NewNotFoundError("object", nil)
+	}
+	return data, nil
+}
+
+// GetLimited is Get, failing with a *domain.TooLargeError for an object over limit.
+func (s *Storage) GetLimited(ctx context.Context, key string, limit int64) ([]byte, error) {
+	data, err := s.Get(ctx, key)
+	if err == nil && int64(len(data)) > limit {
+		return nil, &domain.TooLargeError{Resource: "object", Size: int64(len(data)), Limit: limit}
+	}
+	return data, err
+}
+
+func (s *Storage) Exists(ctx context.Context, key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.objects[key]
+	return ok, nil
+}
+
+// Published is one message sent through Queue.Publish.
+type Published struct {
+	Exchange   string
+	RoutingKey string
+	Body       []byte
+}
+
+// Queue is an in-memory ports.BatchPublisher and ports.Consumer. Published
+// messages are recorded; Deliver feeds bodies to the channel returned by Consume
+// and records how each was settled.
+type Queue struct {
+	PublishErr error
+
+	mu         sync.Mutex
+	published  []Published
+	batches    []int
+	deliveries chan ports.Delivery
+	acked      int
+	nacked     int
+}
+
+// NewQueue returns a Queue whose Consume channel buffers up to 64 deliveries.
+func NewQueue() *Queue {
+	return &Queue{deliveries: make(chan ports.Delivery, 64)}
+}
+
+func (q *Queue) Publish(ctx context.Context, exchange, routingKey string, body []byte) error {
+	if q.PublishErr != nil {
+		return q.PublishErr
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.published = append(q.published, Published{exchange, routingKey, append([]byte(nil), body...)})
+	return nil
+}
+
+// PublishBatch records bodies like Publish and the batch's size (see Batches). It
+// rejects more than ports.MaxPublishBatch bodies, as a real adapter would.
+func (q *Queue) PublishBatch(ctx context.Context, exchange, routingKey string, bodies [][]byte) error {
+	if q.PublishErr != nil {
+		return q.PublishErr
+	}
+	if len(bodies) > ports.MaxPublishBatch {
+		return fmt.Errorf("fluxatest: %d bodies in one batch, max %d", len(bodies), ports.MaxPublishBatch)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, body := range bodies {
+		q.published = append(q.published, Published{exchange, routingKey, append([]byte(nil), body...)})
+	}
+	q.batches = append(q.batches, len(bodies))
+	return nil
+}
+
+// Batches returns the size of each PublishBatch call, in order.
+func (q *Queue) Batches() []int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]int(nil), q.batches...)
+}
+
+// Consume returns the delivery channel regardless of queue name.
+func (q *Queue) Consume(ctx context.Context, queue string) (<-chan ports.Delivery, error) {
+	return q.deliveries, nil
+}
+
+// Deliver enqueues body for consumers.
+func (q *Queue) Deliver(body []byte) {
+	q.deliveries <- &delivery{q: q, body: body}
+}
+
+// Close closes the delivery channel; call it at most once.
+func (q *Queue) Close() error {
+	close(q.deliveries)
+	return nil
+}
+
+// Published returns messages published to exchange ("" for all).
+func (q *Queue) Published(exchange string) []Published {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []Published
+	for _, p := range q.published {
+		if exchange == "" || p.Exchange == exchange {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Settled returns how many deliveries were acked and nacked.
+func (q *Queue) Settled() (acked, nacked int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.acked, q.nacked
+}
+
+type delivery struct {
+	q    *Queue
+	body []byte
+}
+
+func (d *delivery) Body() []byte { return d.body }
+
+func (d *delivery) Ack() error {
+	d.q.mu.Lock()
+	d.q.acked++
+	d.q.mu.Unlock()
+	return nil
+}
+
+func (d *delivery) Nack(requeue bool) error {
+	d.q.mu.Lock()
+	d.q.nacked++
+	d.q.mu.Unlock()
+	return nil
+}
+
+// Redelivered is always false: nacked deliveries are not delivered again.
+func (d *delivery) Redelivered() bool { return false }
+
+// Metrics is a ports.Metrics that records every call. Series are keyed by the
+// metric name followed by its label values, joined with "/":
+// "events_processed_total/processor/success".
+type Metrics struct {
+	mu         sync.Mutex
+	counters   map[string]int
+	histograms map[string][]float64
+	gauges     map[string]float64
+}
+
+// NewMetrics returns an empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{counters: map[string]int{}, histograms: map[string][]float64{}, gauges: map[string]float64{}}
+}
+
+func (m *Metrics) IncCounter(name string, labels ...string) {
+	mustBeCatalogued(name, metricdef.Counter)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[seriesKey(name, labels)]++
+}
+
+func (m *Metrics) ObserveHistogram(name string, value float64, labels ...string) {
+	mustBeCatalogued(name, metricdef.Histogram)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := seriesKey(name, labels)
+	m.histograms[key] = append(m.histograms[key], value)
+}
+
+func (m *Metrics) SetGauge(name string, value float64, labels ...string) {
+	mustBeCatalogued(name, metricdef.Gauge)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[seriesKey(name, labels)] = value
+}
+
+// Counter returns the count for name with the given label values, in call order.
+func (m *Metrics) Counter(name string, labelValues ...string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[strings.Join(append([]string{name}, labelValues...), "/")]
+}
+
+// Observations returns the values observed for name with the given label values.
+func (m *Metrics) Observations(name string, labelValues ...string) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]float64(nil), m.histograms[strings.Join(append([]string{name}, labelValues...), "/")]...)
+}
+
+// Gauge returns the last value set for name with the given label values, and
+// whether it was set at all.
+func (m *Metrics) Gauge(name string, labelValues ...string) (float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.gauges[strings.Join(append([]string{name}, labelValues...), "/")]
+	return v, ok
+}
+
+// mustBeCatalogued panics unless name is a metricdef metric of kind: the
+// Prometheus adapter drops anything else, so a test must not pass on it.
+func mustBeCatalogued(name string, kind metricdef.Kind) {
+	if d, ok := metricdef.Lookup(name); !ok || d.Kind != kind {
+		panic("fluxatest: metric " + name + " is not in the metricdef catalog as this kind")
+	}
+}
+
+func seriesKey(name string, labels []string) string {
+	parts := []string{name}
+	for i := 1; i < len(labels); i += 2 {
+		parts = append(parts, labels[i])
+	}
+	return strings.Join(parts, "/")
+}
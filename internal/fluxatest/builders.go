@@ -0,0 +1,130 @@
+package fluxatest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// EventBuilder builds a valid domain.Event with overridable fields.
+type EventBuilder struct {
+	event domain.Event
+}
+
+// NewEvent starts a valid USD event for eventID, timestamped a minute ago.
+func NewEvent(eventID string) *EventBuilder {
+	return &EventBuilder{event: domain.Event{
+		EventID:   eventID,
+		UserID:    "test-user",
+		Amount:    25.00,
+		Currency:  "USD",
+		Merchant:  "test-merchant",
+		Timestamp: time.Now().UTC().Add(-time.Minute).Truncate(time.Second),
+		Metadata:  map[string]interface{}{},
+	}}
+}
+
+func (b *EventBuilder) User(userID string) *EventBuilder       { b.event.UserID = userID; return b }
+func (b *EventBuilder) Amount(amount float64) *EventBuilder    { b.event.Amount = amount; return b }
+func (b *EventBuilder) Currency(currency string) *EventBuilder { b.event.Currency = currency; return b }
+func (b *EventBuilder) Merchant(merchant string) *EventBuilder { b.event.Merchant = merchant; return b }
+func (b *EventBuilder) At(ts time.Time) *EventBuilder          { b.event.Timestamp = ts; return b }
+func (b *EventBuilder) Canary() *EventBuilder                  { b.event.Canary = true; return b }
+func (b *EventBuilder) Ref(ref string) *EventBuilder           { b.event.ClientReference = ref; return b }
+func (b *EventBuilder) Schema(v string) *EventBuilder          { b.event.SchemaVersion = v; return b }
+func (b *EventBuilder) Group(groupID string, size int) *EventBuilder {
+	b.event.GroupID, b.event.GroupSize = groupID, size
+	return b
+}
+func (b *EventBuilder) Meta(key string, v interface{}) *EventBuilder {
+	b.event.Metadata[key] = v
+	return b
+}
+
+// Padded adds a metadata field so the encoded payload is at least size bytes.
+func (b *EventBuilder) Padded(size int) *EventBuilder {
+	return b.Meta("padding", strings.Repeat("x", size))
+}
+
+// Build returns a copy of the event.
+func (b *EventBuilder) Build() *domain.Event {
+	e := b.event
+	e.Metadata = make(map[string]interface{}, len(b.event.Metadata))
+	for k, v := range b.event.Metadata {
+		e.Metadata[k] = v
+	}
+	return &e
+}
+
+// Payload returns the event's JSON encoding, as a producer would post it.
+func (b *EventBuilder) Payload() []byte {
+	payload, err := b.Build().ToJSON()
+	if err != nil {
+		panic("fluxatest: encode event: " + err.Error())
+	}
+	return payload
+}
+
+// Envelope wraps payload in a queue message with its correct SHA-256. Payloads over
+// domain.MaxInlinePayloadBytes are written to storage under domain.PayloadKey and
+// referenced by key, exactly as ingest does; storage may be nil for inline payloads.
+func Envelope(eventID string, payload []byte, storage ports.Storage) *domain.QueueMessage {
+	if len(payload) > domain.MaxInlinePayloadBytes {
+		return S3Envelope(eventID, payload, storage)
+	}
+	return InlineEnvelope(eventID, payload)
+}
+
+// InlineEnvelope carries payload inline regardless of size.
+func InlineEnvelope(eventID string, payload []byte) *domain.QueueMessage {
+	msg := baseEnvelope(eventID, payload)
+	inline := string(payload)
+	msg.PayloadMode = domain.PayloadModeInline
+	msg.PayloadInline = &inline
+	return msg
+}
+
+// S3Envelope stores payload in storage and references it by content-addressed key.
+func S3Envelope(eventID string, payload []byte, storage ports.Storage) *domain.QueueMessage {
+	msg := baseEnvelope(eventID, payload)
+	key := domain.PayloadKey(msg.PayloadSHA256)
+	if err := storage.Put(context.Background(), key, payload); err != nil {
+		panic("fluxatest: store payload: " + err.Error())
+	}
+	msg.PayloadMode = domain.PayloadModeS3
+	msg.S3Key = &key
+	return msg
+}
+
+// BatchEnvelope wraps events in an atomic batch message, as POST /events/batch with
+// "atomic": true does. Like Envelope, large batches go to storage.
+func BatchEnvelope(batchID string, storage ports.Storage, events ...*domain.Event) *domain.QueueMessage {
+	payload := domain.BatchPayload{}
+	for _, e := range events {
+		payload.Events = append(payload.Events, *e)
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		panic("fluxatest: encode batch: " + err.Error())
+	}
+	msg := Envelope(domain.BatchIdempotencyKey(batchID), raw, storage)
+	msg.BatchID = batchID
+	msg.Atomic = true
+	return msg
+}
+
+func baseEnvelope(eventID string, payload []byte) *domain.QueueMessage {
+	sum := sha256.Sum256(payload)
+	return &domain.QueueMessage{
+		EventID:       eventID,
+		CorrelationID: "corr-" + eventID,
+		PayloadSHA256: hex.EncodeToString(sum[:]),
+		ReceivedAt:    time.Now().UTC(),
+	}
+}
@@ -0,0 +1,62 @@
+package fluxatest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+func TestEnvelope_HashAndMode(t *testing.T) {
+	storage := NewStorage()
+	for _, size := range []int{0, domain.MaxInlinePayloadBytes} {
+		payload := NewEvent("evt").Padded(size).Payload()
+		msg := Envelope("evt", payload, storage)
+
+		var body []byte
+		switch msg.PayloadMode {
+		case domain.PayloadModeInline:
+			body = []byte(*msg.PayloadInline)
+		case domain.PayloadModeS3:
+			var err error
+			if body, err = storage.Get(context.Background(), *msg.S3Key); err != nil {
+				t.Fatalf("size %d: Get: %v", size, err)
+			}
+		}
+		sum := sha256.Sum256(body)
+		if hex.EncodeToString(sum[:]) != msg.PayloadSHA256 {
+			t.Errorf("size %d: envelope hash does not match carried payload", size)
+		}
+		wantS3 := len(payload) > domain.MaxInlinePayloadBytes
+		if (msg.PayloadMode == domain.PayloadModeS3) != wantS3 {
+			t.Errorf("size %d: mode %q, want S3=%v", size, msg.PayloadMode, wantS3)
+		}
+	}
+}
+
+func TestStore_AggregatesSkipCanaries(t *testing.T) {
+	s := NewStore()
+	asOf := time.Now().UTC()
+	for _, e := range []*domain.Event{
+		NewEvent("a").User("u").Amount(10).At(asOf.Add(-2 * time.Minute)).Build(),
+		NewEvent("b").User("u").Amount(30).At(asOf.Add(-time.Minute)).Build(),
+		NewEvent("c").User("u").Amount(999).At(asOf.Add(-30 * time.Second)).Canary().Build(),
+		NewEvent("d").User("u").Amount(5).At(asOf.Add(-time.Hour)).Build(),
+	} {
+		if err := s.InsertEvent(e, "corr", domain.PayloadModeInline, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, _ := s.CountUserEventsAsOf("u", asOf, 600)
+	sum, max, prev, _ := s.UserAmountStatsAsOf("u", asOf, 600)
+	if n != 2 || sum != 40 || max != 30 || !prev.Equal(asOf.Add(-time.Minute)) {
+		t.Errorf("as-of aggregates = n=%d sum=%v max=%v prev=%v", n, sum, max, prev)
+	}
+	if recent, _ := s.CountRecentEvents("u", 60); recent != 3 {
+		t.Errorf("CountRecentEvents = %d, want 3 (all non-canary inserts are recent)", recent)
+	}
+}
@@ -0,0 +1,530 @@
+// Package fluxatest provides in-memory fakes of the processor's collaborators and
+// builders for valid events and queue envelopes, so processor behavior can be
+// tested without Postgres, RabbitMQ, or MinIO.
+//
+// The fakes mirror the semantics the processor relies on (insert-once events,
+// canary exclusion from aggregates, idempotency claim/settle) but none of the SQL.
+// Behavior that only exists in SQL — the merchant roll-up, payload_refs — still
+// needs the integration tests in internal/db.
+package fluxatest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// StoredEvent is an event as Store recorded it.
+type StoredEvent struct {
+	Event         domain.Event
+	CorrelationID string
+	PayloadMode   domain.PayloadMode
+	S3Key         *string
+	CreatedAt     time.Time
+}
+
+// Store is an in-memory processor.Store. InsertEvent is insert-once per event ID
+// (like ON CONFLICT DO NOTHING), a client reference is unique per producer, and
+// the aggregate queries skip canary events.
+// Set the *Err fields to make the corresponding calls fail.
+type Store struct {
+	InsertEventErr error
+	InsertFlagErr  error
+	QueryErr       error
+	// RejectEvents are event IDs InsertPartialBatch rejects as Postgres would a
+	// bad row.
+	RejectEvents map[string]bool
+
+	mu      sync.Mutex
+	events  map[string]*StoredEvent
+	order   []string
+	flags   []domain.FraudFlag
+	batches map[string]domain.Batch
+	members map[string]domain.IdempotencyStatus // batchID + "/" + eventID
+	groups  map[string]domain.EventGroup
+	times   map[string]domain.EventTimeline
+	now     func() time.Time
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{events: map[string]*StoredEvent{}, batches: map[string]domain.Batch{}, members: map[string]domain.IdempotencyStatus{}, groups: map[string]domain.EventGroup{}, times: map[string]domain.EventTimeline{}, now: time.Now}
+}
+
+// InsertEvent records event unless its ID is already stored.
+func (s *Store) InsertEvent(event *domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) error {
+	if s.InsertEventErr != nil {
+		return s.InsertEventErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.conflictLocked(event); err != nil {
+		return err
+	}
+	s.insertLocked(event, correlationID, payloadMode, s3Key)
+	return nil
+}
+
+// InsertEventBatch records all events and the batch, or (with InsertEventErr set)
+// nothing.
+func (s *Store) InsertEventBatch(batch *domain.Batch, events []*domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) error {
+	if s.InsertEventErr != nil {
+		return s.InsertEventErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range events {
+		if err := s.conflictLocked(event); err != nil {
+			return err
+		}
+	}
+	for _, event := range events {
+		s.insertLocked(event, correlationID, payloadMode, s3Key)
+	}
+	s.saveBatchLocked(batch)
+	return nil
+}
+
+// InsertPartialBatch records the events not in RejectEvents and the batch, with
+// the rejected ones added to batch.Failures as "db_rejected"; like the SQL it
+// sets the batch's counts and status.
+func (s *Store) InsertPartialBatch(batch *domain.Batch, events []*domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) error {
+	if s.InsertEventErr != nil {
+		return s.InsertEventErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failures := append([]domain.BatchFailure(nil), batch.Failures...)
+	for _, event := range events {
+		if s.RejectEvents[event.EventID] {
+			failures = append(failures, domain.BatchFailure{EventID: event.EventID, Reason: "db_rejected"})
+			continue
+		}
+		if s.conflictLocked(event) != nil {
+			failures = append(failures, domain.BatchFailure{EventID: event.EventID, Reason: "client_reference_conflict"})
+			continue
+		}
+		s.insertLocked(event, correlationID, payloadMode, s3Key)
+	}
+	batch.Failures = failures
+	batch.Failed = len(failures)
+	batch.Processed = batch.Submitted - batch.Failed
+	switch {
+	case batch.Failed == 0:
+		batch.Status = domain.BatchStatusSuccess
+	case batch.Failed >= batch.Submitted:
+		batch.Status = domain.BatchStatusFailed
+	default:
+		batch.Status = domain.BatchStatusComplete
+	}
+	s.saveBatchLocked(batch)
+	return nil
+}
+
+// SaveBatch records batch, replacing any earlier record for its ID.
+func (s *Store) SaveBatch(batch *domain.Batch) error {
+	if s.InsertEventErr != nil {
+		return s.InsertEventErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveBatchLocked(batch)
+	return nil
+}
+
+// RecordBatchMember counts eventID's terminal status towards batchID, creating the
+// batch with submitted members on first use; a member is counted once per status
+// change, as in the SQL.
+func (s *Store) RecordBatchMember(batchID, eventID string, submitted int, status domain.IdempotencyStatus) error {
+	if s.InsertEventErr != nil {
+		return s.InsertEventErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.batches[batchID]
+	if !ok {
+		b = domain.Batch{BatchID: batchID, Status: domain.BatchStatusProcessing, Submitted: submitted, CreatedAt: s.now().UTC()}
+	}
+	key := batchID + "/" + eventID
+	prev := s.members[key]
+	if prev == status {
+		return nil
+	}
+	s.members[key] = status
+	for st, n := range map[domain.IdempotencyStatus]*int{domain.IdempotencyStatusSuccess: &b.Processed, domain.IdempotencyStatusFailed: &b.Failed} {
+		if status == st {
+			*n++
+		}
+		if prev == st {
+			*n--
+		}
+	}
+	b.Status = domain.BatchStatusProcessing
+	if b.Drained() {
+		b.Status = domain.BatchStatusComplete
+	}
+	b.UpdatedAt = s.now().UTC()
+	s.batches[batchID] = b
+	return nil
+}
+
+// RecordGroupMember recounts groupID's persisted events, creating the group with
+// expected members on first use, and reports whether this call completed it, as
+// in the SQL.
+func (s *Store) RecordGroupMember(groupID string, expected int) (*domain.EventGroup, bool, error) {
+	if s.InsertEventErr != nil {
+		return nil, false, s.InsertEventErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().UTC()
+	g, ok := s.groups[groupID]
+	if !ok {
+		g = domain.EventGroup{GroupID: groupID, Expected: expected, CreatedAt: now}
+	}
+	g.Persisted = 0
+	for _, stored := range s.events {
+		if stored.Event.GroupID == groupID {
+			g.Persisted++
+		}
+	}
+	g.UpdatedAt = now
+	completed := !g.Complete && g.Persisted >= g.Expected
+	if completed {
+		g.Complete, g.CompletedAt = true, &now
+	}
+	s.groups[groupID] = g
+	return &g, completed, nil
+}
+
+// Group returns groupID's progress, or nil if no member was recorded.
+func (s *Store) Group(groupID string) *domain.EventGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.groups[groupID]
+	if !ok {
+		return nil
+	}
+	return &g
+}
+
+// RecordEventTimelines stores each timeline unless its event already has one.
+func (s *Store) RecordEventTimelines(timelines []domain.EventTimeline) error {
+	if s.InsertEventErr != nil {
+		return s.InsertEventErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range timelines {
+		if _, ok := s.times[t.EventID]; !ok {
+			s.times[t.EventID] = t
+		}
+	}
+	return nil
+}
+
+// Timeline returns the recorded timeline of eventID, or nil.
+func (s *Store) Timeline(eventID string) *domain.EventTimeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.times[eventID]; ok {
+		return &t
+	}
+	return nil
+}
+
+// Batch returns the recorded batch with batchID, or nil.
+func (s *Store) Batch(batchID string) *domain.Batch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.batches[batchID]; ok {
+		return &b
+	}
+	return nil
+}
+
+func (s *Store) saveBatchLocked(batch *domain.Batch) {
+	now := s.now().UTC()
+	batch.CreatedAt, batch.UpdatedAt = now, now
+	if prev, ok := s.batches[batch.BatchID]; ok {
+		batch.CreatedAt = prev.CreatedAt
+	}
+	s.batches[batch.BatchID] = *batch
+}
+
+// conflictLocked returns the *domain.ClientReferenceConflictError inserting event
+// would fail with, or nil.
+func (s *Store) conflictLocked(event *domain.Event) error {
+	if event.ClientReference == "" {
+		return nil
+	}
+	for id, stored := range s.events {
+		if id != event.EventID && stored.Event.ProducerKey == event.ProducerKey && stored.Event.ClientReference == event.ClientReference {
+			return &domain.ClientReferenceConflictError{EventID: event.EventID, ClientReference: event.ClientReference, ExistingEventID: id}
+		}
+	}
+	return nil
+}
+
+func (s *Store) insertLocked(event *domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) {
+	if _, ok := s.events[event.EventID]; ok {
+		return
+	}
+	s.events[event.EventID] = &StoredEvent{
+		Event:         *event,
+		CorrelationID: correlationID,
+		PayloadMode:   payloadMode,
+		S3Key:         s3Key,
+		CreatedAt:     s.now().UTC(),
+	}
+	s.order = append(s.order, event.EventID)
+}
+
+// InsertFraudFlag records flag; a repeated FlagID is ignored.
+func (s *Store) InsertFraudFlag(flag *domain.FraudFlag) error {
+	if s.InsertFlagErr != nil {
+		return s.InsertFlagErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.flags {
+		if f.FlagID == flag.FlagID {
+			return nil
+		}
+	}
+	s.flags = append(s.flags, *flag)
+	return nil
+}
+
+// Event returns the stored event with eventID, or nil.
+func (s *Store) Event(eventID string) *StoredEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.events[eventID]; ok {
+		cp := *e
+		return &cp
+	}
+	return nil
+}
+
+// EventPersisted reports whether eventID is stored.
+func (s *Store) EventPersisted(eventID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.events[eventID]
+	return ok, nil
+}
+
+// ListFraudFlags returns the flags raised for eventID in insertion order, with
+// Canary copied from the event.
+func (s *Store) ListFraudFlags(eventID string) ([]domain.FraudFlag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flags := []domain.FraudFlag{}
+	for _, f := range s.flags {
+		if f.EventID == eventID {
+			if e, ok := s.events[eventID]; ok {
+				f.Canary = e.Event.Canary
+			}
+			flags = append(flags, f)
+		}
+	}
+	return flags, nil
+}
+
+// EventCount returns the number of stored events.
+func (s *Store) EventCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+// Flags returns the stored fraud flags in insertion order.
+func (s *Store) Flags() []domain.FraudFlag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]domain.FraudFlag(nil), s.flags...)
+}
+
+// CountRecentEvents counts the user's non-canary events stored within the last
+// windowSeconds (by insert time, like the created_at query).
+func (s *Store) CountRecentEvents(userID string, windowSeconds int) (int, error) {
+	if s.QueryErr != nil {
+		return 0, s.QueryErr
+	}
+	since := s.now().UTC().Add(-time.Duration(windowSeconds) * time.Second)
+	n := 0
+	s.each(userID, func(e *StoredEvent) {
+		if !e.CreatedAt.Before(since) {
+			n++
+		}
+	})
+	return n, nil
+}
+
+// CountUserEventsAsOf counts the user's non-canary events with ts in (asOf-window, asOf].
+func (s *Store) CountUserEventsAsOf(userID string, asOf time.Time, windowSeconds int) (int, error) {
+	if s.QueryErr != nil {
+		return 0, s.QueryErr
+	}
+	n := 0
+	s.each(userID, func(e *StoredEvent) {
+		if inWindow(e.Event.Timestamp, asOf, windowSeconds) {
+			n++
+		}
+	})
+	return n, nil
+}
+
+// UserAmountStatsAsOf mirrors db.Client.UserAmountStatsAsOf over the stored events.
+func (s *Store) UserAmountStatsAsOf(userID string, asOf time.Time, windowSeconds int) (sum, max float64, prevTs time.Time, err error) {
+	if s.QueryErr != nil {
+		return 0, 0, time.Time{}, s.QueryErr
+	}
+	s.each(userID, func(e *StoredEvent) {
+		ts := e.Event.Timestamp
+		if inWindow(ts, asOf, windowSeconds) {
+			sum += e.Event.Amount
+			if e.Event.Amount > max {
+				max = e.Event.Amount
+			}
+		}
+		if ts.Before(asOf) && ts.After(prevTs) {
+			prevTs = ts
+		}
+	})
+	return sum, max, prevTs, nil
+}
+
+// each calls fn for every non-canary event of userID, in insertion order.
+func (s *Store) each(userID string, fn func(*StoredEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range s.order {
+		e := s.events[id]
+		if e.Event.UserID == userID && !e.Event.Canary {
+			fn(e)
+		}
+	}
+}
+
+func inWindow(ts, asOf time.Time, windowSeconds int) bool {
+	return !ts.After(asOf) && ts.After(asOf.Add(-time.Duration(windowSeconds)*time.Second))
+}
+
+// Idempotency is an in-memory processor.IdempotencyStore. Unlike the Postgres
+// client there is no stale-lock takeover: a key left in 'processing' stays claimed.
+type Idempotency struct {
+	// CheckErr, when set, fails every CheckAndMark.
+	CheckErr error
+
+	mu      sync.Mutex
+	records map[string]*domain.IdempotencyKeyRecord
+	failed  map[string][]byte
+}
+
+// NewIdempotency returns an Idempotency with no claimed keys.
+func NewIdempotency() *Idempotency {
+	return &Idempotency{records: map[string]*domain.IdempotencyKeyRecord{}, failed: map[string][]byte{}}
+}
+
+// CheckAndMark claims eventID; it reports true if the event already succeeded or
+// is being processed, and reclaims failed events for retry.
+func (i *Idempotency) CheckAndMark(eventID string) (bool, error) {
+	done, _, err := i.CheckAndMarkAttempt(eventID)
+	return done, err
+}
+
+// CheckAndMarkAttempt is CheckAndMark that also returns the attempt number of a
+// claim, or 0 when the event was not claimed.
+func (i *Idempotency) CheckAndMarkAttempt(eventID string) (bool, int, error) {
+	if i.CheckErr != nil {
+		return false, 0, i.CheckErr
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := time.Now().UTC()
+	rec, ok := i.records[eventID]
+	if !ok {
+		i.records[eventID] = &domain.IdempotencyKeyRecord{
+			EventID:     eventID,
+			Status:      string(domain.IdempotencyStatusProcessing),
+			FirstSeenAt: now,
+			LastSeenAt:  now,
+			Attempts:    1,
+		}
+		return false, 1, nil
+	}
+	if rec.Status != string(domain.IdempotencyStatusFailed) {
+		return true, 0, nil
+	}
+	rec.Status = string(domain.IdempotencyStatusProcessing)
+	rec.LastSeenAt = now
+	rec.Attempts++
+	return false, rec.Attempts, nil
+}
+
+// Release does nothing: like the Postgres client's row locks, an unsettled claim
+// stays 'processing'.
+func (i *Idempotency) Release(eventID string) {}
+
+// MarkSuccess settles a claimed eventID as succeeded, dropping any kept message.
+func (i *Idempotency) MarkSuccess(eventID string) error {
+	i.mu.Lock()
+	delete(i.failed, eventID)
+	i.mu.Unlock()
+	return i.settle(eventID, domain.IdempotencyStatusSuccess, nil)
+}
+
+// MarkFailed settles a claimed eventID as failed with errorReason.
+func (i *Idempotency) MarkFailed(eventID, errorReason string) error {
+	return i.settle(eventID, domain.IdempotencyStatusFailed, &errorReason)
+}
+
+// MarkFailedWithMessage is MarkFailed keeping message, returned by FailedMessage.
+func (i *Idempotency) MarkFailedWithMessage(eventID, errorReason string, message []byte) error {
+	if err := i.settle(eventID, domain.IdempotencyStatusFailed, &errorReason); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.records[eventID]; ok {
+		i.failed[eventID] = message
+	}
+	return nil
+}
+
+// FailedMessage returns the message kept for eventID's last failure, or nil.
+func (i *Idempotency) FailedMessage(eventID string) []byte {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.failed[eventID]
+}
+
+func (i *Idempotency) settle(eventID string, status domain.IdempotencyStatus, reason *string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	rec, ok := i.records[eventID]
+	if !ok {
+		// The SQL UPDATE matches no row; mirror its silence.
+		return nil
+	}
+	rec.Status = string(status)
+	rec.LastSeenAt = time.Now().UTC()
+	rec.ErrorReason = reason
+	return nil
+}
+
+// Status returns a copy of eventID's record, or an error if it was never claimed.
+func (i *Idempotency) Status(eventID string) (*domain.IdempotencyKeyRecord, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	rec, ok := i.records[eventID]
+	if !ok {
+		return nil, errors.New("fluxatest: idempotency key not found: " + eventID)
+	}
+	cp := *rec
+	return &cp, nil
+}
@@ -51,6 +51,11 @@ func NewEngine(rulesFilePath string, logger *logging.Logger) (*Engine, error) {
 		return nil, fmt.Errorf("fraud: parse rules file %q: %w", rulesFilePath, err)
 	}
 
+	return NewEngineFromRules(rules, logger), nil
+}
+
+// NewEngineFromRules returns an Engine for already-parsed rules (tests, embedders).
+func NewEngineFromRules(rules domain.RulesConfig, logger *logging.Logger) *Engine {
 	logger.Info("Loaded fraud rules", map[string]interface{}{
 		"amount_threshold":        rules.AmountThreshold,
 		"velocity_window_seconds": rules.VelocityWindowSeconds,
@@ -59,7 +64,7 @@ func NewEngine(rulesFilePath string, logger *logging.Logger) (*Engine, error) {
 		"high_risk_currencies":    len(rules.HighRiskCurrencies),
 	})
 
-	return &Engine{rules: rules, logger: logger}, nil
+	return &Engine{rules: rules, logger: logger}
 }
 
 // Evaluate runs all rules against event. All matching rules produce flags (not first-match).
@@ -7,9 +7,13 @@ import (
 
 	"github.com/fluxa/fluxa/internal/db"
 	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/dynconfig"
+	"github.com/fluxa/fluxa/internal/eventcodec"
+	"github.com/fluxa/fluxa/internal/featureflags"
 	"github.com/fluxa/fluxa/internal/fraud"
 	fraudv1 "github.com/fluxa/fluxa/internal/grpc/fraud/v1"
 	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
 	"github.com/fluxa/fluxa/internal/ports"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -28,6 +32,12 @@ type Server struct {
 	Version string
 	// Scorer is the optional ML scorer; nil => rules-only (fail-open). Set by main after NewServer.
 	Scorer fraud.Scorer
+	// Validation holds the transaction-time tolerances; the zero value is the default drift.
+	Validation domain.ValidationConfig
+	// Flags adjusts Validation per request; nil means the flag defaults.
+	Flags *featureflags.Flags
+	// Tunables overrides Validation's limits from AppConfig; nil means none.
+	Tunables *dynconfig.Store
 }
 
 func NewServer(engine *fraud.Engine, dbClient *db.Client, metrics ports.Metrics, logger *logging.Logger, version string) *Server {
@@ -50,8 +60,8 @@ func (s *Server) EvaluateTransaction(ctx context.Context, req *fraudv1.EvaluateR
 		return nil, status.Error(codes.InvalidArgument, "transaction_time is required")
 	}
 
-	event := protoToEvent(req)
-	if err := event.Validate(); err != nil {
+	event := eventcodec.FromProto(req)
+	if err := event.ValidateWith(s.Flags.Validation(s.Tunables.Validation(s.Validation)), time.Now()); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
@@ -82,12 +92,12 @@ func (s *Server) EvaluateTransaction(ctx context.Context, req *fraudv1.EvaluateR
 			})
 			continue
 		}
-		s.Metrics.IncCounter("fraud_flags_grpc_total", "rule", flag.RuleName)
+		s.Metrics.IncCounter(metricdef.FraudFlagsGRPCTotal, "rule", flag.RuleName)
 	}
 
 	latency := time.Since(start)
 	latencyMs := float64(latency.Microseconds()) / 1000.0
-	s.Metrics.ObserveHistogram("fraud_eval_latency_seconds", latency.Seconds(), "service", ServiceName)
+	s.Metrics.ObserveHistogram(metricdef.FraudEvalLatencySeconds, latency.Seconds(), "service", ServiceName)
 
 	evaluatedBy := s.Version
 	if modelVersion != "" && modelVersion != "unavailable" {
@@ -103,26 +113,6 @@ func (s *Server) EvaluateTransaction(ctx context.Context, req *fraudv1.EvaluateR
 	}, nil
 }
 
-func protoToEvent(req *fraudv1.EvaluateRequest) domain.Event {
-	md := req.GetMetadata()
-	var metadata map[string]interface{}
-	if len(md) > 0 {
-		metadata = make(map[string]interface{}, len(md))
-		for k, v := range md {
-			metadata[k] = v
-		}
-	}
-	return domain.Event{
-		EventID:   req.GetEventId(),
-		UserID:    req.GetUserId(),
-		Amount:    req.GetAmount(),
-		Currency:  req.GetCurrency(),
-		Merchant:  req.GetMerchant(),
-		Timestamp: req.GetTransactionTime().AsTime(),
-		Metadata:  metadata,
-	}
-}
-
 func toProtoFlags(flags []domain.FraudFlag) []*fraudv1.FraudFlag {
 	if len(flags) == 0 {
 		return nil
@@ -312,32 +312,3 @@ func TestEvaluateTransaction_LatencyAndEvaluatedBy(t *testing.T) {
 		t.Errorf("evaluated_by = %q, want fluxa-rules-v1.0", resp.GetEvaluatedBy())
 	}
 }
-
-func TestProtoToEventConversion(t *testing.T) {
-	now := time.Now().UTC().Truncate(time.Second)
-	req := &fraudv1.EvaluateRequest{
-		EventId:         "e1",
-		UserId:          "u1",
-		Amount:          12.5,
-		Currency:        "USD",
-		Merchant:        "acme",
-		TransactionTime: timestamppb.New(now),
-		Metadata:        map[string]string{"channel": "mobile"},
-	}
-	got := protoToEvent(req)
-	if got.EventID != "e1" || got.UserID != "u1" || got.Amount != 12.5 || got.Currency != "USD" || got.Merchant != "acme" {
-		t.Errorf("scalar fields wrong: %+v", got)
-	}
-	if !got.Timestamp.Equal(now) {
-		t.Errorf("timestamp = %v, want %v", got.Timestamp, now)
-	}
-	if v, ok := got.Metadata["channel"]; !ok || v != "mobile" {
-		t.Errorf("metadata channel = %v ok=%v, want mobile/true", v, ok)
-	}
-
-	req2 := &fraudv1.EvaluateRequest{EventId: "e2", TransactionTime: timestamppb.Now()}
-	got2 := protoToEvent(req2)
-	if got2.Currency != "" || got2.Metadata != nil {
-		t.Errorf("empty req → got %+v", got2)
-	}
-}
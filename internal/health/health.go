@@ -0,0 +1,164 @@
+// Package health serves the liveness and readiness probes of the long-running
+// services, for Kubernetes/ECS. /healthz answers 200 while the process is up and
+// checks nothing, so a dependency outage never gets a healthy pod restarted.
+// /readyz runs every registered dependency check (database pool, queue
+// connection) and answers 503 if one fails or the service is draining for
+// shutdown, so the orchestrator stops routing work to it.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Check reports whether one dependency is usable; it should honour ctx.
+type Check func(ctx context.Context) error
+
+// checkTimeout bounds each readiness check, well inside a typical probe timeout.
+const checkTimeout = 2 * time.Second
+
+// Probes holds a service's readiness checks and drain state.
+type Probes struct {
+	mu       sync.RWMutex
+	names    []string
+	checks   map[string]Check
+	draining atomic.Bool
+}
+
+// New returns Probes with no checks: ready until Drain is called.
+func New() *Probes {
+	return &Probes{checks: map[string]Check{}}
+}
+
+// Add registers a readiness check under name, replacing any with the same name.
+func (p *Probes) Add(name string, check Check) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.checks[name]; !ok {
+		p.names = append(p.names, name)
+	}
+	p.checks[name] = check
+}
+
+// Drain marks the service as shutting down: /readyz fails from now on while
+// in-flight work finishes. It cannot be undone.
+func (p *Probes) Drain() {
+	p.draining.Store(true)
+}
+
+// Draining reports whether Drain has been called.
+func (p *Probes) Draining() bool {
+	return p.draining.Load()
+}
+
+// Register mounts /healthz and /readyz on mux.
+func (p *Probes) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", p.handleHealthz)
+	mux.HandleFunc("/readyz", p.handleReadyz)
+}
+
+func (p *Probes) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness is the /readyz body: each check's "ok" or error message.
+type Readiness struct {
+	Status   string            `json:"status"` // "ready" or "not_ready"
+	Draining bool              `json:"draining,omitempty"`
+	Checks   map[string]string `json:"checks,omitempty"`
+}
+
+func (p *Probes) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	res := p.Ready(r.Context())
+	status := http.StatusOK
+	if res.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, res)
+}
+
+// Ready runs every check concurrently, each bounded by checkTimeout.
+func (p *Probes) Ready(ctx context.Context) Readiness {
+	p.mu.RLock()
+	names := append([]string(nil), p.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = p.checks[name]
+	}
+	p.mu.RUnlock()
+
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			results[i] = check(cctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	res := Readiness{Status: "ready", Draining: p.Draining()}
+	if res.Draining {
+		res.Status = "not_ready"
+	}
+	if len(names) > 0 {
+		res.Checks = make(map[string]string, len(names))
+	}
+	for i, name := range names {
+		if results[i] != nil {
+			res.Checks[name] = results[i].Error()
+			res.Status = "not_ready"
+		} else {
+			res.Checks[name] = "ok"
+		}
+	}
+	return res
+}
+
+// OnShutdown waits in the background for SIGINT or SIGTERM, then calls Drain,
+// waits drainDelay so the orchestrator sees /readyz fail and stops sending work,
+// and calls stop.
+func (p *Probes) OnShutdown(drainDelay time.Duration, stop func()) {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		cancel()
+		p.Drain()
+		time.Sleep(drainDelay)
+		stop()
+	}()
+}
+
+// ListenAndServe runs srv until SIGINT or SIGTERM, then drains (see OnShutdown)
+// and shuts srv down, giving in-flight requests up to shutdownTimeout. It returns
+// once they have finished, or the error that stopped srv.
+func (p *Probes) ListenAndServe(srv *http.Server, drainDelay, shutdownTimeout time.Duration) error {
+	stopped := make(chan error, 1)
+	p.OnShutdown(drainDelay, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		stopped <- srv.Shutdown(ctx)
+	})
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-stopped
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func probe(t *testing.T, p *Probes, path string) (int, Readiness) {
+	t.Helper()
+	mux := http.NewServeMux()
+	p.Register(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var body Readiness
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s body %q: %v", path, rec.Body.String(), err)
+	}
+	return rec.Code, body
+}
+
+func TestReadyz(t *testing.T) {
+	p := New()
+	var queueErr error
+	p.Add("db", func(ctx context.Context) error { return nil })
+	p.Add("queue", func(ctx context.Context) error { return queueErr })
+
+	if code, body := probe(t, p, "/readyz"); code != http.StatusOK || body.Status != "ready" || body.Checks["queue"] != "ok" {
+		t.Errorf("/readyz = %d %+v, want ready", code, body)
+	}
+
+	queueErr = errors.New("connection closed")
+	if code, body := probe(t, p, "/readyz"); code != http.StatusServiceUnavailable || body.Checks["queue"] != "connection closed" || body.Checks["db"] != "ok" {
+		t.Errorf("/readyz = %d %+v, want not_ready on the queue", code, body)
+	}
+
+	queueErr = nil
+	p.Drain()
+	if code, body := probe(t, p, "/readyz"); code != http.StatusServiceUnavailable || !body.Draining {
+		t.Errorf("/readyz while draining = %d %+v, want 503", code, body)
+	}
+	if code, _ := probe(t, p, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz while draining = %d, want 200", code)
+	}
+}
+
+func TestReadyz_CheckHonoursTimeout(t *testing.T) {
+	p := New()
+	p.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if res := p.Ready(context.Background()); res.Status != "not_ready" || res.Checks["slow"] != context.DeadlineExceeded.Error() {
+		t.Errorf("Ready = %+v, want the slow check timed out", res)
+	}
+}
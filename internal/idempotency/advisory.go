@@ -0,0 +1,168 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// Lock modes (IDEMPOTENCY_LOCK_MODE): how a claimed key is kept from other
+// deliveries while it is processed.
+const (
+	// LockModeRow treats a 'processing' row as locked until it goes unrefreshed
+	// for staleLockAfter, when another delivery may take it over.
+	LockModeRow = "row"
+	// LockModeAdvisory holds a Postgres advisory lock on the event ID for the
+	// processing window. The lock goes with its connection, so a crashed
+	// processor releases it at once and no staleness guess is needed.
+	LockModeAdvisory = "advisory"
+)
+
+// ErrLockCollision is returned by an advisory claim whose lock key is held for
+// another event ID: the event isn't in flight, so the caller should retry it
+// (requeue) rather than drop it as a duplicate.
+var ErrLockCollision = errors.New("idempotency: advisory lock key held for another event")
+
+// heldLock is a claim's advisory lock and the connection holding it.
+type heldLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// WithAdvisoryLocks switches c to LockModeAdvisory. Each claim then pins a pool
+// connection until it is settled or released, so the pool must be larger than
+// the number of events processed at once. Returns c for chaining.
+func (c *Client) WithAdvisoryLocks() *Client {
+	c.held = map[string]heldLock{}
+	return c
+}
+
+// lockKey maps eventID onto the advisory lock space. Two event IDs may share a
+// key; claimAdvisory tells a held key apart from an event in flight by its row.
+func lockKey(eventID string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(eventID))
+	return int64(h.Sum64())
+}
+
+// claimAdvisory claims eventID under an advisory lock. When another session holds
+// the key, eventID is in flight if its row is 'processing' and a duplicate if it
+// succeeded; otherwise the key is held for another event ID, or for this one
+// before its row is written, and the claim fails with ErrLockCollision so the
+// delivery is retried. Once the lock is taken any unsucceeded row is reclaimed,
+// however recently it was seen: its last holder is gone.
+func (c *Client) claimAdvisory(ctx context.Context, eventID string) (bool, int, error) {
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to get connection for advisory lock: %w", err)
+	}
+	key := lockKey(eventID)
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&locked); err != nil {
+		discard(conn)
+		return false, 0, fmt.Errorf("failed to take advisory lock: %w", err)
+	}
+	if !locked {
+		var status sql.NullString
+		err := conn.QueryRowContext(ctx, `SELECT status FROM idempotency_keys WHERE event_id = $1`, eventID).Scan(&status)
+		_ = conn.Close()
+		if err != nil && err != sql.ErrNoRows {
+			return false, 0, fmt.Errorf("failed to check idempotency key: %w", err)
+		}
+		switch status.String {
+		case string(domain.IdempotencyStatusProcessing):
+			c.recordOutcome(OutcomeInFlight)
+			return true, 0, nil
+		case string(domain.IdempotencyStatusSuccess):
+			c.recordOutcome(OutcomeDuplicate)
+			return true, 0, nil
+		}
+		c.recordOutcome(OutcomeLockCollision)
+		return false, 0, ErrLockCollision
+	}
+
+	var attempts sql.NullInt64
+	var prevStatus sql.NullString
+	err = conn.QueryRowContext(ctx, `
+		WITH prev AS (
+			SELECT status FROM idempotency_keys WHERE event_id = $1
+		), claim AS (
+			INSERT INTO idempotency_keys (event_id, status, first_seen_at, last_seen_at, attempts)
+			VALUES ($1, $2, $3, $3, 1)
+			ON CONFLICT (event_id) DO UPDATE
+			SET status = EXCLUDED.status, last_seen_at = EXCLUDED.last_seen_at,
+			    attempts = idempotency_keys.attempts + 1
+			WHERE idempotency_keys.status <> $4
+			RETURNING attempts
+		)
+		SELECT (SELECT attempts FROM claim), (SELECT status FROM prev)
+	`, eventID, string(domain.IdempotencyStatusProcessing), time.Now().UTC(),
+		string(domain.IdempotencyStatusSuccess)).Scan(&attempts, &prevStatus)
+	if err != nil {
+		unlock(conn, key)
+		return false, 0, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if !attempts.Valid {
+		unlock(conn, key)
+		c.recordOutcome(OutcomeDuplicate)
+		return true, 0, nil
+	}
+
+	c.mu.Lock()
+	c.held[eventID] = heldLock{conn: conn, key: key}
+	c.mu.Unlock()
+	switch prevStatus.String {
+	case "":
+		c.recordOutcome(OutcomeNew)
+	case string(domain.IdempotencyStatusProcessing):
+		c.recordOutcome(OutcomeStaleTakeover)
+	default:
+		c.recordOutcome(OutcomeRetry)
+	}
+	c.recordAttempts(int(attempts.Int64))
+	return false, int(attempts.Int64), nil
+}
+
+// Release gives up the claim on eventID without settling it, after a transient
+// failure: the row stays 'processing' and the next delivery reclaims it. With
+// advisory locks that is immediate; in LockModeRow Release does nothing and the
+// next delivery waits out staleLockAfter as before. MarkSuccess and MarkFailed
+// release the claim themselves.
+func (c *Client) Release(eventID string) {
+	if c.held == nil {
+		return
+	}
+	c.mu.Lock()
+	h, ok := c.held[eventID]
+	delete(c.held, eventID)
+	c.mu.Unlock()
+	if ok {
+		unlock(h.conn, h.key)
+	}
+}
+
+// unlock releases key and returns conn to the pool. A connection that can't
+// confirm the unlock is discarded instead, which ends its session and so drops
+// the lock anyway.
+func unlock(conn *sql.Conn, key int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var released bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_advisory_unlock($1)`, key).Scan(&released); err != nil || !released {
+		discard(conn)
+		return
+	}
+	_ = conn.Close()
+}
+
+// discard closes conn's underlying session rather than pooling it.
+func discard(conn *sql.Conn) {
+	_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	_ = conn.Close()
+}
@@ -4,14 +4,45 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/fluxa/fluxa/internal/sqlrow"
+	"github.com/lib/pq"
 )
 
+// CheckAndMark outcomes, reported as the "outcome" label of idempotency_checks_total.
+const (
+	OutcomeNew           = "new"            // first delivery, key claimed
+	OutcomeDuplicate     = "duplicate"      // already succeeded — a dedupe hit
+	OutcomeInFlight      = "in_flight"      // another worker holds a fresh lock
+	OutcomeStaleTakeover = "stale_takeover" // lock older than staleLockAfter, reclaimed
+	OutcomeRetry         = "retry"          // previous attempt failed, reclaimed
+	OutcomeConflict      = "conflict"       // lost the insert race, re-checking
+	OutcomeLockCollision = "lock_collision" // advisory lock key held for another event, retried
+)
+
+// staleLockAfter is how long a 'processing' row may go unrefreshed before another
+// delivery may take it over.
+const staleLockAfter = 1 * time.Minute
+
 // Client handles idempotency checks
 type Client struct {
-	db *sql.DB
+	db      *sql.DB
+	metrics ports.Metrics
+	flags   *featureflags.Flags
+
+	// held is the advisory lock of each claim in flight; nil in LockModeRow.
+	mu   sync.Mutex
+	held map[string]heldLock
+
+	// keyTTL is how long an Idempotency-Key reservation lasts; 0 is forever.
+	keyTTL time.Duration
 }
 
 // NewClient creates a new idempotency client
@@ -19,15 +50,113 @@ func NewClient(db *sql.DB) *Client {
 	return &Client{db: db}
 }
 
+// WithMetrics enables idempotency_checks_total{outcome} and the idempotency_attempts
+// histogram (attempt number of each claimed delivery). Returns c for chaining.
+func (c *Client) WithMetrics(m ports.Metrics) *Client {
+	c.metrics = m
+	return c
+}
+
+// WithFlags reads idempotency_upsert from flags rather than its default. Returns
+// c for chaining.
+func (c *Client) WithFlags(f *featureflags.Flags) *Client {
+	c.flags = f
+	return c
+}
+
+func (c *Client) recordOutcome(outcome string) {
+	if c.metrics != nil {
+		c.metrics.IncCounter(metricdef.IdempotencyChecksTotal, "outcome", outcome)
+	}
+}
+
+func (c *Client) recordAttempts(attempts int) {
+	if c.metrics != nil {
+		c.metrics.ObserveHistogram(metricdef.IdempotencyAttempts, float64(attempts))
+	}
+}
+
 // CheckAndMark attempts to mark an event as processing, returns true if already processed
-// Uses a transaction with SELECT FOR UPDATE to atomically check and update status
 func (c *Client) CheckAndMark(eventID string) (alreadyProcessed bool, err error) {
+	alreadyProcessed, _, err = c.CheckAndMarkAttempt(eventID)
+	return alreadyProcessed, err
+}
+
+// CheckAndMarkAttempt is CheckAndMark that also returns the attempt number of a
+// claim (the row's attempts after it), or 0 when the event was not claimed.
+//
+// The claim is one INSERT ... ON CONFLICT DO UPDATE statement. With the
+// idempotency_upsert flag off it is the earlier SELECT FOR UPDATE transaction;
+// both claim the same rows. WithAdvisoryLocks replaces both (see claimAdvisory).
+func (c *Client) CheckAndMarkAttempt(eventID string) (alreadyProcessed bool, attempt int, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if c.held != nil {
+		return c.claimAdvisory(ctx, eventID)
+	}
+	if c.flags.Enabled(featureflags.IdempotencyUpsert) {
+		return c.claimUpsert(ctx, eventID)
+	}
+	return c.claimLocked(ctx, eventID)
+}
+
+// claimUpsert claims eventID in one statement. The insert takes a new key; on
+// conflict the update reclaims a failed key or a stale lock, and its WHERE leaves
+// a succeeded or freshly locked key alone, so no row comes back. The prev CTE
+// reads the row as it was before the statement, for the outcome metric only.
+func (c *Client) claimUpsert(ctx context.Context, eventID string) (bool, int, error) {
+	now := time.Now().UTC()
+	var attempts sql.NullInt64
+	var prevStatus sql.NullString
+	err := c.db.QueryRowContext(ctx, `
+		WITH prev AS (
+			SELECT status FROM idempotency_keys WHERE event_id = $1
+		), claim AS (
+			INSERT INTO idempotency_keys (event_id, status, first_seen_at, last_seen_at, attempts)
+			VALUES ($1, $2, $3, $3, 1)
+			ON CONFLICT (event_id) DO UPDATE
+			SET status = EXCLUDED.status, last_seen_at = EXCLUDED.last_seen_at,
+			    attempts = idempotency_keys.attempts + 1
+			WHERE idempotency_keys.status = $4
+			   OR (idempotency_keys.status = $2 AND idempotency_keys.last_seen_at <= $5)
+			RETURNING attempts
+		)
+		SELECT (SELECT attempts FROM claim), (SELECT status FROM prev)
+	`, eventID, string(domain.IdempotencyStatusProcessing), now,
+		string(domain.IdempotencyStatusFailed), now.Add(-staleLockAfter)).Scan(&attempts, &prevStatus)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+
+	if !attempts.Valid {
+		// Not claimed. A key inserted by a concurrent claim has no prev row and
+		// is held by that claim.
+		if prevStatus.String == string(domain.IdempotencyStatusSuccess) {
+			c.recordOutcome(OutcomeDuplicate)
+		} else {
+			c.recordOutcome(OutcomeInFlight)
+		}
+		return true, 0, nil
+	}
+	switch prevStatus.String {
+	case "":
+		c.recordOutcome(OutcomeNew)
+	case string(domain.IdempotencyStatusProcessing):
+		c.recordOutcome(OutcomeStaleTakeover)
+	default:
+		c.recordOutcome(OutcomeRetry)
+	}
+	c.recordAttempts(int(attempts.Int64))
+	return false, int(attempts.Int64), nil
+}
+
+// claimLocked claims eventID in a transaction with SELECT FOR UPDATE, retrying
+// the insert when it loses a race.
+func (c *Client) claimLocked(ctx context.Context, eventID string) (bool, int, error) {
 	tx, err := c.db.BeginTx(ctx, nil)
 	if err != nil {
-		return false, fmt.Errorf("failed to begin transaction: %w", err)
+		return false, 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer func() { _ = tx.Rollback() }()
 
@@ -51,34 +180,39 @@ func (c *Client) CheckAndMark(eventID string) (alreadyProcessed bool, err error)
 			if err != nil {
 				// If duplicate key error (race condition), continue loop to find the record
 				// pq error code 23505 is unique_violation, but checking string is safer cross-driver/mock
+				c.recordOutcome(OutcomeConflict)
 				continue
 			}
 			if err = tx.Commit(); err != nil {
-				return false, fmt.Errorf("failed to commit transaction: %w", err)
+				return false, 0, fmt.Errorf("failed to commit transaction: %w", err)
 			}
-			return false, nil // Successfully claimed new event
+			c.recordOutcome(OutcomeNew)
+			c.recordAttempts(1)
+			return false, 1, nil // Successfully claimed new event
 		} else if err != nil {
-			return false, fmt.Errorf("failed to check idempotency key: %w", err)
+			return false, 0, fmt.Errorf("failed to check idempotency key: %w", err)
 		}
 
 		// 3. Record exists - check state
 		if currentStatus.Valid && currentStatus.String == string(domain.IdempotencyStatusSuccess) {
 			// Already processed successfully
 			if err = tx.Commit(); err != nil {
-				return false, fmt.Errorf("failed to commit transaction: %w", err)
+				return false, 0, fmt.Errorf("failed to commit transaction: %w", err)
 			}
-			return true, nil
+			c.recordOutcome(OutcomeDuplicate)
+			return true, 0, nil
 		}
 
 		if currentStatus.Valid && currentStatus.String == string(domain.IdempotencyStatusProcessing) {
 			// If currently processing and "active" (seen recently), consider it locked/deduplicated.
 			// This prevents concurrent execution race where B thinks it's a retry while A is still working.
-			// Assumption: A process won't take longer than 1 minute without updating status/heartbeat.
-			if lastSeenAt.Valid && now.Sub(lastSeenAt.Time) < 1*time.Minute {
+			// Assumption: A process won't take longer than staleLockAfter without updating status/heartbeat.
+			if lastSeenAt.Valid && now.Sub(lastSeenAt.Time) < staleLockAfter {
 				if err = tx.Commit(); err != nil {
-					return false, fmt.Errorf("failed to commit transaction: %w", err)
+					return false, 0, fmt.Errorf("failed to commit transaction: %w", err)
 				}
-				return true, nil // Considered "already processed" (or being processed)
+				c.recordOutcome(OutcomeInFlight)
+				return true, 0, nil // Considered "already processed" (or being processed)
 			}
 			// If stale, fall through to retry logic
 		}
@@ -88,27 +222,37 @@ func (c *Client) CheckAndMark(eventID string) (alreadyProcessed bool, err error)
 			UPDATE idempotency_keys
 			SET status = $1, last_seen_at = $2, attempts = attempts + 1
 			WHERE event_id = $3
+			RETURNING attempts
 		`
-		_, err = tx.ExecContext(ctx, updateQuery, string(domain.IdempotencyStatusProcessing), now, eventID)
+		var attempts int
+		err = tx.QueryRowContext(ctx, updateQuery, string(domain.IdempotencyStatusProcessing), now, eventID).Scan(&attempts)
 		if err != nil {
-			return false, fmt.Errorf("failed to update idempotency key: %w", err)
+			return false, 0, fmt.Errorf("failed to update idempotency key: %w", err)
 		}
 		if err = tx.Commit(); err != nil {
-			return false, fmt.Errorf("failed to commit transaction: %w", err)
+			return false, 0, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		if currentStatus.String == string(domain.IdempotencyStatusProcessing) {
+			c.recordOutcome(OutcomeStaleTakeover)
+		} else {
+			c.recordOutcome(OutcomeRetry)
 		}
-		return false, nil // Allowed to retry
+		c.recordAttempts(attempts)
+		return false, attempts, nil // Allowed to retry
 	}
-	return false, fmt.Errorf("failed to process idempotency check after retries")
+	return false, 0, fmt.Errorf("failed to process idempotency check after retries")
 }
 
-// MarkSuccess marks an event as successfully processed
+// MarkSuccess marks an event as successfully processed, dropping any queue message
+// kept from an earlier failure.
 func (c *Client) MarkSuccess(eventID string) error {
+	defer c.Release(eventID)
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
 		UPDATE idempotency_keys
-		SET status = $1, last_seen_at = $2
+		SET status = $1, last_seen_at = $2, failed_message = NULL
 		WHERE event_id = $3
 	`
 
@@ -122,6 +266,14 @@ func (c *Client) MarkSuccess(eventID string) error {
 
 // MarkFailed marks an event as failed with error reason
 func (c *Client) MarkFailed(eventID string, errorReason string) error {
+	return c.MarkFailedWithMessage(eventID, errorReason, nil)
+}
+
+// MarkFailedWithMessage is MarkFailed that also keeps the event's queue message
+// (JSON), so a bulk retry can re-enqueue it once the cause is fixed. A nil message
+// keeps none.
+func (c *Client) MarkFailedWithMessage(eventID, errorReason string, message []byte) error {
+	defer c.Release(eventID)
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -129,14 +281,19 @@ func (c *Client) MarkFailed(eventID string, errorReason string) error {
 	if len(errorReason) > 500 {
 		errorReason = errorReason[:500]
 	}
+	var kept *string
+	if message != nil {
+		s := string(message)
+		kept = &s
+	}
 
 	query := `
 		UPDATE idempotency_keys
-		SET status = $1, last_seen_at = $2, error_reason = $3
+		SET status = $1, last_seen_at = $2, error_reason = $3, failed_message = $5
 		WHERE event_id = $4
 	`
 
-	_, err := c.db.ExecContext(ctx, query, string(domain.IdempotencyStatusFailed), time.Now().UTC(), errorReason, eventID)
+	_, err := c.db.ExecContext(ctx, query, string(domain.IdempotencyStatusFailed), time.Now().UTC(), errorReason, eventID, kept)
 	if err != nil {
 		return fmt.Errorf("failed to mark failed: %w", err)
 	}
@@ -149,23 +306,10 @@ func (c *Client) GetStatus(eventID string) (*domain.IdempotencyKeyRecord, error)
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	query := `
-		SELECT event_id, status, first_seen_at, last_seen_at, attempts, error_reason
-		FROM idempotency_keys
-		WHERE event_id = $1
-	`
+	query := `SELECT ` + recordColumns.List() + ` FROM idempotency_keys WHERE event_id = $1`
 
 	var record domain.IdempotencyKeyRecord
-	var errorReason sql.NullString
-
-	err := c.db.QueryRowContext(ctx, query, eventID).Scan(
-		&record.EventID,
-		&record.Status,
-		&record.FirstSeenAt,
-		&record.LastSeenAt,
-		&record.Attempts,
-		&errorReason,
-	)
+	err := c.db.QueryRowContext(ctx, query, eventID).Scan(recordColumns.Dest(&record)...)
 	if err == sql.ErrNoRows {
 		return nil, nil // Not found, means it's new
 	}
@@ -173,9 +317,143 @@ func (c *Client) GetStatus(eventID string) (*domain.IdempotencyKeyRecord, error)
 		return nil, fmt.Errorf("failed to query idempotency key: %w", err)
 	}
 
-	if errorReason.Valid {
-		record.ErrorReason = &errorReason.String
+	return &record, nil
+}
+
+// GetStatuses retrieves idempotency records for many events in one query.
+// IDs with no record are absent from the returned map; duplicates are harmless.
+func (c *Client) GetStatuses(eventIDs []string) (map[string]*domain.IdempotencyKeyRecord, error) {
+	out := make(map[string]*domain.IdempotencyKeyRecord, len(eventIDs))
+	if len(eventIDs) == 0 {
+		return out, nil
 	}
 
-	return &record, nil
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + recordColumns.List() + ` FROM idempotency_keys WHERE event_id = ANY($1)`
+
+	rows, err := c.db.QueryContext(ctx, query, pq.Array(eventIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query idempotency keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record domain.IdempotencyKeyRecord
+		if err := rows.Scan(recordColumns.Dest(&record)...); err != nil {
+			return nil, fmt.Errorf("failed to scan idempotency key: %w", err)
+		}
+		out[record.EventID] = &record
+	}
+	return out, rows.Err()
 }
+
+// failedWhere selects the keys that failed permanently with reason ($1) between
+// $2 (inclusive) and $3 (exclusive). The processor stores NonRetryableError text,
+// "non-retryable: <reason>[: <cause>]".
+const failedWhere = `
+	WHERE status = 'failed' AND last_seen_at >= $2 AND last_seen_at < $3
+	  AND (error_reason = 'non-retryable: ' || $1 OR starts_with(error_reason, 'non-retryable: ' || $1 || ':'))`
+
+// CountFailed returns how many keys failed permanently with reason in [from, to).
+func (c *Client) CountFailed(reason string, from, to time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var n int
+	err := c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM idempotency_keys`+failedWhere, reason, from, to).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count failed idempotency keys: %w", err)
+	}
+	return n, nil
+}
+
+// ListFailed returns up to limit keys that failed permanently with reason in
+// [from, to), with their kept queue messages, in event_id order after afterID
+// ("" for the first page).
+func (c *Client) ListFailed(reason string, from, to time.Time, afterID string, limit int) ([]domain.FailedMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := c.db.QueryContext(ctx, `SELECT event_id, failed_message FROM idempotency_keys`+failedWhere+`
+		  AND event_id > $4
+		ORDER BY event_id
+		LIMIT $5`, reason, from, to, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list failed idempotency keys: %w", err)
+	}
+	defer rows.Close()
+
+	var out []domain.FailedMessage
+	for rows.Next() {
+		var f domain.FailedMessage
+		if err := rows.Scan(&f.EventID, &f.Message); err != nil {
+			return nil, fmt.Errorf("failed to scan failed idempotency key: %w", err)
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// ListFailures returns up to limit permanently failed keys last seen at or after
+// since, oldest first, optionally only those that failed with reason ("" for
+// all). Paging is keyset on (last_seen_at, event_id): pass the last record of the
+// previous page as afterSeen/afterID, or the zero time for the first page.
+func (c *Client) ListFailures(since time.Time, reason string, afterSeen time.Time, afterID string, limit int) ([]domain.FailureRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT event_id, error_reason, attempts, first_seen_at, last_seen_at, failed_message
+		FROM idempotency_keys
+		WHERE status = 'failed' AND last_seen_at >= $1
+		  AND ($2::text = '' OR error_reason = 'non-retryable: ' || $2 OR starts_with(error_reason, 'non-retryable: ' || $2 || ':'))
+		  AND (last_seen_at, event_id) > ($3, $4)
+		ORDER BY last_seen_at, event_id
+		LIMIT $5`, since, reason, afterSeen, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list failures: %w", err)
+	}
+	defer rows.Close()
+
+	var out []domain.FailureRecord
+	for rows.Next() {
+		var f domain.FailureRecord
+		var errorReason sql.NullString
+		var message []byte
+		if err := rows.Scan(&f.EventID, &errorReason, &f.Attempts, &f.FirstSeenAt, &f.LastSeenAt, &message); err != nil {
+			return nil, fmt.Errorf("failed to scan failure: %w", err)
+		}
+		f.Reason, f.Detail = SplitFailureReason(errorReason.String)
+		if message != nil {
+			f.Message = message
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// SplitFailureReason splits a stored error_reason into its reason code and detail:
+// "non-retryable: validation_failed: amount must be positive" is
+// ("validation_failed", "amount must be positive"). Text not in the processor's
+// NonRetryableError form is returned whole as the detail, with reason "unknown".
+func SplitFailureReason(errorReason string) (reason, detail string) {
+	rest, ok := strings.CutPrefix(errorReason, "non-retryable: ")
+	if !ok {
+		return "unknown", errorReason
+	}
+	reason, detail, _ = strings.Cut(rest, ": ")
+	return reason, detail
+}
+
+// recordColumns reads an idempotency_keys row; a NULL error_reason leaves
+// ErrorReason nil.
+var recordColumns = sqlrow.New("idempotency_keys",
+	sqlrow.Col("event_id", func(r *domain.IdempotencyKeyRecord) any { return &r.EventID }),
+	sqlrow.Col("status", func(r *domain.IdempotencyKeyRecord) any { return &r.Status }),
+	sqlrow.Col("first_seen_at", func(r *domain.IdempotencyKeyRecord) any { return &r.FirstSeenAt }),
+	sqlrow.Col("last_seen_at", func(r *domain.IdempotencyKeyRecord) any { return &r.LastSeenAt }),
+	sqlrow.Col("attempts", func(r *domain.IdempotencyKeyRecord) any { return &r.Attempts }),
+	sqlrow.Col("error_reason", func(r *domain.IdempotencyKeyRecord) any { return &r.ErrorReason }),
+)
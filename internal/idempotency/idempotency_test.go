@@ -4,14 +4,30 @@ import (
 	"context"
 	"database/sql"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/sqlrow"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 )
 
+// TestProjectionsMatchMigrations checks recordColumns against the migrated schema;
+// it needs no database.
+func TestProjectionsMatchMigrations(t *testing.T) {
+	schema, err := sqlrow.LoadSchema("../../migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range schema.CheckAll(sqlrow.Registered()) {
+		t.Error(err)
+	}
+}
+
 // getTestDB returns a test database connection (requires TEST_DB_DSN env var)
 // If not set, tests are skipped
 func getTestDB(t *testing.T) *sql.DB {
@@ -39,12 +55,63 @@ func getTestDB(t *testing.T) *sql.DB {
 		if _, err := db.ExecContext(context.Background(), "DELETE FROM events WHERE event_id LIKE 'test-%'"); err != nil {
 			t.Logf("cleanup failed: %v", err)
 		}
+		if _, err := db.ExecContext(context.Background(), "DELETE FROM idempotency_key_payloads WHERE event_id LIKE 'test-%'"); err != nil {
+			t.Logf("cleanup failed: %v", err)
+		}
 	}
 	t.Cleanup(cleanup)
 
 	return db
 }
 
+func TestReserveKey(t *testing.T) {
+	db := getTestDB(t)
+	client := NewClient(db)
+	eventID := "test-" + uuid.New().String()
+
+	if original, err := client.ReserveKey(eventID, "hash-a"); err != nil || original != "" {
+		t.Fatalf("first ReserveKey = %q, %v; want it reserved", original, err)
+	}
+	if original, err := client.ReserveKey(eventID, "hash-b"); err != nil || original != "hash-a" {
+		t.Errorf("second ReserveKey = %q, %v; want the first payload's hash", original, err)
+	}
+	if err := client.ForgetKey(eventID); err != nil {
+		t.Fatalf("ForgetKey: %v", err)
+	}
+	if original, err := client.ReserveKey(eventID, "hash-b"); err != nil || original != "" {
+		t.Errorf("ReserveKey after ForgetKey = %q, %v; want it reserved again", original, err)
+	}
+}
+
+func TestReserveKey_Expiry(t *testing.T) {
+	db := getTestDB(t)
+	client := NewClient(db).WithKeyTTL(time.Hour)
+	expired, live := "test-"+uuid.New().String(), "test-"+uuid.New().String()
+	if _, err := db.Exec(`INSERT INTO idempotency_key_payloads (event_id, payload_sha256, created_at) VALUES
+		($1, 'hash-a', NOW() - INTERVAL '2 hours'), ($2, 'hash-a', NOW())`, expired, live); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = db.Exec(`DELETE FROM idempotency_key_payloads WHERE event_id IN ($1, $2)`, expired, live)
+	}()
+
+	if original, err := client.ReserveKey(expired, "hash-b"); err != nil || original != "" {
+		t.Errorf("ReserveKey(expired) = %q, %v; want it reserved anew", original, err)
+	}
+	if _, err := db.Exec(`UPDATE idempotency_key_payloads SET created_at = NOW() - INTERVAL '2 hours' WHERE event_id = $1`, expired); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := client.PurgeKeys(1000); err != nil || n < 1 {
+		t.Errorf("PurgeKeys = %d, %v; want the expired reservation deleted", n, err)
+	}
+	if original, err := client.ReserveKey(live, "hash-b"); err != nil || original != "hash-a" {
+		t.Errorf("ReserveKey(live) = %q, %v; want it kept through the purge", original, err)
+	}
+	if n, err := NewClient(db).PurgeKeys(1000); err != nil || n != 0 {
+		t.Errorf("PurgeKeys without a TTL = %d, %v; want nothing deleted", n, err)
+	}
+}
+
 func TestCheckAndMark_NewEvent(t *testing.T) {
 	db := getTestDB(t)
 	client := NewClient(db)
@@ -263,3 +330,244 @@ func TestCheckAndMark_ConcurrentCalls_OnlyOneSucceeds(t *testing.T) {
 		t.Errorf("Expected 1 idempotency record, found %d", count)
 	}
 }
+
+func TestGetStatuses(t *testing.T) {
+	db := getTestDB(t)
+	client := NewClient(db)
+
+	done := "test-" + uuid.New().String()
+	failed := "test-" + uuid.New().String()
+	missing := "test-" + uuid.New().String()
+
+	for _, id := range []string{done, failed} {
+		if _, err := client.CheckAndMark(id); err != nil {
+			t.Fatalf("CheckAndMark(%s) failed: %v", id, err)
+		}
+	}
+	if err := client.MarkSuccess(done); err != nil {
+		t.Fatalf("MarkSuccess failed: %v", err)
+	}
+	if err := client.MarkFailed(failed, "boom"); err != nil {
+		t.Fatalf("MarkFailed failed: %v", err)
+	}
+
+	statuses, err := client.GetStatuses([]string{done, failed, missing, done})
+	if err != nil {
+		t.Fatalf("GetStatuses failed: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(statuses))
+	}
+	if got := statuses[done].Status; got != string(domain.IdempotencyStatusSuccess) {
+		t.Errorf("Expected %s to be 'success', got '%s'", done, got)
+	}
+	if rec := statuses[failed]; rec.ErrorReason == nil || *rec.ErrorReason != "boom" {
+		t.Errorf("Expected %s error reason 'boom', got %v", failed, rec.ErrorReason)
+	}
+	if _, ok := statuses[missing]; ok {
+		t.Errorf("Expected no record for %s", missing)
+	}
+
+	empty, err := client.GetStatuses(nil)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("GetStatuses(nil) = %v, %v; want empty map", empty, err)
+	}
+}
+
+func TestImportSucceeded(t *testing.T) {
+	db := getTestDB(t)
+	client := NewClient(db)
+
+	migrated := "test-" + uuid.New().String()
+	failed := "test-" + uuid.New().String()
+	if _, err := client.CheckAndMark(failed); err != nil {
+		t.Fatalf("CheckAndMark failed: %v", err)
+	}
+	if err := client.MarkFailed(failed, "boom"); err != nil {
+		t.Fatalf("MarkFailed failed: %v", err)
+	}
+
+	imported, err := client.ImportSucceeded([]string{migrated, failed, migrated, ""})
+	if err != nil || imported != 1 {
+		t.Fatalf("ImportSucceeded = %d, %v; want 1 new key", imported, err)
+	}
+	rec, err := client.GetStatus(migrated)
+	if err != nil || rec == nil || rec.Status != string(domain.IdempotencyStatusSuccess) || rec.Attempts != 0 {
+		t.Fatalf("imported key = %+v, %v; want success with 0 attempts", rec, err)
+	}
+	if rec, _ := client.GetStatus(failed); rec == nil || rec.Status != string(domain.IdempotencyStatusFailed) {
+		t.Errorf("existing key = %+v, want it left failed", rec)
+	}
+
+	// A replay of the migrated event is a duplicate.
+	if dup, err := client.CheckAndMark(migrated); err != nil || !dup {
+		t.Errorf("CheckAndMark(imported) = %v, %v; want already processed", dup, err)
+	}
+	if _, err := client.ImportSucceeded(make([]string, MaxImportBatch+1)); err == nil {
+		t.Error("ImportSucceeded accepted more than MaxImportBatch keys")
+	}
+}
+
+// recordingMetrics counts IncCounter calls by "name/label-values" for assertions.
+type recordingMetrics struct {
+	counters map[string]int
+	observed []float64
+}
+
+func (m *recordingMetrics) IncCounter(name string, labels ...string) {
+	key := name
+	for i := 1; i < len(labels); i += 2 {
+		key += "/" + labels[i]
+	}
+	m.counters[key]++
+}
+
+func (m *recordingMetrics) ObserveHistogram(name string, value float64, labels ...string) {
+	m.observed = append(m.observed, value)
+}
+
+func (m *recordingMetrics) SetGauge(name string, value float64, labels ...string) {}
+
+func TestCheckAndMark_RecordsOutcomes(t *testing.T) {
+	db := getTestDB(t)
+	m := &recordingMetrics{counters: map[string]int{}}
+	client := NewClient(db).WithMetrics(m)
+
+	eventID := "test-" + uuid.New().String()
+
+	if _, err := client.CheckAndMark(eventID); err != nil { // new
+		t.Fatalf("CheckAndMark failed: %v", err)
+	}
+	if _, err := client.CheckAndMark(eventID); err != nil { // fresh lock held
+		t.Fatalf("CheckAndMark failed: %v", err)
+	}
+	_, err := db.ExecContext(context.Background(), "UPDATE idempotency_keys SET last_seen_at = $1 WHERE event_id = $2", time.Now().Add(-5*time.Minute), eventID)
+	if err != nil {
+		t.Fatalf("Failed to expire lock: %v", err)
+	}
+	if _, err := client.CheckAndMark(eventID); err != nil { // stale takeover
+		t.Fatalf("CheckAndMark failed: %v", err)
+	}
+	if err := client.MarkSuccess(eventID); err != nil {
+		t.Fatalf("MarkSuccess failed: %v", err)
+	}
+	if _, err := client.CheckAndMark(eventID); err != nil { // dedupe hit
+		t.Fatalf("CheckAndMark failed: %v", err)
+	}
+
+	for _, outcome := range []string{OutcomeNew, OutcomeInFlight, OutcomeStaleTakeover, OutcomeDuplicate} {
+		if got := m.counters["idempotency_checks_total/"+outcome]; got != 1 {
+			t.Errorf("Expected 1 %q outcome, got %d", outcome, got)
+		}
+	}
+	if len(m.observed) != 2 || m.observed[0] != 1 || m.observed[1] != 2 {
+		t.Errorf("Expected attempts observations [1 2], got %v", m.observed)
+	}
+}
+
+// TestCheckAndMarkAttempt_ClaimPaths runs the same claims through the upsert and
+// the SELECT FOR UPDATE path; idempotency_upsert must not change what is claimed.
+func TestCheckAndMarkAttempt_ClaimPaths(t *testing.T) {
+	db := getTestDB(t)
+	for _, upsert := range []bool{true, false} {
+		t.Run("upsert="+strconv.FormatBool(upsert), func(t *testing.T) {
+			t.Setenv("FLAG_IDEMPOTENCY_UPSERT", strconv.FormatBool(upsert))
+			client := NewClient(db).WithFlags(featureflags.New(0, logging.NewLogger("test", "test"), featureflags.EnvSource{}))
+			eventID := "test-" + uuid.New().String()
+
+			claim := func(step string, wantDone bool, wantAttempt int) {
+				t.Helper()
+				done, attempt, err := client.CheckAndMarkAttempt(eventID)
+				if err != nil || done != wantDone || attempt != wantAttempt {
+					t.Fatalf("%s: CheckAndMarkAttempt = %v, %d, %v; want %v, %d", step, done, attempt, err, wantDone, wantAttempt)
+				}
+			}
+			claim("new", false, 1)
+			claim("fresh lock held", true, 0)
+			if err := client.MarkFailed(eventID, "boom"); err != nil {
+				t.Fatal(err)
+			}
+			claim("retry after failure", false, 2)
+			_, err := db.ExecContext(context.Background(), "UPDATE idempotency_keys SET last_seen_at = $1 WHERE event_id = $2", time.Now().Add(-5*time.Minute), eventID)
+			if err != nil {
+				t.Fatalf("Failed to expire lock: %v", err)
+			}
+			claim("stale takeover", false, 3)
+			if err := client.MarkSuccess(eventID); err != nil {
+				t.Fatal(err)
+			}
+			claim("dedupe hit", true, 0)
+		})
+	}
+}
+
+func TestCheckAndMarkAttempt_AdvisoryLocks(t *testing.T) {
+	db := getTestDB(t)
+	a, b := NewClient(db).WithAdvisoryLocks(), NewClient(db).WithAdvisoryLocks()
+	eventID := "test-" + uuid.New().String()
+
+	if done, attempt, err := a.CheckAndMarkAttempt(eventID); err != nil || done || attempt != 1 {
+		t.Fatalf("first claim = %v, %d, %v; want claimed on attempt 1", done, attempt, err)
+	}
+	if done, _, err := b.CheckAndMarkAttempt(eventID); err != nil || !done {
+		t.Fatalf("claim while locked = %v, %v; want in flight", done, err)
+	}
+	// A released claim is taken over at once, with no stale-lock wait.
+	a.Release(eventID)
+	if done, attempt, err := b.CheckAndMarkAttempt(eventID); err != nil || done || attempt != 2 {
+		t.Fatalf("claim after release = %v, %d, %v; want claimed on attempt 2", done, attempt, err)
+	}
+	if err := b.MarkSuccess(eventID); err != nil {
+		t.Fatal(err)
+	}
+	if done, _, err := a.CheckAndMarkAttempt(eventID); err != nil || !done {
+		t.Fatalf("claim after success = %v, %v; want already processed", done, err)
+	}
+	if len(a.held) != 0 || len(b.held) != 0 {
+		t.Errorf("locks still held: %d, %d", len(a.held), len(b.held))
+	}
+}
+
+func TestCheckAndMarkAttempt_AdvisoryLockCollision(t *testing.T) {
+	db := getTestDB(t)
+	c := NewClient(db).WithAdvisoryLocks()
+	eventID := "test-" + uuid.New().String()
+
+	// Hold eventID's lock key as another event sharing it would.
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_lock($1)`, lockKey(eventID)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey(eventID))
+	}()
+
+	if done, _, err := c.CheckAndMarkAttempt(eventID); err != ErrLockCollision || done {
+		t.Fatalf("claim under another event's lock = %v, %v; want ErrLockCollision, not processed", done, err)
+	}
+	if _, err := db.Exec(`INSERT INTO idempotency_keys (event_id, status, first_seen_at, last_seen_at, attempts) VALUES ($1, $2, NOW(), NOW(), 1)`,
+		eventID, string(domain.IdempotencyStatusProcessing)); err != nil {
+		t.Fatal(err)
+	}
+	if done, _, err := c.CheckAndMarkAttempt(eventID); err != nil || !done {
+		t.Errorf("claim of a processing event under a held lock = %v, %v; want in flight", done, err)
+	}
+}
+
+func TestSplitFailureReason(t *testing.T) {
+	cases := []struct{ in, reason, detail string }{
+		{"non-retryable: hash_mismatch", "hash_mismatch", ""},
+		{"non-retryable: validation_failed: amount must be positive", "validation_failed", "amount must be positive"},
+		{"max attempts exceeded", "unknown", "max attempts exceeded"},
+	}
+	for _, tc := range cases {
+		reason, detail := SplitFailureReason(tc.in)
+		if reason != tc.reason || detail != tc.detail {
+			t.Errorf("SplitFailureReason(%q) = %q, %q; want %q, %q", tc.in, reason, detail, tc.reason, tc.detail)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/lib/pq"
+)
+
+// MaxImportBatch bounds the keys one ImportSucceeded call writes.
+const MaxImportBatch = 10000
+
+// ImportSucceeded records eventIDs as already processed, for events migrated from
+// another system: a later delivery of one is a duplicate and skipped, as if this
+// processor had persisted it. Imported keys have 0 attempts, which tells them
+// apart from keys this processor claimed. Keys that already exist, in any status,
+// are left alone; imported is how many were new.
+func (c *Client) ImportSucceeded(eventIDs []string) (imported int, err error) {
+	if len(eventIDs) == 0 {
+		return 0, nil
+	}
+	if len(eventIDs) > MaxImportBatch {
+		return 0, fmt.Errorf("at most %d keys per import, got %d", MaxImportBatch, len(eventIDs))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := c.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (event_id, status, first_seen_at, last_seen_at, attempts)
+		SELECT DISTINCT id, $2, $3::timestamptz, $3::timestamptz, 0 FROM unnest($1::text[]) AS id
+		WHERE id <> ''
+		ON CONFLICT (event_id) DO NOTHING
+	`, pq.Array(eventIDs), string(domain.IdempotencyStatusSuccess), time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to import idempotency keys: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to import idempotency keys: %w", err)
+	}
+	return int(n), nil
+}
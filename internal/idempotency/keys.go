@@ -0,0 +1,83 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WithKeyTTL makes Idempotency-Key reservations expire ttl after they are made
+// (IDEMPOTENCY_KEY_TTL_HOURS): ReserveKey takes an expired one over as new, and
+// PurgeKeys deletes them. 0 keeps them forever. Returns c for chaining.
+func (c *Client) WithKeyTTL(ttl time.Duration) *Client {
+	c.keyTTL = ttl
+	return c
+}
+
+// keyCutoff is the creation time before which reservations have expired, or
+// NULL when they don't expire.
+func (c *Client) keyCutoff() sql.NullTime {
+	if c.keyTTL <= 0 {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: time.Now().Add(-c.keyTTL), Valid: true}
+}
+
+// ReserveKey records payloadSHA256 as the payload of eventID, an event ID ingest
+// derived from an Idempotency-Key, unless the key already has one that hasn't
+// expired. It returns the payload hash the key was first accepted with, "" when
+// this call reserved it: the caller compares the two to tell a retry from a key
+// reused for another payload.
+func (c *Client) ReserveKey(eventID, payloadSHA256 string) (original string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = c.db.QueryRowContext(ctx, `
+		INSERT INTO idempotency_key_payloads (event_id, payload_sha256) VALUES ($1, $2)
+		ON CONFLICT (event_id) DO UPDATE
+		SET payload_sha256 = EXCLUDED.payload_sha256, created_at = CURRENT_TIMESTAMP
+		WHERE idempotency_key_payloads.created_at < $3
+		RETURNING event_id`, eventID, payloadSHA256, c.keyCutoff()).Scan(new(string))
+	if err == nil {
+		return "", nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	// Taken: the conflicting insert has committed, so its row is visible now.
+	if err := c.db.QueryRowContext(ctx, `SELECT payload_sha256 FROM idempotency_key_payloads WHERE event_id = $1`, eventID).Scan(&original); err != nil {
+		return "", fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+	return original, nil
+}
+
+// ForgetKey drops the reservation of eventID, when its event was not enqueued
+// after all, so the producer's retry is accepted.
+func (c *Client) ForgetKey(eventID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM idempotency_key_payloads WHERE event_id = $1`, eventID); err != nil {
+		return fmt.Errorf("failed to forget idempotency key: %w", err)
+	}
+	return nil
+}
+
+// PurgeKeys deletes up to limit expired reservations and returns how many it
+// deleted; none when reservations don't expire.
+func (c *Client) PurgeKeys(limit int) (int64, error) {
+	cutoff := c.keyCutoff()
+	if !cutoff.Valid {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	res, err := c.db.ExecContext(ctx, `
+		DELETE FROM idempotency_key_payloads WHERE event_id IN (
+			SELECT event_id FROM idempotency_key_payloads WHERE created_at < $1 LIMIT $2
+		)`, cutoff.Time, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,46 @@
+// Package jobs runs the query service's background admin jobs (exports, bulk
+// retries) in the process that starts them, a few at a time, each under a
+// deadline. What a job does and how it records its progress is up to the caller.
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pool runs jobs on a fixed number of worker slots.
+type Pool struct {
+	timeout time.Duration
+
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewPool returns a Pool running at most workers jobs at once, each with a
+// context that expires after timeout. workers is at least 1.
+func NewPool(workers int, timeout time.Duration) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Pool{timeout: timeout, slots: make(chan struct{}, workers)}
+}
+
+// Start runs job in the background. It stays pending until a slot is free.
+func (p *Pool) Start(job func(ctx context.Context)) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.slots <- struct{}{}
+		defer func() { <-p.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		defer cancel()
+		job(ctx)
+	}()
+}
+
+// Wait blocks until every started job has finished.
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
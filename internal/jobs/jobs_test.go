@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool_BoundsConcurrency(t *testing.T) {
+	p := NewPool(2, time.Minute)
+	var running, peak atomic.Int32
+	for i := 0; i < 6; i++ {
+		p.Start(func(ctx context.Context) {
+			n := running.Add(1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+		})
+	}
+	p.Wait()
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrency = %d, want 2", got)
+	}
+}
+
+func TestPool_Timeout(t *testing.T) {
+	p := NewPool(0, 20*time.Millisecond)
+	var err error
+	p.Start(func(ctx context.Context) {
+		<-ctx.Done()
+		err = ctx.Err()
+	})
+	p.Wait()
+	if err != context.DeadlineExceeded {
+		t.Errorf("job context ended with %v, want DeadlineExceeded", err)
+	}
+}
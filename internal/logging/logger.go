@@ -23,11 +23,13 @@ type LogEntry struct {
 	Fields        map[string]interface{} `json:"fields,omitempty"`
 }
 
-// Logger provides structured logging with context
+// Logger provides structured logging with context. A Logger is immutable once
+// built: With returns a new one, and logging never modifies its fields, so one
+// Logger may be shared across goroutines.
 type Logger struct {
 	correlationID string
 	service       string
-	defaultFields map[string]interface{}
+	defaultFields map[string]interface{} // read-only after construction
 }
 
 // NewLogger creates a new logger with service name and correlation ID
@@ -35,23 +37,16 @@ func NewLogger(service, correlationID string) *Logger {
 	return &Logger{
 		service:       service,
 		correlationID: correlationID,
-		defaultFields: make(map[string]interface{}),
 	}
 }
 
-// With returns a new Logger instance with additional context fields
+// With returns a new Logger instance with additional context fields. l and the
+// fields map are left as they were.
 func (l *Logger) With(fields map[string]interface{}) *Logger {
-	newFields := make(map[string]interface{})
-	for k, v := range l.defaultFields {
-		newFields[k] = v
-	}
-	for k, v := range fields {
-		newFields[k] = v
-	}
 	return &Logger{
 		service:       l.service,
 		correlationID: l.correlationID,
-		defaultFields: newFields,
+		defaultFields: mergeFields(l.defaultFields, fields),
 	}
 }
 
@@ -80,55 +75,66 @@ func (l *Logger) Debug(message string, fields ...map[string]interface{}) {
 }
 
 func (l *Logger) log(level, message string, fields ...map[string]interface{}) {
+	bytes, err := json.Marshal(l.entry(time.Now(), level, message, fields...))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, `{"level":"ERROR","message":"Failed to marshal log entry: %v"}`+"\n", err)
+		return
+	}
+	fmt.Println(string(bytes))
+}
+
+// entry builds the LogEntry for a message: the logger's fields overlaid with the
+// call's, standard ones (stage, event_id, …) promoted to top-level. It only reads
+// the maps it is given; the entry's Fields is its own.
+func (l *Logger) entry(now time.Time, level, message string, fields ...map[string]interface{}) LogEntry {
 	entry := LogEntry{
-		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		Timestamp:     now.UTC().Format(time.RFC3339),
 		Level:         level,
 		Service:       l.service,
 		CorrelationID: l.correlationID,
 		Message:       message,
-		Fields:        mergeFields(l.defaultFields, mergeFields(fields...)),
-	}
-
-	// Promote specific standard fields to top-level if present in Fields
-	if val, ok := entry.Fields["stage"]; ok {
-		entry.Stage = fmt.Sprint(val)
-		delete(entry.Fields, "stage")
-	}
-	if val, ok := entry.Fields["event_id"]; ok {
-		entry.EventID = fmt.Sprint(val)
-		delete(entry.Fields, "event_id")
 	}
-	if val, ok := entry.Fields["payload_mode"]; ok {
-		entry.PayloadMode = fmt.Sprint(val)
-		delete(entry.Fields, "payload_mode")
-	}
-	if val, ok := entry.Fields["status"]; ok {
-		entry.Status = fmt.Sprint(val)
-		delete(entry.Fields, "status")
+	for _, f := range append([]map[string]interface{}{l.defaultFields}, fields...) {
+		for k, v := range f {
+			if entry.promote(k, v) {
+				continue
+			}
+			if entry.Fields == nil {
+				entry.Fields = make(map[string]interface{})
+			}
+			entry.Fields[k] = v
+		}
 	}
-	if val, ok := entry.Fields["latency_ms"]; ok {
+	return entry
+}
+
+// promote sets the top-level field named k, reporting whether k is one.
+func (e *LogEntry) promote(k string, v interface{}) bool {
+	switch k {
+	case "stage":
+		e.Stage = fmt.Sprint(v)
+	case "event_id":
+		e.EventID = fmt.Sprint(v)
+	case "payload_mode":
+		e.PayloadMode = fmt.Sprint(v)
+	case "status":
+		e.Status = fmt.Sprint(v)
+	case "latency_ms":
 		// Handle float and int types for latency
-		switch v := val.(type) {
+		switch v := v.(type) {
 		case float64:
-			entry.LatencyMs = v
+			e.LatencyMs = v
 		case int:
-			entry.LatencyMs = float64(v)
+			e.LatencyMs = float64(v)
 		case int64:
-			entry.LatencyMs = float64(v)
+			e.LatencyMs = float64(v)
 		}
-		delete(entry.Fields, "latency_ms")
-	}
-	if val, ok := entry.Fields["error_code"]; ok {
-		entry.ErrorCode = fmt.Sprint(val)
-		delete(entry.Fields, "error_code")
+	case "error_code":
+		e.ErrorCode = fmt.Sprint(v)
+	default:
+		return false
 	}
-
-	bytes, err := json.Marshal(entry)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, `{"level":"ERROR","message":"Failed to marshal log entry: %v"}`+"\n", err)
-		return
-	}
-	fmt.Println(string(bytes))
+	return true
 }
 
 func mergeFields(fields ...map[string]interface{}) map[string]interface{} {
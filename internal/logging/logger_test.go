@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEntry_PromotesStandardFields(t *testing.T) {
+	l := NewLogger("ingest", "corr-1").With(map[string]interface{}{"event_id": "evt-1", "stage": "validate"})
+	e := l.entry(time.Now(), "INFO", "msg", map[string]interface{}{"stage": "enqueue", "latency_ms": 12, "routing_key": "events"})
+
+	if e.EventID != "evt-1" || e.Stage != "enqueue" || e.LatencyMs != 12 {
+		t.Errorf("entry = %+v, want event_id evt-1, the call's stage, latency 12", e)
+	}
+	if len(e.Fields) != 1 || e.Fields["routing_key"] != "events" {
+		t.Errorf("fields = %v, want only routing_key", e.Fields)
+	}
+}
+
+// TestLogger_SharedAcrossGoroutines is meant for go test -race: logging from one
+// Logger concurrently must not write to its fields or the callers' maps.
+func TestLogger_SharedAcrossGoroutines(t *testing.T) {
+	l := NewLogger("processor", "corr-1").With(map[string]interface{}{"event_id": "evt-1", "batch_id": "b-1"})
+	call := map[string]interface{}{"stage": "persist", "status": "ok"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				e := l.entry(time.Now(), "INFO", "msg", call)
+				if e.EventID != "evt-1" || e.Stage != "persist" || e.Fields["batch_id"] != "b-1" {
+					t.Errorf("entry = %+v, want the shared fields", e)
+					return
+				}
+				_ = l.With(map[string]interface{}{"member": j})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(l.defaultFields) != 2 || l.defaultFields["event_id"] != "evt-1" {
+		t.Errorf("logger fields = %v, want them unchanged", l.defaultFields)
+	}
+	if len(call) != 2 || call["stage"] != "persist" {
+		t.Errorf("call fields = %v, want them unchanged", call)
+	}
+}
@@ -0,0 +1,153 @@
+// Package merchant maps raw merchant descriptors to canonical merchant names.
+// Resolution order: admin-managed aliases (exact match on the normalized key),
+// then the built-in fuzzy rules, then the trimmed raw descriptor unchanged.
+package merchant
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/logging"
+)
+
+// AliasStore is the narrow DB surface the canonicalizer needs. *db.Client satisfies it.
+type AliasStore interface {
+	ListMerchantAliases() ([]domain.MerchantAlias, error)
+}
+
+// processorPrefixes are payment-processor tags prepended to the real merchant
+// name ("SQ *BLUE BOTTLE", "PAYPAL *SPOTIFY"). They are stripped before matching.
+var processorPrefixes = []string{"sq ", "tst ", "paypal ", "pp ", "sp "}
+
+// fuzzyRules map a normalized-key prefix to a canonical merchant. Checked in order.
+var fuzzyRules = []struct {
+	prefix    string
+	canonical string
+}{
+	{"amzn", "Amazon"},
+	{"amazon com", "Amazon"},
+	{"amazon mktp", "Amazon"},
+	{"wal mart", "Walmart"},
+	{"wm supercenter", "Walmart"},
+	{"uber trip", "Uber"},
+	{"uber eats", "Uber Eats"},
+	{"google", "Google"},
+	{"apple com", "Apple"},
+	{"netflix", "Netflix"},
+}
+
+// Key normalizes a raw descriptor for matching: lowercases, turns punctuation into
+// spaces, drops tokens containing digits (store numbers, reference codes), strips
+// processor prefixes, and collapses whitespace. "AMZN Mktp US*2K3LO1" -> "amzn mktp us".
+func Key(raw string) string {
+	mapped := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, raw)
+
+	var tokens []string
+	for _, tok := range strings.Fields(mapped) {
+		if strings.IndexFunc(tok, unicode.IsDigit) >= 0 {
+			continue
+		}
+		tokens = append(tokens, tok)
+	}
+	key := strings.Join(tokens, " ")
+
+	for _, p := range processorPrefixes {
+		if strings.HasPrefix(key+" ", p) && len(key) > len(p) {
+			key = strings.TrimPrefix(key, p)
+			break
+		}
+	}
+	return key
+}
+
+// Canonicalizer resolves canonical merchant names, caching the alias table and
+// refreshing it at most once per RefreshInterval.
+type Canonicalizer struct {
+	store           AliasStore
+	logger          *logging.Logger
+	refreshInterval time.Duration
+
+	mu       sync.RWMutex
+	aliases  map[string]string
+	loadedAt time.Time
+}
+
+// NewCanonicalizer returns a Canonicalizer backed by store. A nil store uses only
+// the built-in fuzzy rules. refreshInterval <= 0 defaults to one minute.
+func NewCanonicalizer(store AliasStore, logger *logging.Logger, refreshInterval time.Duration) *Canonicalizer {
+	if refreshInterval <= 0 {
+		refreshInterval = time.Minute
+	}
+	return &Canonicalizer{store: store, logger: logger, refreshInterval: refreshInterval}
+}
+
+// Canonicalize returns the canonical merchant for raw. It never fails: an alias
+// refresh error keeps serving the previous table (or fuzzy rules only).
+func (c *Canonicalizer) Canonicalize(raw string) string {
+	key := Key(raw)
+	if canonical, ok := c.lookup(key); ok {
+		return canonical
+	}
+	for _, rule := range fuzzyRules {
+		if strings.HasPrefix(key, rule.prefix) {
+			return rule.canonical
+		}
+	}
+	return strings.TrimSpace(raw)
+}
+
+func (c *Canonicalizer) lookup(key string) (string, bool) {
+	c.refreshIfStale()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	canonical, ok := c.aliases[key]
+	return canonical, ok
+}
+
+// Invalidate forces the next Canonicalize call to reload the alias table.
+func (c *Canonicalizer) Invalidate() {
+	c.mu.Lock()
+	c.loadedAt = time.Time{}
+	c.mu.Unlock()
+}
+
+func (c *Canonicalizer) refreshIfStale() {
+	if c.store == nil {
+		return
+	}
+	c.mu.RLock()
+	fresh := time.Since(c.loadedAt) < c.refreshInterval
+	c.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.loadedAt) < c.refreshInterval {
+		return
+	}
+	// Stamp before loading so a failing store is retried once per interval, not per event.
+	c.loadedAt = time.Now()
+
+	list, err := c.store.ListMerchantAliases()
+	if err != nil {
+		if c.logger != nil {
+			c.logger.Warn("Merchant alias refresh failed; using cached aliases", map[string]interface{}{"error": err.Error()})
+		}
+		return
+	}
+	aliases := make(map[string]string, len(list))
+	for _, a := range list {
+		aliases[a.AliasKey] = a.Canonical
+	}
+	c.aliases = aliases
+}
@@ -0,0 +1,93 @@
+package merchant
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/logging"
+)
+
+type fakeStore struct {
+	aliases []domain.MerchantAlias
+	err     error
+	calls   int
+}
+
+func (f *fakeStore) ListMerchantAliases() ([]domain.MerchantAlias, error) {
+	f.calls++
+	return f.aliases, f.err
+}
+
+func TestKey(t *testing.T) {
+	cases := map[string]string{
+		"AMZN Mktp US*2K3LO1":     "amzn mktp us",
+		"  Amazon.com  ":          "amazon com",
+		"SQ *BLUE BOTTLE #0412":   "blue bottle",
+		"WAL-MART #1234":          "wal mart",
+		"Target":                  "target",
+		"PAYPAL *SPOTIFY 4029357": "spotify",
+	}
+	for raw, want := range cases {
+		if got := Key(raw); got != want {
+			t.Errorf("Key(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestCanonicalize_AliasBeatsFuzzyRules(t *testing.T) {
+	store := &fakeStore{aliases: []domain.MerchantAlias{
+		{AliasKey: "blue bottle", Canonical: "Blue Bottle Coffee"},
+		{AliasKey: "amzn mktp us", Canonical: "Amazon Marketplace"},
+	}}
+	c := NewCanonicalizer(store, logging.NewLogger("test", "test"), time.Minute)
+
+	cases := map[string]string{
+		"SQ *BLUE BOTTLE #0412": "Blue Bottle Coffee",
+		"AMZN Mktp US*2K3LO1":   "Amazon Marketplace", // alias wins over the "amzn" fuzzy rule
+		"AMZN Digital*RT4":      "Amazon",             // fuzzy rule
+		"WAL-MART #1234":        "Walmart",
+		"  Corner Shop ":        "Corner Shop", // no match: trimmed raw descriptor
+	}
+	for raw, want := range cases {
+		if got := c.Canonicalize(raw); got != want {
+			t.Errorf("Canonicalize(%q) = %q, want %q", raw, got, want)
+		}
+	}
+	if store.calls != 1 {
+		t.Errorf("alias table loaded %d times, want 1 within the refresh interval", store.calls)
+	}
+}
+
+func TestCanonicalize_StoreErrorFallsBackToRules(t *testing.T) {
+	store := &fakeStore{err: errors.New("db down")}
+	c := NewCanonicalizer(store, logging.NewLogger("test", "test"), time.Minute)
+
+	if got := c.Canonicalize("AMZN Mktp US"); got != "Amazon" {
+		t.Errorf("Canonicalize = %q, want fuzzy-rule result Amazon", got)
+	}
+	c.Canonicalize("Target")
+	if store.calls != 1 {
+		t.Errorf("failing store retried %d times, want once per interval", store.calls)
+	}
+}
+
+func TestCanonicalize_InvalidateReloads(t *testing.T) {
+	store := &fakeStore{}
+	c := NewCanonicalizer(store, nil, time.Hour)
+	c.Canonicalize("x")
+
+	store.aliases = []domain.MerchantAlias{{AliasKey: "x", Canonical: "X Corp"}}
+	c.Invalidate()
+	if got := c.Canonicalize("x"); got != "X Corp" {
+		t.Errorf("Canonicalize after Invalidate = %q, want X Corp", got)
+	}
+}
+
+func TestCanonicalize_NilStore(t *testing.T) {
+	c := NewCanonicalizer(nil, nil, 0)
+	if got := c.Canonicalize("amazon.com"); got != "Amazon" {
+		t.Errorf("Canonicalize = %q, want Amazon", got)
+	}
+}
@@ -0,0 +1,397 @@
+// Package metricdef is the catalog of every metric Fluxa emits: its name, kind,
+// unit, help text, and labels. Call sites use the name constants rather than string
+// literals, so a misspelled metric fails to compile instead of silently creating
+// (or, with the Prometheus adapter, dropping) an orphan series. The Prometheus
+// adapter registers exactly this catalog, and Validate enforces the naming
+// conventions for each unit.
+package metricdef
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Counters.
+const (
+	EventsIngestedTotal         = "events_ingested_total"
+	EventsProcessedTotal        = "events_processed_total"
+	FraudFlagsTotal             = "fraud_flags_total"
+	QueryTotal                  = "query_total"
+	AlertsConsumedTotal         = "alerts_consumed_total"
+	FraudFlagsGRPCTotal         = "fraud_flags_grpc_total"
+	AnomalousEventsTotal        = "anomalous_events_total"
+	EnrichmentLookupsTotal      = "enrichment_lookups_total"
+	IdempotencyChecksTotal      = "idempotency_checks_total"
+	PayloadDedupTotal           = "payload_dedup_total"
+	CanaryEventsTotal           = "canary_events_total"
+	NotificationsResentTotal    = "notifications_resent_total"
+	AlertsDeduplicatedTotal     = "alerts_deduplicated_total"
+	IngestSignatureChecksTotal  = "ingest_signature_checks_total"
+	StaleEventsRejectedTotal    = "stale_events_rejected_total"
+	BatchesProcessedTotal       = "batches_processed_total"
+	WebhookDeliveriesTotal      = "webhook_deliveries_total"
+	CanaryAlertsConsumedTotal   = "canary_alerts_consumed_total"
+	ExportsTotal                = "exports_total"
+	DynamicConfigReloadsTotal   = "dynamic_config_reloads_total"
+	IngestDuplicatesTotal       = "ingest_duplicates_total"
+	DBTimeoutsTotal             = "db_timeouts_total"
+	SlowQueriesTotal            = "slow_queries_total"
+	PayloadsArchivedTotal       = "payloads_archived_total"
+	InlineOverflowTotal         = "inline_overflow_total"
+	RetryJobsTotal              = "retry_jobs_total"
+	RetriedEventsTotal          = "retried_events_total"
+	IngestDeadlineExceededTotal = "ingest_deadline_exceeded_total"
+	IngestPublishRetriesTotal   = "ingest_publish_retries_total"
+	IngestEnqueueFailuresTotal  = "ingest_enqueue_failures_total"
+	IngestCompressedBodiesTotal = "ingest_compressed_bodies_total"
+	IngestRateLimitedTotal      = "ingest_rate_limited_total"
+	ProcessorWriteWaitSeconds   = "processor_write_wait_seconds"
+	ConsumerDeliveriesTotal     = "consumer_deliveries_total"
+	ConsumerRedeliveriesTotal   = "consumer_redeliveries_total"
+	ConsumerSettleFailuresTotal = "consumer_settle_failures_total"
+	FinalAttemptsTotal          = "final_attempts_total"
+	GroupsCompletedTotal        = "groups_completed_total"
+	AuthzDecisionsTotal         = "authz_decisions_total"
+	SchemaDriftTotal            = "schema_drift_total"
+	RequestRecordingsTotal      = "request_recordings_total"
+	TenantEventsProcessedTotal  = "tenant_events_processed_total"
+	TenantObjectsStoredTotal    = "tenant_objects_stored_total"
+)
+
+// Histograms.
+const (
+	IngestLatencySeconds    = "ingest_latency_seconds"
+	ProcessLatencySeconds   = "process_latency_seconds"
+	FraudEvalLatencySeconds = "fraud_eval_latency_seconds"
+	IdempotencyAttempts     = "idempotency_attempts"
+	AmountZScore            = "amount_zscore"
+	ConsumerWaitSeconds     = "consumer_wait_seconds"
+	E2ELatencySeconds       = "e2e_latency_seconds"
+	TenantPayloadBytes      = "tenant_payload_bytes"
+)
+
+// Gauges.
+const (
+	QueueMessages    = "queue_messages"
+	QueueConsumers   = "queue_consumers"
+	ConsumerInFlight = "consumer_in_flight"
+)
+
+// Kind is the metric type.
+type Kind int
+
+const (
+	Counter Kind = iota
+	Histogram
+	Gauge
+)
+
+// Unit is what a metric's values measure. Prometheus puts the unit in the name, so
+// it also fixes the name's suffix.
+type Unit int
+
+const (
+	// UnitNone is dimensionless: counts of things, attempt numbers, z-scores.
+	UnitNone Unit = iota
+	// UnitSeconds is a duration, always in (fractional) seconds.
+	UnitSeconds
+	// UnitBytes is a size, always in bytes.
+	UnitBytes
+)
+
+// Def describes one metric.
+type Def struct {
+	Name    string
+	Kind    Kind
+	Unit    Unit
+	Help    string
+	Labels  []string
+	Buckets []float64 // histograms only
+}
+
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5}
+
+// attemptBuckets cover delivery attempt numbers; anything past a handful means a
+// message is cycling through redelivery.
+var attemptBuckets = []float64{1, 2, 3, 4, 5, 7, 10, 15, 20}
+
+// waitBuckets cover how long a consumer idles between deliveries: near zero
+// under backlog, up to minutes on a quiet queue.
+var waitBuckets = []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 5, 15, 60, 300}
+
+// sizeBuckets cover payload sizes, from a small inline event to the largest
+// offloaded one.
+var sizeBuckets = []float64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+
+// zscoreBuckets cover |z| of an amount against its rolling distribution.
+var zscoreBuckets = []float64{0.5, 1, 1.5, 2, 2.5, 3, 4, 5, 7.5, 10}
+
+var catalog = []Def{
+	{
+		Name: EventsIngestedTotal, Kind: Counter, Labels: []string{"service"},
+		Help: "Total events accepted by ingest",
+	},
+	{
+		Name: EventsProcessedTotal, Kind: Counter, Labels: []string{"service", "status"},
+		Help: "Total events completing the processor pipeline",
+	},
+	{
+		Name: FraudFlagsTotal, Kind: Counter, Labels: []string{"rule"},
+		Help: "Total fraud rule fires",
+	},
+	{
+		Name: QueryTotal, Kind: Counter, Labels: []string{"status"},
+		Help: "Total query endpoint outcomes",
+	},
+	{
+		Name: AlertsConsumedTotal, Kind: Counter,
+		Help: "Total alerts received by alert-consumer",
+	},
+	{
+		Name: FraudFlagsGRPCTotal, Kind: Counter, Labels: []string{"rule"},
+		Help: "Total fraud rule fires via the synchronous gRPC surface",
+	},
+	{
+		Name: AnomalousEventsTotal, Kind: Counter, Labels: []string{"dimension"},
+		Help: "Events whose amount deviates beyond the anomaly threshold, by dimension",
+	},
+	{
+		Name: EnrichmentLookupsTotal, Kind: Counter, Labels: []string{"status"},
+		Help: "User-profile enrichment lookups by outcome (ok/empty/error)",
+	},
+	{
+		Name: IdempotencyChecksTotal, Kind: Counter, Labels: []string{"outcome"},
+		Help: "Idempotency CheckAndMark outcomes (new/duplicate/in_flight/stale_takeover/retry/conflict/lock_collision)",
+	},
+	{
+		Name: PayloadDedupTotal, Kind: Counter, Labels: []string{"result"},
+		Help: "Offloaded payload stores by outcome (hit = existing object reused, miss = uploaded)",
+	},
+	{
+		Name: CanaryEventsTotal, Kind: Counter, Labels: []string{"service"},
+		Help: "Synthetic canary events seen per pipeline stage",
+	},
+	{
+		Name: NotificationsResentTotal, Kind: Counter, Labels: []string{"channel", "status"},
+		Help: "Operator-triggered notification re-sends (query /admin/events/{id}/notify) by outcome",
+	},
+	{
+		Name: AlertsDeduplicatedTotal, Kind: Counter,
+		Help: "Alerts dropped by alert-consumer as repeats of an already-handled dedup token",
+	},
+	{
+		Name: IngestSignatureChecksTotal, Kind: Counter, Labels: []string{"result"},
+		Help: "Signed ingest request checks by result (valid/missing/stale/invalid/unknown_key/unreadable)",
+	},
+	{
+		Name: StaleEventsRejectedTotal, Kind: Counter,
+		Help: "Events rejected at ingest for a timestamp older than the producer's replay window",
+	},
+	{
+		Name: BatchesProcessedTotal, Kind: Counter, Labels: []string{"status"},
+		Help: "Atomic batches committed (success) or rejected as a whole (failed)",
+	},
+	{
+		Name: WebhookDeliveriesTotal, Kind: Counter, Labels: []string{"status"},
+		Help: "Producer ack webhook deliveries by outcome (delivered/failed/dropped)",
+	},
+	{
+		Name: CanaryAlertsConsumedTotal, Kind: Counter,
+		Help: "Alerts for canary events received by alert-consumer (not reported as fraud)",
+	},
+	{
+		Name: ExportsTotal, Kind: Counter, Labels: []string{"status"},
+		Help: "Event export jobs finished, by outcome (complete/failed)",
+	},
+	{
+		Name: DynamicConfigReloadsTotal, Kind: Counter, Labels: []string{"result"},
+		Help: "AppConfig tunables polls by result (applied/unchanged/rejected/error)",
+	},
+	{
+		Name: IngestDuplicatesTotal, Kind: Counter,
+		Help: "POST /events submissions rejected as repeats: within the ingest dedupe window, or of an event the idempotency table has as processing or processed",
+	},
+	{
+		Name: DBTimeoutsTotal, Kind: Counter, Labels: []string{"operation"},
+		Help: "db.Client calls that hit their read/write deadline or statement_timeout, by operation",
+	},
+	{
+		Name: SlowQueriesTotal, Kind: Counter, Labels: []string{"operation"},
+		Help: "db.Client calls slower than DB_SLOW_QUERY_MS, by operation",
+	},
+	{
+		Name: PayloadsArchivedTotal, Kind: Counter, Labels: []string{"result"},
+		Help: "Inline payloads sampled for archival to object storage, by result (archived/failed at ingest, retried/retry_failed from archive_outbox)",
+	},
+	{
+		Name: InlineOverflowTotal, Kind: Counter,
+		Help: "Payloads under the inline limit offloaded because the marshaled queue message exceeded domain.MaxQueueMessageBytes",
+	},
+	{
+		Name: RetryJobsTotal, Kind: Counter, Labels: []string{"status"},
+		Help: "Bulk retry jobs finished, by outcome (complete/failed)",
+	},
+	{
+		Name: IngestDeadlineExceededTotal, Kind: Counter, Labels: []string{"step"},
+		Help: "Ingest requests answered 504 because a step (persist_storage/enqueue) ran past INGEST_REQUEST_BUDGET_MS",
+	},
+	{
+		Name: IngestPublishRetriesTotal, Kind: Counter, Labels: []string{"reason"},
+		Help: "Ingest publishes retried after a transient broker failure, by reason (queue_throttled/queue_unavailable/queue_timeout/queue_error)",
+	},
+	{
+		Name: IngestEnqueueFailuresTotal, Kind: Counter, Labels: []string{"class"},
+		Help: "Ingest requests whose payload could not be stored or published (budget overruns aside), by class (throttled: answered 429, error: answered 500)",
+	},
+	{
+		Name: IngestCompressedBodiesTotal, Kind: Counter, Labels: []string{"result"},
+		Help: "Gzip-encoded ingest request bodies by result (ok/too_large/invalid)",
+	},
+	{
+		Name: IngestRateLimitedTotal, Kind: Counter,
+		Help: "Ingest requests answered 429 because their producer was over its rate limit",
+	},
+	{
+		Name: ProcessorWriteWaitSeconds, Kind: Histogram, Unit: UnitSeconds, Buckets: waitBuckets,
+		Help: "Time a message waited for its turn under PROCESSOR_DB_WRITES_PER_SECOND before the processor touched Postgres",
+	},
+	{
+		Name: RetriedEventsTotal, Kind: Counter, Labels: []string{"result"},
+		Help: "Failed events handled by bulk retry jobs, by result (enqueued/skipped: no kept message)",
+	},
+	{
+		Name: IngestLatencySeconds, Kind: Histogram, Unit: UnitSeconds, Labels: []string{"service"}, Buckets: latencyBuckets,
+		Help: "Ingest handler latency",
+	},
+	{
+		Name: ProcessLatencySeconds, Kind: Histogram, Unit: UnitSeconds, Labels: []string{"service"}, Buckets: latencyBuckets,
+		Help: "Per-message processor latency",
+	},
+	{
+		Name: FraudEvalLatencySeconds, Kind: Histogram, Unit: UnitSeconds, Labels: []string{"service"}, Buckets: latencyBuckets,
+		Help: "End-to-end gRPC fraud evaluation latency",
+	},
+	{
+		Name: IdempotencyAttempts, Kind: Histogram, Buckets: attemptBuckets,
+		Help: "Attempt number of each delivery that claimed an idempotency key",
+	},
+	{
+		Name: AmountZScore, Kind: Histogram, Labels: []string{"dimension"}, Buckets: zscoreBuckets,
+		Help: "|z| of event amounts against rolling user/merchant distributions",
+	},
+	{
+		Name: QueueMessages, Kind: Gauge, Labels: []string{"queue"},
+		Help: "Messages ready for delivery in each RabbitMQ queue, as last polled",
+	},
+	{
+		Name: QueueConsumers, Kind: Gauge, Labels: []string{"queue"},
+		Help: "Consumers attached to each RabbitMQ queue, as last polled",
+	},
+	{
+		Name: ConsumerDeliveriesTotal, Kind: Counter, Labels: []string{"queue", "outcome"},
+		Help: "Processor deliveries by how they were settled (ack/nack/discarded)",
+	},
+	{
+		Name: ConsumerRedeliveriesTotal, Kind: Counter, Labels: []string{"queue"},
+		Help: "Processor deliveries the broker had delivered before (nacked, or unacked on a lost connection)",
+	},
+	{
+		Name: ConsumerSettleFailuresTotal, Kind: Counter, Labels: []string{"queue", "op"},
+		Help: "Processor acks and nacks the broker connection rejected; the message will be redelivered",
+	},
+	{
+		Name: FinalAttemptsTotal, Kind: Counter, Labels: []string{"outcome"},
+		Help: "Processor deliveries on the last attempt PROCESSOR_MAX_ATTEMPTS allows, by outcome (processed/failed)",
+	},
+	{
+		Name: GroupsCompletedTotal, Kind: Counter,
+		Help: "Correlation groups completed (every declared member persisted)",
+	},
+	{
+		Name: AuthzDecisionsTotal, Kind: Counter, Labels: []string{"decision"},
+		Help: "Query service authorization decisions with QUERY_AUTHZ=enforce (allowed/unauthenticated/forbidden)",
+	},
+	{
+		Name: SchemaDriftTotal, Kind: Counter, Labels: []string{"kind"},
+		Help: "Sampled payload field changes per producer schema (new_schema/new_field/type_change)",
+	},
+	{
+		Name: RequestRecordingsTotal, Kind: Counter, Labels: []string{"result"},
+		Help: "Ingest requests recorded for debugging with record_requests on, by result (recorded/failed)",
+	},
+	{
+		Name: ConsumerWaitSeconds, Kind: Histogram, Unit: UnitSeconds, Labels: []string{"queue"}, Buckets: waitBuckets,
+		Help: "Time a processor consumer waited for its next delivery",
+	},
+	{
+		Name: E2ELatencySeconds, Kind: Histogram, Unit: UnitSeconds, Labels: []string{"payload_mode"}, Buckets: waitBuckets,
+		Help: "Time from ingest receiving an event to the processor persisting it, queueing and retries included",
+	},
+	{
+		Name: TenantEventsProcessedTotal, Kind: Counter, Labels: []string{"tenant", "status"},
+		Help: "Messages the processor settled, by tenant (METRICS_TENANTS) and outcome (processed/failed)",
+	},
+	{
+		Name: TenantPayloadBytes, Kind: Histogram, Unit: UnitBytes, Labels: []string{"tenant"}, Buckets: sizeBuckets,
+		Help: "Payload size of each message the processor persisted, by tenant (METRICS_TENANTS); the sum is bytes processed",
+	},
+	{
+		Name: TenantObjectsStoredTotal, Kind: Counter, Labels: []string{"tenant"},
+		Help: "Payload objects ingest uploaded to object storage, by tenant (METRICS_TENANTS); reused objects are not counted",
+	},
+	{
+		Name: ConsumerInFlight, Kind: Gauge, Labels: []string{"queue"},
+		Help: "Processor deliveries being processed, per queue",
+	},
+}
+
+// All returns every metric in the catalog.
+func All() []Def {
+	return append([]Def(nil), catalog...)
+}
+
+// Lookup returns the definition of name.
+func Lookup(name string) (Def, bool) {
+	for _, d := range catalog {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Def{}, false
+}
+
+// unitSuffixes are spellings of non-base units, which Prometheus names must not use.
+var unitSuffixes = []string{"_ms", "_millis", "_milliseconds", "_secs", "_sec", "_minutes", "_hours", "_kb", "_kilobytes", "_mb", "_megabytes"}
+
+// Validate checks d against the Prometheus naming conventions: counters end in
+// _total, durations are in seconds and end in _seconds, sizes are in bytes and end
+// in _bytes, and nothing else carries a unit suffix. Histograms need buckets.
+func (d Def) Validate() error {
+	base := strings.TrimSuffix(d.Name, "_total")
+	switch {
+	case d.Name == "" || d.Help == "":
+		return fmt.Errorf("metricdef: %q: name and help are required", d.Name)
+	case d.Kind == Counter && base == d.Name:
+		return fmt.Errorf("metricdef: counter %q must end in _total", d.Name)
+	case d.Kind == Histogram && base != d.Name:
+		return fmt.Errorf("metricdef: histogram %q must not end in _total", d.Name)
+	case d.Kind == Gauge && base != d.Name:
+		return fmt.Errorf("metricdef: gauge %q must not end in _total", d.Name)
+	case d.Kind == Histogram && len(d.Buckets) == 0:
+		return fmt.Errorf("metricdef: histogram %q has no buckets", d.Name)
+	case d.Unit == UnitSeconds && !strings.HasSuffix(base, "_seconds"):
+		return fmt.Errorf("metricdef: %q measures seconds and must end in _seconds", d.Name)
+	case d.Unit != UnitSeconds && strings.HasSuffix(base, "_seconds"):
+		return fmt.Errorf("metricdef: %q ends in _seconds but its unit is not UnitSeconds", d.Name)
+	case d.Unit == UnitBytes && !strings.HasSuffix(base, "_bytes"):
+		return fmt.Errorf("metricdef: %q measures bytes and must end in _bytes", d.Name)
+	case d.Unit != UnitBytes && strings.HasSuffix(base, "_bytes"):
+		return fmt.Errorf("metricdef: %q ends in _bytes but its unit is not UnitBytes", d.Name)
+	}
+	for _, suffix := range unitSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return fmt.Errorf("metricdef: %q uses unit suffix %s; use base units (seconds, bytes)", d.Name, suffix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package metricdef
+
+import "testing"
+
+func TestCatalog_Valid(t *testing.T) {
+	seen := map[string]bool{}
+	for _, d := range All() {
+		if err := d.Validate(); err != nil {
+			t.Error(err)
+		}
+		if seen[d.Name] {
+			t.Errorf("%s is defined twice", d.Name)
+		}
+		seen[d.Name] = true
+	}
+}
+
+func TestDef_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     Def
+		wantErr bool
+	}{
+		{"counter", Def{Name: "events_total", Kind: Counter, Help: "h"}, false},
+		{"counter without _total", Def{Name: "events", Kind: Counter, Help: "h"}, true},
+		{"seconds histogram", Def{Name: "latency_seconds", Kind: Histogram, Unit: UnitSeconds, Help: "h", Buckets: []float64{1}}, false},
+		{"seconds unit without suffix", Def{Name: "latency", Kind: Histogram, Unit: UnitSeconds, Help: "h", Buckets: []float64{1}}, true},
+		{"_seconds without unit", Def{Name: "latency_seconds", Kind: Histogram, Help: "h", Buckets: []float64{1}}, true},
+		{"bytes histogram", Def{Name: "payload_bytes", Kind: Histogram, Unit: UnitBytes, Help: "h", Buckets: []float64{1}}, false},
+		{"_bytes without unit", Def{Name: "payload_bytes", Kind: Histogram, Help: "h", Buckets: []float64{1}}, true},
+		{"kilobytes", Def{Name: "payload_kb", Kind: Histogram, Help: "h", Buckets: []float64{1}}, true},
+		{"milliseconds", Def{Name: "latency_milliseconds", Kind: Histogram, Help: "h", Buckets: []float64{1}}, true},
+		{"gauge", Def{Name: "queue_messages", Kind: Gauge, Help: "h"}, false},
+		{"gauge ending in _total", Def{Name: "queue_messages_total", Kind: Gauge, Help: "h"}, true},
+		{"histogram without buckets", Def{Name: "latency_seconds", Kind: Histogram, Unit: UnitSeconds, Help: "h"}, true},
+		{"missing help", Def{Name: "events_total", Kind: Counter}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.def.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if d, ok := Lookup(IngestLatencySeconds); !ok || d.Kind != Histogram || d.Unit != UnitSeconds {
+		t.Errorf("Lookup(%s) = %+v, %v", IngestLatencySeconds, d, ok)
+	}
+	if _, ok := Lookup("ingest_latency_milliseconds"); ok {
+		t.Error("Lookup found an uncatalogued metric")
+	}
+}
@@ -0,0 +1,42 @@
+package metricdef
+
+// OtherTenant is the tenant label of every producer outside the allowlist.
+const OtherTenant = "other"
+
+// Tenants labels the per-tenant usage metrics (TenantEventsProcessedTotal,
+// TenantPayloadBytes, TenantObjectsStoredTotal) from an allowlist of tenants.
+// Only allowlisted tenants get a label of their own; the rest, and producers with
+// no tenant, share OtherTenant, so the number of series is bounded by the
+// allowlist however many tenants and API keys there are. A nil *Tenants turns
+// the usage metrics off.
+//
+// Tenants are those of the secret bundle's api_keys (internal/config/secrets),
+// which ingest stamps on each message; the allowlist only picks which to break
+// out, so API keys map to tenants in one place.
+type Tenants struct {
+	allowed map[string]bool
+}
+
+// NewTenants returns Tenants for allowlist, the tenant names to label, or nil
+// when it is empty.
+func NewTenants(allowlist []string) *Tenants {
+	if len(allowlist) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool, len(allowlist))
+	for _, tenant := range allowlist {
+		allowed[tenant] = true
+	}
+	return &Tenants{allowed: allowed}
+}
+
+// Label returns the label of tenant, and false when the usage metrics are off.
+func (t *Tenants) Label(tenant string) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	if tenant != "" && t.allowed[tenant] {
+		return tenant, true
+	}
+	return OtherTenant, true
+}
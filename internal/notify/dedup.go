@@ -0,0 +1,78 @@
+// Package notify holds both sides of Fluxa's downstream notifications. Notifier
+// is the publishing side: the processor and admin re-sends deliver fraud alerts
+// through it, to the alerts exchange, a webhook, or nowhere (NOTIFIER_MODE).
+//
+// Every notification carries a dedup token (domain.DedupToken) derived only from
+// the event ID and notification type, so a processor redelivery or an admin
+// re-send (POST /admin/events/{id}/notify) repeats the token exactly. Publishing is
+// at-least-once; a subscriber gets exactly-once handling by dropping tokens it has
+// already handled. Deduper is that check for in-process subscribers such as
+// alert-consumer. Subscribers with their own store should key a unique constraint
+// on the token instead. Ingest uses a Deduper too, as its front-door window for
+// repeated submissions.
+package notify
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Deduper remembers recently handled tokens, bounded by count and age. A token
+// older than the TTL or evicted by capacity is treated as new, so size both to
+// cover the longest expected re-send delay. Safe for concurrent use.
+type Deduper struct {
+	mu    sync.Mutex
+	max   int
+	ttl   time.Duration
+	order *list.List // front = newest
+	seen  map[string]*list.Element
+	now   func() time.Time
+}
+
+type dedupEntry struct {
+	token string
+	at    time.Time
+}
+
+// NewDeduper remembers up to max tokens for ttl each.
+func NewDeduper(max int, ttl time.Duration) *Deduper {
+	return &Deduper{max: max, ttl: ttl, order: list.New(), seen: map[string]*list.Element{}, now: time.Now}
+}
+
+// Seen records token and reports whether it was already recorded within the TTL.
+// An empty token (a producer predating dedup tokens) is never a duplicate.
+func (d *Deduper) Seen(token string) bool {
+	if token == "" {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+
+	if el, ok := d.seen[token]; ok {
+		if now.Sub(el.Value.(*dedupEntry).at) < d.ttl {
+			return true
+		}
+		d.order.Remove(el)
+		delete(d.seen, token)
+	}
+	d.seen[token] = d.order.PushFront(&dedupEntry{token: token, at: now})
+	for d.order.Len() > d.max {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.seen, oldest.Value.(*dedupEntry).token)
+	}
+	return false
+}
+
+// Forget drops token so its next delivery is handled again; call it when handling
+// failed after Seen returned false.
+func (d *Deduper) Forget(token string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if el, ok := d.seen[token]; ok {
+		d.order.Remove(el)
+		delete(d.seen, token)
+	}
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeduper_SeenWithinTTL(t *testing.T) {
+	d := NewDeduper(10, time.Minute)
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	if d.Seen("a") {
+		t.Fatal("first delivery reported as duplicate")
+	}
+	if !d.Seen("a") {
+		t.Error("redelivery not reported as duplicate")
+	}
+	if d.Seen("") || d.Seen("") {
+		t.Error("empty token must never dedupe")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if d.Seen("a") {
+		t.Error("token older than TTL reported as duplicate")
+	}
+}
+
+func TestDeduper_EvictsOldestAtCapacity(t *testing.T) {
+	d := NewDeduper(2, time.Hour)
+	d.Seen("a")
+	d.Seen("b")
+	d.Seen("c") // evicts a
+	if d.Seen("a") {
+		t.Error("evicted token reported as duplicate")
+	}
+	if !d.Seen("c") {
+		t.Error("retained token not reported as duplicate")
+	}
+}
+
+func TestDeduper_Forget(t *testing.T) {
+	d := NewDeduper(10, time.Hour)
+	d.Seen("a")
+	d.Forget("a")
+	if d.Seen("a") {
+		t.Error("forgotten token reported as duplicate")
+	}
+}
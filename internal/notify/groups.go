@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// GroupsExchange is where group completions are published. Consumers that act on
+// complete groups (settlement) bind their own queues to it; nothing is kept for
+// consumers not bound yet.
+const GroupsExchange = "groups"
+
+// GroupNotifier announces correlation groups whose every member is persisted.
+// Delivery is at-least-once, like Notifier's.
+type GroupNotifier interface {
+	GroupComplete(ctx context.Context, msg domain.GroupCompleteMessage) error
+}
+
+var _ GroupNotifier = (*QueueGroupNotifier)(nil)
+
+// QueueGroupNotifier publishes each completion as a JSON message on the groups
+// exchange.
+type QueueGroupNotifier struct {
+	publisher ports.Publisher
+}
+
+// NewQueueGroupNotifier returns a GroupNotifier publishing through p.
+func NewQueueGroupNotifier(p ports.Publisher) *QueueGroupNotifier {
+	return &QueueGroupNotifier{publisher: p}
+}
+
+// GroupComplete implements GroupNotifier.
+func (n *QueueGroupNotifier) GroupComplete(ctx context.Context, msg domain.GroupCompleteMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("notify: marshal group completion %s: %w", msg.GroupID, err)
+	}
+	if err := n.publisher.Publish(ctx, GroupsExchange, "", body); err != nil {
+		return fmt.Errorf("notify: publish group completion %s: %w", msg.GroupID, err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// Notifier modes, selected by NOTIFIER_MODE.
+const (
+	ModeQueue   = "queue"   // the alerts exchange (default)
+	ModeWebhook = "webhook" // one JSON POST per call to NOTIFIER_WEBHOOK_URL
+	ModeNoop    = "noop"    // drop alerts; local runs and test harnesses
+)
+
+// AlertsExchange is where queue mode publishes, and what alert-consumer reads.
+const AlertsExchange = "alerts"
+
+// Notifier delivers fraud alerts downstream. Delivery is at-least-once: a
+// Notifier may repeat an alert after a partial failure, and subscribers dedupe on
+// AlertMessage.DedupToken.
+type Notifier interface {
+	Notify(ctx context.Context, alerts []domain.AlertMessage) error
+}
+
+var (
+	_ Notifier = (*QueueNotifier)(nil)
+	_ Notifier = (*WebhookNotifier)(nil)
+	_ Notifier = Noop{}
+)
+
+// QueueNotifier publishes each alert as a JSON message on the alerts exchange, in
+// PublishBatch calls of up to ports.MaxPublishBatch when the Publisher supports
+// it, else one at a time. A failed batch doesn't stop the rest; all errors are
+// returned together.
+type QueueNotifier struct {
+	publisher ports.Publisher
+}
+
+// NewQueueNotifier returns a Notifier publishing through p.
+func NewQueueNotifier(p ports.Publisher) *QueueNotifier {
+	return &QueueNotifier{publisher: p}
+}
+
+// Notify implements Notifier.
+func (n *QueueNotifier) Notify(ctx context.Context, alerts []domain.AlertMessage) error {
+	bodies := make([][]byte, 0, len(alerts))
+	for _, a := range alerts {
+		body, err := json.Marshal(a)
+		if err != nil {
+			return fmt.Errorf("notify: marshal alert %s: %w", a.FlagID, err)
+		}
+		bodies = append(bodies, body)
+	}
+
+	var errs []error
+	bp, ok := n.publisher.(ports.BatchPublisher)
+	if !ok {
+		for i, body := range bodies {
+			if err := n.publisher.Publish(ctx, AlertsExchange, "", body); err != nil {
+				errs = append(errs, fmt.Errorf("notify: publish alert %s: %w", alerts[i].FlagID, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	for start := 0; start < len(bodies); start += ports.MaxPublishBatch {
+		end := start + ports.MaxPublishBatch
+		if end > len(bodies) {
+			end = len(bodies)
+		}
+		if err := bp.PublishBatch(ctx, AlertsExchange, "", bodies[start:end]); err != nil {
+			errs = append(errs, fmt.Errorf("notify: publish batch of %d alerts: %w", end-start, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WebhookNotifier POSTs each call's alerts as one JSON array to URL. Any status
+// outside 2xx is an error.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier returns a Notifier posting to url with client.
+func NewWebhookNotifier(url string, client *http.Client) *WebhookNotifier {
+	return &WebhookNotifier{url: url, client: client}
+}
+
+// Notify implements Notifier.
+func (n *WebhookNotifier) Notify(ctx context.Context, alerts []domain.AlertMessage) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return fmt.Errorf("notify: marshal alerts: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("notify: build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify: webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notify: webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Noop drops every alert.
+type Noop struct{}
+
+// Notify implements Notifier.
+func (Noop) Notify(context.Context, []domain.AlertMessage) error { return nil }
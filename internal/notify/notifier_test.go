@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+)
+
+func testAlerts(n int) []domain.AlertMessage {
+	alerts := make([]domain.AlertMessage, n)
+	for i := range alerts {
+		alerts[i] = domain.AlertMessage{FlagID: string(rune('a' + i)), EventID: "evt-1", RuleName: "velocity"}
+	}
+	return alerts
+}
+
+func TestQueueNotifier_PublishesInBatches(t *testing.T) {
+	q := fluxatest.NewQueue()
+	if err := NewQueueNotifier(q).Notify(context.Background(), testAlerts(12)); err != nil {
+		t.Fatal(err)
+	}
+	if got := q.Batches(); len(got) != 2 || got[0] != 10 || got[1] != 2 {
+		t.Errorf("batches = %v, want [10 2]", got)
+	}
+	published := q.Published(AlertsExchange)
+	var first domain.AlertMessage
+	if len(published) != 12 || json.Unmarshal(published[0].Body, &first) != nil || first.FlagID != "a" {
+		t.Errorf("published %d alerts, first %+v", len(published), first)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got []domain.AlertMessage
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL, srv.Client())
+	if err := n.Notify(context.Background(), testAlerts(3)); err != nil || len(got) != 3 {
+		t.Errorf("Notify = %v, server got %d alerts; want nil, 3", err, len(got))
+	}
+	status = http.StatusBadGateway
+	if err := n.Notify(context.Background(), testAlerts(1)); err == nil {
+		t.Error("Notify succeeded against a 502")
+	}
+}
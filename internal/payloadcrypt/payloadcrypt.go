@@ -0,0 +1,159 @@
+// Package payloadcrypt seals the inline payloads of sensitive events
+// (domain.Event.Sensitive) so they are not queued in plaintext. It follows the
+// envelope scheme of a KMS data key: each payload is encrypted with a fresh
+// AES-256-GCM data key, and the data key is wrapped with a long-lived key from a
+// Keyring and carried in the message (domain.PayloadEncryption).
+//
+// The keyring is a JSON file (PAYLOAD_ENCRYPTION_KEYS_FILE) that ingest and the
+// processor both read:
+//
+//	{"active": "2026-10", "keys": {"2026-10": "<base64 32 bytes>", "2026-04": "…"}}
+//
+// Ingest seals with the active key; the processor opens with whichever key a
+// message names, so a retired key stays in the file until the messages sealed
+// with it have drained.
+package payloadcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// ErrOpen is returned for a payload that can't be opened: an unknown key, a
+// malformed envelope, or ciphertext that fails authentication.
+var ErrOpen = errors.New("payloadcrypt: cannot open payload")
+
+// Keyring holds the keys that wrap data keys, by ID.
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// keyFile is the PAYLOAD_ENCRYPTION_KEYS_FILE shape.
+type keyFile struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+}
+
+// Load reads a keyring file.
+func Load(path string) (*Keyring, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("payloadcrypt: read keys: %w", err)
+	}
+	var f keyFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("payloadcrypt: parse keys %s: %w", path, err)
+	}
+	keys := make(map[string][]byte, len(f.Keys))
+	for id, encoded := range f.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("payloadcrypt: key %q is not base64: %w", id, err)
+		}
+		keys[id] = key
+	}
+	return New(f.Active, keys)
+}
+
+// New returns a keyring sealing with keys[active]. Keys must be 32 bytes.
+func New(active string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[active]; !ok || active == "" {
+		return nil, fmt.Errorf("payloadcrypt: active key %q is not in the keyring", active)
+	}
+	k := &Keyring{active: active, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("payloadcrypt: key %q must be 32 bytes, got %d", id, len(key))
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// Seal encrypts plain under a fresh data key, bound to aad (the event ID), and
+// returns the wrapped data key and the sealed payload.
+func (k *Keyring) Seal(plain, aad []byte) (*domain.PayloadEncryption, []byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("payloadcrypt: generate data key: %w", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed, err := seal(data, plain, aad)
+	if err != nil {
+		return nil, nil, err
+	}
+	wrapped, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &domain.PayloadEncryption{KeyID: k.active, DataKey: base64.StdEncoding.EncodeToString(wrapped)}, sealed, nil
+}
+
+// Open decrypts a payload Seal produced with the same aad, or returns ErrOpen.
+func (k *Keyring) Open(enc *domain.PayloadEncryption, sealed, aad []byte) ([]byte, error) {
+	kek, ok := k.keys[enc.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrOpen, enc.KeyID)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(enc.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: data key is not base64", ErrOpen)
+	}
+	dataKey, err := open(kek, wrapped, []byte(enc.KeyID))
+	if err != nil || len(dataKey) != 32 {
+		return nil, fmt.Errorf("%w: data key does not unwrap", ErrOpen)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := open(data, sealed, aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload fails authentication", ErrOpen)
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("payloadcrypt: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("payloadcrypt: %w", err)
+	}
+	return aead, nil
+}
+
+// seal returns nonce || ciphertext.
+func seal(aead cipher.AEAD, plain, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("payloadcrypt: nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plain, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrOpen
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], aad)
+}
@@ -0,0 +1,78 @@
+package payloadcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+func testKey(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+
+func TestSealOpen_RoundTripAcrossRotation(t *testing.T) {
+	old, err := New("k1", map[string][]byte{"k1": testKey(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, sealed, err := old.Seal([]byte(`{"amount":10}`), []byte("evt-1"))
+	if err != nil {
+		t.Fatalf("Seal = %v", err)
+	}
+	if bytes.Contains(sealed, []byte("amount")) {
+		t.Fatal("sealed payload contains the plaintext")
+	}
+
+	// After rotation, messages sealed under k1 still open.
+	rotated, err := New("k2", map[string][]byte{"k1": testKey(1), "k2": testKey(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := rotated.Open(enc, sealed, []byte("evt-1"))
+	if err != nil || string(plain) != `{"amount":10}` {
+		t.Errorf("Open = %q, %v; want the plaintext", plain, err)
+	}
+}
+
+func TestOpen_Rejects(t *testing.T) {
+	k, _ := New("k1", map[string][]byte{"k1": testKey(1)})
+	enc, sealed, _ := k.Seal([]byte("payload"), []byte("evt-1"))
+
+	other, _ := New("k2", map[string][]byte{"k2": testKey(2)})
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	tests := map[string]func() error{
+		"other event": func() error { _, err := k.Open(enc, sealed, []byte("evt-2")); return err },
+		"unknown key": func() error { _, err := other.Open(enc, sealed, []byte("evt-1")); return err },
+		"tampered":    func() error { _, err := k.Open(enc, tampered, []byte("evt-1")); return err },
+		"bad data key": func() error {
+			_, err := k.Open(&domain.PayloadEncryption{KeyID: enc.KeyID, DataKey: "AAAA"}, sealed, []byte("evt-1"))
+			return err
+		},
+	}
+	for name, open := range tests {
+		if err := open(); !errors.Is(err, ErrOpen) {
+			t.Errorf("%s: Open = %v, want ErrOpen", name, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	key := base64.StdEncoding.EncodeToString(testKey(3))
+	if err := os.WriteFile(path, []byte(`{"active":"k3","keys":{"k3":"`+key+`"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err != nil {
+		t.Errorf("Load = %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"active":"k4","keys":{"k3":"`+key+`"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load accepted an active key missing from the keyring")
+	}
+}
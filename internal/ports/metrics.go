@@ -5,4 +5,5 @@ package ports
 type Metrics interface {
 	IncCounter(name string, labels ...string)
 	ObserveHistogram(name string, value float64, labels ...string)
+	SetGauge(name string, value float64, labels ...string)
 }
@@ -8,6 +8,18 @@ type Publisher interface {
 	Close() error
 }
 
+// MaxPublishBatch is the most bodies one PublishBatch call takes: SNS
+// PublishBatch's limit, so a cloud adapter can map a call to one request.
+const MaxPublishBatch = 10
+
+// BatchPublisher is a Publisher that can send several messages to one exchange
+// in a single call. Callers chunk to MaxPublishBatch and fall back to Publish
+// when their Publisher doesn't implement it.
+type BatchPublisher interface {
+	Publisher
+	PublishBatch(ctx context.Context, exchange, routingKey string, bodies [][]byte) error
+}
+
 // Consumer receives messages from a named queue.
 type Consumer interface {
 	Consume(ctx context.Context, queue string) (<-chan Delivery, error)
@@ -19,4 +31,6 @@ type Delivery interface {
 	Body() []byte
 	Ack() error
 	Nack(requeue bool) error
+	// Redelivered reports whether the broker delivered the message before.
+	Redelivered() bool
 }
@@ -1,9 +1,27 @@
 package ports
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Storage abstracts object store operations (MinIO or S3-compatible).
 type Storage interface {
 	Put(ctx context.Context, key string, data []byte) error
 	Get(ctx context.Context, key string) ([]byte, error)
+	// Exists reports whether an object is stored at key (a HEAD, no body transfer).
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// Presigner issues time-limited download URLs for stored objects. The MinIO
+// adapter implements it alongside Storage.
+type Presigner interface {
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// LimitedGetter fetches an object only if it is at most limit bytes, failing with
+// a *domain.TooLargeError otherwise, without reading the excess into memory. The
+// MinIO adapter implements it alongside Storage.
+type LimitedGetter interface {
+	GetLimited(ctx context.Context, key string, limit int64) ([]byte, error)
 }
@@ -0,0 +1,251 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/schemamigrate"
+)
+
+// processBatch handles an atomic batch (msg.Atomic). Every member must decode and
+// validate, otherwise the batch is recorded failed and none of it is stored; a
+// valid batch's events and its batch record commit in one transaction. Fraud
+// evaluation then runs per member, best-effort as for single events, and the
+// members' alerts are published together.
+//
+// A partial batch (msg.Partial) still commits in one transaction, but a member
+// that fails validation is left out and one the database rejects is rolled back
+// to its savepoint; both are reported in res.Failures, the batch record, and
+// their own failed acks, and the rest of the batch is stored. Members ingest
+// rejected (BatchPayload.Rejected) are added to the batch record only: ingest
+// already answered for them.
+func (p *Processor) processBatch(ctx context.Context, msg *domain.QueueMessage, payloadBytes []byte, res *ProcessResult) error {
+	startTime := time.Now()
+	if msg.BatchID == "" {
+		return domain.NewNonRetryableError("missing_batch_id", nil)
+	}
+
+	stageStart := time.Now()
+	events, memberErrs, ingestRejected, err := decodeMembers(msg, payloadBytes, p.validation(), p.Migrations)
+	for _, event := range events {
+		res.Members = append(res.Members, event.EventID)
+	}
+	res.events = events
+	if err != nil {
+		return p.failBatch(msg.BatchID, len(events), "", err)
+	}
+	valid, submitted := events, len(events)
+	var rejected []domain.BatchFailure
+	if msg.Partial {
+		valid, submitted = nil, len(events)+len(ingestRejected)
+		rejected = append(rejected, ingestRejected...)
+		for i, event := range events {
+			if memberErrs[i] != nil {
+				rejected = append(rejected, domain.BatchFailure{EventID: event.EventID, Reason: failureReason(memberErrs[i])})
+				continue
+			}
+			valid = append(valid, event)
+		}
+	} else if i := firstError(memberErrs); i >= 0 {
+		return p.failBatch(msg.BatchID, len(events), events[i].EventID, memberErrs[i])
+	}
+	res.timeStage(StageDecode, stageStart)
+
+	stageStart = time.Now()
+	for _, event := range valid {
+		p.prepare(ctx, event)
+	}
+	res.timeStage(StageEnrich, stageStart)
+
+	dbStart := time.Now()
+	// S3 messages reference their payload object; an inline one does too when
+	// ingest sampled its payload for archival.
+	s3Key := msg.S3Key
+	batch := &domain.Batch{
+		BatchID:   msg.BatchID,
+		Atomic:    true,
+		Partial:   msg.Partial,
+		Status:    domain.BatchStatusSuccess,
+		Submitted: submitted,
+		Processed: submitted,
+		Failures:  rejected,
+	}
+	if msg.Partial {
+		err = p.DB.InsertPartialBatch(batch, valid, msg.CorrelationID, msg.PayloadMode, s3Key)
+	} else {
+		err = p.DB.InsertEventBatch(batch, valid, msg.CorrelationID, msg.PayloadMode, s3Key)
+	}
+	var conflict *domain.ClientReferenceConflictError
+	if errors.As(err, &conflict) {
+		// Redelivery can't free the reference: fail the batch as for an invalid member.
+		return p.failBatch(msg.BatchID, len(events), conflict.EventID, domain.NewNonRetryableError("client_reference_conflict", err))
+	}
+	if err != nil {
+		p.Logger.Error("Failed to insert batch into database", err, map[string]interface{}{"batch_id": msg.BatchID})
+		p.Metrics.IncCounter(metricdef.EventsProcessedTotal, "service", "processor", "status", "failure")
+		return domain.NewRetryableError("db_insert_failed", err)
+	}
+	res.timeStage(StagePersist, dbStart)
+	persisted := valid
+	if msg.Partial {
+		persisted = withoutRejected(valid, batch.Failures[len(rejected):])
+		res.Failures = batch.Failures[len(ingestRejected):]
+		res.persisted = persisted
+	}
+	p.recordTimelines(msg, res, persisted)
+	p.trackGroups(ctx, persisted)
+
+	// Alerts for the whole batch go to the Notifier in one call, so the queue
+	// notifier can publish them in as few batches as possible.
+	stageStart = time.Now()
+	var alerts []domain.AlertMessage
+	for _, event := range persisted {
+		alerts = append(alerts, p.evaluateFraud(ctx, event)...)
+	}
+	p.publishAlerts(ctx, alerts)
+	res.timeStage(StageFraud, stageStart)
+
+	if err := p.Idempotency.MarkSuccess(msg.EventID); err != nil {
+		p.Logger.Error("Failed to mark idempotency success", err)
+		// Non-fatal: the batch is already committed
+	}
+
+	for _, event := range persisted {
+		p.Metrics.IncCounter(metricdef.EventsProcessedTotal, "service", "processor", "status", "success")
+		if event.Canary {
+			p.Metrics.IncCounter(metricdef.CanaryEventsTotal, "service", "processor")
+		}
+	}
+	for range res.Failures {
+		p.Metrics.IncCounter(metricdef.EventsProcessedTotal, "service", "processor", "status", "failure")
+	}
+	p.Metrics.IncCounter(metricdef.BatchesProcessedTotal, "status", string(batch.Status))
+	latency := time.Since(startTime).Seconds()
+	p.Metrics.ObserveHistogram(metricdef.ProcessLatencySeconds, latency, "service", "processor")
+	p.Logger.Info("Successfully processed atomic batch", map[string]interface{}{
+		"batch_id":   msg.BatchID,
+		"events":     len(persisted),
+		"failed":     batch.Failed,
+		"latency_ms": latency * 1000,
+	})
+	return nil
+}
+
+// withoutRejected returns events minus those whose IDs the database rejected.
+func withoutRejected(events []*domain.Event, rejected []domain.BatchFailure) []*domain.Event {
+	if len(rejected) == 0 {
+		return events
+	}
+	drop := make(map[string]bool, len(rejected))
+	for _, f := range rejected {
+		drop[f.EventID] = true
+	}
+	kept := make([]*domain.Event, 0, len(events)-len(rejected))
+	for _, event := range events {
+		if !drop[event.EventID] {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
+
+// failBatch records a batch that will never succeed and returns cause, which is
+// non-retryable. If the record itself can't be written the delivery is retried
+// instead, so the producer never sees a batch stuck without a status.
+func (p *Processor) failBatch(batchID string, eventCount int, failedEventID string, cause error) error {
+	batch := &domain.Batch{
+		BatchID:       batchID,
+		Atomic:        true,
+		Status:        domain.BatchStatusFailed,
+		Submitted:     eventCount,
+		Failed:        eventCount,
+		FailedEventID: failedEventID,
+		ErrorReason:   failureReason(cause),
+	}
+	if err := p.DB.SaveBatch(batch); err != nil {
+		p.Logger.Error("Failed to record failed batch", err, map[string]interface{}{"batch_id": batchID})
+		return domain.NewRetryableError("batch_record_failed", err)
+	}
+	p.Metrics.IncCounter(metricdef.BatchesProcessedTotal, "status", string(domain.BatchStatusFailed))
+	p.Logger.Warn("Atomic batch rejected", map[string]interface{}{
+		"batch_id":        batchID,
+		"failed_event_id": failedEventID,
+		"reason":          batch.ErrorReason,
+	})
+	return cause
+}
+
+// decodeMembers checks payloadBytes against the envelope's hash and decodes,
+// upgrades (with migrations), normalizes, and validates (with vc) every member of
+// a BatchPayload. It returns all decoded members even when some fail:
+// memberErrs[i] is why events[i] is invalid, or nil. rejected is the members
+// ingest turned away before enqueueing. err is set, and events nil, only when the
+// batch as a whole is unreadable. All failures are non-retryable.
+func decodeMembers(msg *domain.QueueMessage, payloadBytes []byte, vc domain.ValidationConfig, migrations *schemamigrate.Registry) (events []*domain.Event, memberErrs []error, rejected []domain.BatchFailure, err error) {
+	hash := sha256.Sum256(payloadBytes)
+	if hex.EncodeToString(hash[:]) != msg.PayloadSHA256 {
+		return nil, nil, nil, domain.NewNonRetryableError("hash_mismatch", nil)
+	}
+	if msg.ContentType != "" && msg.ContentType != domain.ContentTypeJSON {
+		return nil, nil, nil, domain.NewNonRetryableError("unsupported_content_type", nil)
+	}
+
+	var payload domain.BatchPayload
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return nil, nil, nil, domain.NewNonRetryableError("unmarshal_error", err)
+	}
+	switch n := len(payload.Events); {
+	case n == 0:
+		return nil, nil, nil, domain.NewNonRetryableError("empty_batch", nil)
+	case n > domain.MaxBatchEvents:
+		return nil, nil, nil, domain.NewNonRetryableError("batch_too_large", nil)
+	}
+
+	now := time.Now()
+	events = make([]*domain.Event, len(payload.Events))
+	memberErrs = make([]error, len(payload.Events))
+	seen := make(map[string]bool, len(payload.Events))
+	seenRefs := map[string]bool{}
+	for i := range payload.Events {
+		event := &payload.Events[i]
+		_, upgradeErr := migrations.UpgradeEvent(event)
+		event.Normalize()
+		event.ProducerKey = msg.ProducerKey
+		events[i] = event
+		switch {
+		case upgradeErr != nil:
+			memberErrs[i] = domain.NewNonRetryableError("schema_migration_failed", upgradeErr)
+		case event.EventID == "":
+			memberErrs[i] = domain.NewNonRetryableError("missing_event_id", nil)
+		case seen[event.EventID]:
+			memberErrs[i] = domain.NewNonRetryableError("duplicate_event_id", nil)
+		case event.ClientReference != "" && seenRefs[event.ClientReference]:
+			memberErrs[i] = domain.NewNonRetryableError("duplicate_client_reference", nil)
+		default:
+			if verr := event.ValidateWith(vc, now); verr != nil {
+				memberErrs[i] = domain.NewNonRetryableError("validation_error", verr)
+			}
+		}
+		seen[event.EventID] = true
+		if event.ClientReference != "" {
+			seenRefs[event.ClientReference] = true
+		}
+	}
+	return events, memberErrs, payload.Rejected, nil
+}
+
+// firstError returns the index of the first non-nil error, or -1.
+func firstError(errs []error) int {
+	for i, err := range errs {
+		if err != nil {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,255 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+func TestProcessorFake_AtomicBatchCommitsAll(t *testing.T) {
+	rules := &domain.RulesConfig{AmountThreshold: 1000}
+	p, d := newFakeProcessor(rules)
+	acks := &recordingAcks{}
+	p.Acks = acks
+	msg := fluxatest.BatchEnvelope("b-1", nil,
+		fluxatest.NewEvent("evt-b1").Build(),
+		fluxatest.NewEvent("evt-b2").Amount(5000).Build(),
+		fluxatest.NewEvent("evt-b3").Build(),
+	)
+	msg.ProducerKey = "key-a"
+
+	res, err := p.ProcessMessage(msg)
+	if err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("ProcessMessage = %+v, %v; want processed", res, err)
+	}
+	if n := d.store.EventCount(); n != 3 {
+		t.Errorf("stored %d events, want 3", n)
+	}
+	if b := d.store.Batch("b-1"); b == nil || b.Status != domain.BatchStatusSuccess || b.Submitted != 3 || b.Processed != 3 || !b.Atomic {
+		t.Errorf("batch record = %+v, want atomic success with 3 processed", b)
+	}
+	if n := len(d.store.Flags()); n != 1 {
+		t.Errorf("stored %d fraud flags, want 1 (evt-b2)", n)
+	}
+	if got := d.idemStatus(t, domain.BatchIdempotencyKey("b-1")).Status; got != string(domain.IdempotencyStatusSuccess) {
+		t.Errorf("batch idempotency status = %q, want success", got)
+	}
+	if got := d.metrics.Counter(metricdef.EventsProcessedTotal, "processor", "success"); got != 3 {
+		t.Errorf("events_processed_total{success} = %d, want 3", got)
+	}
+	if len(acks.acks) != 3 || acks.acks[0].ack.EventID != "evt-b1" || acks.acks[2].ack.Outcome != domain.AckOutcomeProcessed {
+		t.Errorf("acks = %+v, want one processed ack per member", acks.acks)
+	}
+	for _, id := range []string{"evt-b1", "evt-b2", "evt-b3"} {
+		if tl := d.store.Timeline(id); tl == nil || !tl.ReceivedAt.Equal(msg.ReceivedAt) {
+			t.Errorf("timeline of %s = %+v, want the batch's received_at", id, tl)
+		}
+	}
+
+	if res, _ := p.ProcessMessage(msg); res.Outcome != OutcomeDuplicate {
+		t.Errorf("redelivery outcome = %q, want duplicate", res.Outcome)
+	}
+}
+
+func TestProcessorFake_PartialBatchFailsMembersAlone(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	acks := &recordingAcks{}
+	p.Acks = acks
+	d.store.RejectEvents = map[string]bool{"evt-p3": true}
+	msg := fluxatest.BatchEnvelope("b-partial", nil,
+		fluxatest.NewEvent("evt-p1").Build(),
+		fluxatest.NewEvent("evt-p2").Currency("").Build(),
+		fluxatest.NewEvent("evt-p3").Build(),
+		fluxatest.NewEvent("evt-p4").Build(),
+	)
+	msg.Partial = true
+	msg.ProducerKey = "key-a"
+
+	res, err := p.ProcessMessage(msg)
+	if err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("ProcessMessage = %+v, %v; want processed", res, err)
+	}
+	want := []domain.BatchFailure{{EventID: "evt-p2", Reason: "validation_error"}, {EventID: "evt-p3", Reason: "db_rejected"}}
+	if len(res.Failures) != 2 || res.Failures[0] != want[0] || res.Failures[1] != want[1] {
+		t.Errorf("Failures = %+v, want %+v", res.Failures, want)
+	}
+	for _, id := range []string{"evt-p1", "evt-p4"} {
+		if d.store.Event(id) == nil || d.store.Timeline(id) == nil {
+			t.Errorf("%s not stored with a timeline", id)
+		}
+	}
+	for _, id := range []string{"evt-p2", "evt-p3"} {
+		if d.store.Event(id) != nil || d.store.Timeline(id) != nil {
+			t.Errorf("failed member %s was stored", id)
+		}
+	}
+	b := d.store.Batch("b-partial")
+	if b == nil || b.Status != domain.BatchStatusComplete || !b.Partial || b.Submitted != 4 || b.Processed != 2 || b.Failed != 2 {
+		t.Errorf("batch record = %+v, want complete partial with 2 of 4 processed", b)
+	}
+	outcomes := map[string]string{}
+	for _, a := range acks.acks {
+		outcomes[a.ack.EventID] = a.ack.Outcome + "/" + a.ack.Reason
+	}
+	wantAcks := map[string]string{"evt-p1": "processed/", "evt-p2": "failed/validation_error", "evt-p3": "failed/db_rejected", "evt-p4": "processed/"}
+	if len(acks.acks) != 4 || len(outcomes) != 4 {
+		t.Errorf("acks = %+v, want one per member", acks.acks)
+	}
+	for id, w := range wantAcks {
+		if outcomes[id] != w {
+			t.Errorf("ack for %s = %q, want %q", id, outcomes[id], w)
+		}
+	}
+	if got := d.metrics.Counter(metricdef.BatchesProcessedTotal, "complete"); got != 1 {
+		t.Errorf("batches_processed_total{complete} = %d, want 1", got)
+	}
+}
+
+func TestProcessorFake_PartialBatchWithNothingPersistedFails(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	acks := &recordingAcks{}
+	p.Acks = acks
+	raw, err := json.Marshal(domain.BatchPayload{
+		Events:   []domain.Event{*fluxatest.NewEvent("evt-s1").Currency("").Build()},
+		Rejected: []domain.BatchFailure{{EventID: "evt-s0", Reason: "validation_error"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := fluxatest.Envelope(domain.BatchIdempotencyKey("b-stale"), raw, nil)
+	msg.BatchID, msg.Atomic, msg.Partial, msg.ProducerKey = "b-stale", true, true, "key-a"
+
+	if res, err := p.ProcessMessage(msg); err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("ProcessMessage = %+v, %v; want processed", res, err)
+	}
+	b := d.store.Batch("b-stale")
+	if b == nil || b.Status != domain.BatchStatusFailed || b.Submitted != 2 || b.Processed != 0 || b.Failed != 2 {
+		t.Fatalf("batch record = %+v, want failed with 0 of 2 processed", b)
+	}
+	if len(b.Failures) != 2 || b.Failures[0].EventID != "evt-s0" || b.Failures[1].EventID != "evt-s1" {
+		t.Errorf("Failures = %+v, want the ingest reject and the invalid member", b.Failures)
+	}
+	// Ingest already answered for evt-s0.
+	if len(acks.acks) != 1 || acks.acks[0].ack.EventID != "evt-s1" {
+		t.Errorf("acks = %+v, want one for evt-s1", acks.acks)
+	}
+}
+
+func TestProcessorFake_AtomicBatchRejectsAll(t *testing.T) {
+	tests := []struct {
+		name       string
+		msg        func() *domain.QueueMessage
+		wantReason string
+		wantFailed string
+	}{
+		{"invalid member", func() *domain.QueueMessage {
+			return fluxatest.BatchEnvelope("b-bad", nil,
+				fluxatest.NewEvent("evt-ok").Build(),
+				fluxatest.NewEvent("evt-bad").Currency("").Build(),
+			)
+		}, "validation_error", "evt-bad"},
+		{"duplicate member", func() *domain.QueueMessage {
+			return fluxatest.BatchEnvelope("b-bad", nil,
+				fluxatest.NewEvent("evt-dup").Build(),
+				fluxatest.NewEvent("evt-dup").Build(),
+			)
+		}, "duplicate_event_id", "evt-dup"},
+		{"duplicate client reference", func() *domain.QueueMessage {
+			return fluxatest.BatchEnvelope("b-bad", nil,
+				fluxatest.NewEvent("evt-a").Ref("order-7").Build(),
+				fluxatest.NewEvent("evt-b").Ref("order-7").Build(),
+			)
+		}, "duplicate_client_reference", "evt-b"},
+		{"hash mismatch", func() *domain.QueueMessage {
+			msg := fluxatest.BatchEnvelope("b-bad", nil, fluxatest.NewEvent("evt-ok").Build())
+			msg.PayloadSHA256 = "bad-hash"
+			return msg
+		}, "hash_mismatch", ""},
+		{"empty batch", func() *domain.QueueMessage {
+			return fluxatest.BatchEnvelope("b-bad", nil)
+		}, "empty_batch", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, d := newFakeProcessor(nil)
+			res, err := p.ProcessMessage(tt.msg())
+			if err != nil || res.Outcome != OutcomeFailed || res.Reason != tt.wantReason {
+				t.Fatalf("ProcessMessage = %+v, %v; want ACKed failure %q", res, err, tt.wantReason)
+			}
+			if n := d.store.EventCount(); n != 0 {
+				t.Errorf("failed batch persisted %d events", n)
+			}
+			b := d.store.Batch("b-bad")
+			if b == nil || b.Status != domain.BatchStatusFailed || b.ErrorReason != tt.wantReason || b.FailedEventID != tt.wantFailed {
+				t.Errorf("batch record = %+v, want failed/%s at %q", b, tt.wantReason, tt.wantFailed)
+			}
+			if got := d.idemStatus(t, domain.BatchIdempotencyKey("b-bad")).Status; got != string(domain.IdempotencyStatusFailed) {
+				t.Errorf("batch idempotency status = %q, want failed", got)
+			}
+		})
+	}
+}
+
+func TestProcessorFake_AtomicBatchRetriesTransientErrors(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	d.store.InsertEventErr = errors.New("deadlock")
+	msg := fluxatest.BatchEnvelope("b-r", nil, fluxatest.NewEvent("evt-r1").Build(), fluxatest.NewEvent("evt-r2").Build())
+
+	if res, err := p.ProcessMessage(msg); err == nil || res.Outcome != OutcomeRetry {
+		t.Fatalf("ProcessMessage = %+v, %v; want NACKed retry", res, err)
+	}
+	if d.store.Batch("b-r") != nil || d.store.EventCount() != 0 {
+		t.Error("transient failure left a partial batch behind")
+	}
+}
+
+func TestProcessorFake_BatchMembersTrackProgress(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	member := func(id string, payload []byte) *domain.QueueMessage {
+		msg := fluxatest.InlineEnvelope(id, payload)
+		msg.BatchID, msg.BatchSize = "b-n", 3
+		return msg
+	}
+	ok1 := member("evt-n1", fluxatest.NewEvent("evt-n1").Payload())
+	bad := member("evt-n2", fluxatest.NewEvent("evt-n2").Payload())
+	bad.PayloadSHA256 = "bad-hash"
+	ok3 := member("evt-n3", fluxatest.NewEvent("evt-n3").Payload())
+
+	_, _ = p.ProcessMessage(ok1)
+	_, _ = p.ProcessMessage(ok1) // duplicate: not counted again
+	_, _ = p.ProcessMessage(bad)
+	b := d.store.Batch("b-n")
+	if b == nil || b.Atomic || b.Status != domain.BatchStatusProcessing || b.Submitted != 3 || b.Processed != 1 || b.Failed != 1 {
+		t.Fatalf("batch after 2 of 3 = %+v, want processing 1/1 of 3", b)
+	}
+
+	_, _ = p.ProcessMessage(ok3)
+	if b := d.store.Batch("b-n"); b.Status != domain.BatchStatusComplete || b.Processed != 2 || b.Failed != 1 {
+		t.Errorf("drained batch = %+v, want complete with 2 processed, 1 failed", b)
+	}
+}
+
+func TestProcessorFake_AtomicBatchPublishesAlertsInBatches(t *testing.T) {
+	p, d := newFakeProcessor(&domain.RulesConfig{AmountThreshold: 1000})
+	var members []*domain.Event
+	for i := 0; i < 12; i++ {
+		members = append(members, fluxatest.NewEvent(fmt.Sprintf("evt-a%02d", i)).Amount(5000).Build())
+	}
+	msg := fluxatest.BatchEnvelope("b-a", nil, members...)
+
+	if res, err := p.ProcessMessage(msg); err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("ProcessMessage = %+v, %v; want processed", res, err)
+	}
+	if n := len(d.queue.Published("alerts")); n != 12 {
+		t.Errorf("published %d alerts, want 12", n)
+	}
+	if got := d.queue.Batches(); len(got) != 2 || got[0] != ports.MaxPublishBatch || got[1] != 2 {
+		t.Errorf("PublishBatch sizes = %v, want [%d 2]", got, ports.MaxPublishBatch)
+	}
+}
@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// envelopeDir holds the golden envelopes written by the domain contract tests
+// (internal/domain/message_contract_test.go).
+const envelopeDir = "../domain/testdata/envelopes"
+
+// TestDecodeEvent_EnvelopeContract runs the consumer-side decode over every golden
+// envelope, old versions included, so a processor change that can no longer read
+// messages still in the queue fails before deploy.
+func TestDecodeEvent_EnvelopeContract(t *testing.T) {
+	all, err := filepath.Glob(filepath.Join(envelopeDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for _, path := range all {
+		if strings.HasSuffix(path, ".object.json") {
+			continue
+		}
+		n++
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var msg domain.QueueMessage
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				t.Fatalf("unmarshal envelope: %v", err)
+			}
+
+			var payload []byte
+			switch msg.PayloadMode {
+			case domain.PayloadModeInline:
+				if payload, err = msg.InlinePayload(); err != nil {
+					t.Fatalf("inline payload: %v", err)
+				}
+			case domain.PayloadModeS3:
+				if payload, err = os.ReadFile(strings.TrimSuffix(path, ".json") + ".object.json"); err != nil {
+					t.Fatalf("missing S3 object fixture: %v", err)
+				}
+			default:
+				t.Fatalf("unknown payload_mode %q", msg.PayloadMode)
+			}
+
+			if msg.Atomic {
+				events, memberErrs, _, err := decodeMembers(&msg, payload, domain.ValidationConfig{}, nil)
+				if err != nil || len(events) == 0 {
+					t.Fatalf("decodeMembers = %d events, %v", len(events), err)
+				}
+				if i := firstError(memberErrs); i >= 0 {
+					t.Fatalf("member %s: %v", events[i].EventID, memberErrs[i])
+				}
+				return
+			}
+			event, err := decodeEvent(&msg, payload, domain.ValidationConfig{}, nil)
+			if err != nil {
+				t.Fatalf("decodeEvent: %v", err)
+			}
+			if event.EventID != msg.EventID {
+				t.Errorf("EventID = %q, want envelope's %q", event.EventID, msg.EventID)
+			}
+		})
+	}
+	if n == 0 {
+		t.Fatal("no envelope fixtures found")
+	}
+}
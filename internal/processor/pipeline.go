@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pipeline stage names accepted in PROCESSOR_STAGES.
+const (
+	PipelineHashVerify = "hash-verify" // payload SHA-256 against the message
+	PipelineValidate   = "validate"    // unmarshal, normalize, domain validation
+	PipelineEnrich     = "enrich"      // merchant canonicalization, profile enrichment
+	PipelineAnomaly    = "anomaly"     // amount z-scoring
+	PipelinePersist    = "persist"     // events insert (and batches)
+	PipelineFraud      = "fraud"       // rules + ML scorer, fraud_flags
+	PipelineNotify     = "notify"      // fraud alerts through the Notifier
+)
+
+// pipelineOrder is every stage, in the only order the processor can run them:
+// each stage reads what the ones before it wrote.
+var pipelineOrder = []string{
+	PipelineHashVerify, PipelineValidate, PipelineEnrich, PipelineAnomaly,
+	PipelinePersist, PipelineFraud, PipelineNotify,
+}
+
+// requiredStages guard data integrity and can't be left out.
+var requiredStages = map[string]bool{PipelineHashVerify: true, PipelineValidate: true, PipelinePersist: true}
+
+// Pipeline is the set of stages a Processor runs. The zero value runs them all.
+type Pipeline struct {
+	skip map[string]bool
+}
+
+// DefaultPipeline lists every stage, in order.
+func DefaultPipeline() []string {
+	return append([]string(nil), pipelineOrder...)
+}
+
+// ParsePipeline checks a PROCESSOR_STAGES list: every name must be a known stage,
+// listed once, in pipeline order, with the required stages present and notify
+// only after fraud (it publishes fraud's alerts). An empty list is the default.
+func ParsePipeline(stages []string) (Pipeline, error) {
+	if len(stages) == 0 {
+		return Pipeline{}, nil
+	}
+	rank := make(map[string]int, len(pipelineOrder))
+	for i, s := range pipelineOrder {
+		rank[s] = i
+	}
+	listed := map[string]bool{}
+	last := -1
+	for _, s := range stages {
+		r, ok := rank[s]
+		switch {
+		case !ok:
+			return Pipeline{}, fmt.Errorf("processor: unknown pipeline stage %q (known: %s)", s, strings.Join(pipelineOrder, ", "))
+		case listed[s]:
+			return Pipeline{}, fmt.Errorf("processor: pipeline stage %q listed twice", s)
+		case r < last:
+			return Pipeline{}, fmt.Errorf("processor: pipeline stage %q must come before %q", s, pipelineOrder[last])
+		}
+		listed[s], last = true, r
+	}
+	for _, s := range pipelineOrder {
+		if requiredStages[s] && !listed[s] {
+			return Pipeline{}, fmt.Errorf("processor: pipeline stage %q is required", s)
+		}
+	}
+	if listed[PipelineNotify] && !listed[PipelineFraud] {
+		return Pipeline{}, fmt.Errorf("processor: pipeline stage %q needs %q", PipelineNotify, PipelineFraud)
+	}
+	pl := Pipeline{skip: map[string]bool{}}
+	for _, s := range pipelineOrder {
+		if !listed[s] {
+			pl.skip[s] = true
+		}
+	}
+	return pl, nil
+}
+
+// Runs reports whether stage is part of the pipeline.
+func (pl Pipeline) Runs(stage string) bool {
+	return !pl.skip[stage]
+}
+
+// Stages lists the stages that run, in order.
+func (pl Pipeline) Stages() []string {
+	var out []string
+	for _, s := range pipelineOrder {
+		if pl.Runs(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,33 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	pl, err := ParsePipeline(nil)
+	if err != nil || !reflect.DeepEqual(pl.Stages(), DefaultPipeline()) {
+		t.Errorf("empty list = %v, %v; want every stage", pl.Stages(), err)
+	}
+
+	pl, err = ParsePipeline([]string{"hash-verify", "validate", "anomaly", "persist", "fraud"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.Runs(PipelineEnrich) || pl.Runs(PipelineNotify) || !pl.Runs(PipelineAnomaly) {
+		t.Errorf("stages = %v", pl.Stages())
+	}
+
+	for name, stages := range map[string][]string{
+		"unknown":          {"hash-verify", "validate", "persist", "sinks"},
+		"out of order":     {"hash-verify", "validate", "persist", "enrich"},
+		"duplicate":        {"hash-verify", "validate", "persist", "persist"},
+		"missing required": {"hash-verify", "persist"},
+		"notify alone":     {"hash-verify", "validate", "persist", "notify"},
+	} {
+		if _, err := ParsePipeline(stages); err == nil {
+			t.Errorf("%s: ParsePipeline(%v) accepted it", name, stages)
+		}
+	}
+}
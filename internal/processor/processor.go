@@ -5,46 +5,343 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
+	"github.com/fluxa/fluxa/internal/anomaly"
 	"github.com/fluxa/fluxa/internal/db"
 	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/dynconfig"
+	"github.com/fluxa/fluxa/internal/enrichment"
+	"github.com/fluxa/fluxa/internal/eventcodec"
+	"github.com/fluxa/fluxa/internal/featureflags"
 	"github.com/fluxa/fluxa/internal/fraud"
 	"github.com/fluxa/fluxa/internal/idempotency"
 	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/merchant"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/notify"
+	"github.com/fluxa/fluxa/internal/payloadcrypt"
 	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/fluxa/fluxa/internal/ratelimit"
+	"github.com/fluxa/fluxa/internal/schemadrift"
+	"github.com/fluxa/fluxa/internal/schemamigrate"
+)
+
+// Store is the persistence the processor needs: event/flag writes plus the
+// aggregates the fraud engine queries. *db.Client implements it; fluxatest.Store
+// is the in-memory fake.
+type Store interface {
+	InsertEvent(event *domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) error
+	InsertFraudFlag(flag *domain.FraudFlag) error
+	InsertEventBatch(batch *domain.Batch, events []*domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) error
+	InsertPartialBatch(batch *domain.Batch, events []*domain.Event, correlationID string, payloadMode domain.PayloadMode, s3Key *string) error
+	SaveBatch(batch *domain.Batch) error
+	RecordBatchMember(batchID, eventID string, submitted int, status domain.IdempotencyStatus) error
+	RecordEventTimelines(timelines []domain.EventTimeline) error
+	RecordGroupMember(groupID string, expected int) (group *domain.EventGroup, completed bool, err error)
+	EventPersisted(eventID string) (bool, error)
+	ListFraudFlags(eventID string) ([]domain.FraudFlag, error)
+	fraud.EvalQuerier
+}
+
+// IdempotencyStore claims and settles event IDs. *idempotency.Client implements it;
+// fluxatest.Idempotency is the in-memory fake.
+type IdempotencyStore interface {
+	// CheckAndMarkAttempt claims eventID, returning the claim's attempt number.
+	CheckAndMarkAttempt(eventID string) (alreadyProcessed bool, attempt int, err error)
+	MarkSuccess(eventID string) error
+	// MarkFailedWithMessage keeps the failed message for a bulk retry
+	// (query POST /admin/retries).
+	MarkFailedWithMessage(eventID, errorReason string, message []byte) error
+	// Release gives up a claim left unsettled by a transient failure.
+	Release(eventID string)
+}
+
+// AckNotifier is told when an event submitted with a producer key reaches a
+// terminal outcome. Notify must not block; webhook.Dispatcher queues deliveries.
+type AckNotifier interface {
+	Notify(producerKey string, ack domain.EventAck)
+}
+
+var (
+	_ Store            = (*db.Client)(nil)
+	_ IdempotencyStore = (*idempotency.Client)(nil)
 )
 
 // Processor handles the core event processing logic.
 type Processor struct {
-	DB          *db.Client
-	Idempotency *idempotency.Client
+	DB          Store
+	Idempotency IdempotencyStore
 	Storage     ports.Storage   // MinIO adapter
-	Publisher   ports.Publisher // RabbitMQ adapter (alerts exchange)
+	Notifier    notify.Notifier // fraud alerts; nil drops them (useful in tests)
 	Fraud       *fraud.Engine
-	Scorer      fraud.Scorer // optional ML scorer; nil => rules-only (fail-open)
-	Metrics     ports.Metrics
-	Logger      *logging.Logger
+	Scorer      fraud.Scorer            // optional ML scorer; nil => rules-only (fail-open)
+	Merchants   *merchant.Canonicalizer // optional; nil => canonical merchant is the raw descriptor
+	Enricher    enrichment.Provider     // optional; nil => no user-profile enrichment
+	// EnrichTimeout bounds each enrichment lookup; zero means 200ms.
+	EnrichTimeout time.Duration
+	Anomaly       *anomaly.Detector     // optional; nil => no amount z-scoring
+	Acks          AckNotifier           // optional; nil => no producer ack webhooks
+	Groups        notify.GroupNotifier  // optional; nil => no group completions
+	Drift         *schemadrift.Observer // optional; nil => no schema drift sampling
+	Metrics       ports.Metrics
+	Logger        *logging.Logger
+	// Migrations upgrades events from old schema versions before they are
+	// validated (PAYLOAD_MIGRATIONS_FILE); nil => none.
+	Migrations *schemamigrate.Registry
+	// Payloads opens sealed inline payloads (sensitive events,
+	// PAYLOAD_ENCRYPTION_KEYS_FILE); nil fails them as payload_decrypt_failed.
+	Payloads *payloadcrypt.Keyring
+	// Validation holds the timestamp tolerances; the zero value is the default drift.
+	// MaxAge should stay unset: events keep aging while queued.
+	Validation domain.ValidationConfig
+	// Flags adjusts Validation per message (strict_metadata_validation); nil
+	// means the flag defaults.
+	Flags *featureflags.Flags
+	// Tunables overrides Validation's limits from AppConfig; nil means none.
+	Tunables *dynconfig.Store
+	// Pipeline is the optional stages to run (PROCESSOR_STAGES); the zero value
+	// runs them all.
+	Pipeline Pipeline
+	// MaxAttempts is how many claims of an event ID may end in a transient failure
+	// (PROCESSOR_MAX_ATTEMPTS): on the last one the failure becomes permanent, so
+	// the message is kept for a bulk retry rather than redelivered. Zero means no
+	// limit.
+	MaxAttempts int
+	// MaxPayloadBytes bounds a payload, inline or stored
+	// (PROCESSOR_MAX_PAYLOAD_BYTES); a larger one fails permanently as
+	// payload_too_large. A stored one is checked before it is read, when Storage
+	// implements ports.LimitedGetter. Zero means no limit.
+	MaxPayloadBytes int64
+	// Writes paces messages toward Postgres (PROCESSOR_DB_WRITES_PER_SECOND), so
+	// draining a backlog after an outage doesn't saturate the database. Each
+	// message takes a token before its idempotency claim, waiting up to WriteWait;
+	// one that can't is requeued as db_rate_limited. Nil means unpaced.
+	Writes    *ratelimit.Limiter
+	WriteWait time.Duration
+	// Tenants labels the per-tenant usage metrics (METRICS_TENANTS) by the tenant
+	// ingest stamped on each message; nil leaves them off.
+	Tenants *metricdef.Tenants
+}
+
+// validation returns the tolerances for the message in hand: Validation with the
+// current tunables and flags applied.
+func (p *Processor) validation() domain.ValidationConfig {
+	return p.Flags.Validation(p.Tunables.Validation(p.Validation))
 }
 
-// ProcessMessage handles a single queue message.
-// Returns nil to ACK (including permanent failures), non-nil to NACK for retry.
-func (p *Processor) ProcessMessage(msg *domain.QueueMessage) error {
-	if err := p.process(msg); err != nil {
+// ProcessMessage handles a single queue message and reports what happened.
+// The error is nil to ACK (including permanent failures), non-nil to NACK for
+// retry; res.Ack() agrees with it.
+func (p *Processor) ProcessMessage(msg *domain.QueueMessage) (res ProcessResult, err error) {
+	startTime := time.Now()
+	res = ProcessResult{EventID: msg.EventID, Stages: map[string]time.Duration{}, dequeuedAt: startTime.UTC()}
+	defer func() { res.Total = time.Since(startTime) }()
+	defer p.recordFinalAttempt(&res)
+	defer p.recordUsage(msg, &res)
+
+	if err := p.process(msg, &res); err != nil {
+		if p.finalAttempt(res) {
+			err = p.exhausted(res, err)
+		}
+		res.Reason = failureReason(err)
 		if _, ok := err.(*domain.NonRetryableError); ok {
 			// ACK poison messages to prevent retry loops
-			return p.failPermanent(msg.EventID, err.Error())
+			res.Outcome = OutcomeFailed
+			p.ack(msg, domain.AckOutcomeFailed, res)
+			p.trackBatchMember(msg, domain.IdempotencyStatusFailed)
+			return res, p.failPermanent(msg, err.Error())
 		}
 		// NACK transient errors to trigger broker retry
 		p.Logger.Error("Transient failure, triggering retry", err)
+		if res.claimed {
+			// Only a claim this delivery holds: with advisory locks, releasing
+			// without one would drop another delivery's lock on the event.
+			p.Idempotency.Release(msg.EventID)
+		}
+		res.Outcome = OutcomeRetry
+		return res, err
+	}
+	switch res.Outcome {
+	case "":
+		res.Outcome = OutcomeProcessed
+		fallthrough
+	case OutcomeRepublished:
+		// The attempt that persisted a republished event may have stopped before
+		// acking it; the producer is told (again) now.
+		p.ack(msg, domain.AckOutcomeProcessed, res)
+		p.trackBatchMember(msg, domain.IdempotencyStatusSuccess)
+	}
+	return res, nil
+}
+
+// finalAttempt reports whether res is the last claim MaxAttempts allows.
+func (p *Processor) finalAttempt(res ProcessResult) bool {
+	return p.MaxAttempts > 0 && res.Attempt >= p.MaxAttempts
+}
+
+// exhausted turns a transient err on the final attempt into a permanent failure,
+// whose detail is the snapshot an operator needs before a bulk retry: the attempt,
+// the stages the event got through and the transient reason. Permanent errors
+// are returned as they are.
+func (p *Processor) exhausted(res ProcessResult, err error) error {
+	if _, ok := err.(*domain.NonRetryableError); ok {
 		return err
 	}
-	return nil
+	p.Logger.Warn("Final attempt failed, giving up on retries", map[string]interface{}{
+		"event_id": res.EventID,
+		"attempt":  res.Attempt,
+		"reason":   failureReason(err),
+	})
+	return domain.NewNonRetryableError("max_attempts_exceeded",
+		fmt.Errorf("attempt %d of %d, completed %s: %w", res.Attempt, p.MaxAttempts, res.completedStages(), err))
+}
+
+// recordFinalAttempt counts a final attempt's outcome in final_attempts_total.
+func (p *Processor) recordFinalAttempt(res *ProcessResult) {
+	if p.finalAttempt(*res) {
+		p.Metrics.IncCounter(metricdef.FinalAttemptsTotal, "outcome", string(res.Outcome))
+	}
+}
+
+// recordUsage counts a processed or failed message towards its producer's tenant
+// in the usage metrics, with the payload bytes of a processed one. Retries wait
+// for their final outcome; duplicates and republishes did no new work.
+func (p *Processor) recordUsage(msg *domain.QueueMessage, res *ProcessResult) {
+	tenant, ok := p.Tenants.Label(msg.Tenant)
+	if !ok || (res.Outcome != OutcomeProcessed && res.Outcome != OutcomeFailed) {
+		return
+	}
+	p.Metrics.IncCounter(metricdef.TenantEventsProcessedTotal, "tenant", tenant, "status", string(res.Outcome))
+	if res.Outcome == OutcomeProcessed {
+		p.Metrics.ObserveHistogram(metricdef.TenantPayloadBytes, float64(res.PayloadBytes), "tenant", tenant)
+	}
+}
+
+// ack reports a terminal outcome to the producer's webhook, if the message carries
+// a producer key. Duplicates are not re-acknowledged: the first delivery already was.
+func (p *Processor) ack(msg *domain.QueueMessage, outcome string, res ProcessResult) {
+	if p.Acks == nil || msg.ProducerKey == "" {
+		return
+	}
+	decoded := make(map[string]*domain.Event, len(res.events))
+	for _, e := range res.events {
+		decoded[e.EventID] = e
+	}
+	if msg.Partial && outcome == domain.AckOutcomeProcessed {
+		// The batch went through, but not every member did: ack each on its own.
+		for _, e := range res.persisted {
+			ack := domain.NewEventAck(e.EventID, outcome, "", time.Now())
+			ack.Event = e
+			p.Acks.Notify(msg.ProducerKey, ack)
+		}
+		for _, f := range res.Failures {
+			ack := domain.NewEventAck(f.EventID, domain.AckOutcomeFailed, f.Reason, time.Now())
+			ack.Event = decoded[f.EventID]
+			p.Acks.Notify(msg.ProducerKey, ack)
+		}
+		return
+	}
+	if msg.Atomic {
+		// One ack per member; the batch's own key means nothing to the producer.
+		for _, id := range res.Members {
+			ack := domain.NewEventAck(id, outcome, res.Reason, time.Now())
+			ack.Event = decoded[id]
+			p.Acks.Notify(msg.ProducerKey, ack)
+		}
+		return
+	}
+	ack := domain.NewEventAck(msg.EventID, outcome, res.Reason, time.Now())
+	ack.Canary = res.Canary
+	ack.Event = decoded[msg.EventID]
+	p.Acks.Notify(msg.ProducerKey, ack)
+}
+
+// recordTimelines stores when the just-persisted events were received, dequeued
+// and persisted, and observes each one's end-to-end latency. Best-effort: a
+// missing timeline only thins out the status endpoint, so a write failure is
+// logged and the event carries on.
+func (p *Processor) recordTimelines(msg *domain.QueueMessage, res *ProcessResult, events []*domain.Event) {
+	persistedAt := time.Now().UTC()
+	timelines := make([]domain.EventTimeline, 0, len(events))
+	for _, e := range events {
+		if !msg.ReceivedAt.IsZero() {
+			// Ingest and processor clocks may disagree by a little; never go negative.
+			e2e := max(persistedAt.Sub(msg.ReceivedAt), 0)
+			p.Metrics.ObserveHistogram(metricdef.E2ELatencySeconds, e2e.Seconds(), "payload_mode", string(msg.PayloadMode))
+		}
+		timelines = append(timelines, domain.EventTimeline{
+			EventID:     e.EventID,
+			ReceivedAt:  msg.ReceivedAt,
+			DequeuedAt:  res.dequeuedAt,
+			PersistedAt: persistedAt,
+		})
+	}
+	if err := p.DB.RecordEventTimelines(timelines); err != nil {
+		p.Logger.Error("Failed to record event timelines", err, map[string]interface{}{"event_id": msg.EventID})
+	}
+}
+
+// trackBatchMember counts a terminal outcome towards the progress of the non-atomic
+// batch msg belongs to, if any (atomic batches record themselves). Best-effort like
+// alerts and acks: the event's own outcome stands even if the count can't be written.
+func (p *Processor) trackBatchMember(msg *domain.QueueMessage, status domain.IdempotencyStatus) {
+	if msg.BatchID == "" || msg.Atomic {
+		return
+	}
+	if err := p.DB.RecordBatchMember(msg.BatchID, msg.EventID, msg.BatchSize, status); err != nil {
+		p.Logger.Error("Failed to record batch progress", err, map[string]interface{}{
+			"batch_id": msg.BatchID,
+			"event_id": msg.EventID,
+		})
+	}
+}
+
+// trackGroups counts just-persisted events towards their correlation groups and
+// announces each group one of them completed. Best-effort like batch progress: a
+// failed count is logged, and the next member (or redelivery) recounts.
+func (p *Processor) trackGroups(ctx context.Context, events []*domain.Event) {
+	done := map[string]bool{}
+	for _, e := range events {
+		if e.GroupID == "" || done[e.GroupID] {
+			continue
+		}
+		done[e.GroupID] = true
+		group, completed, err := p.DB.RecordGroupMember(e.GroupID, e.GroupSize)
+		if err != nil {
+			p.Logger.Error("Failed to record group progress", err, map[string]interface{}{
+				"group_id": e.GroupID,
+				"event_id": e.EventID,
+			})
+			continue
+		}
+		if group.Expected != e.GroupSize {
+			p.Logger.Warn("Event declares a different group size; the first one stands", map[string]interface{}{
+				"group_id":   e.GroupID,
+				"event_id":   e.EventID,
+				"group_size": e.GroupSize,
+				"expected":   group.Expected,
+			})
+		}
+		if !completed {
+			continue
+		}
+		p.Metrics.IncCounter(metricdef.GroupsCompletedTotal)
+		if p.Groups == nil {
+			continue
+		}
+		if err := p.Groups.GroupComplete(ctx, domain.NewGroupCompleteMessage(*group)); err != nil {
+			p.Logger.Error("Failed to publish group completion", err, map[string]interface{}{"group_id": e.GroupID})
+		}
+	}
 }
 
 // process encapsulates the core logic to enable cleaner error handling in ProcessMessage.
-func (p *Processor) process(msg *domain.QueueMessage) error {
+// It fills in res as stages complete.
+func (p *Processor) process(msg *domain.QueueMessage, res *ProcessResult) error {
 	startTime := time.Now()
 	ctx := context.Background()
 
@@ -52,74 +349,98 @@ func (p *Processor) process(msg *domain.QueueMessage) error {
 		"event_id": msg.EventID,
 	})
 
+	if err := p.paceWrites(ctx); err != nil {
+		return err
+	}
+
 	// Step 1: Idempotency check
-	alreadyProcessed, err := p.Idempotency.CheckAndMark(msg.EventID)
+	stageStart := time.Now()
+	alreadyProcessed, attempt, err := p.Idempotency.CheckAndMarkAttempt(msg.EventID)
+	res.timeStage(StageIdempotency, stageStart)
+	res.Attempt = attempt
 	if err != nil {
 		p.Logger.Error("Failed to check idempotency", err)
-		p.Metrics.IncCounter("events_processed_total", "service", "processor", "status", "failure")
+		p.Metrics.IncCounter(metricdef.EventsProcessedTotal, "service", "processor", "status", "failure")
 		return domain.NewRetryableError("idempotency_check_failed", err)
 	}
 	if alreadyProcessed {
 		p.Logger.Info("Event already processed, skipping", map[string]interface{}{"event_id": msg.EventID})
+		res.Outcome = OutcomeDuplicate
 		return nil
 	}
+	res.claimed = true
 
 	// Step 2: Fetch payload
-	var payloadBytes []byte
-	switch msg.PayloadMode {
-	case domain.PayloadModeInline:
-		if msg.PayloadInline == nil {
-			return domain.NewNonRetryableError("missing_payload", nil)
-		}
-		payloadBytes = []byte(*msg.PayloadInline)
-
-	case domain.PayloadModeS3:
-		if msg.S3Key == nil {
-			return domain.NewNonRetryableError("missing_s3_key", nil)
-		}
-		payloadBytes, err = p.Storage.Get(ctx, *msg.S3Key)
-		if err != nil {
-			p.Logger.Error("Failed to fetch payload from storage", err)
-			p.Metrics.IncCounter("events_processed_total", "service", "processor", "status", "failure")
-			return domain.NewRetryableError("storage_fetch_failed", err)
-		}
-
-	default:
-		return domain.NewNonRetryableError("invalid_payload_mode", nil)
+	stageStart = time.Now()
+	payloadBytes, err := p.fetchPayload(ctx, msg)
+	if err != nil {
+		return err
 	}
+	res.timeStage(StageFetch, stageStart)
+	res.PayloadBytes = len(payloadBytes)
 
-	// Step 3: Verify hash
-	hash := sha256.Sum256(payloadBytes)
-	calculatedHash := hex.EncodeToString(hash[:])
-	if calculatedHash != msg.PayloadSHA256 {
-		return domain.NewNonRetryableError("hash_mismatch", nil)
+	if msg.Atomic {
+		return p.processBatch(ctx, msg, payloadBytes, res)
 	}
 
-	// Step 4: Parse and validate event
-	var event domain.Event
-	if err := json.Unmarshal(payloadBytes, &event); err != nil {
-		return domain.NewNonRetryableError("unmarshal_error", err)
+	// Steps 3-4: Verify hash, parse and validate event
+	stageStart = time.Now()
+	event, err := decodeEvent(msg, payloadBytes, p.validation(), p.Migrations)
+	if err != nil {
+		return err
 	}
-	if err := event.Validate(); err != nil {
-		return domain.NewNonRetryableError("validation_error", err)
+	res.Canary = event.Canary
+	res.events = []*domain.Event{event}
+	res.timeStage(StageDecode, stageStart)
+
+	// A reclaimed event may have been persisted by an attempt that stopped short
+	// of settling it. Then only its notifications are outstanding; rerunning the
+	// pipeline would enrich it again and raise its fraud flags twice. If the check
+	// itself fails, the full pipeline is still safe: the insert is a no-op.
+	if attempt > 1 {
+		persisted, err := p.DB.EventPersisted(event.EventID)
+		if err != nil {
+			p.Logger.Warn("Failed to check for an earlier persist, rerunning the pipeline", map[string]interface{}{
+				"event_id": event.EventID,
+				"error":    err.Error(),
+			})
+		} else if persisted {
+			return p.republish(ctx, event, res)
+		}
 	}
-	event.EventID = msg.EventID
+
+	stageStart = time.Now()
+	p.prepare(ctx, event)
+	res.timeStage(StageEnrich, stageStart)
 
 	// Step 5: Persist to DB
 	dbStart := time.Now()
-	var s3Key *string
-	if msg.PayloadMode == domain.PayloadModeS3 {
-		s3Key = msg.S3Key
+	// S3 messages reference their payload object; an inline one does too when
+	// ingest sampled its payload for archival. One whose archival failed is
+	// queued for a retry with the row instead.
+	s3Key := msg.S3Key
+	if msg.ArchivePending && s3Key == nil && msg.PayloadMode == domain.PayloadModeInline {
+		event.PendingArchive = payloadBytes
 	}
-	if err := p.DB.InsertEvent(&event, msg.CorrelationID, msg.PayloadMode, s3Key); err != nil {
+	if err := p.DB.InsertEvent(event, msg.CorrelationID, msg.PayloadMode, s3Key); err != nil {
 		p.Logger.Error("Failed to insert event into database", err)
-		p.Metrics.IncCounter("events_processed_total", "service", "processor", "status", "failure")
+		p.Metrics.IncCounter(metricdef.EventsProcessedTotal, "service", "processor", "status", "failure")
+		var conflict *domain.ClientReferenceConflictError
+		if errors.As(err, &conflict) {
+			return domain.NewNonRetryableError("client_reference_conflict", err)
+		}
 		return domain.NewRetryableError("db_insert_failed", err)
 	}
-	p.Metrics.ObserveHistogram("process_latency_seconds", time.Since(dbStart).Seconds(), "service", "processor")
+	res.timeStage(StagePersist, dbStart)
+	p.Metrics.ObserveHistogram(metricdef.ProcessLatencySeconds, res.Stages[StagePersist].Seconds(), "service", "processor")
+	p.recordTimelines(msg, res, res.events)
+	p.trackGroups(ctx, res.events)
+	p.Drift.Observe(msg, event, payloadBytes)
 
 	// Step 5.5: Fraud evaluation (best-effort — errors do not abort the pipeline)
-	p.evaluateFraud(ctx, &event)
+	stageStart = time.Now()
+	p.publishAlerts(ctx, p.evaluateFraud(ctx, event))
+	res.timeStage(StageFraud, stageStart)
 
 	// Step 6: Mark idempotency success
 	if err := p.Idempotency.MarkSuccess(msg.EventID); err != nil {
@@ -131,28 +452,277 @@ func (p *Processor) process(msg *domain.QueueMessage) error {
 	p.Logger.Info("Successfully processed event", map[string]interface{}{
 		"event_id":   msg.EventID,
 		"latency_ms": latency * 1000,
+		"canary":     event.Canary,
 	})
-	p.Metrics.IncCounter("events_processed_total", "service", "processor", "status", "success")
-	p.Metrics.ObserveHistogram("process_latency_seconds", latency, "service", "processor")
+	p.Metrics.IncCounter(metricdef.EventsProcessedTotal, "service", "processor", "status", "success")
+	if event.Canary {
+		p.Metrics.IncCounter(metricdef.CanaryEventsTotal, "service", "processor")
+	}
+	p.Metrics.ObserveHistogram(metricdef.ProcessLatencySeconds, latency, "service", "processor")
 
 	return nil
 }
 
-// evaluateFraud runs all fraud rules and publishes alerts for any flags found.
-// Errors are logged but never propagated — the event itself is already safely persisted.
-// A nil Fraud engine or Publisher is treated as a no-op (useful in tests).
-func (p *Processor) evaluateFraud(ctx context.Context, event *domain.Event) {
-	if p.Fraud == nil {
+// republish settles an event an earlier attempt persisted but never marked done:
+// it crashed, or its success mark failed. The alerts of the event's recorded fraud
+// flags are published again, and nothing else reruns; alerts carry deterministic
+// dedup tokens, so consumers drop copies they already have. An event without
+// flags may never have reached the fraud stage, so it is evaluated now, as it
+// would have been right after persisting.
+func (p *Processor) republish(ctx context.Context, event *domain.Event, res *ProcessResult) error {
+	stageStart := time.Now()
+	flags, err := p.DB.ListFraudFlags(event.EventID)
+	if err != nil {
+		p.Logger.Error("Failed to list fraud flags", err, map[string]interface{}{"event_id": event.EventID})
+		return domain.NewRetryableError("flag_lookup_failed", err)
+	}
+	var alerts []domain.AlertMessage
+	if len(flags) == 0 {
+		p.prepare(ctx, event)
+		alerts = p.evaluateFraud(ctx, event)
+	}
+	for _, flag := range flags {
+		alerts = append(alerts, domain.NewAlertMessage(flag))
+	}
+	p.publishAlerts(ctx, alerts)
+	res.timeStage(StageFraud, stageStart)
+	p.trackGroups(ctx, res.events)
+
+	if err := p.Idempotency.MarkSuccess(event.EventID); err != nil {
+		p.Logger.Error("Failed to mark idempotency success", err)
+	}
+	p.Logger.Info("Event already persisted, republished its notifications", map[string]interface{}{
+		"event_id": event.EventID,
+		"attempt":  res.Attempt,
+		"alerts":   len(alerts),
+	})
+	p.Metrics.IncCounter(metricdef.EventsProcessedTotal, "service", "processor", "status", "republished")
+	res.Outcome = OutcomeRepublished
+	return nil
+}
+
+// paceWrites waits for the message's turn under Writes. It runs before the claim,
+// so a message sent back for it has not used up one of its MaxAttempts.
+func (p *Processor) paceWrites(ctx context.Context) error {
+	if p.Writes == nil {
+		return nil
+	}
+	start := time.Now()
+	err := p.Writes.Wait(ctx, "", 1, p.WriteWait)
+	p.Metrics.ObserveHistogram(metricdef.ProcessorWriteWaitSeconds, time.Since(start).Seconds())
+	if err != nil {
+		return domain.NewRetryableError("db_rate_limited", err)
+	}
+	return nil
+}
+
+// fetchPayload returns the message's payload bytes, inline or from object storage.
+func (p *Processor) fetchPayload(ctx context.Context, msg *domain.QueueMessage) ([]byte, error) {
+	switch msg.PayloadMode {
+	case domain.PayloadModeInline:
+		payloadBytes, err := msg.InlinePayload()
+		if err != nil {
+			return nil, domain.NewNonRetryableError("invalid_inline_payload", err)
+		}
+		if payloadBytes == nil {
+			return nil, domain.NewNonRetryableError("missing_payload", nil)
+		}
+		if msg.Encryption != nil {
+			if p.Payloads == nil {
+				return nil, domain.NewNonRetryableError("payload_decrypt_failed", errors.New("no payload encryption keys configured"))
+			}
+			if payloadBytes, err = p.Payloads.Open(msg.Encryption, payloadBytes, []byte(msg.EventID)); err != nil {
+				return nil, domain.NewNonRetryableError("payload_decrypt_failed", err)
+			}
+		}
+		if p.MaxPayloadBytes > 0 && int64(len(payloadBytes)) > p.MaxPayloadBytes {
+			return nil, domain.NewNonRetryableError("payload_too_large",
+				&domain.TooLargeError{Resource: "inline payload", Size: int64(len(payloadBytes)), Limit: p.MaxPayloadBytes})
+		}
+		return payloadBytes, nil
+
+	case domain.PayloadModeS3:
+		if msg.S3Key == nil {
+			return nil, domain.NewNonRetryableError("missing_s3_key", nil)
+		}
+		payloadBytes, err := p.getStored(ctx, *msg.S3Key)
+		if err != nil {
+			p.Logger.Error("Failed to fetch payload from storage", err)
+			// A deleted, oversize or forbidden object won't heal on redelivery;
+			// only transient (or unclassified) storage errors are retried.
+			var notFound *domain.NotFoundError
+			var tooLarge *domain.TooLargeError
+			var permanent *domain.NonRetryableError
+			switch {
+			case errors.As(err, &notFound):
+				return nil, domain.NewNonRetryableError("payload_not_found", err)
+			case errors.As(err, &tooLarge):
+				return nil, domain.NewNonRetryableError("payload_too_large", err)
+			case errors.As(err, &permanent):
+				return nil, domain.NewNonRetryableError("storage_fetch_rejected", err)
+			}
+			p.Metrics.IncCounter(metricdef.EventsProcessedTotal, "service", "processor", "status", "failure")
+			return nil, domain.NewRetryableError("storage_fetch_failed", err)
+		}
+		return payloadBytes, nil
+
+	default:
+		return nil, domain.NewNonRetryableError("invalid_payload_mode", nil)
+	}
+}
+
+// getStored reads the payload object at key within MaxPayloadBytes. A Storage
+// that can't bound its reads is read whole and checked afterwards.
+func (p *Processor) getStored(ctx context.Context, key string) ([]byte, error) {
+	if p.MaxPayloadBytes <= 0 {
+		return p.Storage.Get(ctx, key)
+	}
+	if limited, ok := p.Storage.(ports.LimitedGetter); ok {
+		return limited.GetLimited(ctx, key, p.MaxPayloadBytes)
+	}
+	data, err := p.Storage.Get(ctx, key)
+	if err == nil && int64(len(data)) > p.MaxPayloadBytes {
+		return nil, &domain.TooLargeError{Resource: "object", Size: int64(len(data)), Limit: p.MaxPayloadBytes}
+	}
+	return data, err
+}
+
+// decodeEvent checks payloadBytes against the envelope's hash and decodes (by the
+// envelope's content_type, see eventcodec), upgrades (with migrations), normalizes,
+// and validates the event with vc. All failures are non-retryable: redelivering the
+// same bytes cannot fix them. The envelope's event_id wins over the payload's.
+func decodeEvent(msg *domain.QueueMessage, payloadBytes []byte, vc domain.ValidationConfig, migrations *schemamigrate.Registry) (*domain.Event, error) {
+	hash := sha256.Sum256(payloadBytes)
+	calculatedHash := hex.EncodeToString(hash[:])
+	if calculatedHash != msg.PayloadSHA256 {
+		return nil, domain.NewNonRetryableError("hash_mismatch", nil)
+	}
+
+	event, err := eventcodec.Decode(msg.ContentType, payloadBytes)
+	if errors.Is(err, eventcodec.ErrUnsupported) {
+		return nil, domain.NewNonRetryableError("unsupported_content_type", err)
+	}
+	if err != nil {
+		return nil, domain.NewNonRetryableError("unmarshal_error", err)
+	}
+	if _, err := migrations.UpgradeEvent(&event); err != nil {
+		return nil, domain.NewNonRetryableError("schema_migration_failed", err)
+	}
+	event.Normalize()
+	if err := event.ValidateWith(vc, time.Now()); err != nil {
+		return nil, domain.NewNonRetryableError("validation_error", err)
+	}
+	event.EventID = msg.EventID
+	event.ProducerKey = msg.ProducerKey
+	return &event, nil
+}
+
+// prepare runs the pipeline's pre-persist stages on a decoded event: merchant
+// canonicalization and profile enrichment (enrich), then anomaly scoring. With
+// enrich left out the canonical merchant is the raw descriptor.
+func (p *Processor) prepare(ctx context.Context, event *domain.Event) {
+	event.CanonicalMerchant = event.Merchant
+	if p.Pipeline.Runs(PipelineEnrich) {
+		if p.Merchants != nil {
+			event.CanonicalMerchant = p.Merchants.Canonicalize(event.Merchant)
+		}
+		p.enrich(ctx, event)
+	}
+	if p.Pipeline.Runs(PipelineAnomaly) {
+		p.scoreAnomaly(event)
+	}
+}
+
+// enrich attaches user-profile attributes to the event. Skip-on-failure: a slow or
+// failing profile service never blocks persistence, the event is stored unenriched.
+func (p *Processor) enrich(ctx context.Context, event *domain.Event) {
+	if p.Enricher == nil {
+		return
+	}
+	timeout := p.EnrichTimeout
+	if timeout <= 0 {
+		timeout = 200 * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	attrs, err := p.Enricher.Lookup(ctx, event.UserID)
+	if err != nil {
+		p.Logger.Warn("Enrichment lookup failed; skipping", map[string]interface{}{
+			"event_id": event.EventID,
+			"error":    err.Error(),
+		})
+		p.Metrics.IncCounter(metricdef.EnrichmentLookupsTotal, "status", "error")
+		return
+	}
+	if len(attrs) == 0 {
+		p.Metrics.IncCounter(metricdef.EnrichmentLookupsTotal, "status", "empty")
 		return
 	}
+	event.Enrichment = attrs
+	p.Metrics.IncCounter(metricdef.EnrichmentLookupsTotal, "status", "ok")
+}
+
+// scoreAnomaly records the event's amount z-score against the user's and merchant's
+// rolling distributions in metadata ("anomaly_score", "anomaly_stats") and counts
+// anomalous dimensions. Advisory and best-effort: errors are logged and skipped.
+func (p *Processor) scoreAnomaly(event *domain.Event) {
+	if p.Anomaly == nil {
+		return
+	}
+	res, err := p.Anomaly.Evaluate(event)
+	if err != nil {
+		p.Logger.Warn("Anomaly scoring failed; skipping", map[string]interface{}{
+			"event_id": event.EventID,
+			"error":    err.Error(),
+		})
+		return
+	}
+
+	stats := map[string]interface{}{}
+	if res.User.Scored {
+		stats[anomaly.DimensionUser] = res.User
+		p.Metrics.ObserveHistogram(metricdef.AmountZScore, math.Abs(res.User.ZScore), "dimension", anomaly.DimensionUser)
+	}
+	if res.Merchant.Scored {
+		stats[anomaly.DimensionMerchant] = res.Merchant
+		p.Metrics.ObserveHistogram(metricdef.AmountZScore, math.Abs(res.Merchant.ZScore), "dimension", anomaly.DimensionMerchant)
+	}
+	if len(stats) == 0 {
+		return // not enough history to score either dimension
+	}
+
+	event.Metadata["anomaly_score"] = math.Round(res.Score*1e4) / 1e4
+	event.Metadata["anomaly_stats"] = stats
+	for _, dim := range res.Anomalous {
+		p.Metrics.IncCounter(metricdef.AnomalousEventsTotal, "dimension", dim)
+	}
+	if len(res.Anomalous) > 0 {
+		p.Logger.Info("Anomalous amount", map[string]interface{}{
+			"event_id":      event.EventID,
+			"anomaly_score": res.Score,
+			"dimensions":    res.Anomalous,
+		})
+	}
+}
+
+// evaluateFraud runs all fraud rules, records any flags, and returns their alert
+// messages for publishAlerts. Errors are logged but never propagated — the event
+// itself is already safely persisted. A nil Fraud engine is treated as a no-op
+// (useful in tests).
+func (p *Processor) evaluateFraud(ctx context.Context, event *domain.Event) (alerts []domain.AlertMessage) {
+	if p.Fraud == nil || !p.Pipeline.Runs(PipelineFraud) {
+		return nil
+	}
 	flags, mlScore, _, err := p.Fraud.EvaluateWithScorer(ctx, event, p.DB, p.Scorer)
 	if err != nil {
 		p.Logger.Error("Fraud evaluation error", err)
-		return
+		return nil
 	}
 
 	for _, flag := range flags {
 		flag.MlScore = mlScore
+		flag.Canary = event.Canary
 		if err := p.DB.InsertFraudFlag(&flag); err != nil {
 			p.Logger.Error("Failed to insert fraud flag", err, map[string]interface{}{
 				"rule_name": flag.RuleName,
@@ -161,22 +731,12 @@ func (p *Processor) evaluateFraud(ctx context.Context, event *domain.Event) {
 			continue
 		}
 
-		p.Metrics.IncCounter("fraud_flags_total", "rule", flag.RuleName)
-
-		alertMsg := domain.AlertMessage(flag)
-		body, err := json.Marshal(alertMsg)
-		if err != nil {
-			p.Logger.Error("Failed to marshal alert message", err)
-			continue
-		}
-		if p.Publisher == nil {
-			continue
-		}
-		if err := p.Publisher.Publish(ctx, "alerts", "", body); err != nil {
-			p.Logger.Error("Failed to publish alert", err, map[string]interface{}{
-				"rule_name": flag.RuleName,
-			})
+		// Canary flags stay out of fraud_flags_total so they never move the fraud rate.
+		if !event.Canary {
+			p.Metrics.IncCounter(metricdef.FraudFlagsTotal, "rule", flag.RuleName)
 		}
+
+		alerts = append(alerts, domain.NewAlertMessage(flag))
 	}
 
 	if len(flags) > 0 {
@@ -184,15 +744,31 @@ func (p *Processor) evaluateFraud(ctx context.Context, event *domain.Event) {
 			"event_id": event.EventID,
 		})
 	}
+	return alerts
+}
+
+// publishAlerts hands alerts to the Notifier. Failures are logged, not returned,
+// like the rest of fraud handling. A nil Notifier, or a pipeline without notify,
+// drops them.
+func (p *Processor) publishAlerts(ctx context.Context, alerts []domain.AlertMessage) {
+	if p.Notifier == nil || !p.Pipeline.Runs(PipelineNotify) || len(alerts) == 0 {
+		return
+	}
+	if err := p.Notifier.Notify(ctx, alerts); err != nil {
+		p.Logger.Error("Failed to publish alerts", err, map[string]interface{}{"alerts": len(alerts)})
+	}
 }
 
 // failPermanent logs a permanent failure, marks idempotency as failed, and returns nil (ACK).
-func (p *Processor) failPermanent(eventID, reason string) error {
+func (p *Processor) failPermanent(msg *domain.QueueMessage, reason string) error {
 	p.Logger.Error("Permanent failure: "+reason, nil)
-	p.Metrics.IncCounter("events_processed_total", "service", "processor", "status", "failure")
-	if err := p.Idempotency.MarkFailed(eventID, reason); err != nil {
+	p.Metrics.IncCounter(metricdef.EventsProcessedTotal, "service", "processor", "status", "failure")
+	// The message can't fail to marshal (it was unmarshaled from JSON); if it
+	// somehow did, the failure is recorded without it.
+	kept, _ := json.Marshal(msg)
+	if err := p.Idempotency.MarkFailedWithMessage(msg.EventID, reason, kept); err != nil {
 		p.Logger.Warn("Failed to mark idempotency key as failed (best-effort)", map[string]interface{}{
-			"event_id": eventID,
+			"event_id": msg.EventID,
 			"error":    err.Error(),
 		})
 	}
@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/idempotency"
+	"github.com/fluxa/fluxa/internal/logging"
+)
+
+// Benchmarks for the processor hot path. The ProcessMessage benchmarks run against
+// the local Postgres (same DSN as getTestDB) and skip without it; payloads come
+// from an in-memory store so S3 mode measures the processor rather than MinIO.
+// decodeEvent runs anywhere. Compare runs with benchstat:
+//
+//	make bench > old.txt; <change>; make bench > new.txt; benchstat old.txt new.txt
+
+// benchMessage builds an envelope for a valid event padded to roughly size bytes,
+// offloading to storage when it exceeds the inline limit (as ingest does).
+func benchMessage(eventID string, size int, storage *fluxatest.Storage) *domain.QueueMessage {
+	ev := fluxatest.NewEvent(eventID).User("bench-user").Amount(12.34).Merchant("bench-merchant")
+	if size > 0 {
+		ev.Padded(size)
+	}
+	return fluxatest.Envelope(eventID, ev.Payload(), storage)
+}
+
+func benchProcessor(b *testing.B) (*Processor, *fluxatest.Storage, func()) {
+	b.Helper()
+	dbClient := getTestDB(b)
+	storage := fluxatest.NewStorage()
+	p := &Processor{
+		DB:          dbClient,
+		Idempotency: idempotency.NewClient(dbClient.GetDB()),
+		Storage:     storage,
+		Metrics:     &noopMetrics{},
+		Logger:      logging.NewLogger("bench", "bench"),
+	}
+	cleanup := func() {
+		_, _ = dbClient.GetDB().Exec("DELETE FROM merchant_stats_hourly WHERE merchant = 'bench-merchant'")
+		_, _ = dbClient.GetDB().Exec("DELETE FROM payload_refs WHERE s3_key IN (SELECT s3_key FROM events WHERE event_id LIKE 'test-proc-bench-%')")
+		_, _ = dbClient.GetDB().Exec("DELETE FROM events WHERE event_id LIKE 'test-proc-bench-%'")
+		_, _ = dbClient.GetDB().Exec("DELETE FROM idempotency_keys WHERE event_id LIKE 'test-proc-bench-%'")
+		dbClient.Close()
+	}
+	return p, storage, cleanup
+}
+
+func benchmarkProcess(b *testing.B, size int, dupRatio float64) {
+	p, storage, cleanup := benchProcessor(b)
+	defer cleanup()
+
+	run := time.Now().UnixNano()
+	// Pre-build envelopes so the timed loop measures only ProcessMessage. Every
+	// 1/dupRatio-th message re-sends the previous envelope.
+	msgs := make([]*domain.QueueMessage, b.N)
+	for i := range msgs {
+		if dupRatio > 0 && i > 0 && float64(i%100) < dupRatio*100 {
+			msgs[i] = msgs[i-1]
+			continue
+		}
+		msgs[i] = benchMessage(fmt.Sprintf("test-proc-bench-%d-%d", run, i), size, storage)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for _, msg := range msgs {
+		res, err := p.ProcessMessage(msg)
+		if err != nil || !res.Ack() {
+			b.Fatalf("ProcessMessage: outcome=%s reason=%s err=%v", res.Outcome, res.Reason, err)
+		}
+	}
+}
+
+func BenchmarkProcessMessage_InlineSmall(b *testing.B) { benchmarkProcess(b, 0, 0) }
+func BenchmarkProcessMessage_Inline64KB(b *testing.B)  { benchmarkProcess(b, 64*1024, 0) }
+func BenchmarkProcessMessage_S3_300KB(b *testing.B)    { benchmarkProcess(b, 300*1024, 0) }
+func BenchmarkProcessMessage_DuplicateHeavy(b *testing.B) {
+	benchmarkProcess(b, 0, 0.8)
+}
+
+func BenchmarkDecodeEvent(b *testing.B) {
+	for _, size := range []int{0, 64 * 1024, 300 * 1024} {
+		b.Run(fmt.Sprintf("pad=%d", size), func(b *testing.B) {
+			storage := fluxatest.NewStorage()
+			msg := benchMessage("bench-decode", size, storage)
+			var payload []byte
+			if msg.PayloadInline != nil {
+				payload = []byte(*msg.PayloadInline)
+			} else {
+				payload, _ = storage.Get(context.Background(), *msg.S3Key)
+			}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(payload)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := decodeEvent(msg, payload, domain.ValidationConfig{}, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
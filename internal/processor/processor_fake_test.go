@@ -0,0 +1,706 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fixtures"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/fraud"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/notify"
+	"github.com/fluxa/fluxa/internal/payloadcrypt"
+	"github.com/fluxa/fluxa/internal/ratelimit"
+	"github.com/fluxa/fluxa/internal/schemamigrate"
+)
+
+// Behavior tests against the in-memory fakes in internal/fluxatest; they need no
+// Postgres, RabbitMQ, or MinIO. processor_test.go keeps the DB-backed cases.
+
+type fakeDeps struct {
+	store   *fluxatest.Store
+	idem    *fluxatest.Idempotency
+	storage *fluxatest.Storage
+	queue   *fluxatest.Queue
+	metrics *fluxatest.Metrics
+}
+
+func newFakeProcessor(rules *domain.RulesConfig) (*Processor, *fakeDeps) {
+	d := &fakeDeps{
+		store:   fluxatest.NewStore(),
+		idem:    fluxatest.NewIdempotency(),
+		storage: fluxatest.NewStorage(),
+		queue:   fluxatest.NewQueue(),
+		metrics: fluxatest.NewMetrics(),
+	}
+	logger := logging.NewLogger("test", "test-corr-id")
+	p := &Processor{
+		DB:          d.store,
+		Idempotency: d.idem,
+		Storage:     d.storage,
+		Notifier:    notify.NewQueueNotifier(d.queue),
+		Metrics:     d.metrics,
+		Logger:      logger,
+	}
+	if rules != nil {
+		p.Fraud = fraud.NewEngineFromRules(*rules, logger)
+	}
+	return p, d
+}
+
+func (d *fakeDeps) idemStatus(t *testing.T, eventID string) *domain.IdempotencyKeyRecord {
+	t.Helper()
+	rec, err := d.idem.Status(eventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestProcessorFake_InlineProcessedOnce(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	msg := fluxatest.Envelope("evt-1", fluxatest.NewEvent("evt-1").Merchant("  Amazon ").Payload(), nil)
+
+	res, err := p.ProcessMessage(msg)
+	if err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("first delivery = %+v, %v; want processed", res, err)
+	}
+	res, err = p.ProcessMessage(msg)
+	if err != nil || res.Outcome != OutcomeDuplicate {
+		t.Fatalf("redelivery = %+v, %v; want duplicate", res, err)
+	}
+
+	if n := d.store.EventCount(); n != 1 {
+		t.Errorf("stored %d events, want 1", n)
+	}
+	stored := d.store.Event("evt-1")
+	if stored == nil || stored.Event.Merchant != "Amazon" || stored.PayloadMode != domain.PayloadModeInline {
+		t.Errorf("stored event = %+v, want normalized inline event", stored)
+	}
+	if got := d.idemStatus(t, "evt-1").Status; got != string(domain.IdempotencyStatusSuccess) {
+		t.Errorf("idempotency status = %q, want success", got)
+	}
+	if got := d.metrics.Counter(metricdef.EventsProcessedTotal, "processor", "success"); got != 1 {
+		t.Errorf("events_processed_total{success} = %d, want 1", got)
+	}
+	tl := d.store.Timeline("evt-1")
+	if tl == nil || !tl.ReceivedAt.Equal(msg.ReceivedAt) || tl.DequeuedAt.Before(tl.ReceivedAt) || tl.PersistedAt.Before(tl.DequeuedAt) {
+		t.Errorf("timeline = %+v, want received <= dequeued <= persisted", tl)
+	}
+}
+
+func TestProcessorFake_GroupCompletesOnce(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	p.Groups = notify.NewQueueGroupNotifier(d.queue)
+	for _, id := range []string{"leg-1", "leg-2", "leg-2"} {
+		msg := fluxatest.Envelope(id, fluxatest.NewEvent(id).Group("transfer-1", 2).Payload(), nil)
+		if _, err := p.ProcessMessage(msg); err != nil {
+			t.Fatalf("ProcessMessage(%s) = %v", id, err)
+		}
+	}
+
+	g := d.store.Group("transfer-1")
+	if g == nil || !g.Complete || g.Persisted != 2 || g.Expected != 2 || g.CompletedAt == nil {
+		t.Fatalf("group = %+v, want complete with 2 of 2", g)
+	}
+	if got := d.metrics.Counter(metricdef.GroupsCompletedTotal); got != 1 {
+		t.Errorf("groups_completed_total = %d, want 1", got)
+	}
+	published := d.queue.Published(notify.GroupsExchange)
+	if len(published) != 1 {
+		t.Fatalf("published %d group messages, want 1", len(published))
+	}
+	var msg domain.GroupCompleteMessage
+	if err := json.Unmarshal(published[0].Body, &msg); err != nil || msg.GroupID != "transfer-1" || msg.Members != 2 {
+		t.Errorf("group message = %+v, %v; want transfer-1 with 2 members", msg, err)
+	}
+}
+
+func TestProcessorFake_S3Payload(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	payload := fluxatest.NewEvent("evt-big").Padded(domain.MaxInlinePayloadBytes).Payload()
+	msg := fluxatest.Envelope("evt-big", payload, d.storage)
+	if msg.PayloadMode != domain.PayloadModeS3 {
+		t.Fatalf("envelope mode = %q, want S3 for %d bytes", msg.PayloadMode, len(payload))
+	}
+
+	res, err := p.ProcessMessage(msg)
+	if err != nil || res.Outcome != OutcomeProcessed || res.PayloadBytes != len(payload) {
+		t.Fatalf("ProcessMessage = %+v, %v; want processed with %d bytes", res, err, len(payload))
+	}
+	stored := d.store.Event("evt-big")
+	if stored == nil || stored.S3Key == nil || *stored.S3Key != *msg.S3Key {
+		t.Errorf("stored event = %+v, want s3_key %q", stored, *msg.S3Key)
+	}
+}
+
+func TestProcessorFake_ObservesEndToEndLatency(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	msg := fluxatest.InlineEnvelope("evt-e2e", fluxatest.NewEvent("evt-e2e").Payload())
+	msg.ReceivedAt = time.Now().Add(-2 * time.Second)
+	if _, err := p.ProcessMessage(msg); err != nil {
+		t.Fatalf("ProcessMessage = %v", err)
+	}
+	got := d.metrics.Observations(metricdef.E2ELatencySeconds, string(domain.PayloadModeInline))
+	if len(got) != 1 || got[0] < 2 || got[0] > 60 {
+		t.Errorf("e2e_latency_seconds{inline} = %v, want one observation of about 2s", got)
+	}
+}
+
+func testKeyring(t *testing.T) *payloadcrypt.Keyring {
+	t.Helper()
+	keys, err := payloadcrypt.New("k1", map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)})
+	if err != nil {
+		t.Fatalf("payloadcrypt.New = %v", err)
+	}
+	return keys
+}
+
+// sealedEnvelope is an inline envelope for eventID as ingest sends a sensitive
+// event: sealed with keys, the hash still the plaintext's.
+func sealedEnvelope(t *testing.T, keys *payloadcrypt.Keyring, eventID string) *domain.QueueMessage {
+	t.Helper()
+	payload := fluxatest.NewEvent(eventID).Payload()
+	msg := fluxatest.InlineEnvelope(eventID, payload)
+	enc, sealed, err := keys.Seal(payload, []byte(eventID))
+	if err != nil {
+		t.Fatalf("Seal = %v", err)
+	}
+	msg.SetSealed(enc, sealed)
+	return msg
+}
+
+func TestProcessorFake_OpensSealedPayloads(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	keys := testKeyring(t)
+	p.Payloads = keys
+	if res, err := p.ProcessMessage(sealedEnvelope(t, keys, "evt-sealed")); err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("ProcessMessage = %+v, %v; want processed", res, err)
+	}
+	if d.store.EventCount() != 1 {
+		t.Errorf("stored %d events, want the opened one", d.store.EventCount())
+	}
+
+	// Sealed for another event: the event ID is bound into the seal.
+	msg := sealedEnvelope(t, keys, "evt-other")
+	msg.EventID = "evt-moved"
+	if res, _ := p.ProcessMessage(msg); res.Reason != "payload_decrypt_failed" {
+		t.Errorf("reason = %q, want payload_decrypt_failed for a payload sealed under another event", res.Reason)
+	}
+}
+
+func TestProcessorFake_PermanentFailuresAck(t *testing.T) {
+	tests := []struct {
+		name       string
+		msg        func(d *fakeDeps) *domain.QueueMessage
+		wantReason string
+	}{
+		{"hash mismatch", func(d *fakeDeps) *domain.QueueMessage {
+			msg := fluxatest.InlineEnvelope("evt-p", fluxatest.NewEvent("evt-p").Payload())
+			msg.PayloadSHA256 = "bad-hash"
+			return msg
+		}, "hash_mismatch"},
+		{"invalid event", func(d *fakeDeps) *domain.QueueMessage {
+			return fluxatest.InlineEnvelope("evt-p", fluxatest.NewEvent("evt-p").Currency("").Payload())
+		}, "validation_error"},
+		{"missing object", func(d *fakeDeps) *domain.QueueMessage {
+			msg := fluxatest.S3Envelope("evt-p", fluxatest.NewEvent("evt-p").Payload(), fluxatest.NewStorage())
+			return msg // stored in a different Storage than the processor reads
+		}, "payload_not_found"},
+		{"oversize inline payload", func(d *fakeDeps) *domain.QueueMessage {
+			return fluxatest.InlineEnvelope("evt-p", fluxatest.NewEvent("evt-p").Padded(8192).Payload())
+		}, "payload_too_large"},
+		{"oversize object", func(d *fakeDeps) *domain.QueueMessage {
+			return fluxatest.S3Envelope("evt-p", fluxatest.NewEvent("evt-p").Padded(8192).Payload(), d.storage)
+		}, "payload_too_large"},
+		{"sealed payload without keys", func(d *fakeDeps) *domain.QueueMessage {
+			return sealedEnvelope(t, testKeyring(t), "evt-p")
+		}, "payload_decrypt_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, d := newFakeProcessor(nil)
+			p.MaxPayloadBytes = 4096
+			res, err := p.ProcessMessage(tt.msg(d))
+			if err != nil || !res.Ack() || res.Outcome != OutcomeFailed {
+				t.Fatalf("ProcessMessage = %+v, %v; want ACKed failure", res, err)
+			}
+			if res.Reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", res.Reason, tt.wantReason)
+			}
+			rec := d.idemStatus(t, "evt-p")
+			if rec.Status != string(domain.IdempotencyStatusFailed) || rec.ErrorReason == nil {
+				t.Errorf("idempotency = %+v, want failed with reason", rec)
+			}
+			var kept domain.QueueMessage
+			if err := json.Unmarshal(d.idem.FailedMessage("evt-p"), &kept); err != nil || kept.EventID != "evt-p" {
+				t.Errorf("kept message = %+v, %v; want the failed message, for bulk retry", kept, err)
+			}
+			if d.store.EventCount() != 0 {
+				t.Errorf("permanent failure persisted an event")
+			}
+		})
+	}
+}
+
+func TestProcessorFake_TransientFailuresRetry(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(d *fakeDeps)
+	}{
+		{"idempotency unavailable", func(d *fakeDeps) { d.idem.CheckErr = errors.New("conn refused") }},
+		{"db insert fails", func(d *fakeDeps) { d.store.InsertEventErr = errors.New("deadlock") }},
+		{"storage throttled", func(d *fakeDeps) { d.storage.GetErr = domain.NewRetryableError("storage_throttled", nil) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, d := newFakeProcessor(nil)
+			tt.setup(d)
+			msg := fluxatest.S3Envelope("evt-r", fluxatest.NewEvent("evt-r").Payload(), d.storage)
+			res, err := p.ProcessMessage(msg)
+			if err == nil || res.Ack() || res.Outcome != OutcomeRetry {
+				t.Fatalf("ProcessMessage = %+v, %v; want NACKed retry", res, err)
+			}
+			if got := d.metrics.Counter(metricdef.EventsProcessedTotal, "processor", "success"); got != 0 {
+				t.Errorf("events_processed_total{success} = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestProcessorFake_PacesWritesBeforeClaiming(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	p.Writes = ratelimit.New(ratelimit.Options{PerSecond: 1})
+	p.MaxAttempts = 1
+	if res, err := p.ProcessMessage(fluxatest.InlineEnvelope("evt-w1", fluxatest.NewEvent("evt-w1").Payload())); err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("first message = %+v, %v; want processed on the burst", res, err)
+	}
+	res, err := p.ProcessMessage(fluxatest.InlineEnvelope("evt-w2", fluxatest.NewEvent("evt-w2").Payload()))
+	if err == nil || res.Outcome != OutcomeRetry || res.Reason != "db_rate_limited" {
+		t.Fatalf("second message = %+v, %v; want a db_rate_limited retry", res, err)
+	}
+	// Sent back before its claim: the one attempt MaxAttempts allows is still unused.
+	if rec, err := d.idem.Status("evt-w2"); err == nil {
+		t.Errorf("idempotency = %+v, want no claim", rec)
+	}
+	if got := d.metrics.Observations(metricdef.ProcessorWriteWaitSeconds); len(got) != 2 {
+		t.Errorf("processor_write_wait_seconds observed %d times, want 2", len(got))
+	}
+}
+
+// releaseRecorder records Release calls on top of the fake idempotency store.
+type releaseRecorder struct {
+	*fluxatest.Idempotency
+	released []string
+}
+
+func (r *releaseRecorder) Release(eventID string) { r.released = append(r.released, eventID) }
+
+func TestProcessorFake_PacedOutMessageKeepsOthersClaim(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	idem := &releaseRecorder{Idempotency: d.idem}
+	p.Idempotency = idem
+	p.Writes = ratelimit.New(ratelimit.Options{PerSecond: 1})
+	p.Writes.Take("", 1)
+
+	// Another delivery of evt-p holds the claim while this one is paced out.
+	if done, _, err := d.idem.CheckAndMarkAttempt("evt-p"); done || err != nil {
+		t.Fatalf("claim = %v, %v", done, err)
+	}
+	res, err := p.ProcessMessage(fluxatest.InlineEnvelope("evt-p", fluxatest.NewEvent("evt-p").Payload()))
+	if err == nil || res.Reason != "db_rate_limited" {
+		t.Fatalf("ProcessMessage = %+v, %v; want a db_rate_limited retry", res, err)
+	}
+	if len(idem.released) != 0 {
+		t.Errorf("released %v without holding a claim, want none", idem.released)
+	}
+
+	// A delivery that did claim releases it on a transient failure.
+	p.Writes = nil
+	d.store.InsertEventErr = errors.New("deadlock")
+	if _, err := p.ProcessMessage(fluxatest.InlineEnvelope("evt-q", fluxatest.NewEvent("evt-q").Payload())); err == nil {
+		t.Fatal("ProcessMessage succeeded, want a retry")
+	}
+	if len(idem.released) != 1 || idem.released[0] != "evt-q" {
+		t.Errorf("released = %v, want [evt-q]", idem.released)
+	}
+}
+
+func TestProcessorFake_TenantUsage(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	p.Tenants = metricdef.NewTenants([]string{"acme"})
+
+	payload := fluxatest.NewEvent("evt-a").Payload()
+	listed := fluxatest.InlineEnvelope("evt-a", payload)
+	listed.Tenant = "acme"
+	bad := fluxatest.InlineEnvelope("evt-bad", fluxatest.NewEvent("evt-bad").Payload())
+	bad.PayloadSHA256 = "bad-hash"
+	bad.Tenant = "acme"
+	unlisted := fluxatest.InlineEnvelope("evt-b", fluxatest.NewEvent("evt-b").Payload())
+	unlisted.Tenant = "globex"
+	for _, msg := range []*domain.QueueMessage{listed, listed, bad, unlisted} {
+		_, _ = p.ProcessMessage(msg)
+	}
+
+	// The duplicate did no new work and isn't counted.
+	if got := d.metrics.Counter(metricdef.TenantEventsProcessedTotal, "acme", "processed"); got != 1 {
+		t.Errorf("acme processed = %d, want 1", got)
+	}
+	if got := d.metrics.Counter(metricdef.TenantEventsProcessedTotal, "acme", "failed"); got != 1 {
+		t.Errorf("acme failed = %d, want 1", got)
+	}
+	if got := d.metrics.Counter(metricdef.TenantEventsProcessedTotal, metricdef.OtherTenant, "processed"); got != 1 {
+		t.Errorf("unlisted producer counted %d times as other, want 1", got)
+	}
+	if got := d.metrics.Observations(metricdef.TenantPayloadBytes, "acme"); len(got) != 1 || got[0] != float64(len(payload)) {
+		t.Errorf("acme payload bytes = %v, want [%d]", got, len(payload))
+	}
+}
+
+func TestProcessorFake_FinalAttemptFailsPermanently(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	p.MaxAttempts = 2
+	d.store.InsertEventErr = errors.New("deadlock")
+	msg := fluxatest.InlineEnvelope("evt-r", fluxatest.NewEvent("evt-r").Payload())
+
+	res, err := p.ProcessMessage(msg)
+	if err == nil || res.Outcome != OutcomeRetry || res.Attempt != 1 {
+		t.Fatalf("attempt 1 = %+v, %v; want NACKed retry", res, err)
+	}
+	// Reclaim the key as a stale-lock takeover would.
+	if err := d.idem.MarkFailed("evt-r", "stale"); err != nil {
+		t.Fatal(err)
+	}
+	res, err = p.ProcessMessage(msg)
+	if err != nil || !res.Ack() || res.Outcome != OutcomeFailed || res.Reason != "max_attempts_exceeded" {
+		t.Fatalf("attempt 2 = %+v, %v; want ACKed max_attempts_exceeded", res, err)
+	}
+	rec := d.idemStatus(t, "evt-r")
+	if rec.ErrorReason == nil || !strings.Contains(*rec.ErrorReason, "attempt 2 of 2, completed idempotency, fetch, decode") ||
+		!strings.Contains(*rec.ErrorReason, "db_insert_failed") {
+		t.Errorf("error_reason = %v, want the attempt, stages reached and last reason", rec.ErrorReason)
+	}
+	if d.idem.FailedMessage("evt-r") == nil {
+		t.Error("final attempt kept no message for bulk retry")
+	}
+	if got := d.metrics.Counter(metricdef.FinalAttemptsTotal, "failed"); got != 1 {
+		t.Errorf("final_attempts_total{failed} = %d, want 1", got)
+	}
+}
+
+func TestProcessorFake_PersistedRetryOnlyRepublishes(t *testing.T) {
+	p, d := newFakeProcessor(&domain.RulesConfig{AmountThreshold: 1000})
+	msg := fluxatest.InlineEnvelope("evt-n", fluxatest.NewEvent("evt-n").Amount(5000).Payload())
+
+	if res, err := p.ProcessMessage(msg); err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("first attempt = %+v, %v; want processed", res, err)
+	}
+	// The key is reclaimed as if the first attempt had died before settling it.
+	if err := d.idem.MarkFailed("evt-n", "stale"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := p.ProcessMessage(msg)
+	if err != nil || !res.Ack() || res.Outcome != OutcomeRepublished || res.Attempt != 2 {
+		t.Fatalf("retry = %+v, %v; want ACKed republished on attempt 2", res, err)
+	}
+	if n := len(d.store.Flags()); n != 1 {
+		t.Errorf("stored %d fraud flags, want the first attempt's 1", n)
+	}
+	alerts := d.queue.Published("alerts")
+	if len(alerts) != 2 {
+		t.Fatalf("published %d alert messages, want the original and its republished copy", len(alerts))
+	}
+	var first, again domain.AlertMessage
+	if json.Unmarshal(alerts[0].Body, &first) != nil || json.Unmarshal(alerts[1].Body, &again) != nil || first.DedupToken != again.DedupToken {
+		t.Errorf("republished alert token = %q, want the original's %q", again.DedupToken, first.DedupToken)
+	}
+	if got := d.idemStatus(t, "evt-n").Status; got != string(domain.IdempotencyStatusSuccess) {
+		t.Errorf("idempotency status = %q, want success", got)
+	}
+	if got := d.metrics.Counter(metricdef.EventsProcessedTotal, "processor", "republished"); got != 1 {
+		t.Errorf("events_processed_total{republished} = %d, want 1", got)
+	}
+}
+
+func TestProcessorFake_StorageErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		getErr    error
+		wantRetry bool
+	}{
+		{"deleted object is permanent", domain.NewNotFoundError("object", errors.New("NoSuchKey")), false},
+		{"access denied is permanent", domain.NewNonRetryableError("storage_access_denied", nil), false},
+		{"throttling is retried", domain.NewRetryableError("storage_throttled", nil), true},
+		{"unclassified is retried", errors.New("boom"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, d := newFakeProcessor(nil)
+			d.storage.GetErr = tt.getErr
+			msg := fluxatest.S3Envelope("evt-s", fluxatest.NewEvent("evt-s").Payload(), d.storage)
+			res, err := p.ProcessMessage(msg)
+			if (err != nil) != tt.wantRetry {
+				t.Errorf("ProcessMessage() = %v, wantRetry %v", err, tt.wantRetry)
+			}
+			if res.Ack() == tt.wantRetry {
+				t.Errorf("res.Ack() = %v, want %v (outcome %q)", res.Ack(), !tt.wantRetry, res.Outcome)
+			}
+		})
+	}
+}
+
+func TestProcessorFake_FraudFlagsPublishAlerts(t *testing.T) {
+	rules := &domain.RulesConfig{AmountThreshold: 1000, BlockedMerchants: []string{"BadShop"}}
+
+	t.Run("real event", func(t *testing.T) {
+		p, d := newFakeProcessor(rules)
+		msg := fluxatest.Envelope("evt-f", fluxatest.NewEvent("evt-f").Amount(5000).Merchant("BadShop").Payload(), nil)
+		if res, err := p.ProcessMessage(msg); err != nil || res.Outcome != OutcomeProcessed {
+			t.Fatalf("ProcessMessage = %+v, %v", res, err)
+		}
+		if n := len(d.store.Flags()); n != 2 {
+			t.Fatalf("stored %d flags, want 2", n)
+		}
+		alerts := d.queue.Published("alerts")
+		if len(alerts) != 2 {
+			t.Fatalf("published %d alerts, want 2", len(alerts))
+		}
+		var alert domain.AlertMessage
+		if err := json.Unmarshal(alerts[0].Body, &alert); err != nil || alert.EventID != "evt-f" || alert.Canary {
+			t.Errorf("alert = %+v (%v), want non-canary alert for evt-f", alert, err)
+		}
+		if want := domain.DedupToken("evt-f", domain.FraudAlertType(alert.RuleName)); alert.DedupToken != want {
+			t.Errorf("alert dedup_token = %q, want %q", alert.DedupToken, want)
+		}
+		if got := d.metrics.Counter(metricdef.FraudFlagsTotal, "amount_threshold"); got != 1 {
+			t.Errorf("fraud_flags_total{amount_threshold} = %d, want 1", got)
+		}
+	})
+
+	t.Run("canary event", func(t *testing.T) {
+		p, d := newFakeProcessor(rules)
+		msg := fluxatest.Envelope("evt-c", fluxatest.NewEvent("evt-c").Amount(5000).Canary().Payload(), nil)
+		if res, err := p.ProcessMessage(msg); err != nil || !res.Canary {
+			t.Fatalf("ProcessMessage = %+v, %v; want processed canary", res, err)
+		}
+		alerts := d.queue.Published("alerts")
+		if len(alerts) != 1 {
+			t.Fatalf("published %d alerts, want 1", len(alerts))
+		}
+		var alert domain.AlertMessage
+		if err := json.Unmarshal(alerts[0].Body, &alert); err != nil || !alert.Canary {
+			t.Errorf("alert = %+v (%v), want canary alert", alert, err)
+		}
+		if got := d.metrics.Counter(metricdef.FraudFlagsTotal, "amount_threshold"); got != 0 {
+			t.Errorf("fraud_flags_total counted a canary flag")
+		}
+		if got := d.metrics.Counter(metricdef.CanaryEventsTotal, "processor"); got != 1 {
+			t.Errorf("canary_events_total{processor} = %d, want 1", got)
+		}
+	})
+}
+
+func TestProcessorFake_PipelineSkipsStages(t *testing.T) {
+	rules := &domain.RulesConfig{AmountThreshold: 1000}
+	for stages, want := range map[string][2]int{
+		"hash-verify,validate,persist,fraud":        {1, 0},
+		"hash-verify,validate,enrich,persist":       {0, 0},
+		"hash-verify,validate,persist,fraud,notify": {1, 1},
+	} {
+		pl, err := ParsePipeline(strings.Split(stages, ","))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, d := newFakeProcessor(rules)
+		p.Pipeline = pl
+		msg := fluxatest.Envelope("evt-p", fluxatest.NewEvent("evt-p").Amount(5000).Payload(), nil)
+		if res, err := p.ProcessMessage(msg); err != nil || res.Outcome != OutcomeProcessed {
+			t.Fatalf("%s: ProcessMessage = %+v, %v", stages, res, err)
+		}
+		if flags, alerts := len(d.store.Flags()), len(d.queue.Published("alerts")); flags != want[0] || alerts != want[1] {
+			t.Errorf("%s: %d flags, %d alerts; want %d, %d", stages, flags, alerts, want[0], want[1])
+		}
+	}
+}
+
+func TestProcessorFake_VelocityExcludesCanaries(t *testing.T) {
+	rules := &domain.RulesConfig{VelocityWindowSeconds: 300, VelocityMaxCount: 3}
+	p, d := newFakeProcessor(rules)
+	send := func(id string, canary bool) {
+		b := fluxatest.NewEvent(id).User("u-vel")
+		if canary {
+			b.Canary()
+		}
+		if res, err := p.ProcessMessage(fluxatest.Envelope(id, b.Payload(), nil)); err != nil || !res.Ack() {
+			t.Fatalf("ProcessMessage(%s) = %+v, %v", id, res, err)
+		}
+	}
+	send("v-1", false)
+	send("v-2", true)
+	send("v-3", true)
+	send("v-4", false)
+	if n := len(d.store.Flags()); n != 0 {
+		t.Fatalf("canaries counted toward velocity: %d flags", n)
+	}
+	send("v-5", false)
+	flags := d.store.Flags()
+	if len(flags) != 1 || flags[0].EventID != "v-5" || flags[0].RuleName != "velocity" {
+		t.Errorf("flags = %+v, want one velocity flag on v-5", flags)
+	}
+}
+
+func TestProcessorFake_GeneratedTraffic(t *testing.T) {
+	p, d := newFakeProcessor(&domain.RulesConfig{AmountThreshold: 1000, HighRiskCurrencies: []string{"XMR"}})
+	gen := fixtures.New(fixtures.Options{
+		Seed:        11,
+		Start:       time.Now().Add(-time.Hour),
+		Sizes:       []fixtures.SizeBucket{{Weight: 0.9, Bytes: 400}, {Weight: 0.1, Bytes: domain.MaxInlinePayloadBytes + 1}},
+		CanaryRatio: 0.05,
+	})
+	const n = 300
+	for i := 0; i < n; i++ {
+		ev, payload := gen.Next()
+		res, err := p.ProcessMessage(fluxatest.Envelope(ev.EventID, payload, d.storage))
+		if err != nil || res.Outcome != OutcomeProcessed {
+			t.Fatalf("event %d (%d bytes): %+v, %v", i, len(payload), res, err)
+		}
+	}
+	if got := d.store.EventCount(); got != n {
+		t.Errorf("stored %d events, want %d", got, n)
+	}
+	if len(d.store.Flags()) == 0 {
+		t.Error("no fraud flags on generated traffic with a heavy amount tail")
+	}
+}
+
+type recordedAck struct {
+	producerKey string
+	ack         domain.EventAck
+}
+
+type recordingAcks struct{ acks []recordedAck }
+
+func (r *recordingAcks) Notify(producerKey string, ack domain.EventAck) {
+	r.acks = append(r.acks, recordedAck{producerKey, ack})
+}
+
+func TestProcessorFake_ProducerAcks(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	acks := &recordingAcks{}
+	p.Acks = acks
+
+	ok := fluxatest.InlineEnvelope("evt-ok", fluxatest.NewEvent("evt-ok").Payload())
+	ok.ProducerKey = "key-a"
+	bad := fluxatest.InlineEnvelope("evt-bad", fluxatest.NewEvent("evt-bad").Payload())
+	bad.PayloadSHA256 = "bad-hash"
+	bad.ProducerKey = "key-a"
+	anon := fluxatest.InlineEnvelope("evt-anon", fluxatest.NewEvent("evt-anon").Payload())
+	transient := fluxatest.InlineEnvelope("evt-retry", fluxatest.NewEvent("evt-retry").Payload())
+	transient.ProducerKey = "key-a"
+
+	for _, msg := range []*domain.QueueMessage{ok, ok, bad, anon} {
+		_, _ = p.ProcessMessage(msg)
+	}
+	d.store.InsertEventErr = errors.New("deadlock")
+	_, _ = p.ProcessMessage(transient)
+
+	// One ack each for the processed and failed events: no re-ack for the duplicate,
+	// none without a producer key, none for a retry.
+	if len(acks.acks) != 2 {
+		t.Fatalf("got %d acks, want 2: %+v", len(acks.acks), acks.acks)
+	}
+	if a := acks.acks[0]; a.producerKey != "key-a" || a.ack.EventID != "evt-ok" || a.ack.Outcome != domain.AckOutcomeProcessed {
+		t.Errorf("first ack = %+v, want evt-ok processed", a)
+	}
+	if a := acks.acks[1].ack; a.EventID != "evt-bad" || a.Outcome != domain.AckOutcomeFailed || a.Reason != "hash_mismatch" {
+		t.Errorf("second ack = %+v, want evt-bad failed/hash_mismatch", a)
+	}
+}
+
+func TestProcessorFake_InlineArchivedKeyPersisted(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	msg := fluxatest.Envelope("evt-sampled", fluxatest.NewEvent("evt-sampled").Payload(), nil)
+	key := "payloads/" + msg.PayloadSHA256
+	msg.S3Key = &key
+
+	if res, err := p.ProcessMessage(msg); err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("ProcessMessage = %+v, %v; want processed", res, err)
+	}
+	stored := d.store.Event("evt-sampled")
+	if stored == nil || stored.PayloadMode != domain.PayloadModeInline || stored.S3Key == nil || *stored.S3Key != key {
+		t.Errorf("stored event = %+v, want inline with s3_key %q", stored, key)
+	}
+}
+
+func TestProcessorFake_FailedArchivalQueuedWithEvent(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	payload := fluxatest.NewEvent("evt-unarchived").Payload()
+	msg := fluxatest.Envelope("evt-unarchived", payload, nil)
+	msg.ArchivePending = true
+
+	if res, err := p.ProcessMessage(msg); err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("ProcessMessage = %+v, %v; want processed", res, err)
+	}
+	stored := d.store.Event("evt-unarchived")
+	if stored == nil || stored.S3Key != nil || !bytes.Equal(stored.Event.PendingArchive, payload) {
+		t.Errorf("stored event = %+v, want no s3_key and the payload queued for archival", stored)
+	}
+}
+
+func TestProcessorFake_UpgradesOldSchemaVersion(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	var err error
+	p.Migrations, err = schemamigrate.New(schemamigrate.Migration{
+		From: "1", To: "2",
+		Rename:   map[string]string{"metadata.mcc": "metadata.category"},
+		Defaults: map[string]interface{}{"currency": "EUR"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := fluxatest.NewEvent("evt-v1").Schema("1").Currency("").Meta("mcc", "5411").Payload()
+
+	if res, err := p.ProcessMessage(fluxatest.InlineEnvelope("evt-v1", payload)); err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("ProcessMessage = %+v, %v; want processed", res, err)
+	}
+	stored := d.store.Event("evt-v1")
+	if stored == nil || stored.Event.SchemaVersion != "2" || stored.Event.Currency != "EUR" ||
+		stored.Event.Metadata["category"] != "5411" || stored.Event.Metadata["mcc"] != nil {
+		t.Errorf("stored event = %+v, want it upgraded to schema 2", stored)
+	}
+}
+
+func TestProcessorFake_ClientReferenceConflict(t *testing.T) {
+	p, d := newFakeProcessor(nil)
+	envelope := func(id, producer string) *domain.QueueMessage {
+		msg := fluxatest.InlineEnvelope(id, fluxatest.NewEvent(id).Ref("order-7").Payload())
+		msg.ProducerKey = producer
+		return msg
+	}
+
+	if res, err := p.ProcessMessage(envelope("evt-1", "producer-a")); err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("first = %+v, %v; want processed", res, err)
+	}
+	if stored := d.store.Event("evt-1"); stored == nil || stored.Event.ClientReference != "order-7" || stored.Event.ProducerKey != "producer-a" {
+		t.Fatalf("stored = %+v, want the reference and producer", stored)
+	}
+	// Another producer may use the same reference.
+	if res, err := p.ProcessMessage(envelope("evt-2", "producer-b")); err != nil || res.Outcome != OutcomeProcessed {
+		t.Fatalf("other producer = %+v, %v; want processed", res, err)
+	}
+
+	res, err := p.ProcessMessage(envelope("evt-3", "producer-a"))
+	if err != nil || !res.Ack() || res.Outcome != OutcomeFailed || res.Reason != "client_reference_conflict" {
+		t.Fatalf("reuse = %+v, %v; want ACKed client_reference_conflict", res, err)
+	}
+	rec := d.idemStatus(t, "evt-3")
+	if rec.ErrorReason == nil || !strings.Contains(*rec.ErrorReason, "evt-1") {
+		t.Errorf("error reason = %v, want it to name the event holding the reference", rec.ErrorReason)
+	}
+}
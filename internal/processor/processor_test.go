@@ -19,8 +19,9 @@ type noopMetrics struct{}
 
 func (n *noopMetrics) IncCounter(name string, labels ...string)                      {}
 func (n *noopMetrics) ObserveHistogram(name string, value float64, labels ...string) {}
+func (n *noopMetrics) SetGauge(name string, value float64, labels ...string)         {}
 
-func getTestDB(t *testing.T) *db.Client {
+func getTestDB(t testing.TB) *db.Client {
 	dsn := "host=localhost port=5432 user=fluxa_user password=fluxa_password dbname=fluxa sslmode=disable"
 	client, err := db.NewClient(dsn, 10)
 	if err != nil {
@@ -40,7 +41,7 @@ func TestProcessor_DuplicateMessage(t *testing.T) {
 		DB:          dbClient,
 		Idempotency: idempotency.NewClient(dbClient.GetDB()),
 		Storage:     nil, // Not needed for INLINE test
-		Publisher:   nil,
+		Notifier:    nil,
 		Fraud:       nil, // Skipped when nil
 		Metrics:     &noopMetrics{},
 		Logger:      logging.NewLogger("test", "test-corr-id"),
@@ -59,12 +60,23 @@ func TestProcessor_DuplicateMessage(t *testing.T) {
 		ReceivedAt:    time.Now(),
 	}
 
-	if err := proc.ProcessMessage(msg); err != nil {
+	res, err := proc.ProcessMessage(msg)
+	if err != nil {
 		t.Fatalf("First processing failed: %v", err)
 	}
-	if err := proc.ProcessMessage(msg); err != nil {
+	if res.Outcome != OutcomeProcessed || res.PayloadBytes != len(payload) {
+		t.Errorf("First result = %+v, want processed with %d payload bytes", res, len(payload))
+	}
+	if _, ok := res.Stages[StagePersist]; !ok {
+		t.Errorf("First result missing %q stage latency: %v", StagePersist, res.Stages)
+	}
+	res, err = proc.ProcessMessage(msg)
+	if err != nil {
 		t.Fatalf("Second processing (duplicate) failed: %v", err)
 	}
+	if res.Outcome != OutcomeDuplicate {
+		t.Errorf("Second result outcome = %q, want %q", res.Outcome, OutcomeDuplicate)
+	}
 
 	var count int
 	if err := dbClient.GetDB().QueryRow("SELECT COUNT(*) FROM events WHERE event_id = $1", eventID).Scan(&count); err != nil {
@@ -100,9 +112,13 @@ func TestProcessor_HashMismatch_NoRetry(t *testing.T) {
 	}
 
 	// Process should return nil (permanent failure — ACK'd) but mark idempotency as failed
-	if err := proc.ProcessMessage(msg); err != nil {
+	res, err := proc.ProcessMessage(msg)
+	if err != nil {
 		t.Errorf("Expected nil error (permanent failure), got %v", err)
 	}
+	if res.Outcome != OutcomeFailed || res.Reason != "hash_mismatch" || !res.Ack() {
+		t.Errorf("Result = %+v, want failed/hash_mismatch", res)
+	}
 
 	status, err := idemClient.GetStatus(eventID)
 	if err != nil {
@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// Outcome classifies how a message left the pipeline.
+type Outcome string
+
+const (
+	OutcomeProcessed Outcome = "processed" // persisted; ACK
+	OutcomeDuplicate Outcome = "duplicate" // idempotency hit, nothing done; ACK
+	OutcomeFailed    Outcome = "failed"    // permanent failure, marked failed; ACK
+	OutcomeRetry     Outcome = "retry"     // transient failure; NACK for redelivery
+	// OutcomeRepublished: an earlier attempt persisted the event but never settled
+	// it; only its notifications were sent again. ACK
+	OutcomeRepublished Outcome = "republished"
+)
+
+// Pipeline stages, used as ProcessResult.Stages keys. A stage is absent when the
+// message never reached it.
+const (
+	StageIdempotency = "idempotency"
+	StageFetch       = "fetch"   // inline decode or object-store GET
+	StageDecode      = "decode"  // hash check, unmarshal, normalize, validate
+	StageEnrich      = "enrich"  // merchant canonicalization, profile enrichment, anomaly scoring
+	StagePersist     = "persist" // InsertEvent
+	StageFraud       = "fraud"
+)
+
+// ProcessResult describes one ProcessMessage call, so callers (the consumer loop,
+// tests) can act on the outcome without inspecting the database.
+type ProcessResult struct {
+	EventID      string
+	Outcome      Outcome
+	Reason       string // failure reason (e.g. "hash_mismatch"); empty on success/duplicate
+	Attempt      int    // claim number of the event ID (1 on first delivery); 0 for duplicates
+	PayloadBytes int
+	Canary       bool
+	Members      []string              // member event IDs of an atomic batch; nil otherwise
+	Failures     []domain.BatchFailure // members of a partial batch that were not persisted
+	Stages       map[string]time.Duration
+	Total        time.Duration
+
+	// events are the decoded events (the member events for an atomic batch), for
+	// the acks' templates.
+	events []*domain.Event
+	// persisted are the members of a partial batch that were stored.
+	persisted []*domain.Event
+	// dequeuedAt is when the processor picked the message up, for the events'
+	// timelines.
+	dequeuedAt time.Time
+	// claimed is set once this delivery holds the event's idempotency claim.
+	claimed bool
+}
+
+// Ack reports whether the delivery should be acknowledged (everything except OutcomeRetry).
+func (r *ProcessResult) Ack() bool {
+	return r.Outcome != OutcomeRetry
+}
+
+// stageOrder lists the stages in the order they run.
+var stageOrder = []string{StageIdempotency, StageFetch, StageDecode, StageEnrich, StagePersist, StageFraud}
+
+// completedStages lists the stages r got through, comma-separated, or "none".
+func (r *ProcessResult) completedStages() string {
+	var done []string
+	for _, s := range stageOrder {
+		if _, ok := r.Stages[s]; ok {
+			done = append(done, s)
+		}
+	}
+	if len(done) == 0 {
+		return "none"
+	}
+	return strings.Join(done, ", ")
+}
+
+// timeStage records the elapsed time since start under stage.
+func (r *ProcessResult) timeStage(stage string, start time.Time) {
+	r.Stages[stage] = time.Since(start)
+}
+
+// failureReason extracts the typed-error reason, falling back to the error text.
+func failureReason(err error) string {
+	var nr *domain.NonRetryableError
+	if errors.As(err, &nr) {
+		return nr.Reason
+	}
+	var re *domain.RetryableError
+	if errors.As(err, &re) {
+		return re.Reason
+	}
+	return err.Error()
+}
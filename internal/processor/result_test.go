@@ -0,0 +1,39 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{domain.NewNonRetryableError("hash_mismatch", nil), "hash_mismatch"},
+		{domain.NewRetryableError("db_insert_failed", errors.New("timeout")), "db_insert_failed"},
+		{fmt.Errorf("wrapped: %w", domain.NewRetryableError("storage_fetch_failed", nil)), "storage_fetch_failed"},
+		{errors.New("plain"), "plain"},
+	}
+	for _, tt := range tests {
+		if got := failureReason(tt.err); got != tt.want {
+			t.Errorf("failureReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestProcessResult_Ack(t *testing.T) {
+	for outcome, want := range map[Outcome]bool{
+		OutcomeProcessed: true,
+		OutcomeDuplicate: true,
+		OutcomeFailed:    true,
+		OutcomeRetry:     false,
+	} {
+		if got := (&ProcessResult{Outcome: outcome}).Ack(); got != want {
+			t.Errorf("Ack() for %q = %v, want %v", outcome, got, want)
+		}
+	}
+}
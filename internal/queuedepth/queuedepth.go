@@ -0,0 +1,65 @@
+// Package queuedepth polls the broker for queue backlogs and publishes them as
+// Fluxa gauges (queue_messages, queue_consumers, labelled by queue), so backlog
+// alerts live beside the pipeline's own metrics instead of in the broker's.
+package queuedepth
+
+import (
+	"context"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// DefaultInterval is the poll interval when Exporter.Interval is zero.
+const DefaultInterval = 15 * time.Second
+
+// Inspector reports a queue's ready-message and consumer counts; the RabbitMQ
+// adapter implements it.
+type Inspector interface {
+	QueueStats(ctx context.Context, queue string) (messages, consumers int, err error)
+}
+
+// Exporter polls Queues through Inspector and sets the gauges.
+type Exporter struct {
+	Inspector Inspector
+	Queues    []string
+	Interval  time.Duration
+	Metrics   ports.Metrics
+	Logger    *logging.Logger
+}
+
+// Run polls immediately and then every Interval until ctx is done.
+func (e *Exporter) Run(ctx context.Context) {
+	interval := e.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll reads every queue once. A queue that can't be read keeps its last
+// value and is logged; the others are still updated.
+func (e *Exporter) Poll(ctx context.Context) {
+	for _, q := range e.Queues {
+		messages, consumers, err := e.Inspector.QueueStats(ctx, q)
+		if err != nil {
+			if ctx.Err() == nil && e.Logger != nil {
+				e.Logger.Warn("Queue depth poll failed", map[string]interface{}{"queue": q, "error": err.Error()})
+			}
+			continue
+		}
+		e.Metrics.SetGauge(metricdef.QueueMessages, float64(messages), "queue", q)
+		e.Metrics.SetGauge(metricdef.QueueConsumers, float64(consumers), "queue", q)
+	}
+}
@@ -0,0 +1,43 @@
+package queuedepth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+type fakeInspector map[string][2]int
+
+func (f fakeInspector) QueueStats(_ context.Context, queue string) (int, int, error) {
+	s, ok := f[queue]
+	if !ok {
+		return 0, 0, errors.New("no such queue")
+	}
+	return s[0], s[1], nil
+}
+
+func TestPoll(t *testing.T) {
+	metrics := fluxatest.NewMetrics()
+	e := &Exporter{
+		Inspector: fakeInspector{"events": {42, 3}, "alerts": {0, 1}},
+		Queues:    []string{"events", "missing", "alerts"},
+		Metrics:   metrics,
+	}
+	e.Poll(context.Background())
+
+	want := map[string][2]float64{"events": {42, 3}, "alerts": {0, 1}}
+	for q, w := range want {
+		if v, ok := metrics.Gauge(metricdef.QueueMessages, q); !ok || v != w[0] {
+			t.Errorf("queue_messages{queue=%s} = %v, %v; want %v", q, v, ok, w[0])
+		}
+		if v, ok := metrics.Gauge(metricdef.QueueConsumers, q); !ok || v != w[1] {
+			t.Errorf("queue_consumers{queue=%s} = %v, %v; want %v", q, v, ok, w[1])
+		}
+	}
+	if _, ok := metrics.Gauge(metricdef.QueueMessages, "missing"); ok {
+		t.Error("queue_messages set for a queue that failed to poll")
+	}
+}
@@ -0,0 +1,148 @@
+// Package ratelimit throttles producers with a token bucket per key. Each bucket
+// refills at its rate up to its burst, and a request takes one token per event
+// it carries, so a steady producer under its rate is never limited and a
+// runaway one is held to it. Callers that would rather be paced than refused
+// Wait for their tokens instead: the processor does, toward the database.
+//
+// Buckets live in the process: each ingest replica limits on its own, so a
+// producer's fleet-wide rate is the configured one times the replicas its
+// requests are spread over.
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrLimited is returned by Wait when the tokens won't be there in time.
+var ErrLimited = errors.New("ratelimit: rate limit exceeded")
+
+// DefaultMaxKeys bounds the buckets kept when Options.MaxKeys is 0.
+const DefaultMaxKeys = 10000
+
+// Options configures a Limiter.
+type Options struct {
+	// PerSecond is the rate each key refills at; 0 leaves keys unlimited.
+	PerSecond float64
+	// Burst is how many tokens a full bucket holds, never less than one second's
+	// worth of its rate.
+	Burst int
+	// Overrides sets the rate of individual keys; 0 exempts a key.
+	Overrides map[string]float64
+	// MaxKeys bounds the buckets kept. The least recently used is dropped to make
+	// room, which refills it: size MaxKeys above the number of active producers.
+	MaxKeys int
+}
+
+// Limiter holds a token bucket per key. A nil *Limiter allows everything. Safe for
+// concurrent use.
+type Limiter struct {
+	opts Options
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List // front = most recently used
+	buckets map[string]*list.Element
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	at     time.Time
+}
+
+// New returns a Limiter, or nil when no key would ever be limited.
+func New(opts Options) *Limiter {
+	limited := opts.PerSecond > 0
+	for _, rate := range opts.Overrides {
+		limited = limited || rate > 0
+	}
+	if !limited {
+		return nil
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = DefaultMaxKeys
+	}
+	return &Limiter{opts: opts, now: time.Now, order: list.New(), buckets: map[string]*list.Element{}}
+}
+
+// Limit returns the rate and burst that apply to key; a zero rate is unlimited.
+func (l *Limiter) Limit(key string) (perSecond float64, burst int) {
+	if l == nil {
+		return 0, 0
+	}
+	perSecond = l.opts.PerSecond
+	if rate, ok := l.opts.Overrides[key]; ok {
+		perSecond = rate
+	}
+	if perSecond <= 0 {
+		return 0, 0
+	}
+	return perSecond, max(l.opts.Burst, int(math.Ceil(perSecond)))
+}
+
+// Take takes n tokens from key's bucket. When they are not there it takes none
+// and returns how long until they will be. n above the burst is charged as the
+// burst, so a large batch still gets through from a full bucket.
+func (l *Limiter) Take(key string, n int) (ok bool, retryAfter time.Duration) {
+	rate, burst := l.Limit(key)
+	if rate <= 0 {
+		return true, 0
+	}
+	need := float64(min(max(n, 1), burst))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b := l.bucket(key, float64(burst), now)
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.at).Seconds()*rate)
+	b.at = now
+	if b.tokens >= need {
+		b.tokens -= need
+		return true, 0
+	}
+	return false, time.Duration((need - b.tokens) / rate * float64(time.Second))
+}
+
+// Wait takes n tokens from key's bucket as Take does, sleeping until they are
+// there. It gives up without them when they won't be within maxWait, returning
+// ErrLimited, or when ctx ends first, returning its error.
+func (l *Limiter) Wait(ctx context.Context, key string, n int, maxWait time.Duration) error {
+	deadline := l.now().Add(maxWait)
+	for {
+		ok, retryAfter := l.Take(key, n)
+		if ok {
+			return nil
+		}
+		if l.now().Add(retryAfter).After(deadline) {
+			return ErrLimited
+		}
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// bucket returns key's bucket, creating a full one. Callers hold mu.
+func (l *Limiter) bucket(key string, full float64, now time.Time) *bucket {
+	if el, ok := l.buckets[key]; ok {
+		l.order.MoveToFront(el)
+		return el.Value.(*bucket)
+	}
+	b := &bucket{key: key, tokens: full, at: now}
+	l.buckets[key] = l.order.PushFront(b)
+	for l.order.Len() > l.opts.MaxKeys {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*bucket).key)
+	}
+	return b
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(opts Options) (*Limiter, *time.Time) {
+	l := New(opts)
+	now := time.Unix(1_760_000_000, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestTake_RefillsAtRate(t *testing.T) {
+	l, now := newTestLimiter(Options{PerSecond: 2, Burst: 4})
+	for i := 0; i < 4; i++ {
+		if ok, _ := l.Take("k", 1); !ok {
+			t.Fatalf("take %d from a full bucket refused", i+1)
+		}
+	}
+	ok, retry := l.Take("k", 1)
+	if ok || retry != 500*time.Millisecond {
+		t.Errorf("Take on empty bucket = %v, %v; want refused, retry in 500ms", ok, retry)
+	}
+	if ok, _ := l.Take("other", 1); !ok {
+		t.Errorf("another key was limited by k's bucket")
+	}
+	*now = now.Add(time.Second)
+	if ok, _ := l.Take("k", 2); !ok {
+		t.Errorf("Take after 1s at 2/s refused")
+	}
+}
+
+func TestTake_CapsCostAtBurst(t *testing.T) {
+	l, _ := newTestLimiter(Options{PerSecond: 10, Burst: 20})
+	if ok, _ := l.Take("k", 1000); !ok {
+		t.Errorf("a batch larger than the burst was refused from a full bucket")
+	}
+	if ok, retry := l.Take("k", 1000); ok || retry != 2*time.Second {
+		t.Errorf("second large batch = %v, %v; want refused, retry in 2s", ok, retry)
+	}
+}
+
+func TestLimit_Overrides(t *testing.T) {
+	l, _ := newTestLimiter(Options{PerSecond: 5, Overrides: map[string]float64{"trusted": 0, "bulk": 50}})
+	if rate, burst := l.Limit("anyone"); rate != 5 || burst != 5 {
+		t.Errorf("Limit(anyone) = %v, %v; want 5/s with a burst of a second's worth", rate, burst)
+	}
+	if rate, burst := l.Limit("bulk"); rate != 50 || burst != 50 {
+		t.Errorf("Limit(bulk) = %v, %v; want the override", rate, burst)
+	}
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Take("trusted", 1); !ok {
+			t.Fatalf("exempt key limited after %d takes", i)
+		}
+	}
+	if New(Options{Overrides: map[string]float64{"trusted": 0}}) != nil {
+		t.Errorf("New without any limited key should be nil")
+	}
+	var none *Limiter
+	if ok, _ := none.Take("k", 1); !ok {
+		t.Errorf("nil Limiter limited a request")
+	}
+}
+
+func TestWait(t *testing.T) {
+	l := New(Options{PerSecond: 50})
+	ctx := context.Background()
+	if ok, _ := l.Take("", 50); !ok {
+		t.Fatal("draining a full bucket refused")
+	}
+	if err := l.Wait(ctx, "", 1, 0); !errors.Is(err, ErrLimited) {
+		t.Errorf("Wait with no time to wait = %v, want ErrLimited", err)
+	}
+	start := time.Now()
+	if err := l.Wait(ctx, "", 1, time.Second); err != nil {
+		t.Fatalf("Wait = %v", err)
+	}
+	if d := time.Since(start); d < 10*time.Millisecond {
+		t.Errorf("Wait on an empty bucket at 50/s took %v, want about 20ms", d)
+	}
+	slow := New(Options{PerSecond: 1})
+	slow.Take("", 1)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := slow.Wait(cancelled, "", 1, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait after cancel = %v, want context.Canceled", err)
+	}
+}
+
+func TestTake_EvictsLeastRecentlyUsed(t *testing.T) {
+	l, _ := newTestLimiter(Options{PerSecond: 1, Burst: 1, MaxKeys: 2})
+	l.Take("a", 1)
+	l.Take("b", 1)
+	l.Take("c", 1)
+	if _, ok := l.buckets["a"]; ok || len(l.buckets) != 2 {
+		t.Errorf("buckets = %d with a kept; want a evicted and 2 left", len(l.buckets))
+	}
+}
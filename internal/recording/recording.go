@@ -0,0 +1,333 @@
+// Package recording keeps redacted copies of sampled ingest requests and their
+// responses in object storage, so a producer-specific parsing bug can be
+// reproduced from what the producer actually sent. It runs only while the
+// record_requests feature flag is on, for RECORDING_SAMPLE_PERCENT of correlation
+// IDs (optionally only RECORDING_PRODUCERS), and a recording is read back by its
+// correlation ID (query GET /admin/recordings/{correlation_id}) for
+// RECORDING_RETENTION_DAYS, after which a bucket lifecycle rule deletes it.
+//
+// Secrets and PII are redacted before anything is stored: credential headers,
+// user_id values and the string values under metadata. Other JSON values keep
+// their types, which is what a parsing bug usually turns on. A body that isn't
+// JSON (protobuf) can't be redacted, so only its size and hash are kept.
+package recording
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/google/uuid"
+)
+
+// MaxBodyBytes is the largest request or response body kept; a bigger one is
+// recorded as truncated, without its body.
+const MaxBodyBytes = 1 << 20
+
+// Prefix is where recordings are stored.
+const Prefix = "recordings"
+
+// redacted replaces every removed value.
+const redacted = "[redacted]"
+
+// Recording is one recorded request and its response.
+type Recording struct {
+	CorrelationID string    `json:"correlation_id"`
+	Service       string    `json:"service"`
+	RecordedAt    time.Time `json:"recorded_at"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	Query         string    `json:"query,omitempty"`
+	ProducerKey   string    `json:"producer_key,omitempty"`
+	Request       Message   `json:"request"`
+	Response      Message   `json:"response"`
+	LatencyMs     float64   `json:"latency_ms"`
+}
+
+// Message is one side of a Recording. Body is the redacted JSON body, absent when
+// the body was empty, not JSON or Truncated (over MaxBodyBytes). BodyBytes and
+// BodySHA256 describe the body as sent, except for a truncated one.
+type Message struct {
+	Status     int               `json:"status,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+	BodyBytes  int               `json:"body_bytes"`
+	BodySHA256 string            `json:"body_sha256,omitempty"`
+	Truncated  bool              `json:"truncated,omitempty"`
+}
+
+// Key is the object key of the recording for correlationID. The ID is hashed:
+// producers choose it, so it can't be trusted as a path.
+func Key(correlationID string) string {
+	sum := sha256.Sum256([]byte(correlationID))
+	return Prefix + "/" + hex.EncodeToString(sum[:]) + ".json"
+}
+
+// Load reads the recording for correlationID. One missing, or recorded more than
+// retention before now (its object may not have been expired yet), is a
+// *domain.NotFoundError.
+func Load(ctx context.Context, storage ports.Storage, correlationID string, retention time.Duration, now time.Time) (*Recording, error) {
+	body, err := storage.Get(ctx, Key(correlationID))
+	if err != nil {
+		return nil, err
+	}
+	var recording Recording
+	if err := json.Unmarshal(body, &recording); err != nil {
+		return nil, err
+	}
+	if now.Sub(recording.RecordedAt) > retention {
+		return nil, domain.NewNotFoundError("recording", nil)
+	}
+	return &recording, nil
+}
+
+// Sampled reports whether correlationID falls in a sample of percent (0-100).
+// Like archival sampling it is a function of the ID, so a retry carrying the
+// same correlation ID is recorded again.
+func Sampled(correlationID string, percent float64) bool {
+	sum := sha256.Sum256([]byte(correlationID))
+	return domain.SampledForArchive(hex.EncodeToString(sum[:]), percent)
+}
+
+// Recorder records the requests of the handlers it wraps.
+type Recorder struct {
+	Service string
+	// Storage returns the object store; ingest connects to it lazily.
+	Storage       func() (ports.Storage, error)
+	Flags         *featureflags.Flags
+	SamplePercent float64
+	// Producers limits recording to these hashed API keys; empty records every
+	// producer.
+	Producers map[string]bool
+	// RetentionDays is how long recordings are kept. The first store sets it on
+	// the bucket when the storage can (Expirer).
+	RetentionDays int
+	Metrics       ports.Metrics
+	Logger        *logging.Logger
+
+	expiryMu  sync.Mutex
+	expirySet bool
+}
+
+// Expirer deletes the objects under a prefix some days after they are written;
+// the MinIO adapter implements it with a bucket lifecycle rule.
+type Expirer interface {
+	ExpirePrefix(ctx context.Context, prefix string, days int) error
+}
+
+// Wrap records the sampled requests next serves. A request without an
+// X-Correlation-ID is given one first, so every recording can be found. The
+// recording is stored in the background and never affects the response.
+func (rec *Recorder) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !rec.Flags.Enabled(featureflags.RecordRequests) {
+			next(w, r)
+			return
+		}
+		correlationID := r.Header.Get("X-Correlation-ID")
+		if correlationID == "" {
+			correlationID = uuid.New().String()
+			r.Header.Set("X-Correlation-ID", correlationID)
+		}
+		producerKey := ""
+		if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+			producerKey = domain.HashAPIKey(key)
+		}
+		if !Sampled(correlationID, rec.SamplePercent) || (len(rec.Producers) > 0 && !rec.Producers[producerKey]) {
+			next(w, r)
+			return
+		}
+
+		start := time.Now()
+		reqBody, err := io.ReadAll(io.LimitReader(r.Body, MaxBodyBytes+1))
+		if err != nil {
+			next(w, r)
+			return
+		}
+		// Hand next the bytes already read followed by the rest, unchanged.
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(reqBody), r.Body), r.Body}
+		cw := &capture{ResponseWriter: w, status: http.StatusOK}
+		next(cw, r)
+
+		recording := &Recording{
+			CorrelationID: correlationID,
+			Service:       rec.Service,
+			RecordedAt:    start.UTC(),
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Query:         r.URL.RawQuery,
+			ProducerKey:   producerKey,
+			Request:       message(0, r.Header, reqBody),
+			Response:      message(cw.status, w.Header(), cw.body.Bytes()),
+			LatencyMs:     float64(time.Since(start).Microseconds()) / 1000,
+		}
+		go rec.store(recording)
+	}
+}
+
+// store writes recording, counting the outcome. Failures are logged only.
+func (rec *Recorder) store(recording *Recording) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	body, err := json.Marshal(recording)
+	if err == nil {
+		var storage ports.Storage
+		if storage, err = rec.Storage(); err == nil {
+			rec.setExpiry(ctx, storage)
+			err = storage.Put(ctx, Key(recording.CorrelationID), body)
+		}
+	}
+	if err != nil {
+		rec.Metrics.IncCounter(metricdef.RequestRecordingsTotal, "result", "failed")
+		rec.Logger.Warn("Failed to store request recording", map[string]interface{}{
+			"correlation_id": recording.CorrelationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+	rec.Metrics.IncCounter(metricdef.RequestRecordingsTotal, "result", "recorded")
+}
+
+// setExpiry applies RetentionDays to storage once. A failure is logged and
+// tried again with the next recording.
+func (rec *Recorder) setExpiry(ctx context.Context, storage ports.Storage) {
+	expirer, ok := storage.(Expirer)
+	if !ok || rec.RetentionDays <= 0 {
+		return
+	}
+	rec.expiryMu.Lock()
+	defer rec.expiryMu.Unlock()
+	if rec.expirySet {
+		return
+	}
+	if err := expirer.ExpirePrefix(ctx, Prefix, rec.RetentionDays); err != nil {
+		rec.Logger.Warn("Failed to set recording retention", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	rec.expirySet = true
+}
+
+// message builds a redacted Message from headers and body. A body of more than
+// MaxBodyBytes arrives cut at MaxBodyBytes+1.
+func message(status int, headers http.Header, body []byte) Message {
+	m := Message{Status: status, Headers: redactHeaders(headers)}
+	if len(body) > MaxBodyBytes {
+		m.Truncated = true
+		return m
+	}
+	m.BodyBytes = len(body)
+	if len(body) == 0 {
+		return m
+	}
+	sum := sha256.Sum256(body)
+	m.BodySHA256 = hex.EncodeToString(sum[:])
+	if kept, ok := redactBody(body); ok {
+		m.Body = kept
+	}
+	return m
+}
+
+// secretHeaderParts mark headers whose values are credentials.
+var secretHeaderParts = []string{"key", "token", "secret", "signature", "auth", "cookie"}
+
+// redactHeaders flattens headers, replacing credential values.
+func redactHeaders(headers http.Header) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(headers))
+	for name, values := range headers {
+		lower := strings.ToLower(name)
+		value := strings.Join(values, ", ")
+		for _, part := range secretHeaderParts {
+			if strings.Contains(lower, part) {
+				value = redacted
+				break
+			}
+		}
+		out[name] = value
+	}
+	return out
+}
+
+// redactBody returns body with user_id values and the strings under metadata
+// replaced, or false when body isn't JSON. Numbers are decoded as json.Number so
+// they are re-encoded exactly as sent.
+func redactBody(body []byte) (json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	out, err := json.Marshal(redactValue(v, false))
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// redactValue redacts v; under is true below a metadata key, where every string
+// goes.
+func redactValue(v interface{}, under bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			switch {
+			case k == "user_id":
+				t[k] = redacted
+			default:
+				t[k] = redactValue(child, under || k == "metadata")
+			}
+		}
+		return t
+	case []interface{}:
+		for i, child := range t {
+			t[i] = redactValue(child, under)
+		}
+		return t
+	case string:
+		if under {
+			return redacted
+		}
+	}
+	return v
+}
+
+// capture keeps a copy of the response body, up to MaxBodyBytes+1 bytes.
+type capture struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *capture) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *capture) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if room := MaxBodyBytes + 1 - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(len(b), room)])
+	}
+	return w.ResponseWriter.Write(b)
+}
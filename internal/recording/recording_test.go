@@ -0,0 +1,146 @@
+package recording
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// flagSource turns record_requests on.
+type flagSource struct{}
+
+func (flagSource) Load(context.Context) (map[featureflags.Flag]bool, error) {
+	return map[featureflags.Flag]bool{featureflags.RecordRequests: true}, nil
+}
+
+func newRecorder(storage *fluxatest.Storage, m *fluxatest.Metrics) *Recorder {
+	return &Recorder{
+		Service:       "ingest",
+		Storage:       func() (ports.Storage, error) { return storage, nil },
+		Flags:         featureflags.New(time.Minute, nil, flagSource{}),
+		SamplePercent: 100,
+		RetentionDays: 3,
+		Metrics:       m,
+		Logger:        logging.NewLogger("test", "test"),
+	}
+}
+
+// waitFor polls storage for the recording of correlationID, stored in the background.
+func waitFor(t *testing.T, storage *fluxatest.Storage, correlationID string) *Recording {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if rec, err := Load(context.Background(), storage, correlationID, time.Hour, time.Now()); err == nil {
+			return rec
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no recording stored for %q", correlationID)
+	return nil
+}
+
+func TestRecorder_RecordsRedactedExchange(t *testing.T) {
+	storage, m := fluxatest.NewStorage(), fluxatest.NewMetrics()
+	var seen string
+	handler := newRecorder(storage, m).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		seen = string(body)
+		w.Header().Set("X-Correlation-ID", r.Header.Get("X-Correlation-ID"))
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid amount"}`))
+	})
+
+	body := `{"event_id":"evt-1","user_id":"alice","amount":"12.50","metadata":{"email":"a@example.com","items":[{"sku":"x","qty":2}]}}`
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+	req.Header.Set("X-Correlation-ID", "corr-1")
+	req.Header.Set("X-API-Key", "secret-key")
+	req.Header.Set("Content-Type", "application/json")
+	handler(httptest.NewRecorder(), req)
+
+	if seen != body {
+		t.Errorf("handler read %q, want the body unchanged", seen)
+	}
+	rec := waitFor(t, storage, "corr-1")
+	if rec.Response.Status != http.StatusBadRequest || string(rec.Response.Body) != `{"error":"invalid amount"}` {
+		t.Errorf("response = %+v, want the 400 and its body", rec.Response)
+	}
+	if rec.ProducerKey != domain.HashAPIKey("secret-key") || rec.Request.Headers["X-Api-Key"] != redacted {
+		t.Errorf("producer %q, key header %q; want the hash and the header redacted", rec.ProducerKey, rec.Request.Headers["X-Api-Key"])
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(rec.Request.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	meta := got["metadata"].(map[string]interface{})
+	item := meta["items"].([]interface{})[0].(map[string]interface{})
+	if got["user_id"] != redacted || meta["email"] != redacted || item["sku"] != redacted {
+		t.Errorf("request body = %s, want user_id and metadata strings redacted", rec.Request.Body)
+	}
+	if got["amount"] != "12.50" || item["qty"] != float64(2) {
+		t.Errorf("request body = %s, want other values and types kept", rec.Request.Body)
+	}
+	if rec.Request.BodyBytes != len(body) {
+		t.Errorf("body_bytes = %d, want %d", rec.Request.BodyBytes, len(body))
+	}
+	if got := m.Counter(metricdef.RequestRecordingsTotal, "recorded"); got != 1 {
+		t.Errorf("request_recordings_total{recorded} = %d, want 1", got)
+	}
+}
+
+func TestRecorder_AssignsCorrelationID(t *testing.T) {
+	storage := fluxatest.NewStorage()
+	var correlationID string
+	handler := newRecorder(storage, fluxatest.NewMetrics()).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		correlationID = r.Header.Get("X-Correlation-ID")
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/events", strings.NewReader("\x08\x01")))
+
+	if correlationID == "" {
+		t.Fatal("handler saw no correlation ID")
+	}
+	rec := waitFor(t, storage, correlationID)
+	if rec.Request.Body != nil || rec.Request.BodyBytes != 2 || rec.Request.BodySHA256 == "" {
+		t.Errorf("request = %+v, want a non-JSON body kept as size and hash only", rec.Request)
+	}
+}
+
+func TestRecorder_Skips(t *testing.T) {
+	storage := fluxatest.NewStorage()
+	off := newRecorder(storage, fluxatest.NewMetrics())
+	off.Flags = nil // record_requests defaults off
+	other := newRecorder(storage, fluxatest.NewMetrics())
+	other.Producers = map[string]bool{domain.HashAPIKey("someone-else"): true}
+
+	for name, rec := range map[string]*Recorder{"flag off": off, "other producer": other} {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{}`))
+		req.Header.Set("X-Correlation-ID", "corr-"+name)
+		rec.Wrap(func(http.ResponseWriter, *http.Request) {})(httptest.NewRecorder(), req)
+		time.Sleep(20 * time.Millisecond)
+		if ok, _ := storage.Exists(context.Background(), Key("corr-"+name)); ok {
+			t.Errorf("%s: request was recorded", name)
+		}
+	}
+}
+
+func TestLoad_ExpiredIsNotFound(t *testing.T) {
+	storage := fluxatest.NewStorage()
+	body, _ := json.Marshal(Recording{CorrelationID: "corr-old", RecordedAt: time.Now().Add(-4 * 24 * time.Hour)})
+	_ = storage.Put(context.Background(), Key("corr-old"), body)
+
+	_, err := Load(context.Background(), storage, "corr-old", 3*24*time.Hour, time.Now())
+	if _, ok := err.(*domain.NotFoundError); !ok {
+		t.Errorf("Load = %v, want not found past retention", err)
+	}
+}
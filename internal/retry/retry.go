@@ -0,0 +1,86 @@
+// Package retry repeats an operation that failed transiently, with jittered
+// exponential backoff, inside the caller's deadline. Only *domain.RetryableError
+// failures are retried; anything else is returned at once.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// Defaults for a zero Policy.Base and Policy.Max.
+const (
+	DefaultBase = 50 * time.Millisecond
+	DefaultMax  = time.Second
+)
+
+// Policy bounds Do. Attempts counts the first call, so 1 (or less) never retries.
+// The n-th retry waits a random time up to Base·2ⁿ⁻¹, capped at Max ("full
+// jitter"), so callers failing together don't retry together.
+type Policy struct {
+	Attempts int
+	Base     time.Duration
+	Max      time.Duration
+}
+
+// Do calls fn until it succeeds, fails for good, or p.Attempts is used up, and
+// returns its last error. A retry whose wait would run past ctx's deadline is not
+// made: the error fn returned is more useful than a deadline. onRetry, when set,
+// is told about each retry before its wait.
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error, onRetry func(attempt int, err error)) error {
+	base, max := p.Base, p.Max
+	if base <= 0 {
+		base = DefaultBase
+	}
+	if max <= 0 {
+		max = DefaultMax
+	}
+	backoff := base
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.Attempts || !Retryable(err) {
+			return err
+		}
+		wait := time.Duration(rand.Int63n(int64(backoff) + 1))
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(attempt, err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > max {
+			backoff = max
+		}
+	}
+}
+
+// Retryable reports whether err is a transient failure worth another attempt.
+func Retryable(err error) bool {
+	var retryable *domain.RetryableError
+	return errors.As(err, &retryable)
+}
+
+// Reason returns the reason of the *domain.RetryableError or
+// *domain.NonRetryableError in err's chain, or "".
+func Reason(err error) string {
+	var retryable *domain.RetryableError
+	if errors.As(err, &retryable) {
+		return retryable.Reason
+	}
+	var permanent *domain.NonRetryableError
+	if errors.As(err, &permanent) {
+		return permanent.Reason
+	}
+	return ""
+}
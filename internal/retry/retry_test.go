@@ -0,0 +1,67 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+func TestDo_RetriesTransientFailures(t *testing.T) {
+	calls, retries := 0, 0
+	err := Do(context.Background(), Policy{Attempts: 3, Base: time.Millisecond}, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return domain.NewRetryableError("queue_throttled", errors.New("resource error"))
+		}
+		return nil
+	}, func(int, error) { retries++ })
+	if err != nil || calls != 3 || retries != 2 {
+		t.Errorf("Do = %v after %d calls, %d retries; want nil after 3, 2", err, calls, retries)
+	}
+}
+
+func TestDo_StopsOnPermanentFailure(t *testing.T) {
+	calls := 0
+	want := domain.NewNonRetryableError("queue_rejected", nil)
+	err := Do(context.Background(), Policy{Attempts: 3, Base: time.Millisecond}, func(context.Context) error {
+		calls++
+		return want
+	}, nil)
+	if err != want || calls != 1 {
+		t.Errorf("Do = %v after %d calls, want the permanent error after 1", err, calls)
+	}
+	if got := Reason(err); got != "queue_rejected" {
+		t.Errorf("Reason = %q, want queue_rejected", got)
+	}
+}
+
+func TestDo_GivesUp(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), Policy{Attempts: 2, Base: time.Millisecond}, func(context.Context) error {
+		calls++
+		return domain.NewRetryableError("queue_error", nil)
+	}, nil)
+	if !Retryable(err) || calls != 2 {
+		t.Errorf("Do = %v after %d calls, want the last retryable error after 2", err, calls)
+	}
+}
+
+func TestDo_StaysInsideDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	calls := 0
+	start := time.Now()
+	err := Do(ctx, Policy{Attempts: 10, Base: time.Second, Max: time.Second}, func(context.Context) error {
+		calls++
+		return domain.NewRetryableError("queue_timeout", nil)
+	}, nil)
+	if !Retryable(err) {
+		t.Errorf("Do = %v, want the last error rather than a deadline", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Do took %v, want it to stop at the 20ms deadline", d)
+	}
+}
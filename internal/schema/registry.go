@@ -0,0 +1,120 @@
+package schema
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// baseSchema is the schema every event is validated against. It covers the
+// fields domain.Event's built-in checks do.
+//
+//go:embed schemas/event.json
+var baseSchema []byte
+
+// Registry holds the base event schema and the schema of each event type. It
+// implements domain.EventSchemas. Safe for concurrent use.
+type Registry struct {
+	base  *Schema
+	types map[string]*Schema
+}
+
+// New returns a Registry of the embedded base schema and types, compiled schemas
+// by event type name.
+func New(types map[string]*Schema) (*Registry, error) {
+	base, err := Compile(baseSchema)
+	if err != nil {
+		return nil, fmt.Errorf("schema: base event schema: %w", err)
+	}
+	for name := range types {
+		if !domain.ValidEventType(name) {
+			return nil, fmt.Errorf("schema: invalid event type name %q", name)
+		}
+	}
+	return &Registry{base: base, types: types}, nil
+}
+
+// Load returns a Registry with the schema of each of types, read from store at
+// prefix + "<type>.json" (EVENT_SCHEMA_PREFIX, EVENT_SCHEMA_TYPES). A schema
+// that is missing or doesn't compile fails the load.
+func Load(ctx context.Context, store ports.Storage, prefix string, types []string) (*Registry, error) {
+	compiled := make(map[string]*Schema, len(types))
+	for _, name := range types {
+		if !domain.ValidEventType(name) {
+			return nil, fmt.Errorf("schema: invalid event type name %q", name)
+		}
+		raw, err := store.Get(ctx, prefix+name+".json")
+		if err != nil {
+			return nil, fmt.Errorf("schema: read %s%s.json: %w", prefix, name, err)
+		}
+		if compiled[name], err = Compile(raw); err != nil {
+			return nil, fmt.Errorf("schema: event type %s: %w", name, err)
+		}
+	}
+	return New(compiled)
+}
+
+// Types returns the registered event types, sorted.
+func (r *Registry) Types() []string {
+	return sortedKeys(r.types)
+}
+
+// ValidateEvent validates e, normalized, against the base schema and then the
+// schema of its event type. A violation is a domain.ErrInvalidEvent whose Field
+// is the path of the offending value, e.g. "metadata.order_id".
+func (r *Registry) ValidateEvent(e *domain.Event) error {
+	raw, err := e.ToJSON()
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	if err := r.base.Validate(doc); err != nil {
+		return invalid(err)
+	}
+	if e.EventType == "" {
+		return nil
+	}
+	typed, ok := r.types[e.EventType]
+	if !ok {
+		return domain.ErrInvalidEvent{Field: "event_type", Reason: fmt.Sprintf("unknown event type %q", e.EventType), Code: domain.ErrCodeInvalidValue}
+	}
+	if err := typed.Validate(doc); err != nil {
+		return invalid(err)
+	}
+	return nil
+}
+
+// invalid converts a schema violation to the event validation error.
+func invalid(err error) error {
+	serr, ok := err.(*Error)
+	if !ok {
+		return err
+	}
+	code := domain.ErrCodeInvalidValue
+	if serr.Missing {
+		code = domain.ErrCodeMissingField
+	}
+	field := serr.Path
+	if field == "" {
+		field = "event"
+	}
+	return domain.ErrInvalidEvent{Field: field, Reason: serr.Reason, Code: code}
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+)
+
+// TestBaseSchema_MatchesBuiltInChecks holds the embedded base schema to the
+// checks domain.Event falls back on without a registry: both accept and reject
+// the same events, on the same field.
+func TestBaseSchema_MatchesBuiltInChecks(t *testing.T) {
+	payment, err := Compile([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, err := New(map[string]*Schema{"payment": payment})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		modify func(e *domain.Event)
+	}{
+		{"valid", func(e *domain.Event) {}},
+		{"empty user_id", func(e *domain.Event) { e.UserID = "" }},
+		{"zero amount", func(e *domain.Event) { e.Amount = 0 }},
+		{"negative amount", func(e *domain.Event) { e.Amount = -1 }},
+		{"empty currency", func(e *domain.Event) { e.Currency = "" }},
+		{"empty merchant", func(e *domain.Event) { e.Merchant = "" }},
+		{"high priority", func(e *domain.Event) { e.Priority = domain.PriorityHigh }},
+		{"bad priority", func(e *domain.Event) { e.Priority = "urgent" }},
+		{"client_reference at the limit", func(e *domain.Event) { e.ClientReference = strings.Repeat("x", domain.MaxClientReferenceLen) }},
+		{"client_reference over the limit", func(e *domain.Event) { e.ClientReference = strings.Repeat("x", domain.MaxClientReferenceLen+1) }},
+		// Limits count bytes: 127 two-byte runes and one more byte fill 255.
+		{"multibyte client_reference at the limit", func(e *domain.Event) { e.ClientReference = strings.Repeat("é", 127) + "x" }},
+		{"multibyte client_reference over the limit", func(e *domain.Event) { e.ClientReference = strings.Repeat("é", 128) }},
+		{"multibyte schema_version over the limit", func(e *domain.Event) { e.SchemaVersion = strings.Repeat("€", 22) }},
+		{"group", func(e *domain.Event) { e.GroupID, e.GroupSize = "g", domain.MaxGroupSize }},
+		{"group too large", func(e *domain.Event) { e.GroupID, e.GroupSize = "g", domain.MaxGroupSize+1 }},
+		{"group_id over the limit", func(e *domain.Event) { e.GroupID, e.GroupSize = strings.Repeat("g", domain.MaxGroupIDLen+1), 2 }},
+		{"group without size", func(e *domain.Event) { e.GroupID = "g" }},
+		{"size without group", func(e *domain.Event) { e.GroupSize = 2 }},
+		{"schema_version over the limit", func(e *domain.Event) { e.SchemaVersion = strings.Repeat("v", domain.MaxSchemaVersionLen+1) }},
+		{"event_type over the limit", func(e *domain.Event) { e.EventType = strings.Repeat("t", domain.MaxEventTypeLen+1) }},
+		{"event_type", func(e *domain.Event) { e.EventType = "payment" }},
+		{"event_type with a space", func(e *domain.Event) { e.EventType = "card payment" }},
+		{"event_type starting with a dot", func(e *domain.Event) { e.EventType = ".payment" }},
+		{"multibyte event_type", func(e *domain.Event) { e.EventType = "paiement-é" }},
+		{"zero timestamp", func(e *domain.Event) { e.Timestamp = time.Time{} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := fluxatest.NewEvent("evt-1").Build()
+			tt.modify(e)
+			builtIn := e.Validate()
+			schema := e.ValidateWith(domain.ValidationConfig{Schemas: reg}, time.Now())
+			if (builtIn == nil) != (schema == nil) {
+				t.Fatalf("built-in = %v, schema = %v; want both to agree", builtIn, schema)
+			}
+			var b, s domain.ErrInvalidEvent
+			if builtIn != nil && (!errors.As(builtIn, &b) || !errors.As(schema, &s) || b.Field != s.Field) {
+				t.Errorf("built-in = %v, schema = %v; want the same field", builtIn, schema)
+			}
+		})
+	}
+}
+
+func TestRegistry_EventTypes(t *testing.T) {
+	store := fluxatest.NewStorage()
+	_ = store.Put(context.Background(), "schemas/refund.json", []byte(`{
+		"required": ["metadata"],
+		"properties": {"metadata": {
+			"type": "object",
+			"required": ["original_event_id"],
+			"properties": {"original_event_id": {"type": "string", "minLength": 1}}
+		}}
+	}`))
+	reg, err := Load(context.Background(), store, "schemas/", []string{"refund"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reg.Types(); len(got) != 1 || got[0] != "refund" {
+		t.Errorf("Types() = %v, want [refund]", got)
+	}
+	vc := domain.ValidationConfig{Schemas: reg}
+
+	refund := fluxatest.NewEvent("evt-r").Build()
+	refund.EventType = "refund"
+	var invalid domain.ErrInvalidEvent
+	if err := refund.ValidateWith(vc, time.Now()); !errors.As(err, &invalid) || invalid.Field != "metadata" {
+		t.Errorf("refund without metadata = %v, want a missing metadata", err)
+	}
+	refund.Metadata["reason"] = "returned"
+	if err := refund.ValidateWith(vc, time.Now()); !errors.As(err, &invalid) ||
+		invalid.Field != "metadata.original_event_id" || invalid.Code != domain.ErrCodeMissingField {
+		t.Errorf("refund without original_event_id = %v, want a missing metadata.original_event_id", err)
+	}
+	refund.Metadata["original_event_id"] = "evt-1"
+	if err := refund.ValidateWith(vc, time.Now()); err != nil {
+		t.Errorf("refund = %v, want valid", err)
+	}
+
+	unknown := fluxatest.NewEvent("evt-u").Build()
+	unknown.EventType = "chargeback"
+	if err := unknown.ValidateWith(vc, time.Now()); !errors.As(err, &invalid) || invalid.Field != "event_type" {
+		t.Errorf("unknown type = %v, want an event_type error", err)
+	}
+
+	if _, err := Load(context.Background(), store, "schemas/", []string{"missing"}); err == nil {
+		t.Error("Load succeeded without the schema object")
+	}
+	if _, err := Load(context.Background(), store, "schemas/", []string{"../refund"}); err == nil {
+		t.Error("Load accepted a type name with a path in it")
+	}
+}
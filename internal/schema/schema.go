@@ -0,0 +1,431 @@
+// Package schema validates events against JSON Schemas: an embedded base schema
+// every event must satisfy, and a schema per event type (Event.EventType) loaded
+// from object storage, so a new event shape is a new schema file rather than a
+// code change.
+//
+// Schemas are a subset of JSON Schema draft 2020-12: type, enum, const,
+// properties, required, additionalProperties, dependentRequired, items,
+// minItems, maxItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum and format "date-time", plus the
+// annotations ($schema, $id, $comment, title, description, default, examples).
+// Compile rejects any other keyword rather than ignore a constraint the author
+// expects to hold.
+//
+// Unlike JSON Schema, minLength and maxLength count UTF-8 bytes, not characters:
+// the unit of every length limit fluxa documents and enforces elsewhere
+// (domain.Event's built-in checks, GET /limits).
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Error is the first violation Validate finds.
+type Error struct {
+	// Path is where in the document, e.g. "metadata.items[2].sku"; empty for the
+	// document itself.
+	Path   string
+	Reason string
+	// Missing is set for a required value that is absent or an empty string, as
+	// opposed to one that is present but invalid.
+	Missing bool
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return "schema: document " + e.Reason
+	}
+	return "schema: " + e.Path + " " + e.Reason
+}
+
+// Schema is a compiled JSON Schema. Safe for concurrent use.
+type Schema struct {
+	types             []string
+	enum              []interface{}
+	constant          *interface{}
+	properties        map[string]*Schema
+	order             []string // properties in the order the schema declares them
+	required          []string
+	additional        *Schema // nil allows any additional property
+	noAdditional      bool    // additionalProperties: false
+	dependentRequired map[string][]string
+	items             *Schema
+	minItems          *int
+	maxItems          *int
+	minLength         *int
+	maxLength         *int
+	pattern           *regexp.Regexp
+	minimum           *float64
+	maximum           *float64
+	exclusiveMinimum  *float64
+	exclusiveMaximum  *float64
+	format            string
+}
+
+// keywords are those Compile understands; annotations are accepted and ignored.
+var keywords = map[string]bool{
+	"type": true, "enum": true, "const": true, "properties": true, "required": true,
+	"additionalProperties": true, "dependentRequired": true, "items": true,
+	"minItems": true, "maxItems": true, "minLength": true, "maxLength": true,
+	"pattern": true, "minimum": true, "maximum": true, "exclusiveMinimum": true,
+	"exclusiveMaximum": true, "format": true,
+	"$schema": true, "$id": true, "$comment": true, "title": true,
+	"description": true, "default": true, "examples": true,
+}
+
+var typeNames = map[string]bool{
+	"null": true, "boolean": true, "string": true, "number": true,
+	"integer": true, "array": true, "object": true,
+}
+
+// Compile parses a JSON Schema document.
+func Compile(raw []byte) (*Schema, error) {
+	return compile(raw, "")
+}
+
+func compile(raw json.RawMessage, path string) (*Schema, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("schema: %s: %w", pathOrRoot(path), err)
+	}
+	for k := range doc {
+		if !keywords[k] {
+			return nil, fmt.Errorf("schema: %s: unsupported keyword %q", pathOrRoot(path), k)
+		}
+	}
+	var (
+		s   Schema
+		err error
+	)
+	field := func(name string, v interface{}) {
+		if raw, ok := doc[name]; ok && err == nil {
+			if uerr := json.Unmarshal(raw, v); uerr != nil {
+				err = fmt.Errorf("schema: %s: %s: %w", pathOrRoot(path), name, uerr)
+			}
+		}
+	}
+	if raw, ok := doc["type"]; ok {
+		var one string
+		if json.Unmarshal(raw, &one) == nil {
+			s.types = []string{one}
+		} else {
+			field("type", &s.types)
+		}
+	}
+	field("enum", &s.enum)
+	if _, ok := doc["const"]; ok {
+		var c interface{}
+		field("const", &c)
+		s.constant = &c
+	}
+	field("required", &s.required)
+	field("dependentRequired", &s.dependentRequired)
+	field("minItems", &s.minItems)
+	field("maxItems", &s.maxItems)
+	field("minLength", &s.minLength)
+	field("maxLength", &s.maxLength)
+	field("minimum", &s.minimum)
+	field("maximum", &s.maximum)
+	field("exclusiveMinimum", &s.exclusiveMinimum)
+	field("exclusiveMaximum", &s.exclusiveMaximum)
+	field("format", &s.format)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range s.types {
+		if !typeNames[t] {
+			return nil, fmt.Errorf("schema: %s: unknown type %q", pathOrRoot(path), t)
+		}
+	}
+	if s.format != "" && s.format != "date-time" {
+		return nil, fmt.Errorf("schema: %s: unsupported format %q", pathOrRoot(path), s.format)
+	}
+	if raw, ok := doc["pattern"]; ok {
+		var pattern string
+		if err := json.Unmarshal(raw, &pattern); err != nil {
+			return nil, fmt.Errorf("schema: %s: pattern: %w", pathOrRoot(path), err)
+		}
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("schema: %s: pattern: %w", pathOrRoot(path), err)
+		}
+	}
+	if raw, ok := doc["properties"]; ok {
+		if s.order, err = objectKeys(raw); err != nil {
+			return nil, fmt.Errorf("schema: %s: properties: %w", pathOrRoot(path), err)
+		}
+		var props map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &props); err != nil {
+			return nil, fmt.Errorf("schema: %s: properties: %w", pathOrRoot(path), err)
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, sub := range props {
+			if s.properties[name], err = compile(sub, join(path, name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if raw, ok := doc["additionalProperties"]; ok {
+		var allowed bool
+		if json.Unmarshal(raw, &allowed) == nil {
+			s.noAdditional = !allowed
+		} else if s.additional, err = compile(raw, join(path, "*")); err != nil {
+			return nil, err
+		}
+	}
+	if raw, ok := doc["items"]; ok {
+		if s.items, err = compile(raw, path+"[]"); err != nil {
+			return nil, err
+		}
+	}
+	return &s, nil
+}
+
+// objectKeys returns the keys of the JSON object raw in document order.
+func objectKeys(raw json.RawMessage) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("not an object")
+	}
+	var keys []string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, t.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// Validate checks doc, a value decoded by encoding/json, and returns the first
+// violation as an *Error. Properties are checked in the order the schema
+// declares them, so the same document always fails the same way.
+func (s *Schema) Validate(doc interface{}) error {
+	return s.validate(doc, "")
+}
+
+func (s *Schema) validate(v interface{}, path string) error {
+	if len(s.types) > 0 && !hasType(v, s.types) {
+		if len(s.types) == 1 {
+			return &Error{Path: path, Reason: "must be " + article(s.types[0])}
+		}
+		return &Error{Path: path, Reason: "must be one of types " + strings.Join(s.types, ", ")}
+	}
+	if s.constant != nil && !equal(v, *s.constant) {
+		return &Error{Path: path, Reason: "must be " + encode(*s.constant)}
+	}
+	if s.enum != nil && !inEnum(v, s.enum) {
+		values := make([]string, len(s.enum))
+		for i, e := range s.enum {
+			values[i] = encode(e)
+		}
+		return &Error{Path: path, Reason: "must be one of " + strings.Join(values, ", ")}
+	}
+	switch v := v.(type) {
+	case string:
+		return s.validateString(v, path)
+	case float64:
+		return s.validateNumber(v, path)
+	case []interface{}:
+		return s.validateArray(v, path)
+	case map[string]interface{}:
+		return s.validateObject(v, path)
+	}
+	return nil
+}
+
+func (s *Schema) validateString(v, path string) error {
+	n := len(v)
+	switch {
+	case s.minLength != nil && n < *s.minLength:
+		if *s.minLength == 1 {
+			return &Error{Path: path, Reason: "cannot be empty", Missing: true}
+		}
+		return &Error{Path: path, Reason: fmt.Sprintf("must be at least %d bytes", *s.minLength)}
+	case s.maxLength != nil && n > *s.maxLength:
+		return &Error{Path: path, Reason: fmt.Sprintf("must be at most %d bytes", *s.maxLength)}
+	case s.pattern != nil && !s.pattern.MatchString(v):
+		return &Error{Path: path, Reason: "must match " + s.pattern.String()}
+	case s.format == "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+			return &Error{Path: path, Reason: "must be an RFC 3339 date-time"}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateNumber(v float64, path string) error {
+	switch {
+	case s.minimum != nil && v < *s.minimum:
+		return &Error{Path: path, Reason: "must be at least " + formatNumber(*s.minimum)}
+	case s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum:
+		return &Error{Path: path, Reason: "must be greater than " + formatNumber(*s.exclusiveMinimum)}
+	case s.maximum != nil && v > *s.maximum:
+		return &Error{Path: path, Reason: "must be at most " + formatNumber(*s.maximum)}
+	case s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum:
+		return &Error{Path: path, Reason: "must be less than " + formatNumber(*s.exclusiveMaximum)}
+	}
+	return nil
+}
+
+func (s *Schema) validateArray(v []interface{}, path string) error {
+	switch {
+	case s.minItems != nil && len(v) < *s.minItems:
+		return &Error{Path: path, Reason: fmt.Sprintf("must have at least %d items", *s.minItems)}
+	case s.maxItems != nil && len(v) > *s.maxItems:
+		return &Error{Path: path, Reason: fmt.Sprintf("must have at most %d items", *s.maxItems)}
+	}
+	if s.items == nil {
+		return nil
+	}
+	for i, item := range v {
+		if err := s.items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(v map[string]interface{}, path string) error {
+	required := make(map[string]bool, len(s.required))
+	for _, name := range s.required {
+		required[name] = true
+	}
+	for _, name := range s.order {
+		value, ok := v[name]
+		if !ok {
+			if required[name] {
+				return &Error{Path: join(path, name), Reason: "is required", Missing: true}
+			}
+			continue
+		}
+		if err := s.properties[name].validate(value, join(path, name)); err != nil {
+			return err
+		}
+	}
+	for _, name := range s.required {
+		if _, ok := v[name]; !ok {
+			return &Error{Path: join(path, name), Reason: "is required", Missing: true}
+		}
+	}
+	for _, name := range sortedKeys(s.dependentRequired) {
+		if _, ok := v[name]; !ok {
+			continue
+		}
+		for _, dep := range s.dependentRequired[name] {
+			if _, ok := v[dep]; !ok {
+				return &Error{Path: join(path, dep), Reason: "is required with " + name, Missing: true}
+			}
+		}
+	}
+	if !s.noAdditional && s.additional == nil {
+		return nil
+	}
+	for _, name := range sortedKeys(v) {
+		if _, declared := s.properties[name]; declared {
+			continue
+		}
+		if s.noAdditional {
+			return &Error{Path: join(path, name), Reason: "is not allowed"}
+		}
+		if err := s.additional.validate(v[name], join(path, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasType(v interface{}, types []string) bool {
+	for _, t := range types {
+		switch t {
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "number":
+			if _, ok := v.(float64); ok {
+				return true
+			}
+		case "integer":
+			if f, ok := v.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]interface{}); ok {
+				return true
+			}
+		case "object":
+			if _, ok := v.(map[string]interface{}); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if equal(v, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares decoded JSON values.
+func equal(a, b interface{}) bool {
+	return encode(a) == encode(b)
+}
+
+// encode renders a decoded JSON value for comparison and error messages;
+// encoding/json sorts object keys, so equal values encode the same.
+func encode(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func formatNumber(f float64) string {
+	return encode(f)
+}
+
+func article(t string) string {
+	switch t {
+	case "array", "integer", "object":
+		return "an " + t
+	case "null":
+		return "null"
+	}
+	return "a " + t
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
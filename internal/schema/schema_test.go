@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCompile_RejectsUnsupported(t *testing.T) {
+	for _, raw := range []string{
+		`{"oneOf": [{"type": "string"}]}`,
+		`{"type": "strng"}`,
+		`{"format": "email"}`,
+		`{"properties": {"a": {"$ref": "#/defs/a"}}}`,
+		`{"pattern": "("}`,
+	} {
+		if _, err := Compile([]byte(raw)); err == nil {
+			t.Errorf("Compile(%s) succeeded, want an error", raw)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Compile([]byte(`{
+		"type": "object",
+		"required": ["sku", "items"],
+		"properties": {
+			"sku": {"type": "string", "pattern": "^[A-Z]{3}-[0-9]+$"},
+			"items": {"type": "array", "minItems": 1, "items": {"type": "integer", "minimum": 1}},
+			"channel": {"enum": ["web", "pos"]},
+			"note": {"type": ["string", "null"], "maxLength": 3}
+		},
+		"additionalProperties": false
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		doc      string
+		wantPath string // "" for valid
+		missing  bool
+	}{
+		{`{"sku": "ABC-1", "items": [1, 2], "note": null}`, "", false},
+		{`{"items": [1]}`, "sku", true},
+		{`{"sku": "abc", "items": [1]}`, "sku", false},
+		{`{"sku": "ABC-1", "items": []}`, "items", false},
+		{`{"sku": "ABC-1", "items": [1, 1.5]}`, "items[1]", false},
+		{`{"sku": "ABC-1", "items": [1], "channel": "fax"}`, "channel", false},
+		{`{"sku": "ABC-1", "items": [1], "note": "long"}`, "note", false},
+		{`{"sku": "ABC-1", "items": [1], "extra": true}`, "extra", false},
+		{`[]`, "", false},
+	}
+	for _, tt := range tests {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		err := s.Validate(doc)
+		if tt.wantPath == "" && tt.doc != `[]` {
+			if err != nil {
+				t.Errorf("Validate(%s) = %v, want valid", tt.doc, err)
+			}
+			continue
+		}
+		var serr *Error
+		if !errors.As(err, &serr) || serr.Path != tt.wantPath || serr.Missing != tt.missing {
+			t.Errorf("Validate(%s) = %#v, want a violation at %q (missing %v)", tt.doc, err, tt.wantPath, tt.missing)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package schemadrift watches the shape of incoming event payloads. An Observer
+// flattens a sample of JSON payloads into field paths and JSON types and records
+// them per producer and schema_version (payload_schema_fields, migration 026).
+// A field path or type the schema has not carried before is drift: it is logged
+// and counted in schema_drift_total, an early warning before a producer's
+// "harmless" change breaks parsing.
+package schemadrift
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+const (
+	// MaxFields bounds the fields recorded per payload; the rest are ignored.
+	MaxFields = 256
+	// maxPathLen is the width of payload_schema_fields.field_path; longer paths
+	// are ignored.
+	maxPathLen = 255
+)
+
+// Store records observed fields and reports drift; *db.Client implements it.
+type Store interface {
+	RecordSchemaFields(producerKey, schemaVersion string, fields []domain.PayloadField) ([]domain.SchemaDrift, error)
+}
+
+// Observer samples payloads for drift.
+type Observer struct {
+	Store   Store
+	Metrics ports.Metrics
+	Logger  *logging.Logger
+	// SamplePercent (0-100) of payloads are observed, chosen by payload hash as
+	// archival samples are (domain.SampledForArchive), so a redelivered payload
+	// is sampled alike. Zero observes none.
+	SamplePercent float64
+}
+
+// Observe records the fields of event's JSON payload when msg is sampled, and
+// reports any drift. It is best-effort: failures are logged and never fail the
+// event. Payloads in other formats are skipped; their fields are fixed.
+func (o *Observer) Observe(msg *domain.QueueMessage, event *domain.Event, payload []byte) {
+	if o == nil || (msg.ContentType != "" && msg.ContentType != domain.ContentTypeJSON) ||
+		!domain.SampledForArchive(msg.PayloadSHA256, o.SamplePercent) {
+		return
+	}
+	fields, err := Fields(payload)
+	if err != nil {
+		o.Logger.Warn("Schema drift sample not decoded", map[string]interface{}{"event_id": event.EventID, "error": err.Error()})
+		return
+	}
+	drift, err := o.Store.RecordSchemaFields(msg.ProducerKey, event.SchemaVersion, fields)
+	if err != nil {
+		o.Logger.Error("Failed to record schema fields", err, map[string]interface{}{"event_id": event.EventID})
+		return
+	}
+	for _, d := range drift {
+		o.Metrics.IncCounter(metricdef.SchemaDriftTotal, "kind", d.Kind)
+		logFields := map[string]interface{}{
+			"kind":           d.Kind,
+			"producer_key":   d.ProducerKey,
+			"schema_version": d.SchemaVersion,
+			"event_id":       event.EventID,
+		}
+		if d.Kind == domain.SchemaDriftNewSchema {
+			o.Logger.Info("New payload schema", logFields)
+			continue
+		}
+		logFields["field"], logFields["type"] = d.Field.Path, d.Field.Type
+		if d.Kind == domain.SchemaDriftTypeChange {
+			logFields["previous_types"] = d.PreviousTypes
+		}
+		o.Logger.Warn("Payload schema drift", logFields)
+	}
+}
+
+// Fields flattens a JSON object payload into its field paths and the JSON type
+// (object, array, string, number, boolean) at each, sorted by path. Nulls carry
+// no type and are left out. At most MaxFields are returned.
+func Fields(payload []byte) ([]domain.PayloadField, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, fmt.Errorf("payload is not a JSON object: %w", err)
+	}
+	seen := map[domain.PayloadField]bool{}
+	walk("", doc, seen)
+	fields := make([]domain.PayloadField, 0, len(seen))
+	for f := range seen {
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].Path != fields[j].Path {
+			return fields[i].Path < fields[j].Path
+		}
+		return fields[i].Type < fields[j].Type
+	})
+	if len(fields) > MaxFields {
+		fields = fields[:MaxFields]
+	}
+	return fields, nil
+}
+
+// walk adds the fields of v, found at path, to seen.
+func walk(path string, v interface{}, seen map[domain.PayloadField]bool) {
+	add := func(typ string) {
+		if path != "" && len(path) <= maxPathLen {
+			seen[domain.PayloadField{Path: path, Type: typ}] = true
+		}
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		add("object")
+		for k, child := range v {
+			if path == "" {
+				walk(k, child, seen)
+			} else {
+				walk(path+"."+k, child, seen)
+			}
+		}
+	case []interface{}:
+		add("array")
+		for _, child := range v {
+			walk(path+"[]", child, seen)
+		}
+	case string:
+		add("string")
+	case float64:
+		add("number")
+	case bool:
+		add("boolean")
+	}
+}
@@ -0,0 +1,104 @@
+package schemadrift
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+// fakeStore remembers fields per schema and reports drift like db.Client.
+type fakeStore struct {
+	known map[string]map[domain.PayloadField]bool
+	calls int
+}
+
+func (s *fakeStore) RecordSchemaFields(producerKey, schemaVersion string, fields []domain.PayloadField) ([]domain.SchemaDrift, error) {
+	s.calls++
+	key := producerKey + "/" + schemaVersion
+	known := s.known[key]
+	if known == nil {
+		s.known[key] = map[domain.PayloadField]bool{}
+		for _, f := range fields {
+			s.known[key][f] = true
+		}
+		return []domain.SchemaDrift{{Kind: domain.SchemaDriftNewSchema, ProducerKey: producerKey, SchemaVersion: schemaVersion}}, nil
+	}
+	var drift []domain.SchemaDrift
+	for _, f := range fields {
+		if known[f] {
+			continue
+		}
+		d := domain.SchemaDrift{Kind: domain.SchemaDriftNewField, ProducerKey: producerKey, SchemaVersion: schemaVersion, Field: f}
+		for k := range known {
+			if k.Path == f.Path {
+				d.Kind, d.PreviousTypes = domain.SchemaDriftTypeChange, append(d.PreviousTypes, k.Type)
+			}
+		}
+		known[f] = true
+		drift = append(drift, d)
+	}
+	return drift, nil
+}
+
+func TestFields(t *testing.T) {
+	fields, err := Fields([]byte(`{"amount": 10, "canary": false, "note": null,
+		"metadata": {"items": [{"price": 1.5}, {"price": "2"}], "tags": []}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []domain.PayloadField{
+		{Path: "amount", Type: "number"},
+		{Path: "canary", Type: "boolean"},
+		{Path: "metadata", Type: "object"},
+		{Path: "metadata.items", Type: "array"},
+		{Path: "metadata.items[]", Type: "object"},
+		{Path: "metadata.items[].price", Type: "number"},
+		{Path: "metadata.items[].price", Type: "string"},
+		{Path: "metadata.tags", Type: "array"},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Fields = %+v, want %+v", fields, want)
+	}
+	if _, err := Fields([]byte(`[1, 2]`)); err == nil {
+		t.Error("Fields accepted a payload that is not an object")
+	}
+}
+
+func TestObserver_ReportsDrift(t *testing.T) {
+	store, m := &fakeStore{known: map[string]map[domain.PayloadField]bool{}}, fluxatest.NewMetrics()
+	o := &Observer{Store: store, Metrics: m, Logger: logging.NewLogger("test", "test"), SamplePercent: 100}
+	observe := func(id string, payload []byte) {
+		msg := fluxatest.InlineEnvelope(id, payload)
+		msg.ProducerKey = "producer-a"
+		o.Observe(msg, &domain.Event{EventID: id, SchemaVersion: "v1"}, payload)
+	}
+
+	observe("evt-1", []byte(`{"amount": 10, "metadata": {"order_id": "o-1"}}`))
+	observe("evt-2", []byte(`{"amount": 10, "metadata": {"order_id": "o-2"}}`))
+	observe("evt-3", []byte(`{"amount": 10, "metadata": {"order_id": 3, "channel": "web"}}`))
+
+	if got := m.Counter(metricdef.SchemaDriftTotal, domain.SchemaDriftNewSchema); got != 1 {
+		t.Errorf("schema_drift_total{new_schema} = %d, want 1", got)
+	}
+	if got := m.Counter(metricdef.SchemaDriftTotal, domain.SchemaDriftNewField); got != 1 {
+		t.Errorf("schema_drift_total{new_field} = %d, want 1 (metadata.channel)", got)
+	}
+	if got := m.Counter(metricdef.SchemaDriftTotal, domain.SchemaDriftTypeChange); got != 1 {
+		t.Errorf("schema_drift_total{type_change} = %d, want 1 (metadata.order_id)", got)
+	}
+
+	// Unsampled and non-JSON payloads are not observed.
+	o.SamplePercent = 0
+	observe("evt-4", []byte(`{"amount": "ten"}`))
+	o.SamplePercent = 100
+	proto := fluxatest.InlineEnvelope("evt-5", []byte(`{}`))
+	proto.ContentType = domain.ContentTypeProtobuf
+	o.Observe(proto, &domain.Event{EventID: "evt-5"}, []byte(`{}`))
+	if store.calls != 3 {
+		t.Errorf("recorded %d samples, want 3", store.calls)
+	}
+}
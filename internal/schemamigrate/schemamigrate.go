@@ -0,0 +1,279 @@
+// Package schemamigrate upgrades events from old producer schema versions
+// (domain.Event.SchemaVersion) to the latest one, so consumers see one shape
+// whenever an event was ingested. The processor upgrades each event it decodes,
+// and the query service upgrades stored events it reads that are still labelled
+// with an older version, e.g. because a migration was registered after them.
+//
+// Migrations are registered in a YAML file (PAYLOAD_MIGRATIONS_FILE), each taking
+// one version to the next:
+//
+//	migrations:
+//	  - from: "1"
+//	    to: "2"
+//	    rename: {metadata.merchant_name: merchant, metadata.mcc: metadata.category}
+//	    defaults: {currency: USD}
+//	    remove: [metadata.legacy_flag]
+//
+// Paths are the event's JSON field names, with dots stepping into objects
+// (metadata.mcc). A migration renames, then fills in defaults for missing or
+// empty fields, then removes; a rename or removal whose field is absent does
+// nothing. Versions chain: an event at "1" with migrations 1→2 and 2→3 ends at
+// "3".
+package schemamigrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// Migration takes events from one schema version to the next.
+type Migration struct {
+	From     string                 `yaml:"from"`
+	To       string                 `yaml:"to"`
+	Rename   map[string]string      `yaml:"rename"`
+	Defaults map[string]interface{} `yaml:"defaults"`
+	Remove   []string               `yaml:"remove"`
+}
+
+// Registry holds the registered migrations by the version they upgrade. A nil
+// *Registry upgrades nothing.
+type Registry struct {
+	byFrom map[string]Migration
+}
+
+// migrationFile is the PAYLOAD_MIGRATIONS_FILE shape.
+type migrationFile struct {
+	Migrations []Migration `yaml:"migrations"`
+}
+
+// Load reads and validates a migrations file.
+func Load(path string) (*Registry, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("schemamigrate: read migrations: %w", err)
+	}
+	var f migrationFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("schemamigrate: parse migrations %s: %w", path, err)
+	}
+	return New(f.Migrations...)
+}
+
+// New registers migrations. Each version may be upgraded by one migration, and
+// the chain must not loop back on itself.
+func New(migrations ...Migration) (*Registry, error) {
+	r := &Registry{byFrom: make(map[string]Migration, len(migrations))}
+	for _, m := range migrations {
+		if m.From == "" || m.To == "" || m.From == m.To {
+			return nil, fmt.Errorf("schemamigrate: migration %q → %q: from and to must be distinct versions", m.From, m.To)
+		}
+		if _, dup := r.byFrom[m.From]; dup {
+			return nil, fmt.Errorf("schemamigrate: version %q has more than one migration", m.From)
+		}
+		for _, p := range append(m.paths(), m.Remove...) {
+			if p == "" || strings.HasPrefix(p, ".") || strings.HasSuffix(p, ".") || strings.Contains(p, "..") {
+				return nil, fmt.Errorf("schemamigrate: migration %q → %q: invalid path %q", m.From, m.To, p)
+			}
+		}
+		r.byFrom[m.From] = m
+	}
+	for from := range r.byFrom {
+		if _, err := r.chain(from); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// paths are the rename sources and targets and the default fields of m.
+func (m Migration) paths() []string {
+	var out []string
+	for from, to := range m.Rename {
+		out = append(out, from, to)
+	}
+	for p := range m.Defaults {
+		out = append(out, p)
+	}
+	return out
+}
+
+// chain returns the migrations that take version to the latest, in order.
+func (r *Registry) chain(version string) ([]Migration, error) {
+	if r == nil {
+		return nil, nil
+	}
+	var out []Migration
+	for m, ok := r.byFrom[version]; ok; m, ok = r.byFrom[version] {
+		if len(out) == len(r.byFrom) {
+			return nil, fmt.Errorf("schemamigrate: migrations from %q loop", version)
+		}
+		out = append(out, m)
+		version = m.To
+	}
+	return out, nil
+}
+
+// Latest returns the version an event at version upgrades to: version itself
+// when no migration applies.
+func (r *Registry) Latest(version string) string {
+	chain, _ := r.chain(version)
+	if len(chain) == 0 {
+		return version
+	}
+	return chain[len(chain)-1].To
+}
+
+// Apply upgrades doc, an event's JSON object, from version, and returns the
+// version it ends at. doc is changed in place.
+func (r *Registry) Apply(version string, doc map[string]interface{}) (string, error) {
+	chain, err := r.chain(version)
+	if err != nil {
+		return version, err
+	}
+	for _, m := range chain {
+		if err := m.apply(doc); err != nil {
+			return version, fmt.Errorf("schemamigrate: %q → %q: %w", m.From, m.To, err)
+		}
+		version = m.To
+	}
+	return version, nil
+}
+
+func (m Migration) apply(doc map[string]interface{}) error {
+	for from, to := range m.Rename {
+		v, ok, err := take(doc, from)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := put(doc, to, v, true); err != nil {
+				return err
+			}
+		}
+	}
+	for p, v := range m.Defaults {
+		if err := put(doc, p, v, false); err != nil {
+			return err
+		}
+	}
+	for _, p := range m.Remove {
+		if _, _, err := take(doc, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parent walks doc to the object holding path's last segment, creating missing
+// objects when create is set. It returns nil when a step is missing.
+func parent(doc map[string]interface{}, path string, create bool) (map[string]interface{}, string, error) {
+	segs := strings.Split(path, ".")
+	obj := doc
+	for _, seg := range segs[:len(segs)-1] {
+		next, ok := obj[seg]
+		if !ok || next == nil {
+			if !create {
+				return nil, "", nil
+			}
+			child := map[string]interface{}{}
+			obj[seg] = child
+			obj = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("%s: %s is not an object", path, seg)
+		}
+		obj = child
+	}
+	return obj, segs[len(segs)-1], nil
+}
+
+// take removes path from doc and returns its value, if it was there.
+func take(doc map[string]interface{}, path string) (interface{}, bool, error) {
+	obj, key, err := parent(doc, path, false)
+	if err != nil || obj == nil {
+		return nil, false, err
+	}
+	v, ok := obj[key]
+	delete(obj, key)
+	return v, ok, nil
+}
+
+// put sets path in doc; without overwrite, only when it is missing, null or "".
+func put(doc map[string]interface{}, path string, v interface{}, overwrite bool) error {
+	obj, key, err := parent(doc, path, true)
+	if err != nil {
+		return err
+	}
+	if cur, ok := obj[key]; ok && cur != nil && cur != "" && !overwrite {
+		return nil
+	}
+	obj[key] = v
+	return nil
+}
+
+// UpgradeEvent upgrades e in place from e.SchemaVersion, through its JSON form,
+// and reports whether any migration applied. Fields without a JSON form
+// (ProducerKey, Enrichment, …) are kept as they were.
+func (r *Registry) UpgradeEvent(e *domain.Event) (bool, error) {
+	if r.Latest(e.SchemaVersion) == e.SchemaVersion {
+		return false, nil
+	}
+	var up domain.Event
+	if err := r.roundTrip(e.SchemaVersion, e, &up); err != nil {
+		return false, err
+	}
+	up.ProducerKey, up.CanonicalMerchant, up.Enrichment, up.PendingArchive =
+		e.ProducerKey, e.CanonicalMerchant, e.Enrichment, e.PendingArchive
+	*e = up
+	return true, nil
+}
+
+// UpgradeRecord is UpgradeEvent for a stored event.
+func (r *Registry) UpgradeRecord(rec *domain.EventRecord) (bool, error) {
+	if r.Latest(rec.SchemaVersion) == rec.SchemaVersion {
+		return false, nil
+	}
+	var up domain.EventRecord
+	if err := r.roundTrip(rec.SchemaVersion, rec, &up); err != nil {
+		return false, err
+	}
+	if up.Metadata != nil {
+		raw, err := json.Marshal(up.Metadata)
+		if err != nil {
+			return false, err
+		}
+		up.MetadataJSON = string(raw)
+	}
+	*rec = up
+	return true, nil
+}
+
+// roundTrip decodes in's JSON form, upgrades it from version, and decodes the
+// result into out, with schema_version set to the version reached.
+func (r *Registry) roundTrip(version string, in, out interface{}) error {
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	if doc["schema_version"], err = r.Apply(version, doc); err != nil {
+		return err
+	}
+	if raw, err = json.Marshal(doc); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("schemamigrate: upgraded event does not decode: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package schemamigrate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+func TestNew_Rejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []Migration
+	}{
+		{"no from", []Migration{{To: "2"}}},
+		{"to itself", []Migration{{From: "1", To: "1"}}},
+		{"two from one version", []Migration{{From: "1", To: "2"}, {From: "1", To: "3"}}},
+		{"loop", []Migration{{From: "1", To: "2"}, {From: "2", To: "1"}}},
+		{"bad path", []Migration{{From: "1", To: "2", Remove: []string{"metadata."}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.migrations...); err == nil {
+				t.Error("New = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestApply_Chains(t *testing.T) {
+	r, err := New(
+		Migration{From: "2", To: "3", Remove: []string{"metadata.legacy"}},
+		Migration{From: "1", To: "2",
+			Rename:   map[string]string{"metadata.merchant_name": "merchant", "metadata.mcc": "metadata.category"},
+			Defaults: map[string]interface{}{"currency": "USD", "merchant": "unknown"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{
+		"currency": "",
+		"metadata": map[string]interface{}{"merchant_name": "Shop", "mcc": "5411", "legacy": true},
+	}
+	version, err := r.Apply("1", doc)
+	if err != nil {
+		t.Fatalf("Apply = %v", err)
+	}
+	want := map[string]interface{}{
+		"merchant": "Shop",
+		"currency": "USD",
+		"metadata": map[string]interface{}{"category": "5411"},
+	}
+	if version != "3" || !reflect.DeepEqual(doc, want) {
+		t.Errorf("Apply = %q, %v; want 3, %v", version, doc, want)
+	}
+	if got := r.Latest("3"); got != "3" {
+		t.Errorf("Latest(3) = %q, want 3", got)
+	}
+
+	if _, err := r.Apply("1", map[string]interface{}{"metadata": "flat"}); err == nil {
+		t.Error("Apply through a non-object = nil, want an error")
+	}
+}
+
+func TestUpgradeEvent(t *testing.T) {
+	r, err := New(Migration{From: "1", To: "2", Rename: map[string]string{"metadata.mcc": "metadata.category"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	e := &domain.Event{EventID: "evt-1", UserID: "u-1", Amount: 12.5, Currency: "USD", Merchant: "Shop",
+		Timestamp: ts, SchemaVersion: "1", Metadata: map[string]interface{}{"mcc": "5411"}, ProducerKey: "producer-a"}
+
+	if ok, err := r.UpgradeEvent(e); !ok || err != nil {
+		t.Fatalf("UpgradeEvent = %v, %v; want upgraded", ok, err)
+	}
+	if e.SchemaVersion != "2" || e.Metadata["category"] != "5411" || e.Metadata["mcc"] != nil ||
+		e.ProducerKey != "producer-a" || !e.Timestamp.Equal(ts) || e.Amount != 12.5 {
+		t.Errorf("upgraded event = %+v", e)
+	}
+	if ok, _ := r.UpgradeEvent(e); ok {
+		t.Error("UpgradeEvent of the latest version upgraded it again")
+	}
+	var none *Registry
+	if ok, err := none.UpgradeEvent(&domain.Event{SchemaVersion: "1"}); ok || err != nil {
+		t.Errorf("nil Registry UpgradeEvent = %v, %v; want a no-op", ok, err)
+	}
+}
+
+func TestUpgradeRecord(t *testing.T) {
+	r, err := New(Migration{From: "1", To: "2", Defaults: map[string]interface{}{"metadata.channel": "web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &domain.EventRecord{EventID: "evt-1", SchemaVersion: "1", MetadataJSON: "{}", Version: 3}
+	if ok, err := r.UpgradeRecord(rec); !ok || err != nil {
+		t.Fatalf("UpgradeRecord = %v, %v; want upgraded", ok, err)
+	}
+	if rec.SchemaVersion != "2" || rec.Metadata["channel"] != "web" || rec.MetadataJSON != `{"channel":"web"}` || rec.Version != 3 {
+		t.Errorf("upgraded record = %+v", rec)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrations.yaml")
+	raw := "migrations:\n  - from: \"1\"\n    to: \"2\"\n    defaults: {currency: USD}\n"
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Load(path)
+	if err != nil || r.Latest("1") != "2" {
+		t.Fatalf("Load = %v, %v; want 1 → 2", r, err)
+	}
+}
@@ -0,0 +1,62 @@
+package signing
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_760_000_000, 0)
+	body := []byte(`{"event_id":"evt-1"}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := Sign("s3cret", ts, body)
+
+	tests := []struct {
+		name    string
+		secret  string
+		ts      string
+		sig     string
+		body    []byte
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", "s3cret", ts, sig, body, now, nil},
+		{"valid within window", "s3cret", ts, sig, body, now.Add(4 * time.Minute), nil},
+		{"missing signature", "s3cret", ts, "", body, now, ErrMissingSignature},
+		{"missing timestamp", "s3cret", "", sig, body, now, ErrMissingSignature},
+		{"garbled timestamp", "s3cret", "yesterday", sig, body, now, ErrMissingSignature},
+		{"replayed later", "s3cret", ts, sig, body, now.Add(6 * time.Minute), ErrStaleTimestamp},
+		{"from the future", "s3cret", ts, sig, body, now.Add(-6 * time.Minute), ErrStaleTimestamp},
+		{"tampered body", "s3cret", ts, sig, []byte(`{"event_id":"evt-2"}`), now, ErrBadSignature},
+		{"wrong secret", "other", ts, sig, body, now, ErrBadSignature},
+		{"timestamp swapped", "s3cret", strconv.FormatInt(now.Unix()+1, 10), sig, body, now, ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.ts, tt.sig, tt.body, tt.now, 5*time.Minute); err != tt.wantErr {
+				t.Errorf("Verify = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyAny(t *testing.T) {
+	now := time.Unix(1_760_000_000, 0)
+	body := []byte(`{"event_id":"evt-1"}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := Sign("old", ts, body)
+
+	if err := VerifyAny([]string{"new", "old"}, ts, sig, body, now, time.Minute); err != nil {
+		t.Errorf("VerifyAny with the retired secret = %v, want nil", err)
+	}
+	if err := VerifyAny([]string{"new"}, ts, sig, body, now, time.Minute); err != ErrBadSignature {
+		t.Errorf("VerifyAny without it = %v, want ErrBadSignature", err)
+	}
+	if err := VerifyAny(nil, ts, sig, body, now, time.Minute); err != ErrBadSignature {
+		t.Errorf("VerifyAny with no secrets = %v, want ErrBadSignature", err)
+	}
+	if err := VerifyAny([]string{"new", "old"}, ts, sig, body, now.Add(time.Hour), time.Minute); err != ErrStaleTimestamp {
+		t.Errorf("VerifyAny on a stale request = %v, want ErrStaleTimestamp", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package slo keeps the hourly processing SLO roll-up (slo_rollups) current and
+// reports a window of it against the objective (query GET /slo). The SLI is the
+// share of events persisted within the latency target, out of every event that
+// reached a terminal state: an event that failed permanently, or took longer than
+// the target, counts against it.
+package slo
+
+import (
+	"context"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/logging"
+)
+
+// Defaults for a zero Roller.Interval and Roller.Target.
+const (
+	DefaultInterval = 5 * time.Minute
+	DefaultTarget   = 5 * time.Second
+)
+
+// Store rebuilds roll-up hours; *db.Client implements it.
+type Store interface {
+	RollUpSLO(from, to time.Time, target time.Duration) (int64, error)
+}
+
+// Roller rebuilds the current and previous hour of the roll-up every Interval. The
+// previous hour is redone so events received late in it but persisted since are
+// counted.
+type Roller struct {
+	Store    Store
+	Interval time.Duration
+	Target   time.Duration
+	Logger   *logging.Logger
+}
+
+// Run rolls up immediately and then every Interval until ctx is done.
+func (r *Roller) Run(ctx context.Context) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.RollUp(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RollUp rebuilds the hour of now and the one before. Failures are logged; the
+// next run retries.
+func (r *Roller) RollUp(now time.Time) {
+	target := r.Target
+	if target <= 0 {
+		target = DefaultTarget
+	}
+	from := now.UTC().Truncate(time.Hour).Add(-time.Hour)
+	if _, err := r.Store.RollUpSLO(from, now, target); err != nil && r.Logger != nil {
+		r.Logger.Warn("SLO roll-up failed", map[string]interface{}{"error": err.Error()})
+	}
+}
+
+// Report is a window of the roll-up against the objective. Rates are nil when the
+// window has no events. WorstP99Ms is the highest hourly p99: hourly percentiles
+// can't be merged exactly, so the window reports its worst hour.
+type Report struct {
+	Window           string             `json:"window"`
+	Objective        float64            `json:"objective"`
+	TargetMs         int                `json:"target_ms"`
+	Processed        int64              `json:"processed"`
+	Failed           int64              `json:"failed"`
+	WithinTarget     int64              `json:"within_target"`
+	SuccessRate      *float64           `json:"success_rate,omitempty"`
+	WithinTargetRate *float64           `json:"within_target_rate,omitempty"`
+	Met              bool               `json:"met"`
+	WorstP99Ms       *float64           `json:"worst_p99_ms,omitempty"`
+	Hours            []domain.SLORollup `json:"hours"`
+}
+
+// Summarize totals rollups into a Report against objective (e.g. 0.999). An empty
+// window meets the objective: nothing was late or lost.
+func Summarize(window string, objective float64, target time.Duration, rollups []domain.SLORollup) Report {
+	rep := Report{Window: window, Objective: objective, TargetMs: int(target.Milliseconds()), Met: true, Hours: rollups}
+	if rep.Hours == nil {
+		rep.Hours = []domain.SLORollup{}
+	}
+	for _, h := range rollups {
+		rep.Processed += h.Processed
+		rep.Failed += h.Failed
+		rep.WithinTarget += h.WithinTarget
+		if h.P99Ms != nil && (rep.WorstP99Ms == nil || *h.P99Ms > *rep.WorstP99Ms) {
+			p99 := *h.P99Ms
+			rep.WorstP99Ms = &p99
+		}
+	}
+	if total := rep.Processed + rep.Failed; total > 0 {
+		success := float64(rep.Processed) / float64(total)
+		within := float64(rep.WithinTarget) / float64(total)
+		rep.SuccessRate, rep.WithinTargetRate = &success, &within
+		rep.Met = within >= objective
+	}
+	return rep
+}
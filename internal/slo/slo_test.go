@@ -0,0 +1,61 @@
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+type fakeStore struct {
+	from, to time.Time
+	target   time.Duration
+}
+
+func (f *fakeStore) RollUpSLO(from, to time.Time, target time.Duration) (int64, error) {
+	f.from, f.to, f.target = from, to, target
+	return 2, nil
+}
+
+func TestRoller_RollsUpCurrentAndPreviousHour(t *testing.T) {
+	store := &fakeStore{}
+	r := &Roller{Store: store}
+	now := time.Date(2026, 10, 16, 14, 25, 0, 0, time.UTC)
+	r.RollUp(now)
+	if want := time.Date(2026, 10, 16, 13, 0, 0, 0, time.UTC); !store.from.Equal(want) || !store.to.Equal(now) {
+		t.Errorf("rolled up [%v, %v), want [%v, %v)", store.from, store.to, want, now)
+	}
+	if store.target != DefaultTarget {
+		t.Errorf("target = %v, want %v", store.target, DefaultTarget)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	p99a, p99b := 1200.0, 4100.0
+	hours := []domain.SLORollup{
+		{Processed: 5000, WithinTarget: 4999, P99Ms: &p99a},
+		{Processed: 4999, Failed: 1, WithinTarget: 4997, P99Ms: &p99b},
+		{Failed: 1},
+	}
+	rep := Summarize("7d", 0.999, 5*time.Second, hours)
+	if rep.Processed != 9999 || rep.Failed != 2 || rep.WithinTarget != 9996 {
+		t.Fatalf("totals = %d/%d/%d, want 9999/2/9996", rep.Processed, rep.Failed, rep.WithinTarget)
+	}
+	if r := rep.WithinTargetRate; r == nil || *r < 0.9995 || *r > 0.9996 || !rep.Met {
+		t.Errorf("within target rate = %v, met %v; want 9996/10001, met", r, rep.Met)
+	}
+	if rep.WorstP99Ms == nil || *rep.WorstP99Ms != p99b {
+		t.Errorf("worst p99 = %v, want %v", rep.WorstP99Ms, p99b)
+	}
+	if rep.TargetMs != 5000 {
+		t.Errorf("target_ms = %d, want 5000", rep.TargetMs)
+	}
+
+	missed := Summarize("1d", 0.999, 5*time.Second, []domain.SLORollup{{Processed: 100, WithinTarget: 99}})
+	if missed.Met {
+		t.Error("99% within target met a 99.9% objective")
+	}
+	if empty := Summarize("1d", 0.999, 5*time.Second, nil); !empty.Met || empty.SuccessRate != nil || empty.Hours == nil {
+		t.Errorf("empty window = %+v, want met, no rates, empty hours", empty)
+	}
+}
@@ -0,0 +1,163 @@
+package sqlrow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Schema is the set of columns of each table, as built by the migrations.
+type Schema map[string]map[string]bool
+
+var (
+	lineComment = regexp.MustCompile(`--[^\n]*`)
+	createTable = regexp.MustCompile(`(?i)\bCREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?(\w+)\s*\(`)
+	alterTable  = regexp.MustCompile(`(?i)\bALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(\w+)\s+(.*)`)
+	addColumn   = regexp.MustCompile(`(?i)^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(\w+)`)
+	dropColumn  = regexp.MustCompile(`(?i)^DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?(\w+)`)
+	renameCol   = regexp.MustCompile(`(?i)^RENAME\s+(?:COLUMN\s+)?(\w+)\s+TO\s+(\w+)`)
+)
+
+// tableConstraints start a CREATE TABLE entry that is not a column.
+var tableConstraints = map[string]bool{"primary": true, "unique": true, "constraint": true, "foreign": true, "check": true, "exclude": true}
+
+// LoadSchema replays the *.sql files in dir, in name order, and returns the
+// resulting columns. It understands CREATE TABLE and ALTER TABLE ADD, DROP and
+// RENAME COLUMN — what the migrations use — and ignores everything else.
+func LoadSchema(dir string) (Schema, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("sqlrow: no migrations in %s", dir)
+	}
+	sort.Strings(files)
+	s := Schema{}
+	for _, f := range files {
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("sqlrow: read migration: %w", err)
+		}
+		if err := s.apply(string(raw)); err != nil {
+			return nil, fmt.Errorf("sqlrow: %s: %w", filepath.Base(f), err)
+		}
+	}
+	return s, nil
+}
+
+func (s Schema) apply(sql string) error {
+	sql = lineComment.ReplaceAllString(sql, "")
+	for _, stmt := range strings.Split(sql, ";") {
+		stmt = strings.Join(strings.Fields(stmt), " ")
+		if m := createTable.FindStringSubmatchIndex(stmt); m != nil {
+			table := strings.ToLower(stmt[m[2]:m[3]])
+			body, err := parenBody(stmt[m[1]:])
+			if err != nil {
+				return fmt.Errorf("table %s: %w", table, err)
+			}
+			cols := map[string]bool{}
+			for _, entry := range splitTopLevel(body) {
+				fields := strings.Fields(entry)
+				if len(fields) == 0 || tableConstraints[strings.ToLower(fields[0])] {
+					continue
+				}
+				cols[strings.ToLower(fields[0])] = true
+			}
+			if _, ok := s[table]; !ok {
+				s[table] = cols
+			}
+			continue
+		}
+		m := alterTable.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		table := strings.ToLower(m[1])
+		cols, ok := s[table]
+		if !ok {
+			return fmt.Errorf("ALTER TABLE %s before it is created", table)
+		}
+		for _, action := range splitTopLevel(m[2]) {
+			action = strings.TrimSpace(action)
+			if a := addColumn.FindStringSubmatch(action); a != nil && !tableConstraints[strings.ToLower(a[1])] {
+				cols[strings.ToLower(a[1])] = true
+			} else if d := dropColumn.FindStringSubmatch(action); d != nil {
+				delete(cols, strings.ToLower(d[1]))
+			} else if r := renameCol.FindStringSubmatch(action); r != nil {
+				delete(cols, strings.ToLower(r[1]))
+				cols[strings.ToLower(r[2])] = true
+			}
+		}
+	}
+	return nil
+}
+
+// parenBody returns the text up to the parenthesis that closes one already open.
+func parenBody(s string) (string, error) {
+	depth := 1
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s[:i], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unbalanced parentheses")
+}
+
+// splitTopLevel splits s on commas outside parentheses.
+func splitTopLevel(s string) []string {
+	var out []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(out, s[start:])
+}
+
+// Check reports columns that table doesn't have, or a table that doesn't exist.
+func (s Schema) Check(table string, columns ...string) error {
+	cols, ok := s[table]
+	if !ok {
+		return fmt.Errorf("sqlrow: no table %s in the migrations", table)
+	}
+	var missing []string
+	for _, c := range columns {
+		if !cols[c] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("sqlrow: %s has no column %s", table, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// CheckAll checks every projection, returning one error per mismatched projection.
+func (s Schema) CheckAll(projections []Checked) []error {
+	var errs []error
+	for _, p := range projections {
+		if err := s.Check(p.TableName(), p.ColumnNames()...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
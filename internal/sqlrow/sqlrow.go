@@ -0,0 +1,109 @@
+// Package sqlrow is the typed row layer under the hand-written SQL in db and
+// idempotency. A Projection pairs every selected column with the field of a Go
+// row type it scans into, so a query's SELECT list and its Scan arguments come
+// from one definition and can't drift apart in count, order, or type. Schema
+// replays migrations/ so unit tests can check every registered projection
+// against the tables as migrated, without a database.
+//
+//	var aliasColumns = sqlrow.New("merchant_aliases",
+//		sqlrow.Col("alias_key", func(a *domain.MerchantAlias) any { return &a.AliasKey }),
+//		sqlrow.Col("canonical", func(a *domain.MerchantAlias) any { return &a.Canonical }),
+//	)
+//
+//	rows, err := db.QueryContext(ctx, `SELECT `+aliasColumns.List()+` FROM merchant_aliases`)
+//	...
+//	var a domain.MerchantAlias
+//	err = rows.Scan(aliasColumns.Dest(&a)...)
+//
+// Nullable columns scan into pointer fields (*string, *time.Time) or into
+// sql.Null* fields of a row type that embeds the domain struct.
+package sqlrow
+
+import (
+	"strings"
+	"sync"
+)
+
+// Column maps one column of a table to where it scans in a T.
+type Column[T any] struct {
+	Name string
+	Dest func(*T) any
+}
+
+// Col returns a Column; with a typed func literal the row type is inferred.
+func Col[T any](name string, dest func(*T) any) Column[T] {
+	return Column[T]{Name: name, Dest: dest}
+}
+
+// Projection is an ordered list of one table's columns read into a T.
+type Projection[T any] struct {
+	table   string
+	columns []Column[T]
+}
+
+// New returns the projection of table onto T and registers it for schema checks.
+func New[T any](table string, columns ...Column[T]) *Projection[T] {
+	p := &Projection[T]{table: table, columns: columns}
+	register(p)
+	return p
+}
+
+// List is the SELECT list: the column names, comma-separated.
+func (p *Projection[T]) List() string {
+	return strings.Join(p.ColumnNames(), ", ")
+}
+
+// Qualified is List with every column prefixed by alias ("f.flag_id, ..."), for joins.
+func (p *Projection[T]) Qualified(alias string) string {
+	names := p.ColumnNames()
+	for i, n := range names {
+		names[i] = alias + "." + n
+	}
+	return strings.Join(names, ", ")
+}
+
+// Dest returns row's scan destinations, in List order.
+func (p *Projection[T]) Dest(row *T) []any {
+	dest := make([]any, len(p.columns))
+	for i, c := range p.columns {
+		dest[i] = c.Dest(row)
+	}
+	return dest
+}
+
+// TableName is the table the columns belong to.
+func (p *Projection[T]) TableName() string { return p.table }
+
+// ColumnNames returns the column names, in List order.
+func (p *Projection[T]) ColumnNames() []string {
+	names := make([]string, len(p.columns))
+	for i, c := range p.columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// Checked is what Schema.CheckAll needs of a projection.
+type Checked interface {
+	TableName() string
+	ColumnNames() []string
+}
+
+var (
+	mu         sync.Mutex
+	registered []Checked
+)
+
+func register(p Checked) {
+	mu.Lock()
+	defer mu.Unlock()
+	registered = append(registered, p)
+}
+
+// Registered returns every projection created with New in this binary, so a
+// package's tests can check all of its projections at once.
+func Registered() []Checked {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Checked(nil), registered...)
+}
@@ -0,0 +1,82 @@
+package sqlrow
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type widget struct {
+	ID    string
+	Count int
+	Note  sql.NullString
+}
+
+func TestProjection(t *testing.T) {
+	p := New("widgets",
+		Col("widget_id", func(w *widget) any { return &w.ID }),
+		Col("count", func(w *widget) any { return &w.Count }),
+		Col("note", func(w *widget) any { return &w.Note }),
+	)
+	if got := p.List(); got != "widget_id, count, note" {
+		t.Errorf("List() = %q", got)
+	}
+	if got := p.Qualified("w"); got != "w.widget_id, w.count, w.note" {
+		t.Errorf("Qualified(w) = %q", got)
+	}
+	var w widget
+	dest := p.Dest(&w)
+	if len(dest) != 3 || dest[0] != &w.ID || dest[1] != &w.Count || dest[2] != &w.Note {
+		t.Errorf("Dest() = %v, want pointers into the row in List order", dest)
+	}
+	found := false
+	for _, r := range Registered() {
+		found = found || r == Checked(p)
+	}
+	if !found {
+		t.Error("New did not register the projection")
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	for name, sql := range map[string]string{
+		"001_widgets.sql": `-- widgets, with a comment, (and parentheses)
+CREATE TABLE IF NOT EXISTS widgets (
+    widget_id VARCHAR(255) PRIMARY KEY,
+    kind      VARCHAR(10) NOT NULL CHECK (kind IN ('a', 'b')),
+    amount    DECIMAL(18, 2) NOT NULL,
+    legacy    INTEGER,
+    UNIQUE (kind, amount)
+);`,
+		"002_widgets_note.sql": `ALTER TABLE widgets ADD COLUMN IF NOT EXISTS note TEXT;
+ALTER TABLE widgets ADD CONSTRAINT widgets_amount_positive CHECK (amount > 0);
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'widgets') THEN
+        ALTER TABLE widgets DROP COLUMN legacy;
+    END IF;
+END $$;
+ALTER TABLE widgets RENAME COLUMN kind TO category;`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(sql), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := LoadSchema(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Check("widgets", "widget_id", "category", "amount", "note"); err != nil {
+		t.Error(err)
+	}
+	for _, col := range []string{"legacy", "kind", "unique", "constraint"} {
+		if s.Check("widgets", col) == nil {
+			t.Errorf("Check(widgets, %s) = nil, want missing column", col)
+		}
+	}
+	if s.Check("gadgets", "id") == nil {
+		t.Error("Check(gadgets) = nil for an unknown table")
+	}
+}
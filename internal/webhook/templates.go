@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// Templates renders ack bodies from Go text/templates, so each producer can get
+// the fields it needs (amount, merchant, a metadata subset) instead of the
+// standard EventAck JSON. A template sees the ack's fields (.EventID, .Outcome,
+// .Reason, .Canary, .DedupToken, .OccurredAt) and .Event, the decoded event; .Event
+// is nil when the event never decoded (e.g. a hash mismatch), so guard it with
+// {{with .Event}}. Templates must render JSON.
+//
+// Functions: json (encode a value as JSON), amount (domain.FormatAmount), and
+// pick (a map of only the named keys, e.g. pick .Metadata "order_id" "sku").
+//
+// A nil *Templates, or a producer with no template and no default, renders the
+// standard EventAck JSON.
+type Templates struct {
+	def       *template.Template
+	producers map[string]*template.Template
+}
+
+// templateFile is the WEBHOOK_TEMPLATES_FILE shape: a default template and
+// per-producer ones keyed by hashed API key (domain.HashAPIKey).
+type templateFile struct {
+	Default   string            `yaml:"default"`
+	Producers map[string]string `yaml:"producers"`
+}
+
+// LoadTemplates reads and validates a templates file.
+func LoadTemplates(path string) (*Templates, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: read templates: %w", err)
+	}
+	var f templateFile
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("webhook: parse templates %s: %w", path, err)
+	}
+	return ParseTemplates(f.Default, f.Producers)
+}
+
+// ParseTemplates compiles def (may be empty) and the per-producer templates and
+// checks that each renders valid JSON for a sample ack, both with and without an
+// event, so a broken template fails at startup rather than on a delivery.
+func ParseTemplates(def string, producers map[string]string) (*Templates, error) {
+	t := &Templates{producers: map[string]*template.Template{}}
+	var err error
+	if def != "" {
+		if t.def, err = compileTemplate("default", def); err != nil {
+			return nil, err
+		}
+	}
+	for key, text := range producers {
+		if key == "" || text == "" {
+			return nil, fmt.Errorf("webhook: templates: empty producer key or template")
+		}
+		if t.producers[key], err = compileTemplate(key, text); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"amount": domain.FormatAmount,
+	"pick": func(m map[string]interface{}, keys ...string) map[string]interface{} {
+		out := make(map[string]interface{}, len(keys))
+		for _, k := range keys {
+			if v, ok := m[k]; ok {
+				out[k] = v
+			}
+		}
+		return out
+	},
+}
+
+func compileTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: template %s: %w", name, err)
+	}
+	now := time.Now()
+	sample := domain.NewEventAck("evt-sample", domain.AckOutcomeProcessed, "", now)
+	for _, event := range []*domain.Event{
+		domain.NewEvent("evt-sample", "user-1", 12.5, "USD", "Merchant", now, map[string]interface{}{"key": "value"}),
+		nil,
+	} {
+		sample.Event = event
+		if _, err := render(tmpl, sample); err != nil {
+			return nil, fmt.Errorf("webhook: template %s: %w", name, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// Render returns the body for producerKey's ack: its template, else the default,
+// else the standard EventAck JSON.
+func (t *Templates) Render(producerKey string, ack domain.EventAck) ([]byte, error) {
+	tmpl := t.lookup(producerKey)
+	if tmpl == nil {
+		return json.Marshal(ack)
+	}
+	return render(tmpl, ack)
+}
+
+func (t *Templates) lookup(producerKey string) *template.Template {
+	if t == nil {
+		return nil
+	}
+	if tmpl, ok := t.producers[producerKey]; ok {
+		return tmpl
+	}
+	return t.def
+}
+
+func render(tmpl *template.Template, ack domain.EventAck) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ack); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("rendered body is not valid JSON")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+const orderTemplate = `{"id": {{json .EventID}}, "status": {{json .Outcome}}
+{{- with .Event}}, "amount": {{json (amount .Amount .Currency)}}, "merchant": {{json .Merchant}}, "meta": {{json (pick .Metadata "order_id")}}{{end}}}`
+
+func TestTemplates_Render(t *testing.T) {
+	tmpls, err := ParseTemplates(`{"event_id": {{json .EventID}}}`, map[string]string{"key-orders": orderTemplate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack := domain.NewEventAck("evt-1", domain.AckOutcomeProcessed, "", time.Now())
+	ack.Event = domain.NewEvent("evt-1", "u1", 1234.5, "USD", "Shop", time.Now(), map[string]interface{}{"order_id": "o-9", "secret": "x"})
+
+	body, err := tmpls.Render("key-orders", ack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("body %s: %v", body, err)
+	}
+	meta, _ := got["meta"].(map[string]interface{})
+	if got["id"] != "evt-1" || got["amount"] != "1,234.50 USD" || got["merchant"] != "Shop" || len(meta) != 1 || meta["order_id"] != "o-9" {
+		t.Errorf("rendered %s", body)
+	}
+
+	ack.Event = nil
+	if body, err := tmpls.Render("key-orders", ack); err != nil || string(body) != `{"id": "evt-1", "status": "processed"}` {
+		t.Errorf("without event: %s, %v", body, err)
+	}
+	if body, _ := tmpls.Render("other", ack); string(body) != `{"event_id": "evt-1"}` {
+		t.Errorf("default template: %s", body)
+	}
+
+	var none *Templates
+	body, err = none.Render("key-orders", ack)
+	var std domain.EventAck
+	if err != nil || json.Unmarshal(body, &std) != nil || std.DedupToken != ack.DedupToken {
+		t.Errorf("nil Templates rendered %s, %v; want the standard ack", body, err)
+	}
+}
+
+func TestParseTemplates_RejectsAtStartup(t *testing.T) {
+	for name, text := range map[string]string{
+		"syntax":        `{"id": {{.EventID}`,
+		"unknown field": `{"id": {{json .EventNumber}}}`,
+		"not json":      `id={{.EventID}}`,
+		"unguarded":     `{"amount": {{json .Event.Amount}}}`,
+	} {
+		if _, err := ParseTemplates("", map[string]string{"k": text}); err == nil {
+			t.Errorf("%s: ParseTemplates accepted %q", name, text)
+		}
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.yaml")
+	yaml := "default: '{\"id\": {{json .EventID}}}'\nproducers:\n  key-a: '{\"event\": {{json .EventID}}}'\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tmpls, err := LoadTemplates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack := domain.NewEventAck("evt-2", domain.AckOutcomeFailed, "x", time.Now())
+	if body, _ := tmpls.Render("key-a", ack); string(body) != `{"event": "evt-2"}` {
+		t.Errorf("key-a rendered %s", body)
+	}
+}
@@ -0,0 +1,249 @@
+// Package webhook delivers producer acknowledgment webhooks: when an event
+// submitted with an X-API-Key reaches a terminal status, the processor hands a
+// domain.EventAck to a Dispatcher, which POSTs it to the URL registered for that
+// key (query service, PUT /webhooks).
+//
+// Delivery is best-effort and asynchronous. A slow or failing producer endpoint
+// never holds up event processing: the queue is bounded and acks beyond it are
+// dropped (webhook_deliveries_total{status="dropped"}). Deliveries are
+// at-least-once, so receivers should dedupe on the ack's dedup_token. Producers can
+// always reconcile through POST /events/status-batch. Bodies are the EventAck JSON
+// unless Templates gives the producer its own shape.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/fluxa/fluxa/internal/signing"
+)
+
+// HeaderDedupToken carries the ack's dedup_token. Deliveries are also signed with
+// the webhook secret (signing.HeaderSignature/HeaderTimestamp); receivers can check
+// them with signing.Verify.
+const HeaderDedupToken = "X-Fluxa-Dedup-Token"
+
+// Store looks up registered webhooks and stamps delivered acks on the event's
+// timeline; *db.Client implements it. A key without a webhook returns
+// db.ErrNotFound.
+type Store interface {
+	GetProducerWebhook(apiKeyHash string) (*domain.ProducerWebhook, error)
+	MarkEventNotified(eventID string, at time.Time) error
+}
+
+// Options tunes a Dispatcher. Zero values select the defaults.
+type Options struct {
+	Workers     int           // concurrent deliveries; default 4
+	QueueSize   int           // pending acks before new ones are dropped; default 1000
+	MaxAttempts int           // per ack, including the first; default 3
+	Backoff     time.Duration // before the second attempt, doubling after; default 1s
+	CacheTTL    time.Duration // webhook lookups (including "none") are cached this long; default 1m
+	Templates   *Templates    // per-producer bodies; nil sends the standard EventAck JSON
+	Secrets     Secrets       // signing secrets that override the registered ones; nil uses the registered ones
+}
+
+// Secrets gives producers webhook signing secrets managed outside the webhook
+// registry; *secrets.Store implements it. A producer it has a secret for gets its
+// acks signed with that one instead of the secret PUT /webhooks generated.
+type Secrets interface {
+	WebhookSecret(apiKeyHash string) (string, bool)
+}
+
+type job struct {
+	producerKey string
+	ack         domain.EventAck
+}
+
+type cachedHook struct {
+	hook      *domain.ProducerWebhook // nil: no webhook registered
+	expiresAt time.Time
+}
+
+// Dispatcher queues acks and delivers them from a fixed worker pool.
+type Dispatcher struct {
+	store   Store
+	client  *http.Client
+	metrics ports.Metrics
+	logger  *logging.Logger
+	opts    Options
+
+	jobs chan job
+	wg   sync.WaitGroup
+
+	mu    sync.Mutex
+	cache map[string]cachedHook
+}
+
+// NewDispatcher starts opts.Workers delivery workers. client should carry a timeout.
+func NewDispatcher(store Store, client *http.Client, metrics ports.Metrics, logger *logging.Logger, opts Options) *Dispatcher {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = time.Minute
+	}
+	d := &Dispatcher{
+		store:   store,
+		client:  client,
+		metrics: metrics,
+		logger:  logger,
+		opts:    opts,
+		jobs:    make(chan job, opts.QueueSize),
+		cache:   map[string]cachedHook{},
+	}
+	for i := 0; i < opts.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Notify queues ack for the webhook of producerKey without blocking; a full queue
+// drops it.
+func (d *Dispatcher) Notify(producerKey string, ack domain.EventAck) {
+	select {
+	case d.jobs <- job{producerKey: producerKey, ack: ack}:
+	default:
+		d.metrics.IncCounter(metricdef.WebhookDeliveriesTotal, "status", "dropped")
+		d.logger.Warn("Webhook queue full, dropping ack", map[string]interface{}{"event_id": ack.EventID})
+	}
+}
+
+// Close stops accepting acks and waits for queued ones to be delivered.
+func (d *Dispatcher) Close() {
+	close(d.jobs)
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for j := range d.jobs {
+		d.handle(j)
+	}
+}
+
+func (d *Dispatcher) handle(j job) {
+	hook, err := d.lookup(j.producerKey)
+	if err != nil {
+		d.metrics.IncCounter(metricdef.WebhookDeliveriesTotal, "status", "failed")
+		d.logger.Error("Webhook lookup failed", err, map[string]interface{}{"event_id": j.ack.EventID})
+		return
+	}
+	if hook == nil {
+		return // producer has no webhook registered
+	}
+
+	body, err := d.opts.Templates.Render(j.producerKey, j.ack)
+	if err != nil {
+		// Templates were checked at startup; a failure here is data-dependent, and
+		// the standard body still tells the producer what happened.
+		d.logger.Warn("Ack template failed; sending the standard body", map[string]interface{}{
+			"event_id": j.ack.EventID,
+			"error":    err.Error(),
+		})
+		if body, err = json.Marshal(j.ack); err != nil {
+			d.logger.Error("Failed to marshal ack", err)
+			return
+		}
+	}
+	secret := hook.Secret
+	if d.opts.Secrets != nil {
+		if managed, ok := d.opts.Secrets.WebhookSecret(j.producerKey); ok {
+			secret = managed
+		}
+	}
+	backoff := d.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(hook.URL, secret, j.ack.DedupToken, body)
+		if err == nil {
+			d.metrics.IncCounter(metricdef.WebhookDeliveriesTotal, "status", "delivered")
+			if err := d.store.MarkEventNotified(j.ack.EventID, time.Now().UTC()); err != nil {
+				d.logger.Warn("Failed to record ack delivery", map[string]interface{}{"event_id": j.ack.EventID, "error": err.Error()})
+			}
+			return
+		}
+		if !retry || attempt >= d.opts.MaxAttempts {
+			d.metrics.IncCounter(metricdef.WebhookDeliveriesTotal, "status", "failed")
+			d.logger.Warn("Webhook delivery failed", map[string]interface{}{
+				"event_id": j.ack.EventID,
+				"attempts": attempt,
+				"error":    err.Error(),
+			})
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt, signed with secret, and reports whether a
+// failure is worth retrying (network errors, 429, 5xx).
+func (d *Dispatcher) post(url, secret, dedupToken string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("webhook: build request: %w", err)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signing.HeaderTimestamp, ts)
+	req.Header.Set(signing.HeaderSignature, signing.Sign(secret, ts, body))
+	req.Header.Set(HeaderDedupToken, dedupToken)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook: post: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook: unexpected status %d", resp.StatusCode)
+}
+
+// lookup returns the cached webhook for producerKey, refreshing it after CacheTTL.
+// Errors are not cached.
+func (d *Dispatcher) lookup(producerKey string) (*domain.ProducerWebhook, error) {
+	now := time.Now()
+	d.mu.Lock()
+	if c, ok := d.cache[producerKey]; ok && now.Before(c.expiresAt) {
+		d.mu.Unlock()
+		return c.hook, nil
+	}
+	d.mu.Unlock()
+
+	hook, err := d.store.GetProducerWebhook(producerKey)
+	if errors.Is(err, db.ErrNotFound) {
+		hook, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.cache[producerKey] = cachedHook{hook: hook, expiresAt: now.Add(d.opts.CacheTTL)}
+	d.mu.Unlock()
+	return hook, nil
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/signing"
+)
+
+type mapStore struct {
+	mu       sync.Mutex
+	hooks    map[string]*domain.ProducerWebhook
+	calls    int
+	notified map[string]time.Time
+}
+
+func (s *mapStore) GetProducerWebhook(apiKeyHash string) (*domain.ProducerWebhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if h, ok := s.hooks[apiKeyHash]; ok {
+		return h, nil
+	}
+	return nil, db.ErrNotFound
+}
+
+func (s *mapStore) MarkEventNotified(eventID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.notified == nil {
+		s.notified = map[string]time.Time{}
+	}
+	s.notified[eventID] = at
+	return nil
+}
+
+func newTestDispatcher(store Store, m *fluxatest.Metrics) *Dispatcher {
+	return NewDispatcher(store, &http.Client{Timeout: time.Second}, m, logging.NewLogger("test", "test"),
+		Options{Workers: 1, Backoff: time.Millisecond})
+}
+
+func TestDispatcher_DeliversSignedAck(t *testing.T) {
+	type delivery struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan delivery, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- delivery{r.Header.Clone(), body}
+	}))
+	defer srv.Close()
+
+	key := domain.HashAPIKey("k1")
+	store := &mapStore{hooks: map[string]*domain.ProducerWebhook{key: {URL: srv.URL, Secret: "s3cret"}}}
+	m := fluxatest.NewMetrics()
+	d := newTestDispatcher(store, m)
+	ack := domain.NewEventAck("evt-1", domain.AckOutcomeFailed, "hash_mismatch", time.Now())
+	d.Notify(key, ack)
+	d.Close()
+
+	dl := <-got
+	var decoded domain.EventAck
+	if err := json.Unmarshal(dl.body, &decoded); err != nil || decoded.EventID != "evt-1" || decoded.Reason != "hash_mismatch" {
+		t.Fatalf("delivered body = %s (%v)", dl.body, err)
+	}
+	ts, sig := dl.header.Get(signing.HeaderTimestamp), dl.header.Get(signing.HeaderSignature)
+	if err := signing.Verify("s3cret", ts, sig, dl.body, time.Now(), time.Minute); err != nil {
+		t.Errorf("delivery signature %q (ts %s) does not verify: %v", sig, ts, err)
+	}
+	if dl.header.Get(HeaderDedupToken) != ack.DedupToken {
+		t.Errorf("dedup token header = %q, want %q", dl.header.Get(HeaderDedupToken), ack.DedupToken)
+	}
+	if m.Counter(metricdef.WebhookDeliveriesTotal, "delivered") != 1 {
+		t.Error("delivery not counted")
+	}
+	if _, ok := store.notified["evt-1"]; !ok {
+		t.Error("delivery not stamped on the event's timeline")
+	}
+}
+
+func TestDispatcher_RetriesServerErrorsOnly(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantCalls int32
+		wantStat  string
+	}{
+		{"5xx then success", []int{500, 503, 200}, 3, "delivered"},
+		{"4xx is final", []int{400, 200}, 1, "failed"},
+		{"gives up after max attempts", []int{500, 500, 500, 200}, 3, "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			key := domain.HashAPIKey("k")
+			m := fluxatest.NewMetrics()
+			d := newTestDispatcher(&mapStore{hooks: map[string]*domain.ProducerWebhook{key: {URL: srv.URL}}}, m)
+			d.Notify(key, domain.NewEventAck("evt", domain.AckOutcomeProcessed, "", time.Now()))
+			d.Close()
+
+			if calls != tt.wantCalls {
+				t.Errorf("endpoint called %d times, want %d", calls, tt.wantCalls)
+			}
+			if m.Counter(metricdef.WebhookDeliveriesTotal, tt.wantStat) != 1 {
+				t.Errorf("webhook_deliveries_total{%s} not counted", tt.wantStat)
+			}
+		})
+	}
+}
+
+func TestDispatcher_CachesMissingWebhook(t *testing.T) {
+	store := &mapStore{hooks: map[string]*domain.ProducerWebhook{}}
+	d := newTestDispatcher(store, fluxatest.NewMetrics())
+	for i := 0; i < 5; i++ {
+		d.Notify("no-hook", domain.NewEventAck("evt", domain.AckOutcomeProcessed, "", time.Now()))
+	}
+	d.Close()
+	if store.calls != 1 {
+		t.Errorf("store queried %d times for an unregistered key, want 1", store.calls)
+	}
+}
+
+type mapSecrets map[string]string
+
+func (s mapSecrets) WebhookSecret(apiKeyHash string) (string, bool) {
+	secret, ok := s[apiKeyHash]
+	return secret, ok
+}
+
+func TestDispatcher_SignsWithManagedSecret(t *testing.T) {
+	got := make(chan http.Header, 2)
+	var bodies [][]byte
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		got <- r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	managed, registered := domain.HashAPIKey("k1"), domain.HashAPIKey("k2")
+	store := &mapStore{hooks: map[string]*domain.ProducerWebhook{
+		managed:    {URL: srv.URL, Secret: "generated"},
+		registered: {URL: srv.URL, Secret: "generated"},
+	}}
+	d := NewDispatcher(store, &http.Client{Timeout: time.Second}, fluxatest.NewMetrics(), logging.NewLogger("test", "test"),
+		Options{Workers: 1, Backoff: time.Millisecond, Secrets: mapSecrets{managed: "from-bundle"}})
+	d.Notify(managed, domain.NewEventAck("evt-1", domain.AckOutcomeProcessed, "", time.Now()))
+	d.Notify(registered, domain.NewEventAck("evt-2", domain.AckOutcomeProcessed, "", time.Now()))
+	d.Close()
+
+	for i, want := range []string{"from-bundle", "generated"} {
+		h := <-got
+		if err := signing.Verify(want, h.Get(signing.HeaderTimestamp), h.Get(signing.HeaderSignature), bodies[i], time.Now(), time.Minute); err != nil {
+			t.Errorf("delivery %d not signed with %s: %v", i+1, want, err)
+		}
+	}
+}
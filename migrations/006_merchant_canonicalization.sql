@@ -0,0 +1,16 @@
+-- 006_merchant_canonicalization.sql
+-- Maps noisy merchant descriptors ("AMZN Mktp US*2K3", "SQ *BLUE BOTTLE") to a
+-- canonical merchant name so aggregations aren't fragmented by descriptor noise.
+-- alias_key is the normalized descriptor produced by internal/merchant.Key.
+CREATE TABLE IF NOT EXISTS merchant_aliases (
+    alias_key  VARCHAR(255) PRIMARY KEY,
+    canonical  VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS canonical_merchant VARCHAR(255);
+CREATE INDEX IF NOT EXISTS idx_events_canonical_merchant ON events(canonical_merchant);
+
+COMMENT ON TABLE merchant_aliases IS 'Admin-managed merchant descriptor -> canonical merchant mappings';
+COMMENT ON COLUMN events.canonical_merchant IS 'Canonical merchant resolved by the processor; raw descriptor stays in merchant';
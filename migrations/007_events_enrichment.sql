@@ -0,0 +1,4 @@
+-- 007_events_enrichment.sql
+-- User attributes (country, segment, ...) fetched from the profile service at
+-- processing time (internal/enrichment). NULL when enrichment is disabled or skipped.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS enrichment_json JSONB;
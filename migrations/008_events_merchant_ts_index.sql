@@ -0,0 +1,4 @@
+-- 008_events_merchant_ts_index.sql
+-- Supports the per-merchant rolling amount statistics used by anomaly scoring
+-- (internal/anomaly via db.MerchantAmountDistributionAsOf).
+CREATE INDEX IF NOT EXISTS idx_events_merchant_ts ON events (merchant, ts);
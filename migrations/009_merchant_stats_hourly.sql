@@ -0,0 +1,17 @@
+-- 009_merchant_stats_hourly.sql
+-- Hourly per-merchant roll-up maintained on every new events row (db.InsertEvent),
+-- so merchant dashboards don't GROUP BY over the full events table. Keyed on the
+-- canonical merchant when resolved, else the raw descriptor; bucketed by event ts.
+CREATE TABLE IF NOT EXISTS merchant_stats_hourly (
+    merchant     VARCHAR(255)             NOT NULL,
+    bucket       TIMESTAMP WITH TIME ZONE NOT NULL,
+    event_count  BIGINT                   NOT NULL DEFAULT 0,
+    total_amount DECIMAL(20, 2)           NOT NULL DEFAULT 0,
+    max_amount   DECIMAL(18, 2)           NOT NULL DEFAULT 0,
+    PRIMARY KEY (merchant, bucket)
+);
+
+-- For "top merchants in window" scans
+CREATE INDEX IF NOT EXISTS idx_merchant_stats_hourly_bucket ON merchant_stats_hourly(bucket);
+
+COMMENT ON TABLE merchant_stats_hourly IS 'Hourly per-merchant event count/amount roll-up, maintained by db.InsertEvent';
@@ -0,0 +1,9 @@
+-- 010_events_canary.sql
+-- Synthetic canary events run the full pipeline but must never skew real data:
+-- aggregates, merchant_stats_hourly, and feature exports all filter on NOT is_canary.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS is_canary BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Canaries are a tiny fraction of traffic; a partial index keeps canary lookups cheap.
+CREATE INDEX IF NOT EXISTS idx_events_canary_ts ON events(ts) WHERE is_canary;
+
+COMMENT ON COLUMN events.is_canary IS 'Synthetic end-to-end check event; excluded from aggregates and exports';
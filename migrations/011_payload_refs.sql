@@ -0,0 +1,13 @@
+-- 011_payload_refs.sql
+-- Offloaded payloads are content-addressed (raw/sha256/<hash>.json), so one object
+-- may back many events. ref_count is the number of events rows pointing at the
+-- object; it is maintained by db.InsertEvent and must reach 0 before an object
+-- is safe to delete.
+CREATE TABLE IF NOT EXISTS payload_refs (
+    s3_key        VARCHAR(500)             PRIMARY KEY,
+    ref_count     INTEGER                  NOT NULL DEFAULT 0 CHECK (ref_count >= 0),
+    first_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_seen_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE payload_refs IS 'Reference counts for content-addressed payload objects, maintained by db.InsertEvent';
@@ -0,0 +1,20 @@
+-- 012_notification_audit.sql
+-- Audit trail for operator-triggered notification re-sends
+-- (POST /admin/events/{id}/notify). One row per request, including requests that
+-- re-sent nothing or failed part-way, so "who re-notified what, when" is answerable.
+CREATE TABLE IF NOT EXISTS notification_audit (
+    audit_id      BIGSERIAL                PRIMARY KEY,
+    event_id      VARCHAR(255)             NOT NULL REFERENCES events(event_id),
+    actor         VARCHAR(255)             NOT NULL,
+    reason        TEXT                     NOT NULL DEFAULT '',
+    channel       VARCHAR(50)              NOT NULL,
+    notifications INTEGER                  NOT NULL DEFAULT 0,
+    error         TEXT,
+    triggered_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_audit_event
+    ON notification_audit(event_id, triggered_at DESC);
+
+COMMENT ON TABLE notification_audit IS 'Operator-triggered notification re-sends (query service /admin/events/{id}/notify)';
+COMMENT ON COLUMN notification_audit.notifications IS 'Messages actually re-published before success or error';
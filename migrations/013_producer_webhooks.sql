@@ -0,0 +1,14 @@
+-- 013_producer_webhooks.sql
+-- Acknowledgment webhooks, one per producer API key. Keys are stored only as their
+-- SHA-256 (domain.HashAPIKey), matching the producer_key on queue messages. The
+-- processor POSTs an EventAck to url when an event submitted with that key reaches
+-- a terminal status; secret signs each delivery (HMAC-SHA256).
+CREATE TABLE IF NOT EXISTS producer_webhooks (
+    api_key_hash CHAR(64)                 PRIMARY KEY,
+    url          TEXT                     NOT NULL,
+    secret       VARCHAR(128)             NOT NULL,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE producer_webhooks IS 'Per-API-key acknowledgment callbacks (query /webhooks, delivered by processor)';
@@ -0,0 +1,17 @@
+-- 014_batches.sql
+-- Outcome of atomic batches (POST /events/batch with "atomic": true). The processor
+-- writes the row in the same transaction as the batch's events, so status 'success'
+-- means every member is in events and 'failed' means none is.
+CREATE TABLE IF NOT EXISTS batches (
+    batch_id        VARCHAR(255)             PRIMARY KEY,
+    atomic          BOOLEAN                  NOT NULL DEFAULT FALSE,
+    status          VARCHAR(20)              NOT NULL,
+    event_count     INTEGER                  NOT NULL DEFAULT 0,
+    failed_event_id VARCHAR(255),
+    error_reason    TEXT,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE batches IS 'Batch submissions and their outcome (query service GET /batches/{id})';
+COMMENT ON COLUMN batches.failed_event_id IS 'First member that failed validation; NULL when the batch succeeded or failed as a whole';
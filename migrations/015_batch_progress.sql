@@ -0,0 +1,32 @@
+-- 015_batch_progress.sql
+-- Progress tracking for every batch, not only atomic ones. submitted is the number
+-- of members enqueued; processed/failed count members that reached a terminal
+-- status. The processor keeps them in step with batch_members, which records each
+-- member's latest outcome so a redelivered or retried member is never counted twice.
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS submitted INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS processed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS failed    INTEGER NOT NULL DEFAULT 0;
+
+-- event_count (014) becomes submitted; atomic batches are all processed or all failed.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'batches' AND column_name = 'event_count') THEN
+        UPDATE batches SET
+            submitted = event_count,
+            processed = CASE WHEN status = 'success' THEN event_count ELSE 0 END,
+            failed    = CASE WHEN status = 'failed'  THEN event_count ELSE 0 END;
+        ALTER TABLE batches DROP COLUMN event_count;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS batch_members (
+    batch_id   VARCHAR(255)             NOT NULL REFERENCES batches(batch_id) ON DELETE CASCADE,
+    event_id   VARCHAR(255)             NOT NULL,
+    status     VARCHAR(20)              NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (batch_id, event_id)
+);
+
+COMMENT ON COLUMN batches.submitted IS 'Members enqueued by ingest (invalid members rejected at submit time are not counted)';
+COMMENT ON TABLE batch_members IS 'Latest terminal status (success/failed) of each non-atomic batch member';
@@ -0,0 +1,23 @@
+-- 016_exports.sql
+-- Asynchronous event exports (query POST /exports). The query service runs each job
+-- in the background, writes the file to object storage at object_key, and
+-- GET /exports/{id} reports progress (rows_exported of total_rows) and, once
+-- complete, a presigned download URL.
+CREATE TABLE IF NOT EXISTS exports (
+    export_id     VARCHAR(64)              PRIMARY KEY,
+    format        VARCHAR(16)              NOT NULL,
+    filters       JSONB                    NOT NULL DEFAULT '{}',
+    status        VARCHAR(20)              NOT NULL,
+    total_rows    INTEGER                  NOT NULL DEFAULT 0,
+    rows_exported INTEGER                  NOT NULL DEFAULT 0,
+    object_key    TEXT,
+    error_reason  TEXT,
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at  TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_exports_status ON exports (status) WHERE status IN ('pending', 'running');
+
+COMMENT ON TABLE exports IS 'Async event export jobs (query service /exports)';
+COMMENT ON COLUMN exports.total_rows IS 'Events matching the filters when the job started';
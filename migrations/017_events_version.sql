@@ -0,0 +1,8 @@
+-- 017_events_version.sql
+-- Row version for optimistic concurrency. Every amendment of an event bumps version
+-- in the same UPDATE that checks it (WHERE version = <If-Match>), so of two
+-- concurrent corrections based on the same read, the second fails instead of
+-- silently overwriting the first.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+COMMENT ON COLUMN events.version IS 'Starts at 1, incremented on every amendment; GET /events/:id returns it as the ETag';
@@ -0,0 +1,27 @@
+-- 018_events_soft_delete.sql
+-- Admin deletes are soft: DELETE /admin/events/{id} sets deleted_at, which hides the
+-- event from every read and aggregate (they all filter on deleted_at IS NULL) and
+-- takes it out of merchant_stats_hourly, until POST .../restore clears it. Only
+-- ?mode=hard (GDPR erasure) removes the row, with its flags and audit rows.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_events_deleted_at ON events(deleted_at) WHERE deleted_at IS NOT NULL;
+
+COMMENT ON COLUMN events.deleted_at IS 'Set by a soft delete; NULL for live events';
+
+-- Every delete, restore, and erasure, by whom and why. No foreign key: the trail
+-- must outlive a hard-deleted event.
+CREATE TABLE IF NOT EXISTS event_deletions (
+    deletion_id BIGSERIAL                PRIMARY KEY,
+    event_id    VARCHAR(255)             NOT NULL,
+    action      VARCHAR(20)              NOT NULL,
+    actor       VARCHAR(255)             NOT NULL,
+    reason      TEXT                     NOT NULL DEFAULT '',
+    version     INTEGER                  NOT NULL DEFAULT 0,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_deletions_event ON event_deletions(event_id, created_at DESC);
+
+COMMENT ON TABLE event_deletions IS 'Audit trail of admin event deletes (query service /admin/events/{id})';
+COMMENT ON COLUMN event_deletions.version IS 'Event version after the action; 0 for hard_delete';
@@ -0,0 +1,17 @@
+-- 019_event_revisions.sql
+-- PUT /events/{id} (query service) replaces an event in place. Before each change
+-- the replaced content is kept here with the version it had, so a revision can be
+-- inspected or rolled back by hand. A PUT with unchanged content writes nothing.
+CREATE TABLE IF NOT EXISTS event_revisions (
+    revision_id BIGSERIAL                PRIMARY KEY,
+    event_id    VARCHAR(255)             NOT NULL,
+    version     INTEGER                  NOT NULL,
+    content     JSONB                    NOT NULL,
+    actor       VARCHAR(255)             NOT NULL,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (event_id, version)
+);
+
+COMMENT ON TABLE event_revisions IS 'Superseded event content, one row per PUT /events/{id} that changed it';
+COMMENT ON COLUMN event_revisions.version IS 'Event version the content had; the replacement got version + 1';
+COMMENT ON COLUMN event_revisions.actor IS 'Hashed API key of the writing service';
@@ -0,0 +1,15 @@
+-- 020_event_timelines.sql
+-- Where each event spent its time: accepted by ingest (received_at), picked up by
+-- the processor (dequeued_at), committed to events (persisted_at), and its producer
+-- ack webhook delivered (notified_at; NULL without a webhook or before delivery).
+-- Written best-effort by the processor and webhook dispatcher; the first attempt
+-- that persists an event fixes its row.
+CREATE TABLE IF NOT EXISTS event_timelines (
+    event_id     VARCHAR(255)             PRIMARY KEY,
+    received_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    dequeued_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+    persisted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    notified_at  TIMESTAMP WITH TIME ZONE
+);
+
+COMMENT ON TABLE event_timelines IS 'Per-event processing timestamps, served by /admin/events/status';
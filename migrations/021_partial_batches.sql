@@ -0,0 +1,8 @@
+-- 021_partial_batches.sql
+-- Partial batches ("atomic": true, "partial": true) commit in one transaction but
+-- roll back a failing member to its savepoint instead of failing the batch. The
+-- failed members are listed in batch_members with the reason each one failed.
+ALTER TABLE batches ADD COLUMN IF NOT EXISTS partial BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE batch_members ADD COLUMN IF NOT EXISTS error_reason TEXT;
+
+COMMENT ON COLUMN batch_members.error_reason IS 'Why a partial batch member failed; NULL for ordinary batch members';
@@ -0,0 +1,30 @@
+-- 022_bulk_retries.sql
+-- Admin bulk retry (query POST /admin/retries). The processor keeps the queue
+-- message of each permanently failed event in idempotency_keys.failed_message, so
+-- once a fix ships the failures of one reason can be re-enqueued. Each retry job
+-- is a retry_jobs row, updated with its progress as it runs.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS failed_message JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_failed ON idempotency_keys (last_seen_at) WHERE status = 'failed';
+
+CREATE TABLE IF NOT EXISTS retry_jobs (
+    job_id       VARCHAR(64)              PRIMARY KEY,
+    reason       TEXT                     NOT NULL,
+    failed_from  TIMESTAMP WITH TIME ZONE NOT NULL,
+    failed_to    TIMESTAMP WITH TIME ZONE NOT NULL,
+    actor        TEXT                     NOT NULL,
+    status       VARCHAR(20)              NOT NULL,
+    total_keys   INTEGER                  NOT NULL DEFAULT 0,
+    enqueued     INTEGER                  NOT NULL DEFAULT 0,
+    skipped      INTEGER                  NOT NULL DEFAULT 0,
+    error_reason TEXT,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_retry_jobs_status ON retry_jobs (status) WHERE status IN ('pending', 'running');
+
+COMMENT ON COLUMN idempotency_keys.failed_message IS 'Queue message of a permanently failed event, for bulk retry; cleared on success';
+COMMENT ON TABLE retry_jobs IS 'Bulk retry jobs (query service /admin/retries)';
+COMMENT ON COLUMN retry_jobs.skipped IS 'Matching keys with no stored message (failed before migration 022)';
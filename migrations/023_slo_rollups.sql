@@ -0,0 +1,24 @@
+-- 023_slo_rollups.sql
+-- Hourly processing SLO roll-up (query GET /slo), rebuilt for the current and
+-- previous hour by the processor every SLO_ROLLUP_INTERVAL_SECONDS (db.RollUpSLO).
+-- processed/latency come from event_timelines (ingest received_at → persisted_at),
+-- bucketed by received_at; failed counts idempotency keys that failed permanently,
+-- bucketed by when they failed. within_target is the processed events whose latency
+-- was at most target_ms.
+CREATE TABLE IF NOT EXISTS slo_rollups (
+    bucket        TIMESTAMP WITH TIME ZONE PRIMARY KEY,
+    processed     BIGINT                   NOT NULL DEFAULT 0,
+    failed        BIGINT                   NOT NULL DEFAULT 0,
+    within_target BIGINT                   NOT NULL DEFAULT 0,
+    target_ms     INTEGER                  NOT NULL,
+    p50_ms        DOUBLE PRECISION,
+    p95_ms        DOUBLE PRECISION,
+    p99_ms        DOUBLE PRECISION,
+    max_ms        DOUBLE PRECISION,
+    updated_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- For the roll-up's hourly scans
+CREATE INDEX IF NOT EXISTS idx_event_timelines_received_at ON event_timelines (received_at);
+
+COMMENT ON TABLE slo_rollups IS 'Hourly ingest-to-persist latency percentiles and success counts, maintained by db.RollUpSLO';
@@ -0,0 +1,14 @@
+-- 024_events_client_reference.sql
+-- Producers may send their own ID for an event (client_reference: an order or
+-- transaction ID), unique per producer. producer_key is the submitting producer's
+-- hashed API key from the queue message ('' for unauthenticated ingest), so the
+-- unique index scopes references to the producer that sent them. A second event
+-- reusing a reference fails in the processor with client_reference_conflict.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS producer_key VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS client_reference VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_client_reference
+    ON events(producer_key, client_reference) WHERE client_reference IS NOT NULL;
+
+COMMENT ON COLUMN events.producer_key IS 'domain.HashAPIKey of the submitting producer; empty when ingest had no X-API-Key';
+COMMENT ON COLUMN events.client_reference IS 'Producer''s own event ID, unique per producer_key; NULL when not sent';
@@ -0,0 +1,21 @@
+-- 025_event_groups.sql
+-- Correlation groups: events sharing a producer-chosen group_id (e.g. every leg of
+-- a transfer), each declaring the group's size. event_groups tracks how many
+-- members are persisted; expected is the size declared by the first member
+-- persisted. completed_at is set once, by the processor that persists the last
+-- member, which then publishes the group's completion.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS group_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_events_group_id ON events(group_id) WHERE group_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS event_groups (
+    group_id     VARCHAR(255)             PRIMARY KEY,
+    expected     INTEGER                  NOT NULL,
+    persisted    INTEGER                  NOT NULL DEFAULT 0,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+COMMENT ON COLUMN events.group_id IS 'Correlation group (event_groups); NULL when the event is in none';
+COMMENT ON TABLE event_groups IS 'Member counts of correlation groups; complete once persisted reaches expected';
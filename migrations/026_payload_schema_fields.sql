@@ -0,0 +1,19 @@
+-- 026_payload_schema_fields.sql
+-- Schema drift detection: the field paths and JSON types seen in sampled event
+-- payloads (SCHEMA_DRIFT_SAMPLE_PERCENT), per producer and schema_version. A
+-- path/type pair not seen before for a known schema is drift: a new field, or a
+-- type change when the path was seen with another type.
+CREATE TABLE IF NOT EXISTS payload_schema_fields (
+    producer_key   VARCHAR(64)              NOT NULL,
+    schema_version VARCHAR(64)              NOT NULL,
+    field_path     VARCHAR(255)             NOT NULL,
+    json_type      VARCHAR(16)              NOT NULL,
+    first_seen_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_seen_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    seen_count     BIGINT                   NOT NULL DEFAULT 1,
+    PRIMARY KEY (producer_key, schema_version, field_path, json_type)
+);
+
+COMMENT ON TABLE payload_schema_fields IS 'Field paths and JSON types seen in sampled payloads, per producer and schema_version';
+COMMENT ON COLUMN payload_schema_fields.producer_key IS 'domain.HashAPIKey of the producer; empty when ingest had no X-API-Key';
+COMMENT ON COLUMN payload_schema_fields.field_path IS 'Dot-joined path, "[]" for array elements (metadata.items[].price)';
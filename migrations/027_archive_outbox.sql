@@ -0,0 +1,21 @@
+-- 027_archive_outbox.sql
+-- Sampled payloads ingest failed to archive (PAYLOAD_ARCHIVE_SAMPLE_PERCENT). The
+-- processor queues one here in the same statement that persists its event, and
+-- internal/archiveretry stores it later, then points the event at the object
+-- (events.s3_key, payload_refs) and deletes the row. A failed retry bumps
+-- attempts and backs off next_attempt_at; rows are never dropped.
+CREATE TABLE IF NOT EXISTS archive_outbox (
+    event_id        VARCHAR(255)             PRIMARY KEY,
+    producer_key    VARCHAR(64)              NOT NULL DEFAULT '',
+    payload_sha256  CHAR(64)                 NOT NULL,
+    payload         BYTEA                    NOT NULL,
+    attempts        INTEGER                  NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error      TEXT,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_archive_outbox_next_attempt ON archive_outbox (next_attempt_at);
+
+COMMENT ON TABLE archive_outbox IS 'Sampled payloads awaiting archival to object storage, drained by internal/archiveretry';
+COMMENT ON COLUMN archive_outbox.next_attempt_at IS 'Due time; a claim pushes it out by a lease so other processors skip the row';
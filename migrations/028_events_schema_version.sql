@@ -0,0 +1,8 @@
+-- 028_events_schema_version.sql
+-- The producer schema version an event was stored under, after the processor's
+-- upgrades (internal/schemamigrate). Reads upgrade events still under an older
+-- version, so a migration registered later applies to them too. NULL when the
+-- producer sent no schema_version, and for events stored before this column.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS schema_version VARCHAR(64);
+
+COMMENT ON COLUMN events.schema_version IS 'domain.Event.SchemaVersion as stored, after upgrades; NULL when unlabelled';
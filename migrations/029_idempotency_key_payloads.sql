@@ -0,0 +1,12 @@
+-- 029_idempotency_key_payloads.sql
+-- The payload each Idempotency-Key was first accepted with, by the event ID
+-- ingest derives from the key (domain.IdempotencyKeyEventID). Ingest reserves the
+-- row before enqueueing, so a retry with the same payload dedupes past the
+-- in-memory window and a reused key with a different payload is refused.
+CREATE TABLE IF NOT EXISTS idempotency_key_payloads (
+    event_id       VARCHAR(255) PRIMARY KEY,
+    payload_sha256 CHAR(64)                 NOT NULL,
+    created_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE idempotency_key_payloads IS 'Payload hash each Idempotency-Key was first accepted with, by derived event ID';
@@ -0,0 +1,20 @@
+-- 030_merchant_stats_hourly_backfill.sql
+-- merchant_stats_hourly (009) is only fed by new events rows, so a deployment that
+-- had events before it would show empty or short merchant stats until they aged
+-- out of the 90-day window. This seeds the roll-up from the stored events, with
+-- the same filters as the reads: no canaries, no soft-deleted events.
+--
+-- It only runs while the roll-up is empty, so re-running it changes nothing. The
+-- lock holds back the roll-up writes of events inserted meanwhile until the seed
+-- commits; those events aren't in its snapshot, so they add on top.
+DO $$
+BEGIN
+    LOCK TABLE merchant_stats_hourly IN SHARE ROW EXCLUSIVE MODE;
+    IF NOT EXISTS (SELECT 1 FROM merchant_stats_hourly) THEN
+        INSERT INTO merchant_stats_hourly (merchant, bucket, event_count, total_amount, max_amount)
+        SELECT COALESCE(canonical_merchant, merchant), date_trunc('hour', ts), COUNT(*), SUM(amount), MAX(amount)
+        FROM events
+        WHERE NOT is_canary AND deleted_at IS NULL
+        GROUP BY 1, 2;
+    END IF;
+END $$;
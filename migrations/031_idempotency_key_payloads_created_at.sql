@@ -0,0 +1,4 @@
+-- 031_idempotency_key_payloads_created_at.sql
+-- Ingest keeps Idempotency-Key reservations (029) for IDEMPOTENCY_KEY_TTL_HOURS
+-- and purges older ones by created_at in batches.
+CREATE INDEX IF NOT EXISTS idx_idempotency_key_payloads_created_at ON idempotency_key_payloads(created_at);
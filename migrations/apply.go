@@ -0,0 +1,192 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/logging"
+)
+
+// Advisory lock held while migrations are applied. The two-key form keeps it
+// apart from the single-bigint locks idempotency takes on event IDs.
+const (
+	lockClass  = 0x666c7861 // "flxa"
+	lockObject = 1
+)
+
+// Migration is one embedded migration file.
+type Migration struct {
+	Name     string // file name without .sql, e.g. "028_events_schema_version"
+	SQL      string
+	Checksum string // hex SHA-256 of SQL
+}
+
+// ChecksumError reports a migration recorded as applied whose file has changed
+// since: the schema may not be what this binary's migrations describe.
+type ChecksumError struct {
+	Name     string
+	Recorded string
+	Embedded string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("migrations: %s was applied with checksum %s but the embedded file has %s", e.Name, e.Recorded, e.Embedded)
+}
+
+// All returns the embedded migrations in the order they run.
+func All() ([]Migration, error) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Migration, 0, len(entries))
+	for _, e := range entries {
+		raw, err := files.ReadFile(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(raw)
+		out = append(out, Migration{
+			Name:     strings.TrimSuffix(e.Name(), ".sql"),
+			SQL:      string(raw),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	return out, nil
+}
+
+// Pending verifies applied, the recorded checksum of each applied migration by
+// name, against all, and returns the migrations of all still to apply. Recorded
+// migrations missing from all are ignored: they come from a newer binary.
+func Pending(all []Migration, applied map[string]string) ([]Migration, error) {
+	var out []Migration
+	for _, m := range all {
+		recorded, ok := applied[m.Name]
+		if !ok {
+			out = append(out, m)
+			continue
+		}
+		if recorded != m.Checksum {
+			return nil, &ChecksumError{Name: m.Name, Recorded: recorded, Embedded: m.Checksum}
+		}
+	}
+	return out, nil
+}
+
+// Apply brings db's schema up to date and returns the names of the migrations it
+// applied. It is safe to run from any number of replicas at once: it holds an
+// advisory lock throughout, so one replica applies while the others wait and
+// then find nothing left. Each migration runs in its own transaction with the
+// row recording it in schema_migrations, so a failure leaves the earlier ones
+// applied and the failed one to retry.
+//
+// The migrations are written to be re-runnable, so a database whose schema was
+// built by the Postgres init scripts, with no schema_migrations rows, is brought
+// under Apply by running them again.
+func Apply(ctx context.Context, db *sql.DB) ([]string, error) {
+	all, err := All()
+	if err != nil {
+		return nil, fmt.Errorf("migrations: read embedded files: %w", err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: get connection: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1, $2)`, lockClass, lockObject); err != nil {
+		return nil, fmt.Errorf("migrations: take lock: %w", err)
+	}
+	defer func() {
+		// A lost unlock is released with the connection when it closes.
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1, $2)`, lockClass, lockObject)
+	}()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		name       TEXT PRIMARY KEY,
+		checksum   TEXT NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return nil, fmt.Errorf("migrations: create schema_migrations: %w", err)
+	}
+	applied, err := recorded(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := Pending(all, applied)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, m := range pending {
+		if err := apply(ctx, conn, m); err != nil {
+			return names, err
+		}
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+// startupTimeout bounds ApplyAtStartup. Replicas starting together queue on the
+// migration lock; the bound is for one stuck behind a long migration, not for
+// the migrations themselves.
+const startupTimeout = 10 * time.Minute
+
+// ApplyAtStartup runs Apply for a service starting with DB_AUTO_MIGRATE and logs
+// what it applied and the schema version.
+func ApplyAtStartup(db *sql.DB, logger *logging.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+	applied, err := Apply(ctx, db)
+	if err != nil {
+		return err
+	}
+	logger.Info("Database migrations applied", map[string]interface{}{"applied": applied, "schema_version": Latest()})
+	return nil
+}
+
+// recorded reads schema_migrations: checksum by migration name.
+func recorded(ctx context.Context, conn *sql.Conn) (map[string]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT name, checksum FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	out := map[string]string{}
+	for rows.Next() {
+		var name, checksum string
+		if err := rows.Scan(&name, &checksum); err != nil {
+			return nil, fmt.Errorf("migrations: read schema_migrations: %w", err)
+		}
+		out[name] = checksum
+	}
+	return out, rows.Err()
+}
+
+// apply runs m and records it, together.
+func apply(ctx context.Context, conn *sql.Conn, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrations: %s: begin: %w", m.Name, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	// The pool's statement_timeout is sized for queries, not DDL on large tables.
+	if _, err := tx.ExecContext(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return fmt.Errorf("migrations: %s: %w", m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return fmt.Errorf("migrations: %s: %w", m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (name, checksum) VALUES ($1, $2)`, m.Name, m.Checksum); err != nil {
+		return fmt.Errorf("migrations: %s: record: %w", m.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrations: %s: commit: %w", m.Name, err)
+	}
+	return nil
+}
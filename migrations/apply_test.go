@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+func TestPending(t *testing.T) {
+	all, err := All()
+	if err != nil || len(all) == 0 {
+		t.Fatalf("All = %d migrations, %v", len(all), err)
+	}
+	if all[len(all)-1].Name != Latest() {
+		t.Errorf("last migration = %q, want Latest() %q", all[len(all)-1].Name, Latest())
+	}
+
+	applied := map[string]string{all[0].Name: all[0].Checksum, "999_from_a_newer_binary": "abc"}
+	pending, err := Pending(all, applied)
+	if err != nil || len(pending) != len(all)-1 || pending[0].Name != all[1].Name {
+		t.Errorf("Pending = %d from %v, %v; want all but the first", len(pending), pending, err)
+	}
+
+	applied[all[1].Name] = "edited"
+	var mismatch *ChecksumError
+	if _, err := Pending(all, applied); !errors.As(err, &mismatch) || mismatch.Name != all[1].Name {
+		t.Errorf("Pending = %v, want a ChecksumError for %s", err, all[1].Name)
+	}
+}
+
+// TestApply_Concurrent starts several replicas at once against a live database;
+// exactly one applies any pending migration and none fails.
+func TestApply_Concurrent(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN not set, skipping integration test")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total int
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := Apply(ctx, db)
+			if err != nil {
+				t.Errorf("Apply = %v", err)
+			}
+			mu.Lock()
+			total += len(applied)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	all, _ := All()
+	var recorded int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&recorded); err != nil {
+		t.Fatalf("count schema_migrations: %v", err)
+	}
+	if recorded < len(all) || total > len(all) {
+		t.Errorf("recorded %d, applied %d in total; want all %d recorded, each applied at most once", recorded, total, len(all))
+	}
+	if applied, err := Apply(ctx, db); err != nil || len(applied) != 0 {
+		t.Errorf("second Apply = %v, %v; want nothing to do", applied, err)
+	}
+}
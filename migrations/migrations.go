@@ -0,0 +1,28 @@
+// Package migrations embeds the SQL migrations, which the Postgres container applies
+// in name order (docker-compose mounts this directory as its init scripts), so a
+// binary can report the schema it was built against. With DB_AUTO_MIGRATE the
+// processor and query service apply them themselves at startup (Apply).
+//
+// Migrations must stay re-runnable (IF NOT EXISTS, guarded DO blocks), and an
+// applied one must not be edited: Apply refuses to run against a database that
+// recorded a different checksum for it.
+package migrations
+
+import (
+	"embed"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Latest returns the name of the last migration without its extension, e.g.
+// "031_idempotency_key_payloads_created_at".
+func Latest() string {
+	entries, err := files.ReadDir(".")
+	if err != nil || len(entries) == 0 {
+		return ""
+	}
+	// ReadDir sorts by name, the order the migrations run in.
+	return strings.TrimSuffix(entries[len(entries)-1].Name(), ".sql")
+}
@@ -6,12 +6,17 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	prommetrics "github.com/fluxa/fluxa/internal/adapters/prometheus"
-	"github.com/fluxa/fluxa/internal/adapters/rabbitmq"
+	"github.com/fluxa/fluxa/internal/clients"
 	"github.com/fluxa/fluxa/internal/config"
+	"github.com/fluxa/fluxa/internal/diagnostics"
 	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/health"
 	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/notify"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -24,7 +29,7 @@ func main() {
 
 	logger := logging.NewLogger("alert-consumer", "init")
 
-	mqClient, err := rabbitmq.NewClient(cfg.RabbitMQURL)
+	mqClient, err := clients.New(cfg, "alert-consumer").Queue()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to connect to RabbitMQ: %v\n", err)
 		os.Exit(1)
@@ -33,6 +38,16 @@ func main() {
 
 	metrics := prommetrics.NewMetrics("alert-consumer")
 
+	// Alerts are published at least once (processor redelivery, admin re-send);
+	// drop repeats of a dedup token so each alert is reported once.
+	deduper := notify.NewDeduper(100000, 24*time.Hour)
+
+	// Probes share the metrics port: the consumer serves no other HTTP.
+	probes := health.New()
+	probes.Add("queue", mqClient.Ping)
+	probes.Register(http.DefaultServeMux)
+	diagnostics.Log(context.Background(), logger, diagnostics.Startup{Service: "alert-consumer", Config: cfg, Probes: probes})
+
 	// Prometheus metrics endpoint
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
@@ -43,7 +58,10 @@ func main() {
 
 	logger.Info("Alert consumer starting — consuming from 'alerts' queue", nil)
 
-	ctx := context.Background()
+	ctx, stopConsuming := context.WithCancel(context.Background())
+	defer stopConsuming()
+	probes.OnShutdown(cfg.ShutdownDrain(), stopConsuming)
+
 	deliveries, err := mqClient.Consume(ctx, "alerts")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to start consuming alerts: %v\n", err)
@@ -58,6 +76,31 @@ func main() {
 			continue
 		}
 
+		if deduper.Seen(alert.DedupToken) {
+			logger.Info("Duplicate alert dropped", map[string]interface{}{
+				"flag_id":     alert.FlagID,
+				"event_id":    alert.EventID,
+				"rule_name":   alert.RuleName,
+				"dedup_token": alert.DedupToken,
+			})
+			metrics.IncCounter(metricdef.AlertsDeduplicatedTotal)
+			_ = d.Ack()
+			continue
+		}
+
+		// Canary alerts prove the alert path end to end; they are counted but never
+		// reported as fraud.
+		if alert.Canary {
+			logger.Info("Canary alert received", map[string]interface{}{
+				"flag_id":   alert.FlagID,
+				"event_id":  alert.EventID,
+				"rule_name": alert.RuleName,
+			})
+			metrics.IncCounter(metricdef.CanaryAlertsConsumedTotal)
+			_ = d.Ack()
+			continue
+		}
+
 		logger.Info("FRAUD ALERT", map[string]interface{}{
 			"flag_id":    alert.FlagID,
 			"event_id":   alert.EventID,
@@ -67,7 +110,7 @@ func main() {
 			"flagged_at": alert.FlaggedAt.Format("2006-01-02T15:04:05Z07:00"),
 		})
 
-		metrics.IncCounter("alerts_consumed_total")
+		metrics.IncCounter(metricdef.AlertsConsumedTotal)
 		_ = d.Ack()
 	}
 
@@ -6,18 +6,19 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"os/signal"
 	"strconv"
-	"syscall"
 	"time"
 
 	prommetrics "github.com/fluxa/fluxa/internal/adapters/prometheus"
 	scoreradapter "github.com/fluxa/fluxa/internal/adapters/scorer"
+	"github.com/fluxa/fluxa/internal/clients"
 	"github.com/fluxa/fluxa/internal/config"
 	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/diagnostics"
 	"github.com/fluxa/fluxa/internal/fraud"
 	"github.com/fluxa/fluxa/internal/fraudeval"
 	fraudv1 "github.com/fluxa/fluxa/internal/grpc/fraud/v1"
+	"github.com/fluxa/fluxa/internal/health"
 	"github.com/fluxa/fluxa/internal/logging"
 	"github.com/fluxa/fluxa/internal/observability"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -43,7 +44,8 @@ func main() {
 
 	shutdownTracing := observability.Init("fraud-grpc")
 
-	dbClient, err := db.NewClient(cfg.DSN(), 10)
+	pool := db.PoolFor(cfg.DBPoolStrategy, cfg.DBPoolIdleTimeout())
+	dbClient, err := db.Connect(cfg.DSN(), pool, cfg.DBLazyConnect)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create database client: %v\n", err)
 		os.Exit(1)
@@ -57,7 +59,12 @@ func main() {
 	}
 
 	metrics := prommetrics.NewMetrics("fraud-grpc")
+	dbClient.WithTimeouts(cfg.DBTimeouts()).WithMetrics(metrics).WithSlowQueryLog(cfg.DBSlowQuery(), logger)
 	srv := fraudeval.NewServer(engine, dbClient, metrics, logger, version)
+	srv.Validation = cfg.EventValidation()
+	factory := clients.New(cfg, "fraud-grpc")
+	srv.Flags = factory.Flags(logger)
+	srv.Tunables = factory.Tunables(context.Background(), metrics, logger)
 
 	// Wire the ML scorer (best-effort, fail-open). The client dials lazily, so a
 	// missing scorer never blocks startup; a per-call timeout bounds the hot path.
@@ -80,9 +87,14 @@ func main() {
 		logger.Info("ML scorer wired", map[string]interface{}{"endpoint": scorerEndpoint, "tau": tau})
 	}
 
+	probes := health.New()
+	probes.Add("db", dbClient.Ping)
+	diagnostics.Log(context.Background(), logger, diagnostics.Startup{Service: "fraud-grpc", Config: cfg, Probes: probes, DB: dbClient, Pool: &pool})
+
 	go func() {
 		mux := http.NewServeMux()
 		mux.Handle("/metrics", promhttp.Handler())
+		probes.Register(mux)
 		if err := http.ListenAndServe(metricsAddr, mux); err != nil {
 			fmt.Fprintf(os.Stderr, "Metrics server error: %v\n", err)
 		}
@@ -102,11 +114,7 @@ func main() {
 	fraudv1.RegisterFraudEvalServer(grpcServer, srv)
 	reflection.Register(grpcServer)
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
-
-	go func() {
-		<-ctx.Done()
+	probes.OnShutdown(cfg.ShutdownDrain(), func() {
 		logger.Info("Shutdown signal received, draining gRPC server", nil)
 		done := make(chan struct{})
 		go func() {
@@ -119,7 +127,7 @@ func main() {
 			logger.Warn("GracefulStop timed out, forcing", nil)
 			grpcServer.Stop()
 		}
-	}()
+	})
 
 	logger.Info(fmt.Sprintf("fraud-grpc listening on %s (metrics %s)", grpcAddr, metricsAddr), nil)
 	if err := grpcServer.Serve(lis); err != nil {
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/eventcodec"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/retry"
+	"github.com/google/uuid"
+)
+
+type batchRequest struct {
+	BatchID string         `json:"batch_id"`
+	Atomic  bool           `json:"atomic"`
+	Partial bool           `json:"partial"`
+	Events  []domain.Event `json:"events"`
+}
+
+type batchItemResult struct {
+	EventID string `json:"event_id"`
+	Status  string `json:"status"` // "enqueued" or "rejected"
+	Error   string `json:"error,omitempty"`
+}
+
+// handleIngestBatch accepts up to domain.MaxBatchEvents events in one request.
+//
+// With "atomic": true the whole batch is enqueued as a single message and the
+// processor applies it all-or-nothing: members are validated there, together, and
+// the outcome is exposed by the query service at GET /batches/{batch_id}.
+// Otherwise each member is validated and enqueued on its own, as POST /events
+// would, and the response reports per-member results; GET /batches/{batch_id}
+// then tracks how many have been processed or failed. Atomic batches are refused
+// with 422 while the atomic_batches flag is off.
+//
+// "partial": true (with "atomic") still commits the batch in one transaction, but
+// a member that fails validation or is rejected by the database is left out and
+// reported on its own instead of failing the batch.
+func handleIngestBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	startTime := time.Now()
+
+	correlationID := r.Header.Get("X-Correlation-ID")
+	if correlationID == "" {
+		correlationID = uuid.New().String()
+	}
+	reqLogger := logging.NewLogger("ingest", correlationID)
+
+	if eventcodec.Parse(r.Header.Get("Content-Type")) != domain.ContentTypeJSON {
+		http.Error(w, `{"error":"batches must be JSON"}`, http.StatusUnsupportedMediaType)
+		return
+	}
+	var req batchRequest
+	if err := decodeBody(r, &req); err != nil {
+		reqLogger.Error("Failed to parse batch body", err, map[string]interface{}{"stage": "validate"})
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	if req.Partial && !req.Atomic {
+		http.Error(w, `{"error":"partial requires atomic"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Atomic && !flags.Enabled(featureflags.AtomicBatches) {
+		http.Error(w, `{"error":"atomic batches are disabled; resend with \"atomic\": false"}`, http.StatusUnprocessableEntity)
+		return
+	}
+	switch n := len(req.Events); {
+	case n == 0:
+		http.Error(w, `{"error":"events must not be empty"}`, http.StatusBadRequest)
+		return
+	case n > domain.MaxBatchEvents:
+		http.Error(w, fmt.Sprintf(`{"error":"at most %d events per batch"}`, domain.MaxBatchEvents), http.StatusBadRequest)
+		return
+	}
+	if rateLimited(w, r, len(req.Events), correlationID, reqLogger) {
+		return
+	}
+	req.BatchID = strings.TrimSpace(req.BatchID)
+	if req.BatchID == "" {
+		req.BatchID = uuid.New().String()
+	}
+	reqLogger = reqLogger.With(map[string]interface{}{"batch_id": req.BatchID, "atomic": req.Atomic})
+
+	for i := range req.Events {
+		req.Events[i].Normalize()
+		assignEventID(&req.Events[i])
+	}
+	if rejectSensitive(w, req.Events...) {
+		return
+	}
+
+	var resp map[string]interface{}
+	if req.Atomic {
+		// The processor validates atomic members, but the replay window is
+		// per-producer config that only ingest holds: one stale member rejects the
+		// batch here, or just itself in a partial batch, which carries it to the
+		// processor for the batch record.
+		rejected := []batchItemResult{}
+		var failures []domain.BatchFailure
+		kept := req.Events[:0]
+		for i := range req.Events {
+			if err := countStale(req.Events[i].CheckAge(time.Now(), validationConfig(producerKey(r)).MaxAge)); err != nil {
+				reqLogger.Warn("Stale event rejected", map[string]interface{}{"stage": "validate", "event_id": req.Events[i].EventID})
+				if !req.Partial {
+					http.Error(w, fmt.Sprintf(`{"error":"validation failed: event %s: %v"}`, req.Events[i].EventID, err), http.StatusBadRequest)
+					return
+				}
+				rejected = append(rejected, batchItemResult{EventID: req.Events[i].EventID, Status: "rejected", Error: err.Error()})
+				failures = append(failures, domain.BatchFailure{EventID: req.Events[i].EventID, Reason: "validation_error"})
+				continue
+			}
+			kept = append(kept, req.Events[i])
+		}
+		req.Events = kept
+		if len(req.Events) == 0 {
+			http.Error(w, `{"error":"validation failed: every event is stale"}`, http.StatusBadRequest)
+			return
+		}
+		if err := enqueueAtomicBatch(r, newRequestBudget(startTime), &req, failures, correlationID, reqLogger); err != nil {
+			writeEnqueueError(w, err, correlationID)
+			return
+		}
+		metrics.IncCounter(metricdef.EventsIngestedTotal, "service", "ingest")
+		resp = map[string]interface{}{"batch_id": req.BatchID, "atomic": true, "events": len(req.Events), "status": "enqueued"}
+		if req.Partial {
+			resp["partial"], resp["rejected"] = true, rejected
+		}
+	} else {
+		results, err := enqueueBatchMembers(r, newRequestBudget(startTime), &req, correlationID, reqLogger)
+		if err != nil {
+			writeEnqueueError(w, err, correlationID)
+			return
+		}
+		resp = map[string]interface{}{"batch_id": req.BatchID, "atomic": false, "results": results}
+		reqLogger.Info("Successfully enqueued batch members", map[string]interface{}{"stage": "enqueue", "events": len(results)})
+	}
+	metrics.ObserveHistogram(metricdef.IngestLatencySeconds, time.Since(startTime).Seconds(), "service", "ingest")
+
+	respBytes, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Correlation-ID", correlationID)
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write(respBytes)
+}
+
+// enqueueAtomicBatch publishes every member in one BatchPayload message keyed by
+// domain.BatchIdempotencyKey, so a redelivered batch is applied at most once.
+// rejected are the members of a partial batch ingest turned away.
+func enqueueAtomicBatch(r *http.Request, b *requestBudget, req *batchRequest, rejected []domain.BatchFailure, correlationID string, reqLogger *logging.Logger) error {
+	payloadBytes, err := json.Marshal(domain.BatchPayload{Events: req.Events, Rejected: rejected})
+	if err != nil {
+		reqLogger.Error("Failed to serialize batch", err, map[string]interface{}{"stage": "serialize"})
+		return err
+	}
+	msg := &domain.QueueMessage{
+		EventID:       domain.BatchIdempotencyKey(req.BatchID),
+		CorrelationID: correlationID,
+		ContentType:   domain.ContentTypeJSON,
+		ReceivedAt:    time.Now().UTC(),
+		ProducerKey:   producerKey(r),
+		Tenant:        producerTenant(r),
+		BatchID:       req.BatchID,
+		Atomic:        true,
+		Partial:       req.Partial,
+		Priority:      batchPriority(req.Events),
+	}
+	if err := publishEnvelope(r.Context(), b, msg, payloadBytes, anySensitive(req.Events), reqLogger); err != nil {
+		return err
+	}
+	reqLogger.Info("Successfully enqueued atomic batch", map[string]interface{}{
+		"stage":        "enqueue",
+		"events":       len(req.Events),
+		"payload_mode": string(msg.PayloadMode),
+	})
+	return nil
+}
+
+// batchPriority is high when every event is, so one high member can't pull a
+// bulk batch into the priority queue.
+func batchPriority(events []domain.Event) string {
+	for _, e := range events {
+		if e.Priority != domain.PriorityHigh {
+			return ""
+		}
+	}
+	return domain.PriorityHigh
+}
+
+// enqueueBatchMembers enqueues each valid member as its own message. Invalid
+// members are reported, not fatal, and don't count towards the batch's submitted
+// size (BatchSize on every member), which the processor uses to tell when the batch
+// has drained. An infrastructure error stops the batch; the members already
+// enqueued stay enqueued and resubmitting the same batch is safe, since event IDs
+// are idempotent and each member is counted once. The members share the request
+// budget b, so running out of it stops the batch the same way.
+func enqueueBatchMembers(r *http.Request, b *requestBudget, req *batchRequest, correlationID string, reqLogger *logging.Logger) ([]batchItemResult, error) {
+	results := make([]batchItemResult, len(req.Events))
+	submitted := 0
+	vc, now := validationConfig(producerKey(r)), time.Now()
+	for i := range req.Events {
+		results[i] = batchItemResult{EventID: req.Events[i].EventID, Status: "enqueued"}
+		if err := countStale(req.Events[i].ValidateWith(vc, now)); err != nil {
+			results[i].Status, results[i].Error = "rejected", err.Error()
+			continue
+		}
+		submitted++
+	}
+
+	key, tenant := producerKey(r), producerTenant(r)
+	for i := range req.Events {
+		if results[i].Status != "enqueued" {
+			continue
+		}
+		event := &req.Events[i]
+		payloadBytes, err := event.ToJSON()
+		if err != nil {
+			reqLogger.Error("Failed to serialize event", err, map[string]interface{}{"stage": "serialize", "event_id": event.EventID})
+			return nil, err
+		}
+		msg := &domain.QueueMessage{
+			EventID:       event.EventID,
+			CorrelationID: correlationID,
+			ContentType:   domain.ContentTypeJSON,
+			ReceivedAt:    time.Now().UTC(),
+			ProducerKey:   key,
+			Tenant:        tenant,
+			BatchID:       req.BatchID,
+			BatchSize:     submitted,
+			Priority:      event.Priority,
+		}
+		if err := publishEnvelope(r.Context(), b, msg, payloadBytes, event.Sensitive, reqLogger); err != nil {
+			return nil, err
+		}
+		metrics.IncCounter(metricdef.EventsIngestedTotal, "service", "ingest")
+		if event.Canary {
+			metrics.IncCounter(metricdef.CanaryEventsTotal, "service", "ingest")
+		}
+	}
+	return results, nil
+}
+
+// publishEnvelope hashes payloadBytes into msg, attaches the payload (sealed when
+// sensitive), and publishes msg to the events queue, each step within its share
+// of b. A step cut off by b returns a *budgetExceededError.
+func publishEnvelope(ctx context.Context, b *requestBudget, msg *domain.QueueMessage, payloadBytes []byte, sensitive bool, reqLogger *logging.Logger) error {
+	hash := sha256.Sum256(payloadBytes)
+	msg.PayloadSHA256 = hex.EncodeToString(hash[:])
+	storageCtx, cancel := b.step(ctx, stepStorage)
+	err := attachPayload(storageCtx, msg, payloadBytes, sensitive, reqLogger)
+	err = b.check(storageCtx, stepStorage, err)
+	cancel()
+	if err != nil {
+		return err
+	}
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		reqLogger.Error("Failed to marshal queue message", err)
+		return err
+	}
+	publishCtx, cancel := b.step(ctx, stepEnqueue)
+	defer cancel()
+	policy := retry.Policy{Attempts: cfg.IngestPublishAttempts, Base: time.Duration(cfg.IngestPublishBackoffMs) * time.Millisecond}
+	err = retry.Do(publishCtx, policy, func(ctx context.Context) error {
+		return publisher.Publish(ctx, "events", msg.RoutingKey(), msgBytes)
+	}, func(attempt int, err error) {
+		metrics.IncCounter(metricdef.IngestPublishRetriesTotal, "reason", retry.Reason(err))
+		reqLogger.Warn("Publish to RabbitMQ failed; retrying", map[string]interface{}{"stage": "enqueue", "attempt": attempt, "error": err.Error()})
+	})
+	if err != nil {
+		reqLogger.Error("Failed to publish to RabbitMQ", err, map[string]interface{}{"stage": "enqueue"})
+		return b.check(publishCtx, stepEnqueue, err)
+	}
+	return nil
+}
+
+// writeEnqueueError answers a failed store-and-publish: 504 when the request
+// budget ran out, 429 when the broker is throttling us (a flow-control or
+// resource alarm that outlasted the retries), and 500 otherwise.
+func writeEnqueueError(w http.ResponseWriter, err error, correlationID string) {
+	if writeBudgetExceeded(w, err, correlationID) {
+		return
+	}
+	if retry.Reason(err) == "queue_throttled" {
+		metrics.IncCounter(metricdef.IngestEnqueueFailuresTotal, "class", "throttled")
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, `{"error":"queue throttled, retry later","code":"queue_throttled"}`, http.StatusTooManyRequests)
+		return
+	}
+	metrics.IncCounter(metricdef.IngestEnqueueFailuresTotal, "class", "error")
+	http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+}
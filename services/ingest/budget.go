@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+// Budgeted steps of an ingest request, named like their log stages.
+const (
+	stepStorage = "persist_storage"
+	stepEnqueue = "enqueue"
+)
+
+// requestBudget bounds an ingest request to INGEST_REQUEST_BUDGET_MS, so a slow
+// MinIO or broker is answered with a 504 naming the step instead of a client or
+// load balancer timing out with nothing. The storage step (offload or archive)
+// may use INGEST_STORAGE_BUDGET_PERCENT of the time left when it starts; the
+// publish gets whatever remains. A nil *requestBudget bounds nothing.
+type requestBudget struct {
+	start    time.Time
+	deadline time.Time
+}
+
+// newRequestBudget starts the budget for a request received at start, or returns
+// nil when budgeting is disabled.
+func newRequestBudget(start time.Time) *requestBudget {
+	if cfg.IngestRequestBudgetMs <= 0 {
+		return nil
+	}
+	return &requestBudget{start: start, deadline: start.Add(time.Duration(cfg.IngestRequestBudgetMs) * time.Millisecond)}
+}
+
+// step returns ctx bounded to step's share of the budget.
+func (b *requestBudget) step(ctx context.Context, step string) (context.Context, context.CancelFunc) {
+	if b == nil {
+		return context.WithCancel(ctx)
+	}
+	deadline := b.deadline
+	if step == stepStorage {
+		share := time.Duration(float64(time.Until(b.deadline)) * cfg.IngestStorageBudgetPercent / 100)
+		deadline = time.Now().Add(share)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// check returns err, or a *budgetExceededError when it happened because stepCtx
+// ran out of budget.
+func (b *requestBudget) check(stepCtx context.Context, step string, err error) error {
+	if b == nil || err == nil || !errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	metrics.IncCounter(metricdef.IngestDeadlineExceededTotal, "step", step)
+	return &budgetExceededError{Step: step, Budget: b.deadline.Sub(b.start), Elapsed: time.Since(b.start), Err: err}
+}
+
+// budgetExceededError is a request step cut off by its budget.
+type budgetExceededError struct {
+	Step    string
+	Budget  time.Duration
+	Elapsed time.Duration
+	Err     error
+}
+
+func (e *budgetExceededError) Error() string {
+	return fmt.Sprintf("%s exceeded the request budget of %v: %v", e.Step, e.Budget, e.Err)
+}
+
+func (e *budgetExceededError) Unwrap() error { return e.Err }
+
+// writeBudgetExceeded answers 504 with the step that ran out of time when err is
+// a *budgetExceededError, and reports whether it did.
+func writeBudgetExceeded(w http.ResponseWriter, err error, correlationID string) bool {
+	var exceeded *budgetExceededError
+	if !errors.As(err, &exceeded) {
+		return false
+	}
+	respBytes, _ := json.Marshal(map[string]interface{}{
+		"error":      "deadline exceeded",
+		"code":       "deadline_exceeded",
+		"step":       exceeded.Step,
+		"budget_ms":  exceeded.Budget.Milliseconds(),
+		"elapsed_ms": exceeded.Elapsed.Milliseconds(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Correlation-ID", correlationID)
+	w.WriteHeader(http.StatusGatewayTimeout)
+	_, _ = w.Write(respBytes)
+	return true
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/logging"
+)
+
+// Original statuses a duplicate submission is answered with.
+const (
+	duplicateEnqueued   = "enqueued"   // in the dedupe window, not yet claimed by the processor
+	duplicateProcessing = "processing" // claimed and being processed
+	duplicateProcessed  = "processed"  // processed successfully
+)
+
+// lookupAccepted reports how an earlier submission of eventID stands according to
+// the idempotency table, or "" when there is none worth deduping against: no
+// key, or one that failed, which a resubmission may retry. A lookup error is
+// logged and treated as no key; the processor's own check still catches the
+// duplicate.
+func lookupAccepted(eventID string, reqLogger *logging.Logger) string {
+	if keys == nil {
+		return ""
+	}
+	record, err := keys.GetStatus(eventID)
+	if err != nil {
+		reqLogger.Warn("Duplicate lookup failed; enqueueing", map[string]interface{}{"stage": "dedupe", "error": err.Error()})
+		return ""
+	}
+	if record == nil {
+		return ""
+	}
+	switch domain.IdempotencyStatus(record.Status) {
+	case domain.IdempotencyStatusSuccess:
+		return duplicateProcessed
+	case domain.IdempotencyStatusProcessing:
+		return duplicateProcessing
+	}
+	return ""
+}
+
+// writeDuplicate answers a submission of an event already accepted, with the
+// original's status: 200 when it was processed, so the producer can stop
+// retrying, and 409 while it is still on its way.
+func writeDuplicate(w http.ResponseWriter, eventID, status, correlationID string) {
+	code := http.StatusConflict
+	if status == duplicateProcessed {
+		code = http.StatusOK
+	}
+	respBytes, _ := json.Marshal(map[string]interface{}{"event_id": eventID, "status": status, "duplicate": true})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Correlation-ID", correlationID)
+	w.WriteHeader(code)
+	_, _ = w.Write(respBytes)
+}
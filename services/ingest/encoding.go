@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+// defaultMaxDecompressedBytes applies when INGEST_MAX_DECOMPRESSED_BYTES is 0.
+const defaultMaxDecompressedBytes = 16 << 20
+
+// sentBodyKey is the context key of the body as the producer sent it.
+type sentBodyKey struct{}
+
+// sentBody returns r's body as the producer sent it, when decompressed has
+// replaced it with the inflated one. Signatures cover these bytes.
+func sentBody(r *http.Request) ([]byte, bool) {
+	body, ok := r.Context().Value(sentBodyKey{}).([]byte)
+	return body, ok
+}
+
+// decompressed lets producers send bodies with Content-Encoding: gzip. The body is
+// inflated before next runs, up to limit bytes so a small bomb can't exhaust
+// memory, and handed on as if it had been sent plain; the compressed bytes stay
+// on the request for sentBody. A body that inflates past limit (or is already
+// past it compressed) is answered 413, a corrupt one 400, and any other encoding
+// 415.
+func decompressed(limit int64, next http.HandlerFunc) http.HandlerFunc {
+	if limit <= 0 {
+		limit = defaultMaxDecompressedBytes
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+		case "", "identity":
+			next(w, r)
+			return
+		case "gzip", "x-gzip":
+		default:
+			http.Error(w, `{"error":"unsupported Content-Encoding; send gzip or none"}`, http.StatusUnsupportedMediaType)
+			return
+		}
+
+		sent, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+		if err != nil {
+			rejectEncoded(w, "invalid", `{"error":"invalid gzip body"}`, http.StatusBadRequest)
+			return
+		}
+		if int64(len(sent)) > limit {
+			rejectEncoded(w, "too_large", `{"error":"decompressed body exceeds `+strconv.FormatInt(limit, 10)+` bytes"}`, http.StatusRequestEntityTooLarge)
+			return
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(sent))
+		if err != nil {
+			rejectEncoded(w, "invalid", `{"error":"invalid gzip body"}`, http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(zr, limit+1))
+		if err == nil {
+			err = zr.Close()
+		}
+		switch {
+		case int64(len(body)) > limit:
+			rejectEncoded(w, "too_large", `{"error":"decompressed body exceeds `+strconv.FormatInt(limit, 10)+` bytes"}`, http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			rejectEncoded(w, "invalid", `{"error":"invalid gzip body"}`, http.StatusBadRequest)
+			return
+		}
+		metrics.IncCounter(metricdef.IngestCompressedBodiesTotal, "result", "ok")
+		r.Header.Del("Content-Encoding")
+		r.ContentLength = int64(len(body))
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(w, r.WithContext(context.WithValue(r.Context(), sentBodyKey{}, sent)))
+	}
+}
+
+func rejectEncoded(w http.ResponseWriter, result, body string, code int) {
+	metrics.IncCounter(metricdef.IngestCompressedBodiesTotal, "result", result)
+	http.Error(w, body, code)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/config/secrets"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/fluxa/fluxa/internal/recording"
+	"github.com/fluxa/fluxa/internal/signing"
+)
+
+// recordAll turns record_requests on.
+type recordAll struct{}
+
+func (recordAll) Load(context.Context) (map[featureflags.Flag]bool, error) {
+	return map[featureflags.Flag]bool{featureflags.RecordRequests: true}, nil
+}
+
+func TestWithMiddleware_RecordsSignedGzipBodyAsJSON(t *testing.T) {
+	setupIngest(t)
+	storage := fluxatest.NewStorage()
+	recorder := &recording.Recorder{
+		Service:       "ingest",
+		Storage:       func() (ports.Storage, error) { return storage, nil },
+		Flags:         featureflags.New(time.Minute, nil, recordAll{}),
+		SamplePercent: 100,
+		Metrics:       metrics,
+		Logger:        logger,
+	}
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(path, []byte(`{"signing_secrets":{"producer-a":"s3cret"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := secrets.Open(path, secrets.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := &requestVerifier{secrets: store, window: time.Minute}
+	handler := withMiddleware(handleIngest, verifier, recorder)
+
+	event := fluxatest.NewEvent("").User("alice").Amount(10).At(time.Now().UTC().Truncate(time.Second)).Meta("email", "a@example.com").Build()
+	plain, _ := json.Marshal(event)
+	var sent bytes.Buffer
+	zw := gzip.NewWriter(&sent)
+	_, _ = zw.Write(plain)
+	_ = zw.Close()
+
+	// The producer signs what it sends: the compressed bytes.
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(sent.Bytes()))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("X-API-Key", "producer-a")
+	req.Header.Set("X-Correlation-ID", "corr-gzip")
+	req.Header.Set(signing.HeaderTimestamp, ts)
+	req.Header.Set(signing.HeaderSignature, signing.Sign("s3cret", ts, sent.Bytes()))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+
+	var recorded *recording.Recording
+	for deadline := time.Now().Add(2 * time.Second); recorded == nil && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		recorded, _ = recording.Load(context.Background(), storage, "corr-gzip", time.Hour, time.Now())
+	}
+	if recorded == nil {
+		t.Fatal("no recording stored")
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(recorded.Request.Body, &body); err != nil {
+		t.Fatalf("recorded request body %q is not JSON: %v", recorded.Request.Body, err)
+	}
+	metadata, _ := body["metadata"].(map[string]interface{})
+	if body["user_id"] != "[redacted]" || metadata["email"] != "[redacted]" || body["amount"] != float64(10) {
+		t.Errorf("recorded body = %v, want the inflated event with user_id and metadata redacted", body)
+	}
+	if recorded.Request.BodyBytes != len(plain) {
+		t.Errorf("recorded %d body bytes, want the %d inflated", recorded.Request.BodyBytes, len(plain))
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/idempotency"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+// keyStore is the part of the idempotency table ingest uses; an
+// *idempotency.Client.
+type keyStore interface {
+	GetStatus(eventID string) (*domain.IdempotencyKeyRecord, error)
+	ReserveKey(eventID, payloadSHA256 string) (original string, err error)
+	ForgetKey(eventID string) error
+}
+
+// checkIdempotencyKey checks eventID, derived from an Idempotency-Key, against
+// the payload the key was first accepted with. A key reused for another payload
+// is answered 422 with the original event_id, and a retry of an event already
+// processed or in flight is answered as a duplicate; handled reports that w was
+// written. reserved reports that this request reserved the key, which the caller
+// gives back with forgetIdempotencyKey when the event isn't enqueued. A failed
+// lookup is logged and the event goes through; the processor's check still
+// catches a duplicate.
+func checkIdempotencyKey(w http.ResponseWriter, eventID, payloadSHA256, correlationID string, reqLogger *logging.Logger) (handled, reserved bool) {
+	if keys == nil {
+		return false, false
+	}
+	original, err := keys.ReserveKey(eventID, payloadSHA256)
+	if err != nil {
+		reqLogger.Warn("Idempotency-Key lookup failed; enqueueing", map[string]interface{}{"stage": "dedupe", "error": err.Error()})
+		return false, false
+	}
+	if original == "" {
+		return false, true
+	}
+	if original != payloadSHA256 {
+		reqLogger.Warn("Idempotency-Key reused with a different payload", map[string]interface{}{"stage": "dedupe"})
+		metrics.IncCounter(metricdef.IngestDuplicatesTotal)
+		respBytes, _ := json.Marshal(map[string]interface{}{
+			"error":    "Idempotency-Key was already used with a different payload",
+			"code":     "idempotency_key_reused",
+			"event_id": eventID,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Correlation-ID", correlationID)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write(respBytes)
+		return true, false
+	}
+	// A retry of the same payload: answer it from the processor's record, or
+	// enqueue it again when there is none yet, or it failed.
+	if status := lookupAccepted(eventID, reqLogger); status != "" {
+		reqLogger.Warn("Duplicate submission rejected", map[string]interface{}{"stage": "dedupe", "original_status": status})
+		metrics.IncCounter(metricdef.IngestDuplicatesTotal)
+		writeDuplicate(w, eventID, status, correlationID)
+		return true, false
+	}
+	return false, false
+}
+
+// forgetIdempotencyKey gives back the reservation of eventID so the producer's
+// retry of an event that wasn't enqueued is accepted.
+func forgetIdempotencyKey(eventID string, reqLogger *logging.Logger) {
+	if err := keys.ForgetKey(eventID); err != nil {
+		reqLogger.Warn("Failed to release Idempotency-Key", map[string]interface{}{"stage": "dedupe", "error": err.Error()})
+	}
+}
+
+// Expired Idempotency-Key reservations are purged every keyPurgeInterval,
+// keyPurgeBatch rows per statement.
+const (
+	keyPurgeInterval = time.Hour
+	keyPurgeBatch    = 10000
+)
+
+// purgeIdempotencyKeys deletes expired reservations every keyPurgeInterval, for
+// as long as ingest runs. Every replica purges; a row deleted twice is harmless.
+func purgeIdempotencyKeys(client *idempotency.Client) {
+	for range time.Tick(keyPurgeInterval) {
+		var purged int64
+		for {
+			n, err := client.PurgeKeys(keyPurgeBatch)
+			purged += n
+			if err != nil {
+				logger.Warn("Failed to purge expired Idempotency-Keys", map[string]interface{}{"error": err.Error()})
+				break
+			}
+			if n < keyPurgeBatch {
+				break
+			}
+		}
+		if purged > 0 {
+			logger.Info("Purged expired Idempotency-Keys", map[string]interface{}{"purged": purged})
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/config"
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/fluxatest"
+	"github.com/fluxa/fluxa/internal/logging"
+)
+
+// memKeys is an in-memory keyStore.
+type memKeys struct {
+	mu       sync.Mutex
+	payloads map[string]string
+	records  map[string]*domain.IdempotencyKeyRecord
+}
+
+func (k *memKeys) GetStatus(eventID string) (*domain.IdempotencyKeyRecord, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.records[eventID], nil
+}
+
+func (k *memKeys) ReserveKey(eventID, payloadSHA256 string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if original, ok := k.payloads[eventID]; ok {
+		return original, nil
+	}
+	k.payloads[eventID] = payloadSHA256
+	return "", nil
+}
+
+func (k *memKeys) ForgetKey(eventID string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.payloads, eventID)
+	return nil
+}
+
+// setupIngest points the ingest globals at fakes and returns the queue and key
+// store.
+func setupIngest(t *testing.T) (*fluxatest.Queue, *memKeys) {
+	t.Helper()
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_USER", "testuser")
+	t.Setenv("DB_PASSWORD", "testpass")
+	loaded, err := config.LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv: %v", err)
+	}
+	queue := fluxatest.NewQueue()
+	store := &memKeys{payloads: map[string]string{}, records: map[string]*domain.IdempotencyKeyRecord{}}
+	cfg, publisher, metrics, logger, keys = loaded, queue, fluxatest.NewMetrics(), logging.NewLogger("ingest", "test"), store
+	submissions = nil
+	t.Cleanup(func() { keys = nil })
+	return queue, store
+}
+
+// postKeyed posts amount under the Idempotency-Key key.
+func postKeyed(t *testing.T, key string, amount float64) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	ts := time.Now().UTC().Truncate(time.Second)
+	body, _ := json.Marshal(fluxatest.NewEvent("").Amount(amount).At(ts).Build())
+	req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "producer-a")
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	handleIngest(rec, req)
+	var resp map[string]interface{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp
+}
+
+func TestIngest_IdempotencyKeySamePayload(t *testing.T) {
+	queue, store := setupIngest(t)
+
+	first, resp := postKeyed(t, "order-1", 10)
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("first: status %d, body %s", first.Code, first.Body)
+	}
+	eventID := resp["event_id"].(string)
+
+	// The processor has the event: a retry is answered with its status.
+	store.records[eventID] = &domain.IdempotencyKeyRecord{EventID: eventID, Status: string(domain.IdempotencyStatusSuccess)}
+	retry, resp := postKeyed(t, "order-1", 10)
+	if retry.Code != http.StatusOK {
+		t.Fatalf("retry: status %d, body %s", retry.Code, retry.Body)
+	}
+	if resp["event_id"] != eventID || resp["duplicate"] != true {
+		t.Errorf("retry: body %v, want duplicate of %s", resp, eventID)
+	}
+	if n := len(queue.Published("events")); n != 1 {
+		t.Errorf("published %d messages, want 1", n)
+	}
+}
+
+func TestIngest_IdempotencyKeyReusedForOtherPayload(t *testing.T) {
+	queue, _ := setupIngest(t)
+
+	first, resp := postKeyed(t, "order-1", 10)
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("first: status %d, body %s", first.Code, first.Body)
+	}
+	eventID := resp["event_id"].(string)
+
+	reused, resp := postKeyed(t, "order-1", 99)
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused: status %d, body %s", reused.Code, reused.Body)
+	}
+	if resp["event_id"] != eventID || resp["code"] != "idempotency_key_reused" {
+		t.Errorf("reused: body %v, want idempotency_key_reused for %s", resp, eventID)
+	}
+	if reused.Header().Get("X-Correlation-ID") == "" {
+		t.Error("reused: no X-Correlation-ID")
+	}
+	if n := len(queue.Published("events")); n != 1 {
+		t.Errorf("published %d messages, want 1", n)
+	}
+}
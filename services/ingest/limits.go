@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// limits is the GET /limits body: the limits ingest and the processor apply to
+// an event right now, dynamic config and flags included. Zero means unlimited
+// where a limit can be disabled (max_payload_bytes, max_event_age_hours,
+// rate_limit_per_second).
+type limits struct {
+	MaxPayloadBytes         int64   `json:"max_payload_bytes"`
+	MaxDecompressedBytes    int64   `json:"max_decompressed_bytes"`
+	InlinePayloadBytes      int     `json:"inline_payload_bytes"`
+	MaxBatchEvents          int     `json:"max_batch_events"`
+	MetadataMaxKeys         int     `json:"metadata_max_keys"`
+	MetadataMaxDepth        int     `json:"metadata_max_depth"`
+	MetadataMaxBytes        int     `json:"metadata_max_bytes"`
+	MetadataKeysOnly        bool    `json:"metadata_keys_only"`
+	MaxFutureDriftSeconds   int     `json:"max_future_drift_seconds"`
+	MaxEventAgeHours        int     `json:"max_event_age_hours"`
+	ClientReferenceMaxBytes int     `json:"client_reference_max_bytes"`
+	GroupIDMaxBytes         int     `json:"group_id_max_bytes"`
+	GroupMaxSize            int     `json:"group_max_size"`
+	SchemaVersionMaxBytes   int     `json:"schema_version_max_bytes"`
+	IdempotencyKeyMaxBytes  int     `json:"idempotency_key_max_bytes"`
+	DedupeWindowSeconds     int     `json:"dedupe_window_seconds"`
+	RequestBudgetMs         int     `json:"request_budget_ms"`
+	RateLimitPerSecond      float64 `json:"rate_limit_per_second"`
+	RateLimitBurst          int     `json:"rate_limit_burst"`
+}
+
+// handleLimits serves GET /limits: the effective limits for the calling producer
+// (X-API-Key, for its replay window), so clients can validate against the live
+// configuration. Metadata limits left at zero are reported as the defaults that
+// apply.
+func handleLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	vc := validationConfig(producerKey(r))
+	resp := limits{
+		MaxPayloadBytes:         cfg.ProcessorMaxPayloadBytes,
+		MaxDecompressedBytes:    cfg.IngestMaxDecompressedBytes,
+		InlinePayloadBytes:      domain.MaxInlinePayloadBytes,
+		MaxBatchEvents:          domain.MaxBatchEvents,
+		MetadataMaxKeys:         orDefault(vc.MetadataMaxKeys, domain.DefaultMetadataMaxKeys),
+		MetadataMaxDepth:        orDefault(vc.MetadataMaxDepth, domain.DefaultMetadataMaxDepth),
+		MetadataMaxBytes:        orDefault(vc.MetadataMaxBytes, domain.DefaultMetadataMaxBytes),
+		MetadataKeysOnly:        vc.MetadataKeysOnly,
+		MaxFutureDriftSeconds:   int(domain.DefaultMaxFutureDrift.Seconds()),
+		MaxEventAgeHours:        int(vc.MaxAge.Hours()),
+		ClientReferenceMaxBytes: domain.MaxClientReferenceLen,
+		GroupIDMaxBytes:         domain.MaxGroupIDLen,
+		GroupMaxSize:            domain.MaxGroupSize,
+		SchemaVersionMaxBytes:   domain.MaxSchemaVersionLen,
+		IdempotencyKeyMaxBytes:  domain.MaxIdempotencyKeyLen,
+		DedupeWindowSeconds:     cfg.IngestDedupeWindowSeconds,
+		RequestBudgetMs:         cfg.IngestRequestBudgetMs,
+	}
+	resp.RateLimitPerSecond, resp.RateLimitBurst = limiter.Limit(producerKey(r))
+	if resp.MaxDecompressedBytes <= 0 {
+		resp.MaxDecompressedBytes = defaultMaxDecompressedBytes
+	}
+	if vc.MaxFutureDrift > 0 {
+		resp.MaxFutureDriftSeconds = int(vc.MaxFutureDrift.Seconds())
+	}
+	respBytes, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	// Limits change with dynamic config; let clients cache them briefly.
+	w.Header().Set("Cache-Control", "max-age=60")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(respBytes)
+}
+
+func orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
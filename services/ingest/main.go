@@ -1,34 +1,87 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
-	minioadapter "github.com/fluxa/fluxa/internal/adapters/minio"
-	"github.com/fluxa/fluxa/internal/adapters/rabbitmq"
+	"github.com/fluxa/fluxa/internal/accesslog"
+	"github.com/fluxa/fluxa/internal/clients"
 	"github.com/fluxa/fluxa/internal/config"
+	"github.com/fluxa/fluxa/internal/config/secrets"
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/diagnostics"
 	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/dynconfig"
+	"github.com/fluxa/fluxa/internal/eventcodec"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/health"
+	"github.com/fluxa/fluxa/internal/idempotency"
 	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/notify"
+	"github.com/fluxa/fluxa/internal/payloadcrypt"
 	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/fluxa/fluxa/internal/ratelimit"
+	"github.com/fluxa/fluxa/internal/recording"
+	"github.com/fluxa/fluxa/internal/schema"
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	prommetrics "github.com/fluxa/fluxa/internal/adapters/prometheus"
 )
 
-const maxInlineBytes = 256 * 1024 // 256 KB
-
 var (
 	cfg       *config.Config
+	factory   *clients.Factory
 	publisher ports.Publisher
-	storage   ports.Storage
 	metrics   ports.Metrics
 	logger    *logging.Logger
+	flags     *featureflags.Flags
+	tunables  *dynconfig.Store
+
+	// submissions is the front-door dedupe window (nil when disabled): event_id
+	// plus payload hash of each POST /events enqueued in the last few seconds.
+	submissions *notify.Deduper
+
+	// keys is the processor's idempotency table: ingest reserves Idempotency-Key
+	// payloads in it, and with INGEST_DUPLICATE_LOOKUP also reads it to answer
+	// resubmissions of processed events.
+	keys keyStore
+
+	// payloadKeys seals the inline payloads of sensitive events (nil unless
+	// PAYLOAD_ENCRYPTION_KEYS_FILE is set, which refuses them).
+	payloadKeys *payloadcrypt.Keyring
+
+	// limiter holds each producer to its INGEST_RATE_LIMIT_* rate (nil when none
+	// is limited).
+	limiter *ratelimit.Limiter
+
+	// schemas validates events against the base and event type JSON Schemas
+	// (EVENT_SCHEMA_TYPES).
+	schemas *schema.Registry
+
+	// tenants labels the per-tenant usage metrics (nil unless METRICS_TENANTS is
+	// set).
+	tenants *metricdef.Tenants
+
+	// bundle is the shared secret bundle (nil unless SECRETS_FILE is set): the
+	// signing secrets requests are verified with and the tenants of API keys.
+	bundle *secrets.Store
+
+	// storage is connected lazily by getStorage: most payloads stay inline, so
+	// ingest shouldn't block startup on MinIO.
+	storageMu sync.Mutex
+	storage   ports.Storage
 )
 
 func main() {
@@ -41,19 +94,45 @@ func main() {
 
 	logger = logging.NewLogger("ingest", "init")
 
-	publisher, err = rabbitmq.NewClient(cfg.RabbitMQURL)
+	factory = clients.New(cfg, "ingest")
+	flags = factory.Flags(logger)
+	mqClient, err := factory.Queue()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to connect to RabbitMQ: %v\n", err)
 		os.Exit(1)
 	}
+	publisher = mqClient
 
-	storage, err = minioadapter.NewClient(cfg.MinioEndpoint, cfg.MinioAccessKey, cfg.MinioSecretKey, cfg.MinioBucket, cfg.MinioUseSSL)
+	metrics = prommetrics.NewMetrics("ingest")
+	tunables = factory.Tunables(context.Background(), metrics, logger)
+	limiter = newLimiter(cfg)
+	tenants = metricdef.NewTenants(cfg.MetricsTenants)
+	if cfg.IngestDedupeWindowSeconds > 0 {
+		submissions = notify.NewDeduper(cfg.IngestDedupeMaxEntries, time.Duration(cfg.IngestDedupeWindowSeconds)*time.Second)
+	}
+	// Lazy, like query: a slow Postgres shouldn't hold up ingest, and lookups
+	// that fail fall through to the processor's check.
+	dbClient, err := db.Connect(cfg.DSN(), db.PoolFor(cfg.DBPoolStrategy, cfg.DBPoolIdleTimeout()), true)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to MinIO: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to create database client: %v\n", err)
 		os.Exit(1)
 	}
-
-	metrics = prommetrics.NewMetrics("ingest")
+	defer dbClient.Close()
+	keyClient := idempotency.NewClient(dbClient.GetDB()).WithKeyTTL(time.Duration(cfg.IdempotencyKeyTTLHours) * time.Hour)
+	keys = keyClient
+	if cfg.IdempotencyKeyTTLHours > 0 {
+		go purgeIdempotencyKeys(keyClient)
+	}
+	if schemas, err = factory.Schemas(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load event schemas: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.PayloadEncryptionKeysFile != "" {
+		if payloadKeys, err = payloadcrypt.Load(cfg.PayloadEncryptionKeysFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load payload encryption keys: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	// Prometheus metrics endpoint
 	go func() {
@@ -63,15 +142,78 @@ func main() {
 		}
 	}()
 
+	var verifier *requestVerifier
+	if bundle, err = factory.Secrets(logger); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load secret bundle: %v\n", err)
+		os.Exit(1)
+	}
+	if bundle != nil {
+		verifier = &requestVerifier{
+			secrets: bundle,
+			window:  time.Duration(cfg.IngestSignatureWindowSeconds) * time.Second,
+			require: cfg.IngestRequireSignature,
+		}
+		logger.Info("Request signing enabled", map[string]interface{}{
+			"keys":    bundle.SigningKeys(),
+			"require": verifier.require,
+		})
+	}
+
+	recorder := &recording.Recorder{
+		Service:       "ingest",
+		Storage:       getStorage,
+		Flags:         flags,
+		SamplePercent: cfg.RecordingSamplePercent,
+		Producers:     map[string]bool{},
+		RetentionDays: cfg.RecordingRetentionDays,
+		Metrics:       metrics,
+		Logger:        logger,
+	}
+	for _, key := range cfg.RecordingProducers {
+		recorder.Producers[key] = true
+	}
+	ingestHandler := withMiddleware(handleIngest, verifier, recorder)
+	batchHandler := withMiddleware(handleIngestBatch, verifier, recorder)
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/events", handleIngest)
+	mux.HandleFunc("/events", ingestHandler)
+	mux.HandleFunc("/events/batch", batchHandler)
+	mux.HandleFunc("/limits", handleLimits)
 	mux.HandleFunc("/health", handleHealth)
 
+	// MinIO is left out of readiness: it is connected lazily, for large payloads only.
+	probes := health.New()
+	probes.Add("queue", mqClient.Ping)
+	probes.Register(mux)
+	diagnostics.Log(context.Background(), logger, diagnostics.Startup{Service: "ingest", Config: cfg, Flags: flags, Probes: probes})
+
 	logger.Info("Ingest service starting", map[string]interface{}{"port": 8080})
-	if err := http.ListenAndServe(":8080", mux); err != nil {
+	var handler http.Handler = mux
+	if cfg.AccessLog {
+		handler = accesslog.Wrap("ingest", mux, mux)
+	}
+	srv := &http.Server{Addr: ":8080", Handler: handler}
+	if err := probes.ListenAndServe(srv, cfg.ShutdownDrain(), 10*time.Second); err != nil {
 		fmt.Fprintf(os.Stderr, "HTTP server error: %v\n", err)
 		os.Exit(1)
 	}
+	logger.Info("Ingest service stopped", nil)
+}
+
+// getStorage returns the MinIO client, connecting on first use. A failed connect
+// is not cached, so the next large payload retries instead of failing forever.
+func getStorage() (ports.Storage, error) {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	if storage != nil {
+		return storage, nil
+	}
+	client, err := factory.Storage()
+	if err != nil {
+		return nil, err
+	}
+	storage = client
+	return storage, nil
 }
 
 func handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -80,6 +222,22 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(`{"status":"ok"}`))
 }
 
+// assignEventID gives e an ID if the producer sent none: derived from its content
+// when INGEST_DERIVE_EVENT_IDS is on, so a resubmission gets the same one, else a
+// random UUID. It reports whether the ID can repeat, i.e. is not random.
+func assignEventID(e *domain.Event) bool {
+	switch {
+	case e.EventID != "":
+		return true
+	case cfg.IngestDeriveEventIDs:
+		e.EventID = e.DerivedID()
+		return true
+	default:
+		e.EventID = uuid.New().String()
+		return false
+	}
+}
+
 func handleIngest(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
@@ -94,79 +252,125 @@ func handleIngest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	reqLogger := logging.NewLogger("ingest", correlationID)
+	if rateLimited(w, r, 1, correlationID, reqLogger) {
+		return
+	}
 
-	var event domain.Event
-	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
-		reqLogger.Error("Failed to parse request body", err, map[string]interface{}{"stage": "validate"})
-		metrics.IncCounter("events_ingested_total", "service", "ingest")
-		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+	contentType := eventcodec.Parse(r.Header.Get("Content-Type"))
+	event, raw, err := readEvent(r, contentType)
+	if err != nil {
+		reqLogger.Error("Failed to parse request body", err, map[string]interface{}{"stage": "validate", "content_type": contentType})
+		metrics.IncCounter(metricdef.EventsIngestedTotal, "service", "ingest")
+		http.Error(w, fmt.Sprintf(`{"error":"invalid %s: %v"}`, bodyKind(contentType), err), http.StatusBadRequest)
 		return
 	}
 
-	if event.EventID == "" {
-		event.EventID = uuid.New().String()
+	event.Normalize()
+	idemKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(idemKey) > domain.MaxIdempotencyKeyLen {
+		reqLogger.Warn("Idempotency-Key too long", map[string]interface{}{"stage": "validate", "key_bytes": len(idemKey)})
+		w.Header().Set("X-Correlation-ID", correlationID)
+		http.Error(w, fmt.Sprintf(`{"error":"Idempotency-Key longer than %d bytes"}`, domain.MaxIdempotencyKeyLen), http.StatusBadRequest)
+		return
+	}
+	// An event_id in the body is already the idempotency key and wins.
+	keyed := idemKey != "" && event.EventID == ""
+	if keyed {
+		event.EventID = domain.IdempotencyKeyEventID(producerKey(r), idemKey)
 	}
+	// Only producer-chosen and derived IDs can repeat; a random one never needs the window.
+	producerID := assignEventID(&event)
 	reqLogger = reqLogger.With(map[string]interface{}{"event_id": event.EventID})
+	if event.Canary {
+		reqLogger = reqLogger.With(map[string]interface{}{"canary": true})
+	}
 
-	if err := event.Validate(); err != nil {
+	if err := countStale(event.ValidateWith(validationConfig(producerKey(r)), time.Now())); err != nil {
 		reqLogger.Error("Event validation failed", err, map[string]interface{}{"stage": "validate"})
 		http.Error(w, fmt.Sprintf(`{"error":"validation failed: %v"}`, err), http.StatusBadRequest)
 		return
 	}
-
-	payloadBytes, err := event.ToJSON()
-	if err != nil {
-		reqLogger.Error("Failed to serialize event", err, map[string]interface{}{"stage": "serialize"})
-		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+	if rejectSensitive(w, event) {
 		return
 	}
 
+	// JSON events are forwarded normalized; other formats as posted, for the
+	// processor to decode by content type.
+	payloadBytes := raw
+	if contentType == domain.ContentTypeJSON {
+		if payloadBytes, err = event.ToJSON(); err != nil {
+			reqLogger.Error("Failed to serialize event", err, map[string]interface{}{"stage": "serialize"})
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
 	hash := sha256.Sum256(payloadBytes)
 	payloadSHA256 := hex.EncodeToString(hash[:])
 
-	msg := &domain.QueueMessage{
-		EventID:       event.EventID,
-		CorrelationID: correlationID,
-		PayloadSHA256: payloadSHA256,
-		ReceivedAt:    event.Timestamp,
+	// release undoes the dedupe claim when the event is not enqueued after all, so
+	// the producer's retry gets through.
+	var releases []func()
+	release := func() {
+		for _, fn := range releases {
+			fn()
+		}
 	}
-
-	if len(payloadBytes) > maxInlineBytes {
-		key := fmt.Sprintf("raw/%s/%s.json", time.Now().UTC().Format("2006-01-02"), event.EventID)
-		if err := storage.Put(r.Context(), key, payloadBytes); err != nil {
-			reqLogger.Error("Failed to store payload in MinIO", err, map[string]interface{}{"stage": "persist_storage"})
-			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+	if keyed {
+		handled, reserved := checkIdempotencyKey(w, event.EventID, payloadSHA256, correlationID, reqLogger)
+		if handled {
+			return
+		}
+		if reserved {
+			eventID := event.EventID
+			releases = append(releases, func() { forgetIdempotencyKey(eventID, reqLogger) })
+		}
+	} else if producerID && cfg.IngestDuplicateLookup {
+		if status := lookupAccepted(event.EventID, reqLogger); status != "" {
+			reqLogger.Warn("Duplicate submission rejected", map[string]interface{}{"stage": "dedupe", "original_status": status})
+			metrics.IncCounter(metricdef.IngestDuplicatesTotal)
+			writeDuplicate(w, event.EventID, status, correlationID)
 			return
 		}
-		msg.PayloadMode = domain.PayloadModeS3
-		msg.S3Key = &key
-		reqLogger.Info("Stored payload in object store", map[string]interface{}{"stage": "persist_storage", "key": key})
-	} else {
-		payloadStr := string(payloadBytes)
-		msg.PayloadMode = domain.PayloadModeInline
-		msg.PayloadInline = &payloadStr
+	}
+	if producerID && submissions != nil {
+		dedupKey := event.EventID + ":" + payloadSHA256
+		if submissions.Seen(dedupKey) {
+			reqLogger.Warn("Duplicate submission rejected", map[string]interface{}{"stage": "dedupe", "original_status": duplicateEnqueued})
+			metrics.IncCounter(metricdef.IngestDuplicatesTotal)
+			writeDuplicate(w, event.EventID, duplicateEnqueued, correlationID)
+			return
+		}
+		releases = append(releases, func() { submissions.Forget(dedupKey) })
 	}
 
-	msgBytes, err := json.Marshal(msg)
-	if err != nil {
-		reqLogger.Error("Failed to marshal queue message", err)
-		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
-		return
+	msg := &domain.QueueMessage{
+		EventID:       event.EventID,
+		CorrelationID: correlationID,
+		PayloadSHA256: payloadSHA256,
+		ContentType:   contentType,
+		ReceivedAt:    time.Now().UTC(),
+		Priority:      event.Priority,
 	}
+	msg.ProducerKey, msg.Tenant = producerKey(r), producerTenant(r)
 
-	if err := publisher.Publish(r.Context(), "events", "events", msgBytes); err != nil {
-		reqLogger.Error("Failed to publish to RabbitMQ", err, map[string]interface{}{"stage": "enqueue"})
-		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+	if err := publishEnvelope(r.Context(), newRequestBudget(startTime), msg, payloadBytes, event.Sensitive, reqLogger); err != nil {
+		release()
+		writeEnqueueError(w, err, correlationID)
 		return
 	}
 
 	latency := time.Since(startTime).Seconds()
-	metrics.IncCounter("events_ingested_total", "service", "ingest")
-	metrics.ObserveHistogram("ingest_latency_seconds", latency, "service", "ingest")
+	metrics.IncCounter(metricdef.EventsIngestedTotal, "service", "ingest")
+	metrics.ObserveHistogram(metricdef.IngestLatencySeconds, latency, "service", "ingest")
+	if event.Canary {
+		metrics.IncCounter(metricdef.CanaryEventsTotal, "service", "ingest")
+	}
 
 	reqLogger.Info("Successfully enqueued event", map[string]interface{}{
 		"stage":        "enqueue",
 		"payload_mode": string(msg.PayloadMode),
+		"routing_key":  msg.RoutingKey(),
 		"latency_ms":   latency * 1000,
 	})
 
@@ -177,3 +381,204 @@ func handleIngest(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 	_, _ = w.Write(respBytes)
 }
+
+// producerKey returns the hashed X-API-Key of the request, or "" without one. Only
+// the hash travels: it keys the producer's ack webhook (query PUT /webhooks).
+func producerKey(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return domain.HashAPIKey(key)
+	}
+	return ""
+}
+
+// producerTenant returns the tenant of the request's X-API-Key from the secret
+// bundle's api_keys, or "" when it has none.
+func producerTenant(r *http.Request) string {
+	tenant, _ := bundle.Tenant(strings.TrimSpace(r.Header.Get("X-API-Key")))
+	return tenant
+}
+
+// validationConfig returns the validation tolerances for a producer (hashed API
+// key, see producerKey): the shared ones, with the replay window from its
+// INGEST_MAX_EVENT_AGE_OVERRIDES entry, else the dynamic or env
+// INGEST_MAX_EVENT_AGE_HOURS.
+func validationConfig(key string) domain.ValidationConfig {
+	hours := tunables.MaxEventAgeHours(cfg.IngestMaxEventAgeHours)
+	if h, ok := cfg.IngestMaxEventAgeOverrides[key]; ok && key != "" {
+		hours = h
+	}
+	vc := flags.Validation(tunables.Validation(cfg.EventValidation()))
+	vc.MaxAge = time.Duration(hours) * time.Hour
+	if schemas != nil {
+		vc.Schemas = schemas
+	}
+	return vc
+}
+
+// readEvent decodes a POST /events body of contentType (see eventcodec.Parse).
+// For formats other than JSON it also returns the body as posted.
+func readEvent(r *http.Request, contentType string) (event domain.Event, raw []byte, err error) {
+	if contentType == domain.ContentTypeJSON {
+		return event, nil, decodeBody(r, &event)
+	}
+	if raw, err = io.ReadAll(r.Body); err != nil {
+		return event, nil, err
+	}
+	event, err = eventcodec.Decode(contentType, raw)
+	return event, raw, err
+}
+
+// bodyKind names a request body of contentType in error messages.
+func bodyKind(contentType string) string {
+	switch contentType {
+	case domain.ContentTypeProtobuf:
+		return "protobuf"
+	case domain.ContentTypeCloudEvents:
+		return "CloudEvent"
+	}
+	return "JSON"
+}
+
+// withMiddleware wraps an ingest handler, outermost first: decompressed, so the
+// recorder sees a gzip body inflated, as JSON; the recorder, so requests rejected
+// by the signature check are recorded too; and the signature check, if verifier
+// is set, which verifies the body as the producer sent it (sentBody).
+func withMiddleware(h http.HandlerFunc, verifier *requestVerifier, recorder *recording.Recorder) http.HandlerFunc {
+	if verifier != nil {
+		h = verifier.wrap(h)
+	}
+	return decompressed(cfg.IngestMaxDecompressedBytes, recorder.Wrap(h))
+}
+
+// decodeBody decodes a JSON request body into v. With the strict_json flag on,
+// fields v doesn't declare are rejected instead of silently dropped.
+func decodeBody(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	if flags.Enabled(featureflags.StrictJSON) {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// countStale counts err in stale_events_rejected_total if it rejects an event as
+// older than the replay window, and returns it.
+func countStale(err error) error {
+	var invalid domain.ErrInvalidEvent
+	if errors.As(err, &invalid) && invalid.Code == domain.ErrCodeEventTooOld {
+		metrics.IncCounter(metricdef.StaleEventsRejectedTotal)
+	}
+	return err
+}
+
+// attachPayload puts payload on msg: inline when small (up to the dynamic
+// inline_payload_max_bytes, else domain.MaxInlinePayloadBytes) and the marshaled
+// message fits domain.MaxQueueMessageBytes, otherwise offloaded to MinIO and
+// referenced by key. msg.PayloadSHA256 and the other envelope fields must already
+// be set. Errors are logged here; callers only map them to a response.
+func attachPayload(ctx context.Context, msg *domain.QueueMessage, payloadBytes []byte, sensitive bool, reqLogger *logging.Logger) error {
+	if len(payloadBytes) <= tunables.InlinePayloadMaxBytes() {
+		if sensitive {
+			if err := sealInline(msg, payloadBytes); err != nil {
+				reqLogger.Error("Failed to seal payload", err, map[string]interface{}{"stage": "serialize"})
+				return err
+			}
+		} else {
+			msg.SetInline(payloadBytes)
+			if domain.SampledForArchive(msg.PayloadSHA256, cfg.PayloadArchiveSamplePercent) {
+				archivePayload(ctx, msg, payloadBytes, reqLogger)
+			}
+		}
+		size, err := encodedSize(msg)
+		if err != nil {
+			reqLogger.Error("Failed to marshal queue message", err)
+			return err
+		}
+		if size <= domain.MaxQueueMessageBytes {
+			return nil
+		}
+		metrics.IncCounter(metricdef.InlineOverflowTotal)
+		reqLogger.Info("Queue message over the size limit; offloading payload", map[string]interface{}{
+			"stage":         "persist_storage",
+			"payload_bytes": len(payloadBytes),
+			"message_bytes": size,
+		})
+		msg.PayloadInline, msg.Encryption = nil, nil
+		if msg.S3Key != nil {
+			// Already archived: the stored copy is the payload.
+			msg.PayloadMode = domain.PayloadModeS3
+			return nil
+		}
+	}
+	store, err := getStorage()
+	if err != nil {
+		reqLogger.Error("Failed to connect to MinIO", err, map[string]interface{}{"stage": "persist_storage"})
+		return err
+	}
+	key, deduped, err := offloadPayload(ctx, store, msg, payloadBytes)
+	if err != nil {
+		reqLogger.Error("Failed to store payload in MinIO", err, map[string]interface{}{"stage": "persist_storage"})
+		return err
+	}
+	msg.PayloadMode = domain.PayloadModeS3
+	msg.S3Key = &key
+	msg.ArchivePending = false
+	reqLogger.Info("Stored payload in object store", map[string]interface{}{
+		"stage":   "persist_storage",
+		"key":     key,
+		"deduped": deduped,
+	})
+	return nil
+}
+
+// encodedSize is the size of msg as published.
+func encodedSize(msg *domain.QueueMessage) (int, error) {
+	b, err := json.Marshal(msg)
+	return len(b), err
+}
+
+// archivePayload also stores a sampled inline payload under its content-addressed
+// key and references it from msg, so the event row points at the raw payload and
+// counts in payload_refs like an offloaded one. On failure the event goes ahead
+// inline with ArchivePending set, and the processor queues the archival for a
+// retry once the event is persisted (internal/archiveretry).
+func archivePayload(ctx context.Context, msg *domain.QueueMessage, payloadBytes []byte, reqLogger *logging.Logger) {
+	store, err := getStorage()
+	if err == nil {
+		var key string
+		if key, _, err = offloadPayload(ctx, store, msg, payloadBytes); err == nil {
+			msg.S3Key = &key
+			metrics.IncCounter(metricdef.PayloadsArchivedTotal, "result", "archived")
+			return
+		}
+	}
+	msg.ArchivePending = true
+	metrics.IncCounter(metricdef.PayloadsArchivedTotal, "result", "failed")
+	reqLogger.Warn("Failed to archive sampled payload; queuing it for retry after persistence", map[string]interface{}{
+		"stage": "archive",
+		"error": err.Error(),
+	})
+}
+
+// offloadPayload stores payload under its content-addressed key, in the producer's
+// placement (PAYLOAD_STORAGE_OVERRIDES) if it has one. The HEAD before PUT means a
+// retried batch re-submitting the same large payload reuses the existing object
+// instead of uploading it again.
+func offloadPayload(ctx context.Context, store ports.Storage, msg *domain.QueueMessage, payload []byte) (key string, deduped bool, err error) {
+	key = cfg.PayloadPlacements[msg.ProducerKey].Key(msg.PayloadSHA256)
+	exists, err := store.Exists(ctx, key)
+	if err != nil {
+		return "", false, err
+	}
+	if exists {
+		metrics.IncCounter(metricdef.PayloadDedupTotal, "result", "hit")
+		return key, true, nil
+	}
+	if err := store.Put(ctx, key, payload); err != nil {
+		return "", false, err
+	}
+	metrics.IncCounter(metricdef.PayloadDedupTotal, "result", "miss")
+	if tenant, ok := tenants.Label(msg.Tenant); ok {
+		metrics.IncCounter(metricdef.TenantObjectsStoredTotal, "tenant", tenant)
+	}
+	return key, false, nil
+}
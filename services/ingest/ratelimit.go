@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/fluxa/fluxa/internal/config"
+	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/ratelimit"
+)
+
+// newLimiter builds the producer rate limiter from INGEST_RATE_LIMIT_*; nil when
+// no producer is limited.
+func newLimiter(cfg *config.Config) *ratelimit.Limiter {
+	overrides := make(map[string]float64, len(cfg.IngestRateLimitOverrides))
+	for key, rate := range cfg.IngestRateLimitOverrides {
+		overrides[key] = float64(rate)
+	}
+	return ratelimit.New(ratelimit.Options{
+		PerSecond: cfg.IngestRateLimitPerSecond,
+		Burst:     cfg.IngestRateLimitBurst,
+		Overrides: overrides,
+	})
+}
+
+// rateLimited takes events tokens from the calling producer's bucket (keyed by
+// hashed X-API-Key; requests without one share a bucket) and, when they are not
+// there, answers 429 with Retry-After and reports true.
+func rateLimited(w http.ResponseWriter, r *http.Request, events int, correlationID string, reqLogger *logging.Logger) bool {
+	ok, retryAfter := limiter.Take(producerKey(r), events)
+	if ok {
+		return false
+	}
+	metrics.IncCounter(metricdef.IngestRateLimitedTotal)
+	reqLogger.Warn("Producer over its rate limit", map[string]interface{}{"stage": "validate", "events": events, "retry_after_ms": retryAfter.Milliseconds()})
+	respBytes, _ := json.Marshal(map[string]interface{}{
+		"error":          "rate limit exceeded",
+		"code":           "rate_limited",
+		"retry_after_ms": retryAfter.Milliseconds(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Correlation-ID", correlationID)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+	_, _ = w.Write(respBytes)
+	return true
+}
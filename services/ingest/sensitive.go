@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// rejectSensitive answers 422 when any of events is sensitive but ingest has no
+// keys to seal it with, rather than queue its payload in plaintext. It reports
+// whether it wrote a response.
+func rejectSensitive(w http.ResponseWriter, events ...domain.Event) bool {
+	if payloadKeys != nil || !anySensitive(events) {
+		return false
+	}
+	http.Error(w, `{"error":"sensitive events are not accepted: no payload encryption keys are configured"}`, http.StatusUnprocessableEntity)
+	return true
+}
+
+// anySensitive reports whether any of events is sensitive, which makes an atomic
+// batch's whole payload sensitive.
+func anySensitive(events []domain.Event) bool {
+	for _, e := range events {
+		if e.Sensitive {
+			return true
+		}
+	}
+	return false
+}
+
+// sealInline carries payloadBytes inline in msg sealed with the active key, bound
+// to the event ID so a sealed payload can't be replayed under another message.
+// Sealed payloads are never archive-sampled: the archived copy would be plaintext.
+func sealInline(msg *domain.QueueMessage, payloadBytes []byte) error {
+	enc, sealed, err := payloadKeys.Seal(payloadBytes, []byte(msg.EventID))
+	if err != nil {
+		return err
+	}
+	msg.SetSealed(enc, sealed)
+	return nil
+}
@@ -7,19 +7,39 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	minioadapter "github.com/fluxa/fluxa/internal/adapters/minio"
 	prommetrics "github.com/fluxa/fluxa/internal/adapters/prometheus"
 	"github.com/fluxa/fluxa/internal/adapters/rabbitmq"
 	scoreradapter "github.com/fluxa/fluxa/internal/adapters/scorer"
+	"github.com/fluxa/fluxa/internal/anomaly"
+	"github.com/fluxa/fluxa/internal/archiveretry"
+	"github.com/fluxa/fluxa/internal/clients"
 	"github.com/fluxa/fluxa/internal/config"
 	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/diagnostics"
 	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/enrichment"
 	"github.com/fluxa/fluxa/internal/fraud"
+	"github.com/fluxa/fluxa/internal/health"
 	"github.com/fluxa/fluxa/internal/idempotency"
 	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/merchant"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/notify"
+	"github.com/fluxa/fluxa/internal/payloadcrypt"
+	"github.com/fluxa/fluxa/internal/ports"
 	"github.com/fluxa/fluxa/internal/processor"
+	"github.com/fluxa/fluxa/internal/queuedepth"
+	"github.com/fluxa/fluxa/internal/ratelimit"
+	"github.com/fluxa/fluxa/internal/schemadrift"
+	"github.com/fluxa/fluxa/internal/schemamigrate"
+	"github.com/fluxa/fluxa/internal/slo"
+	"github.com/fluxa/fluxa/internal/webhook"
+	"github.com/fluxa/fluxa/migrations"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -32,23 +52,55 @@ func main() {
 
 	logger := logging.NewLogger("processor", "init")
 
-	dbClient, err := db.NewClient(cfg.DSN(), 10)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create database client: %v\n", err)
+	factory := clients.New(cfg, "processor")
+
+	// Dial Postgres, RabbitMQ, and MinIO in parallel — each connect is dominated
+	// by network round trips, so serial init stacks their latencies at startup.
+	var (
+		dbClient    *db.Client
+		mqClient    *rabbitmq.Client
+		minioClient *minioadapter.Client
+		dbErr       error
+		mqErr       error
+		minioErr    error
+		wg          sync.WaitGroup
+	)
+	pool := db.PoolFor(cfg.DBPoolStrategy, cfg.DBPoolIdleTimeout())
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		dbClient, dbErr = db.Connect(cfg.DSN(), pool, cfg.DBLazyConnect)
+	}()
+	go func() {
+		defer wg.Done()
+		mqClient, mqErr = factory.Queue()
+	}()
+	go func() {
+		defer wg.Done()
+		minioClient, minioErr = factory.Storage()
+	}()
+	wg.Wait()
+
+	if dbErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create database client: %v\n", dbErr)
 		os.Exit(1)
 	}
 	defer dbClient.Close()
+	if cfg.DBAutoMigrate {
+		if err := migrations.ApplyAtStartup(dbClient.GetDB(), logger); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to apply database migrations: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	mqClient, err := rabbitmq.NewClient(cfg.RabbitMQURL)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to RabbitMQ: %v\n", err)
+	if mqErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to RabbitMQ: %v\n", mqErr)
 		os.Exit(1)
 	}
 	defer mqClient.Close()
 
-	minioClient, err := minioadapter.NewClient(cfg.MinioEndpoint, cfg.MinioAccessKey, cfg.MinioSecretKey, cfg.MinioBucket, cfg.MinioUseSSL)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to MinIO: %v\n", err)
+	if minioErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to MinIO: %v\n", minioErr)
 		os.Exit(1)
 	}
 
@@ -78,16 +130,131 @@ func main() {
 		fraudScorer = sc
 	}
 
+	// User-profile enrichment (optional, skip-on-failure).
+	var enricher enrichment.Provider
+	if cfg.EnrichmentURL != "" {
+		timeout := time.Duration(cfg.EnrichmentTimeoutMs) * time.Millisecond
+		enricher = enrichment.NewCachingProvider(
+			enrichment.NewHTTPProvider(cfg.EnrichmentURL, cfg.EnrichmentFields, timeout),
+			time.Duration(cfg.EnrichmentCacheTTLSeconds)*time.Second,
+			cfg.EnrichmentCacheMaxEntries,
+		)
+		logger.Info("User-profile enrichment enabled", map[string]interface{}{
+			"url":    cfg.EnrichmentURL,
+			"fields": cfg.EnrichmentFields,
+		})
+	}
+
+	var detector *anomaly.Detector
+	if cfg.AnomalyStdDevThreshold > 0 {
+		detector = &anomaly.Detector{
+			Querier:       dbClient,
+			Threshold:     cfg.AnomalyStdDevThreshold,
+			MinSamples:    cfg.AnomalyMinSamples,
+			WindowSeconds: cfg.AnomalyWindowSeconds,
+		}
+	}
+
+	metrics := prommetrics.NewMetrics("processor")
+	dbClient.WithTimeouts(cfg.DBTimeouts()).WithMetrics(metrics).WithSlowQueryLog(cfg.DBSlowQuery(), logger)
+
+	// Producer ack webhooks: deliveries run on their own workers so a slow
+	// producer endpoint never holds up the consume loop. Body templates are
+	// validated here, so a broken one stops startup instead of every delivery.
+	var ackTemplates *webhook.Templates
+	if cfg.WebhookTemplatesFile != "" {
+		ackTemplates, err = webhook.LoadTemplates(cfg.WebhookTemplatesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load webhook templates: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// A webhook secret in the shared bundle signs that producer's acks instead of
+	// the one PUT /webhooks generated.
+	bundle, err := factory.Secrets(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load secret bundle: %v\n", err)
+		os.Exit(1)
+	}
+	// The pipeline's optional stages come from PROCESSOR_STAGES; a typo or a stage
+	// out of order stops startup rather than silently skipping work.
+	pipeline, err := processor.ParsePipeline(cfg.ProcessorStages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid PROCESSOR_STAGES: %v\n", err)
+		os.Exit(1)
+	}
+	logger.Info("Processor pipeline", map[string]interface{}{"stages": pipeline.Stages()})
+
+	// Fraud alerts go wherever NOTIFIER_MODE says; an unknown mode stops startup.
+	notifier, err := factory.Notifier(mqClient)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to configure notifier: %v\n", err)
+		os.Exit(1)
+	}
+
+	acks := webhook.NewDispatcher(dbClient,
+		&http.Client{Transport: factory.HTTPTransport(), Timeout: 5 * time.Second},
+		metrics, logger, webhook.Options{Templates: ackTemplates, Secrets: bundle})
+	defer acks.Close()
+
+	flags := factory.Flags(logger)
+	idem := idempotency.NewClient(dbClient.GetDB()).WithMetrics(metrics).WithFlags(flags)
+	if cfg.IdempotencyLockMode == idempotency.LockModeAdvisory {
+		idem.WithAdvisoryLocks()
+	}
 	proc := &processor.Processor{
-		DB:          dbClient,
-		Idempotency: idempotency.NewClient(dbClient.GetDB()),
-		Storage:     minioClient,
-		Publisher:   mqClient,
-		Fraud:       fraudEngine,
-		Scorer:      fraudScorer,
-		Metrics:     prommetrics.NewMetrics("processor"),
-		Logger:      logger,
+		DB:              dbClient,
+		Idempotency:     idem,
+		Storage:         minioClient,
+		Notifier:        notifier,
+		Pipeline:        pipeline,
+		MaxAttempts:     cfg.ProcessorMaxAttempts,
+		MaxPayloadBytes: cfg.ProcessorMaxPayloadBytes,
+		Writes:          ratelimit.New(ratelimit.Options{PerSecond: cfg.ProcessorDBWritesPerSecond, Burst: cfg.ProcessorDBWriteBurst}),
+		WriteWait:       time.Duration(cfg.ProcessorDBWriteMaxWaitMs) * time.Millisecond,
+		Tenants:         metricdef.NewTenants(cfg.MetricsTenants),
+		Fraud:           fraudEngine,
+		Scorer:          fraudScorer,
+		Merchants:       merchant.NewCanonicalizer(dbClient, logger, time.Minute),
+		Enricher:        enricher,
+		EnrichTimeout:   time.Duration(cfg.EnrichmentTimeoutMs) * time.Millisecond,
+		Anomaly:         detector,
+		Acks:            acks,
+		Groups:          notify.NewQueueGroupNotifier(mqClient),
+		Validation:      cfg.EventValidation(),
+		Flags:           flags,
+		Tunables:        factory.Tunables(context.Background(), metrics, logger),
+		Metrics:         metrics,
+		Logger:          logger,
+	}
+	if cfg.SchemaDriftSamplePercent > 0 {
+		proc.Drift = &schemadrift.Observer{Store: dbClient, Metrics: metrics, Logger: logger, SamplePercent: cfg.SchemaDriftSamplePercent}
 	}
+	// A broken migrations file stops startup rather than failing every event
+	// that needs upgrading.
+	if cfg.PayloadMigrationsFile != "" {
+		if proc.Migrations, err = schemamigrate.Load(cfg.PayloadMigrationsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load payload migrations: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if proc.Validation.Schemas, err = factory.Schemas(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load event schemas: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.PayloadEncryptionKeysFile != "" {
+		if proc.Payloads, err = payloadcrypt.Load(cfg.PayloadEncryptionKeysFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load payload encryption keys: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Probes share the metrics port: the processor serves no other HTTP.
+	probes := health.New()
+	probes.Add("db", dbClient.Ping)
+	probes.Add("queue", mqClient.Ping)
+	probes.Register(http.DefaultServeMux)
+	diagnostics.Log(context.Background(), logger, diagnostics.Startup{Service: "processor", Config: cfg, Flags: flags, Probes: probes, DB: dbClient, Pool: &pool})
 
 	// Prometheus metrics endpoint
 	go func() {
@@ -97,32 +264,136 @@ func main() {
 		}
 	}()
 
-	logger.Info("Processor service starting — consuming from 'events' queue", nil)
+	logger.Info("Processor service starting — consuming from 'events' and 'events.priority' queues", map[string]interface{}{
+		"priority_workers": max(cfg.ProcessorPriorityWorkers, 1),
+	})
+
+	// On shutdown, stop taking deliveries; the message in hand is finished and
+	// acked before the loop ends, and unacked prefetched ones go back to the queue.
+	ctx, stopConsuming := context.WithCancel(context.Background())
+	defer stopConsuming()
+	probes.OnShutdown(cfg.ShutdownDrain(), stopConsuming)
+
+	if cfg.QueueDepthPollSeconds > 0 {
+		depth := &queuedepth.Exporter{
+			Inspector: mqClient,
+			Queues:    rabbitmq.QueueNames(),
+			Interval:  time.Duration(cfg.QueueDepthPollSeconds) * time.Second,
+			Metrics:   metrics,
+			Logger:    logger,
+		}
+		go depth.Run(ctx)
+	}
+	if cfg.SLORollupIntervalSeconds > 0 {
+		roller := &slo.Roller{
+			Store:    dbClient,
+			Interval: time.Duration(cfg.SLORollupIntervalSeconds) * time.Second,
+			Target:   time.Duration(cfg.SLOLatencyTargetMs) * time.Millisecond,
+			Logger:   logger,
+		}
+		go roller.Run(ctx)
+	}
+	if cfg.ArchiveRetryIntervalSeconds > 0 {
+		drainer := &archiveretry.Drainer{
+			Store:      dbClient,
+			Storage:    minioClient,
+			Placements: cfg.PayloadPlacements,
+			Interval:   time.Duration(cfg.ArchiveRetryIntervalSeconds) * time.Second,
+			Metrics:    metrics,
+			Logger:     logger,
+		}
+		go drainer.Run(ctx)
+	}
 
-	ctx := context.Background()
-	deliveries, err := mqClient.Consume(ctx, "events")
+	if cfg.ProcessorPrefetch > 0 {
+		if err := mqClient.SetPrefetch(cfg.ProcessorPrefetch); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set prefetch: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	deliveries, err := mqClient.Consume(ctx, domain.EventsRoutingKey)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to start consuming: %v\n", err)
 		os.Exit(1)
 	}
+	priority, err := mqClient.Consume(ctx, domain.PriorityEventsRoutingKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start consuming priority queue: %v\n", err)
+		os.Exit(1)
+	}
+
+	// High-priority events get workers of their own, so they never queue behind a
+	// bulk backfill. Each worker has its own copy of proc: consume sets its Logger
+	// per message.
+	var lanes sync.WaitGroup
+	priorityLane := &lane{queue: domain.PriorityEventsRoutingKey, metrics: metrics}
+	for i := 0; i < max(cfg.ProcessorPriorityWorkers, 1); i++ {
+		worker := *proc
+		lanes.Add(1)
+		go func() {
+			defer lanes.Done()
+			priorityLane.consume(priority, &worker)
+		}()
+	}
+	(&lane{queue: domain.EventsRoutingKey, metrics: metrics}).consume(deliveries, proc)
+	lanes.Wait()
+
+	logger.Info("Consumer channel closed — processor exiting", nil)
+}
+
+// lane is the consumers of one queue. Its metrics show how they keep up: how long
+// they wait for deliveries, how many are in flight at once, and how each is settled,
+// for tuning PROCESSOR_PREFETCH and PROCESSOR_PRIORITY_WORKERS.
+type lane struct {
+	queue    string
+	metrics  ports.Metrics
+	inFlight atomic.Int64
+}
 
+// consume processes deliveries until the channel closes, acking each message the
+// processor is done with and nacking retryable failures for redelivery. Several
+// workers may consume one lane.
+func (l *lane) consume(deliveries <-chan rabbitmq.Delivery, proc *processor.Processor) {
+	waitStart := time.Now()
 	for d := range deliveries {
-		var msg domain.QueueMessage
-		if err := json.Unmarshal(d.Body(), &msg); err != nil {
-			proc.Logger.Error("Failed to parse queue message — discarding", err)
-			_ = d.Ack() // Discard unparseable message
-			continue
+		l.metrics.ObserveHistogram(metricdef.ConsumerWaitSeconds, time.Since(waitStart).Seconds(), "queue", l.queue)
+		if d.Redelivered() {
+			l.metrics.IncCounter(metricdef.ConsumerRedeliveriesTotal, "queue", l.queue)
 		}
+		l.metrics.SetGauge(metricdef.ConsumerInFlight, float64(l.inFlight.Add(1)), "queue", l.queue)
+		outcome := l.handle(d, proc)
+		l.metrics.SetGauge(metricdef.ConsumerInFlight, float64(l.inFlight.Add(-1)), "queue", l.queue)
+		l.metrics.IncCounter(metricdef.ConsumerDeliveriesTotal, "queue", l.queue, "outcome", outcome)
+		waitStart = time.Now()
+	}
+}
 
-		proc.Logger = logging.NewLogger("processor", msg.CorrelationID)
+// handle processes and settles one delivery, returning how it was settled.
+func (l *lane) handle(d rabbitmq.Delivery, proc *processor.Processor) string {
+	var msg domain.QueueMessage
+	if err := json.Unmarshal(d.Body(), &msg); err != nil {
+		proc.Logger.Error("Failed to parse queue message — discarding", err)
+		l.settle(proc, "ack", d.Ack()) // Discard unparseable message
+		return "discarded"
+	}
 
-		if err := proc.ProcessMessage(&msg); err != nil {
-			// Retryable error — nack so broker re-delivers
-			_ = d.Nack(true)
-		} else {
-			_ = d.Ack()
-		}
+	proc.Logger = logging.NewLogger("processor", msg.CorrelationID)
+
+	if res, _ := proc.ProcessMessage(&msg); res.Ack() {
+		l.settle(proc, "ack", d.Ack())
+		return "ack"
 	}
+	// Retryable error — nack so broker re-delivers
+	l.settle(proc, "nack", d.Nack(true))
+	return "nack"
+}
 
-	logger.Info("Consumer channel closed — processor exiting", nil)
+// settle counts and logs a failed ack or nack. The broker redelivers the message
+// once the channel closes, so there is nothing else to do.
+func (l *lane) settle(proc *processor.Processor, op string, err error) {
+	if err == nil {
+		return
+	}
+	l.metrics.IncCounter(metricdef.ConsumerSettleFailuresTotal, "queue", l.queue, "op", op)
+	proc.Logger.Error("Failed to settle delivery", err, map[string]interface{}{"op": op})
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/accesslog"
+	"github.com/fluxa/fluxa/internal/authz"
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+// routeRule is the role an endpoint needs under QUERY_AUTHZ=enforce. A prefix
+// ending in "/" matches the paths below it, any other only itself.
+type routeRule struct {
+	prefix string
+	method string // empty matches every method
+
+	public bool       // no credentials needed: probes, and endpoints with their own key checks
+	role   authz.Role // the least role allowed
+	tenant bool       // /admin/events/{id}: tenant-admins only for their own tenant's events
+}
+
+// routeRules are matched in order, first match wins. A path no rule matches is
+// denied, so a new endpoint stays closed until it is given a rule here.
+var routeRules = []routeRule{
+	{prefix: "/health", public: true},
+	{prefix: "/healthz", public: true},
+	{prefix: "/readyz", public: true},
+	{prefix: "/webhooks", public: true},                        // scoped to the caller's X-API-Key
+	{prefix: "/events/", method: http.MethodPut, public: true}, // EVENT_WRITER_KEYS
+
+	{prefix: "/events/", role: authz.RoleReader},
+	{prefix: "/batches/", role: authz.RoleReader},
+	{prefix: "/groups/", role: authz.RoleReader},
+	{prefix: "/fraud-events", role: authz.RoleReader},
+	{prefix: "/merchants/", role: authz.RoleReader},
+	{prefix: "/slo", role: authz.RoleReader},
+
+	{prefix: "/admin/events/status", role: authz.RoleAdmin},
+	{prefix: "/admin/events/", role: authz.RoleTenantAdmin, tenant: true},
+	{prefix: "/admin/", role: authz.RoleAdmin},
+	{prefix: "/exports", role: authz.RoleAdmin},
+	{prefix: "/exports/", role: authz.RoleAdmin},
+}
+
+// matchRoute returns the rule for r, or nil.
+func matchRoute(r *http.Request) *routeRule {
+	for i := range routeRules {
+		rule := &routeRules[i]
+		if rule.method != "" && rule.method != r.Method {
+			continue
+		}
+		if r.URL.Path == rule.prefix || (strings.HasSuffix(rule.prefix, "/") && strings.HasPrefix(r.URL.Path, rule.prefix)) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// authorize enforces routeRules in front of next when QUERY_AUTHZ=enforce, and
+// passes every request through otherwise. CORS preflights carry no credentials
+// and are let through; the request they clear is checked.
+func authorize(next http.Handler) http.Handler {
+	if resolver == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule := matchRoute(r)
+		if r.Method == http.MethodOptions || (rule != nil && rule.public) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if rule == nil {
+			deny(w, r, http.StatusForbidden, "forbidden", nil)
+			return
+		}
+		p, err := resolver.Resolve(r)
+		if err != nil {
+			deny(w, r, http.StatusUnauthorized, "unauthenticated", err)
+			return
+		}
+		if !p.Has(rule.role) || (rule.tenant && !canAdminEvent(p, r)) {
+			deny(w, r, http.StatusForbidden, "forbidden", nil)
+			return
+		}
+		if p.Tenant != "" {
+			accesslog.SetTenant(r.Context(), p.Tenant)
+		}
+		metrics.IncCounter(metricdef.AuthzDecisionsTotal, "decision", "allowed")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// canAdminEvent reports whether p may act on the event of an /admin/events/{id}
+// path. Admins always may; a tenant-admin only on events its tenant submitted.
+// An unknown event is let through to answer 404 as it would for an admin.
+func canAdminEvent(p authz.Principal, r *http.Request) bool {
+	if p.Has(authz.RoleAdmin) {
+		return true
+	}
+	eventID, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/events/"), "/")
+	producerKey, err := dbClient.GetEventProducerKey(eventID)
+	if err == db.ErrNotFound {
+		return true
+	}
+	if err != nil {
+		logger.Error("Failed to look up event producer", err, map[string]interface{}{"event_id": eventID})
+		return false
+	}
+	return p.CanAdminTenant(producerKey)
+}
+
+// deny answers an unauthorized request and counts it.
+func deny(w http.ResponseWriter, r *http.Request, status int, decision string, err error) {
+	metrics.IncCounter(metricdef.AuthzDecisionsTotal, "decision", decision)
+	fields := map[string]interface{}{"method": r.Method, "path": r.URL.Path, "decision": decision}
+	if err != nil {
+		fields["reason"] = err.Error()
+	}
+	logger.Warn("Request denied", fields)
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="fluxa"`)
+		http.Error(w, `{"error":"unauthorized"}`, status)
+		return
+	}
+	http.Error(w, `{"error":"forbidden"}`, status)
+}
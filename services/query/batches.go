@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// handleGetBatch serves GET /batches/{id}: submitted/processed/failed counts and
+// status. A non-atomic batch appears once its first member is processed and is
+// complete when the counts add up. An atomic batch appears when it is committed or
+// rejected; before that, one the processor has already claimed reports
+// "processing". A batch still queued (or never submitted) is 404.
+func handleGetBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	batchID := strings.TrimPrefix(r.URL.Path, "/batches/")
+	if batchID == "" || strings.Contains(batchID, "/") {
+		http.Error(w, `{"error":"batch_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	batch, err := dbClient.GetBatch(batchID)
+	if err == nil {
+		writeJSON(w, http.StatusOK, batch)
+		return
+	}
+	if err != db.ErrNotFound {
+		logger.Error("Failed to query batch", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	rec, err := idemClient.GetStatus(domain.BatchIdempotencyKey(batchID))
+	if err != nil {
+		logger.Error("Failed to query batch idempotency status", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	if rec == nil || rec.Status != string(domain.IdempotencyStatusProcessing) {
+		http.Error(w, fmt.Sprintf(`{"error":"batch not found: %s"}`, batchID), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"batch_id": batchID,
+		"atomic":   true,
+		"status":   domain.BatchStatusProcessing,
+	})
+}
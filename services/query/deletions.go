@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+type deletionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handleDeleteEvent serves DELETE /admin/events/{id}. By default the delete is
+// soft: the event disappears from reads, feeds, exports, and aggregates but can be
+// restored. ?mode=hard erases it with its flags for good (GDPR requests) and needs
+// a reason. Both require X-Actor and If-Match and are recorded in event_deletions.
+func handleDeleteEvent(w http.ResponseWriter, r *http.Request, eventID string) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	hard := false
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "soft":
+	case "hard":
+		hard = true
+	default:
+		http.Error(w, fmt.Sprintf(`{"error":"mode must be soft or hard, got %q"}`, mode), http.StatusBadRequest)
+		return
+	}
+	d, version, ok := readDeletion(w, r, eventID)
+	if !ok {
+		return
+	}
+	if hard && d.Reason == "" {
+		http.Error(w, `{"error":"a reason is required for a hard delete"}`, http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if hard {
+		err = dbClient.HardDeleteEvent(d, version)
+	} else {
+		err = dbClient.SoftDeleteEvent(d, version)
+	}
+	writeDeletion(w, d, err)
+}
+
+// handleRestoreEvent serves POST /admin/events/{id}/restore, undoing a soft delete.
+// If-Match is the version the delete returned.
+func handleRestoreEvent(w http.ResponseWriter, r *http.Request, eventID string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	d, version, ok := readDeletion(w, r, eventID)
+	if !ok {
+		return
+	}
+	err := dbClient.RestoreEvent(d, version)
+	if err == db.ErrNotDeleted {
+		http.Error(w, fmt.Sprintf(`{"error":"event is not deleted: %s"}`, eventID), http.StatusConflict)
+		return
+	}
+	writeDeletion(w, d, err)
+}
+
+// handleEventDeletions serves GET /admin/events/{id}/deletions, the event's
+// delete/restore/erasure history. It outlives a hard delete.
+func handleEventDeletions(w http.ResponseWriter, r *http.Request, eventID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	deletions, err := dbClient.ListEventDeletions(eventID)
+	if err != nil {
+		logger.Error("Failed to list event deletions", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"event_id": eventID, "deletions": deletions})
+}
+
+// readDeletion reads the actor, If-Match version, and optional {"reason"} body of
+// a delete or restore. On failure the response has been written.
+func readDeletion(w http.ResponseWriter, r *http.Request, eventID string) (*domain.EventDeletion, int, bool) {
+	actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+	if actor == "" {
+		http.Error(w, `{"error":"X-Actor header is required"}`, http.StatusBadRequest)
+		return nil, 0, false
+	}
+	version, ok := requireIfMatch(w, r)
+	if !ok {
+		return nil, 0, false
+	}
+	var req deletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return nil, 0, false
+	}
+	return &domain.EventDeletion{EventID: eventID, Actor: actor, Reason: strings.TrimSpace(req.Reason)}, version, true
+}
+
+// writeDeletion answers a delete or restore: the audit record with the new version
+// as ETag, 404, 412 on a stale If-Match, or 500.
+func writeDeletion(w http.ResponseWriter, d *domain.EventDeletion, err error) {
+	var conflict *db.VersionConflictError
+	switch {
+	case err == nil:
+		logger.Info("Event deletion state changed", map[string]interface{}{
+			"event_id": d.EventID,
+			"action":   d.Action,
+			"actor":    d.Actor,
+			"reason":   d.Reason,
+		})
+		if d.Version > 0 {
+			w.Header().Set("ETag", versionETag(d.Version))
+		}
+		writeJSON(w, http.StatusOK, d)
+	case err == db.ErrNotFound:
+		http.Error(w, fmt.Sprintf(`{"error":"event not found: %s"}`, d.EventID), http.StatusNotFound)
+	case errors.As(err, &conflict):
+		writeVersionConflict(w, d.EventID, conflict.Current)
+	default:
+		logger.Error("Failed to change event deletion state", err, map[string]interface{}{"event_id": d.EventID, "action": d.Action})
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/clients"
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/export"
+	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/google/uuid"
+)
+
+var (
+	exporterMu sync.Mutex
+	exporter   *export.Runner
+	presigner  ports.Presigner
+)
+
+// getExporter returns the export runner and the presigner for download URLs,
+// connecting to object storage on first use like getNotifier. A failed connect is
+// not cached.
+func getExporter() (*export.Runner, ports.Presigner, error) {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+	if exporter != nil {
+		return exporter, presigner, nil
+	}
+	storage, err := clients.New(cfg, "query").Storage()
+	if err != nil {
+		return nil, nil, err
+	}
+	exporter = export.NewRunner(dbClient, storage, metrics, logger, export.Options{
+		Workers: cfg.ExportWorkers,
+		MaxRows: cfg.ExportMaxRows,
+	})
+	presigner = storage
+	return exporter, presigner, nil
+}
+
+type exportRequest struct {
+	Format  domain.ExportFormat `json:"format"`
+	Filters domain.ExportFilter `json:"filters"`
+}
+
+// handleExports serves POST /exports: it records an export job and starts it in
+// the background, answering 202 with the job; poll GET /exports/{id} for progress.
+func handleExports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	var req exportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	req.Format = domain.ExportFormat(strings.ToLower(strings.TrimSpace(string(req.Format))))
+	if req.Format == "" {
+		req.Format = domain.ExportFormatCSV
+	}
+	if req.Format == "parquet" {
+		http.Error(w, `{"error":"parquet exports are not supported yet; use csv or ndjson"}`, http.StatusBadRequest)
+		return
+	}
+	if !req.Format.Valid() {
+		http.Error(w, `{"error":"format must be csv or ndjson"}`, http.StatusBadRequest)
+		return
+	}
+	f := req.Filters
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		http.Error(w, `{"error":"filters.from must be before filters.to"}`, http.StatusBadRequest)
+		return
+	}
+
+	runner, _, err := getExporter()
+	if err != nil {
+		logger.Error("Failed to connect to object storage for export", err)
+		http.Error(w, `{"error":"object storage unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+	job := &domain.Export{
+		ExportID: uuid.New().String(),
+		Format:   req.Format,
+		Filter:   f,
+		Status:   domain.ExportStatusPending,
+	}
+	if err := dbClient.CreateExport(job); err != nil {
+		logger.Error("Failed to create export", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	// The runner owns job from here on; answer with a copy.
+	resp := *job
+	runner.Start(job)
+
+	logger.Info("Export started", map[string]interface{}{"export_id": resp.ExportID, "format": resp.Format})
+	w.Header().Set("Location", "/exports/"+resp.ExportID)
+	writeJSON(w, http.StatusAccepted, &resp)
+}
+
+// handleGetExport serves GET /exports/{id}: the job's status and progress, plus a
+// presigned download_url (valid for ExportURLTTLSeconds) once it is complete.
+func handleGetExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	exportID := strings.TrimPrefix(r.URL.Path, "/exports/")
+	if exportID == "" || strings.Contains(exportID, "/") {
+		http.Error(w, `{"error":"export_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	job, err := dbClient.GetExport(exportID)
+	if err == db.ErrNotFound {
+		http.Error(w, fmt.Sprintf(`{"error":"export not found: %s"}`, exportID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to query export", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	resp := struct {
+		*domain.Export
+		DownloadURL       string     `json:"download_url,omitempty"`
+		DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
+	}{Export: job}
+	if job.Status == domain.ExportStatusComplete && job.ObjectKey != "" {
+		_, signer, err := getExporter()
+		if err != nil {
+			logger.Error("Failed to connect to object storage for export download", err)
+			http.Error(w, `{"error":"object storage unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+		ttl := time.Duration(cfg.ExportURLTTLSeconds) * time.Second
+		u, err := signer.PresignGet(r.Context(), job.ObjectKey, ttl)
+		if err != nil {
+			logger.Error("Failed to presign export download", err, map[string]interface{}{"export_id": exportID})
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+		expires := time.Now().UTC().Add(ttl)
+		resp.DownloadURL, resp.DownloadExpiresAt = u, &expires
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
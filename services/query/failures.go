@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// Page sizes for GET /admin/failures.
+const (
+	defaultFailuresLimit = 100
+	maxFailuresLimit     = 1000
+)
+
+// failuresCursor is the state sealed into a next_cursor token: the filters of the
+// listing and the last record returned, so a page can only continue the listing
+// it came from.
+type failuresCursor struct {
+	Since     time.Time `json:"s"`
+	Reason    string    `json:"r"`
+	AfterSeen time.Time `json:"t"`
+	AfterID   string    `json:"e"`
+}
+
+type failuresPage struct {
+	Failures   []domain.FailureRecord `json:"failures"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+var failuresCSVHeader = []string{"event_id", "reason", "detail", "attempts", "first_seen_at", "last_seen_at", "message"}
+
+// handleFailures serves GET /admin/failures?since=&reason=&limit=&cursor=: the
+// events that failed permanently, last seen at or after since (RFC 3339, default
+// 24h ago), oldest first, optionally only one reason code, each with the queue
+// message the processor kept. next_cursor continues the listing and carries its
+// filters; it is encrypted (see ADMIN_CURSOR_SECRET). format=csv returns the page
+// as CSV with the cursor in X-Next-Cursor, for export.
+func handleFailures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, `{"error":"format must be json or csv"}`, http.StatusBadRequest)
+		return
+	}
+	limit := defaultFailuresLimit
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxFailuresLimit {
+			http.Error(w, fmt.Sprintf(`{"error":"limit must be between 1 and %d"}`, maxFailuresLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	filters := failuresCursor{Since: time.Now().UTC().Add(-24 * time.Hour), Reason: q.Get("reason")}
+	if raw := q.Get("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			http.Error(w, `{"error":"since must be an RFC 3339 timestamp"}`, http.StatusBadRequest)
+			return
+		}
+		filters.Since = since
+	}
+	page := filters
+	if token := q.Get("cursor"); token != "" {
+		if err := cursors.Open(token, &page); err != nil {
+			http.Error(w, `{"error":"invalid cursor"}`, http.StatusBadRequest)
+			return
+		}
+		// Filters may be repeated alongside the cursor, but not changed.
+		if (q.Has("since") && !filters.Since.Equal(page.Since)) || (q.Has("reason") && filters.Reason != page.Reason) {
+			http.Error(w, `{"error":"cursor belongs to a listing with other filters"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	failures, err := idemClient.ListFailures(page.Since, page.Reason, page.AfterSeen, page.AfterID, limit)
+	if err != nil {
+		logger.Error("Failed to list failures", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	out := failuresPage{Failures: failures}
+	if out.Failures == nil {
+		out.Failures = []domain.FailureRecord{}
+	}
+	if len(failures) == limit {
+		last := failures[len(failures)-1]
+		page.AfterSeen, page.AfterID = last.LastSeenAt, last.EventID
+		if out.NextCursor, err = cursors.Seal(page); err != nil {
+			logger.Error("Failed to seal failures cursor", err)
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !canReadPII(r) {
+		// The kept message is the event itself; without the scope it is withheld.
+		for i := range out.Failures {
+			out.Failures[i].Message = nil
+		}
+	}
+	if format != "csv" {
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="failures.csv"`)
+	if out.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", out.NextCursor)
+	}
+	cw := csv.NewWriter(w)
+	_ = cw.Write(failuresCSVHeader)
+	for _, f := range out.Failures {
+		_ = cw.Write([]string{
+			f.EventID, f.Reason, f.Detail, strconv.Itoa(f.Attempts),
+			f.FirstSeenAt.UTC().Format(time.RFC3339Nano), f.LastSeenAt.UTC().Format(time.RFC3339Nano),
+			string(f.Message),
+		})
+	}
+	cw.Flush()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// groupResponse is GET /groups/{id}: the group's progress and its persisted
+// members.
+type groupResponse struct {
+	*domain.EventGroup
+	Events []domain.EventRecord `json:"events"`
+}
+
+// handleGetGroup serves GET /groups/{id}: expected and persisted member counts,
+// whether the group is complete, and its events in timestamp order. A group
+// appears once its first member is persisted; before that it is 404.
+func handleGetGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	groupID := strings.TrimPrefix(r.URL.Path, "/groups/")
+	if groupID == "" || strings.Contains(groupID, "/") {
+		http.Error(w, `{"error":"group_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	group, err := dbClient.GetGroup(groupID)
+	if err == db.ErrNotFound {
+		http.Error(w, fmt.Sprintf(`{"error":"group not found: %s"}`, groupID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to query group", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	events, err := dbClient.ListGroupEvents(groupID)
+	if err != nil {
+		logger.Error("Failed to list group events", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	for i := range events {
+		if _, err := migrations.UpgradeRecord(&events[i]); err != nil {
+			logger.Error("Failed to upgrade event schema", err, map[string]interface{}{"event_id": events[i].EventID})
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	if !canReadPII(r) {
+		for i := range events {
+			maskRecord(&events[i])
+		}
+	}
+	writeJSON(w, http.StatusOK, groupResponse{EventGroup: group, Events: events})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/idempotency"
+)
+
+// maxImportBodyBytes bounds a POST /admin/idempotency/import body: a full batch of
+// long event IDs, with headroom.
+const maxImportBodyBytes = 4 << 20
+
+type importRequest struct {
+	EventIDs []string `json:"event_ids"`
+}
+
+type importResponse struct {
+	Imported int `json:"imported"`
+	Existing int `json:"existing"`
+}
+
+// handleImportIdempotency serves POST /admin/idempotency/import: it records up to
+// idempotency.MaxImportBatch event IDs, migrated from another system, as already
+// processed, so replaying them during a cutover dedupes instead of inserting them
+// twice. Keys that already exist are counted and left alone. The caller is
+// identified by the X-Actor header, which is logged. cmd/idempotency-import loads
+// whole files in batches.
+func handleImportIdempotency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+	if actor == "" {
+		http.Error(w, `{"error":"X-Actor header is required"}`, http.StatusBadRequest)
+		return
+	}
+	var req importRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBodyBytes)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	ids := uniqueNonEmpty(req.EventIDs)
+	if len(ids) == 0 {
+		http.Error(w, `{"error":"at least one event id is required"}`, http.StatusBadRequest)
+		return
+	}
+	if len(ids) > idempotency.MaxImportBatch {
+		http.Error(w, fmt.Sprintf(`{"error":"at most %d event ids per request"}`, idempotency.MaxImportBatch), http.StatusBadRequest)
+		return
+	}
+
+	imported, err := idemClient.ImportSucceeded(ids)
+	if err != nil {
+		logger.Error("Failed to import idempotency keys", err, map[string]interface{}{"actor": actor})
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Idempotency keys imported", map[string]interface{}{
+		"actor":    actor,
+		"imported": imported,
+		"existing": len(ids) - imported,
+	})
+	writeJSON(w, http.StatusOK, importResponse{Imported: imported, Existing: len(ids) - imported})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,20 +10,45 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fluxa/fluxa/internal/accesslog"
 	prommetrics "github.com/fluxa/fluxa/internal/adapters/prometheus"
+	"github.com/fluxa/fluxa/internal/authz"
+	"github.com/fluxa/fluxa/internal/clients"
 	"github.com/fluxa/fluxa/internal/config"
+	"github.com/fluxa/fluxa/internal/config/secrets"
+	"github.com/fluxa/fluxa/internal/cursor"
 	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/diagnostics"
 	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/dynconfig"
+	"github.com/fluxa/fluxa/internal/featureflags"
+	"github.com/fluxa/fluxa/internal/health"
+	"github.com/fluxa/fluxa/internal/idempotency"
 	"github.com/fluxa/fluxa/internal/logging"
+	"github.com/fluxa/fluxa/internal/merchant"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/notify"
 	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/fluxa/fluxa/internal/schema"
+	"github.com/fluxa/fluxa/internal/schemamigrate"
+	dbmigrations "github.com/fluxa/fluxa/migrations"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
-	cfg      *config.Config
-	dbClient *db.Client
-	metrics  ports.Metrics
-	logger   *logging.Logger
+	cfg        *config.Config
+	dbClient   *db.Client
+	idemClient *idempotency.Client
+	metrics    ports.Metrics
+	logger     *logging.Logger
+	merchants  *merchant.Canonicalizer
+	flags      *featureflags.Flags
+	tunables   *dynconfig.Store
+	cursors    *cursor.Codec
+	resolver   *authz.Resolver         // nil unless QUERY_AUTHZ=enforce
+	migrations *schemamigrate.Registry // nil unless PAYLOAD_MIGRATIONS_FILE is set
+	schemas    *schema.Registry        // event JSON Schemas, for replacements
+	bundle     *secrets.Store          // nil unless SECRETS_FILE is set
 )
 
 func main() {
@@ -35,14 +61,78 @@ func main() {
 
 	logger = logging.NewLogger("query", "init")
 
-	dbClient, err = db.NewClient(cfg.DSN(), 10)
+	// Exports save progress while streaming rows, which takes a second connection.
+	pool := db.PoolFor(cfg.DBPoolStrategy, cfg.DBPoolIdleTimeout())
+	pool.MaxOpen, pool.MaxIdle = max(pool.MaxOpen, 2), max(pool.MaxIdle, 2)
+	// Skip the startup ping: the pool dials on the first request, so a slow
+	// Postgres doesn't hold up the listener.
+	dbClient, err = db.Connect(cfg.DSN(), pool, true)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create database client: %v\n", err)
 		os.Exit(1)
 	}
 	defer dbClient.Close()
+	if cfg.DBAutoMigrate {
+		if err := dbmigrations.ApplyAtStartup(dbClient.GetDB(), logger); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to apply database migrations: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	idemClient = idempotency.NewClient(dbClient.GetDB())
+	cursors, err = cursor.NewCodec(cfg.AdminCursorSecret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create cursor codec: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.QueryAuthz == "enforce" {
+		if resolver, err = authz.NewResolver(cfg.QueryAPIRoles, cfg.QueryJWTSecret); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to configure authorization: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.PayloadMigrationsFile != "" {
+		if migrations, err = schemamigrate.Load(cfg.PayloadMigrationsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load payload migrations: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	merchants = merchant.NewCanonicalizer(dbClient, logger, time.Minute)
+	factory := clients.New(cfg, "query")
+	flags = factory.Flags(logger)
 
 	metrics = prommetrics.NewMetrics("query")
+	dbClient.WithTimeouts(cfg.DBTimeouts()).WithMetrics(metrics).WithSlowQueryLog(cfg.DBSlowQuery(), logger)
+	tunables = factory.Tunables(context.Background(), metrics, logger)
+	if schemas, err = factory.Schemas(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load event schemas: %v\n", err)
+		os.Exit(1)
+	}
+	if bundle, err = factory.Secrets(logger); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load secret bundle: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Queue mode connects on the first re-send; the other modes are checked now so
+	// a bad NOTIFIER_MODE stops startup as it does in the processor.
+	if cfg.NotifierMode != notify.ModeQueue {
+		if _, err := getNotifier(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to configure notifier: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Export jobs run in this process; any still open were cut off by the last
+	// shutdown and would otherwise report "running" forever.
+	if n, err := dbClient.FailInterruptedExports("interrupted: query service restarted"); err != nil {
+		logger.Error("Failed to fail interrupted exports", err)
+	} else if n > 0 {
+		logger.Warn("Failed exports interrupted by restart", map[string]interface{}{"count": n})
+	}
+	if n, err := dbClient.FailInterruptedRetryJobs("interrupted: query service restarted"); err != nil {
+		logger.Error("Failed to fail interrupted retry jobs", err)
+	} else if n > 0 {
+		logger.Warn("Failed retry jobs interrupted by restart", map[string]interface{}{"count": n})
+	}
 
 	// Prometheus metrics endpoint
 	go func() {
@@ -53,15 +143,49 @@ func main() {
 	}()
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/events/", handleGetEvent)
+	mux.HandleFunc("/events/", handleEvent)
+	mux.HandleFunc("/events/status-batch", handleStatusBatch)
+	mux.HandleFunc("/batches/", handleGetBatch)
+	mux.HandleFunc("/groups/", handleGetGroup)
 	mux.HandleFunc("/fraud-events", handleFraudEvents)
+	mux.HandleFunc("/merchants/top", handleTopMerchants)
+	mux.HandleFunc("/merchants/", handleMerchantStats)
+	mux.HandleFunc("/admin/merchants/aliases", handleMerchantAliases)
+	mux.HandleFunc("/admin/merchants/aliases/", handleDeleteMerchantAlias)
+	mux.HandleFunc("/admin/events/status", handleEventStatuses)
+	mux.HandleFunc("/admin/events/", handleEventAdmin)
+	mux.HandleFunc("/webhooks", handleWebhooks)
+	mux.HandleFunc("/admin/failures", handleFailures)
+	mux.HandleFunc("/admin/retries", handleRetries)
+	mux.HandleFunc("/admin/retries/", handleGetRetry)
+	mux.HandleFunc("/admin/recordings/", handleGetRecording)
+	mux.HandleFunc("/admin/idempotency/import", handleImportIdempotency)
+	mux.HandleFunc("/admin/reconciliation", handleReconciliation)
+	mux.HandleFunc("/exports", handleExports)
+	mux.HandleFunc("/exports/", handleGetExport)
+	mux.HandleFunc("/slo", handleSLO)
 	mux.HandleFunc("/health", handleHealth)
 
+	// RabbitMQ and MinIO are left out of readiness: both are connected lazily and
+	// only back the admin re-send and exports.
+	probes := health.New()
+	probes.Add("db", dbClient.Ping)
+	probes.Register(mux)
+	diagnostics.Log(context.Background(), logger, diagnostics.Startup{
+		Service: "query", Config: cfg, Flags: flags, Probes: probes, DB: dbClient, Pool: &pool,
+	})
+
 	logger.Info("Query service starting", map[string]interface{}{"port": 8083})
-	if err := http.ListenAndServe(":8083", mux); err != nil {
+	handler := authorize(mux)
+	if cfg.AccessLog {
+		handler = accesslog.Wrap("query", mux, handler)
+	}
+	srv := &http.Server{Addr: ":8083", Handler: handler}
+	if err := probes.ListenAndServe(srv, cfg.ShutdownDrain(), 10*time.Second); err != nil {
 		fmt.Fprintf(os.Stderr, "HTTP server error: %v\n", err)
 		os.Exit(1)
 	}
+	logger.Info("Query service stopped", nil)
 }
 
 func handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -73,7 +197,7 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 func handleFraudEvents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Correlation-ID")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Correlation-ID, X-API-Key")
 
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusNoContent)
@@ -102,6 +226,7 @@ func handleFraudEvents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
 
+	mask := !canReadPII(r)
 	recent, err := dbClient.GetRecentFraudEvents(limit)
 	if err != nil {
 		logger.Error("Failed to get recent fraud events", err)
@@ -112,7 +237,7 @@ func handleFraudEvents(w http.ResponseWriter, r *http.Request) {
 	if len(recent) > 0 {
 		lastSeen = recent[0].FlaggedAt // recent[0] is newest (DESC order)
 		for i := len(recent) - 1; i >= 0; i-- {
-			if err := writeSSEEvent(w, recent[i]); err != nil {
+			if err := writeSSEEvent(w, recent[i], mask); err != nil {
 				return
 			}
 		}
@@ -135,7 +260,7 @@ func handleFraudEvents(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 			for _, fe := range fresh {
-				if err := writeSSEEvent(w, fe); err != nil {
+				if err := writeSSEEvent(w, fe, mask); err != nil {
 					return
 				}
 				if fe.FlaggedAt.After(lastSeen) {
@@ -149,7 +274,11 @@ func handleFraudEvents(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func writeSSEEvent(w http.ResponseWriter, fe *domain.FraudEvent) error {
+func writeSSEEvent(w http.ResponseWriter, fe *domain.FraudEvent, mask bool) error {
+	fe.AmountDisplay = domain.FormatAmount(fe.Amount, fe.Currency)
+	if mask {
+		fe.UserID = maskUserID(fe.UserID)
+	}
 	data, err := json.Marshal(fe)
 	if err != nil {
 		return err
@@ -174,27 +303,62 @@ func handleGetEvent(w http.ResponseWriter, r *http.Request) {
 	eventID := strings.TrimPrefix(r.URL.Path, "/events/")
 	if eventID == "" {
 		reqLogger.Warn("Missing event_id in path")
-		metrics.IncCounter("query_total", "status", "missing_event_id")
+		metrics.IncCounter(metricdef.QueryTotal, "status", "missing_event_id")
 		http.Error(w, `{"error":"event_id is required"}`, http.StatusBadRequest)
 		return
 	}
 
-	record, err := dbClient.GetEventByID(eventID)
+	fields, err := parseEventFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		metrics.IncCounter(metricdef.QueryTotal, "status", "bad_request")
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	var asOf time.Time
+	if raw := r.URL.Query().Get("as_of"); raw != "" {
+		if asOf, err = time.Parse(time.RFC3339Nano, raw); err != nil {
+			metrics.IncCounter(metricdef.QueryTotal, "status", "bad_request")
+			http.Error(w, `{"error":"as_of must be an RFC 3339 timestamp"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var record *domain.EventRecord
+	var revision *domain.EventRevision
+	if asOf.IsZero() {
+		// A migration may move values between fields, so an event that can be
+		// upgraded is read whole and projected afterwards.
+		dbFields := fields
+		if migrations != nil {
+			dbFields = nil
+		}
+		record, err = dbClient.GetEventByID(eventID, dbFields...)
+	} else {
+		record, revision, err = eventAsOf(eventID, asOf)
+	}
 	if err == db.ErrNotFound {
 		reqLogger.Info("Event not found", map[string]interface{}{"event_id": eventID})
-		metrics.IncCounter("query_total", "status", "not_found")
+		metrics.IncCounter(metricdef.QueryTotal, "status", "not_found")
 		http.Error(w, fmt.Sprintf(`{"error":"event not found: %s"}`, eventID), http.StatusNotFound)
 		return
 	}
 	if err != nil {
 		reqLogger.Error("Failed to query event", err)
-		metrics.IncCounter("query_total", "status", "error")
+		metrics.IncCounter(metricdef.QueryTotal, "status", "error")
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := migrations.UpgradeRecord(record); err != nil {
+		reqLogger.Error("Failed to upgrade event schema", err, map[string]interface{}{"event_id": eventID})
+		metrics.IncCounter(metricdef.QueryTotal, "status", "error")
 		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
 		return
 	}
 
 	reqLogger.Info("Successfully retrieved event", map[string]interface{}{"event_id": eventID})
-	metrics.IncCounter("query_total", "status", "found")
+	metrics.IncCounter(metricdef.QueryTotal, "status", "found")
 
 	response := map[string]interface{}{
 		"event_id":       record.EventID,
@@ -207,16 +371,92 @@ func handleGetEvent(w http.ResponseWriter, r *http.Request) {
 		"metadata":       record.Metadata,
 		"payload_mode":   record.PayloadMode,
 		"created_at":     record.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		"version":        record.Version,
+	}
+	if record.Currency != "" {
+		response["amount_display"] = domain.FormatAmount(record.Amount, record.Currency)
 	}
 	if record.S3Key != nil {
 		response["s3_key"] = *record.S3Key
 	}
+	if record.CanonicalMerchant != "" {
+		response["canonical_merchant"] = record.CanonicalMerchant
+	}
+	if len(record.Enrichment) > 0 {
+		response["enrichment"] = record.Enrichment
+	}
+	if record.Canary {
+		response["canary"] = true
+	}
+	if record.GroupID != "" {
+		response["group_id"] = record.GroupID
+	}
+	if record.SchemaVersion != "" {
+		response["schema_version"] = record.SchemaVersion
+	}
+	if !asOf.IsZero() {
+		response["as_of"] = asOf.UTC().Format(time.RFC3339Nano)
+	}
+	if revision != nil {
+		// Storage and enrichment are only known for the current content.
+		delete(response, "payload_mode")
+		delete(response, "s3_key")
+		delete(response, "canonical_merchant")
+		delete(response, "enrichment")
+		response["superseded_at"] = revision.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	if !canReadPII(r) {
+		response["user_id"], response["metadata"] = maskUserID(record.UserID), maskMetadata(record.Metadata)
+	}
+	if fields != nil {
+		keep := make(map[string]bool, len(fields))
+		for _, f := range fields {
+			keep[f] = true
+		}
+		keep["amount_display"] = keep["amount"] && keep["currency"]
+		keep["as_of"], keep["superseded_at"] = true, true
+		for k := range response {
+			if !keep[k] {
+				delete(response, k)
+			}
+		}
+	}
 
 	respBytes, _ := json.Marshal(response)
 	w.Header().Set("Content-Type", "application/json")
 	if correlationID != "" {
 		w.Header().Set("X-Correlation-ID", correlationID)
 	}
+	if record.Version > 0 {
+		w.Header().Set("ETag", versionETag(record.Version))
+	}
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(respBytes)
 }
+
+// parseEventFields parses a fields= projection ("amount,currency") for GET
+// /events/{id}. event_id is always included; an empty value selects every field
+// (nil). Unknown names are an error so typos don't silently return less.
+func parseEventFields(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	known := make(map[string]bool, len(db.EventFields))
+	for _, f := range db.EventFields {
+		known[f] = true
+	}
+	fields := []string{"event_id"}
+	seen := map[string]bool{"event_id": true}
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		if !known[f] {
+			return nil, fmt.Errorf("unknown field %q; valid fields: %s", f, strings.Join(db.EventFields, ","))
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// redacted replaces masked values.
+const redacted = "[redacted]"
+
+// userIDPrefix is how much of a user ID a masked read keeps, enough to tell
+// users apart at a glance without identifying them.
+const userIDPrefix = 4
+
+// canReadPII reports whether r may see user IDs and metadata unmasked: always
+// when QUERY_MASK_PII is off, otherwise only for a caller whose principal holds
+// the pii scope (QUERY_AUTHZ=enforce) or, as a fallback, an X-API-Key whose hash
+// is in PII_READER_KEYS.
+func canReadPII(r *http.Request) bool {
+	if !cfg.QueryMaskPII {
+		return true
+	}
+	if resolver != nil {
+		if p, err := resolver.Resolve(r); err == nil && p.PII {
+			return true
+		}
+	}
+	key := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if key == "" {
+		return false
+	}
+	keyHash := domain.HashAPIKey(key)
+	for _, k := range cfg.PIIReaderKeys {
+		if k == keyHash {
+			return true
+		}
+	}
+	return false
+}
+
+// maskUserID truncates userID to its first userIDPrefix characters; shorter IDs
+// are redacted whole.
+func maskUserID(userID string) string {
+	runes := []rune(userID)
+	if len(runes) <= userIDPrefix {
+		return redacted
+	}
+	return string(runes[:userIDPrefix]) + "…"
+}
+
+// maskMetadata keeps metadata's keys, so its shape is still visible, and redacts
+// every value.
+func maskMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		return nil
+	}
+	masked := make(map[string]interface{}, len(metadata))
+	for k := range metadata {
+		masked[k] = redacted
+	}
+	return masked
+}
+
+// maskRecord masks rec in place.
+func maskRecord(rec *domain.EventRecord) {
+	rec.UserID, rec.Metadata = maskUserID(rec.UserID), maskMetadata(rec.Metadata)
+}
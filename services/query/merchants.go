@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/merchant"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/slo"
+)
+
+type merchantAliasRequest struct {
+	Alias     string `json:"alias"`
+	Canonical string `json:"canonical"`
+}
+
+// handleMerchantAliases serves GET (list) and PUT (create/replace) on /admin/merchants/aliases.
+// The alias is stored under its normalized key, so "AMZN Mktp US*2K3" and "amzn mktp us"
+// are the same mapping. The processor picks up changes within its refresh interval.
+func handleMerchantAliases(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		aliases, err := dbClient.ListMerchantAliases()
+		if err != nil {
+			logger.Error("Failed to list merchant aliases", err)
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"aliases": aliases})
+
+	case http.MethodPut:
+		var req merchantAliasRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+			return
+		}
+		key := merchant.Key(req.Alias)
+		canonical := strings.TrimSpace(req.Canonical)
+		if key == "" || canonical == "" {
+			http.Error(w, `{"error":"alias and canonical are required"}`, http.StatusBadRequest)
+			return
+		}
+		if err := dbClient.UpsertMerchantAlias(key, canonical); err != nil {
+			logger.Error("Failed to upsert merchant alias", err)
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+		merchants.Invalidate()
+		logger.Info("Merchant alias upserted", map[string]interface{}{"alias_key": key, "canonical": canonical})
+		writeJSON(w, http.StatusOK, map[string]string{"alias_key": key, "canonical": canonical})
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDeleteMerchantAlias serves DELETE /admin/merchants/aliases/{alias}.
+func handleDeleteMerchantAlias(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	key := merchant.Key(strings.TrimPrefix(r.URL.Path, "/admin/merchants/aliases/"))
+	if key == "" {
+		http.Error(w, `{"error":"alias is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := dbClient.DeleteMerchantAlias(key)
+	if err == db.ErrNotFound {
+		http.Error(w, fmt.Sprintf(`{"error":"merchant alias not found: %s"}`, key), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to delete merchant alias", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	merchants.Invalidate()
+	logger.Info("Merchant alias deleted", map[string]interface{}{"alias_key": key})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	respBytes, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(respBytes)
+}
+
+// maxStatsWindow bounds ?window= on the merchant stats endpoints.
+const maxStatsWindow = 90 * 24 * time.Hour
+
+// parseWindow parses ?window= as a Go duration, additionally accepting whole days ("7d").
+// Empty means 24h. The roll-ups are hourly, so a window is at least 1h and at most
+// maxStatsWindow.
+func parseWindow(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 24 * time.Hour, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q", raw)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(raw); err != nil {
+			return 0, fmt.Errorf("invalid window %q", raw)
+		}
+	}
+	if d < time.Hour || d > maxStatsWindow {
+		return 0, fmt.Errorf("window must be between 1h and 90d")
+	}
+	return d, nil
+}
+
+// handleSLO serves GET /slo?window=7d (default 7d): the processing SLO over the
+// window from the hourly roll-up, with the hours themselves.
+func handleSLO(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	raw := r.URL.Query().Get("window")
+	if raw == "" {
+		raw = "7d"
+	}
+	window, err := parseWindow(raw)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	rollups, err := dbClient.ListSLORollups(time.Now().Add(-window))
+	if err != nil {
+		logger.Error("Failed to query SLO roll-ups", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	target := time.Duration(cfg.SLOLatencyTargetMs) * time.Millisecond
+	writeJSON(w, http.StatusOK, slo.Summarize(raw, cfg.SLOObjective, target, rollups))
+}
+
+// handleTopMerchants serves GET /merchants/top?window=24h&limit=10 from the hourly roll-up.
+func handleTopMerchants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	window, err := parseWindow(r.URL.Query().Get("window"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	limit := 10
+	if lStr := r.URL.Query().Get("limit"); lStr != "" {
+		if n, err := strconv.Atoi(lStr); err == nil && n > 0 && n <= 100 {
+			limit = n
+		}
+	}
+
+	top, err := dbClient.GetTopMerchants(time.Now().UTC().Add(-window), limit)
+	if err != nil {
+		logger.Error("Failed to query top merchants", err)
+		metrics.IncCounter(metricdef.QueryTotal, "status", "error")
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	if top == nil {
+		top = []domain.MerchantStats{}
+	}
+	metrics.IncCounter(metricdef.QueryTotal, "status", "found")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"window":    window.String(),
+		"merchants": top,
+	})
+}
+
+// handleMerchantStats serves GET /merchants/{name}/stats?window=24h: window totals
+// plus the hourly buckets. {name} is the canonical merchant (URL-escaped).
+func handleMerchantStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.EscapedPath(), "/merchants/"), "/stats")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	name, err := url.PathUnescape(name)
+	if err != nil {
+		http.Error(w, `{"error":"invalid merchant name"}`, http.StatusBadRequest)
+		return
+	}
+	window, err := parseWindow(r.URL.Query().Get("window"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := dbClient.GetMerchantStatsBuckets(name, time.Now().UTC().Add(-window))
+	if err != nil {
+		logger.Error("Failed to query merchant stats", err)
+		metrics.IncCounter(metricdef.QueryTotal, "status", "error")
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	if len(buckets) == 0 {
+		metrics.IncCounter(metricdef.QueryTotal, "status", "not_found")
+		http.Error(w, fmt.Sprintf(`{"error":"no stats for merchant in window: %s"}`, name), http.StatusNotFound)
+		return
+	}
+
+	totals := domain.MerchantStats{Merchant: name}
+	for _, b := range buckets {
+		totals.EventCount += b.EventCount
+		totals.TotalAmount += b.TotalAmount
+		if b.MaxAmount > totals.MaxAmount {
+			totals.MaxAmount = b.MaxAmount
+		}
+	}
+	totals.AvgAmount = totals.TotalAmount / float64(totals.EventCount)
+
+	metrics.IncCounter(metricdef.QueryTotal, "status", "found")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"window": window.String(),
+		"stats":  totals,
+		"hourly": buckets,
+	})
+}
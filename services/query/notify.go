@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/clients"
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/metricdef"
+	"github.com/fluxa/fluxa/internal/notify"
+	"github.com/fluxa/fluxa/internal/ports"
+)
+
+// notifyChannel is the only notification the pipeline emits today: one
+// AlertMessage per fraud flag, sent through the NOTIFIER_MODE notifier.
+const notifyChannel = "alerts"
+
+var (
+	notifierMu sync.Mutex
+	notifier   notify.Notifier
+)
+
+// getNotifier returns the alert notifier. In queue mode it connects to RabbitMQ on
+// first use, so the query service still starts (and serves reads) while RabbitMQ
+// is down. A failed connect is not cached.
+func getNotifier() (notify.Notifier, error) {
+	notifierMu.Lock()
+	defer notifierMu.Unlock()
+	if notifier != nil {
+		return notifier, nil
+	}
+	factory := clients.New(cfg, "query")
+	var pub ports.Publisher
+	if cfg.NotifierMode == notify.ModeQueue {
+		client, err := factory.Queue()
+		if err != nil {
+			return nil, fmt.Errorf("connect to RabbitMQ: %w", err)
+		}
+		pub = client
+	}
+	n, err := factory.Notifier(pub)
+	if err != nil {
+		return nil, err
+	}
+	notifier = n
+	return notifier, nil
+}
+
+type notifyRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handleEventAdmin routes /admin/events/{id} (delete), /admin/events/{id}/restore,
+// /admin/events/{id}/deletions, /admin/events/{id}/revisions, and
+// /admin/events/{id}/notify.
+func handleEventAdmin(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/admin/events/")
+	eventID, action, _ := strings.Cut(rest, "/")
+	if eventID == "" {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	switch action {
+	case "":
+		handleDeleteEvent(w, r, eventID)
+		return
+	case "restore":
+		handleRestoreEvent(w, r, eventID)
+		return
+	case "deletions":
+		handleEventDeletions(w, r, eventID)
+		return
+	case "revisions":
+		handleEventRevisions(w, r, eventID)
+		return
+	case "notify":
+	default:
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		audits, err := dbClient.ListNotificationAudits(eventID)
+		if err != nil {
+			logger.Error("Failed to list notification audits", err)
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"event_id": eventID, "audits": audits})
+	case http.MethodPost:
+		handleNotify(w, r, eventID)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleNotify re-publishes the alert for every fraud flag on an already-persisted
+// event, for downstream consumers that lost the original messages. The caller is
+// identified by the X-Actor header; every request is written to notification_audit,
+// including ones that re-sent nothing or failed part-way.
+func handleNotify(w http.ResponseWriter, r *http.Request, eventID string) {
+	actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+	if actor == "" {
+		http.Error(w, `{"error":"X-Actor header is required"}`, http.StatusBadRequest)
+		return
+	}
+	var req notifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := dbClient.GetEventByID(eventID, "event_id"); err == db.ErrNotFound {
+		http.Error(w, fmt.Sprintf(`{"error":"event not found: %s"}`, eventID), http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to query event", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	flags, err := dbClient.ListFraudFlags(eventID)
+	if err != nil {
+		logger.Error("Failed to list fraud flags", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	audit := &domain.NotificationAudit{
+		EventID: eventID,
+		Actor:   actor,
+		Reason:  strings.TrimSpace(req.Reason),
+		Channel: notifyChannel,
+	}
+	status := http.StatusOK
+	if sendErr := republishAlerts(r.Context(), flags, audit); sendErr != nil {
+		msg := sendErr.Error()
+		audit.Error = &msg
+		status = http.StatusBadGateway
+	}
+	if err := dbClient.InsertNotificationAudit(audit); err != nil {
+		// The messages may already be out; report the missing audit loudly rather
+		// than pretend nothing was sent.
+		logger.Error("Failed to record notification audit", err, map[string]interface{}{
+			"event_id":      eventID,
+			"actor":         actor,
+			"notifications": audit.Notifications,
+		})
+		http.Error(w, `{"error":"notifications sent but audit record failed"}`, http.StatusInternalServerError)
+		return
+	}
+
+	result := "ok"
+	if audit.Error != nil {
+		result = "error"
+	}
+	metrics.IncCounter(metricdef.NotificationsResentTotal, "channel", notifyChannel, "status", result)
+	logger.Info("Notification re-send", map[string]interface{}{
+		"event_id":      eventID,
+		"actor":         actor,
+		"reason":        audit.Reason,
+		"notifications": audit.Notifications,
+		"audit_id":      audit.AuditID,
+		"status":        result,
+	})
+	writeJSON(w, status, audit)
+}
+
+// republishAlerts sends one AlertMessage per flag, counting successes in
+// audit.Notifications, and stops at the first failure.
+func republishAlerts(ctx context.Context, flags []domain.FraudFlag, audit *domain.NotificationAudit) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	n, err := getNotifier()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	for _, flag := range flags {
+		if err := n.Notify(ctx, []domain.AlertMessage{domain.NewAlertMessage(flag)}); err != nil {
+			return fmt.Errorf("publish alert %s: %w", flag.FlagID, err)
+		}
+		audit.Notifications++
+	}
+	return nil
+}
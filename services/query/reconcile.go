@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Bounds of GET /admin/reconciliation.
+const (
+	defaultReconcileSample = 20
+	maxReconcileSample     = 100
+	maxReconcileWindow     = 7 * 24 * time.Hour
+	// reconcileSettle keeps in-flight events out of a default window: an event is
+	// stored before its key is marked success, so the newest ones look off.
+	reconcileSettle = 5 * time.Minute
+)
+
+// handleReconciliation serves GET /admin/reconciliation?since=&until=&sample=:
+// success idempotency keys without an events row, and events whose key is missing
+// or not success, each with a count and a sample of the oldest event IDs. since
+// defaults to 24h ago and until to 5 minutes ago, so events still being processed
+// don't show up; the window may span at most 7 days.
+func handleReconciliation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	sample := defaultReconcileSample
+	if raw := q.Get("sample"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxReconcileSample {
+			http.Error(w, fmt.Sprintf(`{"error":"sample must be between 1 and %d"}`, maxReconcileSample), http.StatusBadRequest)
+			return
+		}
+		sample = n
+	}
+	until := time.Now().UTC().Add(-reconcileSettle)
+	if raw := q.Get("until"); raw != "" {
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			http.Error(w, `{"error":"until must be an RFC 3339 timestamp"}`, http.StatusBadRequest)
+			return
+		}
+		until = t
+	}
+	since := until.Add(-24 * time.Hour)
+	if raw := q.Get("since"); raw != "" {
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			http.Error(w, `{"error":"since must be an RFC 3339 timestamp"}`, http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	if !since.Before(until) || until.Sub(since) > maxReconcileWindow {
+		http.Error(w, `{"error":"since must be before until, at most 7 days apart"}`, http.StatusBadRequest)
+		return
+	}
+
+	rec, err := dbClient.Reconcile(since, until, sample)
+	if err != nil {
+		logger.Error("Failed to reconcile idempotency keys with events", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, rec)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/clients"
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/ports"
+	"github.com/fluxa/fluxa/internal/recording"
+)
+
+var (
+	recordingStorageMu sync.Mutex
+	recordingStorage   ports.Storage
+)
+
+// getRecordingStorage returns the object store holding request recordings,
+// connecting on first use like getExporter. A failed connect is not cached.
+func getRecordingStorage() (ports.Storage, error) {
+	recordingStorageMu.Lock()
+	defer recordingStorageMu.Unlock()
+	if recordingStorage != nil {
+		return recordingStorage, nil
+	}
+	storage, err := clients.New(cfg, "query").Storage()
+	if err != nil {
+		return nil, err
+	}
+	recordingStorage = storage
+	return recordingStorage, nil
+}
+
+// handleGetRecording serves GET /admin/recordings/{correlation_id}: the redacted
+// ingest request and response recorded under that correlation ID while the
+// record_requests flag was on, if it is within RECORDING_RETENTION_DAYS.
+func handleGetRecording(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	correlationID := strings.TrimPrefix(r.URL.Path, "/admin/recordings/")
+	if correlationID == "" {
+		http.Error(w, `{"error":"correlation_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	storage, err := getRecordingStorage()
+	if err != nil {
+		logger.Error("Failed to connect to object storage", err)
+		http.Error(w, `{"error":"object storage unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+	retention := time.Duration(cfg.RecordingRetentionDays) * 24 * time.Hour
+	rec, err := recording.Load(r.Context(), storage, correlationID, retention, time.Now())
+	var notFound *domain.NotFoundError
+	if errors.As(err, &notFound) {
+		http.Error(w, fmt.Sprintf(`{"error":"no recording for correlation_id: %s"}`, correlationID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to read request recording", err, map[string]interface{}{"correlation_id": correlationID})
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, rec)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+// defaultMaxEventBodyBytes bounds a PUT /events/{id} body when
+// PROCESSOR_MAX_PAYLOAD_BYTES is 0.
+const defaultMaxEventBodyBytes = 16 << 20
+
+// handleEvent routes /events/{id}: GET reads the event, PUT replaces it.
+func handleEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		handlePutEvent(w, r)
+		return
+	}
+	handleGetEvent(w, r)
+}
+
+// handlePutEvent serves PUT /events/{id} for internal services whose X-API-Key is
+// in EVENT_WRITER_KEYS. The body is the full event, as posted to ingest, and goes
+// through the same normalization and validation; the event must already exist.
+// Replacing with identical content is a no-op (200, "unchanged"), so retries are
+// safe; a change needs If-Match with the current version and creates a new
+// revision. Fraud rules are not re-run for the new content. A body over the
+// ingest payload limit (PROCESSOR_MAX_PAYLOAD_BYTES) is answered 413.
+func handlePutEvent(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if key == "" {
+		http.Error(w, `{"error":"X-API-Key header is required"}`, http.StatusUnauthorized)
+		return
+	}
+	actor := domain.HashAPIKey(key)
+	if !isEventWriter(actor) {
+		http.Error(w, `{"error":"this API key may not modify events"}`, http.StatusForbidden)
+		return
+	}
+
+	eventID := strings.TrimPrefix(r.URL.Path, "/events/")
+	if eventID == "" || strings.Contains(eventID, "/") {
+		http.Error(w, `{"error":"event_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	limit := cfg.ProcessorMaxPayloadBytes
+	if limit <= 0 {
+		limit = defaultMaxEventBodyBytes
+	}
+	var event domain.Event
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(&event); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf(`{"error":"body exceeds %d bytes"}`, limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	event.Normalize()
+	if event.EventID != "" && event.EventID != eventID {
+		http.Error(w, `{"error":"event_id in the body does not match the path"}`, http.StatusBadRequest)
+		return
+	}
+	event.EventID = eventID
+	vc := flags.Validation(tunables.Validation(cfg.EventValidation()))
+	vc.Schemas = schemas
+	if err := event.ValidateWith(vc, time.Now()); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"validation failed: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	event.CanonicalMerchant = merchants.Canonicalize(event.Merchant)
+
+	// If-Match only matters when the content changes, so it is optional here: a
+	// retry of an applied PUT must still succeed.
+	ifMatch := 0
+	if r.Header.Get("If-Match") != "" {
+		v, ok := requireIfMatch(w, r)
+		if !ok {
+			return
+		}
+		ifMatch = v
+	}
+
+	version, changed, err := dbClient.ReplaceEvent(&event, ifMatch, actor)
+	var conflict *db.VersionConflictError
+	switch {
+	case err == db.ErrNotFound:
+		http.Error(w, fmt.Sprintf(`{"error":"event not found: %s"}`, eventID), http.StatusNotFound)
+		return
+	case errors.As(err, &conflict) && ifMatch == 0:
+		w.Header().Set("ETag", versionETag(conflict.Current))
+		http.Error(w, `{"error":"If-Match header with the event version (its ETag) is required to change an event"}`, http.StatusPreconditionRequired)
+		return
+	case errors.As(err, &conflict):
+		writeVersionConflict(w, eventID, conflict.Current)
+		return
+	case err != nil:
+		logger.Error("Failed to replace event", err, map[string]interface{}{"event_id": eventID})
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	status := "unchanged"
+	if changed {
+		status = "revised"
+		logger.Info("Event revised", map[string]interface{}{"event_id": eventID, "version": version, "actor": actor})
+	}
+	w.Header().Set("ETag", versionETag(version))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"event_id": eventID, "version": version, "status": status})
+}
+
+// isEventWriter reports whether the hashed API key may PUT events.
+func isEventWriter(keyHash string) bool {
+	for _, k := range cfg.EventWriterKeys {
+		if k == keyHash {
+			return true
+		}
+	}
+	return false
+}
+
+// handleEventRevisions serves GET /admin/events/{id}/revisions, the contents PUTs
+// have replaced, newest first. An event that doesn't exist, e.g. one erased with
+// a hard delete, is 404 whatever revisions remain.
+func handleEventRevisions(w http.ResponseWriter, r *http.Request, eventID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if _, err := dbClient.GetEventProducerKey(eventID); err == db.ErrNotFound {
+		http.Error(w, `{"error":"event not found"}`, http.StatusNotFound)
+		return
+	} else if err != nil {
+		logger.Error("Failed to look up event", err, map[string]interface{}{"event_id": eventID})
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	revisions, err := dbClient.ListEventRevisions(eventID)
+	if err != nil {
+		logger.Error("Failed to list event revisions", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	if !canReadPII(r) {
+		for i := range revisions {
+			c := &revisions[i].Content
+			c.UserID, c.Metadata = maskUserID(c.UserID), maskMetadata(c.Metadata)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"event_id": eventID, "revisions": revisions})
+}
+
+// eventAsOf reads eventID as it stood at asOf, for GET /events/{id}?as_of=: the
+// current record with the producer content of the revision then current, if a PUT
+// has replaced it since. That revision is returned too, nil for current content.
+// An event persisted after asOf is db.ErrNotFound, as is a soft-deleted one.
+func eventAsOf(eventID string, asOf time.Time) (*domain.EventRecord, *domain.EventRevision, error) {
+	record, err := dbClient.GetEventByID(eventID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if record.CreatedAt.After(asOf) {
+		return nil, nil, db.ErrNotFound
+	}
+	revision, err := dbClient.EventRevisionAsOf(eventID, asOf)
+	if err == db.ErrNotFound {
+		return record, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	c := revision.Content
+	record.UserID, record.Amount, record.Currency, record.Merchant = c.UserID, c.Amount, c.Currency, c.Merchant
+	record.Timestamp, record.Metadata, record.Canary = c.Timestamp, c.Metadata, c.Canary
+	record.Version = revision.Version
+	return record, revision, nil
+}
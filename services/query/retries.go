@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/bulkretry"
+	"github.com/fluxa/fluxa/internal/clients"
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/google/uuid"
+)
+
+var (
+	retrierMu sync.Mutex
+	retrier   *bulkretry.Runner
+)
+
+// getRetrier returns the bulk retry runner, connecting to RabbitMQ on first use
+// like getNotifier. A failed connect is not cached.
+func getRetrier() (*bulkretry.Runner, error) {
+	retrierMu.Lock()
+	defer retrierMu.Unlock()
+	if retrier != nil {
+		return retrier, nil
+	}
+	queue, err := clients.New(cfg, "query").Queue()
+	if err != nil {
+		return nil, fmt.Errorf("connect to RabbitMQ: %w", err)
+	}
+	retrier = bulkretry.NewRunner(dbClient, idemClient, queue, metrics, logger, bulkretry.Options{
+		Rate: cfg.BulkRetryRatePerSec,
+	})
+	return retrier, nil
+}
+
+type retryRequest struct {
+	Reason string     `json:"reason"`
+	From   *time.Time `json:"from"`
+	To     *time.Time `json:"to"`
+}
+
+// handleRetries serves POST /admin/retries: it records a bulk retry job for the
+// events that failed permanently with reason between from and to, and starts it
+// in the background, answering 202 with the job; poll GET /admin/retries/{id}.
+// The caller is identified by the X-Actor header, kept on the job.
+func handleRetries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+	if actor == "" {
+		http.Error(w, `{"error":"X-Actor header is required"}`, http.StatusBadRequest)
+		return
+	}
+	var req retryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, `{"error":"reason is required"}`, http.StatusBadRequest)
+		return
+	}
+	if req.From == nil || req.To == nil || !req.From.Before(*req.To) {
+		http.Error(w, `{"error":"from and to are required, with from before to"}`, http.StatusBadRequest)
+		return
+	}
+
+	runner, err := getRetrier()
+	if err != nil {
+		logger.Error("Failed to connect to RabbitMQ for bulk retry", err)
+		http.Error(w, `{"error":"queue unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+	job := &domain.RetryJob{
+		JobID:  uuid.New().String(),
+		Reason: req.Reason,
+		From:   req.From.UTC(),
+		To:     req.To.UTC(),
+		Actor:  actor,
+		Status: domain.RetryJobStatusPending,
+	}
+	if err := dbClient.CreateRetryJob(job); err != nil {
+		logger.Error("Failed to create retry job", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	// The runner owns job from here on; answer with a copy.
+	resp := *job
+	runner.Start(job)
+
+	logger.Info("Bulk retry started", map[string]interface{}{"job_id": resp.JobID, "reason": resp.Reason, "actor": actor})
+	w.Header().Set("Location", "/admin/retries/"+resp.JobID)
+	writeJSON(w, http.StatusAccepted, &resp)
+}
+
+// handleGetRetry serves GET /admin/retries/{id}: the job's status and progress.
+func handleGetRetry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	jobID := strings.TrimPrefix(r.URL.Path, "/admin/retries/")
+	if jobID == "" || strings.Contains(jobID, "/") {
+		http.Error(w, `{"error":"job_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	job, err := dbClient.GetRetryJob(jobID)
+	if err == db.ErrNotFound {
+		http.Error(w, fmt.Sprintf(`{"error":"retry job not found: %s"}`, jobID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to query retry job", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
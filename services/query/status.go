@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fluxa/fluxa/internal/domain"
+	"github.com/fluxa/fluxa/internal/metricdef"
+)
+
+// maxStatusBatch bounds one batch status lookup; the IDs travel as a single array parameter.
+const maxStatusBatch = 100
+
+type eventStatusRequest struct {
+	EventIDs []string `json:"event_ids"`
+}
+
+type eventStatus struct {
+	EventID     string    `json:"event_id"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+	ErrorReason *string   `json:"error_reason,omitempty"`
+	Timeline    *timeline `json:"timeline,omitempty"`
+}
+
+// timeline is an event's stage timestamps plus the time spent between them:
+// queued_ms (received to dequeued), persist_ms (dequeued to persisted) and
+// notify_ms (persisted to ack delivered, absent until delivered).
+type timeline struct {
+	*domain.EventTimeline
+	QueuedMs  int64  `json:"queued_ms"`
+	PersistMs int64  `json:"persist_ms"`
+	NotifyMs  *int64 `json:"notify_ms,omitempty"`
+}
+
+func newTimeline(t *domain.EventTimeline) *timeline {
+	out := &timeline{
+		EventTimeline: t,
+		QueuedMs:      t.DequeuedAt.Sub(t.ReceivedAt).Milliseconds(),
+		PersistMs:     t.PersistedAt.Sub(t.DequeuedAt).Milliseconds(),
+	}
+	if t.NotifiedAt != nil {
+		ms := t.NotifiedAt.Sub(t.PersistedAt).Milliseconds()
+		out.NotifyMs = &ms
+	}
+	return out
+}
+
+// handleEventStatuses serves /admin/events/status: the processing (idempotency) status
+// of up to maxStatusBatch events, resolved in one query, with each persisted event's
+// processing timeline. IDs come from ?ids=a,b,c on GET or {"event_ids":[...]} on
+// POST. Unknown IDs are listed under "missing", in request order.
+func handleEventStatuses(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	switch r.Method {
+	case http.MethodGet:
+		ids = strings.Split(r.URL.Query().Get("ids"), ",")
+	case http.MethodPost:
+		var req eventStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+			return
+		}
+		ids = req.EventIDs
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	ids = uniqueNonEmpty(ids)
+	if len(ids) == 0 {
+		http.Error(w, `{"error":"at least one event id is required"}`, http.StatusBadRequest)
+		return
+	}
+	if len(ids) > maxStatusBatch {
+		http.Error(w, fmt.Sprintf(`{"error":"at most %d event ids per request"}`, maxStatusBatch), http.StatusBadRequest)
+		return
+	}
+
+	records, err := idemClient.GetStatuses(ids)
+	if err != nil {
+		logger.Error("Failed to get event statuses", err)
+		metrics.IncCounter(metricdef.QueryTotal, "status", "error")
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	// Timelines are supplementary: without them the statuses are still right.
+	timelines, err := dbClient.GetEventTimelines(ids)
+	if err != nil {
+		logger.Warn("Failed to get event timelines", map[string]interface{}{"error": err.Error()})
+	}
+
+	statuses := make([]eventStatus, 0, len(records))
+	missing := []string{}
+	for _, id := range ids {
+		rec, ok := records[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		status := eventStatus{
+			EventID:     rec.EventID,
+			Status:      rec.Status,
+			Attempts:    rec.Attempts,
+			FirstSeenAt: rec.FirstSeenAt,
+			LastSeenAt:  rec.LastSeenAt,
+			ErrorReason: rec.ErrorReason,
+		}
+		if t, ok := timelines[id]; ok {
+			status.Timeline = newTimeline(t)
+		}
+		statuses = append(statuses, status)
+	}
+
+	metrics.IncCounter(metricdef.QueryTotal, "status", "found")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"statuses": statuses, "missing": missing})
+}
+
+// uniqueNonEmpty trims ids and drops blanks and repeats, preserving first-seen order.
+func uniqueNonEmpty(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
+
+// maxProducerStatusBatch bounds POST /events/status-batch, sized for a producer's
+// whole submission file rather than an operator's spot check.
+const maxProducerStatusBatch = 500
+
+// Compact status codes returned by /events/status-batch.
+const (
+	statusPending    = "pending" // no processing record yet: still queued, or never ingested
+	statusProcessing = "processing"
+	statusSuccess    = "success"
+	statusFailed     = "failed"
+)
+
+// handleStatusBatch serves POST /events/status-batch for producers reconciling a
+// submission: {"event_ids":[...]} (up to maxProducerStatusBatch) in, one compact code
+// per ID out, plus the failure reason code for failed IDs and per-code totals:
+//
+//	{"statuses":{"e1":"success","e2":"failed"},"reasons":{"e2":"hash_mismatch"},
+//	 "counts":{"success":1,"failed":1}}
+//
+// Ingest keeps no record of accepted events, so an ID the processor hasn't picked
+// up yet and an ID that was never submitted both report "pending".
+func handleStatusBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	var req eventStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	ids := uniqueNonEmpty(req.EventIDs)
+	if len(ids) == 0 {
+		http.Error(w, `{"error":"at least one event id is required"}`, http.StatusBadRequest)
+		return
+	}
+	if len(ids) > maxProducerStatusBatch {
+		http.Error(w, fmt.Sprintf(`{"error":"at most %d event ids per request"}`, maxProducerStatusBatch), http.StatusBadRequest)
+		return
+	}
+
+	records, err := idemClient.GetStatuses(ids)
+	if err != nil {
+		logger.Error("Failed to get event statuses", err)
+		metrics.IncCounter(metricdef.QueryTotal, "status", "error")
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	statuses := make(map[string]string, len(ids))
+	reasons := map[string]string{}
+	counts := map[string]int{}
+	for _, id := range ids {
+		code := statusPending
+		if rec, ok := records[id]; ok {
+			code = rec.Status
+			if code == statusFailed && rec.ErrorReason != nil {
+				reasons[id] = reasonCode(*rec.ErrorReason)
+			}
+		}
+		statuses[id] = code
+		counts[code]++
+	}
+
+	metrics.IncCounter(metricdef.QueryTotal, "status", "found")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"statuses": statuses, "reasons": reasons, "counts": counts})
+}
+
+// reasonCode reduces a stored error reason ("non-retryable: hash_mismatch: <cause>")
+// to its reason code ("hash_mismatch"). Unrecognized text is returned as is.
+func reasonCode(reason string) string {
+	for _, prefix := range []string{"non-retryable: ", "retryable: "} {
+		if rest, ok := strings.CutPrefix(reason, prefix); ok {
+			code, _, _ := strings.Cut(rest, ":")
+			return code
+		}
+	}
+	return reason
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// versionETag renders an event version as the ETag of GET /events/{id}.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// requireIfMatch reads the event version an admin mutation was based on from
+// If-Match (the ETag of GET /events/{id}; a bare number is accepted too). Without
+// it the request fails with 428, so no correction can be applied blind; a value
+// that isn't a version is 400. On failure the response has been written.
+func requireIfMatch(w http.ResponseWriter, r *http.Request) (int, bool) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" {
+		http.Error(w, `{"error":"If-Match header with the event version (its ETag) is required"}`, http.StatusPreconditionRequired)
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(raw, "W/"), `"`))
+	if err != nil || version < 1 {
+		http.Error(w, fmt.Sprintf(`{"error":"If-Match must be an event version, got %q"}`, raw), http.StatusBadRequest)
+		return 0, false
+	}
+	return version, true
+}
+
+// writeVersionConflict answers a mutation whose If-Match is stale with 412 and the
+// current version, so the caller can re-read and retry.
+func writeVersionConflict(w http.ResponseWriter, eventID string, current int) {
+	w.Header().Set("ETag", versionETag(current))
+	writeJSON(w, http.StatusPreconditionFailed, map[string]interface{}{
+		"error":           "event was modified since it was read; re-read it and retry",
+		"event_id":        eventID,
+		"current_version": current,
+	})
+}
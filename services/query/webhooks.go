@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fluxa/fluxa/internal/db"
+	"github.com/fluxa/fluxa/internal/domain"
+)
+
+type webhookRequest struct {
+	URL string `json:"url"`
+}
+
+// handleWebhooks manages the ack webhook of the caller's API key (X-API-Key):
+// PUT registers or replaces it, GET shows it, DELETE removes it. Keys are stored
+// hashed, the same way ingest tags the events it enqueues.
+func handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if key == "" {
+		http.Error(w, `{"error":"X-API-Key header is required"}`, http.StatusUnauthorized)
+		return
+	}
+	keyHash := domain.HashAPIKey(key)
+
+	switch r.Method {
+	case http.MethodPut:
+		putWebhook(w, r, keyHash)
+	case http.MethodGet:
+		hook, err := dbClient.GetProducerWebhook(keyHash)
+		if err == db.ErrNotFound {
+			http.Error(w, `{"error":"no webhook registered"}`, http.StatusNotFound)
+			return
+		} else if err != nil {
+			logger.Error("Failed to get producer webhook", err)
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, hook)
+	case http.MethodDelete:
+		if err := dbClient.DeleteProducerWebhook(keyHash); err == db.ErrNotFound {
+			http.Error(w, `{"error":"no webhook registered"}`, http.StatusNotFound)
+			return
+		} else if err != nil {
+			logger.Error("Failed to delete producer webhook", err)
+			http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// putWebhook stores the URL with a fresh signing secret. The secret is returned
+// only in this response; re-registering rotates it. A key whose webhook secret
+// is in the secret bundle is signed with that one instead, so none is returned.
+func putWebhook(w http.ResponseWriter, r *http.Request, keyHash string) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, `{"error":"url must be an absolute http or https URL"}`, http.StatusBadRequest)
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		logger.Error("Failed to generate webhook secret", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	hook := &domain.ProducerWebhook{APIKeyHash: keyHash, URL: u.String(), Secret: hex.EncodeToString(secret)}
+	if err := dbClient.UpsertProducerWebhook(hook); err != nil {
+		logger.Error("Failed to upsert producer webhook", err)
+		http.Error(w, `{"error":"internal server error"}`, http.StatusInternalServerError)
+		return
+	}
+	logger.Info("Producer webhook registered", map[string]interface{}{"url": hook.URL})
+	resp := map[string]interface{}{
+		"url":        hook.URL,
+		"secret":     hook.Secret,
+		"created_at": hook.CreatedAt,
+		"updated_at": hook.UpdatedAt,
+	}
+	if _, managed := bundle.WebhookSecret(keyHash); managed {
+		delete(resp, "secret")
+		resp["secret_managed"] = true
+	}
+	writeJSON(w, http.StatusOK, resp)
+}